@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"parfum/config"
 	"parfum/internal/handler"
+	"parfum/internal/repository"
+	"parfum/internal/service/i18n"
 	"parfum/traits/database"
 	"parfum/traits/logger"
 	"syscall"
@@ -27,6 +30,7 @@ func main() {
 	}
 
 	zapLogger.Info("🌟 Starting ZHAD Perfume Application...")
+	i18n.SetLogger(zapLogger)
 
 	// Initialize configuration
 	cfg, err := config.NewConfig()
@@ -36,17 +40,18 @@ func main() {
 	}
 
 	// Initialize database
-	db, err := sql.Open("sqlite3", cfg.DBName)
+	sqliteSettings := database.SQLiteSettings{
+		JournalMode:  cfg.DBJournalMode,
+		BusyTimeout:  time.Duration(cfg.DBBusyTimeoutMS) * time.Millisecond,
+		MaxOpenConns: cfg.DBMaxOpenConns,
+	}
+	db, err := sql.Open("sqlite3", database.DSN(cfg.DBName, sqliteSettings))
 	if err != nil {
 		zapLogger.Fatal("Failed to connect to database", zap.Error(err))
 		return
 	}
 	defer db.Close()
 
-	db.Exec(`DROP TABLE orders`)
-	db.Exec(`DROP TABLE client`)
-	db.Exec(`DROP TABLE loto`)
-
 	// Test database connection
 	if err = db.Ping(); err != nil {
 		zapLogger.Fatal("Failed to ping database", zap.Error(err))
@@ -55,6 +60,15 @@ func main() {
 
 	zapLogger.Info("Database connected successfully", zap.String("db", cfg.DBName))
 
+	if err := database.Configure(db, sqliteSettings); err != nil {
+		zapLogger.Fatal("Failed to configure database", zap.Error(err))
+		return
+	}
+	zapLogger.Info("Database configured",
+		zap.String("journal_mode", cfg.DBJournalMode),
+		zap.Int("busy_timeout_ms", cfg.DBBusyTimeoutMS),
+		zap.Int("max_open_conns", cfg.DBMaxOpenConns))
+
 	// Create database tables
 	if err := database.CreateTables(db); err != nil {
 		zapLogger.Fatal("Failed to create database tables", zap.Error(err))
@@ -68,7 +82,31 @@ func main() {
 
 	// Run database migrations
 	if err := database.MigrateDatabase(db); err != nil {
-		zapLogger.Warn("Failed to run database migrations", zap.Error(err))
+		zapLogger.Fatal("Failed to run database migrations", zap.Error(err))
+		return
+	}
+
+	// "parfum migrate" only brings the schema up to date
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		version, err := database.SchemaVersion(db)
+		if err != nil {
+			zapLogger.Fatal("Failed to read schema version", zap.Error(err))
+			return
+		}
+		zapLogger.Info("Database migrated", zap.String("version", version))
+		return
+	}
+
+	// Make sure every table the repositories query exists
+	if err := database.VerifyTables(db); err != nil {
+		var missingErr *database.MissingTablesError
+		if errors.As(err, &missingErr) && !cfg.StrictSchemaCheck {
+			zapLogger.Error("Database is missing tables used by repositories",
+				zap.Strings("missing", missingErr.Tables))
+		} else {
+			zapLogger.Fatal("Database schema check failed", zap.Error(err))
+			return
+		}
 	}
 
 	// Optionally seed sample data (only in development)
@@ -82,15 +120,24 @@ func main() {
 
 	// Initialize context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
-	redisClient, err := database.ConnectRedis(ctx, zapLogger)
+	redisSettings := database.RedisSettings{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	}
+	redisClient, err := database.ConnectRedis(ctx, redisSettings, zapLogger)
 	if err != nil {
+		// Bot state is kept in memory until Redis comes up
 		zapLogger.Error("error connecting to Redis", zap.Error(err))
-		return
+		redisClient = database.NewRedisClient(redisSettings)
 	}
 	defer database.CloseRedis(redisClient, zapLogger)
 
 	// Initialize handler with database repositories
-	handle := handler.NewHandler(cfg, zapLogger, ctx, db, redisClient)
+	handle, err := handler.NewHandler(cfg, zapLogger, ctx, db, redisClient)
+	if err != nil {
+		zapLogger.Fatal("Failed to initialize handler", zap.Error(err))
+	}
 	var deleteWebhook func(token string) error
 	deleteWebhook = func(token string) error {
 		client := &http.Client{}
@@ -109,14 +156,38 @@ func main() {
 	if cfg.Token != "" {
 		// Replace with your bot token
 		token := cfg.Token
-		if err := deleteWebhook(token); err != nil {
-			zapLogger.Error("error creating bot config", zap.Error(err))
-			return
+		// Polling doesn't work while a webhook is set
+		if cfg.BotMode == config.BotModePolling {
+			if err := deleteWebhook(token); err != nil {
+				zapLogger.Error("error creating bot config", zap.Error(err))
+				return
+			}
 		}
 		opts := []bot.Option{
+			bot.WithMiddlewares(handle.SkipDuplicateUpdates, handle.SerializeUserUpdates),
 			bot.WithDefaultHandler(handle.DefaultHandler),
 			bot.WithCallbackQueryDataHandler("buy_parfume", bot.MatchTypePrefix, handle.BuyParfumeHandler),
 			bot.WithCallbackQueryDataHandler("count_", bot.MatchTypePrefix, handle.CountHandler),
+			bot.WithCallbackQueryDataHandler(handler.ManualPaymentCallbackPrefix, bot.MatchTypePrefix, handle.ManualPaymentHandler),
+			bot.WithCallbackQueryDataHandler(handler.MyOrdersCallbackPrefix, bot.MatchTypePrefix, handle.MyOrdersHandler),
+			bot.WithCallbackQueryDataHandler(handler.BroadcastCallbackPrefix, bot.MatchTypePrefix, handle.BroadcastCallbackHandler),
+			bot.WithCallbackQueryDataHandler(handler.LanguageCallbackPrefix, bot.MatchTypePrefix, handle.LanguageHandler),
+			bot.WithCallbackQueryDataHandler(handler.StatsCallbackPrefix, bot.MatchTypePrefix, handle.StatsHandler),
+			bot.WithCallbackQueryDataHandler(handler.DrawCallbackPrefix, bot.MatchTypePrefix, handle.DrawHandler),
+			bot.WithMessageTextHandler(handler.CancelCommand, bot.MatchTypeCommandStartOnly, handle.CancelHandler),
+			bot.WithMessageTextHandler(handler.MyOrdersCommand, bot.MatchTypeCommandStartOnly, handle.MyOrdersHandler),
+			bot.WithMessageTextHandler(handler.BroadcastCommand, bot.MatchTypeCommandStartOnly, handle.BroadcastHandler),
+			bot.WithMessageTextHandler(handler.LanguageCommand, bot.MatchTypeCommandStartOnly, handle.LanguageHandler),
+			bot.WithMessageTextHandler(handler.HelpCommand, bot.MatchTypeCommandStartOnly, handle.HelpHandler),
+			bot.WithMessageTextHandler(handler.StatsCommand, bot.MatchTypeCommandStartOnly, handle.StatsHandler),
+			bot.WithMessageTextHandler(handler.PromoCommand, bot.MatchTypeCommandStartOnly, handle.PromoHandler),
+			bot.WithMessageTextHandler(handler.DrawCommand, bot.MatchTypeCommandStartOnly, handle.DrawHandler),
+		}
+		for _, text := range i18n.All(i18n.CancelButton) {
+			opts = append(opts, bot.WithMessageTextHandler(text, bot.MatchTypeExact, handle.CancelHandler))
+		}
+		if cfg.BotMode == config.BotModeWebhook {
+			opts = append(opts, bot.WithWebhookSecretToken(cfg.WebhookSecret()))
 		}
 
 		b, err = bot.New(cfg.Token, opts...)
@@ -125,6 +196,19 @@ func main() {
 			return
 		}
 		zapLogger.Info("Telegram bot initialized successfully")
+
+		handle.RegisterCommands(ctx, b)
+
+		if cfg.BotMode == config.BotModeWebhook {
+			if _, err := b.SetWebhook(ctx, &bot.SetWebhookParams{
+				URL:         cfg.WebhookURL(),
+				SecretToken: cfg.WebhookSecret(),
+			}); err != nil {
+				zapLogger.Fatal("Failed to set webhook", zap.Error(err), zap.String("base_url", cfg.BaseURL))
+				return
+			}
+			zapLogger.Info("Telegram webhook set", zap.String("base_url", cfg.BaseURL))
+		}
 	} else {
 		zapLogger.Warn("No Telegram bot token provided, running without bot integration")
 	}
@@ -139,24 +223,51 @@ func main() {
 		handle.StartWebServer(ctx, b)
 	}()
 
-	// Start Telegram bot if available
+	// Start Telegram bot if available. In webhook mode the web server takes the updates
+	// and the bot only runs the handlers.
 	if b != nil {
 		go func() {
-			zapLogger.Info("Starting Telegram bot...")
+			zapLogger.Info("Starting Telegram bot...", zap.String("mode", cfg.BotMode))
+			if cfg.BotMode == config.BotModeWebhook {
+				b.StartWebhook(ctx)
+				return
+			}
 			b.Start(ctx)
 		}()
 	}
 
-	// Optional: Start cleanup routine
+	// Optional: Start cleanup routine. It only counts stale orders until CLEANUP_DRY_RUN=false
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
 	go func() {
 		cleanupTicker := time.NewTicker(24 * time.Hour)
 		defer cleanupTicker.Stop()
 		for {
 			select {
 			case <-cleanupTicker.C:
-				if err := database.CleanupOldData(db, 30); err != nil {
-					zapLogger.Error("Failed to cleanup old data", zap.Error(err))
+				if cfg.CleanupEnabled {
+					if _, err := database.CleanupOldData(db, cfg.CleanupRetentionDays, cfg.CleanupDryRun); err != nil {
+						zapLogger.Error("Failed to cleanup old data", zap.Error(err))
+					}
 				}
+				if removed, err := idempotencyRepo.DeleteExpired(ctx); err != nil {
+					zapLogger.Error("Failed to cleanup idempotency keys", zap.Error(err))
+				} else {
+					zapLogger.Info("Cleaned up expired idempotency keys", zap.Int64("removed", removed))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Remind the winners of unclaimed prizes and expire the ones left too long
+	go func() {
+		prizeClaimTicker := time.NewTicker(24 * time.Hour)
+		defer prizeClaimTicker.Stop()
+		for {
+			select {
+			case <-prizeClaimTicker.C:
+				handle.ProcessPrizeClaims(ctx)
 			case <-ctx.Done():
 				return
 			}
@@ -167,6 +278,14 @@ func main() {
 	zapLogger.Info("🛑 Shutdown signal received, gracefully stopping Lumen application...")
 	cancel()
 
+	if b != nil && cfg.BotMode == config.BotModeWebhook {
+		deleteCtx, deleteCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if _, err := b.DeleteWebhook(deleteCtx, &bot.DeleteWebhookParams{}); err != nil {
+			zapLogger.Error("Failed to delete webhook", zap.Error(err))
+		}
+		deleteCancel()
+	}
+
 	// Close database connection
 	if err := db.Close(); err != nil {
 		zapLogger.Error("Error closing database connection", zap.Error(err))
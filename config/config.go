@@ -2,7 +2,12 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Config contains application configuration parameters
@@ -18,13 +23,62 @@ type Config struct {
 	StartPhotoId      string `json:"start_photo_id"`
 	StartVideoId      string `json:"start_video_id"`
 	InstructorVideoId string `json:"instructor_video"`
-	Cost              int    `json:"cost"`
 	BotUsername       string `json:"bot_username"`
+	BotMode           string `json:"bot_mode"` // BotModePolling or BotModeWebhook
 	Bin               int    `json:"bin"`
 	Bin2              int    `json:"bin2"`
 	Bin3              int    `json:"bin3"`
 	Bin4              int    `json:"bin4"`
 	Bin5              int    `json:"bin5"`
+
+	Prices          map[string]int `json:"prices"`            // price in tenge per volume/SKU, e.g. "30ml"
+	DefaultVolume   string         `json:"default_volume"`    // the volume the bot's count buttons sell
+	MaxOrderCount   int            `json:"max_order_count"`   // the most kits a user may type in the bot
+	KaspiPaymentURL string         `json:"kaspi_payment_url"` // the shop's Kaspi payment page
+
+	// PaymentLinkTemplate is the bot's payment link with {amount} in place of the sum to
+	// pay, e.g. "https://pay.kaspi.kz/pay/xopyuql9?amount={amount}". Empty means the bot
+	// sends KaspiPaymentURL as is.
+	PaymentLinkTemplate string `json:"payment_link_template"`
+
+	DBJournalMode   string `json:"db_journal_mode"`    // SQLite journal mode, WAL by default
+	DBBusyTimeoutMS int    `json:"db_busy_timeout_ms"` // how long SQLite waits for a lock
+	DBMaxOpenConns  int    `json:"db_max_open_conns"`  // size of the SQLite connection pool
+
+	RedisAddr     string `json:"redis_addr"` // host:port of the Redis server
+	RedisPassword string `json:"-"`          // empty when Redis has no AUTH
+	RedisDB       int    `json:"redis_db"`   // logical Redis database number
+
+	PendingReceiptTTLMinutes int `json:"pending_receipt_ttl_minutes"`
+	LowStockThreshold        int `json:"low_stock_threshold"`
+	CartTTLHours             int `json:"cart_ttl_hours"`
+	MaxPhotoSizeMB           int `json:"max_photo_size_mb"`
+	TelegramSendRate         int `json:"telegram_send_rate"` // queued notifications sent per second
+
+	SpinCooldownSeconds int `json:"spin_cooldown_seconds"` // least time between two spins of a user
+	DailySpinCap        int `json:"daily_spin_cap"`        // most spins per user per day, 0 for no cap
+
+	PrizeTokenSecret     string `json:"-"`                       // signs the prize tokens of spins; random per process if empty
+	PrizeTokenTTLMinutes int    `json:"prize_token_ttl_minutes"` // how long a spin's prize token can complete the prize
+
+	PrizeReminderDays int `json:"prize_reminder_days"` // remind a winner who hasn't sent an address after this many days, 0 disables
+	PrizeExpiryDays   int `json:"prize_expiry_days"`   // expire a prize that has no address after this many days, 0 disables
+
+	CleanupEnabled       bool `json:"cleanup_enabled"`        // delete old unpaid orders once a day
+	CleanupRetentionDays int  `json:"cleanup_retention_days"` // how old an unpaid order gets before it is deleted
+	CleanupDryRun        bool `json:"cleanup_dry_run"`        // only log how many orders cleanup would delete; on unless CLEANUP_DRY_RUN=false
+
+	ReceiptAlertUserFailures   int `json:"receipt_alert_user_failures"`   // alert admins when a user has more failed receipts in an hour, 0 disables
+	ReceiptAlertGlobalFailures int `json:"receipt_alert_global_failures"` // alert admins when all users have more failed receipts in an hour, 0 disables
+	ReceiptAlertCooldownMin    int `json:"receipt_alert_cooldown_min"`    // least time between two alerts about the same user, or about all users
+
+	StrictSchemaCheck bool `json:"strict_schema_check"` // exit on startup if tables are missing
+	SimulationEnabled bool `json:"simulation_enabled"`  // expose /api/test/simulate-order (never in production)
+
+	AdminToken string `json:"-"` // bearer token for admin API routes; empty disables them
+
+	ReceiptFormats []string `json:"receipt_formats"` // accepted receipt MIME types
+	PhotoFormats   []string `json:"photo_formats"`   // accepted perfume photo MIME types
 }
 
 // NewConfig creates and returns a new configuration instance
@@ -41,13 +95,49 @@ func NewConfig() (*Config, error) {
 		StartPhotoId:      "AgACAgIAAxkBAAMDaNZNqmdbFqp471RV-PTuHqIDn-MAAhUJMhuVX7FKvMqjmDrEfY4BAAMCAAN3AAM2BA",
 		StartVideoId:      "BAACAgIAAxkBAAIGQ2hs996Wo5tLH-aZu32XGWhcBjMxAALFeQACM7hoSwWQNDUxWvt-NgQ",
 		InstructorVideoId: "BAACAgIAAxkBAAIExWhf1MIAAZ0mGONHcGxOWRPHa4SRLAACXnUAAj8UAUt-qpkmBZGhqjYE",
-		Cost:              2499,
 		BotUsername:       "zhad_parfume_bot",
+		BotMode:           BotModePolling,
 		Bin:               951125301078,
 		Bin2:              60301551728,
 		Bin3:              11225600097,
 		Bin4:              10514551360,
 		Bin5:              980517451262,
+
+		Prices:          map[string]int{"30ml": 2499},
+		DefaultVolume:   "30ml",
+		MaxOrderCount:   200,
+		KaspiPaymentURL: "https://pay.kaspi.kz/pay/xopyuql9",
+
+		DBJournalMode:   "WAL",
+		DBBusyTimeoutMS: 5000,
+		DBMaxOpenConns:  4,
+
+		RedisAddr: "localhost:6379",
+		RedisDB:   0,
+
+		PendingReceiptTTLMinutes: 30,
+		LowStockThreshold:        5,
+		CartTTLHours:             72,
+		MaxPhotoSizeMB:           10,
+		TelegramSendRate:         25,
+
+		SpinCooldownSeconds: 3,
+
+		PrizeTokenTTLMinutes: 60,
+
+		PrizeReminderDays: 3,
+		PrizeExpiryDays:   14,
+
+		CleanupEnabled:       true,
+		CleanupRetentionDays: 30,
+		CleanupDryRun:        true,
+
+		ReceiptAlertUserFailures:   3,
+		ReceiptAlertGlobalFailures: 20,
+		ReceiptAlertCooldownMin:    60,
+
+		ReceiptFormats: []string{"application/pdf"},
+		PhotoFormats:   []string{"image/jpeg", "image/png", "image/webp", "image/gif"},
 	}
 
 	// Override with environment variables if set
@@ -63,6 +153,14 @@ func NewConfig() (*Config, error) {
 		cfg.BaseURL = baseURL
 	}
 
+	if mode := os.Getenv("BOT_MODE"); mode != "" {
+		mode = strings.ToLower(strings.TrimSpace(mode))
+		if mode != BotModePolling && mode != BotModeWebhook {
+			return nil, fmt.Errorf("invalid BOT_MODE %q, want %q or %q", mode, BotModePolling, BotModeWebhook)
+		}
+		cfg.BotMode = mode
+	}
+
 	if dbName := os.Getenv("DB_NAME"); dbName != "" {
 		cfg.DBName = dbName
 	}
@@ -71,5 +169,296 @@ func NewConfig() (*Config, error) {
 		cfg.DBName = savePaymentsDir
 	}
 
+	if journalMode := os.Getenv("DB_JOURNAL_MODE"); journalMode != "" {
+		cfg.DBJournalMode = strings.ToUpper(strings.TrimSpace(journalMode))
+	}
+
+	if timeout := os.Getenv("DB_BUSY_TIMEOUT_MS"); timeout != "" {
+		if ms, err := strconv.Atoi(timeout); err == nil && ms >= 0 {
+			cfg.DBBusyTimeoutMS = ms
+		}
+	}
+
+	if conns := os.Getenv("DB_MAX_OPEN_CONNS"); conns != "" {
+		if value, err := strconv.Atoi(conns); err == nil && value > 0 {
+			cfg.DBMaxOpenConns = value
+		}
+	}
+
+	if redisAddr := os.Getenv("REDIS_ADDR"); redisAddr != "" {
+		cfg.RedisAddr = redisAddr
+	}
+
+	if redisPassword := os.Getenv("REDIS_PASSWORD"); redisPassword != "" {
+		cfg.RedisPassword = redisPassword
+	}
+
+	if redisDB := os.Getenv("REDIS_DB"); redisDB != "" {
+		value, err := strconv.Atoi(redisDB)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid REDIS_DB %q", redisDB)
+		}
+		cfg.RedisDB = value
+	}
+
+	if ttl := os.Getenv("PENDING_RECEIPT_TTL_MINUTES"); ttl != "" {
+		if minutes, err := strconv.Atoi(ttl); err == nil && minutes > 0 {
+			cfg.PendingReceiptTTLMinutes = minutes
+		}
+	}
+
+	if threshold := os.Getenv("LOW_STOCK_THRESHOLD"); threshold != "" {
+		if value, err := strconv.Atoi(threshold); err == nil && value >= 0 {
+			cfg.LowStockThreshold = value
+		}
+	}
+
+	if ttl := os.Getenv("CART_TTL_HOURS"); ttl != "" {
+		if hours, err := strconv.Atoi(ttl); err == nil && hours > 0 {
+			cfg.CartTTLHours = hours
+		}
+	}
+
+	if size := os.Getenv("MAX_PHOTO_SIZE_MB"); size != "" {
+		if mb, err := strconv.Atoi(size); err == nil && mb > 0 {
+			cfg.MaxPhotoSizeMB = mb
+		}
+	}
+
+	if rate := os.Getenv("TELEGRAM_SEND_RATE"); rate != "" {
+		if perSecond, err := strconv.Atoi(rate); err == nil && perSecond > 0 {
+			cfg.TelegramSendRate = perSecond
+		}
+	}
+
+	if cooldown := os.Getenv("SPIN_COOLDOWN_SECONDS"); cooldown != "" {
+		value, err := strconv.Atoi(cooldown)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid SPIN_COOLDOWN_SECONDS %q", cooldown)
+		}
+		cfg.SpinCooldownSeconds = value
+	}
+
+	if spinCap := os.Getenv("DAILY_SPIN_CAP"); spinCap != "" {
+		value, err := strconv.Atoi(spinCap)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid DAILY_SPIN_CAP %q", spinCap)
+		}
+		cfg.DailySpinCap = value
+	}
+
+	if secret := os.Getenv("PRIZE_TOKEN_SECRET"); secret != "" {
+		cfg.PrizeTokenSecret = secret
+	}
+
+	if ttl := os.Getenv("PRIZE_TOKEN_TTL_MINUTES"); ttl != "" {
+		value, err := strconv.Atoi(ttl)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid PRIZE_TOKEN_TTL_MINUTES %q", ttl)
+		}
+		cfg.PrizeTokenTTLMinutes = value
+	}
+
+	if days := os.Getenv("PRIZE_REMINDER_DAYS"); days != "" {
+		value, err := strconv.Atoi(days)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid PRIZE_REMINDER_DAYS %q", days)
+		}
+		cfg.PrizeReminderDays = value
+	}
+
+	if days := os.Getenv("PRIZE_EXPIRY_DAYS"); days != "" {
+		value, err := strconv.Atoi(days)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid PRIZE_EXPIRY_DAYS %q", days)
+		}
+		cfg.PrizeExpiryDays = value
+	}
+
+	// A winner is reminded before their prize expires
+	if cfg.PrizeReminderDays > 0 && cfg.PrizeExpiryDays > 0 && cfg.PrizeExpiryDays <= cfg.PrizeReminderDays {
+		return nil, fmt.Errorf("PRIZE_EXPIRY_DAYS %d must be greater than PRIZE_REMINDER_DAYS %d", cfg.PrizeExpiryDays, cfg.PrizeReminderDays)
+	}
+
+	if cleanup := os.Getenv("CLEANUP_ENABLED"); cleanup != "" {
+		cfg.CleanupEnabled = cleanup == "1" || cleanup == "true"
+	}
+
+	if days := os.Getenv("CLEANUP_RETENTION_DAYS"); days != "" {
+		value, err := strconv.Atoi(days)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid CLEANUP_RETENTION_DAYS %q", days)
+		}
+		cfg.CleanupRetentionDays = value
+	}
+
+	if dryRun := os.Getenv("CLEANUP_DRY_RUN"); dryRun != "" {
+		cfg.CleanupDryRun = dryRun == "1" || dryRun == "true"
+	}
+
+	if failures := os.Getenv("RECEIPT_ALERT_USER_FAILURES"); failures != "" {
+		value, err := strconv.Atoi(failures)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid RECEIPT_ALERT_USER_FAILURES %q", failures)
+		}
+		cfg.ReceiptAlertUserFailures = value
+	}
+
+	if failures := os.Getenv("RECEIPT_ALERT_GLOBAL_FAILURES"); failures != "" {
+		value, err := strconv.Atoi(failures)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid RECEIPT_ALERT_GLOBAL_FAILURES %q", failures)
+		}
+		cfg.ReceiptAlertGlobalFailures = value
+	}
+
+	if cooldown := os.Getenv("RECEIPT_ALERT_COOLDOWN_MINUTES"); cooldown != "" {
+		value, err := strconv.Atoi(cooldown)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid RECEIPT_ALERT_COOLDOWN_MINUTES %q", cooldown)
+		}
+		cfg.ReceiptAlertCooldownMin = value
+	}
+
+	if strict := os.Getenv("STRICT_SCHEMA_CHECK"); strict != "" {
+		cfg.StrictSchemaCheck = strict == "1" || strict == "true"
+	}
+
+	if simulation := os.Getenv("ENABLE_ORDER_SIMULATION"); simulation != "" {
+		cfg.SimulationEnabled = simulation == "1" || simulation == "true"
+	}
+
+	// The simulation writes real records, so production never gets it
+	if os.Getenv("LUMEN_ENV") == "production" {
+		cfg.SimulationEnabled = false
+	}
+
+	if adminToken := os.Getenv("ADMIN_API_TOKEN"); adminToken != "" {
+		cfg.AdminToken = adminToken
+	}
+
+	// Comma-separated volume=price pairs, e.g. "10ml=999,30ml=2499"
+	if prices := os.Getenv("PRICES"); prices != "" {
+		parsed := make(map[string]int)
+		for _, pair := range strings.Split(prices, ",") {
+			volume, price, ok := strings.Cut(pair, "=")
+			value, err := strconv.Atoi(strings.TrimSpace(price))
+			volume = strings.ToLower(strings.TrimSpace(volume))
+			if !ok || err != nil || value <= 0 || volume == "" {
+				return nil, fmt.Errorf("invalid PRICES entry %q", pair)
+			}
+			parsed[volume] = value
+		}
+		cfg.Prices = parsed
+	}
+
+	if volume := os.Getenv("DEFAULT_VOLUME"); volume != "" {
+		cfg.DefaultVolume = strings.ToLower(strings.TrimSpace(volume))
+	}
+
+	if maxCount := os.Getenv("MAX_ORDER_COUNT"); maxCount != "" {
+		value, err := strconv.Atoi(maxCount)
+		if err != nil || value < 1 {
+			return nil, fmt.Errorf("invalid MAX_ORDER_COUNT %q", maxCount)
+		}
+		cfg.MaxOrderCount = value
+	}
+
+	if kaspiURL := os.Getenv("KASPI_PAYMENT_URL"); kaspiURL != "" {
+		cfg.KaspiPaymentURL = kaspiURL
+	}
+
+	if linkTemplate := os.Getenv("PAYMENT_LINK_TEMPLATE"); linkTemplate != "" {
+		if !strings.Contains(linkTemplate, paymentAmountPlaceholder) {
+			return nil, fmt.Errorf("PAYMENT_LINK_TEMPLATE has no %s placeholder", paymentAmountPlaceholder)
+		}
+		cfg.PaymentLinkTemplate = linkTemplate
+	}
+
+	if _, ok := cfg.Prices[cfg.DefaultVolume]; !ok {
+		return nil, fmt.Errorf("no price configured for default volume %q", cfg.DefaultVolume)
+	}
+
+	// Comma-separated MIME types, e.g. "application/pdf,image/jpeg,image/png"
+	if formats := os.Getenv("RECEIPT_FORMATS"); formats != "" {
+		var accepted []string
+		for _, format := range strings.Split(formats, ",") {
+			if format = strings.ToLower(strings.TrimSpace(format)); format != "" {
+				accepted = append(accepted, format)
+			}
+		}
+		if len(accepted) > 0 {
+			cfg.ReceiptFormats = accepted
+		}
+	}
+
+	// Comma-separated MIME types, e.g. "image/jpeg,image/png"
+	if formats := os.Getenv("PHOTO_FORMATS"); formats != "" {
+		var accepted []string
+		for _, format := range strings.Split(formats, ",") {
+			if format = strings.ToLower(strings.TrimSpace(format)); format != "" {
+				accepted = append(accepted, format)
+			}
+		}
+		if len(accepted) > 0 {
+			cfg.PhotoFormats = accepted
+		}
+	}
+
 	return cfg, nil
 }
+
+// How the bot receives updates, set with BOT_MODE. In polling mode (the default) it keeps
+// asking Telegram for them. In webhook mode Telegram posts them to WebhookURL on the web
+// server, which suits replicas behind a load balancer; BASE_URL must then be the public
+// HTTPS address of the server.
+const (
+	BotModePolling = "polling"
+	BotModeWebhook = "webhook"
+)
+
+// WebhookPath is where the web server takes updates in webhook mode. It is derived from the
+// token so it can't be guessed, and stays the same across restarts and replicas.
+func (c *Config) WebhookPath() string {
+	sum := sha256.Sum256([]byte("webhook-path:" + c.Token))
+	return "/telegram/webhook/" + hex.EncodeToString(sum[:16])
+}
+
+// WebhookURL is the address given to Telegram with setWebhook
+func (c *Config) WebhookURL() string {
+	return strings.TrimRight(c.BaseURL, "/") + c.WebhookPath()
+}
+
+// WebhookSecret is sent by Telegram in the X-Telegram-Bot-Api-Secret-Token header of every
+// webhook request; updates without it are dropped
+func (c *Config) WebhookSecret() string {
+	sum := sha256.Sum256([]byte("webhook-secret:" + c.Token))
+	return hex.EncodeToString(sum[:])
+}
+
+// paymentAmountPlaceholder is replaced with the sum to pay in PaymentLinkTemplate
+const paymentAmountPlaceholder = "{amount}"
+
+// PaymentLink returns the link the bot sends to pay amount tenge
+func (c *Config) PaymentLink(amount int) string {
+	if c.PaymentLinkTemplate == "" {
+		return c.KaspiPaymentURL
+	}
+	return strings.ReplaceAll(c.PaymentLinkTemplate, paymentAmountPlaceholder, strconv.Itoa(amount))
+}
+
+// UnitPrice returns the price of one kit of the default volume
+func (c *Config) UnitPrice() int {
+	return c.Prices[c.DefaultVolume]
+}
+
+// Bins returns the BINs a receipt may be paid to; unset ones are left out
+func (c *Config) Bins() []int {
+	bins := make([]int, 0, 5)
+	for _, bin := range []int{c.Bin, c.Bin2, c.Bin3, c.Bin4, c.Bin5} {
+		if bin != 0 {
+			bins = append(bins, bin)
+		}
+	}
+	return bins
+}
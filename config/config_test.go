@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewConfigRedisDefaults(t *testing.T) {
+	for _, key := range []string{"REDIS_ADDR", "REDIS_PASSWORD", "REDIS_DB"} {
+		t.Setenv(key, "")
+	}
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig: %v", err)
+	}
+	if cfg.RedisAddr != "localhost:6379" || cfg.RedisPassword != "" || cfg.RedisDB != 0 {
+		t.Errorf("redis = %q, %q, %d; want localhost:6379 without a password, database 0", cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	}
+}
+
+func TestNewConfigRedisOverrides(t *testing.T) {
+	t.Setenv("REDIS_ADDR", "redis.internal:6380")
+	t.Setenv("REDIS_PASSWORD", "s3cret")
+	t.Setenv("REDIS_DB", "3")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig: %v", err)
+	}
+	if cfg.RedisAddr != "redis.internal:6380" || cfg.RedisPassword != "s3cret" || cfg.RedisDB != 3 {
+		t.Errorf("redis = %q, %q, %d; want the environment's", cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("config JSON %s has the Redis password", data)
+	}
+}
+
+func TestNewConfigRejectsInvalidRedisDB(t *testing.T) {
+	for _, value := range []string{"one", "-1", "1.5"} {
+		t.Setenv("REDIS_DB", value)
+		if _, err := NewConfig(); err == nil || !strings.Contains(err.Error(), "REDIS_DB") {
+			t.Errorf("REDIS_DB=%s: err = %v, want it rejected", value, err)
+		}
+	}
+}
+
+func TestNewConfigCleanupSettings(t *testing.T) {
+	// Cleanup only counts until it is switched to deleting
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig: %v", err)
+	}
+	if !cfg.CleanupEnabled || cfg.CleanupRetentionDays != 30 || !cfg.CleanupDryRun {
+		t.Errorf("default cleanup = enabled %v, %d days, dry run %v; want a 30-day dry run", cfg.CleanupEnabled, cfg.CleanupRetentionDays, cfg.CleanupDryRun)
+	}
+
+	t.Setenv("CLEANUP_ENABLED", "false")
+	t.Setenv("CLEANUP_RETENTION_DAYS", "90")
+	t.Setenv("CLEANUP_DRY_RUN", "false")
+
+	cfg, err = NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig: %v", err)
+	}
+	if cfg.CleanupEnabled || cfg.CleanupRetentionDays != 90 || cfg.CleanupDryRun {
+		t.Errorf("cleanup = enabled %v, %d days, dry run %v; want the environment's", cfg.CleanupEnabled, cfg.CleanupRetentionDays, cfg.CleanupDryRun)
+	}
+
+	for _, value := range []string{"0", "month"} {
+		t.Setenv("CLEANUP_RETENTION_DAYS", value)
+		if _, err := NewConfig(); err == nil || !strings.Contains(err.Error(), "CLEANUP_RETENTION_DAYS") {
+			t.Errorf("CLEANUP_RETENTION_DAYS=%s: err = %v, want it rejected", value, err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package domain
+
+// Аудитории рассылки админа
+const (
+	BroadcastAudienceAll     = "all"     // все, кто запускал бота (таблица just)
+	BroadcastAudienceClients = "clients" // оплатившие клиенты
+	BroadcastAudienceWinners = "winners" // выигравшие приз
+)
+
+// BroadcastAudiences — все аудитории в порядке показа админу
+var BroadcastAudiences = []string{
+	BroadcastAudienceAll,
+	BroadcastAudienceClients,
+	BroadcastAudienceWinners,
+}
+
+// IsValidBroadcastAudience — известная ли аудитория
+func IsValidBroadcastAudience(audience string) bool {
+	for _, a := range BroadcastAudiences {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package domain
+
+// CartItem — временно выбранный парфюм (хранится в Redis до оформления заказа)
+type CartItem struct {
+	ParfumeID string `json:"id"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	Price     int    `json:"price"`
+}
+
+// CartQuantity — общее количество позиций в корзине
+func CartQuantity(items []CartItem) int {
+	total := 0
+	for _, item := range items {
+		total += item.Quantity
+	}
+	return total
+}
+
+// ToOrderItem — позиция заказа из корзины с указанным количеством
+func (c CartItem) ToOrderItem(orderID int64, quantity int) OrderItem {
+	return OrderItem{
+		OrderID:   orderID,
+		ParfumeID: c.ParfumeID,
+		Name:      c.Name,
+		Quantity:  quantity,
+		Price:     c.Price,
+	}
+}
+
+// FormatCartItems — legacy-строка "name: qty, name: qty" для корзины
+func FormatCartItems(items []CartItem) string {
+	orderItems := make([]OrderItem, 0, len(items))
+	for _, item := range items {
+		orderItems = append(orderItems, item.ToOrderItem(0, item.Quantity))
+	}
+	return FormatOrderItems(orderItems)
+}
+
+// CheckoutInfo — данные формы адреса, которые записываются в заказ при оформлении корзины или приза
+type CheckoutInfo struct {
+	FIO       string
+	Contact   string
+	Address   string
+	Latitude  *float64 // nil, если точку на карте не выбрали
+	Longitude *float64
+}
+
+// CheckoutAllocation — часть корзины, записанная в один оплаченный заказ
+type CheckoutAllocation struct {
+	Order Order
+	Items []OrderItem
+}
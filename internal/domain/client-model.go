@@ -1,6 +1,9 @@
 package domain
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type Client struct {
 	ID         int64  `json:"id"`
@@ -39,5 +42,27 @@ type LotoEntry struct {
 	Address   sql.NullString `json:"address" db:"address"`
 	DatePay   string         `json:"date_pay" db:"dataPay"`
 	UpdatedAt string         `json:"updated_at" db:"updated_at"`
-	Checks    bool           `json:"checks" db:"checks"` // Add this field
+	Checks    bool           `json:"checks" db:"checks"` // билет уже разыгран
+}
+
+// ClientListItem is a paying client with the number of their orders, as listed in the admin panel
+type ClientListItem struct {
+	ID         int64     `json:"id"`
+	TelegramID int64     `json:"telegram_id"`
+	UserName   string    `json:"userName"`
+	FIO        string    `json:"fio"`
+	Contact    string    `json:"contact"`
+	Address    string    `json:"address"`
+	DatePay    string    `json:"dataPay"`
+	OrderCount int       `json:"order_count"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ClientFilter narrows the admin client list; zero values don't filter
+type ClientFilter struct {
+	Query     string // part of fio, userName or contact
+	Phone     string // digits of Query normalized as a phone number, matched against contact
+	HasOrders *bool
+	Limit     int
+	Offset    int
 }
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// FailedNotification — сообщение пользователю, которое Telegram не доставил
+type FailedNotification struct {
+	ID            int64      `json:"id"`
+	TelegramID    int64      `json:"telegram_id"`
+	OrderID       *int64     `json:"order_id,omitempty"`
+	Message       string     `json:"message"`
+	ParseMode     string     `json:"parse_mode,omitempty"`
+	ReplyMarkup   string     `json:"reply_markup,omitempty"` // JSON клавиатуры, отправляется повторно как есть
+	Reason        string     `json:"reason"`                 // ошибка последней попытки
+	Attempts      int        `json:"attempts"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastAttemptAt time.Time  `json:"last_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"` // nil — ещё не доставлено
+}
+
+// FailedNotificationFilter narrows the failed notification list; Delivered nil lists all
+type FailedNotificationFilter struct {
+	Delivered *bool
+	Limit     int
+	Offset    int
+}
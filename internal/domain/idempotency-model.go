@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// IdempotencyRecord — повторяемый POST с Idempotency-Key и сохранённый ответ на него.
+// StatusCode = 0, пока первый запрос ещё выполняется
+type IdempotencyRecord struct {
+	Key        string    `json:"key"         db:"idempotency_key"`
+	Endpoint   string    `json:"endpoint"    db:"endpoint"`
+	TelegramID int64     `json:"telegram_id" db:"telegram_id"`
+	StatusCode int       `json:"status_code" db:"status_code"`
+	Response   []byte    `json:"response"    db:"response"`
+	ResourceID int64     `json:"resource_id" db:"resource_id"` // созданный заказ; 0 — нет
+	CreatedAt  time.Time `json:"created_at"  db:"created_at"`
+}
+
+// InProgress — первый запрос с этим ключом ещё не ответил
+func (r *IdempotencyRecord) InProgress() bool {
+	return r.StatusCode == 0
+}
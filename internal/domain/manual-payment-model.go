@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Статусы ручной проверки чека
+const (
+	ManualPaymentPending  = "pending"
+	ManualPaymentApproved = "approved"
+	ManualPaymentRejected = "rejected"
+)
+
+// ManualPayment — чек, который бот не смог прочитать; его подтверждает или отклоняет админ
+type ManualPayment struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Quantity  int        `json:"quantity"` // выбранное количество наборов
+	Amount    int        `json:"amount"`   // ожидаемая сумма за это количество
+	FilePath  string     `json:"file_path"`
+	FileName  string     `json:"file_name"`
+	Reason    string     `json:"reason"` // почему чек не прошёл автоматически
+	Status    string     `json:"status"`
+	DecidedBy *int64     `json:"decided_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
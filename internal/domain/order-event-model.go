@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OrderEventType — вид события в истории заказа
+type OrderEventType string
+
+const (
+	OrderEventPaymentReceived OrderEventType = "payment_received"
+	OrderEventReceiptSaved    OrderEventType = "receipt_saved"
+	OrderEventPerfumeSelected OrderEventType = "perfume_selected"
+	OrderEventAddressProvided OrderEventType = "address_provided"
+	OrderEventPrizeWon        OrderEventType = "prize_won"
+	OrderEventPrizeAssigned   OrderEventType = "prize_assigned"
+	OrderEventPrizeCompleted  OrderEventType = "prize_completed"
+	OrderEventPrizeReminded   OrderEventType = "prize_reminded"
+	OrderEventPrizeExpired    OrderEventType = "prize_expired"
+	OrderEventStatusChanged   OrderEventType = "status_changed"
+	OrderEventLotoReissued    OrderEventType = "loto_reissued"
+)
+
+// Кто вызвал событие
+const (
+	OrderEventActorUser   = "user"
+	OrderEventActorAdmin  = "admin"
+	OrderEventActorSystem = "system"
+)
+
+// OrderEvent — запись в истории заказа. FromStatus/ToStatus заполнены только у status_changed,
+// подробности остальных событий лежат в Payload
+type OrderEvent struct {
+	ID         int64           `json:"id"                    db:"id"`
+	OrderID    int64           `json:"order_id"              db:"order_id"`
+	EventType  OrderEventType  `json:"event_type"            db:"event_type"`
+	FromStatus OrderStatus     `json:"from_status,omitempty" db:"from_status"`
+	ToStatus   OrderStatus     `json:"to_status,omitempty"   db:"to_status"`
+	Payload    json.RawMessage `json:"payload"               db:"payload"`
+	Actor      string          `json:"actor"                 db:"actor"`
+	Note       string          `json:"note"                  db:"note"`
+	CreatedAt  time.Time       `json:"created_at"            db:"created_at"`
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OrderItem — одна позиция парфюма в заказе (снимок имени и цены на момент выбора)
+type OrderItem struct {
+	ID        int64     `json:"id"         db:"id"`
+	OrderID   int64     `json:"order_id"   db:"order_id"`
+	ParfumeID string    `json:"parfume_id" db:"parfume_id"`
+	Name      string    `json:"name"       db:"name"`
+	Quantity  int       `json:"quantity"   db:"quantity"`
+	Price     int       `json:"price"      db:"price"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FormatOrderItems — legacy-строка "name: qty, name: qty" для orders.parfumes
+func FormatOrderItems(items []OrderItem) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		parts = append(parts, fmt.Sprintf("%s: %d", item.Name, item.Quantity))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -12,7 +12,7 @@ type OrderEntry struct {
 	UserID       int64          `json:"userID"        db:"id_user"`
 	UserName     string         `json:"userName"      db:"userName"`
 	Parfumes     string         `json:"parfumes"      db:"parfumes"`
-	Quantity     int            `json:"quantity"      db:"quantity"`
+	Quantity     sql.NullInt64  `json:"quantity"      db:"quantity"` // NULL — количество неизвестно (состояние бота потерялось)
 	Fio          sql.NullString `json:"fio"           db:"fio"`
 	Contact      string         `json:"contact"       db:"contact"`
 	Address      sql.NullString `json:"address"       db:"address"`
@@ -21,22 +21,161 @@ type OrderEntry struct {
 	Checks       bool           `json:"checks"        db:"checks"`
 }
 
+// PaymentEntry — всё, что записывается при подтверждённой оплате чека одной транзакцией
+type PaymentEntry struct {
+	UserID   int64
+	UserName string
+	Quantity int    // оплаченное количество наборов; 0 — неизвестно, в заказ пишется NULL
+	Amount   int    // сумма чека в тенге
+	QR       string // QR чека; один чек засчитывается один раз
+	Receipt  string // путь к сохранённому файлу чека
+	DatePay  string
+	Tickets  []LotoEntry // лото-билеты за эту оплату
+}
+
+// PendingPayment — количество и сумма, названные пользователю в боте до оплаты;
+// чек сверяется с ними, даже если состояние в Redis уже истекло
+type PendingPayment struct {
+	UserID    int64     `json:"user_id"`
+	Quantity  int       `json:"quantity"` // выбранное количество наборов
+	Amount    int       `json:"amount"`   // сумма к оплате в тенге
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // Order — полная доменная модель заказа
 type Order struct {
-	ID           int64     `json:"id"            db:"id"`
-	IDUser       int64     `json:"id_user"       db:"id_user"`
-	UserName     string    `json:"userName"      db:"userName"`
-	Quantity     *int      `json:"quantity"      db:"quantity"`
-	Parfumes     string    `json:"parfumes"      db:"parfumes"`
-	Gift         string    `json:"gift"          db:"gift"`
-	FIO          string    `json:"fio"           db:"fio"`
-	Contact      string    `json:"contact"       db:"contact"`
-	Address      string    `json:"address"       db:"address"`
-	DateRegister string    `json:"dateRegister"  db:"dateRegister"`
-	DataPay      string    `json:"dataPay"       db:"dataPay"` // ЕДИНЫЙ нейминг: DataPay
-	Checks       bool      `json:"checks"        db:"checks"`
-	CreatedAt    time.Time `json:"created_at"    db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"    db:"updated_at"`
+	ID           int64       `json:"id"            db:"id"`
+	IDUser       int64       `json:"id_user"       db:"id_user"`
+	UserName     string      `json:"userName"      db:"userName"`
+	Quantity     *int        `json:"quantity"      db:"quantity"`
+	Parfumes     string      `json:"parfumes"      db:"parfumes"`
+	Gift         string      `json:"gift"          db:"gift"`
+	FIO          string      `json:"fio"           db:"fio"`
+	Contact      string      `json:"contact"       db:"contact"`
+	Address      string      `json:"address"       db:"address"`
+	Latitude     *float64    `json:"latitude"      db:"latitude"` // nil — точку на карте не выбрали
+	Longitude    *float64    `json:"longitude"     db:"longitude"`
+	DateRegister string      `json:"dateRegister"  db:"dateRegister"`
+	DataPay      string      `json:"dataPay"       db:"dataPay"` // ЕДИНЫЙ нейминг: DataPay
+	Checks       bool        `json:"checks"        db:"checks"`  // синхронизировано со Status, см. OrderStatus.Checks
+	Status       OrderStatus `json:"status"        db:"status"`
+	PaymentLink  string      `json:"payment_link"  db:"payment_link"` // ссылка на оплату из мини-приложения
+	TotalAmount  int         `json:"total_amount"  db:"total_amount"` // сумма к оплате, ₸
+	CreatedAt    time.Time   `json:"created_at"    db:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"    db:"updated_at"`
+
+	PrizeAwardedAt  *time.Time `json:"prize_awarded_at,omitempty"  db:"prize_awarded_at"`  // когда заказ получил приз
+	PrizeRemindedAt *time.Time `json:"prize_reminded_at,omitempty" db:"prize_reminded_at"` // когда победителю напомнили прислать адрес
+	PrizeExpiredAt  *time.Time `json:"prize_expired_at,omitempty"  db:"prize_expired_at"`  // приз не забрали вовремя; gift остаётся для истории
+	PrizeClaimedAt  *time.Time `json:"prize_claimed_at,omitempty"  db:"prize_claimed_at"`  // когда победитель прислал адрес доставки приза
+}
+
+// Состояние приза заказа
+const (
+	PrizeClaimClaimed = "claimed" // адрес доставки получен
+	PrizeClaimPending = "pending" // ждём адрес
+	PrizeClaimExpired = "expired" // адрес не прислали вовремя
+)
+
+// PrizeClaim — состояние приза заказа; пусто, если приза нет
+func (o Order) PrizeClaim() string {
+	switch {
+	case o.Gift == "" || o.Gift == "null":
+		return ""
+	case o.PrizeClaimedAt != nil:
+		return PrizeClaimClaimed
+	case o.PrizeExpiredAt != nil:
+		return PrizeClaimExpired
+	}
+	return PrizeClaimPending
+}
+
+// PrizeClaimCounts — сколько призов одного вида забрали, ждут и сгорели
+type PrizeClaimCounts struct {
+	Claimed int `json:"claimed"`
+	Pending int `json:"pending"`
+	Expired int `json:"expired"`
+}
+
+// PrizeAssignment — ручное назначение приза заказу (админ)
+type PrizeAssignment struct {
+	OrderID  int64  `json:"order_id"`
+	Prize    string `json:"prize"`
+	Fallback string `json:"-"` // приз вместо Prize, если его запас кончился
+}
+
+// AssignedPrize — итог назначения: что заказ получил и что у него было до этого
+type AssignedPrize struct {
+	OrderID       int64  `json:"order_id"`
+	Prize         string `json:"prize"`
+	PreviousPrize string `json:"previous_prize"`
+}
+
+// PrizeInventory — запас приза с ограниченным количеством (кольца, деньги)
+type PrizeInventory struct {
+	Prize     string    `json:"prize"`
+	Total     int       `json:"total"`   // сколько выделено всего, с пополнениями
+	Awarded   int       `json:"awarded"` // сколько уже выдано колесом
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Remaining — сколько ещё можно выдать
+func (p PrizeInventory) Remaining() int {
+	return max(p.Total-p.Awarded, 0)
+}
+
+// Откуда у заказа приз в журнале spins
+const (
+	SpinSourceWheel = "wheel" // выпал на колесе
+	SpinSourceAdmin = "admin" // назначен админом
+)
+
+// Spin — вращение колеса, выдавшее приз; журнал для разбора споров о призах
+type Spin struct {
+	ID            int64     `json:"id"`
+	OrderID       int64     `json:"order_id"`
+	TelegramID    int64     `json:"telegram_id"`
+	OrderSequence int       `json:"order_sequence"`
+	Prize         string    `json:"prize"`         // выданный приз
+	DrawnPrize    string    `json:"drawn_prize"`   // выпавший приз; отличается, если его не осталось на складе
+	Sector        int       `json:"sector"`        // сектор, на котором остановилось колесо
+	RulesVersion  int64     `json:"rules_version"` // версия PrizeRules, по которой выпал приз
+	Source        string    `json:"source"`        // SpinSourceWheel или SpinSourceAdmin
+	RemoteAddr    string    `json:"remote_addr"`
+	UserAgent     string    `json:"user_agent"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PrizeRules — правила, по которым колесо выбирает приз по порядковому номеру заказа.
+// Каждое изменение сохраняется новой версией, чтобы старые призы можно было объяснить
+type PrizeRules struct {
+	Version          int64     `json:"version"`           // 0 — встроенные правила
+	MoneyInterval    int       `json:"money_interval"`    // деньги — каждому N-му заказу
+	DiamondsPer1000  int       `json:"diamonds_per_1000"` // кольца по интервалу — N на 1000 заказов
+	ML30Interval     int       `json:"ml30_interval"`     // 30мл — каждому N-му заказу
+	DiamondPositions []int     `json:"diamond_positions"` // номера заказов с кольцом сверх интервала
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// DiamondInterval — каждый какой заказ получает кольцо по интервалу
+func (r PrizeRules) DiamondInterval() int {
+	return 1000 / r.DiamondsPer1000
+}
+
+// SpinFilter — фильтры журнала вращений (пусто — без фильтра)
+type SpinFilter struct {
+	Prize string
+	From  string // YYYY-MM-DD, включительно
+	To    string // YYYY-MM-DD, включительно
+	Limit int    // 0 — без ограничения
+}
+
+// OrderFilter — фильтры списка и выгрузки заказов (nil/пусто — без фильтра)
+type OrderFilter struct {
+	Checks *bool
+	Status OrderStatus
+	From   string // YYYY-MM-DD, включительно
+	To     string // YYYY-MM-DD, включительно
 }
 
 // OrderCreateRequest — вход при создании
@@ -79,6 +218,8 @@ type OrderResponse struct {
 	DateRegister string `json:"dateRegister"`
 	DataPay      string `json:"dataPay"`
 	Checks       bool   `json:"checks"`
+	PaymentLink  string `json:"payment_link"`
+	TotalAmount  int    `json:"total_amount"`
 	CreatedAt    string `json:"created_at"`
 	UpdatedAt    string `json:"updated_at"`
 }
@@ -108,6 +249,8 @@ func (o *Order) ToResponse() *OrderResponse {
 		DateRegister: o.DateRegister,
 		DataPay:      o.DataPay,
 		Checks:       o.Checks,
+		PaymentLink:  o.PaymentLink,
+		TotalAmount:  o.TotalAmount,
 		CreatedAt:    o.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:    o.UpdatedAt.Format(time.RFC3339),
 	}
@@ -0,0 +1,72 @@
+package domain
+
+// OrderStatus — этап жизненного цикла заказа
+type OrderStatus string
+
+const (
+	OrderStatusAwaitingPayment OrderStatus = "awaiting_payment"
+	OrderStatusPaid            OrderStatus = "paid"
+	OrderStatusPerfumeSelected OrderStatus = "perfume_selected"
+	OrderStatusAddressProvided OrderStatus = "address_provided"
+	OrderStatusPacked          OrderStatus = "packed"
+	OrderStatusShipped         OrderStatus = "shipped"
+	OrderStatusDelivered       OrderStatus = "delivered"
+	OrderStatusCancelled       OrderStatus = "cancelled"
+)
+
+// OrderStatuses — все статусы в порядке прохождения
+var OrderStatuses = []OrderStatus{
+	OrderStatusAwaitingPayment,
+	OrderStatusPaid,
+	OrderStatusPerfumeSelected,
+	OrderStatusAddressProvided,
+	OrderStatusPacked,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+}
+
+// orderStatusTransitions — разрешённые переходы; delivered и cancelled конечные
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusAwaitingPayment: {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:            {OrderStatusPerfumeSelected, OrderStatusCancelled},
+	OrderStatusPerfumeSelected: {OrderStatusAddressProvided, OrderStatusCancelled},
+	OrderStatusAddressProvided: {OrderStatusPacked, OrderStatusCancelled},
+	OrderStatusPacked:          {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:         {OrderStatusDelivered},
+}
+
+// IsValid — известный ли статус
+func (s OrderStatus) IsValid() bool {
+	for _, status := range OrderStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransitionTo — можно ли перевести заказ из s в next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if next == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStatuses — куда можно перейти из s
+func (s OrderStatus) NextStatuses() []OrderStatus {
+	return orderStatusTransitions[s]
+}
+
+// Checks — значение старого поля checks для статуса: true с момента ввода адреса
+// (включая delivered), чтобы старые запросы по checks видели заказ завершённым
+func (s OrderStatus) Checks() bool {
+	switch s {
+	case OrderStatusAddressProvided, OrderStatusPacked, OrderStatusShipped, OrderStatusDelivered:
+		return true
+	}
+	return false
+}
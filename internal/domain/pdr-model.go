@@ -2,7 +2,24 @@ package domain
 
 type PdfResult struct {
 	Total       int
+	Lines       []PriceLine // что оплачено; сумма считается по прайсу объёмов
 	ActualPrice int
+	Expected    int // сумма, названная пользователю; 0 — считать по прайсу
 	Bin         int
 	Qr          string
 }
+
+// PriceLine — оплачиваемая позиция: объём (ключ прайса в конфиге) и количество
+type PriceLine struct {
+	Volume   string `json:"volume"`
+	Quantity int    `json:"quantity"`
+}
+
+// PendingReceipt is a parsed receipt kept while the user fixes a count/amount mismatch
+type PendingReceipt struct {
+	FilePath    string `json:"file_path"`
+	FileName    string `json:"file_name"`
+	ActualPrice int    `json:"actual_price"`
+	Qr          string `json:"qr"`
+	Bin         int    `json:"bin"`
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// DurationStats — сводка по длительностям (в секундах)
+type DurationStats struct {
+	Count          int     `json:"count"`
+	AverageSeconds float64 `json:"average_seconds"`
+	MedianSeconds  float64 `json:"median_seconds"`
+	MinSeconds     float64 `json:"min_seconds"`
+	MaxSeconds     float64 `json:"max_seconds"`
+}
+
+// ConversionTimings — время от регистрации до покупки и от оплаты до заполнения адреса
+type ConversionTimings struct {
+	RegistrationToPurchase DurationStats `json:"registration_to_purchase"`
+	PaymentToAddress       DurationStats `json:"payment_to_address"`
+}
+
+// NewDurationStats — считает среднее/медиану/мин/макс; пустой срез даёт нули
+func NewDurationStats(seconds []float64) DurationStats {
+	if len(seconds) == 0 {
+		return DurationStats{}
+	}
+
+	sorted := append([]float64(nil), seconds...)
+	sort.Float64s(sorted)
+
+	total := 0.0
+	for _, s := range sorted {
+		total += s
+	}
+
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	return DurationStats{
+		Count:          len(sorted),
+		AverageSeconds: total / float64(len(sorted)),
+		MedianSeconds:  median,
+		MinSeconds:     sorted[0],
+		MaxSeconds:     sorted[len(sorted)-1],
+	}
+}
+
+// PerfumeSales — сколько единиц парфюма выбрали во всех заказах
+type PerfumeSales struct {
+	ParfumeID string `json:"parfume_id"` // пусто для старых выборов без ID
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+}
+
+// DashboardStats — сводка для админ-панели: заказы, пользователи, выручка, призы
+type DashboardStats struct {
+	OrderStatsResponse
+	TotalUsers    int                         `json:"total_users"`
+	PayingClients int                         `json:"paying_clients"`
+	Revenue       int64                       `json:"revenue"`
+	PrizeCounts   map[string]int              `json:"prize_counts"`
+	PrizeClaims   map[string]PrizeClaimCounts `json:"prize_claims"` // по видам призов
+	TopPerfumes   []PerfumeSales              `json:"top_perfumes"`
+	GeneratedAt   time.Time                   `json:"generated_at"`
+}
+
+// OrderPeriodStats — заказы за день/неделю/месяц (дата — начало периода)
+type OrderPeriodStats struct {
+	Date            string `json:"date"` // YYYY-MM-DD
+	TotalOrders     int    `json:"total_orders"`
+	TotalQuantity   int    `json:"total_quantity"`
+	CheckedOrders   int    `json:"checked_orders"`
+	UncheckedOrders int    `json:"unchecked_orders"`
+	Revenue         int    `json:"revenue"` // total_quantity * Config.UnitPrice()
+}
+
+// BotStats — цифры для команды /stats за период с Since; нулевое Since — за всё время
+type BotStats struct {
+	Since           time.Time
+	Orders          int
+	PendingOrders   int
+	CompletedOrders int
+	Quantity        int
+	Revenue         int64 // за всё время — из money, за период — сумма чеков из receipts
+	NewUsers        int
+	TodayOrders     int // не зависит от периода
+	PrizeCounts     map[string]int
+}
@@ -6,4 +6,16 @@ type UserState struct {
 	Count         int    `json:"count"`
 	Contact       string `json:"contact"`
 	IsPaid        bool   `json:"is_paid"`
+
+	// Amount — сумма, названная при выборе количества; чек сверяется с ней
+	Amount int `json:"amount,omitempty"`
+
+	// Receipt — принятый чек; нужен, чтобы записать оплату в историю заказа
+	Receipt *PendingReceipt `json:"receipt,omitempty"`
+
+	// OrderID — заказ, созданный при оплате; шаг с контактом дополняет его
+	OrderID int64 `json:"order_id,omitempty"`
+
+	// Lang — язык, выбранный на /start; копия just.language, чтобы не читать базу на каждое сообщение
+	Lang string `json:"lang,omitempty"`
 }
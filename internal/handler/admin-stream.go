@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Types of the events pushed on /api/admin/stream
+const (
+	AdminEventOrderCreated = "order_created"
+	AdminEventPrizeWon     = "prize_won"
+)
+
+const (
+	// adminStreamBuffer is how many events a slow admin panel may fall behind before missing some
+	adminStreamBuffer = 32
+	// adminStreamHeartbeat keeps idle streams from being closed by proxies
+	adminStreamHeartbeat = 25 * time.Second
+)
+
+// AdminEvent is something admins want to see live in the web panel
+type AdminEvent struct {
+	Type      string    `json:"type"`
+	OrderID   int64     `json:"order_id"`
+	UserID    int64     `json:"user_id"`
+	Source    string    `json:"source,omitempty"`     // order_created: bot or web
+	Quantity  int       `json:"quantity,omitempty"`   // order_created
+	Amount    int       `json:"amount,omitempty"`     // order_created, ₸
+	Prize     string    `json:"prize,omitempty"`      // prize_won: prize code
+	PrizeName string    `json:"prize_name,omitempty"` // prize_won
+	At        time.Time `json:"at"`
+}
+
+// publishOrderCreated tells the open admin streams about a new order
+func (h *Handler) publishOrderCreated(orderID, userID int64, source string, quantity, amount int) {
+	h.events.Publish(AdminEvent{
+		Type:     AdminEventOrderCreated,
+		OrderID:  orderID,
+		UserID:   userID,
+		Source:   source,
+		Quantity: quantity,
+		Amount:   amount,
+		At:       time.Now(),
+	})
+}
+
+// publishPrizeWon tells the open admin streams about a prize won on the wheel
+func (h *Handler) publishPrizeWon(orderID, userID int64, prize string) {
+	h.events.Publish(AdminEvent{
+		Type:      AdminEventPrizeWon,
+		OrderID:   orderID,
+		UserID:    userID,
+		Prize:     prize,
+		PrizeName: PrizeDisplayName(prize),
+		At:        time.Now(),
+	})
+}
+
+// GET /api/admin/stream
+// Server-Sent Events: one "order_created" or "prize_won" event with an AdminEvent as JSON
+// data for each new order and prize win, and a comment line every 25s while idle. Needs
+// the admin token header, so the panel reads it with fetch rather than EventSource.
+func (h *Handler) handleAdminStream(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	events, unsubscribe := h.events.Subscribe(adminStreamBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stops nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		h.logger.Error("Admin stream can't be flushed", zap.Error(err))
+		return
+	}
+
+	requestID := RequestIDFromContext(r.Context())
+	h.logger.Info("Admin stream opened", zap.String("request_id", requestID))
+	defer h.logger.Info("Admin stream closed", zap.String("request_id", requestID))
+
+	heartbeat := time.NewTicker(adminStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.ctx.Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.Error("Error encoding admin event", zap.Error(err))
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+
+		// A write to a client that went away fails here and ends the stream
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readStreamEvent reads the stream up to the next event and returns its type and data
+func readStreamEvent(t *testing.T, stream *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var eventType, data string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && eventType != "":
+			return eventType, data
+		}
+	}
+}
+
+func TestAdminStreamReceivesNewOrder(t *testing.T) {
+	h, _ := newTestHandler(t)
+	server := httptest.NewServer(http.HandlerFunc(h.handleAdminStream))
+	t.Cleanup(server.Close)
+
+	// Reads fail once this runs out rather than hanging the test
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/admin/stream", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	resp, err := server.Client().Do(r)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("content type = %q, want text/event-stream", got)
+	}
+
+	stream := bufio.NewReader(resp.Body)
+	if line, err := stream.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("first line = %q, %v; want the connected comment", line, err)
+	}
+
+	if rec := placeOrder(t, h, "", checkoutForm()); rec.Code != http.StatusOK {
+		t.Fatalf("place order: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	eventType, data := readStreamEvent(t, stream)
+	if eventType != AdminEventOrderCreated {
+		t.Fatalf("event = %q, want %q", eventType, AdminEventOrderCreated)
+	}
+	var event AdminEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatalf("decode event %q: %v", data, err)
+	}
+	if event.OrderID == 0 || event.UserID != 7001 || event.Source != orderSourceWeb || event.Quantity != 2 || event.Amount != 4998 {
+		t.Errorf("event = %+v, want the web order of user 7001", event)
+	}
+
+	// Closing the connection unsubscribes the stream
+	cancel()
+	deadline := time.Now().Add(3 * time.Second)
+	for h.events.Subscribers() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscribers left after the client went away", h.events.Subscribers())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/service"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// BroadcastCommand is the admin command that sends a message to a group of users
+const BroadcastCommand = "broadcast"
+
+// Callback data of the broadcast buttons; the audience follows the "to" and "send" prefixes
+const (
+	BroadcastCallbackPrefix = "broadcast_"
+	broadcastAudiencePrefix = "broadcast_to_"
+	broadcastSendPrefix     = "broadcast_send_"
+	broadcastCancelData     = "broadcast_cancel"
+)
+
+// broadcastRate keeps a broadcast under Telegram's limit of about 30 messages a second
+const broadcastRate = 25
+
+// broadcastAudienceLabels are the audience buttons shown to the admin
+var broadcastAudienceLabels = map[string]string{
+	domain.BroadcastAudienceAll:     "👥 Барлық қолданушылар",
+	domain.BroadcastAudienceClients: "💳 Төлеген клиенттер",
+	domain.BroadcastAudienceWinners: "🏆 Сыйлық ұтқандар",
+}
+
+// broadcastContent is the message an admin broadcasts: text, or a photo or video with a caption
+type broadcastContent struct {
+	text     string
+	entities []models.MessageEntity
+	photoID  string
+	videoID  string
+}
+
+// broadcastContentOf takes the content from a message, the same way the admin's file id
+// echo in DefaultHandler does; other kinds of messages can't be broadcast
+func broadcastContentOf(message *models.Message) (broadcastContent, bool) {
+	switch {
+	case len(message.Photo) > 0:
+		return broadcastContent{
+			text:     message.Caption,
+			entities: message.CaptionEntities,
+			photoID:  message.Photo[len(message.Photo)-1].FileID,
+		}, true
+	case message.Video != nil:
+		return broadcastContent{
+			text:     message.Caption,
+			entities: message.CaptionEntities,
+			videoID:  message.Video.FileID,
+		}, true
+	case strings.TrimSpace(message.Text) != "":
+		return broadcastContent{text: message.Text, entities: message.Entities}, true
+	}
+	return broadcastContent{}, false
+}
+
+// send sends the content to chatID; markup may be nil
+func (c broadcastContent) send(ctx context.Context, b *bot.Bot, chatID int64, markup models.ReplyMarkup) error {
+	var err error
+	switch {
+	case c.photoID != "":
+		_, err = b.SendPhoto(ctx, &bot.SendPhotoParams{
+			ChatID:          chatID,
+			Photo:           &models.InputFileString{Data: c.photoID},
+			Caption:         c.text,
+			CaptionEntities: c.entities,
+			ReplyMarkup:     markup,
+		})
+	case c.videoID != "":
+		_, err = b.SendVideo(ctx, &bot.SendVideoParams{
+			ChatID:          chatID,
+			Video:           &models.InputFileString{Data: c.videoID},
+			Caption:         c.text,
+			CaptionEntities: c.entities,
+			ReplyMarkup:     markup,
+		})
+	default:
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      chatID,
+			Text:        c.text,
+			Entities:    c.entities,
+			ReplyMarkup: markup,
+		})
+	}
+	return err
+}
+
+// BroadcastHandler handles /broadcast from an admin by asking who the broadcast is for
+func (h *Handler) BroadcastHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || update.Message.From == nil {
+		return
+	}
+	adminId := update.Message.From.ID
+	if !slices.Contains(h.receiptAdmins(), adminId) {
+		return
+	}
+
+	if h.broadcasting.Load() {
+		h.sendAdminText(ctx, b, adminId, "⏳ Алдыңғы рассылка әлі жіберіліп жатыр, аяқталуын күтіңіз.")
+		return
+	}
+
+	var rows [][]models.InlineKeyboardButton
+	for _, audience := range domain.BroadcastAudiences {
+		recipients, err := h.clientRepo.GetBroadcastAudience(ctx, audience)
+		if err != nil {
+			h.logger.Error("Failed to get broadcast audience", zap.Error(err), zap.String("audience", audience))
+			h.sendAdminText(ctx, b, adminId, "❌ Аудиторияны алу мүмкін болмады, қайталап көріңіз.")
+			return
+		}
+		rows = append(rows, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("%s (%d)", broadcastAudienceLabels[audience], len(recipients)),
+			CallbackData: broadcastAudiencePrefix + audience,
+		}})
+	}
+	rows = append(rows, []models.InlineKeyboardButton{{Text: "❌ Бас тарту", CallbackData: broadcastCancelData}})
+
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      adminId,
+		Text:        "📣 Рассылканы кімге жібереміз?",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	}); err != nil {
+		h.logger.Warn("Failed to send broadcast audiences", zap.Error(err))
+	}
+}
+
+// BroadcastCallbackHandler handles the broadcast buttons: picking the audience, sending
+// the previewed message and cancelling
+func (h *Handler) BroadcastCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query == nil {
+		return
+	}
+
+	answer := func(text string) {
+		if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: query.ID,
+			Text:            text,
+		}); err != nil {
+			h.logger.Warn("Failed to answer callback query", zap.Error(err))
+		}
+	}
+
+	adminId := query.From.ID
+	if !slices.Contains(h.receiptAdmins(), adminId) {
+		answer("⛔️")
+		return
+	}
+	message := query.Message.Message
+	if message == nil {
+		answer("")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(query.Data, broadcastAudiencePrefix):
+		audience := strings.TrimPrefix(query.Data, broadcastAudiencePrefix)
+		if !domain.IsValidBroadcastAudience(audience) {
+			answer("")
+			return
+		}
+		if err := h.redisRepo.SaveBroadcastState(ctx, adminId, audience); err != nil {
+			h.logger.Error("Failed to save broadcast state", zap.Error(err), zap.Int64("admin_id", adminId))
+			answer("Қате, қайталап көріңіз")
+			return
+		}
+		h.editBroadcastMessage(ctx, b, message, fmt.Sprintf(
+			"📣 %s\n\n✍️ Жіберілетін хабарламаны осында жазыңыз: мәтін, фото немесе видео.",
+			broadcastAudienceLabels[audience]))
+		answer("")
+
+	case strings.HasPrefix(query.Data, broadcastSendPrefix):
+		audience := strings.TrimPrefix(query.Data, broadcastSendPrefix)
+		// The preview carries the content, so nothing has to be kept between the steps
+		content, ok := broadcastContentOf(message)
+		if !domain.IsValidBroadcastAudience(audience) || !ok {
+			answer("")
+			return
+		}
+		if !h.broadcasting.CompareAndSwap(false, true) {
+			answer("Алдыңғы рассылка әлі жіберіліп жатыр")
+			return
+		}
+		if _, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+			ChatID:      message.Chat.ID,
+			MessageID:   message.ID,
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{}},
+		}); err != nil {
+			h.logger.Warn("Failed to remove broadcast buttons", zap.Error(err))
+		}
+		answer("Жіберілуде")
+
+		h.logger.Info("Broadcast started", zap.Int64("admin_id", adminId), zap.String("audience", audience))
+		go func() {
+			defer h.broadcasting.Store(false)
+			h.runBroadcast(h.ctx, b, adminId, audience, content)
+		}()
+
+	case query.Data == broadcastCancelData:
+		if err := h.redisRepo.DeleteBroadcastState(ctx, adminId); err != nil {
+			h.logger.Error("Failed to delete broadcast state", zap.Error(err), zap.Int64("admin_id", adminId))
+		}
+		if message.Text != "" {
+			h.editBroadcastMessage(ctx, b, message, "❌ Рассылка тоқтатылды")
+		} else if _, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+			ChatID:      message.Chat.ID,
+			MessageID:   message.ID,
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{}},
+		}); err != nil {
+			h.logger.Warn("Failed to remove broadcast buttons", zap.Error(err))
+		}
+		answer("Тоқтатылды")
+
+	default:
+		answer("")
+	}
+}
+
+// captureBroadcastContent takes the message an admin sent after picking an audience and
+// shows it back as a preview with a confirm button. It reports whether the message was
+// meant for a broadcast, so DefaultHandler leaves it alone.
+func (h *Handler) captureBroadcastContent(ctx context.Context, b *bot.Bot, message *models.Message) bool {
+	adminId := message.From.ID
+	if !slices.Contains(h.receiptAdmins(), adminId) {
+		return false
+	}
+	audience, err := h.redisRepo.GetBroadcastState(ctx, adminId)
+	if err != nil {
+		h.logger.Error("Failed to get broadcast state", zap.Error(err), zap.Int64("admin_id", adminId))
+		return false
+	}
+	if audience == "" {
+		return false
+	}
+
+	content, ok := broadcastContentOf(message)
+	if !ok {
+		h.sendAdminText(ctx, b, adminId, "⚠️ Тек мәтін, фото немесе видео жіберуге болады.")
+		return true
+	}
+	if err := h.redisRepo.DeleteBroadcastState(ctx, adminId); err != nil {
+		h.logger.Error("Failed to delete broadcast state", zap.Error(err), zap.Int64("admin_id", adminId))
+	}
+
+	recipients, err := h.clientRepo.GetBroadcastAudience(ctx, audience)
+	if err != nil {
+		h.logger.Error("Failed to get broadcast audience", zap.Error(err), zap.String("audience", audience))
+		h.sendAdminText(ctx, b, adminId, "❌ Аудиторияны алу мүмкін болмады, қайталап көріңіз.")
+		return true
+	}
+
+	h.sendAdminText(ctx, b, adminId, fmt.Sprintf("👀 Рассылка осылай көрінеді.\n%s: %d адам",
+		broadcastAudienceLabels[audience], len(recipients)))
+	buttons := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: fmt.Sprintf("✅ Жіберу (%d)", len(recipients)), CallbackData: broadcastSendPrefix + audience},
+				{Text: "❌ Бас тарту", CallbackData: broadcastCancelData},
+			},
+		},
+	}
+	if err := content.send(ctx, b, adminId, buttons); err != nil {
+		h.logger.Error("Failed to send broadcast preview", zap.Error(err), zap.Int64("admin_id", adminId))
+	}
+	return true
+}
+
+// runBroadcast sends the content to every recipient of the audience, at most broadcastRate
+// messages a second, and reports the counts to the admin. Users who blocked the bot are
+// recorded and left out of the next broadcasts.
+func (h *Handler) runBroadcast(ctx context.Context, b *bot.Bot, adminId int64, audience string, content broadcastContent) {
+	recipients, err := h.clientRepo.GetBroadcastAudience(ctx, audience)
+	if err != nil {
+		h.logger.Error("Failed to get broadcast audience", zap.Error(err), zap.String("audience", audience))
+		h.sendAdminText(ctx, b, adminId, "❌ Аудиторияны алу мүмкін болмады, рассылка жіберілмеді.")
+		return
+	}
+
+	ticker := time.NewTicker(time.Second / broadcastRate)
+	defer ticker.Stop()
+
+	var sent, failed, blocked int
+	for _, userId := range recipients {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			h.logger.Warn("Broadcast interrupted", zap.Int("sent", sent), zap.Int("left", len(recipients)-sent-failed))
+			return
+		}
+
+		err := service.SendWithRetry(ctx, telegramSendAttempts, func(ctx context.Context) error {
+			return content.send(ctx, b, userId, nil)
+		})
+		if err == nil {
+			sent++
+			continue
+		}
+
+		failed++
+		h.logger.Warn("Failed to send broadcast message", zap.Error(err), zap.Int64("user_id", userId))
+		if errors.Is(err, bot.ErrorForbidden) {
+			blocked++
+			if err := h.clientRepo.MarkBroadcastBlocked(ctx, userId, err.Error()); err != nil {
+				h.logger.Error("Failed to record blocked user", zap.Error(err), zap.Int64("user_id", userId))
+			}
+		}
+	}
+
+	h.logger.Info("Broadcast finished",
+		zap.String("audience", audience),
+		zap.Int("sent", sent),
+		zap.Int("failed", failed),
+		zap.Int("blocked", blocked))
+	h.sendAdminText(ctx, b, adminId, fmt.Sprintf(
+		"📣 Рассылка аяқталды: %s\n\n"+
+			"✅ Жіберілді: %d\n"+
+			"❌ Жіберілмеді: %d\n"+
+			"🚫 Оның ішінде ботты бұғаттағандар: %d",
+		broadcastAudienceLabels[audience], sent, failed, blocked))
+}
+
+// editBroadcastMessage replaces the text of a broadcast step message and drops its buttons
+func (h *Handler) editBroadcastMessage(ctx context.Context, b *bot.Bot, message *models.Message, text string) {
+	if _, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      message.Chat.ID,
+		MessageID:   message.ID,
+		Text:        text,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{}},
+	}); err != nil {
+		h.logger.Warn("Failed to update broadcast message", zap.Error(err))
+	}
+}
+
+func (h *Handler) sendAdminText(ctx context.Context, b *bot.Bot, adminId int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: adminId,
+		Text:   text,
+	}); err != nil {
+		h.logger.Warn("Failed to send message to admin", zap.Error(err), zap.Int64("admin_id", adminId))
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+
+	"parfum/internal/service/i18n"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// CancelCommand is the bot command that starts the purchase flow over
+const CancelCommand = "cancel"
+
+// CancelHandler handles /cancel and the start-over button from any state: it forgets the
+// user's conversation state, their quoted sum, a receipt waiting for a count and an admin's
+// unsent broadcast, then shows the start promo again. Paid orders are left as they are.
+func (h *Handler) CancelHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || update.Message.From == nil {
+		return
+	}
+
+	userId := update.Message.From.ID
+	if err := h.redisRepo.DeleteUserState(ctx, userId); err != nil {
+		h.logger.Error("Failed to delete user state", zap.Error(err), zap.Int64("user_id", userId))
+	}
+	if err := h.redisRepo.DeletePendingReceipt(ctx, userId); err != nil {
+		h.logger.Error("Failed to delete pending receipt", zap.Error(err), zap.Int64("user_id", userId))
+	}
+	if err := h.clientRepo.DeletePendingPayment(ctx, userId); err != nil {
+		h.logger.Error("Failed to delete pending payment", zap.Error(err), zap.Int64("user_id", userId))
+	}
+	if err := h.redisRepo.DeleteBroadcastState(ctx, userId); err != nil {
+		h.logger.Error("Failed to delete broadcast state", zap.Error(err), zap.Int64("user_id", userId))
+	}
+
+	h.logger.Info("User cancelled the conversation", zap.Int64("user_id", userId))
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        i18n.T(h.userLang(ctx, userId), i18n.CancelDone),
+		ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to confirm cancel", zap.Error(err))
+	}
+
+	h.StartHandler(ctx, b, update)
+}
+
+// cancelKeyboard is a reply keyboard with the start-over button
+func cancelKeyboard(lang string) *models.ReplyKeyboardMarkup {
+	return &models.ReplyKeyboardMarkup{
+		Keyboard: [][]models.KeyboardButton{
+			{
+				{Text: i18n.T(lang, i18n.CancelButton)},
+			},
+		},
+		ResizeKeyboard:  true,
+		OneTimeKeyboard: true,
+	}
+}
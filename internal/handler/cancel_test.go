@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+)
+
+func TestCancelFromEveryState(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	ctx := context.Background()
+
+	for i, state := range []string{StateStart, StateDefault, StateCount, StatePay, StateContact} {
+		t.Run(state, func(t *testing.T) {
+			userID := int64(7601 + i)
+
+			if err := h.clientRepo.InsertJust(ctx, domain.JustEntry{UserId: userID, UserName: "user"}); err != nil {
+				t.Fatalf("insert user: %v", err)
+			}
+			if err := h.clientRepo.SetLanguage(ctx, userID, i18n.LangKz); err != nil {
+				t.Fatalf("set language: %v", err)
+			}
+			paid := seedOrder(t, db, domain.Order{IDUser: userID, Parfumes: "Baccarat Rouge: 1"})
+
+			if err := h.redisRepo.SaveUserState(ctx, userID, &domain.UserState{State: state, Count: 2, Amount: 4998}); err != nil {
+				t.Fatalf("save state: %v", err)
+			}
+			if err := h.redisRepo.SavePendingReceipt(ctx, userID, &domain.PendingReceipt{FileName: "receipt.pdf"}, time.Hour); err != nil {
+				t.Fatalf("save pending receipt: %v", err)
+			}
+			if err := h.clientRepo.SavePendingPayment(ctx, domain.PendingPayment{UserID: userID, Quantity: 2, Amount: 4998}); err != nil {
+				t.Fatalf("save pending payment: %v", err)
+			}
+			photos := tg.count("sendPhoto")
+
+			h.CancelHandler(ctx, tg.bot, &models.Update{Message: &models.Message{
+				From: &models.User{ID: userID},
+				Chat: models.Chat{ID: userID},
+				Text: "/" + CancelCommand,
+			}})
+
+			if got, err := h.redisRepo.GetUserState(ctx, userID); err != nil || got != nil {
+				t.Errorf("state = %+v, %v; want it gone", got, err)
+			}
+			if got, err := h.redisRepo.GetPendingReceipt(ctx, userID); err != nil || got != nil {
+				t.Errorf("pending receipt = %+v, %v; want it gone", got, err)
+			}
+			if got, err := h.clientRepo.GetPendingPayment(ctx, userID); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("pending payment = %+v, %v; want it gone", got, err)
+			}
+
+			order, err := h.orderRepo.GetByID(ctx, paid.ID)
+			if err != nil {
+				t.Fatalf("get paid order: %v", err)
+			}
+			if order.Status != paid.Status || order.Parfumes != paid.Parfumes {
+				t.Errorf("paid order = %+v, want it untouched", order)
+			}
+
+			if !slices.Contains(tg.messages(userID), i18n.T(i18n.LangKz, i18n.CancelDone)) {
+				t.Errorf("messages = %q, want the reset confirmed", tg.messages(userID))
+			}
+			if tg.count("sendPhoto") != photos+1 {
+				t.Error("start promo wasn't sent again")
+			}
+		})
+	}
+}
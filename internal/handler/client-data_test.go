@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func getClientData(h *Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleGetClientData(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	return rec
+}
+
+func TestGetClientData(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	client := &domain.Client{TelegramID: 7201, FIO: "Ерлан", Contact: "+77017654321", Address: "Шымкент"}
+	if err := h.clientRepo.SaveOrUpdate(context.Background(), client); err != nil {
+		t.Fatalf("SaveOrUpdate: %v", err)
+	}
+
+	rec := getClientData(h, `{"telegram_id": 7201}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Success bool          `json:"success"`
+		Client  domain.Client `json:"client"`
+	}
+	decodeJSON(t, rec, &resp)
+	if !resp.Success || resp.Client.Address != "Шымкент" {
+		t.Errorf("response = %+v, want the saved client", resp)
+	}
+
+	rec = getClientData(h, `{"telegram_id": 7202}`)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "client_not_found") {
+		t.Errorf("missing client: status = %d, body %s; want 404 client_not_found", rec.Code, rec.Body.String())
+	}
+
+	// A broken database is a server error, not a missing client
+	if _, err := db.Exec(`DROP TABLE clients`); err != nil {
+		t.Fatalf("drop clients: %v", err)
+	}
+	rec = getClientData(h, `{"telegram_id": 7201}`)
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "database_error") {
+		t.Errorf("database error: status = %d, body %s; want 500 database_error", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"parfum/internal/service/i18n"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// HelpCommand is the bot command that lists the other commands
+const HelpCommand = "help"
+
+// botCommand is a command of the menu and of /help
+type botCommand struct {
+	command string
+	descKey string // catalog key of a user command's description
+	desc    string // an admin command's description; admin texts are Kazakh only
+}
+
+func (c botCommand) description(lang string) string {
+	if c.descKey != "" {
+		return i18n.T(lang, c.descKey)
+	}
+	return c.desc
+}
+
+// userCommands are everyone's commands, in menu order
+var userCommands = []botCommand{
+	{command: "start", descKey: i18n.StartCommandDesc},
+	{command: MyOrdersCommand, descKey: i18n.MyOrdersCommandDesc},
+	{command: CancelCommand, descKey: i18n.CancelCommandDesc},
+	{command: LanguageCommand, descKey: i18n.LanguageCommandDesc},
+	{command: HelpCommand, descKey: i18n.HelpCommandDesc},
+}
+
+// adminCommands follow the user commands for receiptAdmins
+var adminCommands = []botCommand{
+	{command: StatsCommand, desc: "Негізгі көрсеткіштер"},
+	{command: BroadcastCommand, desc: "Қолданушыларға рассылка жіберу"},
+	{command: PromoCommand, desc: "Старт промо мәтінін өзгерту"},
+	{command: DrawCommand, desc: "Лото ұтысының жеңімпазын анықтау"},
+}
+
+// commandMenu lists the commands in lang, with the admin commands if admin is set
+func commandMenu(lang string, admin bool) []models.BotCommand {
+	commands := userCommands
+	if admin {
+		commands = append(slices.Clip(userCommands), adminCommands...)
+	}
+
+	menu := make([]models.BotCommand, 0, len(commands))
+	for _, c := range commands {
+		menu = append(menu, models.BotCommand{Command: c.command, Description: c.description(lang)})
+	}
+	return menu
+}
+
+// RegisterCommands sets the menu under the bot's menu button: the user commands for
+// everyone and a menu with the admin commands in each admin's chat, in every supported
+// language. A menu that is already up to date is left alone, so restarts only call
+// setMyCommands after the commands or the catalog changed. A menu that can't be set is
+// logged and the others are still set.
+func (h *Handler) RegisterCommands(ctx context.Context, b *bot.Bot) {
+	for _, lang := range i18n.Langs {
+		// The Kazakh menus are the default ones, Russian clients get their own
+		languageCode := ""
+		if lang != i18n.DefaultLang {
+			languageCode = lang
+		}
+
+		h.setCommands(ctx, b, &models.BotCommandScopeDefault{}, languageCode, commandMenu(lang, false))
+		for _, adminID := range h.receiptAdmins() {
+			if adminID != 0 {
+				h.setCommands(ctx, b, &models.BotCommandScopeChat{ChatID: adminID}, languageCode, commandMenu(lang, true))
+			}
+		}
+	}
+}
+
+func (h *Handler) setCommands(ctx context.Context, b *bot.Bot, scope models.BotCommandScope, languageCode string, commands []models.BotCommand) {
+	current, err := b.GetMyCommands(ctx, &bot.GetMyCommandsParams{Scope: scope, LanguageCode: languageCode})
+	if err == nil && slices.Equal(current, commands) {
+		return
+	}
+
+	_, err = b.SetMyCommands(ctx, &bot.SetMyCommandsParams{
+		Commands:     commands,
+		Scope:        scope,
+		LanguageCode: languageCode,
+	})
+	if err != nil {
+		h.logger.Warn("Failed to set bot commands",
+			zap.Error(err),
+			zap.Any("scope", scope),
+			zap.String("lang", languageCode))
+	}
+}
+
+// HelpHandler handles /help by listing the same commands as the user's menu
+func (h *Handler) HelpHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || update.Message.From == nil {
+		return
+	}
+
+	userId := update.Message.From.ID
+	lang := h.userLang(ctx, userId)
+	lines := []string{i18n.T(lang, i18n.HelpTitle)}
+	for _, c := range commandMenu(lang, slices.Contains(h.receiptAdmins(), userId)) {
+		lines = append(lines, fmt.Sprintf("/%s — %s", c.Command, c.Description))
+	}
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   strings.Join(lines, "\n"),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send help", zap.Error(err), zap.Int64("user_id", userId))
+	}
+}
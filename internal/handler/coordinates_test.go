@@ -0,0 +1,25 @@
+package handler
+
+import "testing"
+
+func TestParseCoordinates(t *testing.T) {
+	tests := []struct {
+		latitude, longitude string
+		ok                  bool
+	}{
+		{"43.238949", "76.889709", true},
+		{"-90", "180", true},
+		{"", "", false},
+		{"43.2", "", false},
+		{"north", "76.8", false},
+		{"91", "76.8", false},
+		{"43.2", "-181", false},
+	}
+
+	for _, tt := range tests {
+		latitude, longitude := parseCoordinates(tt.latitude, tt.longitude)
+		if (latitude != nil) != tt.ok || (longitude != nil) != tt.ok {
+			t.Errorf("parseCoordinates(%q, %q) = %v, %v; want ok %v", tt.latitude, tt.longitude, latitude, longitude, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"parfum/internal/domain"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// maxNotificationRetryBatch caps how many pending notifications one bulk retry resends
+const maxNotificationRetryBatch = 100
+
+// unsentNotificationReasons are the failures of notifications that never reached Telegram:
+// they were queued while the bot wasn't set, or left in the outbox at shutdown
+var unsentNotificationReasons = []string{errBotNotInitialized.Error(), context.Canceled.Error()}
+
+// logFailedNotification keeps a message Telegram didn't deliver to a user, so an admin
+// can review and resend it. orderID 0 means the message isn't about an order.
+func (h *Handler) logFailedNotification(telegramID, orderID int64, params *bot.SendMessageParams, sendErr error) {
+	notification := domain.FailedNotification{
+		TelegramID: telegramID,
+		Message:    params.Text,
+		ParseMode:  string(params.ParseMode),
+		Reason:     sendErr.Error(),
+	}
+	if orderID != 0 {
+		notification.OrderID = &orderID
+	}
+	if params.ReplyMarkup != nil {
+		markup, err := json.Marshal(params.ReplyMarkup)
+		if err != nil {
+			h.logger.Error("Failed to encode reply markup of failed notification", zap.Error(err))
+		} else {
+			notification.ReplyMarkup = string(markup)
+		}
+	}
+
+	// Outlives shutdown, so the notifications left in the outbox are still kept
+	id, err := h.clientRepo.LogFailedNotification(context.WithoutCancel(h.ctx), notification)
+	if err != nil {
+		h.logger.Error("Failed to log failed notification",
+			zap.Error(err),
+			zap.Int64("telegram_id", telegramID),
+			zap.Int64("order_id", orderID))
+		return
+	}
+
+	h.logger.Info("Failed notification logged",
+		zap.Int64("id", id),
+		zap.Int64("telegram_id", telegramID),
+		zap.Int64("order_id", orderID))
+}
+
+// flushPendingNotifications queues every notification that never reached Telegram, oldest
+// first, once there is a bot to send them with. Each row is updated with how its resend went.
+func (h *Handler) flushPendingNotifications() {
+	notifications, err := h.clientRepo.GetUnsentNotifications(h.ctx, unsentNotificationReasons, outboxSize)
+	if err != nil {
+		h.logger.Error("Error listing pending notifications", zap.Error(err))
+		return
+	}
+	if len(notifications) == 0 {
+		return
+	}
+
+	h.logger.Info("Sending pending notifications", zap.Int("count", len(notifications)))
+	for _, notification := range notifications {
+		params := &bot.SendMessageParams{
+			ChatID:    notification.TelegramID,
+			Text:      notification.Message,
+			ParseMode: models.ParseMode(notification.ParseMode),
+		}
+		if notification.ReplyMarkup != "" {
+			params.ReplyMarkup = json.RawMessage(notification.ReplyMarkup)
+		}
+		var orderID int64
+		if notification.OrderID != nil {
+			orderID = *notification.OrderID
+		}
+
+		h.enqueue(outboundMessage{
+			bot:            h.bot,
+			chatID:         notification.TelegramID,
+			orderID:        orderID,
+			notificationID: notification.ID,
+			params:         params,
+			result:         make(chan error, 1),
+		})
+	}
+}
+
+// resendFailedNotification sends a failed notification again and records the attempt.
+// It returns why the send failed, or "" if the message was delivered.
+func (h *Handler) resendFailedNotification(ctx context.Context, notification *domain.FailedNotification) (string, error) {
+	params := &bot.SendMessageParams{
+		ChatID:    notification.TelegramID,
+		Text:      notification.Message,
+		ParseMode: models.ParseMode(notification.ParseMode),
+	}
+	if notification.ReplyMarkup != "" {
+		params.ReplyMarkup = json.RawMessage(notification.ReplyMarkup)
+	}
+
+	reason := ""
+	if err := sendMessageWithRetry(ctx, h.bot, params); err != nil {
+		reason = err.Error()
+	}
+	return reason, h.clientRepo.RecordNotificationAttempt(ctx, notification.ID, reason)
+}
+
+// handleFailedNotificationRoutes dispatches /api/failed-notifications,
+// /api/failed-notifications/retry and /api/failed-notifications/{id}/retry
+func (h *Handler) handleFailedNotificationRoutes(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/failed-notifications"), "/")
+	switch {
+	case path == "":
+		h.handleGetFailedNotifications(w, r)
+	case path == "retry":
+		h.handleRetryFailedNotifications(w, r)
+	case strings.HasSuffix(path, "/retry"):
+		h.handleRetryFailedNotification(w, r)
+	default:
+		writeJSONError(w, http.StatusNotFound, "not_found", "Not found", nil)
+	}
+}
+
+// Failed user notifications, newest first
+// GET /api/failed-notifications?status=pending|delivered|all&page=1&per_page=20
+func (h *Handler) handleGetFailedNotifications(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	query := r.URL.Query()
+	page, perPage := 1, defaultClientsPerPage
+	if raw := query.Get("page"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_page", "page must be a positive number", nil)
+			return
+		}
+		page = value
+	}
+	if raw := query.Get("per_page"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > maxClientsPerPage {
+			writeJSONError(w, http.StatusBadRequest, "invalid_per_page",
+				fmt.Sprintf("per_page must be a number between 1 and %d", maxClientsPerPage), nil)
+			return
+		}
+		perPage = value
+	}
+
+	filter := domain.FailedNotificationFilter{Limit: perPage, Offset: (page - 1) * perPage}
+	switch status := query.Get("status"); status {
+	case "", "pending":
+		delivered := false
+		filter.Delivered = &delivered
+	case "delivered":
+		delivered := true
+		filter.Delivered = &delivered
+	case "all":
+	default:
+		writeJSONError(w, http.StatusBadRequest, "invalid_status", "status must be pending, delivered or all", nil)
+		return
+	}
+
+	total, err := h.clientRepo.CountFailedNotifications(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Error counting failed notifications", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	notifications, err := h.clientRepo.GetFailedNotifications(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Error listing failed notifications", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"notifications": notifications,
+		"total":         total,
+		"page":          page,
+		"per_page":      perPage,
+		"total_pages":   (total + perPage - 1) / perPage,
+	})
+}
+
+// Resend one failed notification
+// POST /api/failed-notifications/{id}/retry
+func (h *Handler) handleRetryFailedNotification(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	idStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/failed-notifications/"), "/retry")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_notification_id", "Invalid notification ID", nil)
+		return
+	}
+
+	if h.bot == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "bot_unavailable", "Bot not initialized", nil)
+		return
+	}
+
+	notification, err := h.clientRepo.GetFailedNotification(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "notification_not_found", "Notification not found", nil)
+			return
+		}
+		h.logger.Error("Error getting failed notification", zap.Error(err), zap.Int64("id", id))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	if notification.DeliveredAt != nil {
+		writeJSONError(w, http.StatusConflict, "already_delivered", "Notification was already delivered", nil)
+		return
+	}
+
+	reason, err := h.resendFailedNotification(r.Context(), notification)
+	if err != nil {
+		h.logger.Error("Error recording notification retry", zap.Error(err), zap.Int64("id", id))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success":   true,
+		"id":        id,
+		"delivered": reason == "",
+	}
+	if reason != "" {
+		response["error"] = reason
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Resend every pending failed notification, oldest first
+// POST /api/failed-notifications/retry
+func (h *Handler) handleRetryFailedNotifications(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	if h.bot == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "bot_unavailable", "Bot not initialized", nil)
+		return
+	}
+
+	pending := false
+	notifications, err := h.clientRepo.GetFailedNotifications(r.Context(), domain.FailedNotificationFilter{
+		Delivered: &pending,
+		Limit:     maxNotificationRetryBatch,
+	})
+	if err != nil {
+		h.logger.Error("Error listing failed notifications", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	delivered, failed := 0, 0
+	for i := len(notifications) - 1; i >= 0; i-- {
+		reason, err := h.resendFailedNotification(r.Context(), &notifications[i])
+		if err != nil {
+			h.logger.Error("Error recording notification retry", zap.Error(err), zap.Int64("id", notifications[i].ID))
+		}
+		if reason != "" {
+			failed++
+		} else {
+			delivered++
+		}
+	}
+
+	h.logger.Info("Retried failed notifications", zap.Int("delivered", delivered), zap.Int("failed", failed))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"attempted": len(notifications),
+		"delivered": delivered,
+		"failed":    failed,
+	})
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// failedNotificationList is the response of GET /api/failed-notifications
+type failedNotificationList struct {
+	Notifications []domain.FailedNotification `json:"notifications"`
+	Total         int                         `json:"total"`
+	TotalPages    int                         `json:"total_pages"`
+}
+
+func listFailedNotifications(t *testing.T, h *Handler, query string) failedNotificationList {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.handleFailedNotificationRoutes(rec, adminRequest("GET", "/api/failed-notifications"+query, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var list failedNotificationList
+	decodeJSON(t, rec, &list)
+	return list
+}
+
+func TestFailedSendIsLoggedAndRetried(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	h.SetBot(tg.bot)
+	order := seedOrder(t, db, domain.Order{IDUser: 7501})
+
+	// The user blocked the bot: Telegram refuses, and the message is kept
+	tg.failNext("sendMessage", 1)
+	err := <-h.enqueueMessage(7501, order.ID, &bot.SendMessageParams{
+		ChatID:    7501,
+		Text:      "Тапсырыс расталды",
+		ParseMode: models.ParseModeMarkdown,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "Төлеу", URL: "https://pay.kaspi.kz/pay/test"}},
+		}},
+	})
+	if err == nil {
+		t.Fatal("send succeeded, want the refusal")
+	}
+
+	list := listFailedNotifications(t, h, "")
+	if list.Total != 1 || len(list.Notifications) != 1 {
+		t.Fatalf("pending = %+v, want the failed message", list)
+	}
+	failed := list.Notifications[0]
+	if failed.TelegramID != 7501 || failed.OrderID == nil || *failed.OrderID != order.ID ||
+		failed.Message != "Тапсырыс расталды" || failed.ParseMode != string(models.ParseModeMarkdown) ||
+		!strings.Contains(failed.ReplyMarkup, "pay.kaspi.kz") || !strings.Contains(failed.Reason, "test failure") {
+		t.Errorf("failed notification = %+v", failed)
+	}
+
+	rec := httptest.NewRecorder()
+	h.handleFailedNotificationRoutes(rec, adminRequest("POST", fmt.Sprintf("/api/failed-notifications/%d/retry", failed.ID), ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retry status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var retry struct {
+		Delivered bool `json:"delivered"`
+	}
+	decodeJSON(t, rec, &retry)
+	if !retry.Delivered {
+		t.Errorf("retry = %s, want delivered", rec.Body.String())
+	}
+	tg.waitForMessage(t, 7501, "Тапсырыс расталды")
+
+	if pending := listFailedNotifications(t, h, ""); pending.Total != 0 {
+		t.Errorf("pending after retry = %+v, want none", pending)
+	}
+	delivered := listFailedNotifications(t, h, "?status=delivered")
+	if delivered.Total != 1 || delivered.Notifications[0].DeliveredAt == nil || delivered.Notifications[0].Attempts < 1 {
+		t.Errorf("delivered = %+v, want the retried message", delivered)
+	}
+
+	// Retrying a delivered message again is refused
+	rec = httptest.NewRecorder()
+	h.handleFailedNotificationRoutes(rec, adminRequest("POST", fmt.Sprintf("/api/failed-notifications/%d/retry", failed.ID), ""))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("second retry status = %d, want 409", rec.Code)
+	}
+}
+
+func TestListFailedNotificationsPages(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		_, err := h.clientRepo.LogFailedNotification(ctx, domain.FailedNotification{
+			TelegramID: int64(7510 + i),
+			Message:    fmt.Sprintf("message %d", i),
+			Reason:     "Forbidden: bot was blocked by the user",
+		})
+		if err != nil {
+			t.Fatalf("log notification: %v", err)
+		}
+	}
+
+	page := listFailedNotifications(t, h, "?per_page=2&page=3")
+	if page.Total != 5 || page.TotalPages != 3 || len(page.Notifications) != 1 {
+		t.Errorf("page 3 = %+v, want the last of five", page)
+	}
+	if all := listFailedNotifications(t, h, "?status=all&per_page=10"); len(all.Notifications) != 5 {
+		t.Errorf("all = %d notifications, want 5", len(all.Notifications))
+	}
+
+	for _, query := range []string{"?status=lost", "?page=0", "?per_page=abc"} {
+		rec := httptest.NewRecorder()
+		h.handleFailedNotificationRoutes(rec, adminRequest("GET", "/api/failed-notifications"+query, ""))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestNotificationQueuedWithoutBotIsSentAfterSetBot(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	order := seedOrder(t, db, domain.Order{IDUser: 7521})
+
+	err := <-h.enqueueMessage(7521, order.ID, &bot.SendMessageParams{ChatID: 7521, Text: "Тапсырыс қабылданды"})
+	if !errors.Is(err, errBotNotInitialized) {
+		t.Fatalf("send without a bot = %v, want %v", err, errBotNotInitialized)
+	}
+	if list := listFailedNotifications(t, h, ""); list.Total != 1 || list.Notifications[0].Reason != errBotNotInitialized.Error() {
+		t.Fatalf("pending = %+v, want the message kept until there is a bot", list)
+	}
+
+	h.SetBot(tg.bot)
+	tg.waitForMessage(t, 7521, "Тапсырыс қабылданды")
+
+	// The row is marked sent once the resend went through
+	deadline := time.Now().Add(3 * time.Second)
+	for listFailedNotifications(t, h, "").Total != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("notification still pending after it was sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,43 @@
+// Package fakes has in-memory stores to run a handler.Handler in tests without SQLite
+// and Redis. Each fake embeds its handler store interface, left nil, and implements only
+// the methods the prize wheel and perfume selection handlers call; any other method
+// panics, which points a test at the method the fake is missing.
+package fakes
+
+import (
+	"context"
+
+	"parfum/internal/handler"
+	"parfum/internal/repository"
+)
+
+// Set is one of each fake. Orders and OrderItems are linked the way their tables are, so
+// items count against the quantity of their order.
+type Set struct {
+	Orders     *Orders
+	OrderItems *OrderItems
+	Parfumes   *Parfumes
+	State      *repository.MemoryStateRepository
+}
+
+// New creates an empty set; the state store sweeps expired entries until ctx is done
+func New(ctx context.Context) *Set {
+	items := NewOrderItems()
+	return &Set{
+		Orders:     NewOrders(items),
+		OrderItems: items,
+		Parfumes:   NewParfumes(),
+		State:      repository.NewMemoryStateRepository(ctx),
+	}
+}
+
+// Stores are the fakes as handler stores. Clients, photos, idempotency keys and settings
+// have no fake and are left nil.
+func (s *Set) Stores() handler.Stores {
+	return handler.Stores{
+		Orders:     s.Orders,
+		OrderItems: s.OrderItems,
+		Parfumes:   s.Parfumes,
+		State:      s.State,
+	}
+}
@@ -0,0 +1,55 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/handler"
+)
+
+// OrderItems keeps the perfumes chosen for each order in memory
+type OrderItems struct {
+	handler.OrderItemStore
+
+	mu     sync.Mutex
+	nextID int64
+	items  map[int64][]domain.OrderItem // by order ID
+}
+
+func NewOrderItems() *OrderItems {
+	return &OrderItems{items: make(map[int64][]domain.OrderItem)}
+}
+
+func (s *OrderItems) AddItems(ctx context.Context, orderID int64, items []domain.OrderItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, item := range items {
+		s.nextID++
+		item.ID = s.nextID
+		item.OrderID = orderID
+		item.CreatedAt, item.UpdatedAt = now, now
+		s.items[orderID] = append(s.items[orderID], item)
+	}
+	return nil
+}
+
+func (s *OrderItems) GetByOrder(ctx context.Context, orderID int64) ([]domain.OrderItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]domain.OrderItem{}, s.items[orderID]...), nil
+}
+
+func (s *OrderItems) SumQuantityByOrder(ctx context.Context, orderID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for _, item := range s.items[orderID] {
+		total += item.Quantity
+	}
+	return total, nil
+}
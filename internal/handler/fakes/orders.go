@@ -0,0 +1,260 @@
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/handler"
+	"parfum/internal/repository"
+)
+
+// Orders keeps orders, spins, order events, prize stock and prize rules in memory
+type Orders struct {
+	handler.OrderStore
+
+	items *OrderItems
+
+	mu     sync.Mutex
+	orders []domain.Order // by ID
+	spins  []domain.Spin
+	events []domain.OrderEvent
+	stock  map[string]int // prizes with limited stock and how many are left
+	rules  *domain.PrizeRules
+}
+
+// NewOrders creates an empty store; items are what GetAvailableQuantityForUser subtracts
+func NewOrders(items *OrderItems) *Orders {
+	return &Orders{items: items, stock: make(map[string]int)}
+}
+
+// Add stores order with the next ID and returns it. Like the repository, an order without
+// a status is paid and one without a creation time is created now.
+func (s *Orders) Add(order domain.Order) domain.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order.ID = int64(len(s.orders) + 1)
+	if order.Status == "" {
+		order.Status = domain.OrderStatusPaid
+	}
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = time.Now()
+	}
+	s.orders = append(s.orders, order)
+	return order
+}
+
+// Order returns a stored order, false if there is none with that ID
+func (s *Orders) Order(id int64) (domain.Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order := s.find(id)
+	if order == nil {
+		return domain.Order{}, false
+	}
+	return *order, true
+}
+
+// SetStock limits a prize to left more awards, like a prize_inventory row
+func (s *Orders) SetStock(prize string, left int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stock[prize] = left
+}
+
+// SetPrizeRules saves the rules GetActivePrizeRules returns; without any it returns
+// repository.ErrNoPrizeRules
+func (s *Orders) SetPrizeRules(rules domain.PrizeRules) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = &rules
+}
+
+// Spins returns the recorded spins in the order they were recorded
+func (s *Orders) Spins() []domain.Spin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.spins)
+}
+
+// Events returns the recorded order events in the order they were recorded
+func (s *Orders) Events() []domain.OrderEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.events)
+}
+
+func (s *Orders) find(id int64) *domain.Order {
+	if id < 1 || id > int64(len(s.orders)) {
+		return nil
+	}
+	return &s.orders[id-1]
+}
+
+func hasPrize(order domain.Order) bool {
+	return order.Gift != "" && order.Gift != "null"
+}
+
+// selectable is an order whose kits count: neither cancelled nor still awaiting payment
+func selectable(order domain.Order) bool {
+	return order.Status != domain.OrderStatusCancelled && order.Status != domain.OrderStatusAwaitingPayment
+}
+
+func (s *Orders) GetOrdersEligibleForPrize(ctx context.Context, telegramID int64) ([]domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []domain.Order
+	for _, order := range s.orders {
+		if order.IDUser == telegramID && order.Parfumes != "" && !hasPrize(order) && selectable(order) {
+			orders = append(orders, order)
+		}
+	}
+	slices.SortStableFunc(orders, func(a, b domain.Order) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return orders, nil
+}
+
+func (s *Orders) GetPrizeOrdersByUser(ctx context.Context, telegramID int64) ([]domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []domain.Order
+	for i := len(s.orders) - 1; i >= 0; i-- {
+		if order := s.orders[i]; order.IDUser == telegramID && hasPrize(order) {
+			orders = append(orders, order)
+		}
+	}
+	slices.SortStableFunc(orders, func(a, b domain.Order) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return orders, nil
+}
+
+func (s *Orders) GetOrderSequenceNumber(ctx context.Context, orderID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sequence := 1
+	for _, order := range s.orders {
+		if order.ID < orderID && order.Parfumes != "" {
+			sequence++
+		}
+	}
+	return sequence, nil
+}
+
+func (s *Orders) AwardPrize(ctx context.Context, orderID int64, prize, fallback string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order := s.find(orderID)
+	if order == nil {
+		return "", false, fmt.Errorf("no order found with id %d", orderID)
+	}
+	if hasPrize(*order) {
+		return order.Gift, false, nil
+	}
+
+	if left, limited := s.stock[prize]; limited {
+		if left > 0 {
+			s.stock[prize] = left - 1
+		} else {
+			prize = fallback
+		}
+	}
+	now := time.Now()
+	order.Gift = prize
+	order.PrizeAwardedAt = &now
+	return prize, true, nil
+}
+
+func (s *Orders) RecordSpin(ctx context.Context, spin *domain.Spin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	spin.ID = int64(len(s.spins) + 1)
+	spin.CreatedAt = time.Now()
+	s.spins = append(s.spins, *spin)
+	return nil
+}
+
+func (s *Orders) GetSpunOrderIDs(ctx context.Context, telegramID int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orderIDs := []int64{}
+	for _, spin := range s.spins {
+		if spin.TelegramID == telegramID {
+			orderIDs = append(orderIDs, spin.OrderID)
+		}
+	}
+	slices.Sort(orderIDs)
+	return orderIDs, nil
+}
+
+func (s *Orders) GetActivePrizeRules(ctx context.Context) (*domain.PrizeRules, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rules == nil {
+		return nil, repository.ErrNoPrizeRules
+	}
+	rules := *s.rules
+	return &rules, nil
+}
+
+func (s *Orders) RecordEvent(ctx context.Context, orderID int64, eventType domain.OrderEventType, actor string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event payload: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, domain.OrderEvent{
+		ID:        int64(len(s.events) + 1),
+		OrderID:   orderID,
+		EventType: eventType,
+		Payload:   data,
+		Actor:     actor,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+func (s *Orders) GetUnpaidOrdersByUser(ctx context.Context, telegramID int64) ([]domain.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []domain.Order
+	for _, order := range s.orders {
+		if order.IDUser == telegramID && !order.Checks && (order.Quantity == nil || *order.Quantity > 0) && selectable(order) {
+			orders = append(orders, order)
+		}
+	}
+	slices.SortStableFunc(orders, func(a, b domain.Order) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return orders, nil
+}
+
+func (s *Orders) GetAvailableQuantityForUser(ctx context.Context, telegramID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	available := 0
+	for _, order := range s.orders {
+		if order.IDUser != telegramID || order.Checks || order.Quantity == nil || *order.Quantity <= 0 || !selectable(order) {
+			continue
+		}
+		used, err := s.items.SumQuantityByOrder(ctx, order.ID)
+		if err != nil {
+			return 0, err
+		}
+		available += *order.Quantity - used
+	}
+	return available, nil
+}
@@ -0,0 +1,67 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	"parfum/internal/handler"
+	"parfum/internal/repository"
+)
+
+// Parfumes keeps the perfume catalog in memory
+type Parfumes struct {
+	handler.ParfumeStore
+
+	mu       sync.Mutex
+	products map[string]repository.Product // by ID
+}
+
+func NewParfumes() *Parfumes {
+	return &Parfumes{products: make(map[string]repository.Product)}
+}
+
+// Add stores products, replacing any with the same ID. IsActive is set from DeletedAt, as
+// the repository reads it.
+func (s *Parfumes) Add(products ...repository.Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, product := range products {
+		product.IsActive = product.DeletedAt == nil
+		product.InStock = product.Stock == nil || *product.Stock > 0
+		s.products[product.Id] = product
+	}
+}
+
+func (s *Parfumes) GetByIDs(ctx context.Context, ids []string) (map[string]repository.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]repository.Product, len(ids))
+	for _, id := range ids {
+		if product, ok := s.products[id]; ok {
+			result[id] = product
+		}
+	}
+	return result, nil
+}
+
+func (s *Parfumes) GetByNames(ctx context.Context, names []string) (map[string]repository.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]repository.Product, len(names))
+	for _, name := range names {
+		for _, product := range s.products {
+			if product.NameParfume != name {
+				continue
+			}
+			// An active perfume wins over an archived one with the same name
+			if existing, ok := result[name]; ok && existing.IsActive {
+				continue
+			}
+			result[name] = product
+		}
+	}
+	return result, nil
+}
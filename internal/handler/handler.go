@@ -3,20 +3,31 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"parfum/config"
 	"parfum/internal/domain"
 	"parfum/internal/repository"
 	"parfum/internal/service"
+	"parfum/internal/service/i18n"
+	"parfum/traits/cache"
+	"parfum/traits/keylock"
+	"parfum/traits/pubsub"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-telegram/bot"
@@ -35,14 +46,27 @@ const (
 )
 
 type Handler struct {
-	cfg         *config.Config
-	logger      *zap.Logger
-	ctx         context.Context
-	bot         *bot.Bot
-	parfumeRepo *repository.ParfumeRepository
-	clientRepo  *repository.ClientRepository
-	orderRepo   *repository.OrderRepository
-	redisRepo   *repository.RedisRepository
+	cfg           *config.Config
+	logger        *zap.Logger
+	ctx           context.Context
+	bot           *bot.Bot
+	parfumeRepo   ParfumeStore
+	clientRepo    ClientStore
+	orderRepo     OrderStore
+	orderItemRepo OrderItemStore
+	photoRepo     PhotoStore
+	redisRepo     StateStore
+
+	idempotencyRepo IdempotencyStore
+	settingsRepo    SettingsStore
+	dashboardCache  *cache.TTLCache[string, *domain.DashboardStats]
+	metrics         *handlerMetrics
+	broadcasting    *atomic.Bool // an admin broadcast is being sent; shared by copies of the handler
+	events          *pubsub.Broker[AdminEvent]
+	outbox          chan outboundMessage // notifications waiting for runOutbox
+	silent          bool                 // simulated orders send nothing to Telegram
+	userLocks       *keylock.Locker[int64]
+	prizeSecret     []byte // signs prize tokens, see signPrizeToken
 }
 
 type Client struct {
@@ -69,34 +93,61 @@ type Order struct {
 	UpdatedAt   string `json:"updated_at"`
 }
 
-type CartItem struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Price    int    `json:"price"`
-	Quantity int    `json:"quantity"`
-}
-
-
 // Prize types
 const (
 	Prize10ML    = "parfum_10ml"
-	Prize30ML    = "parfum_30ml" 
+	Prize30ML    = "parfum_30ml"
 	PrizeDiamond = "diamond_ring"
 	PrizeMoney   = "money"
 )
 
+// isValidPrize reports whether prize is one of the known prize types
+func isValidPrize(prize string) bool {
+	switch prize {
+	case Prize10ML, Prize30ML, PrizeDiamond, PrizeMoney:
+		return true
+	}
+	return false
+}
+
+// prizeMessageKeys are the catalog keys of the prize names shown to users and admins
+var prizeMessageKeys = map[string]string{
+	Prize10ML:    i18n.Prize10ML,
+	Prize30ML:    i18n.Prize30ML,
+	PrizeDiamond: i18n.PrizeDiamond,
+	PrizeMoney:   i18n.PrizeMoney,
+}
+
+// PrizeDisplayName returns the human-readable name of a prize code, or the code itself if it's unknown
+func PrizeDisplayName(code string) string {
+	return prizeName(i18n.DefaultLang, code)
+}
+
+// prizeName is PrizeDisplayName in the given language
+func prizeName(lang, code string) string {
+	if key, ok := prizeMessageKeys[code]; ok {
+		return i18n.T(lang, key)
+	}
+	return code
+}
+
 // Prize wheel spin request/response
 type SpinWheelRequest struct {
 	TelegramID int64 `json:"telegram_id"`
 }
 
 type SpinWheelResponse struct {
-	Success   bool   `json:"success"`
-	CanSpin   bool   `json:"can_spin"`
-	PrizeWon  string `json:"prize_won,omitempty"`
-	Message   string `json:"message"`
-	OrderID   int64  `json:"order_id,omitempty"`
-	SpinsLeft int    `json:"spins_left"`
+	Success    bool       `json:"success"`
+	CanSpin    bool       `json:"can_spin"`
+	PrizeWon   string     `json:"prize_won,omitempty"`
+	Message    string     `json:"message"`
+	OrderID    int64      `json:"order_id,omitempty"`
+	SpinsLeft  int        `json:"spins_left"`
+	NextSpinAt *time.Time `json:"next_spin_at,omitempty"` // set while the cooldown or daily cap holds the next spin back
+
+	SectorIndex         *int       `json:"sector_index,omitempty"` // the wheel sector of the prize, see prizeWheelSectors
+	PrizeToken          string     `json:"prize_token,omitempty"`  // completes the prize on /api/prize/complete
+	PrizeTokenExpiresAt *time.Time `json:"prize_token_expires_at,omitempty"`
 }
 
 // Prize completion request
@@ -110,65 +161,81 @@ type CompletePrizeRequest struct {
 	Longitude  string `json:"longitude"`
 }
 
-func NewHandler(cfg *config.Config, zapLogger *zap.Logger, ctx context.Context, db *sql.DB, redisClient *redis.Client) *Handler {
-	h := &Handler{
-		cfg:         cfg,
-		logger:      zapLogger,
-		ctx:         ctx,
-		redisRepo:   repository.NewRedisRepository(redisClient),
-		parfumeRepo: repository.NewParfumeRepository(db),
-		clientRepo:  repository.NewClientRepository(db),
-		orderRepo:   repository.NewOrderRepository(db),
+func NewHandler(cfg *config.Config, zapLogger *zap.Logger, ctx context.Context, db *sql.DB, redisClient *redis.Client) (*Handler, error) {
+	redisRepo := repository.NewRedisRepository(redisClient)
+	state := newFallbackStateStore(ctx, redisRepo, repository.NewMemoryStateRepository(ctx), redisRepo.Ping, zapLogger)
+
+	return NewHandlerWithStores(cfg, zapLogger, ctx, Stores{
+		Orders:      repository.NewOrderRepository(db),
+		OrderItems:  repository.NewOrderItemRepository(db),
+		Clients:     repository.NewClientRepository(db),
+		Parfumes:    repository.NewParfumeRepository(db),
+		Photos:      repository.NewParfumePhotoRepository(db),
+		State:       state,
+		Idempotency: repository.NewIdempotencyRepository(db),
+		Settings:    repository.NewSettingsRepository(db),
+	})
+}
+
+// NewHandlerWithStores builds a Handler on the given stores, e.g. in-memory ones in tests
+func NewHandlerWithStores(cfg *config.Config, zapLogger *zap.Logger, ctx context.Context, stores Stores) (*Handler, error) {
+	prizeSecret, err := newPrizeTokenSecret(cfg, zapLogger)
+	if err != nil {
+		return nil, err
 	}
 
-	return h
+	h := &Handler{
+		cfg:           cfg,
+		logger:        zapLogger,
+		ctx:           ctx,
+		redisRepo:     stores.State,
+		parfumeRepo:   stores.Parfumes,
+		clientRepo:    stores.Clients,
+		orderRepo:     stores.Orders,
+		orderItemRepo: stores.OrderItems,
+		photoRepo:     stores.Photos,
+
+		idempotencyRepo: stores.Idempotency,
+		settingsRepo:    stores.Settings,
+		dashboardCache:  cache.NewTTLCache[string, *domain.DashboardStats](ctx, time.Minute),
+		metrics:         newHandlerMetrics(),
+		broadcasting:    new(atomic.Bool),
+		events:          pubsub.NewBroker[AdminEvent](),
+		outbox:          make(chan outboundMessage, outboxSize),
+		userLocks:       keylock.New[int64](),
+		prizeSecret:     prizeSecret,
+	}
+	go h.runOutbox()
+
+	return h, nil
 }
 
-
-// Deterministic prize algorithm based on order sequence number
-func (h *Handler) DeterminePrize(orderSequence int) string {
-	// Every 200th order gets money (highest priority)
-	if orderSequence%200 == 0 {
+// Deterministic prize algorithm based on order sequence number: money has the highest
+// priority, then diamond rings at their interval and at the fixed positions, then 30ml
+func (h *Handler) DeterminePrize(rules domain.PrizeRules, orderSequence int) string {
+	if orderSequence%rules.MoneyInterval == 0 {
 		return PrizeMoney
 	}
 
-	// Diamond rings: try to place at multiples of 100, with collision handling
-	// We want 10 diamonds in first 1000 orders (1% rate)
-	if orderSequence%100 == 0 {
-		// This should be a diamond position, but check if it conflicts with money
-		if orderSequence%200 != 0 {
-			return PrizeDiamond
-		}
+	// The fixed positions make up for the interval positions money takes
+	if orderSequence%rules.DiamondInterval() == 0 || slices.Contains(rules.DiamondPositions, orderSequence) {
+		return PrizeDiamond
 	}
 
-	// Handle diamond shifting for collision cases
-	// If we're at a diamond position that conflicts with money,
-	// we need to shift diamonds to nearby positions
-	diamondPositions := []int{50, 150, 250, 350, 450, 550, 650, 750, 850, 950}
-	for _, pos := range diamondPositions {
-		if orderSequence == pos {
-			return PrizeDiamond
-		}
+	if orderSequence%rules.ML30Interval == 0 {
+		return Prize30ML
 	}
 
-	// Every 30th order gets 30ml (if not already taken by higher priority)
-	if orderSequence%30 == 0 {
-		// Check if this position is not taken by money or diamond
-		if orderSequence%200 != 0 && orderSequence%100 != 0 {
-			isDiamondPosition := false
-			for _, pos := range diamondPositions {
-				if orderSequence == pos {
-					isDiamondPosition = true
-					break
-				}
-			}
-			if !isDiamondPosition {
-				return Prize30ML
-			}
-		}
-	}
+	// All remaining orders get 10ml
+	return Prize10ML
+}
 
-	// All remaining orders get 10ml (should be ~90%)
+// fallbackPrize is what an order gets when DeterminePrize drew a ring or cash that is out
+// of stock: the 30ml on its interval as usual, 10ml otherwise
+func fallbackPrize(rules domain.PrizeRules, orderSequence int) string {
+	if orderSequence%rules.ML30Interval == 0 {
+		return Prize30ML
+	}
 	return Prize10ML
 }
 
@@ -181,36 +248,39 @@ func (h *Handler) CheckSpinEligibility(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	telegramIDStr := r.URL.Query().Get("telegram_id")
 	if telegramIDStr == "" {
-		http.Error(w, "telegram_id parameter required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "telegram_id parameter required", nil)
 		return
 	}
 
 	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid telegram_id", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
 		return
 	}
 
-	// Get user's orders that are paid but not yet completed with prizes
-	orders, err := h.orderRepo.GetUnpaidOrdersByUser(telegramID)
+	// Orders with a finalized perfume selection that haven't got a prize yet
+	orders, err := h.orderRepo.GetOrdersEligibleForPrize(r.Context(), telegramID)
 	if err != nil {
 		h.logger.Error("Error getting user orders", zap.Error(err))
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
 		return
 	}
 
+	// Orders with a recorded spin don't count, whatever their gift says
+	spunOrders := h.spunOrders(r.Context(), telegramID)
+
 	availableSpins := 0
 	var eligibleOrders []map[string]interface{}
 
 	for _, order := range orders {
 		// Count orders that have perfume selections but no prize yet
-		if order.Parfumes != "" && (order.Gift == "" || order.Gift == "null") {
+		if order.Parfumes != "" && (order.Gift == "" || order.Gift == "null") && !slices.Contains(spunOrders, order.ID) {
 			availableSpins++
 			eligibleOrders = append(eligibleOrders, map[string]interface{}{
 				"id":         order.ID,
@@ -221,12 +291,41 @@ func (h *Handler) CheckSpinEligibility(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// spins_available counts the orders still to spin for, spins_left those of them the
+	// daily cap allows today
+	spinsLeft, nextSpinAt := h.spinAllowance(r.Context(), telegramID, availableSpins)
+
+	// Prizes won earlier that still wait for a delivery address, so the user can finish
+	// them without spinning again
+	unclaimedPrizes := []map[string]interface{}{}
+	prizeOrders, err := h.orderRepo.GetPrizeOrdersByUser(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Warn("Failed to get prize orders", zap.Error(err), zap.Int64("telegram_id", telegramID))
+	}
+	for _, order := range prizeOrders {
+		if order.PrizeClaim() != domain.PrizeClaimPending {
+			continue
+		}
+		unclaimedPrizes = append(unclaimedPrizes, map[string]interface{}{
+			"order_id":     order.ID,
+			"prize":        order.Gift,
+			"prize_name":   PrizeDisplayName(order.Gift),
+			"sector_index": prizeSector(order.Gift),
+			"awarded_at":   order.PrizeAwardedAt,
+			"expires_at":   h.prizeClaimDeadline(order),
+		})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":         true,
-		"can_spin":        availableSpins > 0,
-		"spins_available": availableSpins,
-		"eligible_orders": eligibleOrders,
+		"success":          true,
+		"can_spin":         spinsLeft > 0,
+		"spins_available":  availableSpins,
+		"spins_left":       spinsLeft,
+		"next_spin_at":     nextSpinAt,
+		"eligible_orders":  eligibleOrders,
+		"spun_orders":      spunOrders,
+		"unclaimed_prizes": unclaimedPrizes,
 	})
 }
 
@@ -239,33 +338,35 @@ func (h *Handler) SpinWheel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	var req SpinWheelRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
 		return
 	}
 
 	if req.TelegramID == 0 {
-		http.Error(w, "telegram_id required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "telegram_id required", nil)
 		return
 	}
 
-	// Get user's eligible orders (paid, with perfumes, but no prize yet)
-	orders, err := h.orderRepo.GetUnpaidOrdersByUser(req.TelegramID)
+	// Get user's eligible orders (with perfumes, but no prize yet)
+	orders, err := h.orderRepo.GetOrdersEligibleForPrize(r.Context(), req.TelegramID)
 	if err != nil {
 		h.logger.Error("Error getting user orders", zap.Error(err))
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
 		return
 	}
 
-	var eligibleOrder *repository.Order
+	spunOrders := h.spunOrders(r.Context(), req.TelegramID)
+
+	var eligibleOrder *domain.Order
 	for _, order := range orders {
-		if order.Parfumes != "" && (order.Gift == "" || order.Gift == "null") {
+		if order.Parfumes != "" && (order.Gift == "" || order.Gift == "null") && !slices.Contains(spunOrders, order.ID) {
 			eligibleOrder = &order
 			break
 		}
@@ -281,8 +382,41 @@ func (h *Handler) SpinWheel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The daily cap and the cooldown are checked only once there is something to spin for,
+	// so a refused spin doesn't start a cooldown. Limits that can't be read aren't applied.
+	now := time.Now()
+	spinsToday := 0
+	if h.cfg.DailySpinCap > 0 {
+		spinsToday, err = h.redisRepo.GetDailySpins(r.Context(), req.TelegramID, spinDay(now))
+		if err != nil {
+			h.logger.Warn("Failed to get daily spins", zap.Error(err), zap.Int64("telegram_id", req.TelegramID))
+		} else if spinsToday >= h.cfg.DailySpinCap {
+			writeSpinLimited(w, "daily_spin_cap", "Daily spin limit reached", 0, nextSpinDay(now))
+			return
+		}
+	}
+	if h.cfg.SpinCooldownSeconds > 0 {
+		cooldown := time.Duration(h.cfg.SpinCooldownSeconds) * time.Second
+		left, err := h.redisRepo.StartSpinCooldown(r.Context(), req.TelegramID, cooldown)
+		if err != nil {
+			h.logger.Warn("Failed to start spin cooldown", zap.Error(err), zap.Int64("telegram_id", req.TelegramID))
+		} else if left > 0 {
+			spinsLeft := 0
+			for _, order := range orders {
+				if order.Parfumes != "" && (order.Gift == "" || order.Gift == "null") && !slices.Contains(spunOrders, order.ID) {
+					spinsLeft++
+				}
+			}
+			if h.cfg.DailySpinCap > 0 {
+				spinsLeft = min(spinsLeft, h.cfg.DailySpinCap-spinsToday)
+			}
+			writeSpinLimited(w, "spin_cooldown", "Too many spins, wait a moment", spinsLeft, now.Add(left))
+			return
+		}
+	}
+
 	// Get global order sequence number for deterministic prize
-	orderSequence, err := h.orderRepo.GetOrderSequenceNumber(eligibleOrder.ID)
+	orderSequence, err := h.orderRepo.GetOrderSequenceNumber(r.Context(), eligibleOrder.ID)
 	if err != nil {
 		h.logger.Error("Error getting order sequence", zap.Error(err))
 		// Fallback to order ID if sequence lookup fails
@@ -290,23 +424,73 @@ func (h *Handler) SpinWheel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Determine prize using our algorithm
-	prizeWon := h.DeterminePrize(orderSequence)
+	rules := h.activePrizeRules(r.Context())
+	drawnPrize := h.DeterminePrize(rules, orderSequence)
 
-	// Save the prize to the order
-	err = h.orderRepo.UpdateOrderPrize(eligibleOrder.ID, prizeWon)
+	// Save the prize to the order; rings and cash come out of their limited stock
+	prizeWon, awarded, err := h.orderRepo.AwardPrize(r.Context(), eligibleOrder.ID, drawnPrize, fallbackPrize(rules, orderSequence))
 	if err != nil {
 		h.logger.Error("Error saving prize to order", zap.Error(err))
-		http.Error(w, "Error saving prize", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "prize_save_failed", "Error saving prize", nil)
 		return
 	}
 
+	if !awarded {
+		// A parallel spin for the same order got there first. It is answered with the
+		// prize that spin set, so both wheels show the same result; the spin is only
+		// counted once.
+		h.logger.Warn("Spin lost a race for its order, returning the prize already set",
+			zap.Int64("telegram_id", req.TelegramID),
+			zap.Int64("order_id", eligibleOrder.ID),
+			zap.String("prize", prizeWon))
+	} else {
+		if prizeWon != drawnPrize {
+			h.logger.Warn("Prize out of stock, awarded the fallback",
+				zap.Int64("order_id", eligibleOrder.ID),
+				zap.Int("order_sequence", orderSequence),
+				zap.String("drawn", drawnPrize),
+				zap.String("awarded", prizeWon))
+		}
+		h.recordSpin(r, eligibleOrder, orderSequence, rules.Version, drawnPrize, prizeWon, domain.SpinSourceWheel)
+		h.metrics.spins.WithLabelValues(prizeWon).Inc()
+		h.publishPrizeWon(eligibleOrder.ID, eligibleOrder.IDUser, prizeWon)
+
+		prizeEvent := map[string]interface{}{
+			"prize":          prizeWon,
+			"order_sequence": orderSequence,
+			"rules_version":  rules.Version,
+		}
+		if prizeWon != drawnPrize {
+			prizeEvent["out_of_stock"] = drawnPrize
+		}
+		h.recordOrderEvent(eligibleOrder.ID, domain.OrderEventPrizeWon, domain.OrderEventActorUser, prizeEvent)
+
+		if spins, err := h.redisRepo.IncrDailySpins(r.Context(), req.TelegramID, spinDay(now)); err != nil {
+			h.logger.Warn("Failed to count daily spin", zap.Error(err), zap.Int64("telegram_id", req.TelegramID))
+		} else {
+			spinsToday = spins
+		}
+	}
+
 	// Count remaining spins
 	remainingSpins := 0
 	for _, order := range orders {
-		if order.ID != eligibleOrder.ID && order.Parfumes != "" && (order.Gift == "" || order.Gift == "null") {
+		if order.ID != eligibleOrder.ID && order.Parfumes != "" && (order.Gift == "" || order.Gift == "null") && !slices.Contains(spunOrders, order.ID) {
 			remainingSpins++
 		}
 	}
+	var nextSpinAt *time.Time
+	if h.cfg.DailySpinCap > 0 {
+		if remainingSpins > 0 && spinsToday >= h.cfg.DailySpinCap {
+			next := nextSpinDay(now)
+			nextSpinAt = &next
+		}
+		remainingSpins = min(remainingSpins, max(h.cfg.DailySpinCap-spinsToday, 0))
+	}
+	if nextSpinAt == nil && remainingSpins > 0 && h.cfg.SpinCooldownSeconds > 0 {
+		next := now.Add(time.Duration(h.cfg.SpinCooldownSeconds) * time.Second)
+		nextSpinAt = &next
+	}
 
 	h.logger.Info("Prize wheel spin completed",
 		zap.Int64("telegram_id", req.TelegramID),
@@ -315,14 +499,22 @@ func (h *Handler) SpinWheel(w http.ResponseWriter, r *http.Request) {
 		zap.String("prize_won", prizeWon),
 		zap.Int("remaining_spins", remainingSpins))
 
+	sector := prizeSector(prizeWon)
+	tokenExpiresAt := now.Add(h.prizeTokenTTL())
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(SpinWheelResponse{
-		Success:   true,
-		CanSpin:   true,
-		PrizeWon:  prizeWon,
-		OrderID:   eligibleOrder.ID,
-		SpinsLeft: remainingSpins,
-		Message:   "Prize determined successfully",
+		Success:    true,
+		CanSpin:    true,
+		PrizeWon:   prizeWon,
+		OrderID:    eligibleOrder.ID,
+		SpinsLeft:  remainingSpins,
+		NextSpinAt: nextSpinAt,
+		Message:    "Prize determined successfully",
+
+		SectorIndex:         &sector,
+		PrizeToken:          h.signPrizeToken(eligibleOrder.ID, prizeWon, now),
+		PrizeTokenExpiresAt: &tokenExpiresAt,
 	})
 }
 
@@ -335,13 +527,13 @@ func (h *Handler) CompletePrizeOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	err := r.ParseMultipartForm(10 << 20)
 	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_form", "Error parsing form", nil)
 		return
 	}
 
@@ -352,57 +544,120 @@ func (h *Handler) CompletePrizeOrder(w http.ResponseWriter, r *http.Request) {
 	address := r.FormValue("address")
 	latitudeStr := r.FormValue("latitude")
 	longitudeStr := r.FormValue("longitude")
+	prizeToken := r.FormValue("prize_token")
 
 	if telegramIDStr == "" || orderIDStr == "" || fio == "" || contact == "" || address == "" {
-		http.Error(w, "Required fields missing", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_fields", "Required fields missing", nil)
+		return
+	}
+
+	contact, err = service.NormalizePhone(contact)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_contact", "contact must be a Kazakhstan phone number", nil)
+		return
+	}
+
+	if prizeToken == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_prize_token", "prize_token required", nil)
 		return
 	}
 
 	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid telegram_id", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
 		return
 	}
 
 	orderID, err := strconv.ParseInt(orderIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid order_id", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_order_id", "Invalid order_id", nil)
+		return
+	}
+
+	// A repeated Idempotency-Key gets the first response back without notifying admins again
+	idempotent, handled := h.beginIdempotent(w, r, idempotencyEndpointCompletePrize, telegramID)
+	if handled {
 		return
 	}
+	if idempotent != nil {
+		w = idempotent
+		defer idempotent.finish()
+		idempotent.resourceID = orderID
+	}
 
 	// Get the order to verify it belongs to the user and has a prize
-	order, err := h.orderRepo.GetByID(orderID)
+	order, err := h.orderRepo.GetByID(r.Context(), orderID)
 	if err != nil {
 		h.logger.Error("Error getting order", zap.Error(err))
-		http.Error(w, "Order not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "order_not_found", "Order not found", nil)
 		return
 	}
 
-	if order.ID_user != telegramID {
-		http.Error(w, "Order does not belong to user", http.StatusForbidden)
+	if order.IDUser != telegramID {
+		writeJSONError(w, http.StatusForbidden, "order_forbidden", "Order does not belong to user", nil)
 		return
 	}
 
 	if order.Gift == "" || order.Gift == "null" {
-		http.Error(w, "Order has no prize assigned", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "no_prize_assigned", "Order has no prize assigned", nil)
+		return
+	}
+
+	if order.PrizeExpiredAt != nil {
+		writeJSONError(w, http.StatusGone, "prize_expired", "The prize was not claimed in time", map[string]interface{}{
+			"expired_at": order.PrizeExpiredAt,
+		})
+		return
+	}
+
+	// The token must be the one issued for the prize on the order, so a client can't claim
+	// a prize it didn't win. An expired one can be renewed from /api/prize/token.
+	if err := h.verifyPrizeToken(prizeToken, orderID, order.Gift, time.Now()); err != nil {
+		h.logger.Warn("Rejected prize token",
+			zap.Error(err),
+			zap.Int64("telegram_id", telegramID),
+			zap.Int64("order_id", orderID),
+			zap.String("prize", order.Gift))
+		if errors.Is(err, errPrizeTokenExpired) {
+			writeJSONError(w, http.StatusForbidden, "prize_token_expired", "Prize token has expired", nil)
+			return
+		}
+		writeJSONError(w, http.StatusForbidden, "prize_token_invalid", "Prize token does not match the order's prize", nil)
 		return
 	}
 
-	// Update the order with client information
-	err = h.orderRepo.UpdateClientInfoWithCoordinates(orderID, fio, contact, address)
+	// The prize counts as claimed from here on and the address is saved with the claim; a
+	// resent form keeps the first claim
+	latitude, longitude := parseCoordinates(latitudeStr, longitudeStr)
+	info := domain.CheckoutInfo{FIO: fio, Contact: contact, Address: address, Latitude: latitude, Longitude: longitude}
+	claimed, err := h.orderRepo.ClaimPrizeWithAddress(r.Context(), orderID, info)
 	if err != nil {
-		h.logger.Error("Error updating order with client info", zap.Error(err))
-		http.Error(w, "Error saving client information", http.StatusInternalServerError)
+		h.logger.Error("Error claiming prize", zap.Error(err), zap.Int64("order_id", orderID))
+		writeJSONError(w, http.StatusInternalServerError, "client_save_failed", "Error saving client information", nil)
+		return
+	}
+	if !claimed {
+		// Expired between the check above and now; the address isn't kept
+		writeJSONError(w, http.StatusGone, "prize_expired", "The prize was not claimed in time", nil)
 		return
 	}
 
 	// Mark order as completed
-	err = h.orderRepo.MarkOrderAsCompleted(orderID)
+	err = h.orderRepo.MarkOrderAsCompleted(r.Context(), orderID)
 	if err != nil {
 		h.logger.Error("Error marking order as completed", zap.Error(err))
 		// Don't fail the request, just log the error
 	}
 
+	h.recordOrderEvent(orderID, domain.OrderEventPrizeCompleted, domain.OrderEventActorUser, map[string]interface{}{
+		"prize":     order.Gift,
+		"fio":       fio,
+		"contact":   contact,
+		"address":   address,
+		"latitude":  latitudeStr,
+		"longitude": longitudeStr,
+	})
+
 	// Send confirmation messages
 	go h.sendPrizeCompletionMessages(telegramID, orderID, order.UserName, order.Gift, order.Parfumes, fio, contact, address)
 
@@ -412,7 +667,9 @@ func (h *Handler) CompletePrizeOrder(w http.ResponseWriter, r *http.Request) {
 		zap.String("prize", order.Gift),
 		zap.String("fio", fio),
 		zap.String("contact", contact),
-		zap.String("address", address))
+		zap.String("address", address),
+		zap.String("latitude", latitudeStr),
+		zap.String("longitude", longitudeStr))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -422,54 +679,209 @@ func (h *Handler) CompletePrizeOrder(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Send prize completion messages to user and admin
-func (h *Handler) sendPrizeCompletionMessages(telegramID, orderID int64, userName, prize, parfumes, fio, contact, address string) {
-	if h.bot == nil {
-		h.logger.Error("Bot not initialized")
+// GetPrizeHistory lists the prizes a user has won
+// GET /api/prize/history?telegram_id=
+func (h *Handler) GetPrizeHistory(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Get prize display names
-	prizeNames := map[string]string{
-		Prize10ML:    "🧪 10мл парфюм",
-		Prize30ML:    "🧪 30мл парфюм", 
-		PrizeDiamond: "💍 Бриллиант сақина",
-		PrizeMoney:   "💰 100,000 теңге",
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
 	}
 
-	prizeDisplay := prizeNames[prize]
-	if prizeDisplay == "" {
-		prizeDisplay = prize
+	telegramIDStr := r.URL.Query().Get("telegram_id")
+	if telegramIDStr == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "telegram_id parameter required", nil)
+		return
 	}
 
-	// User confirmation message
-	userMessage := fmt.Sprintf(
-		"🎉 Құттықтаймыз! Сіз сыйлық ұттыңыз! 🎉\n\n"+
-			"🏆 Сіздің сыйлығыңыз: %s\n\n"+
-			"📦 Тапсырыс мәліметтері:\n"+
-			"🆔 Тапсырыс №: %d\n"+
-			"👤 Тапсырыс беруші: %s\n"+
-			"📱 Телефон: %s\n"+
-			"📍 Мекенжай: %s\n\n"+
-			"🌸 Таңдалған парфюмдер:\n%s\n\n"+
-			"🚚 Жеткізу туралы ақпарат:\n"+
-			"Біздің менеджер сізбен 24 сағат ішінде байланысады.\n"+
-			"Сыйлығыңыз парфюммен бірге жеткізіледі.\n\n"+
-			"Рахмет! 💝",
-		prizeDisplay, orderID, fio, contact, address, parfumes)
-
-	// Send to user
-	_, err := h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
-		ChatID: telegramID,
-		Text:   userMessage,
+	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
+		return
+	}
+
+	orders, err := h.orderRepo.GetPrizeOrdersByUser(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Error("Error getting prize orders", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	prizes := make([]map[string]interface{}, 0, len(orders))
+	for _, order := range orders {
+		prizes = append(prizes, map[string]interface{}{
+			"order_id":     order.ID,
+			"prize":        order.Gift,
+			"prize_name":   PrizeDisplayName(order.Gift),
+			"parfumes":     order.Parfumes,
+			"status":       order.Status,
+			"completed":    order.PrizeClaimedAt != nil,
+			"completed_at": order.PrizeClaimedAt,
+			"claim_status": order.PrizeClaim(),
+			"expires_at":   h.prizeClaimDeadline(order),
+			"expired_at":   order.PrizeExpiredAt,
+			"created_at":   order.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"prizes":  prizes,
+		"total":   len(prizes),
 	})
+}
+
+// reportPrizes are the prizes of the winners report, most valuable first
+var reportPrizes = []string{PrizeMoney, PrizeDiamond, Prize30ML, Prize10ML}
+
+// PrizeWinner is a completed prize order in the winners report
+type PrizeWinner struct {
+	OrderID     int64     `json:"order_id"`
+	TelegramID  int64     `json:"telegram_id"`
+	UserName    string    `json:"user_name"`
+	FIO         string    `json:"fio"`
+	Contact     string    `json:"contact"`
+	Address     string    `json:"address"`
+	Prize       string    `json:"prize"`
+	PrizeName   string    `json:"prize_name"`
+	Parfumes    string    `json:"parfumes"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// PrizeWinnerGroup is the winners of one prize
+type PrizeWinnerGroup struct {
+	Prize     string        `json:"prize"`
+	PrizeName string        `json:"prize_name"`
+	Count     int           `json:"count"`
+	Winners   []PrizeWinner `json:"winners"`
+}
 
+// Winners of completed prize orders with their contacts, grouped by prize
+// GET /api/prizes/winners?from=YYYY-MM-DD&to=YYYY-MM-DD
+func (h *Handler) handleGetPrizeWinners(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	from, to, err := parseDateRange(r)
 	if err != nil {
-		h.logger.Error("Failed to send prize completion message to user",
-			zap.Error(err),
-			zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusBadRequest, "invalid_date_range", err.Error(), nil)
+		return
+	}
+
+	orders, err := h.orderRepo.GetCompletedPrizeOrders(r.Context(), from, to)
+	if err != nil {
+		h.logger.Error("Error getting completed prize orders", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	// Every known prize is in the summary, also when nobody won it in the period
+	summary := make(map[string]int, len(reportPrizes))
+	groups := make(map[string]*PrizeWinnerGroup, len(reportPrizes))
+	order := append([]string(nil), reportPrizes...)
+	for _, prize := range reportPrizes {
+		summary[prize] = 0
+		groups[prize] = &PrizeWinnerGroup{Prize: prize, PrizeName: PrizeDisplayName(prize), Winners: []PrizeWinner{}}
+	}
+
+	for _, o := range orders {
+		group, ok := groups[o.Gift]
+		if !ok {
+			// Prizes from before the current set are still listed, after the known ones
+			group = &PrizeWinnerGroup{Prize: o.Gift, PrizeName: PrizeDisplayName(o.Gift), Winners: []PrizeWinner{}}
+			groups[o.Gift] = group
+			order = append(order, o.Gift)
+		}
+		group.Winners = append(group.Winners, PrizeWinner{
+			OrderID:     o.ID,
+			TelegramID:  o.IDUser,
+			UserName:    o.UserName,
+			FIO:         o.FIO,
+			Contact:     o.Contact,
+			Address:     o.Address,
+			Prize:       o.Gift,
+			PrizeName:   PrizeDisplayName(o.Gift),
+			Parfumes:    o.Parfumes,
+			CompletedAt: *o.PrizeClaimedAt,
+		})
+		group.Count++
+		summary[o.Gift]++
+	}
+
+	result := make([]PrizeWinnerGroup, 0, len(order))
+	for _, prize := range order {
+		result = append(result, *groups[prize])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"from":    from,
+		"to":      to,
+		"total":   len(orders),
+		"summary": summary,
+		"groups":  result,
+	})
+}
+
+// MessageDelivery is the outcome of sending one Telegram message
+type MessageDelivery struct {
+	ChatID    int64  `json:"chat_id"`
+	Delivered bool   `json:"delivered"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PrizeDelivery reports who received the prize completion messages
+type PrizeDelivery struct {
+	User   MessageDelivery   `json:"user"`
+	Admins []MessageDelivery `json:"admins"`
+}
+
+// telegramSendAttempts is how many times an order or payment notification is tried
+const telegramSendAttempts = 4
+
+// sendMessageWithRetry sends a Telegram message, retrying transient failures
+func sendMessageWithRetry(ctx context.Context, b *bot.Bot, params *bot.SendMessageParams) error {
+	return service.SendWithRetry(ctx, telegramSendAttempts, func(ctx context.Context) error {
+		_, err := b.SendMessage(ctx, params)
+		return err
+	})
+}
+
+// Send prize completion messages to user and admin
+func (h *Handler) sendPrizeCompletionMessages(telegramID, orderID int64, userName, prize, parfumes, fio, contact, address string) PrizeDelivery {
+	delivery := PrizeDelivery{User: MessageDelivery{ChatID: telegramID}}
+
+	if h.silent {
+		return delivery
 	}
 
+	prizeDisplay := PrizeDisplayName(prize)
+
+	// User confirmation message
+	lang := h.userLang(h.ctx, telegramID)
+	userMessage := i18n.T(lang, i18n.PrizeWon, prizeName(lang, prize), orderID, fio, contact, address, parfumes)
+
+	// Queued together, the user and admin messages are sent at the outbox's pace
+	userResult := h.enqueueMessage(telegramID, orderID, &bot.SendMessageParams{
+		ChatID: telegramID,
+		Text:   userMessage,
+	})
+
 	// Admin notification message
 	adminMessage := fmt.Sprintf(
 		"🎊 ЖАҢА СЫЙЛЫҚ ЖЕҢІМПАЗЫ! 🎊\n\n"+
@@ -486,95 +898,507 @@ func (h *Handler) sendPrizeCompletionMessages(telegramID, orderID int64, userNam
 
 	// Send to admins
 	admins := []int64{h.cfg.AdminID, h.cfg.AdminID2}
+	adminResults := make(map[int64]<-chan error, len(admins))
 	for _, adminID := range admins {
 		if adminID != 0 {
-			_, err := h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
+			adminResults[adminID] = h.enqueueMessage(adminID, orderID, &bot.SendMessageParams{
 				ChatID: adminID,
 				Text:   adminMessage,
 			})
-			if err != nil {
-				h.logger.Error("Failed to send admin prize notification",
-					zap.Error(err),
-					zap.Int64("admin_id", adminID))
-			}
 		}
 	}
-}
 
-func (h *Handler) StartHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	if update.Message == nil {
-		return
+	if err := <-userResult; err != nil {
+		delivery.User.Error = err.Error()
+	} else {
+		delivery.User.Delivered = true
+	}
+	for _, adminID := range admins {
+		result, ok := adminResults[adminID]
+		if !ok {
+			continue
+		}
+		adminDelivery := MessageDelivery{ChatID: adminID}
+		if err := <-result; err != nil {
+			adminDelivery.Error = err.Error()
+		} else {
+			adminDelivery.Delivered = true
+		}
+		delivery.Admins = append(delivery.Admins, adminDelivery)
 	}
 
-	promoText := "24990тгге 30мл парфюм сатып алып, 10мл, 30мллік парфюм , 89990тглік бриллант жүзік және 100 000 теңге ақшалай сыйлықтың біріне ие болыңыз."
+	return delivery
+}
 
-	inlineKbd := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{
-					Text:         "🛍 Сатып алу",
-					CallbackData: "buy_parfume",
-				},
-			},
-		},
+// handleBulkAssignPrizes pre-assigns prizes to a set of orders (campaign seeding)
+// POST /api/admin/prizes/bulk-assign, body: [{"order_id": 1, "prize": "money"}]
+// Any invalid entry rejects the whole batch unless ?partial=1 is passed. Rings and cash
+// come out of the prize inventory as on the wheel; one out of stock gives the wheel's
+// fallback, reported as out_of_stock. Every assignment is recorded in spins.
+func (h *Handler) handleBulkAssignPrizes(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
 	}
-	_, err := b.SendPhoto(ctx, &bot.SendPhotoParams{
-		ChatID:         update.Message.Chat.ID,
-		Photo:          &models.InputFileString{Data: h.cfg.StartPhotoId},
-		Caption:        promoText,
-		ReplyMarkup:    inlineKbd,
-		ProtectContent: true,
-	})
-	if err != nil {
-		h.logger.Warn("Failed to send promo photo", zap.Error(err))
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
 	}
-}
 
-func (h *Handler) DefaultHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	if update.Message == nil {
+	var assignments []domain.PrizeAssignment
+	if err := json.NewDecoder(r.Body).Decode(&assignments); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
 		return
 	}
 
-	var userId int64
-	if update.Message != nil {
-		userId = update.Message.From.ID
-	} else if update.CallbackQuery != nil {
-		userId = update.CallbackQuery.From.ID
+	if len(assignments) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "missing_assignments", "At least one assignment is required", nil)
+		return
 	}
 
-	ok, errE := h.clientRepo.ExistsJust(ctx, userId)
-	if errE != nil {
-		h.logger.Error("Failed to check user", zap.Error(errE))
-	} else if !ok {
-		timeNow := time.Now().Format("2006-01-02 15:04:05")
-		h.logger.Info("New user", zap.String("user_id", strconv.FormatInt(userId, 10)), zap.String("date", timeNow))
-		if errN := h.clientRepo.InsertJust(ctx, domain.JustEntry{
-			UserId:         userId,
-			UserName:       update.Message.From.Username,
-			DateRegistered: timeNow,
-		}); errN != nil {
-			h.logger.Error("Failed to insert user", zap.Error(errN))
-		}
+	partialValue := r.URL.Query().Get("partial")
+	partial := partialValue == "1" || partialValue == "true"
+
+	var valid []domain.PrizeAssignment
+	var rejected []map[string]interface{}
+	seen := make(map[int64]bool, len(assignments))
+	orders := make(map[int64]*domain.Order, len(assignments))
+	sequences := make(map[int64]int, len(assignments))
+	rules := h.activePrizeRules(r.Context())
+
+	reject := func(assignment domain.PrizeAssignment, reason string) {
+		rejected = append(rejected, map[string]interface{}{
+			"order_id": assignment.OrderID,
+			"prize":    assignment.Prize,
+			"error":    reason,
+		})
 	}
 
-	if userId == h.cfg.AdminID {
-		var fileId string
+	for _, assignment := range assignments {
 		switch {
-		case len(update.Message.Photo) > 0:
-			fileId = update.Message.Photo[len(update.Message.Photo)-1].FileID
-		case update.Message.Video != nil:
-			fileId = update.Message.Video.FileID
+		case assignment.OrderID <= 0:
+			reject(assignment, "invalid order_id")
+			continue
+		case seen[assignment.OrderID]:
+			reject(assignment, "duplicate order_id")
+			continue
+		case !isValidPrize(assignment.Prize):
+			reject(assignment, "unknown prize")
+			continue
 		}
-		if fileId != "" {
-			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-				ChatID: h.cfg.AdminID,
-				Text:   fileId,
-			})
-			if err != nil {
-				h.logger.Error("error send fileId to admin", zap.Error(err))
+		seen[assignment.OrderID] = true
+
+		order, err := h.orderRepo.GetByID(r.Context(), assignment.OrderID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				reject(assignment, "order not found")
+				continue
 			}
+			h.logger.Error("Error getting order for prize assignment", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+			return
 		}
-	}
+
+		// A ring or cash out of stock gives way to what the wheel would give instead
+		orderSequence, err := h.orderRepo.GetOrderSequenceNumber(r.Context(), order.ID)
+		if err != nil {
+			h.logger.Error("Error getting order sequence", zap.Error(err))
+			orderSequence = int(order.ID)
+		}
+		assignment.Fallback = fallbackPrize(rules, orderSequence)
+		orders[order.ID] = order
+		sequences[order.ID] = orderSequence
+
+		valid = append(valid, assignment)
+	}
+
+	if len(rejected) > 0 && !partial {
+		writeJSONError(w, http.StatusUnprocessableEntity, "invalid_assignments",
+			"Some assignments are invalid; nothing was assigned",
+			map[string]interface{}{
+				"rejected": rejected,
+			})
+		return
+	}
+
+	assigned := []map[string]interface{}{}
+	if len(valid) > 0 {
+		results, err := h.orderRepo.AssignPrizes(r.Context(), valid)
+		if err != nil {
+			h.logger.Error("Error assigning prizes", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "prize_save_failed", "Error assigning prizes", nil)
+			return
+		}
+
+		for i, result := range results {
+			requested := valid[i].Prize
+			h.logger.Info("Prize assigned by admin",
+				zap.String("request_id", RequestIDFromContext(r.Context())),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.Int64("order_id", result.OrderID),
+				zap.String("prize", result.Prize),
+				zap.String("requested_prize", requested),
+				zap.String("previous_prize", result.PreviousPrize))
+
+			h.recordSpin(r, orders[result.OrderID], sequences[result.OrderID], rules.Version, requested, result.Prize, domain.SpinSourceAdmin)
+
+			event := map[string]interface{}{
+				"prize":          result.Prize,
+				"previous_prize": result.PreviousPrize,
+			}
+			item := map[string]interface{}{
+				"order_id":       result.OrderID,
+				"prize":          result.Prize,
+				"previous_prize": result.PreviousPrize,
+			}
+			if result.Prize != requested {
+				event["out_of_stock"] = requested
+				item["out_of_stock"] = requested
+			}
+			h.recordOrderEvent(result.OrderID, domain.OrderEventPrizeAssigned, domain.OrderEventActorAdmin, event)
+			assigned = append(assigned, item)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"partial":  partial,
+		"assigned": assigned,
+		"rejected": rejected,
+	})
+}
+
+// handleAdminOrderRoutes dispatches /api/admin/order/{id}/{action}
+func (h *Handler) handleAdminOrderRoutes(w http.ResponseWriter, r *http.Request) {
+	_, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/order/"), "/")
+	switch action {
+	case "status":
+		h.handleUpdateOrderStatus(w, r)
+	case "events":
+		h.handleGetOrderEvents(w, r)
+	default:
+		h.handleResendPrize(w, r)
+	}
+}
+
+// Move an order through its lifecycle; checks follows the status
+// PATCH /api/admin/order/{id}/status {"status": "packed", "note": "..."}
+// PATCH /api/order/{id}/status (same handler)
+func (h *Handler) handleUpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "PATCH" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	idStr := strings.TrimSuffix(r.URL.Path, "/status")
+	idStr = idStr[strings.LastIndex(idStr, "/")+1:]
+	orderID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_order_id", "Invalid order ID", nil)
+		return
+	}
+
+	var req struct {
+		Status domain.OrderStatus `json:"status"`
+		Note   string             `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
+		return
+	}
+
+	if !req.Status.IsValid() {
+		writeJSONError(w, http.StatusBadRequest, "invalid_status", "Invalid order status", map[string]interface{}{
+			"status":  req.Status,
+			"allowed": domain.OrderStatuses,
+		})
+		return
+	}
+
+	event, err := h.orderRepo.UpdateStatus(r.Context(), orderID, req.Status, domain.OrderEventActorAdmin, strings.TrimSpace(req.Note))
+	if err != nil {
+		var transitionErr *repository.StatusTransitionError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeJSONError(w, http.StatusNotFound, "order_not_found", "Order not found", nil)
+		case errors.As(err, &transitionErr):
+			writeJSONError(w, http.StatusConflict, "invalid_status_transition", transitionErr.Error(), map[string]interface{}{
+				"from":    transitionErr.From,
+				"to":      transitionErr.To,
+				"allowed": transitionErr.From.NextStatuses(),
+			})
+		default:
+			h.logger.Error("Error updating order status", zap.Error(err), zap.Int64("order_id", orderID))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		}
+		return
+	}
+
+	order, err := h.orderRepo.GetByID(r.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Error getting order", zap.Error(err), zap.Int64("order_id", orderID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	events, err := h.orderRepo.GetEvents(r.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Error getting order events", zap.Error(err), zap.Int64("order_id", orderID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	h.logger.Info("Order status updated",
+		zap.Int64("order_id", orderID),
+		zap.String("from", string(event.FromStatus)),
+		zap.String("to", string(event.ToStatus)))
+
+	go h.notifyOrderStatus(order, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"order":   order,
+		"event":   event,
+		"events":  events,
+	})
+}
+
+// Timeline of an order for support and disputes
+// GET /api/admin/order/{id}/events
+func (h *Handler) handleGetOrderEvents(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	idStr, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/order/"), "/")
+	orderID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_order_id", "Invalid order ID", nil)
+		return
+	}
+
+	if _, err := h.orderRepo.GetByID(r.Context(), orderID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "order_not_found", "Order not found", nil)
+			return
+		}
+		h.logger.Error("Error getting order", zap.Error(err), zap.Int64("order_id", orderID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	events, err := h.orderRepo.GetEvents(r.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Error getting order events", zap.Error(err), zap.Int64("order_id", orderID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	if events == nil {
+		events = []domain.OrderEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"order_id": orderID,
+		"events":   events,
+	})
+}
+
+// recordOrderEvent adds an event to the order's timeline. The timeline only serves
+// support, so a failed write is logged and never fails the flow that produced it.
+func (h *Handler) recordOrderEvent(orderID int64, eventType domain.OrderEventType, actor string, payload interface{}) {
+	if err := h.orderRepo.RecordEvent(h.ctx, orderID, eventType, actor, payload); err != nil {
+		h.logger.Error("Failed to record order event",
+			zap.Error(err),
+			zap.Int64("order_id", orderID),
+			zap.String("event_type", string(eventType)))
+	}
+}
+
+// handleResendPrize re-sends prize completion messages for a finished prize order
+// POST /api/admin/order/{id}/resend-prize
+func (h *Handler) handleResendPrize(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/admin/order/")
+	idStr, action, found := strings.Cut(path, "/")
+	if !found || action != "resend-prize" {
+		writeJSONError(w, http.StatusNotFound, "not_found", "Unknown admin order action", nil)
+		return
+	}
+
+	if idStr == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_order_id", "Order ID required", nil)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_order_id", "Invalid order ID", nil)
+		return
+	}
+
+	order, err := h.orderRepo.GetByID(r.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "order_not_found", "Order not found", nil)
+		} else {
+			h.logger.Error("Error getting order", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		}
+		return
+	}
+
+	if order.Gift == "" || order.Gift == "null" {
+		writeJSONError(w, http.StatusBadRequest, "no_prize_assigned", "Order has no prize assigned", nil)
+		return
+	}
+
+	if order.FIO == "" || order.Contact == "" || order.Address == "" {
+		writeJSONError(w, http.StatusConflict, "prize_not_completed", "Prize order has no delivery details yet", nil)
+		return
+	}
+
+	if h.bot == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "bot_unavailable", "Telegram bot is not initialized", nil)
+		return
+	}
+
+	delivery := h.sendPrizeCompletionMessages(order.IDUser, order.ID, order.UserName, order.Gift, order.Parfumes, order.FIO, order.Contact, order.Address)
+
+	h.logger.Info("Prize completion messages resent",
+		zap.Int64("order_id", order.ID),
+		zap.Int64("telegram_id", order.IDUser),
+		zap.String("prize", order.Gift),
+		zap.Bool("user_delivered", delivery.User.Delivered))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  delivery.User.Delivered,
+		"order_id": order.ID,
+		"prize":    order.Gift,
+		"delivery": delivery,
+	})
+}
+
+// StartHandler shows the start promo, after asking the user for their language if they
+// haven't chosen one yet
+func (h *Handler) StartHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+
+	lang := h.chosenLang(ctx, update.Message.From.ID)
+	if lang == "" {
+		h.askLanguage(ctx, b, update.Message.Chat.ID)
+		return
+	}
+	h.sendPromo(ctx, b, update.Message.Chat.ID, i18n.Normalize(lang))
+}
+
+// sendPromo sends the start promo with the buy button
+func (h *Handler) sendPromo(ctx context.Context, b *bot.Bot, chatID int64, lang string) {
+	promoText := h.promoCaption(ctx, lang)
+
+	inlineKbd := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{
+					Text:         i18n.T(lang, i18n.BuyButton),
+					CallbackData: "buy_parfume",
+				},
+			},
+		},
+	}
+	_, err := b.SendPhoto(ctx, &bot.SendPhotoParams{
+		ChatID:         chatID,
+		Photo:          &models.InputFileString{Data: h.promoPhoto(ctx)},
+		Caption:        promoText,
+		ReplyMarkup:    inlineKbd,
+		ProtectContent: true,
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send promo photo", zap.Error(err))
+	}
+}
+
+func (h *Handler) DefaultHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+
+	var userId int64
+	if update.Message != nil {
+		userId = update.Message.From.ID
+	} else if update.CallbackQuery != nil {
+		userId = update.CallbackQuery.From.ID
+	}
+
+	ok, errE := h.clientRepo.ExistsJust(ctx, userId)
+	if errE != nil {
+		h.logger.Error("Failed to check user", zap.Error(errE))
+	} else if !ok {
+		timeNow := time.Now().Format("2006-01-02 15:04:05")
+		h.logger.Info("New user", zap.String("user_id", strconv.FormatInt(userId, 10)), zap.String("date", timeNow))
+		if errN := h.clientRepo.InsertJust(ctx, domain.JustEntry{
+			UserId:         userId,
+			UserName:       update.Message.From.Username,
+			DateRegistered: timeNow,
+		}); errN != nil {
+			h.logger.Error("Failed to insert user", zap.Error(errN))
+		}
+	}
+
+	if h.captureBroadcastContent(ctx, b, update.Message) {
+		return
+	}
+
+	if userId == h.cfg.AdminID {
+		var fileId string
+		switch {
+		case len(update.Message.Photo) > 0:
+			fileId = update.Message.Photo[len(update.Message.Photo)-1].FileID
+		case update.Message.Video != nil:
+			fileId = update.Message.Video.FileID
+		}
+		if fileId != "" {
+			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: h.cfg.AdminID,
+				Text:   fileId,
+			})
+			if err != nil {
+				h.logger.Error("error send fileId to admin", zap.Error(err))
+			}
+		}
+	}
 
 	userState := h.getOrCreateUserState(ctx, userId)
 	if update.Message.Document != nil {
@@ -586,7 +1410,7 @@ func (h *Handler) DefaultHandler(ctx context.Context, b *bot.Bot, update *models
 	}
 
 	fmt.Println("UserState: ", userState.State)
-	
+
 	if update.CallbackQuery != nil {
 		switch userState.State {
 		case StateStart:
@@ -625,14 +1449,14 @@ func (h *Handler) DefaultHandler(ctx context.Context, b *bot.Bot, update *models
 		return
 	default:
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-		    ChatID: update.Message.Chat.ID,
-		    Text:   "Welcome to Parfum Bot!",
-	    })
-	    if err != nil {
-		    h.logger.Error("failed to send message", zap.Error(err))
-	    }
+			ChatID: update.Message.Chat.ID,
+			Text:   "Welcome to Parfum Bot!",
+		})
+		if err != nil {
+			h.logger.Error("failed to send message", zap.Error(err))
+		}
 	}
-	
+
 }
 
 func (h *Handler) BuyParfumeHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
@@ -641,10 +1465,12 @@ func (h *Handler) BuyParfumeHandler(ctx context.Context, b *bot.Bot, update *mod
 	}
 
 	userId := update.CallbackQuery.From.ID
+	lang := h.userLang(ctx, userId)
 	newState := &domain.UserState{
 		State:  StateCount,
 		Count:  0,
 		IsPaid: false,
+		Lang:   lang,
 	}
 	if err := h.redisRepo.SaveUserState(ctx, userId, newState); err != nil {
 		h.logger.Error("Failed to save user state to Redis", zap.Error(err))
@@ -675,7 +1501,7 @@ func (h *Handler) BuyParfumeHandler(ctx context.Context, b *bot.Bot, update *mod
 
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      userId,
-		Text:        "🧪 Парфюм санын таңдаңыз",
+		Text:        i18n.T(lang, i18n.ChooseCount),
 		ReplyMarkup: btn,
 	})
 	if err != nil {
@@ -683,7 +1509,14 @@ func (h *Handler) BuyParfumeHandler(ctx context.Context, b *bot.Bot, update *mod
 	}
 }
 
+// CountHandler takes the number of kits from a count button or, for counts beyond the
+// buttons, from a number the user typed
 func (h *Handler) CountHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message != nil && update.Message.Text != "" {
+		h.countFromText(ctx, b, update.Message)
+		return
+	}
+
 	if update.CallbackQuery == nil || !strings.HasPrefix(update.CallbackQuery.Data, "count_") {
 		return
 	}
@@ -706,29 +1539,75 @@ func (h *Handler) CountHandler(ctx context.Context, b *bot.Bot, update *models.U
 		return
 	}
 
-	totalSum := h.cfg.Cost * userCount
+	h.quoteCount(ctx, b, update.CallbackQuery.From.ID, userCount)
+}
 
-	userId := update.CallbackQuery.From.ID
-	newState := &domain.UserState{
-		State:  StatePay,
-		Count:  userCount,
-		IsPaid: false,
+// countFromText reads a count typed by a user choosing how many kits to buy
+func (h *Handler) countFromText(ctx context.Context, b *bot.Bot, msg *models.Message) {
+	userId := msg.From.ID
+	userCount, err := strconv.Atoi(strings.TrimSpace(msg.Text))
+	if err != nil || userCount < 1 || userCount > h.cfg.MaxOrderCount {
+		lang := h.userLang(ctx, userId)
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      msg.Chat.ID,
+			Text:        i18n.T(lang, i18n.CountInvalid, h.cfg.MaxOrderCount),
+			ReplyMarkup: cancelKeyboard(lang),
+		})
+		return
+	}
+
+	h.quoteCount(ctx, b, userId, userCount)
+}
+
+// quoteCount moves the user on to paying for userCount kits and sends them the sum and
+// payment link
+func (h *Handler) quoteCount(ctx context.Context, b *bot.Bot, userId int64, userCount int) {
+	totalSum, err := service.OrderPrice(h.cfg.Prices, h.countLines(userCount))
+	if err != nil {
+		h.logger.Error("Failed to price count", zap.Error(err))
+		return
+	}
+
+	lang := h.userLang(ctx, userId)
+	newState := &domain.UserState{
+		State:  StatePay,
+		Count:  userCount,
+		IsPaid: false,
+		Amount: totalSum,
+		Lang:   lang,
 	}
 	if err := h.redisRepo.SaveUserState(ctx, userId, newState); err != nil {
 		h.logger.Warn("Failed to save user state in count handler", zap.Error(err))
 	}
+	// Kept in the database too, so a receipt sent after the state expired is still checked
+	if err := h.clientRepo.SavePendingPayment(ctx, domain.PendingPayment{
+		UserID:   userId,
+		Quantity: userCount,
+		Amount:   totalSum,
+	}); err != nil {
+		h.logger.Warn("Failed to save pending payment", zap.Error(err), zap.Int64("user_id", userId))
+	}
+
+	// A receipt uploaded earlier with a mismatching amount completes the payment once the count matches
+	receipt, err := h.redisRepo.GetPendingReceipt(ctx, userId)
+	if err != nil {
+		h.logger.Warn("Failed to get pending receipt from Redis", zap.Error(err))
+	} else if receipt != nil && receipt.ActualPrice == totalSum {
+		h.finalizeReceipt(ctx, b, userId, userId, newState, receipt)
+		return
+	}
 
 	inlineKbd := &models.InlineKeyboardMarkup{
 		InlineKeyboard: [][]models.InlineKeyboardButton{
 			{
 				{
-					Text: "💳 Төлем жасау",
-					URL:  "https://pay.kaspi.kz/pay/xopyuql9",
+					Text: i18n.T(lang, i18n.PayButton),
+					URL:  h.cfg.PaymentLink(totalSum),
 				},
 			},
 		},
 	}
-	msgTxt := fmt.Sprintf("✅ Тамаша! Енді төмендегі сілтемеге өтіп %d теңге төлем жасап, төлемді растайтын чекті PDF форматында ботқа кері жіберіңіз.", totalSum)
+	msgTxt := i18n.T(lang, i18n.PayInstructions, totalSum)
 	_, sendErr := b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      userId,
 		Text:        msgTxt,
@@ -740,22 +1619,38 @@ func (h *Handler) CountHandler(ctx context.Context, b *bot.Bot, update *models.U
 }
 
 func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	if update.Message == nil || update.Message.Document == nil {
+	if update.Message == nil {
+		return
+	}
+
+	fileID, mimeType, found := receiptFile(update.Message)
+	if !found {
+		// Text while a receipt is awaited: remind what's expected and how to start over
+		if update.Message.Text != "" {
+			lang := h.userLang(ctx, update.Message.From.ID)
+			_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID:      update.Message.Chat.ID,
+				Text:        i18n.T(lang, i18n.SendReceiptHint),
+				ReplyMarkup: cancelKeyboard(lang),
+			})
+		}
 		return
 	}
 
-	doc := update.Message.Document
-	if !strings.EqualFold(filepath.Ext(doc.FileName), ".pdf") {
+	userId := update.Message.From.ID
+	lang := h.userLang(ctx, userId)
+	// Images the config doesn't have read automatically still go to an admin
+	manualOnly := !h.acceptsReceiptFormat(mimeType) && slices.Contains(manualReceiptFormats, mimeType)
+	if (!h.acceptsReceiptFormat(mimeType) && !manualOnly) || service.ReceiptExtension(mimeType) == "" {
 		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.From.ID,
-			Text:   "❌ Қате! Тек қана PDF 📄 форматындағы файлдарды қабылдаймыз.",
+			ChatID: userId,
+			Text:   i18n.T(lang, i18n.ReceiptWrongFormat, h.receiptFormatsLabel()),
 		})
 		return
 	}
 
-	userId := update.Message.From.ID
 	fileInfo, err := b.GetFile(ctx, &bot.GetFileParams{
-		FileID: doc.FileID,
+		FileID: fileID,
 	})
 	if err != nil {
 		h.logger.Error("Failed to get file info", zap.Error(err))
@@ -776,7 +1671,7 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 	}
 
 	timestamp := time.Now().Format("20060102_150405")
-	fileName := fmt.Sprintf("%d_%s.pdf", userId, timestamp)
+	fileName := fmt.Sprintf("%d_%s%s", userId, timestamp, service.ReceiptExtension(mimeType))
 	savePath := filepath.Join(saveDir, fileName)
 
 	outFile, err := os.Create(savePath)
@@ -787,63 +1682,66 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 	defer outFile.Close()
 
 	if _, err := io.Copy(outFile, resp.Body); err != nil {
-		h.logger.Error("Failed to save PDF file", zap.Error(err))
+		h.logger.Error("Failed to save receipt file", zap.Error(err))
 		return
 	}
-	h.logger.Info("PDF file saved", zap.String("path", savePath))
+	h.logger.Info("Receipt file saved", zap.String("path", savePath), zap.String("mime_type", mimeType))
 
-	result, err := service.ReadPDF(savePath)
-	if err != nil {
-		h.logger.Warn("Failed to read PDF file", zap.Error(err))
-	}
-	if len(result) < 4 {
-		b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   "❌ Дұрыс емес форматтағы чек! 📄 Қайталап көріңіз.",
-		})
+	if manualOnly {
+		state, err := h.redisRepo.GetUserState(ctx, userId)
+		if err != nil {
+			h.logger.Error("Failed to get user state from Redis", zap.Error(err))
+		}
+		state = h.withPendingPayment(ctx, userId, state)
+		h.requestManualReview(ctx, b, userId, lang, state, &domain.PendingReceipt{
+			FilePath: savePath,
+			FileName: fileName,
+		}, "фото чек")
 		return
 	}
 
-	h.logger.Info("PDF file read", zap.Any("result", result))
-
-	ok, err := h.clientRepo.IsUniqueQr(ctx, result[3])
+	parseStart := time.Now()
+	result, err := service.ReadReceipt(savePath, mimeType)
+	h.metrics.receiptParse.Observe(time.Since(parseStart).Seconds())
 	if err != nil {
-		h.logger.Error("error in check unique", zap.Error(err))
-		return
-	}
-	if ok {
-		b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   "⚠️ Бұл чек бұрын төленіп қойылған! 💳 ✅",
-		})
-		return
+		h.logger.Warn("Failed to read receipt file", zap.Error(err), zap.String("mime_type", mimeType))
 	}
+	h.logger.Info("Receipt file read", zap.Any("result", result))
 
-	var pdfPrice, qrPdf string
-	pdfPrice = result[2]
-	qrPdf = result[3]
-	bin, _ := service.ParsePrice(result[4])
-	if result[0] == "Платеж успешно совершен" {
-		pdfPrice = result[1]
-		qrPdf = result[2]
-		bin, _ = service.ParsePrice(result[3])
-	}
-
-	actualPrice, err := service.ParsePrice(pdfPrice)
+	fields, err := service.ParseReceiptLines(result)
 	if err != nil {
-		h.logger.Error("Failed to parse price from PDF file", zap.Error(err))
-		b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: userId,
-			Text:   "❌ Дұрыс емес PDF файл! 📄 Қайталап көріңіз.",
-		})
+		// Scans, other banks' receipts and unreadable fields are checked by an admin by hand
+		h.logger.Warn("Failed to parse receipt", zap.Error(err), zap.Int64("user_id", userId))
+		reason := "чек оқылмады"
+		var parseErr *service.ReceiptParseError
+		if errors.As(err, &parseErr) {
+			switch parseErr.Field {
+			case service.ReceiptFieldAmount:
+				reason = fmt.Sprintf("сумма оқылмады: %q", parseErr.Value)
+			case service.ReceiptFieldBin:
+				reason = fmt.Sprintf("БСН оқылмады: %q", parseErr.Value)
+			}
+		}
+
+		state, err := h.redisRepo.GetUserState(ctx, userId)
+		if err != nil {
+			h.logger.Error("Failed to get user state from Redis", zap.Error(err))
+		}
+		state = h.withPendingPayment(ctx, userId, state)
+		h.requestManualReview(ctx, b, userId, lang, state, &domain.PendingReceipt{
+			FilePath: savePath,
+			FileName: fileName,
+		}, reason)
 		return
 	}
+	actualPrice, qrPdf, bin := fields.Price, fields.QR, fields.Bin
 
 	state, err := h.redisRepo.GetUserState(ctx, userId)
 	if err != nil {
 		h.logger.Error("Failed to get user state from Redis", zap.Error(err))
 		return
 	}
+	state = h.withPendingPayment(ctx, userId, state)
 
 	rows := make([][]models.InlineKeyboardButton, 6)
 	for i := 0; i < 6; i++ {
@@ -868,10 +1766,29 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 			break
 		}
 	}
-	totalPrice := state.Count * h.cfg.Cost
-	predictedCount := actualPrice / h.cfg.Cost
-	textPrice := fmt.Sprintf("⚠️ Дұрыс емес сумма! 💰\n\n🔄 Көрсетілген сумаға сәйкес төлеңіз!\n📦 Немесе жиынтық суммасына сәйкес жиынтық санын түймелер таңдаңыз.\n\nСіздң жиынтық саны: %d", predictedCount)
+
+	receipt := &domain.PendingReceipt{
+		FilePath:    savePath,
+		FileName:    fileName,
+		ActualPrice: actualPrice,
+		Qr:          qrPdf,
+		Bin:         bin,
+	}
+
+	totalPrice, err := h.expectedAmount(state)
+	if err != nil {
+		h.logger.Error("Failed to price count", zap.Error(err))
+		return
+	}
+	predictedCount := actualPrice / h.cfg.UnitPrice()
+	textPrice := i18n.T(lang, i18n.ReceiptAmountMismatch, predictedCount)
 	if totalPrice != actualPrice {
+		// Remember the receipt so picking the matching count finishes the payment without re-upload
+		ttl := time.Duration(h.cfg.PendingReceiptTTLMinutes) * time.Minute
+		if err := h.redisRepo.SavePendingReceipt(ctx, userId, receipt, ttl); err != nil {
+			h.logger.Error("Failed to save pending receipt to Redis", zap.Error(err))
+		}
+
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      userId,
 			Text:        textPrice,
@@ -880,32 +1797,135 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 		return
 	}
 
-	totalLoto := state.Count * 3
+	h.finalizeReceipt(ctx, b, update.Message.Chat.ID, userId, state, receipt)
+}
+
+// withPendingPayment fills in the count and sum quoted to the user from the database when
+// their state expired or was lost, so a late receipt is checked against what they were told
+func (h *Handler) withPendingPayment(ctx context.Context, userId int64, state *domain.UserState) *domain.UserState {
+	if state == nil {
+		state = &domain.UserState{}
+	}
+	if state.Count > 0 && state.Amount > 0 {
+		return state
+	}
+
+	quote, err := h.clientRepo.GetPendingPayment(ctx, userId)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.logger.Error("Failed to get pending payment", zap.Error(err), zap.Int64("user_id", userId))
+		}
+		return state
+	}
+	if state.Count > 0 && state.Count != quote.Quantity {
+		return state
+	}
+
+	state.Count = quote.Quantity
+	state.Amount = quote.Amount
+	if state.State == "" {
+		state.State = StatePay
+	}
+	return state
+}
+
+// expectedAmount is the sum a receipt for state must show: the quoted one, or the price of
+// the count for states saved before quotes were kept
+func (h *Handler) expectedAmount(state *domain.UserState) (int, error) {
+	if state.Amount > 0 {
+		return state.Amount, nil
+	}
+	return service.OrderPrice(h.cfg.Prices, h.countLines(state.Count))
+}
+
+// countLines is what the bot's count buttons sell: count kits of the default volume
+func (h *Handler) countLines(count int) []domain.PriceLine {
+	return []domain.PriceLine{{Volume: h.cfg.DefaultVolume, Quantity: count}}
+}
+
+// receiptFile picks the receipt out of a message: a document keeps its MIME type
+// (guessed from the extension when Telegram omits it), a compressed photo is JPEG
+func receiptFile(msg *models.Message) (fileID, mimeType string, ok bool) {
+	switch {
+	case msg.Document != nil:
+		mimeType = strings.ToLower(msg.Document.MimeType)
+		if mimeType == "" || mimeType == "application/octet-stream" {
+			mimeType, _, _ = strings.Cut(mime.TypeByExtension(strings.ToLower(filepath.Ext(msg.Document.FileName))), ";")
+		}
+		return msg.Document.FileID, mimeType, true
+	case len(msg.Photo) > 0:
+		// The last size is the largest one
+		return msg.Photo[len(msg.Photo)-1].FileID, service.ReceiptMimeJPEG, true
+	}
+	return "", "", false
+}
+
+// manualReceiptFormats are image receipts accepted even when the config doesn't have them
+// read automatically; an admin checks those by hand
+var manualReceiptFormats = []string{service.ReceiptMimeJPEG, service.ReceiptMimePNG, service.ReceiptMimeWebP}
+
+// acceptsReceiptFormat reports whether receipts of this MIME type are read automatically,
+// as enabled in config
+func (h *Handler) acceptsReceiptFormat(mimeType string) bool {
+	return slices.Contains(h.cfg.ReceiptFormats, mimeType)
+}
+
+// receiptFormatsLabel lists the accepted receipt formats for the user, e.g. "PDF 📄, JPG"
+func (h *Handler) receiptFormatsLabel() string {
+	formats := slices.Clone(h.cfg.ReceiptFormats)
+	for _, format := range manualReceiptFormats {
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+
+	labels := make([]string, 0, len(formats))
+	for _, format := range formats {
+		switch format {
+		case service.ReceiptMimePDF:
+			labels = append(labels, "PDF 📄")
+		default:
+			ext := strings.TrimPrefix(service.ReceiptExtension(format), ".")
+			if ext == "" {
+				continue
+			}
+			labels = append(labels, strings.ToUpper(ext))
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// finalizeReceipt validates a parsed receipt against the chosen count and, on success,
+// records the payment with its loto tickets and order, notifies admins and asks for the contact.
+func (h *Handler) finalizeReceipt(ctx context.Context, b *bot.Bot, chatID, userId int64, state *domain.UserState, receipt *domain.PendingReceipt) {
 	pdfResult := domain.PdfResult{
 		Total:       state.Count,
-		ActualPrice: actualPrice,
-		Qr:          qrPdf,
-		Bin:         bin,
+		Lines:       h.countLines(state.Count),
+		ActualPrice: receipt.ActualPrice,
+		Expected:    state.Amount,
+		Qr:          receipt.Qr,
+		Bin:         receipt.Bin,
 	}
 
-	if err := service.Validator(h.cfg, pdfResult); err != nil {
-		h.logger.Error("error in save newState to redis", zap.Error(err))
+	lang := h.userLang(ctx, userId)
+	if err := service.ValidatorWithDetails(h.cfg, pdfResult); err != nil {
+		h.logger.Warn("Receipt failed validation", zap.Error(err), zap.Int64("user_id", userId))
+		h.notifyReceiptRejected(userId, state.Count, err)
+		h.recordReceiptFailure(ctx, userId, receipt.FilePath)
 
 		var errorMessage string
 		if errors.Is(err, service.ErrWrongBin) {
-			// Specific message for wrong BIN in Kazakh with emojis
-			errorMessage = "❌ Қате банк картасы! 💳\n\n" +
-				"🏦 Тек біздің серіктес банк картасымен төлем жасауға болады.\n" +
-				"📋 Дұрыс банк картасын пайдаланып қайталап көріңіз!"
+			// Specific message for wrong BIN
+			errorMessage = i18n.T(lang, i18n.ReceiptWrongBin)
+			h.metrics.payments.WithLabelValues(paymentResultWrongBin).Inc()
 		} else if errors.Is(err, service.ErrWrongPrice) {
 			// Message for wrong price
-			errorMessage = "❌ Дұрыс емес сумма! 💰\n\n" +
-				"🔍 Төлем сомасы сәйкес келмейді.\n" +
-				"📄 Чекті қайталап тексеріп көріңіз!"
+			errorMessage = i18n.T(lang, i18n.ReceiptWrongPrice)
+			h.metrics.payments.WithLabelValues(paymentResultWrongPrice).Inc()
 		} else {
 			// Generic error message
-			errorMessage = "❌ Дұрыс емес PDF файл! 📄\n\n" +
-				"🔄 Қайталап көріңіз немесе жаңа чек жүктеңіз."
+			errorMessage = i18n.T(lang, i18n.ReceiptInvalid)
+			h.metrics.payments.WithLabelValues(paymentResultInvalid).Inc()
 		}
 		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: userId,
@@ -914,37 +1934,21 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 		return
 	}
 
-	if state != nil {
-		state.IsPaid = true
-		state.State = StateContact
-		if err := h.redisRepo.SaveUserState(ctx, userId, state); err != nil {
-			h.logger.Error("Failed to save user state to Redis", zap.Error(err))
-		}
-	}
-
-	// Just incrFease the total sum
-	if err := h.clientRepo.IncreaseTotalSum(ctx, actualPrice); err != nil {
-		h.logger.Error("Failed to increase total sum", zap.Error(err))
+	if receipt.Qr == "" {
+		h.metrics.payments.WithLabelValues(paymentResultInvalid).Inc()
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: userId,
+			Text:   i18n.T(lang, i18n.ReceiptInvalid),
+		})
+		return
 	}
 
-	tickets := make([]int, 0, totalLoto)
-	for i := 0; i < totalLoto; i++ {
-		lotoId := rand.Intn(90000000) + 10000000
-		if err := h.clientRepo.InsertLoto(ctx, domain.LotoEntry{
-			UserID:  userId,
-			LotoID:  lotoId,
-			QR:      qrPdf,
-			Receipt: savePath,
-			DatePay: time.Now().Format("2006-01-02 15:04:05"),
-			Checks:  false,
-		}); err != nil {
-			h.logger.Error("error in insert loto", zap.Error(err))
-			return
-		}
-		tickets = append(tickets, lotoId)
+	if !h.acceptPayment(ctx, b, chatID, userId, lang, state, receipt) {
+		return
 	}
+	h.metrics.payments.WithLabelValues(paymentResultValid).Inc()
 
-	f, errFile := os.Open(savePath)
+	f, errFile := os.Open(receipt.FilePath)
 	if errFile != nil {
 		h.logger.Error("Failed to open file on disk", zap.Error(errFile))
 	}
@@ -958,48 +1962,116 @@ func (h *Handler) PaidHandler(ctx context.Context, b *bot.Bot, update *models.Up
 			"📄 Чек файлы жоғарыда 👆",
 		userId,
 		state.Count,
-		actualPrice,
+		receipt.ActualPrice,
 		time.Now().Format("2006-01-02 15:04:05"))
 	admins := []int64{h.cfg.AdminID, h.cfg.AdminID2}
 	for i := 0; i < len(admins); i++ {
 		admin := admins[i]
-		if _, err := f.Seek(0, io.SeekStart); err != nil {
-			h.logger.Error("Failed to seek file to start", zap.Error(err))
-		}
+		errSendToAdmin := service.SendWithRetry(ctx, telegramSendAttempts, func(ctx context.Context) error {
+			// Every attempt uploads the receipt from the start
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				h.logger.Error("Failed to seek file to start", zap.Error(err))
+			}
 
-		_, errSendToAdmin := b.SendDocument(ctx, &bot.SendDocumentParams{
-			ChatID: admin,
-			Document: &models.InputFileUpload{
-				Filename: fileName,
-				Data:     f,
-			},
-			Caption: msgText,
+			_, err := b.SendDocument(ctx, &bot.SendDocumentParams{
+				ChatID: admin,
+				Document: &models.InputFileUpload{
+					Filename: receipt.FileName,
+					Data:     f,
+				},
+				Caption: msgText,
+			})
+			return err
 		})
 		if errSendToAdmin != nil {
 			h.logger.Error("Failed to send file to admin", zap.Error(errSendToAdmin))
 		}
 	}
 
-	kb := models.ReplyKeyboardMarkup{
-		Keyboard: [][]models.KeyboardButton{
-			{
-				{
-					Text:           "📲 Контактіні бөлісу",
-					RequestContact: true,
-				},
-			},
-		},
-		ResizeKeyboard:  true,
-		OneTimeKeyboard: true,
+	h.askForContact(ctx, b, chatID, lang)
+}
+
+// acceptPayment records a receipt the user paid with: the claim, loto tickets, money
+// total, client and order, then moves the user on to sharing their contact. It tells the
+// user what went wrong and returns false if the payment wasn't recorded.
+func (h *Handler) acceptPayment(ctx context.Context, b *bot.Bot, chatID, userId int64, lang string, state *domain.UserState, receipt *domain.PendingReceipt) bool {
+	totalLoto := state.Count * lotoTicketsPerKit
+
+	// The claim, tickets, money total, client and order are written together: if
+	// anything fails nothing is kept and the user can send the same receipt again
+	datePay := time.Now().Format("2006-01-02 15:04:05")
+	tickets := newLotoIDs(totalLoto)
+	entries := make([]domain.LotoEntry, 0, len(tickets))
+	for _, lotoId := range tickets {
+		entries = append(entries, domain.LotoEntry{
+			UserID:  userId,
+			LotoID:  lotoId,
+			QR:      receipt.Qr,
+			Receipt: receipt.FilePath,
+			DatePay: datePay,
+			Checks:  false,
+		})
+	}
+	userName, err := h.clientRepo.GetUserName(ctx, userId)
+	if err != nil {
+		h.logger.Warn("Failed to get user name", zap.Error(err), zap.Int64("user_id", userId))
+	}
+	orderID, claimed, err := h.clientRepo.RecordPayment(ctx, domain.PaymentEntry{
+		UserID:   userId,
+		UserName: userName,
+		Quantity: state.Count,
+		Amount:   receipt.ActualPrice,
+		QR:       receipt.Qr,
+		Receipt:  receipt.FilePath,
+		DatePay:  datePay,
+		Tickets:  entries,
+	})
+	if err != nil {
+		h.logger.Error("Failed to record payment", zap.Error(err), zap.Int64("user_id", userId))
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: userId,
+			Text:   i18n.T(lang, i18n.ReceiptRetry),
+		})
+		return false
+	}
+	if !claimed {
+		h.metrics.payments.WithLabelValues(paymentResultDuplicate).Inc()
+		if err := h.redisRepo.DeletePendingReceipt(ctx, userId); err != nil {
+			h.logger.Error("Failed to delete pending receipt from Redis", zap.Error(err))
+		}
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   i18n.T(lang, i18n.ReceiptAlreadyUsed),
+		})
+		return false
 	}
-	successMessage := "✅ Чек PDF сәтті қабылданды! 🎉\n\n" +
-		"📞 Сізбен кері байланысқа шығу үшін төмендегі\n" +
-		"📲 Контактіні бөлісу түймесін 👇 міндетті басыңыз.\n\n"
 
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      update.Message.Chat.ID,
+	state.IsPaid = true
+	state.State = StateContact
+	state.Receipt = receipt
+	state.OrderID = orderID
+	h.metrics.orders.WithLabelValues(orderSourceBot).Inc()
+	h.publishOrderCreated(orderID, userId, orderSourceBot, state.Count, receipt.ActualPrice)
+	h.recordPaymentEvents(orderID, state, datePay)
+	if err := h.redisRepo.SaveUserState(ctx, userId, state); err != nil {
+		h.logger.Error("Failed to save user state to Redis", zap.Error(err))
+	}
+
+	if err := h.redisRepo.DeletePendingReceipt(ctx, userId); err != nil {
+		h.logger.Error("Failed to delete pending receipt from Redis", zap.Error(err))
+	}
+
+	return true
+}
+
+// askForContact thanks the user for the payment and asks them to share their phone
+func (h *Handler) askForContact(ctx context.Context, b *bot.Bot, chatID int64, lang string) {
+	successMessage := i18n.T(lang, i18n.ReceiptAccepted)
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
 		Text:        successMessage,
-		ReplyMarkup: kb,
+		ReplyMarkup: shareContactKeyboard(lang),
 	})
 	if err != nil {
 		h.logger.Warn("Failed to send confirmation message", zap.Error(err))
@@ -1012,24 +2084,13 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 	}
 
 	userId := update.Message.From.ID
+	lang := h.userLang(ctx, userId)
 
 	if update.Message.Contact == nil {
-		kb := models.ReplyKeyboardMarkup{
-			Keyboard: [][]models.KeyboardButton{
-				{
-					{
-						Text:           "📲 Контактіні бөлісу",
-						RequestContact: true,
-					},
-				},
-			},
-			ResizeKeyboard:  true,
-			OneTimeKeyboard: true,
-		}
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      userId,
-			Text:        "Cізбен кері байланысқа шығу үшін контактіні 📲 бөлісу түймесін басыңыз.",
-			ReplyMarkup: kb,
+			Text:        i18n.T(lang, i18n.ShareContactPrompt),
+			ReplyMarkup: shareContactKeyboard(lang),
 		})
 		if err != nil {
 			h.logger.Warn("Failed to answer callback query", zap.Error(err))
@@ -1038,6 +2099,20 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 		return
 	}
 
+	contact, err := service.NormalizePhone(update.Message.Contact.PhoneNumber)
+	if err != nil {
+		h.logger.Warn("Invalid contact phone", zap.Error(err), zap.Int64("user_id", userId))
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      userId,
+			Text:        i18n.T(lang, i18n.ContactInvalid),
+			ReplyMarkup: shareContactKeyboard(lang),
+		})
+		if err != nil {
+			h.logger.Warn("Failed to send message", zap.Error(err))
+		}
+		return
+	}
+
 	state, err := h.redisRepo.GetUserState(ctx, userId)
 	if err != nil {
 		h.logger.Error("Failed to get user state from Redis", zap.Error(err))
@@ -1048,7 +2123,7 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 		}
 	}
 	if state != nil {
-		state.Contact = update.Message.Contact.PhoneNumber
+		state.Contact = contact
 		if err := h.redisRepo.SaveUserState(ctx, userId, state); err != nil {
 			h.logger.Error("Failed to save user state to Redis", zap.Error(err))
 		}
@@ -1087,22 +2162,72 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 		InlineKeyboard: [][]models.InlineKeyboardButton{
 			{
 				{
-					Text: "📍 Мекен-жайды енгізу",
-					URL:  "t.me/zhad_parfume_bot/ZhadParfume", // Direct static URL
+					Text: i18n.T(lang, i18n.AddressButton),
+					URL:  miniAppLink,
 				},
 			},
 		},
 	}
 
-	_, errCheck := h.clientRepo.IsClientUnique(ctx, userId)
-	if errCheck != nil {
-		h.logger.Warn("Failed to check if client is paid", zap.Error(errCheck))
+	if state == nil {
+		h.logger.Warn("Contact shared without a payment state", zap.Int64("user_id", userId))
 		return
 	}
 
+	if state.OrderID != 0 {
+		// The order was created with the payment; the contact only completes it
+		if err := h.clientRepo.SetPaymentContact(ctx, userId, state.OrderID, state.Contact); err != nil {
+			h.logger.Warn("Failed to save payment contact", zap.Error(err), zap.Int64("order_id", state.OrderID))
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: h.cfg.AdminID,
+				Text:   fmt.Sprintf("Error when save contact of order %d, error: %s", state.OrderID, err.Error()),
+			})
+		}
+	} else if state.Receipt != nil {
+		// A payment accepted before orders were created with it has no order yet
+		h.insertContactOrder(ctx, b, update.Message.From, state)
+	} else {
+		// e.g. the fallback state after a Redis error: the payment's order can't be told apart
+		h.logger.Warn("Contact shared without a known order", zap.Int64("user_id", userId))
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:         update.Message.Chat.ID,
+		Text:           i18n.T(lang, i18n.ContactReceived),
+		ReplyMarkup:    kb,
+		ProtectContent: true,
+	})
+	if err != nil {
+		h.logger.Warn("Failed to send confirmation message", zap.Error(err))
+	}
+
+	if err := h.redisRepo.DeleteUserState(ctx, userId); err != nil {
+		h.logger.Error("Failed to delete user state from Redis", zap.Error(err))
+	}
+}
+
+// shareContactKeyboard is the keyboard with the button that shares the user's phone number
+func shareContactKeyboard(lang string) *models.ReplyKeyboardMarkup {
+	return &models.ReplyKeyboardMarkup{
+		Keyboard: [][]models.KeyboardButton{
+			{
+				{
+					Text:           i18n.T(lang, i18n.ShareContactButton),
+					RequestContact: true,
+				},
+			},
+		},
+		ResizeKeyboard:  true,
+		OneTimeKeyboard: true,
+	}
+}
+
+// insertContactOrder creates the client and order of a payment whose state predates
+// RecordPayment, the way they were created before it: when the contact is shared
+func (h *Handler) insertContactOrder(ctx context.Context, b *bot.Bot, from *models.User, state *domain.UserState) {
 	entry := domain.ClientEntry{
-		UserID:       userId,
-		UserName:     update.Message.From.FirstName,
+		UserID:       from.ID,
+		UserName:     from.FirstName,
 		Fio:          sql.NullString{},
 		Contact:      state.Contact,
 		Address:      sql.NullString{},
@@ -1112,9 +2237,9 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 	}
 
 	order := domain.OrderEntry{
-		UserID:       userId,
-		Quantity:     state.Count,
-		UserName:     update.Message.From.FirstName,
+		UserID:       from.ID,
+		Quantity:     sql.NullInt64{Int64: int64(state.Count), Valid: state.Count > 0},
+		UserName:     from.FirstName,
 		Fio:          sql.NullString{},
 		Address:      sql.NullString{},
 		DateRegister: sql.NullString{},
@@ -1130,28 +2255,38 @@ func (h *Handler) ShareContactCallbackHandler(ctx context.Context, b *bot.Bot, u
 		})
 	}
 
-	if err := h.clientRepo.InsertOrder(ctx, order); err != nil {
+	orderID, err := h.clientRepo.InsertOrder(ctx, order)
+	if err != nil {
 		h.logger.Warn("Failed to insert order", zap.Error(err))
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: h.cfg.AdminID,
 			Text:   fmt.Sprintf("Error when save insert order, error: %s", err.Error()),
 		})
+		return
 	}
+	h.metrics.orders.WithLabelValues(orderSourceBot).Inc()
+	h.publishOrderCreated(orderID, from.ID, orderSourceBot, state.Count, state.Amount)
+	h.recordPaymentEvents(orderID, state, order.DatePay)
+}
 
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: update.Message.Chat.ID,
-		Text: "✅ Контактіңіз сәтті алынды! 😊\n" +
-			"Парфюм жинақты қай мекен-жайға жеткізу керек екенін көрсетіңіз. 🚚\n" +
-			"⤵️ Мекен-жайыңызды енгізу үшін батырманы басыңыз👇",
-		ReplyMarkup:    kb,
-		ProtectContent: true,
-	})
-	if err != nil {
-		h.logger.Warn("Failed to send confirmation message", zap.Error(err))
+// recordPaymentEvents starts the timeline of an order created from the bot with the
+// payment and the receipt accepted before the contact was shared
+func (h *Handler) recordPaymentEvents(orderID int64, state *domain.UserState, paidAt string) {
+	payment := map[string]interface{}{
+		"quantity": state.Count,
+		"paid_at":  paidAt,
+	}
+	if state.Receipt != nil {
+		payment["amount"] = state.Receipt.ActualPrice
+		payment["qr"] = state.Receipt.Qr
 	}
+	h.recordOrderEvent(orderID, domain.OrderEventPaymentReceived, domain.OrderEventActorUser, payment)
 
-	if err := h.redisRepo.DeleteUserState(ctx, userId); err != nil {
-		h.logger.Error("Failed to delete user state from Redis", zap.Error(err))
+	if state.Receipt != nil {
+		h.recordOrderEvent(orderID, domain.OrderEventReceiptSaved, domain.OrderEventActorSystem, map[string]interface{}{
+			"file_path": state.Receipt.FilePath,
+			"file_name": state.Receipt.FileName,
+		})
 	}
 }
 
@@ -1188,7 +2323,7 @@ func (h *Handler) getOrCreateUserState(ctx context.Context, userID int64) *domai
 
 // Fixed Handler methods - using repository methods instead of direct DB access
 
-// ENHANCED GetUserAvailableQuantity with temporary selection awareness
+// GetUserAvailableQuantity returns how many perfumes the user can still pick, plus their cart
 func (h *Handler) GetUserAvailableQuantity(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
@@ -1197,34 +2332,32 @@ func (h *Handler) GetUserAvailableQuantity(w http.ResponseWriter, r *http.Reques
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	telegramIDStr := r.URL.Query().Get("telegram_id")
 	if telegramIDStr == "" {
-		http.Error(w, "telegram_id parameter required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "telegram_id parameter required", nil)
 		return
 	}
 
 	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid telegram_id", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
 		return
 	}
 
 	// Get user's orders
-	orders, err := h.orderRepo.GetUnpaidOrdersByUser(telegramID)
+	orders, err := h.orderRepo.GetUnpaidOrdersByUser(r.Context(), telegramID)
 	if err != nil {
 		h.logger.Error("Error getting user orders", zap.Error(err))
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
 		return
 	}
 
 	var totalQuantity int
-	var temporaryQuantity int
 	var orderDetails []map[string]interface{}
-	var hasTemporarySelections bool
 
 	for _, order := range orders {
 		orderQuantity := 0
@@ -1232,35 +2365,24 @@ func (h *Handler) GetUserAvailableQuantity(w http.ResponseWriter, r *http.Reques
 			orderQuantity = *order.Quantity
 		}
 
-		// Parse existing perfume selections
-		selectedPerfumes := []string{}
-		usedQuantity := 0
-		isTemporarySelection := false
-
-		if order.Parfumes != "" {
-			// Check if this is a temporary selection (has perfumes but no address)
-			if order.Address != "" || order.Address == "" {
-				isTemporarySelection = true
-				hasTemporarySelections = true
-			}
+		// Only finalized selections are stored on the order
+		items, err := h.orderItemRepo.GetByOrder(r.Context(), order.ID)
+		if err != nil {
+			h.logger.Error("Error getting order items", zap.Error(err), zap.Int64("order_id", order.ID))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+			return
+		}
 
-			parts := strings.Split(order.Parfumes, ",")
-			for _, part := range parts {
-				if trimmed := strings.TrimSpace(part); trimmed != "" {
-					selectedPerfumes = append(selectedPerfumes, trimmed)
-					// Extract quantity from format "name: quantity"
-					if colonIndex := strings.Index(trimmed, ":"); colonIndex > 0 {
-						if quantityStr := strings.TrimSpace(trimmed[colonIndex+1:]); quantityStr != "" {
-							if qty, err := strconv.Atoi(quantityStr); err == nil {
-								usedQuantity += qty
-								if isTemporarySelection {
-									temporaryQuantity += qty
-								}
-							}
-						}
-					}
-				}
-			}
+		usedQuantity, err := h.orderItemRepo.SumQuantityByOrder(r.Context(), order.ID)
+		if err != nil {
+			h.logger.Error("Error summing order items", zap.Error(err), zap.Int64("order_id", order.ID))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+			return
+		}
+
+		selectedPerfumes := []string{}
+		for _, item := range items {
+			selectedPerfumes = append(selectedPerfumes, fmt.Sprintf("%s: %d", item.Name, item.Quantity))
 		}
 
 		availableInThisOrder := orderQuantity - usedQuantity
@@ -1270,38 +2392,36 @@ func (h *Handler) GetUserAvailableQuantity(w http.ResponseWriter, r *http.Reques
 
 		orderDetails = append(orderDetails, map[string]interface{}{
 			"id":                order.ID,
-			"total_quantity":    orderQuantity,
+			"total_quantity":    order.Quantity, // null if the bot lost the count
 			"used_quantity":     usedQuantity,
 			"available":         availableInThisOrder,
 			"selected_perfumes": selectedPerfumes,
-			"is_temporary":      isTemporarySelection,
+			"is_temporary":      false,
 			"created_at":        order.CreatedAt,
 		})
 	}
 
-	// FIXED: If we have temporary selections but backend shows 0 available,
-	// restore access by adding back the temporary quantity
-	effectiveAvailableQuantity := totalQuantity
-	if totalQuantity == 0 && temporaryQuantity > 0 {
-		effectiveAvailableQuantity = temporaryQuantity
-		h.logger.Info("Restoring user access due to temporary selections",
-			zap.Int64("telegram_id", telegramID),
-			zap.Int("temporary_quantity", temporaryQuantity))
+	// Temporary selections live in the cart and don't use up quantity until checkout
+	cart, err := h.redisRepo.GetCart(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Error("Error getting cart", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error loading cart", nil)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":                  true,
-		"available_quantity":       effectiveAvailableQuantity,
+		"available_quantity":       totalQuantity,
 		"original_available":       totalQuantity,
-		"temporary_quantity":       temporaryQuantity,
-		"has_temporary_selections": hasTemporarySelections,
-		"access_restored":          totalQuantity == 0 && temporaryQuantity > 0,
+		"temporary_quantity":       domain.CartQuantity(cart),
+		"has_temporary_selections": len(cart) > 0,
+		"access_restored":          false,
 		"orders":                   orderDetails,
 	})
 }
 
-// ENHANCED SavePerfumeSelection with better temporary storage logic
+// SavePerfumeSelection stores the user's current selection in their cart
 func (h *Handler) SavePerfumeSelection(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
@@ -1310,7 +2430,7 @@ func (h *Handler) SavePerfumeSelection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
@@ -1321,12 +2441,12 @@ func (h *Handler) SavePerfumeSelection(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
 		return
 	}
 
 	if req.TelegramID == 0 {
-		http.Error(w, "telegram_id required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "telegram_id required", nil)
 		return
 	}
 
@@ -1338,141 +2458,169 @@ func (h *Handler) SavePerfumeSelection(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// FIXED: Enhanced logic to handle both fresh selections and restored access
-	var availableQuantity int
-	var targetOrderID int64 = -1
-
-	// First, get the user's original available quantity from unpaid orders
-	originalAvailableQuantity, err := h.orderRepo.GetAvailableQuantityForUser(req.TelegramID)
+	// The cart doesn't hold any quantity, so it's checked against what finalized orders left
+	availableQuantity, err := h.orderRepo.GetAvailableQuantityForUser(r.Context(), req.TelegramID)
 	if err != nil {
-		h.logger.Error("Error getting original available quantity", zap.Error(err))
-		http.Error(w, "Error checking available quantity", http.StatusInternalServerError)
+		h.logger.Error("Error getting available quantity", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error checking available quantity", nil)
 		return
 	}
 
-	// Check if user had temporary selections that we need to account for
-	orders, err := h.orderRepo.GetUnpaidOrdersByUser(req.TelegramID)
-	if err != nil {
-		h.logger.Error("Error finding orders", zap.Error(err))
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	if totalSelected > availableQuantity {
+		writeJSONError(w, http.StatusBadRequest, "insufficient_quantity",
+			fmt.Sprintf("Not enough quantity available. You have %d, trying to select %d", availableQuantity, totalSelected),
+			map[string]interface{}{
+				"available": availableQuantity,
+				"requested": totalSelected,
+			})
 		return
 	}
 
-	// Calculate previously used quantity from temporary selections
-	var previousTempQuantity int
-	for _, order := range orders {
-		if order.Parfumes != "" && order.Address == "" {
-			// This is a temporary selection - count its quantity
-			parts := strings.Split(order.Parfumes, ",")
-			for _, part := range parts {
-				if trimmed := strings.TrimSpace(part); trimmed != "" {
-					if colonIndex := strings.Index(trimmed, ":"); colonIndex > 0 {
-						if quantityStr := strings.TrimSpace(trimmed[colonIndex+1:]); quantityStr != "" {
-							if qty, err := strconv.Atoi(quantityStr); err == nil {
-								previousTempQuantity += qty
-								if targetOrderID == -1 {
-									targetOrderID = order.ID // Use this order for updating
-								}
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// FIXED: If no temporary selections exist, find a fresh order to use
-	if targetOrderID == -1 {
-		for _, order := range orders {
-			if order.Quantity == nil {
-				continue
-			}
-			orderQuantity := *order.Quantity
-
-			// Calculate used quantity in this order
-			usedQuantity := 0
-			if order.Parfumes != "" {
-				parts := strings.Split(order.Parfumes, ",")
-				for _, part := range parts {
-					if trimmed := strings.TrimSpace(part); trimmed != "" {
-						if colonIndex := strings.Index(trimmed, ":"); colonIndex > 0 {
-							if quantityStr := strings.TrimSpace(trimmed[colonIndex+1:]); quantityStr != "" {
-								if qty, err := strconv.Atoi(quantityStr); err == nil {
-									usedQuantity += qty
-								}
-							}
-						}
-					}
-				}
-			}
-
-			availableInThisOrder := orderQuantity - usedQuantity
-			if availableInThisOrder > 0 {
-				targetOrderID = order.ID
-				break
+	// Build cart items, snapshotting perfume ID and price from the catalog
+	var items []domain.CartItem
+	var ids, names []string
+	for _, perfume := range req.SelectedPerfumes {
+		id, _ := perfume["id"].(string)
+		name, _ := perfume["name"].(string)
+		qty, qtyOk := perfume["quantity"].(float64)
+		if (id != "" || name != "") && qtyOk && qty > 0 {
+			items = append(items, domain.CartItem{
+				ParfumeID: id,
+				Name:      name,
+				Quantity:  int(qty),
+			})
+			if id != "" {
+				ids = append(ids, id)
+			} else {
+				names = append(names, name)
 			}
 		}
 	}
 
-	// Calculate effective available quantity
-	if previousTempQuantity > 0 {
-		// User had temporary selections - restore their effective available quantity
-		availableQuantity = previousTempQuantity
-		h.logger.Info("Restoring user access with temporary quantity",
-			zap.Int64("telegram_id", req.TelegramID),
-			zap.Int("previous_temp_quantity", previousTempQuantity),
-			zap.Int("original_available", originalAvailableQuantity))
-	} else {
-		// Fresh selection - use original available quantity
-		availableQuantity = originalAvailableQuantity
-	}
-
-	// Validate against effective available quantity
-	if totalSelected > availableQuantity {
-		http.Error(w, fmt.Sprintf("Not enough quantity available. You have %d, trying to select %d",
-			availableQuantity, totalSelected), http.StatusBadRequest)
+	perfumesByID, err := h.parfumeRepo.GetByIDs(r.Context(), ids)
+	if err != nil {
+		h.logger.Error("Error getting perfumes by id", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
 		return
 	}
 
-	if targetOrderID == -1 {
-		http.Error(w, "No available orders found", http.StatusBadRequest)
+	perfumesByName, err := h.parfumeRepo.GetByNames(r.Context(), names)
+	if err != nil {
+		h.logger.Error("Error getting perfumes by name", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
 		return
 	}
 
-	// Build perfume selection string (format: "name: quantity, name: quantity")
-	var parfumeSelections []string
-	for _, perfume := range req.SelectedPerfumes {
-		name, nameOk := perfume["name"].(string)
-		qty, qtyOk := perfume["quantity"].(float64)
-		if nameOk && qtyOk && qty > 0 {
-			parfumeSelections = append(parfumeSelections, fmt.Sprintf("%s: %d", name, int(qty)))
+	// The ID is what the page sends; the name is only looked up for clients that don't send it
+	resolved := make(map[string]repository.Product)
+	var unknown []map[string]interface{}
+	var unknownNames []string
+	for i := range items {
+		var perfume repository.Product
+		var ok bool
+		if items[i].ParfumeID != "" {
+			perfume, ok = perfumesByID[items[i].ParfumeID]
+		} else {
+			perfume, ok = perfumesByName[items[i].Name]
+		}
+		if !ok {
+			unknown = append(unknown, map[string]interface{}{
+				"id":   items[i].ParfumeID,
+				"name": items[i].Name,
+			})
+			if items[i].ParfumeID != "" {
+				unknownNames = append(unknownNames, items[i].ParfumeID)
+			} else {
+				unknownNames = append(unknownNames, items[i].Name)
+			}
+			continue
+		}
+		items[i].ParfumeID = perfume.Id
+		items[i].Name = perfume.NameParfume
+		items[i].Price = perfume.Price
+		resolved[perfume.Id] = perfume
+	}
+
+	if len(unknown) > 0 {
+		writeJSONError(w, http.StatusBadRequest, "unknown_perfume",
+			"Unknown perfumes: "+strings.Join(unknownNames, ", "),
+			map[string]interface{}{
+				"perfumes": unknown,
+			})
+		return
+	}
+
+	// Archived perfumes can't be selected
+	var archivedNames []string
+	for _, item := range items {
+		if perfume, ok := resolved[item.ParfumeID]; ok && !perfume.IsActive {
+			archivedNames = append(archivedNames, item.Name)
+		}
+	}
+
+	if len(archivedNames) > 0 {
+		writeJSONError(w, http.StatusConflict, "perfume_archived",
+			"Perfumes are no longer available: "+strings.Join(archivedNames, ", "),
+			map[string]interface{}{
+				"perfumes": archivedNames,
+			})
+		return
+	}
+
+	// Reject selections that exceed the remaining stock of any perfume
+	requested := cartQuantities(items)
+	var outOfStock []map[string]interface{}
+	var outOfStockNames []string
+	for _, item := range items {
+		perfume, ok := resolved[item.ParfumeID]
+		if !ok || perfume.Stock == nil || requested[item.ParfumeID] <= *perfume.Stock {
+			continue
 		}
+		delete(resolved, item.ParfumeID)
+		outOfStock = append(outOfStock, map[string]interface{}{
+			"id":        perfume.Id,
+			"name":      item.Name,
+			"available": *perfume.Stock,
+			"requested": requested[item.ParfumeID],
+		})
+		outOfStockNames = append(outOfStockNames, fmt.Sprintf("%s (available %d, requested %d)", item.Name, *perfume.Stock, requested[item.ParfumeID]))
 	}
 
-	parfumeString := strings.Join(parfumeSelections, ", ")
+	if len(outOfStock) > 0 {
+		writeJSONError(w, http.StatusConflict, "insufficient_stock",
+			"Not enough stock for: "+strings.Join(outOfStockNames, ", "),
+			map[string]interface{}{
+				"perfumes": outOfStock,
+			})
+		return
+	}
 
-	// Update the order with perfume selection (this creates temporary selection)
-	err = h.orderRepo.UpdatePerfumeSelection(targetOrderID, parfumeString)
+	if len(items) == 0 {
+		err = h.redisRepo.ClearCart(r.Context(), req.TelegramID)
+	} else {
+		err = h.redisRepo.SaveCart(r.Context(), req.TelegramID, items, time.Duration(h.cfg.CartTTLHours)*time.Hour)
+	}
 	if err != nil {
-		h.logger.Error("Error updating order with perfumes", zap.Error(err))
-		http.Error(w, "Error saving selection", http.StatusInternalServerError)
+		h.logger.Error("Error saving cart", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "selection_save_failed", "Error saving selection", nil)
 		return
 	}
 
-	h.logger.Info("Perfume selection saved (temporary)",
+	parfumeString := domain.FormatCartItems(items)
+
+	h.logger.Info("Perfume selection saved to cart",
 		zap.Int64("telegram_id", req.TelegramID),
-		zap.Int64("order_id", targetOrderID),
 		zap.String("perfumes", parfumeString),
-		zap.Bool("is_restored_access", previousTempQuantity > 0))
+		zap.Int("total_quantity", totalSelected))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":         true,
 		"message":         "Perfume selection saved successfully",
-		"order_id":        targetOrderID,
 		"perfumes":        parfumeString,
+		"total_quantity":  domain.CartQuantity(items),
 		"is_temporary":    true,
-		"restored_access": previousTempQuantity > 0,
+		"restored_access": false,
 	})
 }
 
@@ -1485,13 +2633,13 @@ func (h *Handler) UpdateOrderWithClientInfo(w http.ResponseWriter, r *http.Reque
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	err := r.ParseMultipartForm(10 << 20)
 	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_form", "Error parsing form", nil)
 		return
 	}
 
@@ -1503,57 +2651,113 @@ func (h *Handler) UpdateOrderWithClientInfo(w http.ResponseWriter, r *http.Reque
 	longitudeStr := r.FormValue("longitude")
 
 	if telegramIDStr == "" || fio == "" || contact == "" || address == "" {
-		http.Error(w, "Required fields missing", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_fields", "Required fields missing", nil)
+		return
+	}
+
+	contact, err = service.NormalizePhone(contact)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_contact", "contact must be a Kazakhstan phone number", nil)
 		return
 	}
 
 	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid telegram_id", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
 		return
 	}
 
 	// Parse coordinates if provided
-	var latitude, longitude *float64
-	if latitudeStr != "" {
-		if lat, err := strconv.ParseFloat(latitudeStr, 64); err == nil {
-			latitude = &lat
-		}
+	latitude, longitude := parseCoordinates(latitudeStr, longitudeStr)
+
+	// The selection page keeps the user's choice in the cart until now
+	cart, err := h.redisRepo.GetCart(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Error("Error getting cart", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error loading cart", nil)
+		return
 	}
-	if longitudeStr != "" {
-		if lng, err := strconv.ParseFloat(longitudeStr, 64); err == nil {
-			longitude = &lng
-		}
+
+	if len(cart) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "no_perfume_selection", "No perfume selection found. Please select perfumes first", nil)
+		return
 	}
 
-	// Find the order with perfume selection using repository method
-	order, err := h.orderRepo.GetOrderWithPerfumeSelection(telegramID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "No perfume selection found. Please select perfumes first", http.StatusBadRequest)
-		} else {
-			h.logger.Error("Error finding order", zap.Error(err))
-			http.Error(w, "Database error", http.StatusInternalServerError)
+	// Stock, order items, the selection and the client info are written together or not at all
+	info := domain.CheckoutInfo{FIO: fio, Contact: contact, Address: address, Latitude: latitude, Longitude: longitude}
+	allocations, err := h.orderRepo.Checkout(r.Context(), telegramID, cart, info)
+	var quantityErr *repository.InsufficientQuantityError
+	var stockErr *repository.InsufficientStockError
+	switch {
+	case errors.As(err, &quantityErr):
+		writeJSONError(w, http.StatusBadRequest, "insufficient_quantity",
+			fmt.Sprintf("Not enough quantity available. You have %d, trying to select %d", quantityErr.Available, quantityErr.Requested),
+			map[string]interface{}{
+				"available": quantityErr.Available,
+				"requested": quantityErr.Requested,
+			})
+		return
+	case errors.As(err, &stockErr):
+		name := stockErr.ParfumeID
+		for _, item := range cart {
+			if item.ParfumeID == stockErr.ParfumeID {
+				name = item.Name
+				break
+			}
 		}
+		writeJSONError(w, http.StatusConflict, "insufficient_stock",
+			fmt.Sprintf("Not enough stock for: %s (available %d, requested %d)", name, stockErr.Available, stockErr.Requested),
+			map[string]interface{}{
+				"perfumes": []map[string]interface{}{{
+					"id":        stockErr.ParfumeID,
+					"name":      name,
+					"available": stockErr.Available,
+					"requested": stockErr.Requested,
+				}},
+			})
+		return
+	case err != nil:
+		h.logger.Error("Error completing orders", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusInternalServerError, "client_save_failed", "Error saving client information", nil)
 		return
 	}
 
-	// Update the order with client information including coordinates
-	err = h.orderRepo.UpdateClientInfoWithCoordinates(order.ID, fio, contact, address)
-	if err != nil {
-		h.logger.Error("Error updating order with client info", zap.Error(err))
-		http.Error(w, "Error saving client information", http.StatusInternalServerError)
-		return
+	orderIDs := make([]int64, 0, len(allocations))
+	for _, allocation := range allocations {
+		orderIDs = append(orderIDs, allocation.Order.ID)
+
+		selected := make([]map[string]interface{}, 0, len(allocation.Items))
+		for _, item := range allocation.Items {
+			selected = append(selected, map[string]interface{}{
+				"parfume_id": item.ParfumeID,
+				"name":       item.Name,
+				"quantity":   item.Quantity,
+			})
+		}
+		h.recordOrderEvent(allocation.Order.ID, domain.OrderEventPerfumeSelected, domain.OrderEventActorUser, map[string]interface{}{
+			"items": selected,
+		})
+		h.recordOrderEvent(allocation.Order.ID, domain.OrderEventAddressProvided, domain.OrderEventActorUser, map[string]interface{}{
+			"fio":       fio,
+			"contact":   contact,
+			"address":   address,
+			"latitude":  latitude,
+			"longitude": longitude,
+		})
 	}
 
-	// Send success message to user via Telegram
-	if h.bot != nil {
-		go h.sendOrderConfirmationMessage(telegramID, order.ID, order.UserName, order.Parfumes, fio, contact, address)
+	if err := h.redisRepo.ClearCart(r.Context(), telegramID); err != nil {
+		h.logger.Error("Error clearing cart", zap.Error(err), zap.Int64("telegram_id", telegramID))
 	}
 
+	order := allocations[0].Order
+
+	// Send success message to user via Telegram
+	go h.sendOrderConfirmationMessage(telegramID, order.ID, order.UserName, domain.FormatCartItems(cart), fio, contact, address)
+
 	h.logger.Info("Order updated with client info",
 		zap.Int64("telegram_id", telegramID),
-		zap.Int64("order_id", order.ID),
+		zap.Int64s("order_ids", orderIDs),
 		zap.String("fio", fio),
 		zap.String("contact", contact),
 		zap.String("address", address),
@@ -1562,49 +2766,29 @@ func (h *Handler) UpdateOrderWithClientInfo(w http.ResponseWriter, r *http.Reque
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":  true,
-		"message":  "Order completed successfully",
-		"order_id": order.ID,
+		"success":   true,
+		"message":   "Order completed successfully",
+		"order_id":  order.ID,
+		"order_ids": orderIDs,
 	})
 }
 
 // Send order confirmation message to Telegram
 func (h *Handler) sendOrderConfirmationMessage(telegramID, orderID int64, userName, parfumes, fio, contact, address string) {
-	if h.bot == nil {
-		h.logger.Error("Bot not initialized")
+	if h.silent {
 		return
 	}
 
 	// Build message
-	var messageText strings.Builder
-	messageText.WriteString("✅ Тапсырыс сәтті рәсімделді!\n\n")
-	messageText.WriteString(fmt.Sprintf("📦 Тапсырыс №: %d\n", orderID))
-	messageText.WriteString(fmt.Sprintf("👤 Клиент: %s\n", fio))
-	messageText.WriteString(fmt.Sprintf("📱 Телефон: %s\n", contact))
-	messageText.WriteString(fmt.Sprintf("📍 Мекенжай: %s\n\n", address))
-	messageText.WriteString("🌸 Таңдалған парфюмдер:\n")
-	messageText.WriteString(fmt.Sprintf("_%s_\n\n", parfumes))
-	messageText.WriteString("🚚 Жеткізу туралы ақпарат:\n")
-	messageText.WriteString("Біздің менеджер сізбен 48 сағат ішінде байланысады.\n\n")
-	messageText.WriteString("Рахмет! 💝")
-
-	// Send message to user
-	_, err := h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
+	lang := h.userLang(h.ctx, telegramID)
+	messageText := i18n.T(lang, i18n.OrderPlaced, orderID, fio, contact, address, parfumes)
+
+	// Queue message to user
+	h.enqueueMessage(telegramID, orderID, &bot.SendMessageParams{
 		ChatID: telegramID,
-		Text:   messageText.String(),
+		Text:   messageText,
 	})
 
-	if err != nil {
-		h.logger.Error("Failed to send confirmation message to user",
-			zap.Error(err),
-			zap.Int64("telegram_id", telegramID),
-			zap.Int64("order_id", orderID))
-	} else {
-		h.logger.Info("Order confirmation sent to user successfully",
-			zap.Int64("telegram_id", telegramID),
-			zap.Int64("order_id", orderID))
-	}
-
 	// Send notification to admin
 	adminMessage := fmt.Sprintf(
 		"📋 Жаңа тапсырыс!\n\n"+
@@ -1620,19 +2804,66 @@ func (h *Handler) sendOrderConfirmationMessage(telegramID, orderID int64, userNa
 	admins := []int64{h.cfg.AdminID, h.cfg.AdminID2}
 	for _, adminID := range admins {
 		if adminID != 0 {
-			_, err := h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
+			h.enqueueMessage(adminID, orderID, &bot.SendMessageParams{
 				ChatID: adminID,
 				Text:   adminMessage,
 			})
-			if err != nil {
-				h.logger.Error("Failed to send admin notification",
-					zap.Error(err),
-					zap.Int64("admin_id", adminID))
-			}
 		}
 	}
 }
 
+// GetPendingPayment returns the user's newest unpaid order so the mini app can show its pay button again
+func (h *Handler) GetPendingPayment(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	telegramIDStr := r.URL.Query().Get("telegram_id")
+	if telegramIDStr == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "telegram_id parameter required", nil)
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
+		return
+	}
+
+	order, err := h.orderRepo.GetPendingPayment(r.Context(), telegramID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"pending": false,
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Error getting pending payment", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"pending":      true,
+		"order_id":     order.ID,
+		"payment_link": order.PaymentLink,
+		"total_amount": order.TotalAmount,
+		"parfumes":     order.Parfumes,
+		"created_at":   order.CreatedAt,
+	})
+}
+
 // GetUserTemporarySelections retrieves user's temporary perfume selections
 func (h *Handler) GetUserTemporarySelections(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
@@ -1642,61 +2873,41 @@ func (h *Handler) GetUserTemporarySelections(w http.ResponseWriter, r *http.Requ
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	telegramIDStr := r.URL.Query().Get("telegram_id")
 	if telegramIDStr == "" {
-		http.Error(w, "telegram_id parameter required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "telegram_id parameter required", nil)
 		return
 	}
 
 	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid telegram_id", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
 		return
 	}
 
-	// Get orders with perfume selections that haven't been finalized (no address yet)
-	orders, err := h.orderRepo.GetUnpaidOrdersByUser(telegramID)
+	cart, err := h.redisRepo.GetCart(r.Context(), telegramID)
 	if err != nil {
-		h.logger.Error("Error getting user orders for temp selections", zap.Error(err))
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		h.logger.Error("Error getting cart for temp selections", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error loading cart", nil)
 		return
 	}
 
-	var temporarySelections []map[string]interface{}
-	var totalTempQuantity int
-
-	for _, order := range orders {
-		// Check if this order has perfume selections but no address (meaning it's temporary)
-		if order.Parfumes != "" && (order.Address == "" || order.Address == "") {
-			// Parse the perfume selections
-			parts := strings.Split(order.Parfumes, ",")
-			for _, part := range parts {
-				if trimmed := strings.TrimSpace(part); trimmed != "" {
-					// Extract name and quantity from format "name: quantity"
-					if colonIndex := strings.Index(trimmed, ":"); colonIndex > 0 {
-						name := strings.TrimSpace(trimmed[:colonIndex])
-						quantityStr := strings.TrimSpace(trimmed[colonIndex+1:])
-						if quantity, err := strconv.Atoi(quantityStr); err == nil && quantity > 0 {
-							// Try to find the perfume ID by name
-							perfumeID := h.findPerfumeIDByName(name)
-							if perfumeID != "" {
-								temporarySelections = append(temporarySelections, map[string]interface{}{
-									"id":       perfumeID,
-									"name":     name,
-									"quantity": quantity,
-								})
-								totalTempQuantity += quantity
-							}
-						}
-					}
-				}
-			}
+	temporarySelections := make([]map[string]interface{}, 0, len(cart))
+	for _, item := range cart {
+		if item.ParfumeID == "" {
+			continue
 		}
+		temporarySelections = append(temporarySelections, map[string]interface{}{
+			"id":       item.ParfumeID,
+			"name":     item.Name,
+			"quantity": item.Quantity,
+		})
 	}
+	totalTempQuantity := domain.CartQuantity(cart)
 
 	h.logger.Info("Retrieved temporary selections",
 		zap.Int64("telegram_id", telegramID),
@@ -1712,25 +2923,13 @@ func (h *Handler) GetUserTemporarySelections(w http.ResponseWriter, r *http.Requ
 	})
 }
 
-// Helper function to find perfume ID by name
-func (h *Handler) findPerfumeIDByName(name string) string {
-	perfumes, err := h.parfumeRepo.GetAll()
-	if err != nil {
-		h.logger.Error("Error getting perfumes for name lookup", zap.Error(err))
-		return ""
-	}
-
-	for _, perfume := range perfumes {
-		if perfume.NameParfume == name {
-			return perfume.Id
-		}
-	}
-	return ""
-}
-
-// SetBot sets the bot instance for the handler
+// SetBot sets the bot instance for the handler and sends the notifications that were
+// kept while there was none
 func (h *Handler) SetBot(b *bot.Bot) {
 	h.bot = b
+	if b != nil {
+		go h.flushPendingNotifications()
+	}
 }
 
 // Update your StartWebServer method to include prize routes
@@ -1745,6 +2944,23 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 		}
 	}
 
+	h.logger.Info("Starting web server with prize wheel functionality", zap.String("port", h.cfg.Port))
+
+	// Requests derive their context from ctx, so shutting down cancels the queries
+	// still running for them instead of leaving them to the closing database
+	server := &http.Server{
+		Addr:        h.cfg.Port,
+		Handler:     h.loggingMiddleware(h.routes(b)),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	if err := server.ListenAndServe(); err != nil {
+		h.logger.Fatal("Failed to start web server", zap.Error(err))
+	}
+}
+
+// routes registers every page and API endpoint of the web server; b takes the Telegram
+// updates in webhook mode
+func (h *Handler) routes(b *bot.Bot) *http.ServeMux {
 	// CORS Middleware
 	corsMiddleware := func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1804,7 +3020,7 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 
 	// API endpoints
 	mux.HandleFunc("/api/parfumes", h.handleGetPerfumes)
-	mux.HandleFunc("/api/parfume/", h.handleGetPerfume)
+	mux.HandleFunc("/api/parfume/", h.handlePerfumeRoutes)
 	mux.HandleFunc("/api/add-parfume", h.handleAddPerfume)
 	mux.HandleFunc("/api/update-parfume/", h.handleUpdatePerfume)
 	mux.HandleFunc("/api/delete-parfume/", h.handleDeletePerfume)
@@ -1814,16 +3030,62 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 	mux.HandleFunc("/api/user/available-quantity", h.GetUserAvailableQuantity)
 	mux.HandleFunc("/api/user/temp-selections", h.GetUserTemporarySelections)
 	mux.HandleFunc("/api/user/save-perfume-selection", h.SavePerfumeSelection)
+	mux.HandleFunc("/api/user/pending-payment", h.GetPendingPayment)
+	mux.HandleFunc("/api/user/profile", h.requireAdmin(h.handleGetUserProfile))
 	mux.HandleFunc("/api/order/complete", h.UpdateOrderWithClientInfo)
+	mux.HandleFunc("/api/order/place", h.handlePlaceOrder)
 
 	// NEW: Prize wheel endpoints
 	mux.HandleFunc("/api/prize/eligibility", h.CheckSpinEligibility)
 	mux.HandleFunc("/api/prize/spin", h.SpinWheel)
 	mux.HandleFunc("/api/prize/complete", h.CompletePrizeOrder)
+	mux.HandleFunc("/api/prize/history", h.GetPrizeHistory)
+	mux.HandleFunc("/api/prize/token", h.GetPrizeToken)
+	mux.HandleFunc("/api/prizes/winners", h.requireAdmin(h.handleGetPrizeWinners))
+
+	// Admin endpoints
+	mux.HandleFunc("/api/admin/order/", h.requireAdmin(h.handleAdminOrderRoutes))
+	mux.HandleFunc("/api/admin/low-stock", h.requireAdmin(h.handleGetLowStock))
+	mux.HandleFunc("/api/admin/parfume/", h.requireAdmin(h.handlePurgePerfume))
+	mux.HandleFunc("/api/admin/prizes/bulk-assign", h.requireAdmin(h.handleBulkAssignPrizes))
+	mux.HandleFunc("/api/admin/prizes/inventory", h.requireAdmin(h.handlePrizeInventory))
+	mux.HandleFunc("/api/admin/spins", h.requireAdmin(h.handleGetSpins))
+	mux.HandleFunc("/api/admin/prizes/rules", h.requireAdmin(h.handlePrizeRules))
+	mux.HandleFunc("/api/admin/loto/draw", h.requireAdmin(h.handleDrawLoto))
+	mux.HandleFunc("/api/admin/photos/thumbnails", h.requireAdmin(h.handleBackfillThumbnails))
+	mux.HandleFunc("/api/admin/parfumes/import", h.requireAdmin(h.handleImportPerfumes))
+	mux.HandleFunc("/api/admin/parfumes/import-template", h.requireAdmin(h.handleImportTemplate))
+	mux.HandleFunc("/api/admin/orders/export", h.requireAdmin(h.handleExportOrders))
+	mux.HandleFunc("/api/admin/dashboard", h.requireAdmin(h.handleGetDashboard))
+	mux.HandleFunc("/api/admin/stats/daily", h.requireAdmin(h.handleGetDailyStats))
+	mux.HandleFunc("/api/admin/clients", h.requireAdmin(h.handleGetClients))
+	mux.HandleFunc("/api/admin/stream", h.requireAdmin(h.handleAdminStream))
+	mux.HandleFunc("/api/failed-notifications", h.requireAdmin(h.handleFailedNotificationRoutes))
+	mux.HandleFunc("/api/failed-notifications/", h.requireAdmin(h.handleFailedNotificationRoutes))
 
 	// Existing endpoints
 	mux.HandleFunc("/api/orders", h.handleGetOrders)
-	mux.HandleFunc("/api/order/", h.handleGetOrder)
+	mux.HandleFunc("/api/orders/export", h.requireAdmin(h.handleExportOrders))
+	mux.HandleFunc("/api/orders/search", h.requireAdmin(h.handleSearchOrders))
+	mux.HandleFunc("/api/loto", h.handleGetLotoTickets)
+	mux.HandleFunc("/api/loto/reissue", h.requireAdmin(h.handleReissueLoto))
+	mux.HandleFunc("/api/admin/promo", h.requireAdmin(h.handlePromoSettings))
+	mux.HandleFunc("/api/stats/timings", h.requireAdmin(h.handleGetTimingStats))
+	mux.HandleFunc("/api/order/", h.handleOrderRoutes)
+
+	// Staging-only end-to-end check of the order flow
+	if h.cfg.SimulationEnabled {
+		h.logger.Warn("Order simulation endpoint is enabled")
+		mux.HandleFunc("/api/test/simulate-order", h.handleSimulateOrder)
+	}
+
+	// Telegram updates in webhook mode; the bot checks the secret token header
+	if b != nil && h.cfg.BotMode == config.BotModeWebhook {
+		mux.HandleFunc(h.cfg.WebhookPath(), b.WebhookHandler())
+	}
+
+	// Prometheus scrape endpoint; the scrape config sends the admin token as a bearer token
+	mux.HandleFunc("/metrics", h.requireAdmin(h.metrics.handler().ServeHTTP))
 
 	// Health check
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -1843,14 +3105,9 @@ func (h *Handler) StartWebServer(ctx context.Context, b *bot.Bot) {
 		})
 	})
 
-	h.logger.Info("Starting web server with prize wheel functionality", zap.String("port", h.cfg.Port))
-
-	if err := http.ListenAndServe(h.cfg.Port, mux); err != nil {
-		h.logger.Fatal("Failed to start web server", zap.Error(err))
-	}
+	return mux
 }
 
-
 // Create photo handler (helper method)
 func (h *Handler) createPhotoHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1863,6 +3120,26 @@ func (h *Handler) createPhotoHandler() http.Handler {
 
 		filePath := filepath.Join("./photo", filename)
 
+		// ?size=thumb|medium serves a resized variant, falling back to the original
+		// when it hasn't been generated yet
+		cacheControl := "public, max-age=86400"
+		if size := r.URL.Query().Get("size"); size != "" {
+			if !service.IsThumbnailSize(size) {
+				writeJSONError(w, http.StatusBadRequest, "invalid_size", "Unknown photo size", map[string]interface{}{
+					"size": size,
+				})
+				return
+			}
+
+			variant := service.ThumbnailFilename(filename, size)
+			if _, err := os.Stat(filepath.Join("./photo", variant)); err == nil {
+				filename = variant
+				filePath = filepath.Join("./photo", variant)
+				// Variants are never rewritten under the same name
+				cacheControl = "public, max-age=31536000, immutable"
+			}
+		}
+
 		h.logger.Info("Photo request",
 			zap.String("url", r.URL.Path),
 			zap.String("filename", filename),
@@ -1875,7 +3152,7 @@ func (h *Handler) createPhotoHandler() http.Handler {
 			return
 		} else if err != nil {
 			h.logger.Error("Error accessing photo file", zap.Error(err))
-			http.Error(w, "Error accessing file", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, "file_access_error", "Error accessing file", nil)
 			return
 		}
 
@@ -1883,7 +3160,8 @@ func (h *Handler) createPhotoHandler() http.Handler {
 			zap.String("filepath", filePath),
 			zap.Int64("size", fileInfo.Size()))
 
-		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
 
 		ext := strings.ToLower(filepath.Ext(filename))
 		switch ext {
@@ -1895,8 +3173,6 @@ func (h *Handler) createPhotoHandler() http.Handler {
 			w.Header().Set("Content-Type", "image/gif")
 		case ".webp":
 			w.Header().Set("Content-Type", "image/webp")
-		case ".svg":
-			w.Header().Set("Content-Type", "image/svg+xml")
 		default:
 			w.Header().Set("Content-Type", "application/octet-stream")
 		}
@@ -1915,19 +3191,76 @@ func (h *Handler) handleGetPerfumes(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
-	perfumes, err := h.parfumeRepo.GetAll()
+	// The storefront passes ?telegram_id= to see how many of each perfume are already in the user's cart
+	var inCart map[string]int
+	if telegramIDStr := r.URL.Query().Get("telegram_id"); telegramIDStr != "" {
+		telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
+			return
+		}
+
+		cart, err := h.redisRepo.GetCart(r.Context(), telegramID)
+		if err != nil {
+			h.logger.Error("Error getting cart for perfume list", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Error loading cart", nil)
+			return
+		}
+		inCart = cartQuantities(cart)
+	}
+
+	// Admins pass ?include_archived=1 to see archived perfumes too
+	perfumes, err := h.parfumeRepo.GetAll(r.Context(), includeArchived(r))
 	if err != nil {
 		h.logger.Error("Error getting perfumes", zap.Error(err))
-		http.Error(w, "Error getting perfumes", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting perfumes", nil)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(perfumes)
+	json.NewEncoder(w).Encode(annotatePerfumes(perfumes, inCart))
+}
+
+// perfumeListItem is a perfume as listed in the storefront
+type perfumeListItem struct {
+	repository.Product
+	Available    bool `json:"available"`       // the user can still add at least one more
+	InCartByUser int  `json:"in_cart_by_user"` // quantity already in the user's cart
+}
+
+// annotatePerfumes marks which perfumes the user can still add, given what
+// is already in their cart; a nil inCart means an empty cart
+func annotatePerfumes(perfumes []repository.Product, inCart map[string]int) []perfumeListItem {
+	items := make([]perfumeListItem, 0, len(perfumes))
+	for _, perfume := range perfumes {
+		quantity := inCart[perfume.Id]
+		items = append(items, perfumeListItem{
+			Product:      perfume,
+			Available:    perfume.IsActive && (perfume.Stock == nil || *perfume.Stock > quantity),
+			InCartByUser: quantity,
+		})
+	}
+	return items
+}
+
+// handlePerfumeRoutes dispatches /api/parfume/{id}, /api/parfume/{id}/restore
+// and the /api/parfume/{id}/photos gallery routes
+func (h *Handler) handlePerfumeRoutes(w http.ResponseWriter, r *http.Request) {
+	_, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/parfume/"), "/")
+	switch {
+	case action == "restore":
+		h.requireAdmin(h.handleRestorePerfume)(w, r)
+	case (action == "photos" || strings.HasPrefix(action, "photos/")) && r.Method == "DELETE":
+		h.requireAdmin(h.handlePerfumePhotos)(w, r)
+	case action == "photos" || strings.HasPrefix(action, "photos/"):
+		h.handlePerfumePhotos(w, r)
+	default:
+		h.handleGetPerfume(w, r)
+	}
 }
 
 // Get single perfume by ID
@@ -1939,23 +3272,23 @@ func (h *Handler) handleGetPerfume(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	path := strings.TrimPrefix(r.URL.Path, "/api/parfume/")
 	if path == "" {
-		http.Error(w, "Perfume ID required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_perfume_id", "Perfume ID required", nil)
 		return
 	}
 
-	perfume, err := h.parfumeRepo.GetByID(path)
+	perfume, err := h.parfumeRepo.GetByID(r.Context(), path)
 	if err != nil {
 		h.logger.Error("Error getting perfume", zap.Error(err))
 		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Perfume not found", http.StatusNotFound)
+			writeJSONError(w, http.StatusNotFound, "perfume_not_found", "Perfume not found", nil)
 		} else {
-			http.Error(w, "Error getting perfume", http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting perfume", nil)
 		}
 		return
 	}
@@ -1973,13 +3306,12 @@ func (h *Handler) handleAddPerfume(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
-	err := r.ParseMultipartForm(10 << 20) // 10 MB limit
-	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+	if err := h.parsePhotoForm(w, r); err != nil {
+		h.writePhotoUploadError(w, err)
 		return
 	}
 
@@ -1989,44 +3321,50 @@ func (h *Handler) handleAddPerfume(w http.ResponseWriter, r *http.Request) {
 	priceStr := r.FormValue("price")
 
 	if name == "" || sex == "" || description == "" || priceStr == "" {
-		http.Error(w, "All fields are required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_fields", "All fields are required", nil)
 		return
 	}
 
 	price, err := strconv.Atoi(priceStr)
 	if err != nil {
-		http.Error(w, "Invalid price", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_price", "Invalid price", nil)
 		return
 	}
 
 	if sex != "Male" && sex != "Female" && sex != "Unisex" {
-		http.Error(w, "Invalid sex value", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_sex", "Invalid sex value", nil)
 		return
 	}
 
-	var photoPath string
-	file, fileHeader, err := r.FormFile("photo")
-	if err == nil {
-		defer file.Close()
+	// Stock is optional; without it the perfume isn't stock-tracked
+	stock, err := parseStockValue(r.FormValue("stock"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_stock", "Invalid stock", nil)
+		return
+	}
 
-		ext := filepath.Ext(fileHeader.Filename)
-		filename := uuid.New().String() + ext
-		photoPath = filename
+	// The legacy "photo" field becomes the primary image, "photos[]" fills the gallery
+	primaryUpload, galleryUploads := photoUploads(r)
+	uploads := galleryUploads
+	if primaryUpload != nil {
+		uploads = append([]*multipart.FileHeader{primaryUpload}, galleryUploads...)
+	}
 
-		dst, err := os.Create(filepath.Join("./photo", filename))
-		if err != nil {
-			h.logger.Error("Error creating photo file", zap.Error(err))
-			http.Error(w, "Error uploading photo", http.StatusInternalServerError)
-			return
-		}
-		defer dst.Close()
+	if err := h.validatePhotoUploads(uploads); err != nil {
+		h.writePhotoUploadError(w, err)
+		return
+	}
 
-		_, err = io.Copy(dst, file)
-		if err != nil {
-			h.logger.Error("Error copying photo file", zap.Error(err))
-			http.Error(w, "Error uploading photo", http.StatusInternalServerError)
-			return
-		}
+	filenames, err := h.savePhotoUploads(uploads)
+	if err != nil {
+		h.logger.Error("Error saving photo files", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "photo_upload_failed", "Error uploading photo", nil)
+		return
+	}
+
+	var photoPath string
+	if len(filenames) > 0 {
+		photoPath = filenames[0]
 	}
 
 	perfume := &repository.Product{
@@ -2035,20 +3373,35 @@ func (h *Handler) handleAddPerfume(w http.ResponseWriter, r *http.Request) {
 		Description: description,
 		Price:       price,
 		PhotoPath:   photoPath,
+		Stock:       stock,
 	}
 
-	err = h.parfumeRepo.Create(perfume)
+	err = h.parfumeRepo.Create(r.Context(), perfume)
 	if err != nil {
 		h.logger.Error("Error creating perfume", zap.Error(err))
-		http.Error(w, "Error creating perfume", http.StatusInternalServerError)
+		h.removePhotoFiles(filenames)
+		writeJSONError(w, http.StatusInternalServerError, "perfume_create_failed", "Error creating perfume", nil)
 		return
 	}
 
+	photos, err := h.photoRepo.Add(r.Context(), perfume.Id, filenames, true)
+	if err != nil {
+		h.logger.Error("Error saving perfume gallery", zap.String("parfume_id", perfume.Id), zap.Error(err))
+	}
+	h.generateGalleryVariants(photos)
+
+	var thumbPath string
+	if len(photos) > 0 {
+		thumbPath = photos[0].ThumbFilename
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Perfume created successfully",
-		"id":      perfume.Id,
+		"message":    "Perfume created successfully",
+		"id":         perfume.Id,
+		"thumb_path": thumbPath,
+		"photos":     photos,
 	})
 }
 
@@ -2061,26 +3414,25 @@ func (h *Handler) handleUpdatePerfume(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "PUT" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	path := strings.TrimPrefix(r.URL.Path, "/api/update-parfume/")
 	if path == "" {
-		http.Error(w, "Perfume ID required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_perfume_id", "Perfume ID required", nil)
 		return
 	}
 
-	existingPerfume, err := h.parfumeRepo.GetByID(path)
+	existingPerfume, err := h.parfumeRepo.GetByID(r.Context(), path)
 	if err != nil {
 		h.logger.Error("Error getting perfume for update", zap.Error(err))
-		http.Error(w, "Perfume not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "perfume_not_found", "Perfume not found", nil)
 		return
 	}
 
-	err = r.ParseMultipartForm(10 << 20)
-	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+	if err := h.parsePhotoForm(w, r); err != nil {
+		h.writePhotoUploadError(w, err)
 		return
 	}
 
@@ -2090,49 +3442,100 @@ func (h *Handler) handleUpdatePerfume(w http.ResponseWriter, r *http.Request) {
 	priceStr := r.FormValue("price")
 
 	if name == "" || sex == "" || description == "" || priceStr == "" {
-		http.Error(w, "All fields are required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_fields", "All fields are required", nil)
 		return
 	}
 
 	price, err := strconv.Atoi(priceStr)
 	if err != nil {
-		http.Error(w, "Invalid price", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_price", "Invalid price", nil)
 		return
 	}
 
 	if sex != "Male" && sex != "Female" && sex != "Unisex" {
-		http.Error(w, "Invalid sex value", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_sex", "Invalid sex value", nil)
+		return
+	}
+
+	// Keep the current stock unless a new value is sent
+	stock := existingPerfume.Stock
+	if stockStr := r.FormValue("stock"); stockStr != "" {
+		stock, err = parseStockValue(stockStr)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_stock", "Invalid stock", nil)
+			return
+		}
+	}
+
+	// A new "photo" replaces the primary image; "photos[]" are appended to the gallery
+	primaryUpload, galleryUploads := photoUploads(r)
+	uploads := galleryUploads
+	if primaryUpload != nil {
+		uploads = append([]*multipart.FileHeader{primaryUpload}, galleryUploads...)
+	}
+
+	if err := h.validatePhotoUploads(uploads); err != nil {
+		h.writePhotoUploadError(w, err)
 		return
 	}
 
-	photoPath := existingPerfume.PhotoPath
-	file, fileHeader, err := r.FormFile("photo")
-	if err == nil {
-		defer file.Close()
+	if primaryUpload != nil {
+		filenames, err := h.savePhotoUploads([]*multipart.FileHeader{primaryUpload})
+		if err != nil {
+			h.logger.Error("Error saving photo file", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "photo_upload_failed", "Error uploading photo", nil)
+			return
+		}
 
-		if existingPerfume.PhotoPath != "" {
-			oldPhotoPath := filepath.Join("./photo", existingPerfume.PhotoPath)
-			os.Remove(oldPhotoPath)
+		oldPrimary, err := h.photoRepo.GetPrimary(r.Context(), existingPerfume.Id)
+		if err != nil {
+			h.logger.Error("Error getting primary photo", zap.Error(err))
+		} else if oldPrimary != nil {
+			if err := h.photoRepo.Delete(r.Context(), oldPrimary.ID); err != nil {
+				h.logger.Error("Error deleting primary photo", zap.Error(err))
+			} else {
+				h.removePhotoFiles(oldPrimary.Files())
+			}
+		} else {
+			h.removePhotoFiles([]string{existingPerfume.PhotoPath})
 		}
 
-		ext := filepath.Ext(fileHeader.Filename)
-		filename := uuid.New().String() + ext
-		photoPath = filename
+		photos, err := h.photoRepo.Add(r.Context(), existingPerfume.Id, filenames, true)
+		if err != nil {
+			h.logger.Error("Error saving primary photo", zap.Error(err))
+			h.removePhotoFiles(filenames)
+			writeJSONError(w, http.StatusInternalServerError, "photo_upload_failed", "Error uploading photo", nil)
+			return
+		}
+		h.generateGalleryVariants(photos)
+	}
 
-		dst, err := os.Create(filepath.Join("./photo", filename))
+	if len(galleryUploads) > 0 {
+		filenames, err := h.savePhotoUploads(galleryUploads)
 		if err != nil {
-			h.logger.Error("Error creating photo file", zap.Error(err))
-			http.Error(w, "Error uploading photo", http.StatusInternalServerError)
+			h.logger.Error("Error saving photo files", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "photo_upload_failed", "Error uploading photo", nil)
 			return
 		}
-		defer dst.Close()
 
-		_, err = io.Copy(dst, file)
+		photos, err := h.photoRepo.Add(r.Context(), existingPerfume.Id, filenames, false)
 		if err != nil {
-			h.logger.Error("Error copying photo file", zap.Error(err))
-			http.Error(w, "Error uploading photo", http.StatusInternalServerError)
+			h.logger.Error("Error saving perfume gallery", zap.Error(err))
+			h.removePhotoFiles(filenames)
+			writeJSONError(w, http.StatusInternalServerError, "photo_upload_failed", "Error uploading photo", nil)
 			return
 		}
+		h.generateGalleryVariants(photos)
+	}
+
+	photoPath, thumbPath := existingPerfume.PhotoPath, existingPerfume.ThumbPath
+	if primaryUpload != nil || len(galleryUploads) > 0 {
+		primary, err := h.photoRepo.GetPrimary(r.Context(), existingPerfume.Id)
+		if err != nil {
+			h.logger.Error("Error getting primary photo", zap.Error(err))
+		} else if primary != nil {
+			photoPath, thumbPath = primary.Filename, primary.ThumbFilename
+		}
 	}
 
 	updatedPerfume := &repository.Product{
@@ -2142,18 +3545,20 @@ func (h *Handler) handleUpdatePerfume(w http.ResponseWriter, r *http.Request) {
 		Description: description,
 		Price:       price,
 		PhotoPath:   photoPath,
+		Stock:       stock,
 	}
 
-	err = h.parfumeRepo.Update(updatedPerfume)
+	err = h.parfumeRepo.Update(r.Context(), updatedPerfume)
 	if err != nil {
 		h.logger.Error("Error updating perfume", zap.Error(err))
-		http.Error(w, "Error updating perfume", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "perfume_update_failed", "Error updating perfume", nil)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Perfume updated successfully",
+		"message":    "Perfume updated successfully",
+		"thumb_path": thumbPath,
 	})
 }
 
@@ -2166,205 +3571,240 @@ func (h *Handler) handleDeletePerfume(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "DELETE" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
 	path := strings.TrimPrefix(r.URL.Path, "/api/delete-parfume/")
 	if path == "" {
-		http.Error(w, "Perfume ID required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "missing_perfume_id", "Perfume ID required", nil)
 		return
 	}
 
-	perfume, err := h.parfumeRepo.GetByID(path)
+	perfume, err := h.parfumeRepo.GetByID(r.Context(), path)
 	if err != nil {
 		h.logger.Error("Error getting perfume for deletion", zap.Error(err))
-		http.Error(w, "Perfume not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "perfume_not_found", "Perfume not found", nil)
 		return
 	}
 
-	err = h.parfumeRepo.Delete(path)
+	// Deleting archives the perfume so historical orders keep resolving it;
+	// permanent removal is the separate purge action
+	err = h.parfumeRepo.Archive(r.Context(), perfume.Id)
 	if err != nil {
-		h.logger.Error("Error deleting perfume", zap.Error(err))
-		http.Error(w, "Error deleting perfume", http.StatusInternalServerError)
-		return
-	}
-
-	if perfume.PhotoPath != "" {
-		photoPath := filepath.Join("./photo", perfume.PhotoPath)
-		err := os.Remove(photoPath)
-		if err != nil {
-			h.logger.Warn("Error deleting photo file", zap.Error(err))
+		h.logger.Error("Error archiving perfume", zap.Error(err))
+		if strings.Contains(err.Error(), "not found") {
+			writeJSONError(w, http.StatusNotFound, "perfume_not_found", "Perfume not found or already archived", nil)
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, "perfume_delete_failed", "Error deleting perfume", nil)
 		}
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Perfume deleted successfully",
+		"message":  "Perfume archived successfully",
+		"archived": true,
 	})
 }
 
-// Search perfumes
-func (h *Handler) handleSearchPerfumes(w http.ResponseWriter, r *http.Request) {
+// Restore an archived perfume
+// POST /api/parfume/{id}/restore
+func (h *Handler) handleRestorePerfume(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
-	query := r.URL.Query().Get("q")
-	sex := r.URL.Query().Get("sex")
-	minPriceStr := r.URL.Query().Get("min_price")
-	maxPriceStr := r.URL.Query().Get("max_price")
-
-	var minPrice, maxPrice int
-	var err error
-
-	if minPriceStr != "" {
-		minPrice, err = strconv.Atoi(minPriceStr)
-		if err != nil {
-			minPrice = 0
-		}
-	}
-
-	if maxPriceStr != "" {
-		maxPrice, err = strconv.Atoi(maxPriceStr)
-		if err != nil {
-			maxPrice = 0
-		}
-	}
-
-	var perfumes []repository.Product
-
-	if query != "" || sex != "" || minPrice > 0 || maxPrice > 0 {
-		perfumes, err = h.parfumeRepo.AdvancedSearch(query, sex, minPrice, maxPrice)
-	} else {
-		perfumes, err = h.parfumeRepo.GetAll()
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/parfume/"), "/restore")
+	if id == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_perfume_id", "Perfume ID required", nil)
+		return
 	}
 
+	err := h.parfumeRepo.Restore(r.Context(), id)
 	if err != nil {
-		h.logger.Error("Error searching perfumes", zap.Error(err))
-		http.Error(w, "Error searching perfumes", http.StatusInternalServerError)
+		h.logger.Error("Error restoring perfume", zap.Error(err))
+		if strings.Contains(err.Error(), "not found") {
+			writeJSONError(w, http.StatusNotFound, "perfume_not_found", "Perfume not found or not archived", nil)
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, "perfume_restore_failed", "Error restoring perfume", nil)
+		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(perfumes)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Perfume restored successfully",
+	})
 }
 
-// Get client data by telegram ID
-func (h *Handler) handleGetClientData(w http.ResponseWriter, r *http.Request) {
+// Permanently remove a perfume and all of its photos
+// DELETE /api/admin/parfume/{id}/purge
+func (h *Handler) handlePurgePerfume(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if r.Method != "DELETE" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
-	var requestData struct {
-		TelegramID int64 `json:"telegram_id"`
+	path := strings.TrimPrefix(r.URL.Path, "/api/admin/parfume/")
+	id, action, found := strings.Cut(path, "/")
+	if !found || action != "purge" {
+		writeJSONError(w, http.StatusNotFound, "not_found", "Unknown admin perfume action", nil)
+		return
 	}
 
-	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if id == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_perfume_id", "Perfume ID required", nil)
+		return
+	}
+
+	perfume, err := h.parfumeRepo.GetByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		h.logger.Error("Error getting perfume for purge", zap.Error(err))
+		writeJSONError(w, http.StatusNotFound, "perfume_not_found", "Perfume not found", nil)
 		return
 	}
 
-	if requestData.TelegramID == 0 {
-		http.Error(w, "Telegram ID required", http.StatusBadRequest)
+	filenames, err := h.photoRepo.DeleteByParfume(r.Context(), perfume.Id)
+	if err != nil {
+		h.logger.Error("Error deleting perfume gallery", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "perfume_delete_failed", "Error deleting perfume", nil)
 		return
 	}
 
-	client, err := h.clientRepo.GetByTelegramID(requestData.TelegramID)
+	err = h.parfumeRepo.Purge(r.Context(), perfume.Id)
 	if err != nil {
-		// Client not found is not an error, just return empty
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"message": "Client not found",
-		})
+		h.logger.Error("Error purging perfume", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "perfume_delete_failed", "Error deleting perfume", nil)
 		return
 	}
 
+	// photo_path normally points at a gallery file, but older rows may not be in the gallery
+	if perfume.PhotoPath != "" && !slices.Contains(filenames, perfume.PhotoPath) {
+		filenames = append(filenames, perfume.PhotoPath)
+	}
+	h.removePhotoFiles(filenames)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"client":  client,
+		"message": "Perfume purged successfully",
 	})
 }
 
-// Save client data
-func (h *Handler) handleSaveClient(w http.ResponseWriter, r *http.Request) {
+// Perfume photo gallery
+// GET /api/parfume/{id}/photos, DELETE /api/parfume/{id}/photos/{photoID}
+func (h *Handler) handlePerfumePhotos(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	path := strings.TrimPrefix(r.URL.Path, "/api/parfume/")
+	id, rest, _ := strings.Cut(path, "/")
+	photoIDStr := strings.TrimPrefix(strings.TrimPrefix(rest, "photos"), "/")
+
+	if id == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_perfume_id", "Perfume ID required", nil)
 		return
 	}
 
-	err := r.ParseMultipartForm(10 << 20)
+	switch {
+	case r.Method == "GET" && photoIDStr == "":
+	case r.Method == "DELETE" && photoIDStr != "":
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	perfume, err := h.parfumeRepo.GetByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		h.logger.Error("Error getting perfume for gallery", zap.Error(err))
+		if strings.Contains(err.Error(), "not found") {
+			writeJSONError(w, http.StatusNotFound, "perfume_not_found", "Perfume not found", nil)
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting perfume", nil)
+		}
 		return
 	}
 
-	telegramIDStr := r.FormValue("telegram_id")
-	fio := r.FormValue("fio")
-	contact := r.FormValue("contact")
-	address := r.FormValue("address")
-	latitude := r.FormValue("latitude")
-	longitude := r.FormValue("longitude")
+	if r.Method == "GET" {
+		photos, err := h.photoRepo.GetByParfume(r.Context(), perfume.Id)
+		if err != nil {
+			h.logger.Error("Error getting perfume photos", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting perfume photos", nil)
+			return
+		}
+		if photos == nil {
+			photos = []repository.ParfumePhoto{}
+		}
 
-	if telegramIDStr == "" || fio == "" || contact == "" || address == "" {
-		http.Error(w, "Required fields missing", http.StatusBadRequest)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"parfume_id": perfume.Id,
+			"photos":     photos,
+			"count":      len(photos),
+		})
 		return
 	}
 
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	photoID, err := strconv.ParseInt(photoIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid telegram ID", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_photo_id", "Invalid photo ID", nil)
 		return
 	}
 
-	client := &domain.Client{
-		TelegramID: telegramID,
-		FIO:        fio,
-		Contact:    contact,
-		Address:    address,
-		Latitude:   latitude,
-		Longitude:  longitude,
+	photo, err := h.photoRepo.GetByID(r.Context(), photoID)
+	if err != nil || photo.ParfumeID != perfume.Id {
+		writeJSONError(w, http.StatusNotFound, "photo_not_found", "Photo not found", nil)
+		return
 	}
 
-	err = h.clientRepo.SaveOrUpdate(client)
-	if err != nil {
-		h.logger.Error("Error saving client", zap.Error(err))
-		http.Error(w, "Error saving client", http.StatusInternalServerError)
+	if err := h.photoRepo.Delete(r.Context(), photo.ID); err != nil {
+		h.logger.Error("Error deleting perfume photo", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "photo_delete_failed", "Error deleting photo", nil)
 		return
 	}
+	h.removePhotoFiles(photo.Files())
+
+	// Keep photo_path pointing at whichever photo is primary now
+	photoPath := ""
+	primary, err := h.photoRepo.GetPrimary(r.Context(), perfume.Id)
+	if err != nil {
+		h.logger.Error("Error getting primary photo", zap.Error(err))
+		photoPath = perfume.PhotoPath
+	} else {
+		if primary != nil {
+			photoPath = primary.Filename
+		}
+		if err := h.parfumeRepo.UpdatePhotoPath(r.Context(), perfume.Id, photoPath); err != nil {
+			h.logger.Error("Error updating perfume photo path", zap.Error(err))
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Client saved successfully",
+		"message":    "Photo deleted successfully",
+		"photo_path": photoPath,
 	})
 }
 
-// Place order
-func (h *Handler) handlePlaceOrder(w http.ResponseWriter, r *http.Request) {
+// Generate resized variants for photos uploaded before thumbnails existed
+// POST /api/admin/photos/thumbnails
+func (h *Handler) handleBackfillThumbnails(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
@@ -2372,168 +3812,286 @@ func (h *Handler) handlePlaceOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
-	err := r.ParseMultipartForm(10 << 20)
+	photos, err := h.photoRepo.GetMissingVariants(r.Context())
 	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		h.logger.Error("Error getting photos without variants", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting photos", nil)
 		return
 	}
 
-	telegramIDStr := r.FormValue("telegram_id")
-	fio := r.FormValue("fio")
-	contact := r.FormValue("contact")
-	address := r.FormValue("address")
-	latitude := r.FormValue("latitude")
-	longitude := r.FormValue("longitude")
-	cartDataStr := r.FormValue("cart_data")
-	totalAmountStr := r.FormValue("total_amount")
-
-	if telegramIDStr == "" || fio == "" || contact == "" || address == "" || cartDataStr == "" || totalAmountStr == "" {
-		http.Error(w, "Required fields missing", http.StatusBadRequest)
-		return
+	generated := 0
+	failed := []string{}
+	for i := range photos {
+		if err := h.generatePhotoVariants(&photos[i]); err != nil {
+			h.logger.Warn("Error generating photo variants",
+				zap.String("filename", photos[i].Filename), zap.Error(err))
+			failed = append(failed, photos[i].Filename)
+			continue
+		}
+		generated++
 	}
 
-	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid telegram ID", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"total":     len(photos),
+		"generated": generated,
+		"failed":    failed,
+	})
+}
+
+// perfumeImportColumns is the header row expected in a catalog import CSV
+var perfumeImportColumns = []string{"name", "sex", "description", "price", "stock", "photo"}
+
+// maxPerfumeImportRows guards against runaway uploads
+const maxPerfumeImportRows = 5000
+
+// Bulk import perfumes from a CSV sent as the "file" multipart field
+// POST /api/admin/parfumes/import[?atomic=1]
+func (h *Handler) handleImportPerfumes(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	totalAmount, err := strconv.Atoi(totalAmountStr)
-	if err != nil {
-		http.Error(w, "Invalid total amount", http.StatusBadRequest)
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
-	// Parse cart data
-	var cartItems []CartItem
-	err = json.Unmarshal([]byte(cartDataStr), &cartItems)
+	atomic := r.URL.Query().Get("atomic") == "1"
+
+	// Read the CSV straight off the multipart stream instead of spooling the form
+	reader, err := r.MultipartReader()
 	if err != nil {
-		http.Error(w, "Invalid cart data", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "invalid_form", "Expected a multipart form with a CSV file", nil)
 		return
 	}
 
-	// Save/update client first
-	client := &domain.Client{
-		TelegramID: telegramID,
-		FIO:        fio,
-		Contact:    contact,
-		Address:    address,
-		Latitude:   latitude,
-		Longitude:  longitude,
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "missing_file", "CSV file is required in the \"file\" field", nil)
+			return
+		}
+		if part.FormName() == "file" {
+			break
+		}
+		part.Close()
 	}
+	defer part.Close()
 
-	err = h.clientRepo.SaveOrUpdate(client)
+	items, rejected, err := parsePerfumeImport(part)
 	if err != nil {
-		h.logger.Error("Error saving client", zap.Error(err))
-		http.Error(w, "Error saving client", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, "invalid_csv", err.Error(), nil)
 		return
 	}
+	total := len(items) + len(rejected)
 
-	// Get saved client to get ID
-	savedClient, err := h.clientRepo.GetByTelegramID(telegramID)
-	if err != nil {
-		h.logger.Error("Error getting saved client", zap.Error(err))
-		http.Error(w, "Error processing order", http.StatusInternalServerError)
-		return
+	var created []repository.Product
+	if !atomic || len(rejected) == 0 {
+		var insertRejected []repository.ProductImportError
+		created, insertRejected, err = h.parfumeRepo.Import(r.Context(), items, atomic)
+		if err != nil {
+			h.logger.Error("Error importing perfumes", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "import_failed", "Error importing perfumes", nil)
+			return
+		}
+		rejected = append(rejected, insertRejected...)
+	}
+	if atomic && len(rejected) > 0 {
+		created = nil
 	}
 
-	// Generate payment link (you can customize this)
-	orderID := fmt.Sprintf("LMN-%d-%d", telegramID, time.Now().Unix())
-	paymentLink := fmt.Sprintf("https://pay.kaspi.kz/pay/%s?amount=%d", orderID, totalAmount)
+	// Register photo files that already sit in ./photo as the primary gallery image
+	for _, product := range created {
+		if product.PhotoPath == "" {
+			continue
+		}
+		photos, err := h.photoRepo.Add(r.Context(), product.Id, []string{product.PhotoPath}, true)
+		if err != nil {
+			h.logger.Error("Error saving imported perfume photo", zap.String("parfume_id", product.Id), zap.Error(err))
+			continue
+		}
+		h.generateGalleryVariants(photos)
+	}
 
-	// Create order
-	order := &domain.Order{
-		ID:     telegramID,
-		IDUser: savedClient.ID,
+	if rejected == nil {
+		rejected = []repository.ProductImportError{}
 	}
+	sort.Slice(rejected, func(i, j int) bool { return rejected[i].Row < rejected[j].Row })
 
-	err = h.orderRepo.Create(order)
-	if err != nil {
-		h.logger.Error("Error creating order", zap.Error(err))
-		http.Error(w, "Error creating order", http.StatusInternalServerError)
-		return
+	ids := make([]string, 0, len(created))
+	for _, product := range created {
+		ids = append(ids, product.Id)
 	}
 
-	// Send order confirmation to Telegram bot
-	go h.sendOrderConfirmation(telegramID, cartItems, totalAmount, paymentLink, orderID)
+	h.logger.Info("Perfumes imported",
+		zap.Int("imported", len(created)),
+		zap.Int("rejected", len(rejected)),
+		zap.Bool("atomic", atomic))
+
+	status := http.StatusOK
+	if len(created) == 0 && len(rejected) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":      true,
-		"message":      "Order placed successfully",
-		"order_id":     orderID,
-		"payment_link": paymentLink,
+		"success":  len(rejected) == 0,
+		"atomic":   atomic,
+		"total":    total,
+		"imported": len(created),
+		"ids":      ids,
+		"errors":   rejected,
 	})
 }
 
-// Send order confirmation via Telegram
-func (h *Handler) sendOrderConfirmation(telegramID int64, cartItems []CartItem, totalAmount int, paymentLink, orderID string) {
-	if h.bot == nil {
-		h.logger.Error("Bot not initialized")
+// Example CSV for the catalog import
+// GET /api/admin/parfumes/import-template
+func (h *Handler) handleImportTemplate(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Build order message
-	var orderText strings.Builder
-	orderText.WriteString("🌟 *Lumen Парфюмерия* - Тапсырыс растауы\n\n")
-	orderText.WriteString(fmt.Sprintf("📦 *Тапсырыс №:* `%s`\n\n", orderID))
-	orderText.WriteString("🛒 *Сіздің тапсырысыңыз:*\n")
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
 
-	for _, item := range cartItems {
-		orderText.WriteString(fmt.Sprintf("• %s\n", item.Name))
-		orderText.WriteString(fmt.Sprintf("  Саны: %d дана\n", item.Quantity))
-		orderText.WriteString(fmt.Sprintf("  Бағасы: %s₸\n", formatPrice(item.Price*item.Quantity)))
-		orderText.WriteString("\n")
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="parfumes_import_template.csv"`)
+
+	writer := csv.NewWriter(w)
+	writer.WriteAll([][]string{
+		perfumeImportColumns,
+		{"Chanel Coco Mademoiselle", "Female", "Шығыс-гүлді хош иіс", "2499", "20", ""},
+		{"Tom Ford Oud Wood", "Unisex", "Ағаш пен амбра ноталары", "2499", "", "oud-wood.jpg"},
+	})
+}
+
+// parsePerfumeImport reads the CSV row by row, returning the rows that passed
+// validation and an error entry for each one that didn't. Row numbers count the
+// header as row 1, like a spreadsheet.
+func parsePerfumeImport(src io.Reader) ([]repository.ProductImport, []repository.ProductImportError, error) {
+	reader := csv.NewReader(src)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CSV header: %w", err)
 	}
 
-	orderText.WriteString("━━━━━━━━━━━━━━━━━━\n")
-	orderText.WriteString(fmt.Sprintf("💰 *Жалпы сома: %s₸*\n\n", formatPrice(totalAmount)))
-	orderText.WriteString("Төлеу үшін төмендегі түймені басыңыз 👇")
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF")))] = i
+	}
+	for _, required := range []string{"name", "sex", "description", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing the %q column", required)
+		}
+	}
 
-	// Create payment keyboard
-	keyboard := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{
-					Text: "💳 Төлеу жасау",
-					URL:  "",
-				},
-			},
-			{
-				{
-					Text: "📞 Қолдау қызметі",
-					URL:  "https://t.me/lumen_support",
-				},
-			},
-		},
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
 	}
 
-	// Send message
-	_, err := h.bot.SendMessage(h.ctx, &bot.SendMessageParams{
-		ChatID:      telegramID,
-		Text:        orderText.String(),
-		ParseMode:   models.ParseModeMarkdown,
-		ReplyMarkup: keyboard,
-	})
+	var items []repository.ProductImport
+	var rejected []repository.ProductImportError
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if row-1 > maxPerfumeImportRows {
+			return nil, nil, fmt.Errorf("CSV has more than %d rows", maxPerfumeImportRows)
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rejected = append(rejected, repository.ProductImportError{Row: row, Message: parseErr.Err.Error()})
+				continue
+			}
+			return nil, nil, fmt.Errorf("error reading CSV: %w", err)
+		}
+
+		product, err := parsePerfumeImportRecord(field, record)
+		if err != nil {
+			rejected = append(rejected, repository.ProductImportError{Row: row, Name: field(record, "name"), Message: err.Error()})
+			continue
+		}
+		items = append(items, repository.ProductImport{Row: row, Product: product})
+	}
+
+	return items, rejected, nil
+}
+
+// parsePerfumeImportRecord validates one CSV record the same way handleAddPerfume
+// validates the form
+func parsePerfumeImportRecord(field func([]string, string) string, record []string) (repository.Product, error) {
+	product := repository.Product{
+		NameParfume: field(record, "name"),
+		Sex:         field(record, "sex"),
+		Description: field(record, "description"),
+		PhotoPath:   field(record, "photo"),
+	}
+
+	if product.NameParfume == "" || product.Sex == "" || product.Description == "" {
+		return product, fmt.Errorf("name, sex and description are required")
+	}
 
+	// Accept any casing of the sex enum
+	switch strings.ToLower(product.Sex) {
+	case "male":
+		product.Sex = "Male"
+	case "female":
+		product.Sex = "Female"
+	case "unisex":
+		product.Sex = "Unisex"
+	default:
+		return product, fmt.Errorf("invalid sex %q: expected Male, Female or Unisex", product.Sex)
+	}
+
+	price, err := strconv.Atoi(field(record, "price"))
+	if err != nil || price <= 0 {
+		return product, fmt.Errorf("price must be a positive whole number")
+	}
+	product.Price = price
+
+	product.Stock, err = parseStockValue(field(record, "stock"))
 	if err != nil {
-		h.logger.Error("Failed to send order confirmation",
-			zap.Error(err),
-			zap.Int64("telegram_id", telegramID),
-			zap.String("order_id", orderID))
-	} else {
-		h.logger.Info("Order confirmation sent successfully",
-			zap.Int64("telegram_id", telegramID),
-			zap.String("order_id", orderID))
+		return product, fmt.Errorf("invalid stock")
+	}
+
+	// The photo must already be uploaded to ./photo under that exact name
+	if product.PhotoPath != "" {
+		if filepath.Base(product.PhotoPath) != product.PhotoPath {
+			return product, fmt.Errorf("photo must be a bare file name")
+		}
+		if _, err := os.Stat(filepath.Join("./photo", product.PhotoPath)); err != nil {
+			return product, fmt.Errorf("photo %s not found", product.PhotoPath)
+		}
 	}
+
+	return product, nil
 }
 
-// Get orders (admin endpoint)
-func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
+// Search perfumes
+func (h *Handler) handleSearchPerfumes(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
@@ -2541,23 +4099,65 @@ func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	sex := r.URL.Query().Get("sex")
+	minPriceStr := r.URL.Query().Get("min_price")
+	maxPriceStr := r.URL.Query().Get("max_price")
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = repository.SortNewest
+	}
+	if !repository.IsValidPerfumeSort(sortBy) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_sort", "Invalid sort", map[string]interface{}{
+			"sort":    sortBy,
+			"allowed": []string{repository.SortNewest, repository.SortPriceAsc, repository.SortPriceDesc, repository.SortNameAsc},
+		})
 		return
 	}
 
-	orders, err := h.orderRepo.GetAll()
+	var minPrice, maxPrice int
+	var err error
+
+	if minPriceStr != "" {
+		minPrice, err = strconv.Atoi(minPriceStr)
+		if err != nil {
+			minPrice = 0
+		}
+	}
+
+	if maxPriceStr != "" {
+		maxPrice, err = strconv.Atoi(maxPriceStr)
+		if err != nil {
+			maxPrice = 0
+		}
+	}
+
+	var perfumes []repository.Product
+
+	// GetAll already lists the newest first
+	if query != "" || sex != "" || minPrice > 0 || maxPrice > 0 || sortBy != repository.SortNewest {
+		perfumes, err = h.parfumeRepo.AdvancedSearch(r.Context(), query, sex, minPrice, maxPrice, includeArchived(r), sortBy)
+	} else {
+		perfumes, err = h.parfumeRepo.GetAll(r.Context(), includeArchived(r))
+	}
+
 	if err != nil {
-		h.logger.Error("Error getting orders", zap.Error(err))
-		http.Error(w, "Error getting orders", http.StatusInternalServerError)
+		h.logger.Error("Error searching perfumes", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error searching perfumes", nil)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orders)
+	json.NewEncoder(w).Encode(perfumes)
 }
 
-// Get single order
-func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+// Get perfumes whose stock is below the threshold (admin)
+func (h *Handler) handleGetLowStock(w http.ResponseWriter, r *http.Request) {
 	h.setCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
@@ -2565,26 +4165,841 @@ func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
 		return
 	}
 
-	path := strings.TrimPrefix(r.URL.Path, "/api/order/")
-	if path == "" {
-		http.Error(w, "Order ID required", http.StatusBadRequest)
-		return
+	threshold := h.cfg.LowStockThreshold
+	if thresholdStr := r.URL.Query().Get("threshold"); thresholdStr != "" {
+		value, err := strconv.Atoi(thresholdStr)
+		if err != nil || value < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_threshold", "Invalid threshold", nil)
+			return
+		}
+		threshold = value
 	}
 
-	orderID, err := strconv.ParseInt(path, 10, 64)
+	perfumes, err := h.parfumeRepo.GetLowStock(r.Context(), threshold)
 	if err != nil {
-		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		h.logger.Error("Error getting low stock perfumes", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting perfumes", nil)
 		return
 	}
 
-	order, err := h.orderRepo.GetByID(orderID)
-	if err != nil {
-		h.logger.Error("Error getting order", zap.Error(err))
-		http.Error(w, "Order not found", http.StatusNotFound)
+	if perfumes == nil {
+		perfumes = []repository.Product{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"threshold": threshold,
+		"count":     len(perfumes),
+		"perfumes":  perfumes,
+	})
+}
+
+// Get client data by telegram ID
+func (h *Handler) handleGetClientData(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	var requestData struct {
+		TelegramID int64 `json:"telegram_id"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
+		return
+	}
+
+	if requestData.TelegramID == 0 {
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "Telegram ID required", nil)
+		return
+	}
+
+	client, err := h.clientRepo.GetByTelegramID(r.Context(), requestData.TelegramID)
+	if errors.Is(err, repository.ErrClientNotFound) {
+		writeJSONError(w, http.StatusNotFound, "client_not_found", "Client not found", nil)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Error getting client", zap.Error(err), zap.Int64("telegram_id", requestData.TelegramID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting client", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"client":  client,
+	})
+}
+
+// Save client data
+func (h *Handler) handleSaveClient(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	err := r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_form", "Error parsing form", nil)
+		return
+	}
+
+	telegramIDStr := r.FormValue("telegram_id")
+	fio := r.FormValue("fio")
+	contact := r.FormValue("contact")
+	address := r.FormValue("address")
+	latitude := r.FormValue("latitude")
+	longitude := r.FormValue("longitude")
+
+	if telegramIDStr == "" || fio == "" || contact == "" || address == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_fields", "Required fields missing", nil)
+		return
+	}
+
+	contact, err = service.NormalizePhone(contact)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_contact", "contact must be a Kazakhstan phone number", nil)
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram ID", nil)
+		return
+	}
+
+	client := &domain.Client{
+		TelegramID: telegramID,
+		FIO:        fio,
+		Contact:    contact,
+		Address:    address,
+		Latitude:   latitude,
+		Longitude:  longitude,
+	}
+
+	err = h.clientRepo.SaveOrUpdate(r.Context(), client)
+	if err != nil {
+		h.logger.Error("Error saving client", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "client_save_failed", "Error saving client", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Client saved successfully",
+	})
+}
+
+// Place order
+func (h *Handler) handlePlaceOrder(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	err := r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_form", "Error parsing form", nil)
+		return
+	}
+
+	telegramIDStr := r.FormValue("telegram_id")
+	fio := r.FormValue("fio")
+	contact := r.FormValue("contact")
+	address := r.FormValue("address")
+	latitude := r.FormValue("latitude")
+	longitude := r.FormValue("longitude")
+	cartDataStr := r.FormValue("cart_data")
+	totalAmountStr := r.FormValue("total_amount")
+
+	if telegramIDStr == "" || fio == "" || contact == "" || address == "" || cartDataStr == "" || totalAmountStr == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_fields", "Required fields missing", nil)
+		return
+	}
+
+	contact, err = service.NormalizePhone(contact)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_contact", "contact must be a Kazakhstan phone number", nil)
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram ID", nil)
+		return
+	}
+
+	// A repeated Idempotency-Key gets the first response back instead of a new order
+	idempotent, handled := h.beginIdempotent(w, r, idempotencyEndpointPlaceOrder, telegramID)
+	if handled {
+		return
+	}
+	if idempotent != nil {
+		w = idempotent
+		defer idempotent.finish()
+	}
+
+	totalAmount, err := strconv.Atoi(totalAmountStr)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_total_amount", "Invalid total amount", nil)
+		return
+	}
+
+	// Parse cart data
+	var cartItems []domain.CartItem
+	err = json.Unmarshal([]byte(cartDataStr), &cartItems)
+	if err != nil || len(cartItems) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_cart_data", "Invalid cart data", nil)
+		return
+	}
+	for _, item := range cartItems {
+		if item.ParfumeID == "" || item.Quantity <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_cart_data", "Every cart item needs an id and a positive quantity", nil)
+			return
+		}
+	}
+
+	// Save/update client first
+	client := &domain.Client{
+		TelegramID: telegramID,
+		FIO:        fio,
+		Contact:    contact,
+		Address:    address,
+		Latitude:   latitude,
+		Longitude:  longitude,
+	}
+
+	err = h.clientRepo.SaveOrUpdate(r.Context(), client)
+	if err != nil {
+		h.logger.Error("Error saving client", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "client_save_failed", "Error saving client", nil)
+		return
+	}
+
+	userName, err := h.clientRepo.GetUserName(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Warn("Error getting user name", zap.Error(err), zap.Int64("telegram_id", telegramID))
+	}
+
+	// The order waits for the Kaspi payment; it is paid once the receipt is accepted
+	quantity := domain.CartQuantity(cartItems)
+	lat, lng := parseCoordinates(latitude, longitude)
+	order := &domain.Order{
+		IDUser:      telegramID,
+		UserName:    userName,
+		Quantity:    &quantity,
+		Parfumes:    domain.FormatCartItems(cartItems),
+		FIO:         fio,
+		Contact:     contact,
+		Address:     address,
+		Latitude:    lat,
+		Longitude:   lng,
+		DataPay:     time.Now().Format("2006-01-02 15:04:05"),
+		Status:      domain.OrderStatusAwaitingPayment,
+		PaymentLink: fmt.Sprintf("%s?amount=%d", h.cfg.KaspiPaymentURL, totalAmount),
+		TotalAmount: totalAmount,
+	}
+
+	err = h.orderRepo.Create(r.Context(), order)
+	if err != nil {
+		h.logger.Error("Error creating order", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "order_create_failed", "Error creating order", nil)
+		return
+	}
+
+	items := make([]domain.OrderItem, 0, len(cartItems))
+	for _, item := range cartItems {
+		items = append(items, item.ToOrderItem(order.ID, item.Quantity))
+	}
+	if err := h.orderItemRepo.AddItems(r.Context(), order.ID, items); err != nil {
+		h.logger.Error("Error saving order items", zap.Error(err), zap.Int64("order_id", order.ID))
+		if err := h.orderRepo.Delete(h.ctx, order.ID); err != nil {
+			h.logger.Error("Error removing order without items", zap.Error(err), zap.Int64("order_id", order.ID))
+		}
+		writeJSONError(w, http.StatusInternalServerError, "order_create_failed", "Error creating order", nil)
+		return
+	}
+	h.metrics.orders.WithLabelValues(orderSourceWeb).Inc()
+	h.publishOrderCreated(order.ID, telegramID, orderSourceWeb, quantity, totalAmount)
+	h.recordOrderEvent(order.ID, domain.OrderEventPerfumeSelected, domain.OrderEventActorUser, map[string]interface{}{
+		"items":        items,
+		"total_amount": totalAmount,
+	})
+
+	// Send order confirmation to Telegram bot
+	go h.sendOrderConfirmation(telegramID, cartItems, totalAmount, order.PaymentLink, order.ID)
+
+	if idempotent != nil {
+		idempotent.resourceID = order.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"message":      "Order placed successfully",
+		"order_id":     order.ID,
+		"status":       order.Status,
+		"payment_link": order.PaymentLink,
+	})
+}
+
+// Send order confirmation via Telegram
+func (h *Handler) sendOrderConfirmation(telegramID int64, cartItems []domain.CartItem, totalAmount int, paymentLink string, orderID int64) {
+	if h.silent {
+		return
+	}
+
+	// Build order message
+	lang := h.userLang(h.ctx, telegramID)
+	var orderText strings.Builder
+	orderText.WriteString(i18n.T(lang, i18n.OrderSummaryTitle, orderID))
+
+	for _, item := range cartItems {
+		orderText.WriteString(i18n.T(lang, i18n.OrderSummaryItem, item.Name, item.Quantity, service.FormatPrice(item.Price*item.Quantity)))
+	}
+
+	orderText.WriteString(i18n.T(lang, i18n.OrderSummaryTotal, service.FormatPrice(totalAmount)))
+
+	// Create payment keyboard
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{
+					Text: i18n.T(lang, i18n.OrderPayButton),
+					URL:  paymentLink,
+				},
+			},
+			{
+				{
+					Text: i18n.T(lang, i18n.SupportButton),
+					URL:  "https://t.me/lumen_support",
+				},
+			},
+		},
+	}
+
+	// Queue message
+	h.enqueueMessage(telegramID, orderID, &bot.SendMessageParams{
+		ChatID:      telegramID,
+		Text:        orderText.String(),
+		ParseMode:   models.ParseModeMarkdown,
+		ReplyMarkup: keyboard,
+	})
+}
+
+// Get orders (admin endpoint)
+func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_filter", err.Error(), nil)
+		return
+	}
+
+	orders, err := h.orderRepo.List(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Error getting orders", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting orders", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orders)
+}
+
+// orderExportLayout is a set of columns the orders spreadsheet can be exported with
+type orderExportLayout struct {
+	filename string
+	header   []string
+	record   func(domain.Order) []string
+}
+
+// orderExportLayouts are the column sets selectable with ?columns=
+var orderExportLayouts = map[string]orderExportLayout{
+	// Accounting: one row per order with the buyer and what was bought
+	"accounting": {
+		filename: "orders",
+		header:   []string{"id", "user", "fio", "contact", "address", "quantity", "parfumes", "gift", "date"},
+		record: func(order domain.Order) []string {
+			user := order.UserName
+			if user == "" {
+				user = strconv.FormatInt(order.IDUser, 10)
+			}
+
+			return []string{
+				strconv.FormatInt(order.ID, 10),
+				user,
+				order.FIO,
+				order.Contact,
+				order.Address,
+				exportQuantity(order),
+				order.Parfumes,
+				order.Gift,
+				order.CreatedAt.Format("2006-01-02 15:04:05"),
+			}
+		},
+	},
+	// Delivery: everything needed to plan the routes
+	"delivery": {
+		filename: "orders_delivery",
+		header: []string{
+			"order_id", "telegram_id", "username", "fio", "contact", "address", "latitude", "longitude",
+			"parfumes", "gift", "quantity", "status", "created_at", "updated_at",
+		},
+		record: func(order domain.Order) []string {
+			latitude, longitude := "", ""
+			if order.Latitude != nil && order.Longitude != nil {
+				latitude = strconv.FormatFloat(*order.Latitude, 'f', -1, 64)
+				longitude = strconv.FormatFloat(*order.Longitude, 'f', -1, 64)
+			}
+
+			return []string{
+				strconv.FormatInt(order.ID, 10),
+				strconv.FormatInt(order.IDUser, 10),
+				order.UserName,
+				order.FIO,
+				order.Contact,
+				order.Address,
+				latitude,
+				longitude,
+				order.Parfumes,
+				order.Gift,
+				exportQuantity(order),
+				string(order.Status),
+				order.CreatedAt.Format("2006-01-02 15:04:05"),
+				order.UpdatedAt.Format("2006-01-02 15:04:05"),
+			}
+		},
+	},
+}
+
+func exportQuantity(order domain.Order) string {
+	if order.Quantity == nil {
+		return ""
+	}
+	return strconv.Itoa(*order.Quantity)
+}
+
+// Export orders as a spreadsheet for accounting or delivery planning.
+// /api/admin/orders/export defaults to the delivery columns, /api/orders/export to the accounting ones.
+// GET /api/orders/export?format=csv&columns=accounting|delivery&status=pending|completed&from=YYYY-MM-DD&to=YYYY-MM-DD
+func (h *Handler) handleExportOrders(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" {
+		writeJSONError(w, http.StatusBadRequest, "unsupported_format", "Only csv export is supported", map[string]interface{}{
+			"format": format,
+		})
+		return
+	}
+
+	columns := r.URL.Query().Get("columns")
+	if columns == "" {
+		columns = "accounting"
+		if r.URL.Path == "/api/admin/orders/export" {
+			columns = "delivery"
+		}
+	}
+	layout, ok := orderExportLayouts[columns]
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "unsupported_columns", "Unknown export columns", map[string]interface{}{
+			"columns": columns,
+		})
+		return
+	}
+
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_filter", err.Error(), nil)
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%s.csv", layout.filename, time.Now().Format("2006-01-02"))
+	rowCount, err := h.writeOrdersCSV(r.Context(), w, filter, filename, layout.header, layout.record)
+
+	// Once the CSV has started streaming, failures can only be logged
+	if err != nil {
+		h.logger.Error("Error exporting orders", zap.String("columns", columns), zap.Int("rows_written", rowCount), zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Orders exported", zap.Int("rows", rowCount), zap.String("format", format), zap.String("columns", columns))
+}
+
+// writeOrdersCSV streams the orders matching filter as a CSV attachment, writing
+// each row as it's scanned. It returns the number of rows written.
+func (h *Handler) writeOrdersCSV(ctx context.Context, w http.ResponseWriter, filter domain.OrderFilter, filename string, header []string, record func(domain.Order) []string) (int, error) {
+	rows, err := h.orderRepo.QueryOrders(ctx, filter)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting orders", nil)
+		return 0, err
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// UTF-8 BOM so Excel opens the Cyrillic/Kazakh text correctly
+	w.Write([]byte("\xEF\xBB\xBF"))
+
+	writer := csv.NewWriter(w)
+	writer.Write(header)
+
+	rowCount := 0
+	for rows.Next() {
+		order, err := repository.ScanOrder(rows)
+		if err != nil {
+			writer.Flush()
+			return rowCount, err
+		}
+
+		writer.Write(record(order))
+
+		// Flush in batches so rows reach the client as they are read
+		rowCount++
+		if rowCount%100 == 0 {
+			writer.Flush()
+			if err := writer.Error(); err != nil {
+				return rowCount, err
+			}
+		}
+	}
+	writer.Flush()
+
+	if err := rows.Err(); err != nil {
+		return rowCount, err
+	}
+	return rowCount, writer.Error()
+}
+
+// Conversion time metrics for marketing
+// GET /api/stats/timings
+func (h *Handler) handleGetTimingStats(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	timings, err := h.orderRepo.GetConversionTimings(r.Context())
+	if err != nil {
+		h.logger.Error("Error getting timing stats", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting timing stats", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"timings": timings,
+	})
+}
+
+// maxStatsDays bounds the range of /api/admin/stats/daily
+const maxStatsDays = 366
+
+// Order stats per day, week or month with revenue, for the admin charts
+// GET /api/admin/stats/daily?days=30&granularity=day|week|month
+func (h *Handler) handleGetDailyStats(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	days := 30
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		value, err := strconv.Atoi(daysStr)
+		if err != nil || value < 1 || value > maxStatsDays {
+			writeJSONError(w, http.StatusBadRequest, "invalid_days",
+				fmt.Sprintf("days must be a number between 1 and %d", maxStatsDays), nil)
+			return
+		}
+		days = value
+	}
+
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = repository.GranularityDay
+	}
+	if !repository.IsValidGranularity(granularity) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_granularity", "Invalid granularity", map[string]interface{}{
+			"granularity": granularity,
+			"allowed":     []string{repository.GranularityDay, repository.GranularityWeek, repository.GranularityMonth},
+		})
+		return
+	}
+
+	stats, err := h.orderRepo.GetPeriodStats(r.Context(), days, granularity)
+	if err != nil {
+		h.logger.Error("Error getting daily stats", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting daily stats", nil)
+		return
+	}
+
+	for i := range stats {
+		stats[i].Revenue = stats[i].TotalQuantity * h.cfg.UnitPrice()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"days":        days,
+		"granularity": granularity,
+		"stats":       stats,
+	})
+}
+
+// Page sizes of /api/admin/clients
+const (
+	defaultClientsPerPage = 20
+	maxClientsPerPage     = 100
+)
+
+// Paying clients with their order counts
+// GET /api/admin/clients?q=&page=1&per_page=20&has_orders=true
+// q matches fio, username or contact; phone numbers match in any format (+7, 8, spaces)
+func (h *Handler) handleGetClients(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	query := r.URL.Query()
+	page, perPage := 1, defaultClientsPerPage
+	if raw := query.Get("page"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_page", "page must be a positive number", nil)
+			return
+		}
+		page = value
+	}
+	if raw := query.Get("per_page"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > maxClientsPerPage {
+			writeJSONError(w, http.StatusBadRequest, "invalid_per_page",
+				fmt.Sprintf("per_page must be a number between 1 and %d", maxClientsPerPage), nil)
+			return
+		}
+		perPage = value
+	}
+
+	filter := domain.ClientFilter{
+		Query:  strings.TrimSpace(query.Get("q")),
+		Limit:  perPage,
+		Offset: (page - 1) * perPage,
+	}
+	if phone := service.PhoneDigits(filter.Query); len(phone) >= 3 {
+		filter.Phone = phone
+	}
+	if raw := query.Get("has_orders"); raw != "" {
+		hasOrders, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_has_orders", "has_orders must be true or false", nil)
+			return
+		}
+		filter.HasOrders = &hasOrders
+	}
+
+	total, err := h.clientRepo.CountSearchClients(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Error counting clients", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	clients, err := h.clientRepo.SearchClients(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Error listing clients", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"clients":     clients,
+		"total":       total,
+		"page":        page,
+		"per_page":    perPage,
+		"total_pages": (total + perPage - 1) / perPage,
+	})
+}
+
+// dashboardCacheTTL is how long the dashboard is served from cache; the admin page polls it
+const dashboardCacheTTL = 60 * time.Second
+
+// Admin dashboard: order stats, users, revenue, prizes and top perfumes
+// GET /api/admin/dashboard
+func (h *Handler) handleGetDashboard(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	// The load is shared by every poller waiting on it, so it isn't tied to this request
+	dashboard, err := h.dashboardCache.GetOrLoad("dashboard", dashboardCacheTTL, func() (*domain.DashboardStats, error) {
+		return h.loadDashboard(h.ctx)
+	})
+	if err != nil {
+		h.logger.Error("Error getting dashboard stats", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Error getting dashboard stats", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"dashboard": dashboard,
+	})
+}
+
+// loadDashboard runs the dashboard aggregations against the database
+func (h *Handler) loadDashboard(ctx context.Context) (*domain.DashboardStats, error) {
+	orderStats, err := h.orderRepo.GetOrderStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &domain.DashboardStats{
+		OrderStatsResponse: *orderStats,
+		GeneratedAt:        time.Now(),
+	}
+
+	if dashboard.TotalUsers, err = h.clientRepo.CountUsers(ctx); err != nil {
+		return nil, fmt.Errorf("count users: %w", err)
+	}
+	if dashboard.PayingClients, err = h.clientRepo.CountClients(ctx); err != nil {
+		return nil, fmt.Errorf("count clients: %w", err)
+	}
+	if dashboard.Revenue, err = h.clientRepo.GetTotalSum(ctx); err != nil {
+		return nil, fmt.Errorf("get revenue: %w", err)
+	}
+	if dashboard.PrizeCounts, err = h.orderRepo.GetPrizeStatistics(ctx); err != nil {
+		return nil, err
+	}
+	if dashboard.PrizeClaims, err = h.orderRepo.GetPrizeClaimStatistics(ctx); err != nil {
+		return nil, err
+	}
+	if dashboard.TopPerfumes, err = h.orderItemRepo.GetTopPerfumes(ctx, 5); err != nil {
+		return nil, err
+	}
+
+	return dashboard, nil
+}
+
+// Get single order
+// handleOrderRoutes dispatches /api/order/{id} and the admin-only /api/order/{id}/status
+func (h *Handler) handleOrderRoutes(w http.ResponseWriter, r *http.Request) {
+	_, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/order/"), "/")
+	if action == "status" {
+		h.requireAdmin(h.handleUpdateOrderStatus)(w, r)
+		return
+	}
+	h.handleGetOrder(w, r)
+}
+
+func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/order/")
+	if path == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_order_id", "Order ID required", nil)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_order_id", "Invalid order ID", nil)
+		return
+	}
+
+	order, err := h.orderRepo.GetByID(r.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Error getting order", zap.Error(err))
+		writeJSONError(w, http.StatusNotFound, "order_not_found", "Order not found", nil)
 		return
 	}
 
@@ -2593,29 +5008,397 @@ func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 }
 
 // Helper functions
-func (h *Handler) setCORSHeaders(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Requested-With")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+// APIError is the error object returned by every API endpoint on failure
+type APIError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// writeJSONError writes {"success":false,"error":{...}} with a stable machine-readable code
+func writeJSONError(w http.ResponseWriter, status int, code, message string, details map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error": APIError{
+			Code:    code,
+			Message: message,
+			Details: details,
+		},
+	})
 }
 
-func formatPrice(price int) string {
-	// Add thousand separators
-	priceStr := strconv.Itoa(price)
-	if len(priceStr) <= 3 {
-		return priceStr
+// includeArchived reports whether the admin asked for archived perfumes too
+func includeArchived(r *http.Request) bool {
+	value := r.URL.Query().Get("include_archived")
+	return value == "1" || value == "true"
+}
+
+// parseStockValue parses an optional stock form value; empty means untracked
+func parseStockValue(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	stock, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	if stock < 0 {
+		return nil, fmt.Errorf("stock must not be negative")
+	}
+	return &stock, nil
+}
+
+// newLotoIDs draws n distinct eight-digit ticket numbers
+func newLotoIDs(n int) []int {
+	seen := make(map[int]bool, n)
+	ids := make([]int, 0, n)
+	for len(ids) < n {
+		id := rand.Intn(90000000) + 10000000
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// cartQuantities sums cart quantities per perfume ID, skipping unknown perfumes
+func cartQuantities(items []domain.CartItem) map[string]int {
+	quantities := make(map[string]int)
+	for _, item := range items {
+		if item.ParfumeID != "" {
+			quantities[item.ParfumeID] += item.Quantity
+		}
+	}
+	return quantities
+}
+
+// parseCoordinates returns the point picked on the address form, or nils
+// unless both coordinates are present and valid
+func parseCoordinates(latitudeStr, longitudeStr string) (*float64, *float64) {
+	latitude, err := strconv.ParseFloat(latitudeStr, 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return nil, nil
+	}
+
+	longitude, err := strconv.ParseFloat(longitudeStr, 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return nil, nil
+	}
+
+	return &latitude, &longitude
+}
+
+// parseOrderFilter reads the order list filters from the query string
+func parseOrderFilter(r *http.Request) (domain.OrderFilter, error) {
+	var filter domain.OrderFilter
+
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case "pending":
+		checks := false
+		filter.Checks = &checks
+	case "completed":
+		checks := true
+		filter.Checks = &checks
+	default:
+		if !domain.OrderStatus(status).IsValid() {
+			return filter, fmt.Errorf("invalid status %q: expected pending, completed or an order status", status)
+		}
+		filter.Status = domain.OrderStatus(status)
+	}
+
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		return filter, err
+	}
+	filter.From, filter.To = from, to
+
+	return filter, nil
+}
+
+// parseDateRange reads the optional ?from=YYYY-MM-DD&to=YYYY-MM-DD, both ends inclusive
+func parseDateRange(r *http.Request) (from, to string, err error) {
+	var fromDate, toDate time.Time
+	if from = r.URL.Query().Get("from"); from != "" {
+		fromDate, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid from date %q: expected YYYY-MM-DD", from)
+		}
+	}
+	if to = r.URL.Query().Get("to"); to != "" {
+		toDate, err = time.Parse("2006-01-02", to)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid to date %q: expected YYYY-MM-DD", to)
+		}
+	}
+	if from != "" && to != "" && fromDate.After(toDate) {
+		return "", "", fmt.Errorf("from date %s is after to date %s", from, to)
+	}
+	return from, to, nil
+}
+
+// maxPhotosPerUpload caps how many images one add/update request may carry
+const maxPhotosPerUpload = 10
+
+// allowedPhotoTypes maps each photo content type that may be accepted to the extension
+// it is stored with; Config.PhotoFormats picks from these. SVG is deliberately absent:
+// it can carry scripts served from /photo/.
+var allowedPhotoTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// acceptsPhotoType reports whether a sniffed content type may be stored as a perfume photo
+func (h *Handler) acceptsPhotoType(contentType string) bool {
+	_, known := allowedPhotoTypes[contentType]
+	return known && slices.Contains(h.cfg.PhotoFormats, contentType)
+}
+
+// photoFormatsLabel lists the accepted photo formats, e.g. "JPG, PNG"
+func (h *Handler) photoFormatsLabel() string {
+	labels := make([]string, 0, len(h.cfg.PhotoFormats))
+	for _, format := range h.cfg.PhotoFormats {
+		if ext, ok := allowedPhotoTypes[format]; ok {
+			labels = append(labels, strings.ToUpper(strings.TrimPrefix(ext, ".")))
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// photoUploadError is a rejected upload reported to the client with its own status
+type photoUploadError struct {
+	status  int
+	code    string
+	message string
+	details map[string]interface{}
+}
+
+func (e *photoUploadError) Error() string {
+	return e.message
+}
+
+func (h *Handler) maxPhotoBytes() int64 {
+	return int64(h.cfg.MaxPhotoSizeMB) << 20
+}
+
+// parsePhotoForm parses a perfume form, capping the body so an oversized upload is
+// cut off while it is read rather than after it has been spooled to disk
+func (h *Handler) parsePhotoForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxPhotoBytes()*maxPhotosPerUpload+1<<20)
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return &photoUploadError{
+				status:  http.StatusRequestEntityTooLarge,
+				code:    "request_too_large",
+				message: "Upload is too large",
+				details: map[string]interface{}{"max_photo_size_mb": h.cfg.MaxPhotoSizeMB},
+			}
+		}
+		return &photoUploadError{
+			status:  http.StatusBadRequest,
+			code:    "invalid_form",
+			message: "Error parsing form",
+		}
+	}
+	return nil
+}
+
+// validatePhotoUploads checks count, size and sniffed content type of every upload
+// before anything is written to ./photo
+func (h *Handler) validatePhotoUploads(uploads []*multipart.FileHeader) error {
+	if len(uploads) > maxPhotosPerUpload {
+		return &photoUploadError{
+			status:  http.StatusBadRequest,
+			code:    "too_many_photos",
+			message: fmt.Sprintf("At most %d photos can be uploaded at once", maxPhotosPerUpload),
+		}
+	}
+
+	for _, fileHeader := range uploads {
+		if fileHeader.Size > h.maxPhotoBytes() {
+			return &photoUploadError{
+				status:  http.StatusRequestEntityTooLarge,
+				code:    "photo_too_large",
+				message: "Photo is too large",
+				details: map[string]interface{}{
+					"filename":          fileHeader.Filename,
+					"max_photo_size_mb": h.cfg.MaxPhotoSizeMB,
+				},
+			}
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			return fmt.Errorf("error opening uploaded photo: %w", err)
+		}
+		contentType, err := sniffPhotoType(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		if !h.acceptsPhotoType(contentType) {
+			return &photoUploadError{
+				status:  http.StatusUnsupportedMediaType,
+				code:    "unsupported_photo_type",
+				message: "Only " + h.photoFormatsLabel() + " photos are accepted",
+				details: map[string]interface{}{
+					"filename":     fileHeader.Filename,
+					"content_type": contentType,
+					"accepted":     h.cfg.PhotoFormats,
+				},
+			}
+		}
+	}
+
+	return nil
+}
+
+// sniffPhotoType detects the content type from the first 512 bytes and rewinds the file
+func sniffPhotoType(file multipart.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("error reading uploaded photo: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("error rewinding uploaded photo: %w", err)
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// writePhotoUploadError reports a rejected upload, or a generic failure for anything else
+func (h *Handler) writePhotoUploadError(w http.ResponseWriter, err error) {
+	var uploadErr *photoUploadError
+	if errors.As(err, &uploadErr) {
+		writeJSONError(w, uploadErr.status, uploadErr.code, uploadErr.message, uploadErr.details)
+		return
+	}
+
+	h.logger.Error("Error validating photo upload", zap.Error(err))
+	writeJSONError(w, http.StatusInternalServerError, "photo_upload_failed", "Error uploading photo", nil)
+}
+
+// photoUploads splits the uploaded images into the legacy single "photo" field and
+// the gallery files sent as "photos[]" (or "photos")
+func photoUploads(r *http.Request) (*multipart.FileHeader, []*multipart.FileHeader) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+
+	var primary *multipart.FileHeader
+	if files := r.MultipartForm.File["photo"]; len(files) > 0 {
+		primary = files[0]
+	}
+
+	gallery := append([]*multipart.FileHeader{}, r.MultipartForm.File["photos[]"]...)
+	gallery = append(gallery, r.MultipartForm.File["photos"]...)
+	return primary, gallery
+}
+
+// savePhotoUploads stores each upload in ./photo under a fresh name; on failure the
+// files already written are removed again
+func (h *Handler) savePhotoUploads(uploads []*multipart.FileHeader) ([]string, error) {
+	filenames := make([]string, 0, len(uploads))
+	for _, fileHeader := range uploads {
+		filename, err := savePhotoUpload(fileHeader)
+		if err != nil {
+			h.removePhotoFiles(filenames)
+			return nil, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, nil
+}
+
+func savePhotoUpload(fileHeader *multipart.FileHeader) (string, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", fmt.Errorf("error opening uploaded photo: %w", err)
+	}
+	defer file.Close()
+
+	// The stored extension follows the sniffed type, never the client's filename
+	contentType, err := sniffPhotoType(file)
+	if err != nil {
+		return "", err
+	}
+	ext, ok := allowedPhotoTypes[contentType]
+	if !ok {
+		return "", fmt.Errorf("unsupported photo type %s", contentType)
+	}
+
+	filename := uuid.New().String() + ext
+	dst, err := os.Create(filepath.Join("./photo", filename))
+	if err != nil {
+		return "", fmt.Errorf("error creating photo file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("error copying photo file: %w", err)
+	}
+	return filename, nil
+}
+
+// generatePhotoVariants writes the resized variants of a gallery photo and records them
+func (h *Handler) generatePhotoVariants(photo *repository.ParfumePhoto) error {
+	variants, err := service.GenerateThumbnails("./photo", photo.Filename)
+	if err != nil {
+		return err
+	}
+
+	thumb, medium := variants[service.ThumbnailSizeThumb], variants[service.ThumbnailSizeMedium]
+	if err := h.photoRepo.SetVariants(h.ctx, photo.ID, thumb, medium); err != nil {
+		h.removePhotoFiles([]string{thumb, medium})
+		return err
+	}
+
+	photo.ThumbFilename = thumb
+	photo.MediumFilename = medium
+	return nil
+}
+
+// generateGalleryVariants creates variants for freshly uploaded photos; a photo that
+// can't be resized is still served in full size, so failures are only logged
+func (h *Handler) generateGalleryVariants(photos []repository.ParfumePhoto) {
+	for i := range photos {
+		if err := h.generatePhotoVariants(&photos[i]); err != nil {
+			h.logger.Warn("Error generating photo variants",
+				zap.String("filename", photos[i].Filename), zap.Error(err))
+		}
 	}
+}
 
-	var result strings.Builder
-	for i, digit := range priceStr {
-		if i > 0 && (len(priceStr)-i)%3 == 0 {
-			result.WriteString(" ")
+// removePhotoFiles deletes photo files from disk, logging anything that can't be removed
+func (h *Handler) removePhotoFiles(filenames []string) {
+	for _, filename := range filenames {
+		if filename == "" {
+			continue
+		}
+		err := os.Remove(filepath.Join("./photo", filename))
+		if err != nil && !os.IsNotExist(err) {
+			h.logger.Warn("Error deleting photo file", zap.String("filename", filename), zap.Error(err))
 		}
-		result.WriteRune(digit)
 	}
+}
 
-	return result.String()
+func (h *Handler) setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Requested-With, X-Admin-Token, Idempotency-Key, X-Telegram-Init-Data")
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
 }
 
 func stringPtr(s string) *string {
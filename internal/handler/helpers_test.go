@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"parfum/config"
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+	"parfum/traits/database"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-telegram/bot"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// testAdminToken is the admin token of the handlers built by newTestHandler
+const testAdminToken = "test-admin-token"
+
+// newTestDB opens a fresh database file with the full schema and every migration applied
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	settings := database.SQLiteSettings{JournalMode: "WAL", BusyTimeout: 5 * time.Second, MaxOpenConns: 1}
+	db, err := sql.Open("sqlite3", database.DSN(filepath.Join(t.TempDir(), "test.db"), settings))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.Configure(db, settings); err != nil {
+		t.Fatalf("configure database: %v", err)
+	}
+	if err := database.CreateTables(db); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	if err := database.MigrateDatabase(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// newTestConfig is the default config with everything written to disk kept in the test's
+// temporary directory
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	cfg.AdminToken = testAdminToken
+	cfg.SavePaymentsDir = t.TempDir()
+	cfg.TelegramSendRate = 1000
+	return cfg
+}
+
+// newTestHandler builds a Handler on a fresh database and an in-process Redis. It runs
+// until the test ends.
+func newTestHandler(t *testing.T) (*Handler, *sql.DB) {
+	t.Helper()
+	return newTestHandlerWithConfig(t, newTestConfig(t))
+}
+
+func newTestHandlerWithConfig(t *testing.T, cfg *config.Config) (*Handler, *sql.DB) {
+	t.Helper()
+
+	db := newTestDB(t)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	h, err := NewHandler(cfg, zap.NewNop(), ctx, db, client)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	return h, db
+}
+
+// seedOrder inserts order, by default a paid order for one kit, and returns it with its ID
+func seedOrder(t *testing.T, db *sql.DB, order domain.Order) domain.Order {
+	t.Helper()
+
+	if order.Quantity == nil {
+		quantity := 1
+		order.Quantity = &quantity
+	}
+	if order.DataPay == "" {
+		order.DataPay = time.Now().Format("2006-01-02 15:04:05")
+	}
+	if err := repository.NewOrderRepository(db).Create(context.Background(), &order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	return order
+}
+
+// setOrderPrize gives an order a prize as if it was won on the wheel just now
+func setOrderPrize(t *testing.T, db *sql.DB, orderID int64, prize string) {
+	t.Helper()
+
+	if _, err := db.Exec(`UPDATE orders SET gift = ?, prize_awarded_at = CURRENT_TIMESTAMP WHERE id = ?`, prize, orderID); err != nil {
+		t.Fatalf("set prize: %v", err)
+	}
+}
+
+// adminRequest is a request carrying the admin token
+func adminRequest(method, target string, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testAdminToken)
+	return r
+}
+
+// formRequest is a multipart POST of fields, with an Idempotency-Key when key is set
+func formRequest(t *testing.T, target, key string, fields map[string]string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for field, value := range fields {
+		if err := form.WriteField(field, value); err != nil {
+			t.Fatalf("write %s: %v", field, err)
+		}
+	}
+	form.Close()
+
+	r := httptest.NewRequest("POST", target, &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+	return r
+}
+
+// decodeJSON decodes a recorded JSON response
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+}
+
+// telegramCall is one request the bot made to the fake Telegram API
+type telegramCall struct {
+	Method string
+	Params map[string]string
+}
+
+// fakeTelegram answers the Bot API methods the handler uses and records every call
+type fakeTelegram struct {
+	bot *bot.Bot
+
+	mu    sync.Mutex
+	calls []telegramCall
+	fail  map[string]int // method -> how many calls to it still fail
+}
+
+// newFakeTelegram starts a fake Bot API and a bot talking to it, built with opts on top
+func newFakeTelegram(t *testing.T, opts ...bot.Option) *fakeTelegram {
+	t.Helper()
+
+	tg := &fakeTelegram{fail: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(tg.serve))
+	t.Cleanup(server.Close)
+
+	opts = append([]bot.Option{bot.WithServerURL(server.URL), bot.WithSkipGetMe()}, opts...)
+	b, err := bot.New("123:test", opts...)
+	if err != nil {
+		t.Fatalf("bot: %v", err)
+	}
+	tg.bot = b
+	return tg
+}
+
+func (tg *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	params := make(map[string]string)
+	if err := r.ParseMultipartForm(32 << 20); err == nil {
+		for key, values := range r.MultipartForm.Value {
+			params[key] = values[0]
+		}
+	}
+
+	tg.mu.Lock()
+	tg.calls = append(tg.calls, telegramCall{Method: method, Params: params})
+	failing := tg.fail[method] > 0
+	if failing {
+		tg.fail[method]--
+	}
+	tg.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if failing {
+		fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: test failure"}`)
+		return
+	}
+
+	switch method {
+	case "getFile":
+		fmt.Fprint(w, `{"ok":true,"result":{"file_id":"file","file_unique_id":"file","file_path":"documents/file.pdf"}}`)
+	case "sendMessage", "sendDocument", "sendPhoto", "sendVideo", "editMessageText":
+		chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":%d,"type":"private"},"text":%q}}`, chatID, params["text"])
+	default:
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	}
+}
+
+// failNext makes the next n calls to method fail with a 400
+func (tg *fakeTelegram) failNext(method string, n int) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	tg.fail[method] = n
+}
+
+// messages returns the texts sent to chatID so far
+func (tg *fakeTelegram) messages(chatID int64) []string {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	var texts []string
+	for _, call := range tg.calls {
+		if call.Method == "sendMessage" && call.Params["chat_id"] == strconv.FormatInt(chatID, 10) {
+			texts = append(texts, call.Params["text"])
+		}
+	}
+	return texts
+}
+
+// count returns how many times method was called
+func (tg *fakeTelegram) count(method string) int {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	n := 0
+	for _, call := range tg.calls {
+		if call.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// waitForMessage waits for a message to chatID containing text; notifications go
+// through the outbox, so they are sent in the background
+func (tg *fakeTelegram) waitForMessage(t *testing.T, chatID int64, text string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, message := range tg.messages(chatID) {
+			if strings.Contains(message, text) {
+				return message
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no message to %d containing %q, got %q", chatID, text, tg.messages(chatID))
+	return ""
+}
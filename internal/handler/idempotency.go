@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Endpoints whose POSTs honour an Idempotency-Key
+const (
+	idempotencyEndpointPlaceOrder    = "order_place"
+	idempotencyEndpointCompletePrize = "prize_complete"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+
+	// A duplicate that arrives while the first request still runs waits for its response
+	idempotencyWaitTimeout  = 10 * time.Second
+	idempotencyPollInterval = 100 * time.Millisecond
+)
+
+// idempotentWriter records the response of a request sent with an Idempotency-Key
+// while passing it through to the client
+type idempotentWriter struct {
+	http.ResponseWriter
+	h          *Handler
+	key        string
+	endpoint   string
+	telegramID int64
+	status     int
+	body       bytes.Buffer
+
+	// resourceID is what the request created, stored next to the response
+	resourceID int64
+}
+
+func (w *idempotentWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotentWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// finish stores a successful response for replays. Any other outcome gives the key
+// up, so the client can retry it after fixing the request.
+func (w *idempotentWriter) finish() {
+	if w.status >= 200 && w.status < 300 {
+		err := w.h.idempotencyRepo.Complete(w.h.ctx, w.key, w.endpoint, w.telegramID, w.status, w.body.Bytes(), w.resourceID)
+		if err != nil {
+			w.h.logger.Error("Failed to save idempotent response", zap.Error(err), zap.String("endpoint", w.endpoint))
+		}
+		return
+	}
+
+	if err := w.h.idempotencyRepo.Release(w.h.ctx, w.key, w.endpoint, w.telegramID); err != nil {
+		w.h.logger.Error("Failed to release idempotency key", zap.Error(err), zap.String("endpoint", w.endpoint))
+	}
+}
+
+// beginIdempotent handles the Idempotency-Key header (or idempotency_key form field) of
+// a POST. A repeat of a finished request gets the stored response and handled is true.
+// Otherwise the caller runs the request, writing to the returned writer and calling
+// its finish once done; the writer is nil when the request carries no key.
+func (h *Handler) beginIdempotent(w http.ResponseWriter, r *http.Request, endpoint string, telegramID int64) (writer *idempotentWriter, handled bool) {
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key == "" {
+		key = strings.TrimSpace(r.FormValue("idempotency_key"))
+	}
+	if key == "" {
+		return nil, false
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		writeJSONError(w, http.StatusBadRequest, "invalid_idempotency_key",
+			fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength), nil)
+		return nil, true
+	}
+
+	deadline := time.Now().Add(idempotencyWaitTimeout)
+	for {
+		record, claimed, err := h.idempotencyRepo.Claim(r.Context(), key, endpoint, telegramID)
+		switch {
+		case err != nil:
+			// Serving the request matters more than deduplicating it
+			h.logger.Warn("Idempotency check failed, running request without it", zap.Error(err), zap.String("endpoint", endpoint))
+			return nil, false
+		case claimed:
+			return &idempotentWriter{ResponseWriter: w, h: h, key: key, endpoint: endpoint, telegramID: telegramID}, false
+		case !record.InProgress():
+			h.logger.Info("Replaying idempotent response",
+				zap.String("endpoint", endpoint),
+				zap.Int64("telegram_id", telegramID),
+				zap.Int64("resource_id", record.ResourceID))
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.StatusCode)
+			w.Write(record.Response)
+			return nil, true
+		}
+
+		if time.Now().After(deadline) {
+			writeJSONError(w, http.StatusConflict, "request_in_progress", "A request with this Idempotency-Key is still being processed", nil)
+			return nil, true
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil, true
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
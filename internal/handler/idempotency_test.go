@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+)
+
+// completePrize posts the prize address form for order with a fresh prize token
+func completePrize(t *testing.T, h *Handler, key string, order domain.Order, prize string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.CompletePrizeOrder(rec, formRequest(t, "/api/prize/complete", key, map[string]string{
+		"telegram_id": strconv.FormatInt(order.IDUser, 10),
+		"order_id":    strconv.FormatInt(order.ID, 10),
+		"fio":         "Айгерим Серікова",
+		"contact":     "+77011234567",
+		"address":     "Алматы, Абая 1",
+		"prize_token": h.signPrizeToken(order.ID, prize, time.Now()),
+	}))
+	return rec
+}
+
+// countRows runs a COUNT(*) query
+func countRows(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("count %q: %v", query, err)
+	}
+	return n
+}
+
+func TestCompletePrizeReplaysSameIdempotencyKey(t *testing.T) {
+	h, db := newTestHandler(t)
+	order := seedOrder(t, db, domain.Order{IDUser: 7101, Parfumes: "Baccarat Rouge: 1"})
+	setOrderPrize(t, db, order.ID, Prize10ML)
+
+	first := completePrize(t, h, "prize-7101", order, Prize10ML)
+	if first.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", first.Code, first.Body.String())
+	}
+	repeat := completePrize(t, h, "prize-7101", order, Prize10ML)
+	if repeat.Code != http.StatusOK || repeat.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("repeat = %d %q, want the first response replayed", repeat.Code, repeat.Body.String())
+	}
+	if repeat.Body.String() != first.Body.String() {
+		t.Errorf("replayed body = %q, want %q", repeat.Body.String(), first.Body.String())
+	}
+
+	events := countRows(t, db, `SELECT COUNT(*) FROM order_events WHERE order_id = ? AND event_type = ?`, order.ID, domain.OrderEventPrizeCompleted)
+	if events != 1 {
+		t.Errorf("prize completed %d times, want once", events)
+	}
+}
+
+func TestIdempotencyKeyIsReleasedWhenRequestFails(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	broken := checkoutForm()
+	broken["total_amount"] = "a lot"
+	if rec := placeOrder(t, h, "checkout-7002", broken); rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+
+	// The fixed request runs instead of replaying the failure
+	rec := placeOrder(t, h, "checkout-7002", checkoutForm())
+	if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry = %d %q, want a new order", rec.Code, rec.Body.String())
+	}
+	if orders := countRows(t, db, `SELECT COUNT(*) FROM orders`); orders != 1 {
+		t.Errorf("orders = %d, want 1", orders)
+	}
+}
+
+func TestConcurrentDuplicatesRunOnce(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	const requests = 5
+	var wg sync.WaitGroup
+	codes := make([]int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h.handlePlaceOrder(rec, formRequest(t, "/api/order/place", "checkout-7003", checkoutForm()))
+			codes[i] = rec.Code
+		}(i)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d: status = %d, want 200", i, code)
+		}
+	}
+	if orders := countRows(t, db, `SELECT COUNT(*) FROM orders`); orders != 1 {
+		t.Errorf("orders = %d, want the checkout placed once", orders)
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// LanguageCommand is the bot command that lets users choose their language again
+const LanguageCommand = "language"
+
+// LanguageCallbackPrefix starts the callback data of the language buttons, e.g. "lang_ru"
+const LanguageCallbackPrefix = "lang_"
+
+// chosenLang is the language the user chose on /start or in the mini app, "" if they
+// never did. The copy in the user's state saves a database read on every message.
+func (h *Handler) chosenLang(ctx context.Context, userID int64) string {
+	state, err := h.redisRepo.GetUserState(ctx, userID)
+	if err == nil && state != nil && state.Lang != "" {
+		return state.Lang
+	}
+
+	lang, err := h.clientRepo.GetPreferredLanguage(ctx, userID)
+	if err != nil {
+		h.logger.Warn("Failed to get preferred language", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	return lang
+}
+
+// userLang is the language to talk to the user in, Kazakh if they never chose one
+func (h *Handler) userLang(ctx context.Context, userID int64) string {
+	return i18n.Normalize(h.chosenLang(ctx, userID))
+}
+
+// askLanguage asks for the user's language in every language, with a button for each
+func (h *Handler) askLanguage(ctx context.Context, b *bot.Bot, chatID int64) {
+	prompts := make([]string, 0, len(i18n.Langs))
+	buttons := make([]models.InlineKeyboardButton, 0, len(i18n.Langs))
+	for _, lang := range i18n.Langs {
+		prompts = append(prompts, i18n.T(lang, i18n.LanguagePrompt))
+		buttons = append(buttons, models.InlineKeyboardButton{
+			Text:         i18n.T(lang, i18n.LanguageButton),
+			CallbackData: LanguageCallbackPrefix + lang,
+		})
+	}
+
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        strings.Join(prompts, " / "),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{buttons}},
+	})
+	if err != nil {
+		h.logger.Warn("Failed to ask for language", zap.Error(err), zap.Int64("chat_id", chatID))
+	}
+}
+
+// LanguageHandler handles /language and the language buttons. A chosen language is kept
+// in just and in the user's state, then the start promo is shown in it.
+func (h *Handler) LanguageHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message != nil {
+		h.askLanguage(ctx, b, update.Message.Chat.ID)
+		return
+	}
+
+	query := update.CallbackQuery
+	if query == nil || !strings.HasPrefix(query.Data, LanguageCallbackPrefix) {
+		return
+	}
+
+	lang := strings.TrimPrefix(query.Data, LanguageCallbackPrefix)
+	if i18n.Normalize(lang) != lang {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID})
+		return
+	}
+
+	userId := query.From.ID
+	if err := h.clientRepo.SetLanguage(ctx, userId, lang); err != nil {
+		h.logger.Error("Failed to save language", zap.Error(err), zap.Int64("user_id", userId))
+	}
+
+	state, err := h.redisRepo.GetUserState(ctx, userId)
+	if err != nil {
+		h.logger.Warn("Failed to get user state", zap.Error(err), zap.Int64("user_id", userId))
+	} else {
+		if state == nil {
+			state = &domain.UserState{State: StateStart}
+		}
+		state.Lang = lang
+		if err := h.redisRepo.SaveUserState(ctx, userId, state); err != nil {
+			h.logger.Warn("Failed to save user state", zap.Error(err), zap.Int64("user_id", userId))
+		}
+	}
+
+	h.logger.Info("User chose language", zap.Int64("user_id", userId), zap.String("lang", lang))
+
+	_, err = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: query.ID,
+		Text:            i18n.T(lang, i18n.LanguageChosen),
+	})
+	if err != nil {
+		h.logger.Warn("Failed to answer callback query", zap.Error(err))
+	}
+
+	// The prompt's buttons are of no use any more
+	if query.Message.Message != nil {
+		_, _ = b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+			ChatID:    query.Message.Message.Chat.ID,
+			MessageID: query.Message.Message.ID,
+		})
+	}
+
+	h.sendPromo(ctx, b, userId, lang)
+}
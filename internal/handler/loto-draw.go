@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+	"parfum/internal/service/i18n"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// DrawCommand is the admin command that draws a winning loto ticket
+const DrawCommand = "draw"
+
+// DrawCallbackPrefix starts the callback data of the button that confirms a draw
+const DrawCallbackPrefix = "draw_"
+
+const drawConfirmData = DrawCallbackPrefix + "confirm"
+
+// drawConfirmTTL is how long a pressed confirmation button keeps a second press from
+// drawing another winner
+const drawConfirmTTL = 24 * time.Hour
+
+// DrawHandler handles /draw from an admin and the button under it. The command only asks
+// for confirmation, since a draw can't be undone; the button draws the winner.
+func (h *Handler) DrawHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message != nil && update.Message.From != nil {
+		adminId := update.Message.From.ID
+		if !slices.Contains(h.receiptAdmins(), adminId) {
+			return
+		}
+
+		count, err := h.clientRepo.CountUndrawnLoto(ctx)
+		if err != nil {
+			h.logger.Error("Failed to count loto tickets", zap.Error(err))
+			h.sendAdminText(ctx, b, adminId, "❌ Билеттерді санау мүмкін болмады, қайталап көріңіз.")
+			return
+		}
+		if count == 0 {
+			h.sendAdminText(ctx, b, adminId, "🎟 Ұтысқа қатысатын билет қалмады.")
+			return
+		}
+
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   fmt.Sprintf("🎟 Ұтысқа %d билет қатысады.\n\nЖеңімпазды анықтаймыз ба? Мұны кері қайтару мүмкін емес.", count),
+			ReplyMarkup: &models.InlineKeyboardMarkup{
+				InlineKeyboard: [][]models.InlineKeyboardButton{
+					{{Text: "🎲 Жеңімпазды анықтау", CallbackData: drawConfirmData}},
+				},
+			},
+		})
+		if err != nil {
+			h.logger.Warn("Failed to send draw confirmation", zap.Error(err))
+		}
+		return
+	}
+
+	query := update.CallbackQuery
+	if query == nil || query.Data != drawConfirmData {
+		return
+	}
+	answer := func(text string) {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID, Text: text})
+	}
+	if !slices.Contains(h.receiptAdmins(), query.From.ID) || query.Message.Message == nil {
+		answer("⛔️")
+		return
+	}
+
+	// A confirmation draws once, however many times its button is pressed
+	message := query.Message.Message
+	started, err := h.redisRepo.StartAlertCooldown(ctx, fmt.Sprintf("loto_draw:%d:%d", message.Chat.ID, message.ID), drawConfirmTTL)
+	if err != nil {
+		h.logger.Warn("Failed to guard loto draw", zap.Error(err))
+		answer("Қате, қайталап көріңіз")
+		return
+	}
+	if !started {
+		answer("Ұтыс өткізіліп қойған")
+		return
+	}
+	answer("")
+
+	if _, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:    message.Chat.ID,
+		MessageID: message.ID,
+	}); err != nil {
+		h.logger.Warn("Failed to remove draw button", zap.Error(err))
+	}
+
+	_, err = h.drawLotoWinner(ctx, fmt.Sprintf("admin %d", query.From.ID))
+	if errors.Is(err, repository.ErrNoLotoTickets) {
+		h.sendAdminText(ctx, b, query.From.ID, "🎟 Ұтысқа қатысатын билет қалмады.")
+	} else if err != nil {
+		h.sendAdminText(ctx, b, query.From.ID, "❌ Ұтысты өткізу мүмкін болмады, қайталап көріңіз.")
+	}
+}
+
+// drawLotoWinner draws a random ticket that hasn't won yet and tells its owner and the
+// admins. drawnBy says who started the draw, for the admins' message.
+func (h *Handler) drawLotoWinner(ctx context.Context, drawnBy string) (*domain.LotoEntry, error) {
+	winner, err := h.clientRepo.DrawRandomLotoWinner(ctx)
+	if errors.Is(err, repository.ErrNoLotoTickets) {
+		return nil, err
+	}
+	if err != nil {
+		h.logger.Error("Failed to draw loto winner", zap.Error(err))
+		return nil, err
+	}
+
+	remaining, err := h.clientRepo.CountUndrawnLoto(ctx)
+	if err != nil {
+		h.logger.Warn("Failed to count loto tickets", zap.Error(err))
+		remaining = -1
+	}
+
+	h.logger.Info("Loto winner drawn",
+		zap.Int("loto_id", winner.LotoID),
+		zap.Int64("user_id", winner.UserID),
+		zap.String("drawn_by", drawnBy),
+		zap.Int("remaining", remaining))
+
+	lang := h.chosenLang(ctx, winner.UserID)
+	h.enqueueMessage(winner.UserID, 0, &bot.SendMessageParams{
+		ChatID: winner.UserID,
+		Text:   i18n.T(lang, i18n.LotoWinner, winner.LotoID),
+	})
+
+	text := lotoWinnerAdminText(winner, drawnBy, remaining)
+	for _, adminID := range h.receiptAdmins() {
+		if adminID != 0 {
+			h.enqueueMessage(adminID, 0, &bot.SendMessageParams{
+				ChatID: adminID,
+				Text:   text,
+			})
+		}
+	}
+
+	return &winner, nil
+}
+
+// lotoWinnerAdminText is the admins' message about a drawn ticket; remaining is -1 when
+// it isn't known
+func lotoWinnerAdminText(winner domain.LotoEntry, drawnBy string, remaining int) string {
+	lines := []string{
+		"🎉 Лото ұтысы өткізілді!",
+		"",
+		fmt.Sprintf("🏆 Жеңімпаз билет: №%d", winner.LotoID),
+		fmt.Sprintf("👤 Қолданушы ID: %d", winner.UserID),
+	}
+	if winner.Fio.Valid && winner.Fio.String != "" {
+		lines = append(lines, "📝 Аты-жөні: "+winner.Fio.String)
+	}
+	if winner.Contact.Valid && winner.Contact.String != "" {
+		lines = append(lines, "📱 Телефон: "+winner.Contact.String)
+	}
+	lines = append(lines, "📅 Төлем күні: "+winner.DatePay)
+	if winner.QR != "" {
+		lines = append(lines, "🧾 Чек: "+winner.QR)
+	}
+	lines = append(lines, "", "🎲 Өткізген: "+drawnBy)
+	if remaining >= 0 {
+		lines = append(lines, fmt.Sprintf("🎟 Ұтысқа қалған билеттер: %d", remaining))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Draw a random winning loto ticket; the winner and the admins are told in the bot
+// POST /api/admin/loto/draw
+func (h *Handler) handleDrawLoto(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	winner, err := h.drawLotoWinner(r.Context(), "API")
+	if errors.Is(err, repository.ErrNoLotoTickets) {
+		writeJSONError(w, http.StatusConflict, "no_loto_tickets", "Every loto ticket has already been drawn", nil)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"winner": map[string]interface{}{
+			"loto_id":     winner.LotoID,
+			"telegram_id": winner.UserID,
+			"qr":          winner.QR,
+			"date_pay":    winner.DatePay,
+			"fio":         winner.Fio.String,
+			"contact":     winner.Contact.String,
+			"address":     winner.Address.String,
+		},
+	})
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+)
+
+func drawLoto(h *Handler) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleDrawLoto(rec, adminRequest("POST", "/api/admin/loto/draw", ""))
+	return rec
+}
+
+// issueLotoTicket records a payment of userID with one ticket
+func issueLotoTicket(t *testing.T, h *Handler, userID int64, lotoID int) {
+	t.Helper()
+
+	qr := fmt.Sprintf("qr-%d", lotoID)
+	payment := domain.PaymentEntry{UserID: userID, UserName: "u", Quantity: 1, Amount: 2499, QR: qr, Receipt: "receipt.pdf", DatePay: "2026-03-01 10:00:00",
+		Tickets: []domain.LotoEntry{{UserID: userID, LotoID: lotoID, QR: qr, Receipt: "receipt.pdf", DatePay: "2026-03-01 10:00:00"}}}
+	if _, _, err := h.clientRepo.RecordPayment(context.Background(), payment); err != nil {
+		t.Fatalf("record payment: %v", err)
+	}
+}
+
+func TestDrawLotoNotifiesWinnerAndAdmins(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	h.SetBot(tg.bot)
+	h.cfg.AdminID, h.cfg.AdminID2 = 8501, 8502
+	const userID = 8511
+
+	if err := h.clientRepo.SetLanguage(context.Background(), userID, i18n.LangKz); err != nil {
+		t.Fatalf("set language: %v", err)
+	}
+	issueLotoTicket(t, h, userID, 77)
+
+	rec := drawLoto(h)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Winner struct {
+			LotoID     int    `json:"loto_id"`
+			TelegramID int64  `json:"telegram_id"`
+			QR         string `json:"qr"`
+		} `json:"winner"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Winner.LotoID != 77 || resp.Winner.TelegramID != userID || resp.Winner.QR != "qr-77" {
+		t.Errorf("winner = %+v, want ticket 77 of %d", resp.Winner, userID)
+	}
+
+	tg.waitForMessage(t, userID, i18n.T(i18n.LangKz, i18n.LotoWinner, 77))
+	for _, adminID := range []int64{8501, 8502} {
+		tg.waitForMessage(t, adminID, "Жеңімпаз билет: №77")
+	}
+
+	// The only ticket has won, so there is nothing left to draw
+	if rec := drawLoto(h); rec.Code != http.StatusConflict {
+		t.Errorf("second draw: status = %d, want 409", rec.Code)
+	}
+}
+
+func TestDrawConfirmationDrawsOnce(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	h.SetBot(tg.bot)
+	h.cfg.AdminID = 8501
+	ctx := context.Background()
+
+	for i := range 3 {
+		issueLotoTicket(t, h, int64(8521+i), 10+i)
+	}
+
+	press := func(from int64) {
+		h.DrawHandler(ctx, tg.bot, &models.Update{CallbackQuery: &models.CallbackQuery{
+			ID:   "press",
+			From: models.User{ID: from},
+			Data: drawConfirmData,
+			Message: models.MaybeInaccessibleMessage{
+				Type:    models.MaybeInaccessibleMessageTypeMessage,
+				Message: &models.Message{ID: 1, Chat: models.Chat{ID: 8501}},
+			},
+		}})
+	}
+
+	press(8599) // not an admin
+	press(8501)
+	press(8501)
+
+	if count, err := h.clientRepo.CountUndrawnLoto(ctx); err != nil || count != 2 {
+		t.Errorf("undrawn tickets = %d, %v; want one drawn", count, err)
+	}
+}
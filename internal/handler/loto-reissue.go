@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"parfum/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// lotoTicketsPerKit is how many loto tickets each paid kit gets
+const lotoTicketsPerKit = 3
+
+// Issue the loto tickets an order is missing, e.g. after a payment whose tickets were
+// only partly saved
+// POST /api/loto/reissue?order_id=
+// Tickets are counted per receipt; an order that already has quantity*3 gets none.
+func (h *Handler) handleReissueLoto(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(r.URL.Query().Get("order_id"), 10, 64)
+	if err != nil || orderID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_order_id", "order_id must be a positive number", nil)
+		return
+	}
+
+	order, err := h.orderRepo.GetByID(r.Context(), orderID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "order_not_found", "Order not found", nil)
+		return
+	}
+	if order.Quantity == nil || *order.Quantity <= 0 {
+		writeJSONError(w, http.StatusUnprocessableEntity, "quantity_unknown", "The order's quantity is unknown, so its ticket count can't be computed", nil)
+		return
+	}
+
+	qr, err := h.orderReceiptQR(r.Context(), order)
+	if err != nil {
+		h.logger.Error("Error finding order receipt", zap.Error(err), zap.Int64("order_id", orderID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+	if qr == "" {
+		writeJSONError(w, http.StatusUnprocessableEntity, "receipt_unknown", "The order has no receipt on record", nil)
+		return
+	}
+
+	expected := *order.Quantity * lotoTicketsPerKit
+	existing, err := h.clientRepo.CountLotoByUserAndReceipt(r.Context(), order.IDUser, qr)
+	if err != nil {
+		h.logger.Error("Error counting loto tickets", zap.Error(err), zap.Int64("order_id", orderID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	issued := []int{}
+	if existing < expected {
+		issued, err = h.clientRepo.TopUpLoto(r.Context(), order.IDUser, qr, order.DataPay, expected, newLotoIDs)
+		if err != nil {
+			h.logger.Error("Error reissuing loto tickets", zap.Error(err), zap.Int64("order_id", orderID))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+			return
+		}
+	}
+
+	if len(issued) > 0 {
+		h.recordOrderEvent(orderID, domain.OrderEventLotoReissued, domain.OrderEventActorAdmin, map[string]interface{}{
+			"qr":      qr,
+			"tickets": issued,
+		})
+		h.logger.Info("Loto tickets reissued",
+			zap.Int64("order_id", orderID),
+			zap.Int64("telegram_id", order.IDUser),
+			zap.Int("issued", len(issued)))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"order_id": orderID,
+		"user_id":  order.IDUser,
+		"expected": expected,
+		"existing": existing,
+		"issued":   issued,
+	})
+}
+
+// orderReceiptQR finds the QR of the receipt an order was paid with: from its
+// payment_received event, or for orders from before the event, from the tickets issued
+// with its payment. It returns "" if neither knows it.
+func (h *Handler) orderReceiptQR(ctx context.Context, order *domain.Order) (string, error) {
+	events, err := h.orderRepo.GetEvents(ctx, order.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, event := range events {
+		if event.EventType != domain.OrderEventPaymentReceived {
+			continue
+		}
+		var payment struct {
+			QR string `json:"qr"`
+		}
+		if json.Unmarshal(event.Payload, &payment) == nil && payment.QR != "" {
+			return payment.QR, nil
+		}
+	}
+
+	if order.DataPay == "" {
+		return "", nil
+	}
+	return h.clientRepo.GetLotoReceipt(ctx, order.IDUser, order.DataPay)
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func reissueLoto(h *Handler, orderID string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleReissueLoto(rec, adminRequest("POST", "/api/loto/reissue?order_id="+orderID, ""))
+	return rec
+}
+
+func TestReissueLotoTopsUpPartialPayment(t *testing.T) {
+	h, db := newTestHandler(t)
+	ctx := context.Background()
+	const userID = 8101
+
+	// Two kits paid, but only 4 of the 6 tickets were saved
+	tickets := make([]domain.LotoEntry, 0, 4)
+	for _, lotoID := range []int{111111, 222222, 333333, 444444} {
+		tickets = append(tickets, domain.LotoEntry{UserID: userID, LotoID: lotoID, QR: "qr-1", Receipt: "./payments/receipt.pdf", DatePay: "2026-03-01 10:00:00"})
+	}
+	orderID, _, err := h.clientRepo.RecordPayment(ctx, domain.PaymentEntry{
+		UserID: userID, UserName: "aigerim", Quantity: 2, Amount: 4998, QR: "qr-1",
+		Receipt: "./payments/receipt.pdf", DatePay: "2026-03-01 10:00:00", Tickets: tickets,
+	})
+	if err != nil {
+		t.Fatalf("RecordPayment: %v", err)
+	}
+
+	rec := reissueLoto(h, fmt.Sprint(orderID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Expected int   `json:"expected"`
+		Existing int   `json:"existing"`
+		Issued   []int `json:"issued"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Expected != 6 || resp.Existing != 4 || len(resp.Issued) != 2 {
+		t.Fatalf("response = %+v, want 2 of 6 tickets issued", resp)
+	}
+
+	all, err := h.clientRepo.GetLotoByUser(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetLotoByUser: %v", err)
+	}
+	if len(all) != 6 {
+		t.Errorf("tickets = %d, want 6", len(all))
+	}
+	for _, ticket := range all {
+		if slices.Contains(resp.Issued, ticket.LotoID) && (ticket.QR != "qr-1" || ticket.Receipt != "./payments/receipt.pdf") {
+			t.Errorf("reissued ticket = %+v, want it on the paid receipt", ticket)
+		}
+	}
+	if n := countRows(t, db, `SELECT COUNT(*) FROM order_events WHERE order_id = ? AND event_type = ?`, orderID, domain.OrderEventLotoReissued); n != 1 {
+		t.Errorf("loto_reissued events = %d, want 1", n)
+	}
+
+	// A full order gets nothing more
+	rec = reissueLoto(h, fmt.Sprint(orderID))
+	decodeJSON(t, rec, &resp)
+	if rec.Code != http.StatusOK || resp.Existing != 6 || len(resp.Issued) != 0 {
+		t.Errorf("second reissue = %d %+v, want nothing issued", rec.Code, resp)
+	}
+}
+
+func TestReissueLotoRejects(t *testing.T) {
+	h, db := newTestHandler(t)
+	ctx := context.Background()
+
+	unknownQuantity, _, err := h.clientRepo.RecordPayment(ctx, domain.PaymentEntry{
+		UserID: 8111, UserName: "dana", Amount: 2499, QR: "qr-2", DatePay: "2026-03-01 10:00:00",
+	})
+	if err != nil {
+		t.Fatalf("RecordPayment: %v", err)
+	}
+	noReceipt := seedOrder(t, db, domain.Order{IDUser: 8112})
+
+	for _, tt := range []struct {
+		orderID string
+		status  int
+	}{
+		{"abc", http.StatusBadRequest},
+		{"0", http.StatusBadRequest},
+		{"99999", http.StatusNotFound},
+		{fmt.Sprint(unknownQuantity), http.StatusUnprocessableEntity},
+		{fmt.Sprint(noReceipt.ID), http.StatusUnprocessableEntity},
+	} {
+		if rec := reissueLoto(h, tt.orderID); rec.Code != tt.status {
+			t.Errorf("order %s: status = %d, want %d: %s", tt.orderID, rec.Code, tt.status, rec.Body.String())
+		}
+	}
+}
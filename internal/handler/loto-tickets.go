@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// A user's loto ticket numbers, with the payment each was issued for
+// GET /api/loto?telegram_id=
+// Only what the user may see of their own tickets is returned, not the receipt file or
+// delivery details.
+func (h *Handler) handleGetLotoTickets(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	telegramIDStr := r.URL.Query().Get("telegram_id")
+	if telegramIDStr == "" {
+		writeJSONError(w, http.StatusBadRequest, "missing_telegram_id", "telegram_id parameter required", nil)
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(telegramIDStr, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
+		return
+	}
+
+	entries, err := h.clientRepo.GetLotoByUser(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Error("Error getting loto tickets", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	tickets := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		tickets = append(tickets, map[string]interface{}{
+			"number":   entry.LotoID,
+			"date_pay": entry.DatePay,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"telegram_id": telegramID,
+		"count":       len(tickets),
+		"tickets":     tickets,
+	})
+}
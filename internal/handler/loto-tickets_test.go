@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func getLotoTickets(h *Handler, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleGetLotoTickets(rec, httptest.NewRequest("GET", "/api/loto"+query, nil))
+	return rec
+}
+
+func TestLotoTicketsOfUser(t *testing.T) {
+	h, _ := newTestHandler(t)
+	const userID = 8401
+
+	payment := domain.PaymentEntry{UserID: userID, UserName: "u", Quantity: 2, Amount: 4998, QR: "qr-loto", Receipt: "files/receipt.pdf", DatePay: "2026-03-01 10:00:00"}
+	for _, lotoID := range []int{305, 17} {
+		payment.Tickets = append(payment.Tickets, domain.LotoEntry{UserID: userID, LotoID: lotoID, QR: payment.QR, Receipt: payment.Receipt, DatePay: payment.DatePay})
+	}
+	if _, _, err := h.clientRepo.RecordPayment(context.Background(), payment); err != nil {
+		t.Fatalf("record payment: %v", err)
+	}
+
+	rec := getLotoTickets(h, "?telegram_id=8401")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "receipt") {
+		t.Errorf("response = %s, want no receipt file", rec.Body.String())
+	}
+
+	var resp struct {
+		Count   int `json:"count"`
+		Tickets []struct {
+			Number  int    `json:"number"`
+			DatePay string `json:"date_pay"`
+		} `json:"tickets"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Count != 2 || len(resp.Tickets) != 2 {
+		t.Fatalf("tickets = %+v, want 2", resp)
+	}
+	for i, number := range []int{305, 17} {
+		if ticket := resp.Tickets[i]; ticket.Number != number || ticket.DatePay != payment.DatePay {
+			t.Errorf("ticket %d = %+v, want number %d paid %s", i, ticket, number, payment.DatePay)
+		}
+	}
+
+	rec = getLotoTickets(h, "?telegram_id=8402")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"tickets":[]`) {
+		t.Errorf("user without tickets: status = %d: %s; want an empty list", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLotoTicketsRejectsBadTelegramID(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, query := range []string{"", "?telegram_id=abc"} {
+		if rec := getLotoTickets(h, query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/service"
+	"parfum/internal/service/i18n"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// Callback data of the admin buttons under a receipt sent for manual review,
+// followed by the manual payment id
+const (
+	ManualPaymentCallbackPrefix = "manual_"
+	manualPaymentApprovePrefix  = "manual_approve_"
+	manualPaymentRejectPrefix   = "manual_reject_"
+)
+
+// requestManualReview sends a receipt the bot couldn't read to the admins with buttons to
+// approve or reject it. The payment is stored first, so the buttons keep working after a
+// restart. reason says why the receipt didn't go through automatically.
+func (h *Handler) requestManualReview(ctx context.Context, b *bot.Bot, userId int64, lang string, state *domain.UserState, receipt *domain.PendingReceipt, reason string) {
+	// Without a chosen count there is nothing for an admin to approve
+	if state == nil || state.Count <= 0 {
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: userId,
+			Text:   i18n.T(lang, i18n.ReceiptUnreadable),
+		})
+		return
+	}
+
+	amount, err := h.expectedAmount(state)
+	if err != nil {
+		h.logger.Error("Failed to price count", zap.Error(err))
+		return
+	}
+
+	id, err := h.clientRepo.CreateManualPayment(ctx, domain.ManualPayment{
+		UserID:   userId,
+		Quantity: state.Count,
+		Amount:   amount,
+		FilePath: receipt.FilePath,
+		FileName: receipt.FileName,
+		Reason:   reason,
+	})
+	if err != nil {
+		h.logger.Error("Failed to save manual payment", zap.Error(err), zap.Int64("user_id", userId))
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: userId,
+			Text:   i18n.T(lang, i18n.ReceiptRetry),
+		})
+		return
+	}
+	h.metrics.payments.WithLabelValues(paymentResultManualReview).Inc()
+
+	f, err := os.Open(receipt.FilePath)
+	if err != nil {
+		h.logger.Error("Failed to open file on disk", zap.Error(err))
+		return
+	}
+	defer f.Close()
+
+	caption := fmt.Sprintf(
+		"🔎 Чекті қолмен тексеру керек №%d\n\n"+
+			"👤 UserId: %d\n"+
+			"🧴 Косметика саны: %d\n"+
+			"💰 Күтілетін сумма: %d ₸\n"+
+			"⚠️ Себебі: %s",
+		id, userId, state.Count, amount, reason)
+	buttons := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "✅ Растау", CallbackData: fmt.Sprintf("%s%d", manualPaymentApprovePrefix, id)},
+				{Text: "❌ Қабылдамау", CallbackData: fmt.Sprintf("%s%d", manualPaymentRejectPrefix, id)},
+			},
+		},
+	}
+	for _, admin := range h.receiptAdmins() {
+		err := service.SendWithRetry(ctx, telegramSendAttempts, func(ctx context.Context) error {
+			// Every attempt uploads the receipt from the start
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				h.logger.Error("Failed to seek file to start", zap.Error(err))
+			}
+
+			_, err := b.SendDocument(ctx, &bot.SendDocumentParams{
+				ChatID: admin,
+				Document: &models.InputFileUpload{
+					Filename: receipt.FileName,
+					Data:     f,
+				},
+				Caption:     caption,
+				ReplyMarkup: buttons,
+			})
+			return err
+		})
+		if err != nil {
+			h.logger.Error("Failed to send receipt for manual review", zap.Error(err), zap.Int64("admin_id", admin))
+		}
+	}
+
+	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: userId,
+		Text:   i18n.T(lang, i18n.ReceiptManualReview),
+	})
+}
+
+// receiptAdmins are the admins who get receipts and may decide manual payments
+func (h *Handler) receiptAdmins() []int64 {
+	return []int64{h.cfg.AdminID, h.cfg.AdminID2}
+}
+
+// ManualPaymentHandler handles an admin pressing approve or reject under a receipt sent
+// for manual review. Approving records the payment like an automatically validated
+// receipt and asks the user for their contact; rejecting asks the user for a new receipt.
+func (h *Handler) ManualPaymentHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	if query == nil {
+		return
+	}
+
+	answer := func(text string) {
+		if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: query.ID,
+			Text:            text,
+		}); err != nil {
+			h.logger.Warn("Failed to answer callback query", zap.Error(err))
+		}
+	}
+
+	if !slices.Contains(h.receiptAdmins(), query.From.ID) {
+		answer("⛔️")
+		return
+	}
+
+	var status, rawID string
+	switch {
+	case strings.HasPrefix(query.Data, manualPaymentApprovePrefix):
+		status, rawID = domain.ManualPaymentApproved, strings.TrimPrefix(query.Data, manualPaymentApprovePrefix)
+	case strings.HasPrefix(query.Data, manualPaymentRejectPrefix):
+		status, rawID = domain.ManualPaymentRejected, strings.TrimPrefix(query.Data, manualPaymentRejectPrefix)
+	default:
+		answer("")
+		return
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		answer("")
+		return
+	}
+
+	payment, err := h.clientRepo.GetManualPayment(ctx, id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.logger.Error("Failed to get manual payment", zap.Error(err), zap.Int64("id", id))
+		}
+		answer("Төлем табылмады")
+		return
+	}
+
+	// Deciding first makes two admins pressing at once act only once
+	decided, err := h.clientRepo.DecideManualPayment(ctx, id, status, query.From.ID)
+	if err != nil {
+		h.logger.Error("Failed to decide manual payment", zap.Error(err), zap.Int64("id", id))
+		answer("Қате, қайталап көріңіз")
+		return
+	}
+	if !decided {
+		answer("Бұл чек бұрын тексерілген")
+		return
+	}
+
+	lang := h.userLang(ctx, payment.UserID)
+	if status == domain.ManualPaymentRejected {
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: payment.UserID,
+			Text:   i18n.T(lang, i18n.ReceiptRejected),
+		})
+		h.markManualPaymentDecided(ctx, b, query, "❌ Қабылданбады")
+		answer("Қабылданбады")
+		return
+	}
+
+	state, err := h.redisRepo.GetUserState(ctx, payment.UserID)
+	if err != nil || state == nil {
+		state = &domain.UserState{}
+	}
+	state.Count = payment.Quantity
+	receipt := &domain.PendingReceipt{
+		FilePath:    payment.FilePath,
+		FileName:    payment.FileName,
+		ActualPrice: payment.Amount,
+		// A manual receipt has no QR read from it; its id keeps it from being paid out twice
+		Qr: fmt.Sprintf("manual:%d", payment.ID),
+	}
+	if !h.acceptPayment(ctx, b, payment.UserID, payment.UserID, lang, state, receipt) {
+		if err := h.clientRepo.ReopenManualPayment(ctx, id); err != nil {
+			h.logger.Error("Failed to reopen manual payment", zap.Error(err), zap.Int64("id", id))
+		}
+		answer("Төлемді сақтау мүмкін болмады")
+		return
+	}
+
+	h.askForContact(ctx, b, payment.UserID, lang)
+	h.markManualPaymentDecided(ctx, b, query, "✅ Расталды")
+	answer("Расталды")
+}
+
+// markManualPaymentDecided removes the buttons from the admin's receipt message and
+// notes the decision in its caption
+func (h *Handler) markManualPaymentDecided(ctx context.Context, b *bot.Bot, query *models.CallbackQuery, decision string) {
+	message := query.Message.Message
+	if message == nil {
+		return
+	}
+
+	caption := fmt.Sprintf("%s\n\n%s (%d, %s)", message.Caption, decision, query.From.ID, time.Now().Format("2006-01-02 15:04:05"))
+	if _, err := b.EditMessageCaption(ctx, &bot.EditMessageCaptionParams{
+		ChatID:      message.Chat.ID,
+		MessageID:   message.ID,
+		Caption:     caption,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{}},
+	}); err != nil {
+		h.logger.Warn("Failed to update manual payment message", zap.Error(err))
+	}
+}
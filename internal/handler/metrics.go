@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Values of the result label of parfum_payments_total
+const (
+	paymentResultValid        = "valid"
+	paymentResultWrongPrice   = "wrong_price"
+	paymentResultWrongBin     = "wrong_bin"
+	paymentResultInvalid      = "invalid"
+	paymentResultDuplicate    = "duplicate"
+	paymentResultManualReview = "manual_review"
+)
+
+// Values of the source label of parfum_orders_total
+const (
+	orderSourceBot = "bot"
+	orderSourceWeb = "web"
+)
+
+// handlerMetrics are the business metrics served on /metrics
+type handlerMetrics struct {
+	registry     *prometheus.Registry
+	orders       *prometheus.CounterVec
+	spins        *prometheus.CounterVec
+	payments     *prometheus.CounterVec
+	receiptParse prometheus.Histogram
+}
+
+func newHandlerMetrics() *handlerMetrics {
+	m := &handlerMetrics{
+		registry: prometheus.NewRegistry(),
+		orders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "parfum_orders_total",
+			Help: "Orders created, by where they were placed.",
+		}, []string{"source"}),
+		spins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "parfum_spins_total",
+			Help: "Prize wheel spins, by the prize won.",
+		}, []string{"prize"}),
+		payments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "parfum_payments_total",
+			Help: "Receipts checked, by the outcome of the check.",
+		}, []string{"result"}),
+		receiptParse: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "parfum_receipt_parse_duration_seconds",
+			Help:    "Time spent reading a receipt file.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+
+	// A registry of our own so tests can build several handlers in one process
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.orders,
+		m.spins,
+		m.payments,
+		m.receiptParse,
+	)
+	return m
+}
+
+// handler serves the metrics to a Prometheus scrape
+func (m *handlerMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
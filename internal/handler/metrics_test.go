@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"parfum/internal/domain"
+)
+
+// counterValue reads the current value of a counter
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	t.Helper()
+
+	var metric dto.Metric
+	if err := counter.Write(&metric); err != nil {
+		t.Fatalf("read counter: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestOrderAndSpinCountersMove(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	if rec := placeOrder(t, h, "", checkoutForm()); rec.Code != http.StatusOK {
+		t.Fatalf("place order: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := counterValue(t, h.metrics.orders.WithLabelValues(orderSourceWeb)); got != 1 {
+		t.Errorf("web orders = %v, want 1", got)
+	}
+
+	seedOrder(t, db, domain.Order{IDUser: 7101, Parfumes: "Baccarat Rouge: 1"})
+	rec := httptest.NewRecorder()
+	h.SpinWheel(rec, httptest.NewRequest("POST", "/api/prize/spin", strings.NewReader(`{"telegram_id": 7101}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("spin: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := counterValue(t, h.metrics.spins.WithLabelValues(Prize10ML)); got != 1 {
+		t.Errorf("10ml spins = %v, want 1", got)
+	}
+}
+
+func TestPaymentCountersMove(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	ctx := context.Background()
+	const userID = 7201
+
+	receiptPath := filepath.Join(t.TempDir(), "receipt.pdf")
+	if err := os.WriteFile(receiptPath, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatalf("write receipt: %v", err)
+	}
+	price := h.cfg.UnitPrice()
+
+	payments := []struct {
+		name    string
+		receipt domain.PendingReceipt
+		result  string
+	}{
+		{"wrong price", domain.PendingReceipt{ActualPrice: price - 1, Bin: h.cfg.Bin, Qr: "qr-1"}, paymentResultWrongPrice},
+		{"wrong BIN", domain.PendingReceipt{ActualPrice: price, Bin: 123, Qr: "qr-1"}, paymentResultWrongBin},
+		{"no QR", domain.PendingReceipt{ActualPrice: price, Bin: h.cfg.Bin}, paymentResultInvalid},
+		{"valid", domain.PendingReceipt{ActualPrice: price, Bin: h.cfg.Bin, Qr: "qr-1"}, paymentResultValid},
+		{"same receipt again", domain.PendingReceipt{ActualPrice: price, Bin: h.cfg.Bin, Qr: "qr-1"}, paymentResultDuplicate},
+	}
+	for _, payment := range payments {
+		before := counterValue(t, h.metrics.payments.WithLabelValues(payment.result))
+
+		receipt := payment.receipt
+		receipt.FilePath, receipt.FileName = receiptPath, "receipt.pdf"
+		state := &domain.UserState{State: StateCount, Count: 1, Amount: price}
+		h.finalizeReceipt(ctx, tg.bot, userID, userID, state, &receipt)
+
+		if got := counterValue(t, h.metrics.payments.WithLabelValues(payment.result)); got != before+1 {
+			t.Errorf("%s: payments{result=%s} = %v, want %v", payment.name, payment.result, got, before+1)
+		}
+	}
+
+	if got := counterValue(t, h.metrics.orders.WithLabelValues(orderSourceBot)); got != 1 {
+		t.Errorf("bot orders = %v, want 1 for the valid payment", got)
+	}
+
+	families, err := h.metrics.registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == "parfum_payments_total" && len(family.GetMetric()) != len(payments) {
+			t.Errorf("payment results = %d, want %d", len(family.GetMetric()), len(payments))
+		}
+	}
+}
+
+func TestMetricsEndpointServesCounters(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.metrics.spins.WithLabelValues(PrizeDiamond).Inc()
+	h.metrics.receiptParse.Observe(0.2)
+
+	rec := httptest.NewRecorder()
+	h.metrics.handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	for _, line := range []string{
+		`parfum_spins_total{prize="diamond_ring"} 1`,
+		`parfum_receipt_parse_duration_seconds_count 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Errorf("metrics have no %q", line)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// RequestIDHeader is the response header carrying the request ID
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDFromContext returns the request ID set by the logging middleware
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// responseWriter records the status code and size written by a handler
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += n
+	return n, err
+}
+
+// Flush keeps streaming responses working through the wrapper
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// loggingMiddleware tags every request with an ID and logs its outcome
+func (h *Handler) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := uuid.New().String()
+
+		w.Header().Set(RequestIDHeader, requestID)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, requestID))
+
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		h.logger.Info("HTTP request",
+			zap.String("request_id", requestID),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", status),
+			zap.Int("bytes", rw.size),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("remote_addr", r.RemoteAddr))
+	})
+}
+
+// requireAdmin lets a request through only with the configured admin token, sent as
+// "Authorization: Bearer <token>" or "X-Admin-Token: <token>". Without a configured
+// token the route is closed. CORS preflights pass so the handler can answer them.
+func (h *Handler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			next(w, r)
+			return
+		}
+
+		h.setCORSHeaders(w)
+		if h.cfg.AdminToken == "" {
+			writeJSONError(w, http.StatusServiceUnavailable, "admin_auth_not_configured", "Admin API token is not configured", nil)
+			return
+		}
+
+		token := r.Header.Get("X-Admin-Token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.AdminToken)) != 1 {
+			h.logger.Warn("Rejected admin request",
+				zap.String("request_id", RequestIDFromContext(r.Context())),
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr))
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Admin token required", nil)
+			return
+		}
+
+		next(w, r)
+	}
+}
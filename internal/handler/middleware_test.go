@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggingMiddlewareRequestID(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	var seen string
+	handler := h.loggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/parfumes", nil))
+
+	if seen == "" {
+		t.Fatal("handler saw no request ID in the context")
+	}
+	if got := rec.Header().Get(RequestIDHeader); got != seen {
+		t.Errorf("%s header = %q, want the ID the handler saw, %q", RequestIDHeader, got, seen)
+	}
+}
+
+func TestLoggingMiddlewareLogsStatus(t *testing.T) {
+	h, _ := newTestHandler(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	h.logger = zap.New(core)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		bytes   int
+	}{
+		{"explicit status", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			w.WriteHeader(http.StatusInternalServerError) // ignored, as by net/http
+			w.Write([]byte("short"))
+		}, http.StatusTeapot, 5},
+		{"implicit 200", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}, http.StatusOK, 2},
+		{"nothing written", func(w http.ResponseWriter, r *http.Request) {}, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		logs.TakeAll()
+
+		rec := httptest.NewRecorder()
+		h.loggingMiddleware(tt.handler).ServeHTTP(rec, httptest.NewRequest("POST", "/api/order/place", nil))
+
+		entries := logs.FilterMessage("HTTP request").AllUntimed()
+		if len(entries) != 1 {
+			t.Fatalf("%s: got %d request logs, want 1", tt.name, len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["status"] != int64(tt.status) || fields["bytes"] != int64(tt.bytes) {
+			t.Errorf("%s: logged status %v and %v bytes, want %d and %d", tt.name, fields["status"], fields["bytes"], tt.status, tt.bytes)
+		}
+		if fields["method"] != "POST" || fields["path"] != "/api/order/place" {
+			t.Errorf("%s: logged %v %v, want POST /api/order/place", tt.name, fields["method"], fields["path"])
+		}
+		if fields["request_id"] != rec.Header().Get(RequestIDHeader) {
+			t.Errorf("%s: logged request ID %v, response has %q", tt.name, fields["request_id"], rec.Header().Get(RequestIDHeader))
+		}
+	}
+}
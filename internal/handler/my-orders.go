@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// MyOrdersCommand is the bot command that lists the user's orders and lottery tickets
+const MyOrdersCommand = "myorders"
+
+// MyOrdersCallbackPrefix is the callback data of the /myorders page buttons, followed by the page
+const MyOrdersCallbackPrefix = "myorders_"
+
+// miniAppLink opens the perfume mini app inside Telegram
+const miniAppLink = "https://t.me/zhad_parfume_bot/ZhadParfume"
+
+const (
+	myOrdersPageSize = 5
+	// myOrdersMaxTickets keeps a big buyer's ticket list within one message
+	myOrdersMaxTickets = 100
+	// myOrdersMaxPerfumes keeps a long perfume list within one message
+	myOrdersMaxPerfumes = 300
+)
+
+// orderStatusMessageKeys are the catalog keys of the order statuses shown to users
+var orderStatusMessageKeys = map[domain.OrderStatus]string{
+	domain.OrderStatusAwaitingPayment: i18n.OrderStatusAwaitingPayment,
+	domain.OrderStatusPaid:            i18n.OrderStatusPaid,
+	domain.OrderStatusPerfumeSelected: i18n.OrderStatusPerfumeSelected,
+	domain.OrderStatusAddressProvided: i18n.OrderStatusAddressProvided,
+	domain.OrderStatusPacked:          i18n.OrderStatusPacked,
+	domain.OrderStatusShipped:         i18n.OrderStatusShipped,
+	domain.OrderStatusDelivered:       i18n.OrderStatusDelivered,
+	domain.OrderStatusCancelled:       i18n.OrderStatusCancelled,
+}
+
+// MyOrdersHandler handles /myorders and its page buttons: it shows the user's orders,
+// newest first, with their perfumes, gift and delivery status, followed by the user's
+// lottery tickets. Pressing a page button edits the list in place.
+func (h *Handler) MyOrdersHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	var userId, chatId int64
+	var messageId, page int
+	switch {
+	case update.CallbackQuery != nil:
+		query := update.CallbackQuery
+		if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID}); err != nil {
+			h.logger.Warn("Failed to answer callback query", zap.Error(err))
+		}
+		message := query.Message.Message
+		if message == nil {
+			return
+		}
+		userId, chatId, messageId = query.From.ID, message.Chat.ID, message.ID
+		page, _ = strconv.Atoi(strings.TrimPrefix(query.Data, MyOrdersCallbackPrefix))
+	case update.Message != nil && update.Message.From != nil:
+		userId, chatId = update.Message.From.ID, update.Message.Chat.ID
+	default:
+		return
+	}
+
+	lang := h.userLang(ctx, userId)
+	orders, err := h.orderRepo.GetByUserID(ctx, userId)
+	if err != nil {
+		h.logger.Error("Failed to get user orders", zap.Error(err), zap.Int64("user_id", userId))
+		return
+	}
+	tickets, err := h.clientRepo.GetLotoTickets(ctx, userId)
+	if err != nil {
+		h.logger.Error("Failed to get loto tickets", zap.Error(err), zap.Int64("user_id", userId))
+	}
+
+	text, keyboard := myOrdersPage(lang, orders, tickets, page)
+	if messageId != 0 {
+		if _, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      chatId,
+			MessageID:   messageId,
+			Text:        text,
+			ReplyMarkup: keyboard,
+		}); err != nil {
+			h.logger.Warn("Failed to edit orders page", zap.Error(err), zap.Int64("user_id", userId))
+		}
+		return
+	}
+
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatId,
+		Text:        text,
+		ReplyMarkup: keyboard,
+	}); err != nil {
+		h.logger.Warn("Failed to send orders", zap.Error(err), zap.Int64("user_id", userId))
+	}
+}
+
+// myOrdersPage renders one page of the user's orders and the buttons under it: one to pick
+// perfumes for every paid order still without them, and prev/next when there are more pages.
+// A page out of range shows the nearest one.
+func myOrdersPage(lang string, orders []domain.Order, tickets []int, page int) (string, *models.InlineKeyboardMarkup) {
+	keyboard := &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{}}
+	if len(orders) == 0 {
+		return i18n.T(lang, i18n.MyOrdersEmpty), keyboard
+	}
+
+	pages := (len(orders) + myOrdersPageSize - 1) / myOrdersPageSize
+	page = max(0, min(page, pages-1))
+	start := page * myOrdersPageSize
+	end := min(start+myOrdersPageSize, len(orders))
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, i18n.MyOrdersTitle, page+1, pages))
+	for _, order := range orders[start:end] {
+		quantity := 0
+		if order.Quantity != nil {
+			quantity = *order.Quantity
+		}
+		parfumes := strings.TrimSpace(order.Parfumes)
+		if parfumes == "" {
+			parfumes = i18n.T(lang, i18n.MyOrdersNoPerfumes)
+		} else if runes := []rune(parfumes); len(runes) > myOrdersMaxPerfumes {
+			parfumes = string(runes[:myOrdersMaxPerfumes]) + "…"
+		}
+
+		sb.WriteString("\n\n")
+		sb.WriteString(i18n.T(lang, i18n.MyOrdersItem, order.ID, quantity, parfumes, orderStatusName(lang, order.Status)))
+		if order.Gift != "" && order.Gift != "null" {
+			sb.WriteString("\n")
+			sb.WriteString(i18n.T(lang, i18n.MyOrdersGift, prizeName(lang, order.Gift)))
+		}
+
+		if needsPerfumeSelection(order) {
+			keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{{
+				Text: i18n.T(lang, i18n.MyOrdersSelectButton, order.ID),
+				URL:  fmt.Sprintf("%s?startapp=order_%d", miniAppLink, order.ID),
+			}})
+		}
+	}
+
+	sb.WriteString("\n\n")
+	sb.WriteString(formatTickets(lang, tickets))
+
+	if pages > 1 {
+		var nav []models.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, models.InlineKeyboardButton{Text: "⬅️", CallbackData: fmt.Sprintf("%s%d", MyOrdersCallbackPrefix, page-1)})
+		}
+		if page < pages-1 {
+			nav = append(nav, models.InlineKeyboardButton{Text: "➡️", CallbackData: fmt.Sprintf("%s%d", MyOrdersCallbackPrefix, page+1)})
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, nav)
+	}
+
+	return sb.String(), keyboard
+}
+
+// needsPerfumeSelection reports whether a paid order is still waiting for its perfumes
+func needsPerfumeSelection(order domain.Order) bool {
+	if strings.TrimSpace(order.Parfumes) != "" {
+		return false
+	}
+	return order.Status != domain.OrderStatusAwaitingPayment && order.Status != domain.OrderStatusCancelled
+}
+
+// orderStatusName is the status shown to users, or the status itself if it's unknown
+func orderStatusName(lang string, status domain.OrderStatus) string {
+	if key, ok := orderStatusMessageKeys[status]; ok {
+		return i18n.T(lang, key)
+	}
+	return string(status)
+}
+
+// formatTickets lists the ticket numbers, cutting the list short for big buyers
+func formatTickets(lang string, tickets []int) string {
+	if len(tickets) == 0 {
+		return i18n.T(lang, i18n.MyOrdersNoTickets)
+	}
+
+	shown := tickets[:min(len(tickets), myOrdersMaxTickets)]
+	numbers := make([]string, len(shown))
+	for i, ticket := range shown {
+		numbers[i] = strconv.Itoa(ticket)
+	}
+	list := strings.Join(numbers, ", ")
+	if hidden := len(tickets) - len(shown); hidden > 0 {
+		list += fmt.Sprintf(" … +%d", hidden)
+	}
+	return i18n.T(lang, i18n.MyOrdersTickets, len(tickets), list)
+}
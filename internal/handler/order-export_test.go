@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func exportOrders(h *Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleExportOrders(rec, adminRequest("GET", target, ""))
+	return rec
+}
+
+// readCSV parses an exported spreadsheet, BOM included
+func readCSV(t *testing.T, rec *httptest.ResponseRecorder) [][]string {
+	t.Helper()
+
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "\xEF\xBB\xBF") {
+		t.Errorf("export doesn't start with the UTF-8 BOM")
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(body, "\xEF\xBB\xBF"))).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv %q: %v", body, err)
+	}
+	return records
+}
+
+// seedExportOrders adds two orders created a day apart, the older one completed and won
+func seedExportOrders(t *testing.T, db *sql.DB) (older, newer domain.Order) {
+	t.Helper()
+
+	quantity := 2
+	older = seedOrder(t, db, domain.Order{
+		IDUser:   4001,
+		UserName: "aigerim",
+		Quantity: &quantity,
+		Parfumes: "Baccarat Rouge, Tobacco Vanille",
+		FIO:      "Айгерим Серікова",
+		Contact:  "+77011234567",
+		Address:  "Алматы, Абая 1",
+		Checks:   true,
+	})
+	setOrderPrize(t, db, older.ID, Prize10ML)
+	newer = seedOrder(t, db, domain.Order{
+		IDUser:  4002,
+		FIO:     "Ерлан",
+		Contact: "+77017654321",
+		Address: "Астана, Кабанбай батыра 5",
+	})
+
+	for id, createdAt := range map[int64]string{older.ID: "2026-03-01 10:00:00", newer.ID: "2026-03-02 12:30:00"} {
+		if _, err := db.Exec(`UPDATE orders SET created_at = ? WHERE id = ?`, createdAt, id); err != nil {
+			t.Fatalf("set created_at: %v", err)
+		}
+	}
+	return older, newer
+}
+
+func TestExportOrdersWritesAccountingColumns(t *testing.T) {
+	h, db := newTestHandler(t)
+	older, newer := seedExportOrders(t, db)
+
+	rec := exportOrders(h, "/api/orders/export?format=csv")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/csv", got)
+	}
+	if got := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(got, `attachment; filename="orders_`) {
+		t.Errorf("Content-Disposition = %q, want an orders attachment", got)
+	}
+
+	records := readCSV(t, rec)
+	want := [][]string{
+		{"id", "user", "fio", "contact", "address", "quantity", "parfumes", "gift", "date"},
+		{strconv.FormatInt(newer.ID, 10), "4002", "Ерлан", "+77017654321", "Астана, Кабанбай батыра 5", "1", "", "", "2026-03-02 12:30:00"},
+		{strconv.FormatInt(older.ID, 10), "aigerim", "Айгерим Серікова", "+77011234567", "Алматы, Абая 1", "2", "Baccarat Rouge, Tobacco Vanille", Prize10ML, "2026-03-01 10:00:00"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records = %q, want %d rows", records, len(want))
+	}
+	for i := range want {
+		if !slices.Equal(records[i], want[i]) {
+			t.Errorf("row %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestExportOrdersAppliesFilters(t *testing.T) {
+	h, db := newTestHandler(t)
+	older, newer := seedExportOrders(t, db)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int64
+	}{
+		{"completed", "?status=completed", []int64{older.ID}},
+		{"pending", "?status=pending", []int64{newer.ID}},
+		{"from", "?from=2026-03-02", []int64{newer.ID}},
+		{"to", "?to=2026-03-01", []int64{older.ID}},
+		{"outside the range", "?from=2026-04-01&to=2026-04-30", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := exportOrders(h, "/api/orders/export"+tt.query)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+			}
+
+			var ids []int64
+			for _, record := range readCSV(t, rec)[1:] {
+				id, err := strconv.ParseInt(record[0], 10, 64)
+				if err != nil {
+					t.Fatalf("order id %q: %v", record[0], err)
+				}
+				ids = append(ids, id)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("exported orders = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportOrdersDeliveryColumns(t *testing.T) {
+	h, db := newTestHandler(t)
+	seedExportOrders(t, db)
+
+	rec := exportOrders(h, "/api/admin/orders/export")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	records := readCSV(t, rec)
+	if len(records) != 3 || records[0][0] != "order_id" || records[0][6] != "latitude" {
+		t.Errorf("records = %q, want the delivery header and two orders", records)
+	}
+}
+
+func TestExportOrdersRejectsBadParameters(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, query := range []string{"?format=xlsx", "?columns=everything", "?status=lost", "?from=01.03.2026"} {
+		rec := exportOrders(h, "/api/orders/export"+query)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+		if strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+			t.Errorf("%s: a CSV was started", query)
+		}
+	}
+}
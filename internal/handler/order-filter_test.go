@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func TestParseDateRange(t *testing.T) {
+	tests := []struct {
+		query    string
+		from, to string
+		err      string
+	}{
+		{query: ""},
+		{query: "?from=2026-03-01&to=2026-03-31", from: "2026-03-01", to: "2026-03-31"},
+		{query: "?from=2026-03-01&to=2026-03-01", from: "2026-03-01", to: "2026-03-01"},
+		{query: "?from=2026-03-01", from: "2026-03-01"},
+		{query: "?to=2026-03-31", to: "2026-03-31"},
+		{query: "?from=2026-03-31&to=2026-03-01", err: "from date 2026-03-31 is after to date 2026-03-01"},
+		{query: "?from=01.03.2026", err: `invalid from date "01.03.2026"`},
+		{query: "?from=2026-3-1", err: `invalid from date "2026-3-1"`},
+		{query: "?to=2026-02-30", err: `invalid to date "2026-02-30"`},
+		{query: "?from=2026-03-01&to=tomorrow", err: `invalid to date "tomorrow"`},
+	}
+
+	for _, tt := range tests {
+		from, to, err := parseDateRange(httptest.NewRequest("GET", "/api/orders"+tt.query, nil))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v, want %q", tt.query, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || from != tt.from || to != tt.to {
+			t.Errorf("%s: got %q, %q, %v; want %q, %q", tt.query, from, to, err, tt.from, tt.to)
+		}
+	}
+}
+
+func TestGetOrdersFiltersByDateRange(t *testing.T) {
+	h, db := newTestHandler(t)
+	older, newer := seedExportOrders(t, db)
+
+	tests := []struct {
+		query  string
+		status int
+		want   []int64
+	}{
+		{"", http.StatusOK, []int64{newer.ID, older.ID}},
+		{"?from=2026-03-01&to=2026-03-01", http.StatusOK, []int64{older.ID}},
+		{"?from=2026-03-02&to=2026-03-31", http.StatusOK, []int64{newer.ID}},
+		{"?from=2026-03-02&to=2026-03-01", http.StatusBadRequest, nil},
+		{"?from=March", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.handleGetOrders(rec, adminRequest("GET", "/api/orders"+tt.query, ""))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, rec.Code, tt.status, rec.Body.String())
+			continue
+		}
+
+		if tt.status != http.StatusOK {
+			var resp struct {
+				Error APIError `json:"error"`
+			}
+			decodeJSON(t, rec, &resp)
+			if resp.Error.Code != "invalid_filter" {
+				t.Errorf("%s: error code = %q, want invalid_filter", tt.query, resp.Error.Code)
+			}
+			continue
+		}
+
+		var orders []domain.Order
+		decodeJSON(t, rec, &orders)
+		var ids []int64
+		for _, order := range orders {
+			ids = append(ids, order.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: orders = %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+}
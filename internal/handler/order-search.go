@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"parfum/internal/domain"
+	"parfum/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// Result limits of /api/orders/search
+const (
+	defaultOrderSearchLimit = 20
+	maxOrderSearchLimit     = 100
+)
+
+// minOrderSearchLength keeps a one-letter query from listing half the orders
+const minOrderSearchLength = 2
+
+// Find a customer's orders by phone number or name, for support calls
+// GET /api/orders/search?q=&limit=20
+// q matches fio, username or contact; phone numbers match in any format (+7, 8, spaces)
+func (h *Handler) handleSearchOrders(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len([]rune(query)) < minOrderSearchLength {
+		writeJSONError(w, http.StatusBadRequest, "invalid_query", "q must be at least 2 characters", nil)
+		return
+	}
+
+	limit := defaultOrderSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > maxOrderSearchLimit {
+			writeJSONError(w, http.StatusBadRequest, "invalid_limit", "limit must be a number between 1 and 100", nil)
+			return
+		}
+		limit = value
+	}
+
+	// Only a query with a few digits is worth matching as a phone number
+	phone := service.PhoneDigits(query)
+	if len(phone) < 3 {
+		phone = ""
+	}
+
+	orders, err := h.orderRepo.SearchOrders(r.Context(), query, phone, limit)
+	if err != nil {
+		h.logger.Error("Error searching orders", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+	if orders == nil {
+		orders = []domain.Order{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"query":   query,
+		"orders":  orders,
+		"count":   len(orders),
+	})
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func searchOrders(t *testing.T, h *Handler, query string) []int64 {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.handleSearchOrders(rec, adminRequest("GET", "/api/orders/search?q="+url.QueryEscape(query), ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%q: status = %d, want 200: %s", query, rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Orders []domain.Order `json:"orders"`
+		Count  int            `json:"count"`
+	}
+	decodeJSON(t, rec, &resp)
+
+	ids := make([]int64, 0, len(resp.Orders))
+	for _, order := range resp.Orders {
+		ids = append(ids, order.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSearchOrdersByPhoneAndName(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	aigerim := seedOrder(t, db, domain.Order{IDUser: 7901, UserName: "aigerim_k", FIO: "Айгерим Серікова", Contact: "87011234567"})
+	dana := seedOrder(t, db, domain.Order{IDUser: 7902, UserName: "dana", FIO: "Дана Ахметова", Contact: "+7 702 555 66 77"})
+	again := seedOrder(t, db, domain.Order{IDUser: 7901, UserName: "aigerim_k", FIO: "Айгерим Серікова", Contact: "+77011234567"})
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"+7 701 123 45 67", []int64{aigerim.ID, again.ID}},
+		{"8 (701) 123-45-67", []int64{aigerim.ID, again.ID}},
+		{"7011234567", []int64{aigerim.ID, again.ID}},
+		{"87025556677", []int64{dana.ID}},
+		{"555-66", []int64{dana.ID}},
+		{"Айгерим", []int64{aigerim.ID, again.ID}},
+		{"Ахметова", []int64{dana.ID}},
+		{"AIGERIM", []int64{aigerim.ID, again.ID}},
+		{"Бекзат", []int64{}},
+		{"8707", []int64{}},
+	}
+	for _, tt := range tests {
+		if got := searchOrders(t, h, tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("search %q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchOrdersRejectsBadQuery(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, query := range []string{"?q=a", "?q=%20%20", "?q=aigerim&limit=0", "?q=aigerim&limit=500"} {
+		rec := httptest.NewRecorder()
+		h.handleSearchOrders(rec, adminRequest("GET", "/api/orders/search"+query, ""))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
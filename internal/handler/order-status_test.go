@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+)
+
+func updateOrderStatus(h *Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleOrderRoutes(rec, r)
+	return rec
+}
+
+func statusRequest(orderID int64, status domain.OrderStatus) *http.Request {
+	return adminRequest("PATCH", fmt.Sprintf("/api/order/%d/status", orderID), fmt.Sprintf(`{"status": %q, "note": "курьер"}`, status))
+}
+
+func TestUpdateOrderStatusMovesOrderAlong(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	h.SetBot(tg.bot)
+
+	order := seedOrder(t, db, domain.Order{IDUser: 7401, Address: "Алматы, Абая 1"})
+	if _, err := db.Exec(`UPDATE orders SET status = ?, checks = 1 WHERE id = ?`, domain.OrderStatusAddressProvided, order.ID); err != nil {
+		t.Fatalf("set status: %v", err)
+	}
+
+	for _, status := range []domain.OrderStatus{domain.OrderStatusPacked, domain.OrderStatusShipped} {
+		rec := updateOrderStatus(h, statusRequest(order.ID, status))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200: %s", status, rec.Code, rec.Body.String())
+		}
+
+		var resp struct {
+			Success bool              `json:"success"`
+			Order   domain.Order      `json:"order"`
+			Event   domain.OrderEvent `json:"event"`
+		}
+		decodeJSON(t, rec, &resp)
+		if !resp.Success || resp.Order.Status != status || !resp.Order.Checks || resp.Event.ToStatus != status {
+			t.Errorf("%s: response = %+v", status, resp)
+		}
+	}
+
+	tg.waitForMessage(t, order.IDUser, i18n.T(i18n.LangKz, i18n.OrderShippedNotice))
+
+	// A shipped order can't go back to packing
+	rec := updateOrderStatus(h, statusRequest(order.ID, domain.OrderStatusPacked))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", rec.Code, rec.Body.String())
+	}
+	var conflict struct {
+		Error APIError `json:"error"`
+	}
+	decodeJSON(t, rec, &conflict)
+	if conflict.Error.Code != "invalid_status_transition" {
+		t.Errorf("code = %q, want invalid_status_transition", conflict.Error.Code)
+	}
+}
+
+func TestUpdateOrderStatusRejectsBadRequests(t *testing.T) {
+	h, db := newTestHandler(t)
+	order := seedOrder(t, db, domain.Order{IDUser: 7402})
+
+	unauthenticated := statusRequest(order.ID, domain.OrderStatusCancelled)
+	unauthenticated.Header.Del("Authorization")
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+		code    string
+	}{
+		{"unknown order", statusRequest(99999, domain.OrderStatusCancelled), http.StatusNotFound, "order_not_found"},
+		{"order id not a number", adminRequest("PATCH", "/api/order/abc/status", `{"status": "cancelled"}`), http.StatusBadRequest, "invalid_order_id"},
+		{"unknown status", statusRequest(order.ID, "lost"), http.StatusBadRequest, "invalid_status"},
+		{"not json", adminRequest("PATCH", fmt.Sprintf("/api/order/%d/status", order.ID), "cancelled"), http.StatusBadRequest, "invalid_json"},
+		{"no admin token", unauthenticated, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		rec := updateOrderStatus(h, tt.request)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body.String())
+			continue
+		}
+		if tt.code == "" {
+			continue
+		}
+		var resp struct {
+			Error APIError `json:"error"`
+		}
+		decodeJSON(t, rec, &resp)
+		if resp.Error.Code != tt.code {
+			t.Errorf("%s: code = %q, want %q", tt.name, resp.Error.Code, tt.code)
+		}
+	}
+
+	saved, err := h.orderRepo.GetByID(context.Background(), order.ID)
+	if err != nil || saved.Status == domain.OrderStatusCancelled {
+		t.Errorf("order = %+v, %v; want it left as it was", saved, err)
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"parfum/internal/service"
+
+	"github.com/go-telegram/bot"
+	"go.uber.org/zap"
+)
+
+// outboxSize is how many notifications may wait to be sent before enqueueing blocks
+const outboxSize = 1000
+
+// errBotNotInitialized is why a notification fails while the handler runs without a bot
+var errBotNotInitialized = errors.New("bot not initialized")
+
+// outboundMessage is a notification waiting in the outbox
+type outboundMessage struct {
+	bot            *bot.Bot
+	chatID         int64
+	orderID        int64 // 0 if the message isn't about an order
+	notificationID int64 // failed_notifications row being resent, 0 for a new message
+	params         *bot.SendMessageParams
+	result         chan error
+}
+
+// enqueueMessage queues a notification for the outbox worker and returns a channel that
+// receives nil once it is delivered, or the error it ended in failed_notifications with.
+// Callers that don't care about the outcome may ignore the channel. Without a bot the
+// notification is kept there until SetBot flushes it.
+func (h *Handler) enqueueMessage(chatID, orderID int64, params *bot.SendMessageParams) <-chan error {
+	return h.enqueue(outboundMessage{
+		bot:     h.bot,
+		chatID:  chatID,
+		orderID: orderID,
+		params:  params,
+		result:  make(chan error, 1),
+	})
+}
+
+func (h *Handler) enqueue(msg outboundMessage) <-chan error {
+	select {
+	case h.outbox <- msg:
+	case <-h.ctx.Done():
+		h.deadLetter(msg, h.ctx.Err())
+	}
+	return msg.result
+}
+
+// runOutbox sends queued notifications one at a time, at most cfg.TelegramSendRate a
+// second. A 429 or other transient error is retried with backoff, honouring Telegram's
+// retry_after, which holds back the whole queue as Telegram expects. A message that still
+// can't be sent is kept in failed_notifications. On shutdown the rest of the queue is
+// kept there too.
+func (h *Handler) runOutbox() {
+	ticker := time.NewTicker(time.Second / time.Duration(max(h.cfg.TelegramSendRate, 1)))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-h.outbox:
+			select {
+			case <-ticker.C:
+				h.deliver(msg)
+			case <-h.ctx.Done():
+				h.deadLetter(msg, h.ctx.Err())
+				h.drainOutbox()
+				return
+			}
+		case <-h.ctx.Done():
+			h.drainOutbox()
+			return
+		}
+	}
+}
+
+func (h *Handler) deliver(msg outboundMessage) {
+	// Queued before SetBot, so it goes out with the bot set since
+	if msg.bot == nil {
+		msg.bot = h.bot
+	}
+	if msg.bot == nil {
+		h.deadLetter(msg, errBotNotInitialized)
+		return
+	}
+
+	err := service.SendWithRetry(h.ctx, telegramSendAttempts, func(ctx context.Context) error {
+		_, err := msg.bot.SendMessage(ctx, msg.params)
+		return err
+	})
+	if err != nil {
+		h.deadLetter(msg, err)
+		return
+	}
+	if msg.notificationID != 0 {
+		h.recordResend(msg.notificationID, "")
+	}
+	msg.result <- nil
+}
+
+// deadLetter keeps a notification that couldn't be delivered for an admin to resend
+func (h *Handler) deadLetter(msg outboundMessage, err error) {
+	h.logger.Error("Failed to send notification",
+		zap.Error(err),
+		zap.Int64("chat_id", msg.chatID),
+		zap.Int64("order_id", msg.orderID))
+	if msg.notificationID != 0 {
+		h.recordResend(msg.notificationID, err.Error())
+	} else {
+		h.logFailedNotification(msg.chatID, msg.orderID, msg.params, err)
+	}
+	msg.result <- err
+}
+
+// recordResend updates the failed_notifications row a queued resend came from
+func (h *Handler) recordResend(notificationID int64, reason string) {
+	if err := h.clientRepo.RecordNotificationAttempt(context.WithoutCancel(h.ctx), notificationID, reason); err != nil {
+		h.logger.Error("Error recording notification retry", zap.Error(err), zap.Int64("id", notificationID))
+	}
+}
+
+// drainOutbox keeps every notification still queued at shutdown
+func (h *Handler) drainOutbox() {
+	for {
+		select {
+		case msg := <-h.outbox:
+			h.deadLetter(msg, h.ctx.Err())
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+	"parfum/internal/service"
+)
+
+// savePendingReceipt stores a receipt whose amount didn't match the count the user chose
+func savePendingReceipt(t *testing.T, h *Handler, userID int64, amount int) *domain.PendingReceipt {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "receipt.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0o644); err != nil {
+		t.Fatalf("write receipt: %v", err)
+	}
+	receipt := &domain.PendingReceipt{
+		FilePath:    path,
+		FileName:    "receipt.pdf",
+		ActualPrice: amount,
+		Qr:          "qr-pending-receipt",
+		Bin:         h.cfg.Bin,
+	}
+	if err := h.redisRepo.SavePendingReceipt(context.Background(), userID, receipt, time.Hour); err != nil {
+		t.Fatalf("save pending receipt: %v", err)
+	}
+	return receipt
+}
+
+func TestQuoteCountResumesPendingReceipt(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	ctx := context.Background()
+	const userID = 1001
+
+	amount, err := service.OrderPrice(h.cfg.Prices, h.countLines(2))
+	if err != nil {
+		t.Fatalf("price: %v", err)
+	}
+	savePendingReceipt(t, h, userID, amount)
+
+	// The user goes back and picks the count the receipt was paid for
+	h.quoteCount(ctx, tg.bot, userID, 2)
+
+	orders, err := repository.NewOrderRepository(db).GetByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("got %d orders, want 1", len(orders))
+	}
+	if orders[0].Quantity == nil || *orders[0].Quantity != 2 {
+		t.Errorf("order quantity = %v, want 2", orders[0].Quantity)
+	}
+
+	receipt, err := h.redisRepo.GetPendingReceipt(ctx, userID)
+	if err != nil || receipt != nil {
+		t.Errorf("pending receipt after resuming = %+v, %v; want it deleted", receipt, err)
+	}
+
+	state, err := h.redisRepo.GetUserState(ctx, userID)
+	if err != nil || state == nil {
+		t.Fatalf("GetUserState = %v, %v", state, err)
+	}
+	if !state.IsPaid || state.State != StateContact {
+		t.Errorf("state = %s paid %v, want %s paid", state.State, state.IsPaid, StateContact)
+	}
+}
+
+func TestQuoteCountKeepsMismatchedPendingReceipt(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	ctx := context.Background()
+	const userID = 1002
+
+	amount, err := service.OrderPrice(h.cfg.Prices, h.countLines(3))
+	if err != nil {
+		t.Fatalf("price: %v", err)
+	}
+	saved := savePendingReceipt(t, h, userID, amount)
+
+	// Still not the count the receipt was paid for: the user gets the payment link again
+	h.quoteCount(ctx, tg.bot, userID, 1)
+
+	orders, err := repository.NewOrderRepository(db).GetByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if len(orders) != 0 {
+		t.Fatalf("got %d orders, want none", len(orders))
+	}
+
+	receipt, err := h.redisRepo.GetPendingReceipt(ctx, userID)
+	if err != nil || receipt == nil || *receipt != *saved {
+		t.Errorf("pending receipt = %+v, %v; want %+v kept", receipt, err, saved)
+	}
+	if len(tg.messages(userID)) == 0 {
+		t.Error("no payment instructions sent")
+	}
+}
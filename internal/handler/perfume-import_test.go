@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"parfum/internal/repository"
+)
+
+// perfumeImportResponse is the JSON reply of the catalog import
+type perfumeImportResponse struct {
+	Success  bool                            `json:"success"`
+	Atomic   bool                            `json:"atomic"`
+	Total    int                             `json:"total"`
+	Imported int                             `json:"imported"`
+	IDs      []string                        `json:"ids"`
+	Errors   []repository.ProductImportError `json:"errors"`
+}
+
+func importPerfumes(t *testing.T, h *Handler, query, content string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "parfumes.csv")
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	part.Write([]byte(content))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close form: %v", err)
+	}
+
+	r := adminRequest("POST", "/api/admin/parfumes/import"+query, body.String())
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h.handleImportPerfumes(rec, r)
+	return rec
+}
+
+func countPerfumes(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM parfume`).Scan(&count); err != nil {
+		t.Fatalf("count perfumes: %v", err)
+	}
+	return count
+}
+
+func TestImportPerfumesReportsMalformedRows(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	csv := strings.Join([]string{
+		"name,sex,description,price,stock,photo",
+		"Chanel Coco Mademoiselle,female,Floral,2499,20,",
+		",Male,No name,2499,,",
+		"Dior Sauvage,Robot,Fresh,2499,,",
+		"Creed Aventus,Male,Fruity,0,,",
+		"Kilian Angels Share,Unisex,Cognac,cheap,,",
+		"Byredo Gypsy Water,Unisex,Woody,2499,-3,",
+		"Le Labo Santal,Unisex,Sandal,2499,,../etc/passwd",
+		`Broken "quote,Unisex,x,2499,,`,
+		"Tom Ford Oud Wood,UNISEX,Oud,2499,,",
+	}, "\n") + "\n"
+
+	rec := importPerfumes(t, h, "", csv)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp perfumeImportResponse
+	decodeJSON(t, rec, &resp)
+	if resp.Success || resp.Total != 9 || resp.Imported != 2 || len(resp.IDs) != 2 {
+		t.Errorf("response = %+v, want 2 of 9 rows imported", resp)
+	}
+
+	wantErrors := map[int]string{
+		3: "name, sex and description are required",
+		4: `invalid sex "Robot"`,
+		5: "price must be a positive whole number",
+		6: "price must be a positive whole number",
+		7: "invalid stock",
+		8: "photo must be a bare file name",
+		9: `bare "`,
+	}
+	if len(resp.Errors) != len(wantErrors) {
+		t.Fatalf("errors = %+v, want %d", resp.Errors, len(wantErrors))
+	}
+	for _, rowErr := range resp.Errors {
+		want, ok := wantErrors[rowErr.Row]
+		if !ok || !strings.Contains(rowErr.Message, want) {
+			t.Errorf("row %d error = %q, want %q", rowErr.Row, rowErr.Message, want)
+		}
+	}
+
+	if n := countPerfumes(t, db); n != 2 {
+		t.Errorf("%d perfumes stored, want 2", n)
+	}
+	perfumes, err := h.parfumeRepo.GetAll(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	for _, perfume := range perfumes {
+		if perfume.Sex != "Female" && perfume.Sex != "Unisex" {
+			t.Errorf("%s sex = %q, want it normalized", perfume.NameParfume, perfume.Sex)
+		}
+	}
+}
+
+func TestImportPerfumesRejectsDuplicateNames(t *testing.T) {
+	h, db := newTestHandler(t)
+	existing := &repository.Product{NameParfume: "Tom Ford Oud Wood", Sex: "Unisex", Description: "Oud", Price: 2499}
+	if err := h.parfumeRepo.Create(context.Background(), existing); err != nil {
+		t.Fatalf("create perfume: %v", err)
+	}
+
+	csv := "name,sex,description,price\n" +
+		"tom ford oud wood ,Unisex,Oud again,2499\n" +
+		"Baccarat Rouge,Unisex,Amber,2499\n" +
+		"Baccarat Rouge,Unisex,Amber twice,2499\n"
+
+	rec := importPerfumes(t, h, "", csv)
+	var resp perfumeImportResponse
+	decodeJSON(t, rec, &resp)
+	if resp.Imported != 1 || len(resp.Errors) != 2 {
+		t.Fatalf("response = %+v, want one imported and two duplicates", resp)
+	}
+	for i, row := range []int{2, 4} {
+		if resp.Errors[i].Row != row || resp.Errors[i].Message != "duplicate name" {
+			t.Errorf("errors[%d] = %+v, want a duplicate at row %d", i, resp.Errors[i], row)
+		}
+	}
+	if n := countPerfumes(t, db); n != 2 {
+		t.Errorf("%d perfumes stored, want 2", n)
+	}
+}
+
+func TestImportPerfumesAtomicRollsBack(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	csv := "name,sex,description,price\n" +
+		"Baccarat Rouge,Unisex,Amber,2499\n" +
+		"Baccarat Rouge,Unisex,Amber twice,2499\n" +
+		"Dior Sauvage,Male,Fresh,2499\n"
+
+	rec := importPerfumes(t, h, "?atomic=1", csv)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body.String())
+	}
+	var resp perfumeImportResponse
+	decodeJSON(t, rec, &resp)
+	if !resp.Atomic || resp.Imported != 0 || len(resp.Errors) != 1 {
+		t.Errorf("response = %+v, want nothing imported and the duplicate reported", resp)
+	}
+	if n := countPerfumes(t, db); n != 0 {
+		t.Errorf("%d perfumes stored, want the batch rolled back", n)
+	}
+
+	// A malformed row rejects an atomic batch before anything is inserted
+	rec = importPerfumes(t, h, "?atomic=1", "name,sex,description,price\nDior Sauvage,Male,Fresh,free\nCreed Aventus,Male,Fruity,2499\n")
+	if rec.Code != http.StatusUnprocessableEntity || countPerfumes(t, db) != 0 {
+		t.Errorf("status = %d with %d perfumes, want 422 and none", rec.Code, countPerfumes(t, db))
+	}
+}
+
+func TestImportPerfumesLargeFile(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	var csv strings.Builder
+	csv.WriteString("name,sex,description,price,stock\n")
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&csv, "Perfume %04d,Unisex,Batch scent %d,%d,%d\n", i, i, 2000+i, i%50)
+	}
+
+	rec := importPerfumes(t, h, "?atomic=1", csv.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp perfumeImportResponse
+	decodeJSON(t, rec, &resp)
+	if !resp.Success || resp.Total != 1000 || resp.Imported != 1000 || len(resp.Errors) != 0 {
+		t.Errorf("response total %d imported %d errors %v, want all 1000 imported", resp.Total, resp.Imported, resp.Errors)
+	}
+	if n := countPerfumes(t, db); n != 1000 {
+		t.Errorf("%d perfumes stored, want 1000", n)
+	}
+}
+
+func TestImportPerfumesRejectsBadFile(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for name, content := range map[string]string{
+		"missing column": "name,sex,price\nDior Sauvage,Male,2499\n",
+		"empty file":     "",
+		"too many rows":  "name,sex,description,price\n" + strings.Repeat("x,Male,y,1\n", maxPerfumeImportRows+1),
+	} {
+		rec := importPerfumes(t, h, "", content)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_csv") {
+			t.Errorf("%s: status = %d, body %s; want 400 invalid_csv", name, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+)
+
+func getPerfumes(h *Handler, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleGetPerfumes(rec, httptest.NewRequest("GET", "/api/parfumes"+query, nil))
+	return rec
+}
+
+func TestGetPerfumesAnnotatesAvailability(t *testing.T) {
+	h, db := newTestHandler(t)
+	ctx := context.Background()
+	perfumes := repository.NewParfumeRepository(db)
+
+	two, none := 2, 0
+	products := map[string]*repository.Product{
+		"untracked": {NameParfume: "Baccarat Rouge", Sex: "Unisex", Price: 2499},
+		"two left":  {NameParfume: "Lost Cherry", Sex: "Female", Price: 2499, Stock: &two},
+		"sold out":  {NameParfume: "Oud Wood", Sex: "Male", Price: 2499, Stock: &none},
+		"archived":  {NameParfume: "Tobacco Vanille", Sex: "Unisex", Price: 2499},
+	}
+	for name, product := range products {
+		if err := perfumes.Create(ctx, product); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+	}
+	if err := perfumes.Archive(ctx, products["archived"].Id); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	err := h.redisRepo.SaveCart(ctx, 7301, []domain.CartItem{
+		{ParfumeID: products["two left"].Id, Quantity: 1},
+		{ParfumeID: products["untracked"].Id, Quantity: 3},
+		{ParfumeID: products["two left"].Id, Quantity: 1},
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("save cart: %v", err)
+	}
+
+	type item struct {
+		Id           string `json:"Id"`
+		NameParfume  string `json:"NameParfume"`
+		Stock        *int   `json:"stock"`
+		Available    *bool  `json:"available"`
+		InCartByUser *int   `json:"in_cart_by_user"`
+	}
+	list := func(query string) map[string]item {
+		t.Helper()
+		rec := getPerfumes(h, query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+		}
+		var items []item
+		decodeJSON(t, rec, &items)
+		byID := make(map[string]item, len(items))
+		for _, i := range items {
+			if i.Available == nil || i.InCartByUser == nil {
+				t.Errorf("%s has no availability fields", i.NameParfume)
+				continue
+			}
+			byID[i.Id] = i
+		}
+		return byID
+	}
+
+	tests := []struct {
+		query, name string
+		available   bool
+		inCart      int
+	}{
+		{"?telegram_id=7301", "untracked", true, 3},
+		{"?telegram_id=7301", "two left", false, 2},
+		{"?telegram_id=7301", "sold out", false, 0},
+		{"", "untracked", true, 0},
+		{"", "two left", true, 0},
+		{"", "sold out", false, 0},
+		{"?telegram_id=7302", "two left", true, 0}, // someone else's cart doesn't count
+	}
+	for _, tt := range tests {
+		got, ok := list(tt.query)[products[tt.name].Id]
+		if !ok {
+			t.Errorf("%s%s: not listed", tt.name, tt.query)
+			continue
+		}
+		if *got.Available != tt.available || *got.InCartByUser != tt.inCart {
+			t.Errorf("%s%s: available = %v, in cart = %d; want %v, %d", tt.name, tt.query, *got.Available, *got.InCartByUser, tt.available, tt.inCart)
+		}
+	}
+
+	if _, listed := list("")[products["archived"].Id]; listed {
+		t.Errorf("archived perfume is listed")
+	}
+	if archived, listed := list("?include_archived=1")[products["archived"].Id]; !listed || *archived.Available {
+		t.Errorf("archived perfume = %+v, %v; want listed as unavailable", archived, listed)
+	}
+
+	if rec := getPerfumes(h, "?telegram_id=abc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid telegram_id: status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,245 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/handler"
+	"parfum/internal/handler/fakes"
+	"parfum/internal/repository"
+)
+
+const selectionUser = 8101
+
+// seedCatalog adds perfumes p1 and p2, p2 with two left in stock, and the archived p3
+func seedCatalog(stores *fakes.Set) {
+	stock := 2
+	archivedAt := time.Now()
+	stores.Parfumes.Add(
+		repository.Product{Id: "p1", NameParfume: "Baccarat Rouge", Price: 2499},
+		repository.Product{Id: "p2", NameParfume: "Lost Cherry", Price: 2999, Stock: &stock},
+		repository.Product{Id: "p3", NameParfume: "Oud Wood", Price: 1999, DeletedAt: &archivedAt},
+	)
+}
+
+// paidOrder adds an order of selectionUser for quantity kits
+func paidOrder(stores *fakes.Set, quantity int) domain.Order {
+	return stores.Orders.Add(domain.Order{IDUser: selectionUser, Quantity: &quantity})
+}
+
+func TestSavePerfumeSelection(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, stores *fakes.Set)
+		body  string
+
+		wantStatus int
+		wantCode   string
+		wantCart   []domain.CartItem
+	}{
+		{
+			name:       "by id",
+			setup:      func(t *testing.T, stores *fakes.Set) { paidOrder(stores, 3) },
+			body:       `{"telegram_id": 8101, "selected_perfumes": [{"id": "p1", "quantity": 2}, {"id": "p2", "quantity": 1}]}`,
+			wantStatus: http.StatusOK,
+			wantCart: []domain.CartItem{
+				{ParfumeID: "p1", Name: "Baccarat Rouge", Quantity: 2, Price: 2499},
+				{ParfumeID: "p2", Name: "Lost Cherry", Quantity: 1, Price: 2999},
+			},
+		},
+		{
+			name:       "by name from an older page",
+			setup:      func(t *testing.T, stores *fakes.Set) { paidOrder(stores, 1) },
+			body:       `{"telegram_id": 8101, "selected_perfumes": [{"name": "Baccarat Rouge", "quantity": 1}]}`,
+			wantStatus: http.StatusOK,
+			wantCart:   []domain.CartItem{{ParfumeID: "p1", Name: "Baccarat Rouge", Quantity: 1, Price: 2499}},
+		},
+		{
+			name:       "empty selection clears the cart",
+			setup:      func(t *testing.T, stores *fakes.Set) { paidOrder(stores, 1) },
+			body:       `{"telegram_id": 8101, "selected_perfumes": []}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "more than the orders left",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				order := paidOrder(stores, 2)
+				stores.OrderItems.AddItems(context.Background(), order.ID, []domain.OrderItem{{ParfumeID: "p1", Name: "Baccarat Rouge", Quantity: 1}})
+			},
+			body:       `{"telegram_id": 8101, "selected_perfumes": [{"id": "p1", "quantity": 2}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "insufficient_quantity",
+		},
+		{
+			name: "cancelled orders don't count",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				stores.Orders.Add(domain.Order{IDUser: selectionUser, Status: domain.OrderStatusCancelled})
+			},
+			body:       `{"telegram_id": 8101, "selected_perfumes": [{"id": "p1", "quantity": 1}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "insufficient_quantity",
+		},
+		{
+			name:       "unknown perfume",
+			setup:      func(t *testing.T, stores *fakes.Set) { paidOrder(stores, 2) },
+			body:       `{"telegram_id": 8101, "selected_perfumes": [{"id": "p1", "quantity": 1}, {"id": "p9", "quantity": 1}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "unknown_perfume",
+		},
+		{
+			name:       "archived perfume",
+			setup:      func(t *testing.T, stores *fakes.Set) { paidOrder(stores, 1) },
+			body:       `{"telegram_id": 8101, "selected_perfumes": [{"id": "p3", "quantity": 1}]}`,
+			wantStatus: http.StatusConflict,
+			wantCode:   "perfume_archived",
+		},
+		{
+			name:       "more than the stock",
+			setup:      func(t *testing.T, stores *fakes.Set) { paidOrder(stores, 3) },
+			body:       `{"telegram_id": 8101, "selected_perfumes": [{"id": "p2", "quantity": 3}]}`,
+			wantStatus: http.StatusConflict,
+			wantCode:   "insufficient_stock",
+		},
+		{
+			name:       "no telegram_id",
+			body:       `{"selected_perfumes": []}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "missing_telegram_id",
+		},
+		{
+			name:       "invalid JSON",
+			body:       `{"telegram_id": `,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newFakeHandler(t, nil)
+			seedCatalog(stores)
+			if tt.setup != nil {
+				tt.setup(t, stores)
+			}
+			// A cart from before is replaced, or kept when the selection is refused
+			before := []domain.CartItem{{ParfumeID: "p1", Name: "Baccarat Rouge", Quantity: 1, Price: 2499}}
+			stores.State.SaveCart(context.Background(), selectionUser, before, time.Hour)
+
+			rec := httptest.NewRecorder()
+			h.SavePerfumeSelection(rec, httptest.NewRequest("POST", "/api/perfumes/select", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			var resp struct {
+				Error handler.APIError `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Error.Code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.wantCode)
+			}
+
+			wantCart := tt.wantCart
+			if tt.wantStatus != http.StatusOK {
+				wantCart = before
+			}
+			cart, err := stores.State.GetCart(context.Background(), selectionUser)
+			if err != nil {
+				t.Fatalf("get cart: %v", err)
+			}
+			if !slices.Equal(cart, wantCart) {
+				t.Errorf("cart = %+v, want %+v", cart, wantCart)
+			}
+		})
+	}
+}
+
+func TestGetUserTemporarySelections(t *testing.T) {
+	tests := []struct {
+		name  string
+		cart  []domain.CartItem
+		query string
+
+		wantStatus     int
+		wantSelections []string // perfume IDs
+		wantQuantity   int
+	}{
+		{
+			name:       "empty cart",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "cart items",
+			cart: []domain.CartItem{
+				{ParfumeID: "p1", Name: "Baccarat Rouge", Quantity: 2},
+				{ParfumeID: "p2", Name: "Lost Cherry", Quantity: 1},
+			},
+			wantStatus:     http.StatusOK,
+			wantSelections: []string{"p1", "p2"},
+			wantQuantity:   3,
+		},
+		{
+			name:           "items saved without an id are counted, not listed",
+			cart:           []domain.CartItem{{ParfumeID: "p1", Quantity: 1}, {Name: "Oud Wood", Quantity: 2}},
+			wantStatus:     http.StatusOK,
+			wantSelections: []string{"p1"},
+			wantQuantity:   3,
+		},
+		{
+			name:       "invalid telegram_id",
+			query:      "?telegram_id=x",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newFakeHandler(t, nil)
+			if tt.cart != nil {
+				stores.State.SaveCart(context.Background(), selectionUser, tt.cart, time.Hour)
+			}
+			query := tt.query
+			if query == "" {
+				query = "?telegram_id=8101"
+			}
+
+			rec := httptest.NewRecorder()
+			h.GetUserTemporarySelections(rec, httptest.NewRequest("GET", "/api/perfumes/temp"+query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Selections []struct {
+					ID string `json:"id"`
+				} `json:"selections"`
+				TotalQuantity     int  `json:"total_quantity"`
+				HasTempSelections bool `json:"has_temp_selections"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			var ids []string
+			for _, selection := range resp.Selections {
+				ids = append(ids, selection.ID)
+			}
+			if !slices.Equal(ids, tt.wantSelections) || resp.HasTempSelections != (len(tt.wantSelections) > 0) {
+				t.Errorf("selections = %v (has %v), want %v", ids, resp.HasTempSelections, tt.wantSelections)
+			}
+			if resp.TotalQuantity != tt.wantQuantity {
+				t.Errorf("total_quantity = %d, want %d", resp.TotalQuantity, tt.wantQuantity)
+			}
+		})
+	}
+}
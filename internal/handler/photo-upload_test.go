@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"parfum/internal/service"
+)
+
+// pngHeader is the start of a PNG file, enough for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00")
+
+// inPhotoDir runs the test from a temporary directory with an empty ./photo, where
+// uploads are saved
+func inPhotoDir(t *testing.T) string {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "photo"), 0755); err != nil {
+		t.Fatalf("create photo dir: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return filepath.Join(dir, "photo")
+}
+
+// encodePNG is a real PNG image of the given size
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// photoFile is one file field of a perfume form
+type photoFile struct {
+	field, filename string
+	content         []byte
+}
+
+// perfumeForm builds a valid add-perfume form carrying files
+func perfumeForm(t *testing.T, files ...photoFile) (body *bytes.Buffer, contentType string) {
+	t.Helper()
+
+	body = &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for field, value := range map[string]string{
+		"name":        "Baccarat Rouge",
+		"sex":         "Unisex",
+		"description": "Amber floral",
+		"price":       "2499",
+	} {
+		if err := writer.WriteField(field, value); err != nil {
+			t.Fatalf("write field: %v", err)
+		}
+	}
+	for _, file := range files {
+		part, err := writer.CreateFormFile(file.field, file.filename)
+		if err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+		part.Write(file.content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close form: %v", err)
+	}
+	return body, writer.FormDataContentType()
+}
+
+func TestAddPerfumeRejectsPhotos(t *testing.T) {
+	megabyte := bytes.Repeat([]byte{0}, 1<<20)
+	tooMany := make([]photoFile, maxPhotosPerUpload+1)
+	for i := range tooMany {
+		tooMany[i] = photoFile{"photos[]", "photo.png", pngHeader}
+	}
+
+	tests := []struct {
+		name   string
+		files  []photoFile
+		status int
+		code   string
+	}{
+		{
+			name:   "svg",
+			files:  []photoFile{{"photo", "photo.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)}},
+			status: http.StatusUnsupportedMediaType,
+			code:   "unsupported_photo_type",
+		},
+		{
+			name:   "html named as an image",
+			files:  []photoFile{{"photo", "photo.jpg", []byte("<!DOCTYPE html><html><body><script>alert(1)</script></body></html>")}},
+			status: http.StatusUnsupportedMediaType,
+			code:   "unsupported_photo_type",
+		},
+		{
+			name:   "gif not configured",
+			files:  []photoFile{{"photos[]", "photo.gif", []byte("GIF89a\x01\x00\x01\x00")}},
+			status: http.StatusUnsupportedMediaType,
+			code:   "unsupported_photo_type",
+		},
+		{
+			name:   "bad gallery photo",
+			files:  []photoFile{{"photo", "photo.png", pngHeader}, {"photos[]", "notes.png", []byte("just text")}},
+			status: http.StatusUnsupportedMediaType,
+			code:   "unsupported_photo_type",
+		},
+		{
+			name:   "photo over the limit",
+			files:  []photoFile{{"photo", "photo.png", append(append([]byte{}, pngHeader...), megabyte...)}},
+			status: http.StatusRequestEntityTooLarge,
+			code:   "photo_too_large",
+		},
+		{
+			name:   "too many photos",
+			files:  tooMany,
+			status: http.StatusBadRequest,
+			code:   "too_many_photos",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.MaxPhotoSizeMB = 1
+			cfg.PhotoFormats = []string{"image/jpeg", "image/png", "image/webp"}
+			h, db := newTestHandlerWithConfig(t, cfg)
+
+			body, contentType := perfumeForm(t, tt.files...)
+			r := adminRequest("POST", "/api/parfume", body.String())
+			r.Header.Set("Content-Type", contentType)
+
+			rec := httptest.NewRecorder()
+			h.handleAddPerfume(rec, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			var resp struct {
+				Error APIError `json:"error"`
+			}
+			decodeJSON(t, rec, &resp)
+			if resp.Error.Code != tt.code {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.code)
+			}
+
+			var perfumes int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM parfume`).Scan(&perfumes); err != nil {
+				t.Fatalf("count perfumes: %v", err)
+			}
+			if perfumes != 0 {
+				t.Errorf("%d perfumes created for a rejected upload", perfumes)
+			}
+		})
+	}
+}
+
+func TestAddPerfumeSavesValidPhoto(t *testing.T) {
+	photoDir := inPhotoDir(t)
+	h, _ := newTestHandler(t)
+
+	// The stored name follows the sniffed type, not the client's extension
+	for _, filename := range []string{"photo.png", "shell.php"} {
+		t.Run(filename, func(t *testing.T) {
+			content := encodePNG(t, 64, 64)
+			body, contentType := perfumeForm(t, photoFile{"photo", filename, content})
+			r := adminRequest("POST", "/api/parfume", body.String())
+			r.Header.Set("Content-Type", contentType)
+
+			rec := httptest.NewRecorder()
+			h.handleAddPerfume(rec, r)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				ID string `json:"id"`
+			}
+			decodeJSON(t, rec, &resp)
+
+			perfume, err := h.parfumeRepo.GetByID(r.Context(), resp.ID)
+			if err != nil {
+				t.Fatalf("get perfume: %v", err)
+			}
+			if filepath.Ext(perfume.PhotoPath) != ".png" {
+				t.Errorf("photo path = %q, want a .png", perfume.PhotoPath)
+			}
+			saved, err := os.ReadFile(filepath.Join(photoDir, perfume.PhotoPath))
+			if err != nil || !bytes.Equal(saved, content) {
+				t.Errorf("saved photo = %d bytes, %v; want the uploaded %d", len(saved), err, len(content))
+			}
+		})
+	}
+}
+
+func TestAddPerfumeGeneratesThumbnails(t *testing.T) {
+	photoDir := inPhotoDir(t)
+	h, _ := newTestHandler(t)
+
+	tests := []struct {
+		name             string
+		width, height    int
+		thumbW, thumbH   int
+		mediumW, mediumH int
+	}{
+		{"large photo", 1200, 800, 200, 133, 600, 400},
+		{"small photo is not upscaled", 150, 100, 150, 100, 150, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := perfumeForm(t, photoFile{"photo", "photo.png", encodePNG(t, tt.width, tt.height)})
+			r := adminRequest("POST", "/api/parfume", body.String())
+			r.Header.Set("Content-Type", contentType)
+
+			rec := httptest.NewRecorder()
+			h.handleAddPerfume(rec, r)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				ThumbPath string `json:"thumb_path"`
+				Photos    []struct {
+					Filename       string `json:"filename"`
+					MediumFilename string `json:"medium_filename"`
+				} `json:"photos"`
+			}
+			decodeJSON(t, rec, &resp)
+			if len(resp.Photos) != 1 {
+				t.Fatalf("photos = %+v, want the uploaded one", resp.Photos)
+			}
+			original := resp.Photos[0].Filename
+			if want := service.ThumbnailFilename(original, service.ThumbnailSizeThumb); resp.ThumbPath != want {
+				t.Errorf("thumb_path = %q, want %q", resp.ThumbPath, want)
+			}
+
+			for _, variant := range []struct {
+				filename      string
+				width, height int
+			}{
+				{resp.ThumbPath, tt.thumbW, tt.thumbH},
+				{resp.Photos[0].MediumFilename, tt.mediumW, tt.mediumH},
+			} {
+				file, err := os.Open(filepath.Join(photoDir, variant.filename))
+				if err != nil {
+					t.Fatalf("open variant: %v", err)
+				}
+				config, format, err := image.DecodeConfig(file)
+				file.Close()
+				if err != nil {
+					t.Fatalf("decode %s: %v", variant.filename, err)
+				}
+				if format != "jpeg" || config.Width != variant.width || config.Height != variant.height {
+					t.Errorf("%s = %s %dx%d, want jpeg %dx%d", variant.filename, format, config.Width, config.Height, variant.width, variant.height)
+				}
+			}
+		})
+	}
+}
+
+func TestAddPerfumeRejectsOversizedBody(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.MaxPhotoSizeMB = 1
+	h, _ := newTestHandlerWithConfig(t, cfg)
+
+	// Bigger than every allowed photo together, cut off while the form is read
+	content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, (maxPhotosPerUpload+2)<<20)...)
+	body, contentType := perfumeForm(t, photoFile{"photo", "photo.png", content})
+	r := adminRequest("POST", "/api/parfume", body.String())
+	r.Header.Set("Content-Type", contentType)
+
+	rec := httptest.NewRecorder()
+	h.handleAddPerfume(rec, r)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "request_too_large") {
+		t.Errorf("status = %d, body %s; want 413 request_too_large", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAddPerfumeRejectsNonMultipartBody(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	r := adminRequest("POST", "/api/parfume", `{"name": "Baccarat Rouge"}`)
+	r.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	h.handleAddPerfume(rec, r)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_form") {
+		t.Errorf("status = %d, body %s; want 400 invalid_form", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeletePerfumePhotoRequiresAdmin(t *testing.T) {
+	inPhotoDir(t)
+	h, _ := newTestHandler(t)
+
+	body, contentType := perfumeForm(t, photoFile{"photo", "photo.png", encodePNG(t, 64, 64)})
+	r := adminRequest("POST", "/api/parfume", body.String())
+	r.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.handleAddPerfume(rec, r)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		ID     string `json:"id"`
+		Photos []struct {
+			ID int64 `json:"id"`
+		} `json:"photos"`
+	}
+	decodeJSON(t, rec, &resp)
+	if len(resp.Photos) != 1 {
+		t.Fatalf("photos = %+v, want the uploaded one", resp.Photos)
+	}
+	target := fmt.Sprintf("/api/parfume/%s/photos/%d", resp.ID, resp.Photos[0].ID)
+
+	rec = httptest.NewRecorder()
+	h.handlePerfumeRoutes(rec, httptest.NewRequest("DELETE", target, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401: %s", rec.Code, rec.Body.String())
+	}
+	if photos, err := h.photoRepo.GetByParfume(r.Context(), resp.ID); err != nil || len(photos) != 1 {
+		t.Fatalf("photos after unauthenticated delete = %d, %v; want the photo kept", len(photos), err)
+	}
+
+	// The gallery stays public
+	rec = httptest.NewRecorder()
+	h.handlePerfumeRoutes(rec, httptest.NewRequest("GET", "/api/parfume/"+resp.ID+"/photos", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("gallery status = %d, want 200", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.handlePerfumeRoutes(rec, adminRequest("DELETE", target, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if photos, err := h.photoRepo.GetByParfume(r.Context(), resp.ID); err != nil || len(photos) != 0 {
+		t.Errorf("photos after admin delete = %d, %v; want none", len(photos), err)
+	}
+}
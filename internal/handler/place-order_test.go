@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// checkoutForm is a valid Mini App checkout of two 30ml kits for user 7001
+func checkoutForm() map[string]string {
+	return map[string]string{
+		"telegram_id":  "7001",
+		"fio":          "Айгерим Серікова",
+		"contact":      "+77011234567",
+		"address":      "Алматы, Абая 1",
+		"cart_data":    `[{"id":"p1","name":"Baccarat Rouge","quantity":2,"price":2499}]`,
+		"total_amount": "4998",
+	}
+}
+
+// placeOrder posts the checkout form, with an Idempotency-Key when key is set
+func placeOrder(t *testing.T, h *Handler, key string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.handlePlaceOrder(rec, formRequest(t, "/api/order/place", key, fields))
+	return rec
+}
+
+func TestPlaceOrderWithSameIdempotencyKeyCreatesOneOrder(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	first := placeOrder(t, h, "checkout-7001-1", checkoutForm())
+	if first.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", first.Code, first.Body.String())
+	}
+	repeat := placeOrder(t, h, "checkout-7001-1", checkoutForm())
+	if repeat.Code != http.StatusOK {
+		t.Fatalf("repeat status = %d, want 200: %s", repeat.Code, repeat.Body.String())
+	}
+	if repeat.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("repeat wasn't marked as replayed")
+	}
+
+	var placed, replayed struct {
+		OrderID int64 `json:"order_id"`
+	}
+	decodeJSON(t, first, &placed)
+	decodeJSON(t, repeat, &replayed)
+	if placed.OrderID == 0 || replayed.OrderID != placed.OrderID {
+		t.Errorf("order ids = %d, %d; want the first order replayed", placed.OrderID, replayed.OrderID)
+	}
+
+	var orders, items int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM orders WHERE id_user = 7001`).Scan(&orders); err != nil {
+		t.Fatalf("count orders: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM order_items`).Scan(&items); err != nil {
+		t.Fatalf("count items: %v", err)
+	}
+	if orders != 1 || items != 1 {
+		t.Errorf("orders = %d, items = %d; want one of each", orders, items)
+	}
+
+	// A new key is a new checkout
+	if rec := placeOrder(t, h, "checkout-7001-2", checkoutForm()); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM orders WHERE id_user = 7001`).Scan(&orders); err != nil {
+		t.Fatalf("count orders: %v", err)
+	}
+	if orders != 2 {
+		t.Errorf("orders = %d, want a second order for a new key", orders)
+	}
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+)
+
+func bulkAssign(h *Handler, query, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleBulkAssignPrizes(rec, adminRequest("POST", "/api/admin/prizes/bulk-assign"+query, body))
+	return rec
+}
+
+// orderGift reads an order's prize straight from the table, "" for none
+func orderGift(t *testing.T, db *sql.DB, orderID int64) string {
+	t.Helper()
+
+	var gift sql.NullString
+	if err := db.QueryRow(`SELECT gift FROM orders WHERE id = ?`, orderID).Scan(&gift); err != nil {
+		t.Fatalf("read gift: %v", err)
+	}
+	return gift.String
+}
+
+func TestBulkAssignRejectsBatchWithInvalidEntries(t *testing.T) {
+	h, db := newTestHandler(t)
+	first := seedOrder(t, db, domain.Order{IDUser: 3001})
+	second := seedOrder(t, db, domain.Order{IDUser: 3002})
+
+	body := fmt.Sprintf(`[
+		{"order_id": %d, "prize": "parfum_10ml"},
+		{"order_id": %d, "prize": "parfum_30ml"},
+		{"order_id": %d, "prize": "parfum_30ml"},
+		{"order_id": 0, "prize": "money"},
+		{"order_id": 99998, "prize": "yacht"},
+		{"order_id": 99999, "prize": "money"}
+	]`, first.ID, second.ID, first.ID)
+
+	rec := bulkAssign(h, "", body)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Details struct {
+				Rejected []struct {
+					OrderID int64  `json:"order_id"`
+					Error   string `json:"error"`
+				} `json:"rejected"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Error.Code != "invalid_assignments" {
+		t.Errorf("code = %q, want invalid_assignments", resp.Error.Code)
+	}
+	rejected := resp.Error.Details.Rejected
+	want := []string{"duplicate order_id", "invalid order_id", "unknown prize", "order not found"}
+	if len(rejected) != len(want) {
+		t.Fatalf("rejected = %+v, want %d entries", rejected, len(want))
+	}
+	for i, reason := range want {
+		if rejected[i].Error != reason {
+			t.Errorf("rejected[%d] = %q, want %q", i, rejected[i].Error, reason)
+		}
+	}
+
+	for _, order := range []domain.Order{first, second} {
+		if gift := orderGift(t, db, order.ID); gift != "" {
+			t.Errorf("order %d gift = %q, want nothing assigned", order.ID, gift)
+		}
+	}
+}
+
+func TestBulkAssignPartialAssignsValidEntries(t *testing.T) {
+	h, db := newTestHandler(t)
+	first := seedOrder(t, db, domain.Order{IDUser: 3011})
+	second := seedOrder(t, db, domain.Order{IDUser: 3012})
+	setOrderPrize(t, db, second.ID, Prize10ML)
+
+	body := fmt.Sprintf(`[
+		{"order_id": %d, "prize": "parfum_30ml"},
+		{"order_id": %d, "prize": "money"},
+		{"order_id": 99999, "prize": "money"},
+		{"order_id": %d, "prize": "yacht"}
+	]`, first.ID, second.ID, first.ID)
+
+	rec := bulkAssign(h, "?partial=1", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Success  bool `json:"success"`
+		Partial  bool `json:"partial"`
+		Assigned []struct {
+			OrderID       int64  `json:"order_id"`
+			Prize         string `json:"prize"`
+			PreviousPrize string `json:"previous_prize"`
+		} `json:"assigned"`
+		Rejected []struct {
+			OrderID int64  `json:"order_id"`
+			Error   string `json:"error"`
+		} `json:"rejected"`
+	}
+	decodeJSON(t, rec, &resp)
+	if !resp.Success || !resp.Partial {
+		t.Errorf("response = %+v, want a partial success", resp)
+	}
+	if len(resp.Assigned) != 2 || resp.Assigned[1].PreviousPrize != Prize10ML {
+		t.Errorf("assigned = %+v, want both orders with the second's previous prize", resp.Assigned)
+	}
+	if len(resp.Rejected) != 2 || resp.Rejected[0].Error != "order not found" || resp.Rejected[1].Error != "duplicate order_id" {
+		t.Errorf("rejected = %+v, want the missing order and the duplicate", resp.Rejected)
+	}
+
+	if gift := orderGift(t, db, first.ID); gift != Prize30ML {
+		t.Errorf("first gift = %q, want %q", gift, Prize30ML)
+	}
+	if gift := orderGift(t, db, second.ID); gift != PrizeMoney {
+		t.Errorf("second gift = %q, want %q", gift, PrizeMoney)
+	}
+
+	spins, err := repository.NewOrderRepository(db).GetSpins(context.Background(), domain.SpinFilter{})
+	if err != nil {
+		t.Fatalf("get spins: %v", err)
+	}
+	if len(spins) != 2 {
+		t.Fatalf("spins = %+v, want one per assignment", spins)
+	}
+	for _, spin := range spins {
+		if spin.Source != domain.SpinSourceAdmin {
+			t.Errorf("spin source = %q, want %q", spin.Source, domain.SpinSourceAdmin)
+		}
+	}
+}
+
+func TestBulkAssignOutOfStockGetsFallback(t *testing.T) {
+	h, db := newTestHandler(t)
+	order := seedOrder(t, db, domain.Order{IDUser: 3021})
+
+	if _, err := db.Exec(`UPDATE prize_inventory SET awarded = total WHERE prize = ?`, PrizeDiamond); err != nil {
+		t.Fatalf("use up rings: %v", err)
+	}
+
+	rec := bulkAssign(h, "", fmt.Sprintf(`[{"order_id": %d, "prize": "diamond_ring"}]`, order.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Assigned []struct {
+			Prize      string `json:"prize"`
+			OutOfStock string `json:"out_of_stock"`
+		} `json:"assigned"`
+	}
+	decodeJSON(t, rec, &resp)
+	if len(resp.Assigned) != 1 || resp.Assigned[0].OutOfStock != PrizeDiamond || resp.Assigned[0].Prize == PrizeDiamond {
+		t.Fatalf("assigned = %+v, want the fallback for an out of stock ring", resp.Assigned)
+	}
+	if gift := orderGift(t, db, order.ID); gift != resp.Assigned[0].Prize {
+		t.Errorf("gift = %q, want %q", gift, resp.Assigned[0].Prize)
+	}
+}
+
+func TestAssignPrizesRollsBackOnMissingOrder(t *testing.T) {
+	_, db := newTestHandler(t)
+	order := seedOrder(t, db, domain.Order{IDUser: 3031})
+
+	_, err := repository.NewOrderRepository(db).AssignPrizes(context.Background(), []domain.PrizeAssignment{
+		{OrderID: order.ID, Prize: PrizeDiamond},
+		{OrderID: 99999, Prize: Prize10ML},
+	})
+	if err == nil {
+		t.Fatal("AssignPrizes succeeded for a missing order")
+	}
+	if gift := orderGift(t, db, order.ID); gift != "" {
+		t.Errorf("gift = %q, want the first assignment rolled back", gift)
+	}
+
+	var awarded int
+	if err := db.QueryRow(`SELECT awarded FROM prize_inventory WHERE prize = ?`, PrizeDiamond).Scan(&awarded); err != nil {
+		t.Fatalf("read inventory: %v", err)
+	}
+	if awarded != 0 {
+		t.Errorf("rings awarded = %d, want the stock rolled back", awarded)
+	}
+}
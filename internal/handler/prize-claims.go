@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// ProcessPrizeClaims reminds the winners who haven't sent a delivery address for their
+// prize after cfg.PrizeReminderDays, and expires the prizes still without one after
+// cfg.PrizeExpiryDays. An expired prize stays on its order for the history but is no
+// longer delivered. With reminders on, a prize only expires once its winner had the
+// days between the two thresholds to answer the reminder.
+func (h *Handler) ProcessPrizeClaims(ctx context.Context) {
+	now := time.Now()
+	reminderDays, expiryDays := h.cfg.PrizeReminderDays, h.cfg.PrizeExpiryDays
+
+	reminded := 0
+	if reminderDays > 0 {
+		orders, err := h.orderRepo.GetPrizesToRemind(ctx, now.AddDate(0, 0, -reminderDays))
+		if err != nil {
+			h.logger.Error("Failed to get prizes to remind", zap.Error(err))
+		}
+		for _, order := range orders {
+			if err := h.orderRepo.MarkPrizeReminded(ctx, order.ID); err != nil {
+				h.logger.Error("Failed to mark prize reminded", zap.Error(err), zap.Int64("order_id", order.ID))
+				continue
+			}
+			h.remindPrizeClaim(ctx, order, expiryDays-reminderDays)
+			h.recordOrderEvent(order.ID, domain.OrderEventPrizeReminded, domain.OrderEventActorSystem, map[string]interface{}{
+				"prize": order.Gift,
+			})
+			reminded++
+		}
+	}
+
+	expired := 0
+	if expiryDays > 0 {
+		var remindedBefore time.Time
+		if reminderDays > 0 {
+			remindedBefore = now.AddDate(0, 0, -(expiryDays - reminderDays))
+		}
+		orders, err := h.orderRepo.GetPrizesToExpire(ctx, now.AddDate(0, 0, -expiryDays), remindedBefore)
+		if err != nil {
+			h.logger.Error("Failed to get prizes to expire", zap.Error(err))
+		}
+		for _, order := range orders {
+			ok, err := h.orderRepo.ExpirePrize(ctx, order.ID)
+			if err != nil {
+				h.logger.Error("Failed to expire prize", zap.Error(err), zap.Int64("order_id", order.ID))
+				continue
+			}
+			if !ok {
+				// Claimed since it was listed
+				continue
+			}
+			h.recordOrderEvent(order.ID, domain.OrderEventPrizeExpired, domain.OrderEventActorSystem, map[string]interface{}{
+				"prize":      order.Gift,
+				"awarded_at": order.PrizeAwardedAt,
+			})
+			if !h.silent {
+				lang := h.userLang(ctx, order.IDUser)
+				h.enqueueMessage(order.IDUser, order.ID, &bot.SendMessageParams{
+					ChatID: order.IDUser,
+					Text:   i18n.T(lang, i18n.PrizeClaimExpired, prizeName(lang, order.Gift), order.ID),
+				})
+			}
+			expired++
+		}
+	}
+
+	h.logger.Info("Processed unclaimed prizes",
+		zap.Int("reminded", reminded),
+		zap.Int("expired", expired))
+}
+
+// remindPrizeClaim asks the winner of an order to send the delivery address for its prize.
+// daysLeft is how long they have until it expires.
+func (h *Handler) remindPrizeClaim(ctx context.Context, order domain.Order, daysLeft int) {
+	if h.silent {
+		return
+	}
+
+	lang := h.userLang(ctx, order.IDUser)
+	text := i18n.T(lang, i18n.PrizeClaimReminder, prizeName(lang, order.Gift), order.ID)
+	if h.cfg.PrizeExpiryDays > 0 {
+		text += "\n\n" + i18n.T(lang, i18n.PrizeClaimDaysLeft, daysLeft)
+	}
+	params := &bot.SendMessageParams{
+		ChatID: order.IDUser,
+		Text:   text,
+	}
+	if h.cfg.BaseURL != "" {
+		params.ReplyMarkup = &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: i18n.T(lang, i18n.PrizeClaimButton), WebApp: &models.WebAppInfo{URL: h.cfg.BaseURL + "/prize"}}},
+			},
+		}
+	}
+	h.enqueueMessage(order.IDUser, order.ID, params)
+}
+
+// prizeClaimDeadline is when a pending prize expires at the earliest, nil for a prize that
+// isn't pending, when prizes don't expire or when the award time isn't known. A winner
+// reminded late still gets the days between the two thresholds after the reminder.
+func (h *Handler) prizeClaimDeadline(order domain.Order) *time.Time {
+	if order.PrizeClaim() != domain.PrizeClaimPending || h.cfg.PrizeExpiryDays == 0 || order.PrizeAwardedAt == nil {
+		return nil
+	}
+	deadline := order.PrizeAwardedAt.AddDate(0, 0, h.cfg.PrizeExpiryDays)
+	if h.cfg.PrizeReminderDays > 0 && order.PrizeRemindedAt != nil {
+		afterReminder := order.PrizeRemindedAt.AddDate(0, 0, h.cfg.PrizeExpiryDays-h.cfg.PrizeReminderDays)
+		if afterReminder.After(deadline) {
+			deadline = afterReminder
+		}
+	}
+	return &deadline
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+)
+
+func TestPrizeDisplayName(t *testing.T) {
+	tests := []struct {
+		code string
+		kz   string
+		ru   string
+	}{
+		{Prize10ML, "🧪 10мл парфюм", "🧪 Парфюм 10мл"},
+		{Prize30ML, "🧪 30мл парфюм", "🧪 Парфюм 30мл"},
+		{PrizeDiamond, "💍 Бриллиант сақина", "💍 Бриллиантовое кольцо"},
+		{PrizeMoney, "💰 100,000 теңге", "💰 100,000 тенге"},
+		{"yacht", "yacht", "yacht"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := PrizeDisplayName(tt.code); got != tt.kz {
+			t.Errorf("PrizeDisplayName(%q) = %q, want %q", tt.code, got, tt.kz)
+		}
+		if got := prizeName(i18n.LangRu, tt.code); got != tt.ru {
+			t.Errorf("prizeName(ru, %q) = %q, want %q", tt.code, got, tt.ru)
+		}
+	}
+}
+
+func TestGetPrizeHistory(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	won := seedOrder(t, db, domain.Order{IDUser: 6301, Parfumes: "Baccarat Rouge: 1"})
+	setOrderPrize(t, db, won.ID, Prize30ML)
+	seedOrder(t, db, domain.Order{IDUser: 6301})
+
+	rec := httptest.NewRecorder()
+	h.GetPrizeHistory(rec, httptest.NewRequest("GET", "/api/prize/history?telegram_id=6301", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Total  int `json:"total"`
+		Prizes []struct {
+			OrderID   int64  `json:"order_id"`
+			Prize     string `json:"prize"`
+			PrizeName string `json:"prize_name"`
+			Parfumes  string `json:"parfumes"`
+			Completed bool   `json:"completed"`
+		} `json:"prizes"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Total != 1 || len(resp.Prizes) != 1 {
+		t.Fatalf("response = %+v, want one prize", resp)
+	}
+	prize := resp.Prizes[0]
+	if prize.OrderID != won.ID || prize.Prize != Prize30ML || prize.PrizeName != PrizeDisplayName(Prize30ML) || prize.Parfumes != "Baccarat Rouge: 1" || prize.Completed {
+		t.Errorf("prize = %+v, want the unclaimed 30ml prize", prize)
+	}
+
+	for _, query := range []string{"", "?telegram_id=abc"} {
+		rec := httptest.NewRecorder()
+		h.GetPrizeHistory(rec, httptest.NewRequest("GET", "/api/prize/history"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
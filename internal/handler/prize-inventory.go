@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"parfum/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// limitedPrizes are the prizes the wheel awards from a limited stock, see
+// OrderRepository.AwardPrize
+var limitedPrizes = []string{PrizeDiamond, PrizeMoney}
+
+// prizeInventoryTopUp adds amount to the stock of prize
+type prizeInventoryTopUp struct {
+	Prize  string `json:"prize"`
+	Amount int    `json:"amount"`
+}
+
+// Show or top up the stock of the limited prizes
+// GET /api/admin/prizes/inventory
+// POST /api/admin/prizes/inventory {"prize":"diamond_ring","amount":5}
+func (h *Handler) handlePrizeInventory(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		var req prizeInventoryTopUp
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
+			return
+		}
+		if !slices.Contains(limitedPrizes, req.Prize) {
+			writeJSONError(w, http.StatusBadRequest, "invalid_prize", "prize must be one of the limited prizes", map[string]interface{}{
+				"prizes": limitedPrizes,
+			})
+			return
+		}
+		if req.Amount <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_amount", "amount must be a positive number", nil)
+			return
+		}
+
+		item, err := h.orderRepo.TopUpPrizeInventory(r.Context(), req.Prize, req.Amount)
+		if err != nil {
+			h.logger.Error("Error topping up prize inventory", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+			return
+		}
+		h.logger.Info("Prize inventory topped up",
+			zap.String("prize", req.Prize),
+			zap.Int("amount", req.Amount),
+			zap.Int("total", item.Total),
+			zap.Int("remaining", item.Remaining()))
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	inventory, err := h.orderRepo.GetPrizeInventory(r.Context())
+	if err != nil {
+		h.logger.Error("Error getting prize inventory", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"inventory": prizeInventoryJSON(inventory),
+	})
+}
+
+func prizeInventoryJSON(inventory []domain.PrizeInventory) []map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(inventory))
+	for _, item := range inventory {
+		items = append(items, map[string]interface{}{
+			"prize":      item.Prize,
+			"prize_name": PrizeDisplayName(item.Prize),
+			"total":      item.Total,
+			"awarded":    item.Awarded,
+			"remaining":  item.Remaining(),
+			"updated_at": item.UpdatedAt,
+		})
+	}
+	return items
+}
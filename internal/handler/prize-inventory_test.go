@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func prizeInventoryRequest(h *Handler, method, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handlePrizeInventory(rec, adminRequest(method, "/api/admin/prizes/inventory", body))
+	return rec
+}
+
+type inventoryItem struct {
+	Prize     string `json:"prize"`
+	Total     int    `json:"total"`
+	Awarded   int    `json:"awarded"`
+	Remaining int    `json:"remaining"`
+}
+
+// diamondStock reads the ring stock from a prize inventory response
+func diamondStock(t *testing.T, rec *httptest.ResponseRecorder) inventoryItem {
+	t.Helper()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("prize inventory: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Inventory []inventoryItem `json:"inventory"`
+	}
+	decodeJSON(t, rec, &resp)
+	for _, item := range resp.Inventory {
+		if item.Prize == PrizeDiamond {
+			return item
+		}
+	}
+	t.Fatalf("inventory = %+v, want the rings listed", resp.Inventory)
+	return inventoryItem{}
+}
+
+func TestEleventhDiamondPositionGetsFallback(t *testing.T) {
+	h, db := newTestHandler(t)
+	ctx := context.Background()
+
+	// Every order is a ring position; the 11th is also on the 30ml interval
+	positions := make([]int, 13)
+	for i := range positions {
+		positions[i] = i + 1
+	}
+	rules := &domain.PrizeRules{MoneyInterval: 1000, DiamondsPer1000: 1, ML30Interval: 11, DiamondPositions: positions}
+	if _, err := h.orderRepo.SavePrizeRules(ctx, rules); err != nil {
+		t.Fatalf("save prize rules: %v", err)
+	}
+
+	spin := func(sequence int) string {
+		t.Helper()
+
+		userID := int64(7800 + sequence)
+		seedOrder(t, db, domain.Order{IDUser: userID, Parfumes: "Baccarat Rouge: 1"})
+		rec := httptest.NewRecorder()
+		h.SpinWheel(rec, httptest.NewRequest("POST", "/api/prize/spin", strings.NewReader(fmt.Sprintf(`{"telegram_id": %d}`, userID))))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("spin %d: status = %d: %s", sequence, rec.Code, rec.Body.String())
+		}
+		var resp SpinWheelResponse
+		decodeJSON(t, rec, &resp)
+		return resp.PrizeWon
+	}
+
+	for sequence := 1; sequence <= 10; sequence++ {
+		if prize := spin(sequence); prize != PrizeDiamond {
+			t.Fatalf("order %d won %q, want one of the 10 rings", sequence, prize)
+		}
+	}
+	if prize := spin(11); prize != Prize30ML {
+		t.Errorf("11th ring position won %q, want the 30ml on its interval", prize)
+	}
+	if prize := spin(12); prize != Prize10ML {
+		t.Errorf("12th ring position won %q, want 10ml", prize)
+	}
+
+	if n := countRows(t, db, `SELECT COUNT(*) FROM spins WHERE drawn_prize = ? AND prize != drawn_prize`, PrizeDiamond); n != 2 {
+		t.Errorf("spins drawn as a ring but given the fallback = %d, want 2", n)
+	}
+	if stock := diamondStock(t, prizeInventoryRequest(h, "GET", "")); stock.Total != 10 || stock.Awarded != 10 || stock.Remaining != 0 {
+		t.Errorf("ring stock = %+v, want all 10 awarded", stock)
+	}
+
+	// A top up gives the next ring position its ring again
+	stock := diamondStock(t, prizeInventoryRequest(h, "POST", fmt.Sprintf(`{"prize": %q, "amount": 1}`, PrizeDiamond)))
+	if stock.Total != 11 || stock.Remaining != 1 {
+		t.Errorf("ring stock after top up = %+v, want 1 left of 11", stock)
+	}
+	if prize := spin(13); prize != PrizeDiamond {
+		t.Errorf("ring position after top up won %q, want a ring", prize)
+	}
+}
+
+func TestPrizeInventoryRejectsBadTopUp(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, tc := range []struct {
+		name, body, code string
+	}{
+		{"bad json", `{"prize":`, "invalid_json"},
+		{"unlimited prize", fmt.Sprintf(`{"prize": %q, "amount": 5}`, Prize10ML), "invalid_prize"},
+		{"no amount", fmt.Sprintf(`{"prize": %q}`, PrizeMoney), "invalid_amount"},
+		{"negative amount", fmt.Sprintf(`{"prize": %q, "amount": -1}`, PrizeMoney), "invalid_amount"},
+	} {
+		rec := prizeInventoryRequest(h, "POST", tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tc.name, rec.Code)
+			continue
+		}
+		var resp struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		decodeJSON(t, rec, &resp)
+		if resp.Error.Code != tc.code {
+			t.Errorf("%s: code = %q, want %q", tc.name, resp.Error.Code, tc.code)
+		}
+	}
+
+	if stock := diamondStock(t, prizeInventoryRequest(h, "GET", "")); stock.Total != 10 || stock.Awarded != 0 {
+		t.Errorf("ring stock = %+v, want the budgeted 10 untouched", stock)
+	}
+}
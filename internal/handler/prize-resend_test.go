@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func resendPrize(h *Handler, orderID int64) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleAdminOrderRoutes(rec, adminRequest("POST", fmt.Sprintf("/api/admin/order/%d/resend-prize", orderID), ""))
+	return rec
+}
+
+func TestResendPrizeSendsMessages(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	h.SetBot(tg.bot)
+
+	order := seedOrder(t, db, domain.Order{
+		IDUser:   2001,
+		UserName: "winner",
+		Parfumes: "Baccarat Rouge",
+		FIO:      "Айгерим Серікова",
+		Contact:  "+77011234567",
+		Address:  "Алматы, Абая 1",
+	})
+	setOrderPrize(t, db, order.ID, PrizeDiamond)
+
+	rec := resendPrize(h, order.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Success  bool          `json:"success"`
+		Prize    string        `json:"prize"`
+		Delivery PrizeDelivery `json:"delivery"`
+	}
+	decodeJSON(t, rec, &resp)
+	if !resp.Success || resp.Prize != PrizeDiamond || !resp.Delivery.User.Delivered {
+		t.Errorf("response = %+v, want the user message delivered", resp)
+	}
+	if len(resp.Delivery.Admins) != 2 {
+		t.Fatalf("admin deliveries = %+v, want two", resp.Delivery.Admins)
+	}
+	for _, admin := range resp.Delivery.Admins {
+		if !admin.Delivered {
+			t.Errorf("admin %d not delivered: %s", admin.ChatID, admin.Error)
+		}
+	}
+
+	userMessages := tg.messages(order.IDUser)
+	if len(userMessages) != 1 || !strings.Contains(userMessages[0], order.Address) {
+		t.Errorf("user messages = %q, want one with the address", userMessages)
+	}
+	adminMessages := tg.messages(h.cfg.AdminID)
+	if len(adminMessages) != 1 || !strings.Contains(adminMessages[0], order.Contact) {
+		t.Errorf("admin messages = %q, want one with the contact", adminMessages)
+	}
+}
+
+func TestResendPrizeReportsFailedDelivery(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	h.SetBot(tg.bot)
+
+	order := seedOrder(t, db, domain.Order{IDUser: 2002, FIO: "Test", Contact: "+77011234567", Address: "Астана"})
+	setOrderPrize(t, db, order.ID, PrizeMoney)
+
+	// The user's message is the first one sent; a 400 isn't retried
+	tg.failNext("sendMessage", 1)
+
+	rec := resendPrize(h, order.ID)
+	var resp struct {
+		Success  bool          `json:"success"`
+		Delivery PrizeDelivery `json:"delivery"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Success || resp.Delivery.User.Delivered || resp.Delivery.User.Error == "" {
+		t.Errorf("response = %+v, want the user delivery failed with its error", resp)
+	}
+}
+
+func TestResendPrizeRejects(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+
+	noPrize := seedOrder(t, db, domain.Order{IDUser: 2003, FIO: "Test", Contact: "+77011234567", Address: "Астана"})
+	noAddress := seedOrder(t, db, domain.Order{IDUser: 2004})
+	setOrderPrize(t, db, noAddress.ID, Prize30ML)
+	complete := seedOrder(t, db, domain.Order{IDUser: 2005, FIO: "Test", Contact: "+77011234567", Address: "Астана"})
+	setOrderPrize(t, db, complete.ID, Prize30ML)
+
+	tests := []struct {
+		name    string
+		orderID int64
+		withBot bool
+		status  int
+	}{
+		{"unknown order", 9999, true, http.StatusNotFound},
+		{"no prize", noPrize.ID, true, http.StatusBadRequest},
+		{"no delivery details", noAddress.ID, true, http.StatusConflict},
+		{"no bot", complete.ID, false, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		h.bot = nil
+		if tt.withBot {
+			h.bot = tg.bot
+		}
+		if rec := resendPrize(h, tt.orderID); rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body.String())
+		}
+	}
+	if n := tg.count("sendMessage"); n != 0 {
+		t.Errorf("%d messages sent for rejected resends", n)
+	}
+}
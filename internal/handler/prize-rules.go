@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// defaultPrizeRules are the rules the wheel draws by until admins save their own: money
+// on every 200th order, a ring on every 100th and at the fixed positions, 30ml on every 30th
+var defaultPrizeRules = domain.PrizeRules{
+	Version:          0,
+	MoneyInterval:    200,
+	DiamondsPer1000:  10,
+	ML30Interval:     30,
+	DiamondPositions: []int{50, 150, 250, 350, 450, 550, 650, 750, 850, 950},
+}
+
+// activePrizeRules returns the rules spins draw by. Rules that can't be read leave the
+// built-in ones.
+func (h *Handler) activePrizeRules(ctx context.Context) domain.PrizeRules {
+	rules, err := h.orderRepo.GetActivePrizeRules(ctx)
+	if errors.Is(err, repository.ErrNoPrizeRules) {
+		return defaultPrizeRules
+	}
+	if err != nil {
+		h.logger.Warn("Failed to get prize rules, using the built-in ones", zap.Error(err))
+		return defaultPrizeRules
+	}
+	return *rules
+}
+
+// validatePrizeRules checks rules before they are saved. Interval rings can't be more per
+// 1000 orders than the ring stock allows.
+func validatePrizeRules(rules domain.PrizeRules, inventory []domain.PrizeInventory) error {
+	if rules.MoneyInterval <= 0 {
+		return errors.New("money_interval must be greater than 0")
+	}
+	if rules.ML30Interval <= 0 {
+		return errors.New("ml30_interval must be greater than 0")
+	}
+	if rules.DiamondsPer1000 <= 0 || rules.DiamondsPer1000 > 1000 {
+		return errors.New("diamonds_per_1000 must be between 1 and 1000")
+	}
+	for i, position := range rules.DiamondPositions {
+		if position <= 0 {
+			return fmt.Errorf("diamond position %d must be greater than 0", position)
+		}
+		if slices.Contains(rules.DiamondPositions[:i], position) {
+			return fmt.Errorf("diamond position %d is listed twice", position)
+		}
+	}
+
+	for _, item := range inventory {
+		if item.Prize == PrizeDiamond && rules.DiamondsPer1000 > item.Total {
+			return fmt.Errorf("diamonds_per_1000 %d is more than the %d rings in stock", rules.DiamondsPer1000, item.Total)
+		}
+	}
+	return nil
+}
+
+// prizeRulesPreview counts the prizes rules draw for the first 1000 orders, before any
+// stock runs out
+func (h *Handler) prizeRulesPreview(rules domain.PrizeRules) map[string]int {
+	preview := make(map[string]int, len(prizeWheelSectors))
+	for _, prize := range prizeWheelSectors {
+		preview[prize] = 0
+	}
+	for sequence := 1; sequence <= 1000; sequence++ {
+		preview[h.DeterminePrize(rules, sequence)]++
+	}
+	return preview
+}
+
+// Show or change the rules the prize wheel draws by
+// GET /api/admin/prizes/rules[?version=N]
+// PUT /api/admin/prizes/rules {"money_interval":200,"diamonds_per_1000":10,"ml30_interval":30,"diamond_positions":[50,150]}
+// A PUT saves a new version; spins record the version they were drawn by. Version 0 is
+// the built-in rules.
+func (h *Handler) handlePrizeRules(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var rules domain.PrizeRules
+	switch r.Method {
+	case "GET":
+		versionStr := r.URL.Query().Get("version")
+		if versionStr == "" {
+			rules = h.activePrizeRules(r.Context())
+			break
+		}
+
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil || version < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid_version", "version must be a number", nil)
+			return
+		}
+		if version == 0 {
+			rules = defaultPrizeRules
+			break
+		}
+		saved, err := h.orderRepo.GetPrizeRules(r.Context(), version)
+		if errors.Is(err, repository.ErrNoPrizeRules) {
+			writeJSONError(w, http.StatusNotFound, "prize_rules_not_found", "No prize rules with this version", nil)
+			return
+		}
+		if err != nil {
+			h.logger.Error("Error getting prize rules", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+			return
+		}
+		rules = *saved
+	case "PUT":
+		var req domain.PrizeRules
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
+			return
+		}
+		if req.DiamondPositions == nil {
+			req.DiamondPositions = []int{}
+		}
+		slices.Sort(req.DiamondPositions)
+
+		inventory, err := h.orderRepo.GetPrizeInventory(r.Context())
+		if err != nil {
+			h.logger.Error("Error getting prize inventory", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+			return
+		}
+		if err := validatePrizeRules(req, inventory); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_prize_rules", err.Error(), nil)
+			return
+		}
+
+		saved, err := h.orderRepo.SavePrizeRules(r.Context(), &req)
+		if err != nil {
+			h.logger.Error("Error saving prize rules", zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+			return
+		}
+		rules = *saved
+		h.logger.Info("Prize rules changed",
+			zap.Int64("version", rules.Version),
+			zap.Int("money_interval", rules.MoneyInterval),
+			zap.Int("diamonds_per_1000", rules.DiamondsPer1000),
+			zap.Int("ml30_interval", rules.ML30Interval),
+			zap.Ints("diamond_positions", rules.DiamondPositions))
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"rules":    rules,
+		"per_1000": h.prizeRulesPreview(rules),
+	})
+}
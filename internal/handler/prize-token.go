@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"parfum/config"
+
+	"go.uber.org/zap"
+)
+
+// prizeWheelSectors are the prizes on the wheel, in the order of PRIZES in
+// static/prize.html. The index of a prize is the sector the wheel animation stops on.
+var prizeWheelSectors = []string{Prize10ML, PrizeDiamond, Prize30ML, PrizeMoney}
+
+// prizeSector returns the wheel sector of prize, -1 if the wheel has none
+func prizeSector(prize string) int {
+	return slices.Index(prizeWheelSectors, prize)
+}
+
+var (
+	errPrizeTokenInvalid = errors.New("prize token is invalid")
+	errPrizeTokenExpired = errors.New("prize token has expired")
+)
+
+// newPrizeTokenSecret returns the key prize tokens are signed with. Without a configured
+// one a random key is made, so tokens issued before a restart stop working; the prize
+// itself stays on the order and a new token can be fetched from /api/prize/token.
+func newPrizeTokenSecret(cfg *config.Config, logger *zap.Logger) ([]byte, error) {
+	if cfg.PrizeTokenSecret != "" {
+		return []byte(cfg.PrizeTokenSecret), nil
+	}
+
+	logger.Warn("PRIZE_TOKEN_SECRET is not set, prize tokens won't survive a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate prize token secret: %w", err)
+	}
+	return secret, nil
+}
+
+// signPrizeToken issues the token that lets the owner of an order complete its prize. It
+// is "<unix issue time>.<HMAC of order ID, prize and issue time>".
+func (h *Handler) signPrizeToken(orderID int64, prize string, issuedAt time.Time) string {
+	issued := strconv.FormatInt(issuedAt.Unix(), 10)
+	return issued + "." + base64.RawURLEncoding.EncodeToString(h.prizeTokenMAC(orderID, prize, issued))
+}
+
+// verifyPrizeToken checks that token was issued for the order's prize and hasn't expired
+func (h *Handler) verifyPrizeToken(token string, orderID int64, prize string, now time.Time) error {
+	issued, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return errPrizeTokenInvalid
+	}
+	issuedUnix, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return errPrizeTokenInvalid
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !hmac.Equal(sum, h.prizeTokenMAC(orderID, prize, issued)) {
+		return errPrizeTokenInvalid
+	}
+
+	if now.After(time.Unix(issuedUnix, 0).Add(h.prizeTokenTTL())) {
+		return errPrizeTokenExpired
+	}
+	return nil
+}
+
+func (h *Handler) prizeTokenMAC(orderID int64, prize, issued string) []byte {
+	mac := hmac.New(sha256.New, h.prizeSecret)
+	fmt.Fprintf(mac, "%d:%s:%s", orderID, prize, issued)
+	return mac.Sum(nil)
+}
+
+func (h *Handler) prizeTokenTTL() time.Duration {
+	return time.Duration(h.cfg.PrizeTokenTTLMinutes) * time.Minute
+}
+
+// GetPrizeToken re-reads the prize of a user's order with a new token, for when the one
+// from the spin expired or was lost before the address form was sent. The caller proves
+// who they are with the Telegram WebApp initData in the X-Telegram-Init-Data header.
+// GET /api/prize/token?telegram_id=&order_id=
+func (h *Handler) GetPrizeToken(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(r.URL.Query().Get("telegram_id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "Invalid telegram_id", nil)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(r.URL.Query().Get("order_id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_order_id", "Invalid order_id", nil)
+		return
+	}
+
+	// Anyone can send a telegram_id; only Telegram can sign the initData of its user
+	userID, err := verifyInitData(r.Header.Get(initDataHeader), h.cfg.Token, time.Now())
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "invalid_init_data", "Telegram WebApp init data is missing or invalid", nil)
+		return
+	}
+	if userID != telegramID {
+		writeJSONError(w, http.StatusForbidden, "order_forbidden", "Order does not belong to user", nil)
+		return
+	}
+
+	order, err := h.orderRepo.GetByID(r.Context(), orderID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "order_not_found", "Order not found", nil)
+		return
+	}
+
+	if order.IDUser != telegramID {
+		writeJSONError(w, http.StatusForbidden, "order_forbidden", "Order does not belong to user", nil)
+		return
+	}
+
+	if order.Gift == "" || order.Gift == "null" {
+		writeJSONError(w, http.StatusBadRequest, "no_prize_assigned", "Order has no prize assigned", nil)
+		return
+	}
+
+	if order.PrizeExpiredAt != nil {
+		writeJSONError(w, http.StatusGone, "prize_expired", "The prize was not claimed in time", map[string]interface{}{
+			"expired_at": order.PrizeExpiredAt,
+		})
+		return
+	}
+
+	now := time.Now()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":                true,
+		"order_id":               orderID,
+		"prize_won":              order.Gift,
+		"sector_index":           prizeSector(order.Gift),
+		"prize_token":            h.signPrizeToken(orderID, order.Gift, now),
+		"prize_token_expires_at": now.Add(h.prizeTokenTTL()),
+	})
+}
@@ -0,0 +1,499 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"parfum/config"
+	"parfum/internal/domain"
+	"parfum/internal/handler"
+	"parfum/internal/handler/fakes"
+)
+
+const wheelUser = 8001
+
+// newFakeHandler builds a Handler on in-memory stores. configure changes the default
+// config first, nil for none.
+func newFakeHandler(t *testing.T, configure func(cfg *config.Config)) (*handler.Handler, *fakes.Set) {
+	t.Helper()
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	cfg.PrizeTokenSecret = "test-secret"
+	cfg.TelegramSendRate = 1000
+	if configure != nil {
+		configure(cfg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	stores := fakes.New(ctx)
+	h, err := handler.NewHandlerWithStores(cfg, zap.NewNop(), ctx, stores.Stores())
+	if err != nil {
+		t.Fatalf("NewHandlerWithStores: %v", err)
+	}
+	return h, stores
+}
+
+// selectedOrder is a paid order of wheelUser with its perfumes chosen
+func selectedOrder() domain.Order {
+	quantity := 1
+	return domain.Order{IDUser: wheelUser, Quantity: &quantity, Parfumes: "Baccarat Rouge: 1"}
+}
+
+type spinResponse struct {
+	handler.SpinWheelResponse
+	Error handler.APIError `json:"error"`
+}
+
+func TestSpinWheel(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		setup     func(t *testing.T, stores *fakes.Set)
+		body      string
+
+		wantStatus int
+		wantCode   string // error code, for a refused spin
+		wantPrize  string
+		wantDrawn  string // the prize drawn before the stock check, if it differs
+		wantSpins  int    // spins recorded
+	}{
+		{
+			name:       "first order gets 10ml",
+			setup:      func(t *testing.T, stores *fakes.Set) { stores.Orders.Add(selectedOrder()) },
+			wantStatus: http.StatusOK,
+			wantPrize:  handler.Prize10ML,
+			wantSpins:  1,
+		},
+		{
+			name: "money on its interval",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				stores.Orders.SetPrizeRules(domain.PrizeRules{Version: 2, MoneyInterval: 2, DiamondsPer1000: 1, ML30Interval: 3})
+				stores.Orders.Add(domain.Order{IDUser: 1, Parfumes: "Lost Cherry: 1"})
+				stores.Orders.Add(selectedOrder())
+			},
+			wantStatus: http.StatusOK,
+			wantPrize:  handler.PrizeMoney,
+			wantSpins:  1,
+		},
+		{
+			name: "30ml on its interval",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				stores.Orders.SetPrizeRules(domain.PrizeRules{Version: 2, MoneyInterval: 100, DiamondsPer1000: 1, ML30Interval: 1})
+				stores.Orders.Add(selectedOrder())
+			},
+			wantStatus: http.StatusOK,
+			wantPrize:  handler.Prize30ML,
+			wantSpins:  1,
+		},
+		{
+			name: "ring out of stock gets the fallback",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				stores.Orders.SetPrizeRules(domain.PrizeRules{Version: 2, MoneyInterval: 100, DiamondsPer1000: 1, ML30Interval: 3, DiamondPositions: []int{1}})
+				stores.Orders.SetStock(handler.PrizeDiamond, 0)
+				stores.Orders.Add(selectedOrder())
+			},
+			wantStatus: http.StatusOK,
+			wantPrize:  handler.Prize10ML,
+			wantDrawn:  handler.PrizeDiamond,
+			wantSpins:  1,
+		},
+		{
+			name:       "order without perfumes",
+			setup:      func(t *testing.T, stores *fakes.Set) { stores.Orders.Add(domain.Order{IDUser: wheelUser}) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "order spun before",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				order := stores.Orders.Add(selectedOrder())
+				stores.Orders.RecordSpin(context.Background(), &domain.Spin{OrderID: order.ID, TelegramID: wheelUser, Prize: handler.Prize10ML})
+			},
+			wantStatus: http.StatusOK,
+			wantSpins:  1,
+		},
+		{
+			name:      "cooldown running",
+			configure: func(cfg *config.Config) { cfg.SpinCooldownSeconds = 60 },
+			setup: func(t *testing.T, stores *fakes.Set) {
+				stores.Orders.Add(selectedOrder())
+				stores.State.StartSpinCooldown(context.Background(), wheelUser, time.Minute)
+			},
+			wantStatus: http.StatusTooManyRequests,
+			wantCode:   "spin_cooldown",
+		},
+		{
+			name:      "daily cap reached",
+			configure: func(cfg *config.Config) { cfg.DailySpinCap = 1 },
+			setup: func(t *testing.T, stores *fakes.Set) {
+				stores.Orders.Add(selectedOrder())
+				stores.State.IncrDailySpins(context.Background(), wheelUser, time.Now().Format("2006-01-02"))
+			},
+			wantStatus: http.StatusTooManyRequests,
+			wantCode:   "daily_spin_cap",
+		},
+		{
+			name:       "invalid JSON",
+			body:       "{",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_json",
+		},
+		{
+			name:       "no telegram_id",
+			body:       "{}",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "missing_telegram_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newFakeHandler(t, tt.configure)
+			if tt.setup != nil {
+				tt.setup(t, stores)
+			}
+			body := tt.body
+			if body == "" {
+				body = `{"telegram_id": 8001}`
+			}
+
+			rec := httptest.NewRecorder()
+			h.SpinWheel(rec, httptest.NewRequest("POST", "/api/prize/spin", strings.NewReader(body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			var resp spinResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Error.Code != tt.wantCode {
+				t.Errorf("error code = %q, want %q", resp.Error.Code, tt.wantCode)
+			}
+			if resp.PrizeWon != tt.wantPrize {
+				t.Errorf("prize = %q, want %q", resp.PrizeWon, tt.wantPrize)
+			}
+
+			spins := stores.Orders.Spins()
+			if len(spins) != tt.wantSpins {
+				t.Fatalf("spins = %+v, want %d", spins, tt.wantSpins)
+			}
+			if tt.wantPrize == "" {
+				return
+			}
+
+			if !resp.Success || resp.PrizeToken == "" || resp.SectorIndex == nil {
+				t.Errorf("response = %+v, want a success with a prize token and sector", resp.SpinWheelResponse)
+			}
+			order, _ := stores.Orders.Order(resp.OrderID)
+			if order.Gift != tt.wantPrize || order.PrizeAwardedAt == nil {
+				t.Errorf("order gift = %q awarded at %v, want %q", order.Gift, order.PrizeAwardedAt, tt.wantPrize)
+			}
+			wantDrawn := tt.wantDrawn
+			if wantDrawn == "" {
+				wantDrawn = tt.wantPrize
+			}
+			if spin := spins[len(spins)-1]; spin.Prize != tt.wantPrize || spin.DrawnPrize != wantDrawn || spin.Source != domain.SpinSourceWheel {
+				t.Errorf("spin = %+v, want %q drawn as %q from the wheel", spin, tt.wantPrize, wantDrawn)
+			}
+			if events := stores.Orders.Events(); len(events) != 1 || events[0].EventType != domain.OrderEventPrizeWon {
+				t.Errorf("events = %+v, want one prize_won", events)
+			}
+		})
+	}
+}
+
+func TestCheckSpinEligibility(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		setup     func(t *testing.T, stores *fakes.Set)
+		query     string
+
+		wantStatus    int
+		wantCanSpin   bool
+		wantAvailable int
+		wantLeft      int
+		wantUnclaimed int
+	}{
+		{
+			name:       "no orders",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "two orders to spin for",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				stores.Orders.Add(selectedOrder())
+				stores.Orders.Add(selectedOrder())
+				stores.Orders.Add(domain.Order{IDUser: wheelUser}) // perfumes not chosen yet
+			},
+			wantStatus:    http.StatusOK,
+			wantCanSpin:   true,
+			wantAvailable: 2,
+			wantLeft:      2,
+		},
+		{
+			name: "cancelled and spun orders don't count",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				cancelled := selectedOrder()
+				cancelled.Status = domain.OrderStatusCancelled
+				stores.Orders.Add(cancelled)
+				spun := stores.Orders.Add(selectedOrder())
+				stores.Orders.RecordSpin(context.Background(), &domain.Spin{OrderID: spun.ID, TelegramID: wheelUser})
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:      "daily cap leaves one of two",
+			configure: func(cfg *config.Config) { cfg.DailySpinCap = 2 },
+			setup: func(t *testing.T, stores *fakes.Set) {
+				stores.Orders.Add(selectedOrder())
+				stores.Orders.Add(selectedOrder())
+				stores.State.IncrDailySpins(context.Background(), wheelUser, time.Now().Format("2006-01-02"))
+			},
+			wantStatus:    http.StatusOK,
+			wantCanSpin:   true,
+			wantAvailable: 2,
+			wantLeft:      1,
+		},
+		{
+			name: "won prize waits for an address",
+			setup: func(t *testing.T, stores *fakes.Set) {
+				order := stores.Orders.Add(selectedOrder())
+				if _, _, err := stores.Orders.AwardPrize(context.Background(), order.ID, handler.Prize30ML, handler.Prize10ML); err != nil {
+					t.Fatalf("award prize: %v", err)
+				}
+			},
+			wantStatus:    http.StatusOK,
+			wantUnclaimed: 1,
+		},
+		{
+			name:       "invalid telegram_id",
+			query:      "?telegram_id=abc",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no telegram_id",
+			query:      "?",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newFakeHandler(t, tt.configure)
+			if tt.setup != nil {
+				tt.setup(t, stores)
+			}
+			query := tt.query
+			if query == "" {
+				query = "?telegram_id=8001"
+			}
+
+			rec := httptest.NewRecorder()
+			h.CheckSpinEligibility(rec, httptest.NewRequest("GET", "/api/prize/check"+query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				CanSpin         bool                     `json:"can_spin"`
+				SpinsAvailable  int                      `json:"spins_available"`
+				SpinsLeft       int                      `json:"spins_left"`
+				UnclaimedPrizes []map[string]interface{} `json:"unclaimed_prizes"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.CanSpin != tt.wantCanSpin || resp.SpinsAvailable != tt.wantAvailable || resp.SpinsLeft != tt.wantLeft {
+				t.Errorf("can_spin = %v, spins_available = %d, spins_left = %d; want %v, %d, %d",
+					resp.CanSpin, resp.SpinsAvailable, resp.SpinsLeft, tt.wantCanSpin, tt.wantAvailable, tt.wantLeft)
+			}
+			if len(resp.UnclaimedPrizes) != tt.wantUnclaimed {
+				t.Errorf("unclaimed prizes = %v, want %d", resp.UnclaimedPrizes, tt.wantUnclaimed)
+			}
+		})
+	}
+}
+
+// spin spins the wheel for wheelUser
+func spin(t *testing.T, h *handler.Handler) (int, spinResponse) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.SpinWheel(rec, httptest.NewRequest("POST", "/api/prize/spin", strings.NewReader(`{"telegram_id": 8001}`)))
+	var resp spinResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return rec.Code, resp
+}
+
+func TestSpinLimitsHoldBackSecondSpin(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+
+		wantSpinsLeft int       // after the first spin
+		wantNext      time.Time // roughly, after the first spin
+		wantCode      string    // of the second spin
+	}{
+		{
+			name:          "cooldown",
+			configure:     func(cfg *config.Config) { cfg.SpinCooldownSeconds = 3 },
+			wantSpinsLeft: 1,
+			wantNext:      time.Now().Add(3 * time.Second),
+			wantCode:      "spin_cooldown",
+		},
+		{
+			name: "daily cap",
+			configure: func(cfg *config.Config) {
+				cfg.SpinCooldownSeconds = 0
+				cfg.DailySpinCap = 1
+			},
+			wantSpinsLeft: 0,
+			wantNext:      time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day()+1, 0, 0, 0, 0, time.Local),
+			wantCode:      "daily_spin_cap",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, stores := newFakeHandler(t, tt.configure)
+			stores.Orders.Add(selectedOrder())
+			stores.Orders.Add(selectedOrder())
+
+			status, first := spin(t, h)
+			if status != http.StatusOK || first.PrizeWon == "" {
+				t.Fatalf("first spin = %d %+v, want a prize", status, first)
+			}
+			if first.SpinsLeft != tt.wantSpinsLeft {
+				t.Errorf("spins_left = %d, want %d", first.SpinsLeft, tt.wantSpinsLeft)
+			}
+			if first.NextSpinAt == nil || first.NextSpinAt.Sub(tt.wantNext).Abs() > time.Second {
+				t.Errorf("next_spin_at = %v, want about %v", first.NextSpinAt, tt.wantNext)
+			}
+
+			status, second := spin(t, h)
+			if status != http.StatusTooManyRequests || second.Error.Code != tt.wantCode {
+				t.Fatalf("second spin = %d %+v, want 429 %s", status, second, tt.wantCode)
+			}
+			if next, ok := second.Error.Details["next_spin_at"].(string); !ok || next == "" {
+				t.Errorf("details = %v, want next_spin_at", second.Error.Details)
+			}
+			if spins := stores.Orders.Spins(); len(spins) != 1 {
+				t.Errorf("spins = %+v, want only the first", spins)
+			}
+		})
+	}
+}
+
+// racingOrders holds AwardPrize back until every spin counted in arrived called it, so
+// parallel spins have all read the order before any of them writes. A spin that never
+// gets there releases the others after a while.
+type racingOrders struct {
+	handler.OrderStore
+	arrived sync.WaitGroup
+}
+
+func (s *racingOrders) AwardPrize(ctx context.Context, orderID int64, prize, fallback string) (string, bool, error) {
+	s.arrived.Done()
+	all := make(chan struct{})
+	go func() {
+		s.arrived.Wait()
+		close(all)
+	}()
+	select {
+	case <-all:
+	case <-time.After(5 * time.Second):
+	}
+	return s.OrderStore.AwardPrize(ctx, orderID, prize, fallback)
+}
+
+func TestParallelSpinsAwardOnePrize(t *testing.T) {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	cfg.PrizeTokenSecret = "test-secret"
+	cfg.SpinCooldownSeconds = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	stores := fakes.New(ctx)
+	orders := &racingOrders{OrderStore: stores.Orders}
+	orders.arrived.Add(2)
+	set := stores.Stores()
+	set.Orders = orders
+	h, err := handler.NewHandlerWithStores(cfg, zap.NewNop(), ctx, set)
+	if err != nil {
+		t.Fatalf("NewHandlerWithStores: %v", err)
+	}
+
+	stores.Orders.SetPrizeRules(domain.PrizeRules{Version: 2, MoneyInterval: 100, DiamondsPer1000: 1, ML30Interval: 3, DiamondPositions: []int{1, 2}})
+	stores.Orders.SetStock(handler.PrizeDiamond, 2)
+	stores.Orders.Add(selectedOrder())
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	responses := make([]spinResponse, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h.SpinWheel(rec, httptest.NewRequest("POST", "/api/prize/spin", strings.NewReader(`{"telegram_id": 8001}`)))
+			codes[i] = rec.Code
+			json.NewDecoder(rec.Body).Decode(&responses[i])
+		}()
+	}
+	wg.Wait()
+
+	for i, resp := range responses {
+		if codes[i] != http.StatusOK || !resp.Success || resp.PrizeWon != handler.PrizeDiamond {
+			t.Errorf("spin %d: status = %d, response = %+v; want the ring", i, codes[i], resp)
+		}
+	}
+	if responses[0].OrderID != responses[1].OrderID {
+		t.Errorf("orders = %d and %d, want both spins on the same order", responses[0].OrderID, responses[1].OrderID)
+	}
+
+	if spins := stores.Orders.Spins(); len(spins) != 1 {
+		t.Errorf("spins = %+v, want the winner's only", spins)
+	}
+	if events := stores.Orders.Events(); len(events) != 1 {
+		t.Errorf("events = %+v, want one prize_won", events)
+	}
+
+	// The loser took no ring from the stock, so the next ring position still gets one
+	other := selectedOrder()
+	other.IDUser = wheelUser + 1
+	stores.Orders.Add(other)
+	plain, err := handler.NewHandlerWithStores(cfg, zap.NewNop(), ctx, stores.Stores())
+	if err != nil {
+		t.Fatalf("NewHandlerWithStores: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	plain.SpinWheel(rec, httptest.NewRequest("POST", "/api/prize/spin", strings.NewReader(`{"telegram_id": 8002}`)))
+	var resp spinResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.PrizeWon != handler.PrizeDiamond {
+		t.Errorf("next ring position won %q, want the ring left in stock", resp.PrizeWon)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func getPrizeWinners(h *Handler, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleGetPrizeWinners(rec, adminRequest("GET", "/api/prizes/winners"+query, ""))
+	return rec
+}
+
+func TestPrizeWinnersGroupsClaimedPrizes(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	// Prize orders and when their winner sent the address, "" for not yet
+	for i, prize := range []struct {
+		gift, claimedAt string
+	}{
+		{PrizeDiamond, "2026-03-02 10:00:00"},
+		{PrizeDiamond, "2026-03-15 18:30:00"},
+		{PrizeMoney, "2026-03-31 23:59:00"},
+		{Prize10ML, "2026-04-01 00:01:00"}, // after the period
+		{Prize30ML, ""},                    // address not sent
+		{"certificate", "2026-03-20 12:00:00"},
+	} {
+		order := seedOrder(t, db, domain.Order{
+			IDUser:   int64(7501 + i),
+			UserName: "winner",
+			Parfumes: "Baccarat Rouge: 1",
+			FIO:      "Айгерим",
+			Contact:  "+77011234567",
+			Address:  "Алматы",
+		})
+		setOrderPrize(t, db, order.ID, prize.gift)
+		if prize.claimedAt != "" {
+			if _, err := db.Exec(`UPDATE orders SET prize_claimed_at = ? WHERE id = ?`, prize.claimedAt, order.ID); err != nil {
+				t.Fatalf("claim prize: %v", err)
+			}
+		}
+	}
+
+	rec := getPrizeWinners(h, "?from=2026-03-01&to=2026-03-31")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Total   int                `json:"total"`
+		Summary map[string]int     `json:"summary"`
+		Groups  []PrizeWinnerGroup `json:"groups"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Total != 4 {
+		t.Errorf("total = %d, want 4", resp.Total)
+	}
+	wantSummary := map[string]int{PrizeMoney: 1, PrizeDiamond: 2, Prize30ML: 0, Prize10ML: 0, "certificate": 1}
+	for prize, count := range wantSummary {
+		if got, ok := resp.Summary[prize]; !ok || got != count {
+			t.Errorf("summary[%s] = %d (listed %v), want %d", prize, got, ok, count)
+		}
+	}
+
+	var prizes []string
+	for _, group := range resp.Groups {
+		prizes = append(prizes, group.Prize)
+		if group.Count != len(group.Winners) || group.Count != wantSummary[group.Prize] {
+			t.Errorf("group %s: count = %d with %d winners, want %d", group.Prize, group.Count, len(group.Winners), wantSummary[group.Prize])
+		}
+	}
+	if want := []string{PrizeMoney, PrizeDiamond, Prize30ML, Prize10ML, "certificate"}; !slices.Equal(prizes, want) {
+		t.Errorf("groups = %q, want %q", prizes, want)
+	}
+
+	diamonds := resp.Groups[1].Winners
+	if len(diamonds) == 2 && (diamonds[0].CompletedAt.After(diamonds[1].CompletedAt) || diamonds[0].Contact != "+77011234567") {
+		t.Errorf("diamond winners = %+v, want them by claim time with their contact", diamonds)
+	}
+}
+
+func TestPrizeWinnersRejectsBadDates(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, query := range []string{"?from=01.03.2026", "?to=tomorrow", "?from=2026-04-01&to=2026-03-01"} {
+		if rec := getPrizeWinners(h, query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"parfum/internal/service/i18n"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// PromoCommand is the admin command that shows or changes the start promo caption
+const PromoCommand = "promo"
+
+// Setting keys of the start promo; without them the catalog text and cfg.StartPhotoId apply
+const (
+	promoCaptionSettingPrefix = "promo_caption_" // followed by the language
+	promoPhotoSetting         = "start_photo_id"
+)
+
+// maxPromoCaption is the longest photo caption Telegram sends, in characters
+const maxPromoCaption = 1024
+
+// promoResetArg resets a language's caption to the default in /promo
+const promoResetArg = "reset"
+
+// promoCaption is the start promo caption in lang: the one set by admins, or the catalog's
+func (h *Handler) promoCaption(ctx context.Context, lang string) string {
+	caption, ok := h.promoSetting(ctx, promoCaptionSettingPrefix+lang)
+	if !ok {
+		return i18n.T(lang, i18n.StartPromo)
+	}
+	return caption
+}
+
+// promoPhoto is the file ID of the start promo photo: the one set by admins, or cfg.StartPhotoId
+func (h *Handler) promoPhoto(ctx context.Context) string {
+	photo, ok := h.promoSetting(ctx, promoPhotoSetting)
+	if !ok {
+		return h.cfg.StartPhotoId
+	}
+	return photo
+}
+
+// promoSetting reads a promo setting. A setting that can't be read counts as unset, so the
+// promo still goes out with its default.
+func (h *Handler) promoSetting(ctx context.Context, key string) (string, bool) {
+	value, ok, err := h.settingsRepo.GetSetting(ctx, key)
+	if err != nil {
+		h.logger.Warn("Failed to get promo setting", zap.Error(err), zap.String("key", key))
+		return "", false
+	}
+	return value, ok
+}
+
+// setPromoSetting stores a promo setting, or deletes it when value is empty
+func (h *Handler) setPromoSetting(ctx context.Context, key, value string) error {
+	if value == "" {
+		return h.settingsRepo.DeleteSetting(ctx, key)
+	}
+	return h.settingsRepo.SetSetting(ctx, key, value)
+}
+
+// PromoHandler handles /promo from an admin:
+//
+//	/promo               shows the captions in every language
+//	/promo kz <text>     sets the Kazakh caption and sends a preview
+//	/promo kz reset      goes back to the catalog caption
+func (h *Handler) PromoHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || update.Message.From == nil {
+		return
+	}
+	adminId := update.Message.From.ID
+	if !slices.Contains(h.receiptAdmins(), adminId) {
+		return
+	}
+
+	lang, caption := promoCommandArgs(update.Message.Text)
+	if lang == "" {
+		h.sendAdminText(ctx, b, adminId, h.promoOverview(ctx))
+		return
+	}
+	if !slices.Contains(i18n.Langs, lang) {
+		h.sendAdminText(ctx, b, adminId, fmt.Sprintf("❌ Белгісіз тіл: %s. Тілдер: %s", lang, strings.Join(i18n.Langs, ", ")))
+		return
+	}
+	if caption == "" {
+		h.sendAdminText(ctx, b, adminId, promoUsage)
+		return
+	}
+
+	if caption == promoResetArg {
+		caption = ""
+	} else if n := utf8.RuneCountInString(caption); n > maxPromoCaption {
+		h.sendAdminText(ctx, b, adminId, fmt.Sprintf("❌ Мәтін тым ұзын: %d/%d таңба.", n, maxPromoCaption))
+		return
+	}
+
+	if err := h.setPromoSetting(ctx, promoCaptionSettingPrefix+lang, caption); err != nil {
+		h.logger.Error("Failed to save promo caption", zap.Error(err), zap.String("lang", lang))
+		h.sendAdminText(ctx, b, adminId, "❌ Промо мәтінін сақтау мүмкін болмады, қайталап көріңіз.")
+		return
+	}
+
+	h.logger.Info("Promo caption changed",
+		zap.Int64("admin_id", adminId),
+		zap.String("lang", lang),
+		zap.Bool("reset", caption == ""))
+
+	if caption == "" {
+		h.sendAdminText(ctx, b, adminId, fmt.Sprintf("✅ Промо мәтіні (%s) әдепкіге қайтарылды. Қолданушылар былай көреді:", lang))
+	} else {
+		h.sendAdminText(ctx, b, adminId, fmt.Sprintf("✅ Промо мәтіні (%s) сақталды. Қолданушылар былай көреді:", lang))
+	}
+	h.sendPromo(ctx, b, adminId, lang)
+}
+
+const promoUsage = "ℹ️ Қолданылуы:\n" +
+	"/promo — қазіргі промо мәтіндері\n" +
+	"/promo kz <мәтін> — қазақша промо мәтінін өзгерту\n" +
+	"/promo ru <мәтін> — орысша промо мәтінін өзгерту\n" +
+	"/promo kz reset — әдепкі мәтінге қайтару"
+
+// promoCommandArgs splits "/promo <lang> <caption>" into its language and caption. The
+// caption keeps its line breaks.
+func promoCommandArgs(text string) (string, string) {
+	i := strings.IndexFunc(text, unicode.IsSpace)
+	if i < 0 {
+		return "", ""
+	}
+	args := strings.TrimSpace(text[i:])
+	if args == "" {
+		return "", ""
+	}
+
+	if i := strings.IndexFunc(args, unicode.IsSpace); i >= 0 {
+		return strings.ToLower(args[:i]), strings.TrimSpace(args[i:])
+	}
+	return strings.ToLower(args), ""
+}
+
+// promoOverview lists the promo caption of every language for /promo
+func (h *Handler) promoOverview(ctx context.Context) string {
+	lines := []string{"📣 Қазіргі промо мәтіндері:"}
+	for _, lang := range i18n.Langs {
+		caption, custom := h.promoSetting(ctx, promoCaptionSettingPrefix+lang)
+		source := "өзгертілген"
+		if !custom {
+			caption = i18n.T(lang, i18n.StartPromo)
+			source = "әдепкі"
+		}
+		lines = append(lines, "", fmt.Sprintf("🌐 %s (%s):", lang, source), caption)
+	}
+	lines = append(lines, "", promoUsage)
+	return strings.Join(lines, "\n")
+}
+
+// promoSettings is the start promo as admins see it: the caption of every language and the
+// photo, each with whether it was changed from its default
+type promoSettings struct {
+	Captions    map[string]promoValue `json:"captions"`
+	PhotoID     string                `json:"photo_id"`
+	PhotoCustom bool                  `json:"photo_custom"`
+}
+
+type promoValue struct {
+	Caption string `json:"caption"`
+	Custom  bool   `json:"custom"`
+}
+
+// promoUpdate changes the start promo; an empty caption or photo_id goes back to the default
+type promoUpdate struct {
+	Lang    string  `json:"lang"`
+	Caption *string `json:"caption"`
+	PhotoID *string `json:"photo_id"`
+}
+
+// Show or change the start promo caption and photo
+// GET /api/admin/promo
+// PUT /api/admin/promo {"lang":"kz","caption":"...","photo_id":"..."}
+func (h *Handler) handlePromoSettings(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "PUT":
+		var req promoUpdate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
+			return
+		}
+		if req.Caption == nil && req.PhotoID == nil {
+			writeJSONError(w, http.StatusBadRequest, "missing_fields", "caption or photo_id required", nil)
+			return
+		}
+		if req.Caption != nil {
+			if !slices.Contains(i18n.Langs, req.Lang) {
+				writeJSONError(w, http.StatusBadRequest, "invalid_lang", "lang must be one of the supported languages", map[string]interface{}{
+					"langs": i18n.Langs,
+				})
+				return
+			}
+			if n := utf8.RuneCountInString(*req.Caption); n > maxPromoCaption {
+				writeJSONError(w, http.StatusBadRequest, "caption_too_long", "Caption is longer than Telegram allows", map[string]interface{}{
+					"length": n,
+					"max":    maxPromoCaption,
+				})
+				return
+			}
+			if err := h.setPromoSetting(r.Context(), promoCaptionSettingPrefix+req.Lang, strings.TrimSpace(*req.Caption)); err != nil {
+				h.logger.Error("Error saving promo caption", zap.Error(err))
+				writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+				return
+			}
+		}
+		if req.PhotoID != nil {
+			if err := h.setPromoSetting(r.Context(), promoPhotoSetting, strings.TrimSpace(*req.PhotoID)); err != nil {
+				h.logger.Error("Error saving promo photo", zap.Error(err))
+				writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+				return
+			}
+		}
+		h.logger.Info("Promo settings changed",
+			zap.String("lang", req.Lang),
+			zap.Bool("caption", req.Caption != nil),
+			zap.Bool("photo", req.PhotoID != nil))
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	settings := promoSettings{Captions: make(map[string]promoValue, len(i18n.Langs))}
+	for _, lang := range i18n.Langs {
+		caption, custom := h.promoSetting(r.Context(), promoCaptionSettingPrefix+lang)
+		if !custom {
+			caption = i18n.T(lang, i18n.StartPromo)
+		}
+		settings.Captions[lang] = promoValue{Caption: caption, Custom: custom}
+	}
+	settings.PhotoID, settings.PhotoCustom = h.promoSetting(r.Context(), promoPhotoSetting)
+	if !settings.PhotoCustom {
+		settings.PhotoID = h.cfg.StartPhotoId
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"promo":   settings,
+	})
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	"parfum/internal/service/i18n"
+)
+
+func promoRequest(h *Handler, method, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handlePromoSettings(rec, adminRequest(method, "/api/admin/promo", body))
+	return rec
+}
+
+// getPromo reads the promo settings as the admin API shows them
+func getPromo(t *testing.T, h *Handler) promoSettings {
+	t.Helper()
+
+	rec := promoRequest(h, "GET", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get promo: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Promo promoSettings `json:"promo"`
+	}
+	decodeJSON(t, rec, &resp)
+	return resp.Promo
+}
+
+// lastPromo returns the caption and photo of the last promo photo sent to chatID
+func (tg *fakeTelegram) lastPromo(chatID int64) (string, string) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+
+	for i := len(tg.calls) - 1; i >= 0; i-- {
+		call := tg.calls[i]
+		if call.Method == "sendPhoto" && call.Params["chat_id"] == strconv.FormatInt(chatID, 10) {
+			return call.Params["caption"], call.Params["photo"]
+		}
+	}
+	return "", ""
+}
+
+func TestPromoSettingsFallBackToDefaults(t *testing.T) {
+	h, db := newTestHandler(t)
+	h.cfg.StartPhotoId = "default-photo"
+
+	promo := getPromo(t, h)
+	for _, lang := range i18n.Langs {
+		want := promoValue{Caption: i18n.T(lang, i18n.StartPromo)}
+		if got := promo.Captions[lang]; got != want {
+			t.Errorf("caption %s = %+v, want the catalog's", lang, got)
+		}
+	}
+	if promo.PhotoID != "default-photo" || promo.PhotoCustom {
+		t.Errorf("photo = %q (custom %v), want the config's", promo.PhotoID, promo.PhotoCustom)
+	}
+
+	// A settings table that can't be read still leaves the defaults
+	if _, err := db.Exec(`DROP TABLE settings`); err != nil {
+		t.Fatalf("drop settings: %v", err)
+	}
+	ctx := context.Background()
+	if got := h.promoCaption(ctx, i18n.LangKz); got != i18n.T(i18n.LangKz, i18n.StartPromo) {
+		t.Errorf("caption = %q, want the catalog's", got)
+	}
+	if got := h.promoPhoto(ctx); got != "default-photo" {
+		t.Errorf("photo = %q, want the config's", got)
+	}
+}
+
+func TestPromoSettingsUpdateAndReset(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	ctx := context.Background()
+	h.cfg.StartPhotoId = "default-photo"
+	const userID = 7701
+
+	if rec := promoRequest(h, "PUT", `{"lang":"kz","caption":"  Жаңа промо  ","photo_id":"new-photo"}`); rec.Code != http.StatusOK {
+		t.Fatalf("put promo: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	promo := getPromo(t, h)
+	if got := promo.Captions[i18n.LangKz]; got != (promoValue{Caption: "Жаңа промо", Custom: true}) {
+		t.Errorf("kz caption = %+v, want the new one", got)
+	}
+	if got := promo.Captions[i18n.LangRu]; got.Custom {
+		t.Errorf("ru caption = %+v, want it left at the default", got)
+	}
+	if promo.PhotoID != "new-photo" || !promo.PhotoCustom {
+		t.Errorf("photo = %q (custom %v), want the new one", promo.PhotoID, promo.PhotoCustom)
+	}
+
+	h.sendPromo(ctx, tg.bot, userID, i18n.LangKz)
+	if caption, photo := tg.lastPromo(userID); caption != "Жаңа промо" || photo != "new-photo" {
+		t.Errorf("sent promo = %q with %q, want the new caption and photo", caption, photo)
+	}
+
+	// Empty values go back to the defaults
+	if rec := promoRequest(h, "PUT", `{"lang":"kz","caption":"","photo_id":""}`); rec.Code != http.StatusOK {
+		t.Fatalf("reset promo: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	h.sendPromo(ctx, tg.bot, userID, i18n.LangKz)
+	if caption, photo := tg.lastPromo(userID); caption != i18n.T(i18n.LangKz, i18n.StartPromo) || photo != "default-photo" {
+		t.Errorf("sent promo = %q with %q, want the defaults", caption, photo)
+	}
+}
+
+func TestPromoSettingsRejectsBadUpdates(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, tc := range []struct {
+		name, body, code string
+	}{
+		{"bad json", `{"lang":`, "invalid_json"},
+		{"nothing to change", `{"lang":"kz"}`, "missing_fields"},
+		{"unknown language", `{"lang":"en","caption":"Hello"}`, "invalid_lang"},
+		{"caption too long", `{"lang":"kz","caption":"` + strings.Repeat("ә", maxPromoCaption+1) + `"}`, "caption_too_long"},
+	} {
+		rec := promoRequest(h, "PUT", tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tc.name, rec.Code)
+			continue
+		}
+		var resp struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		decodeJSON(t, rec, &resp)
+		if resp.Error.Code != tc.code {
+			t.Errorf("%s: code = %q, want %q", tc.name, resp.Error.Code, tc.code)
+		}
+	}
+
+	if promo := getPromo(t, h); promo.Captions[i18n.LangKz].Custom || promo.PhotoCustom {
+		t.Errorf("promo = %+v, want nothing changed", promo)
+	}
+}
+
+func TestPromoCommandFromAdmin(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	ctx := context.Background()
+	admin, user := h.cfg.AdminID, int64(7702)
+
+	promo := func(from int64, text string) {
+		h.PromoHandler(ctx, tg.bot, &models.Update{Message: &models.Message{
+			From: &models.User{ID: from},
+			Chat: models.Chat{ID: from},
+			Text: text,
+		}})
+	}
+
+	promo(user, "/promo kz Бөгде мәтін")
+	if got := h.promoCaption(ctx, i18n.LangKz); got != i18n.T(i18n.LangKz, i18n.StartPromo) {
+		t.Errorf("caption = %q after a non-admin /promo, want the default", got)
+	}
+
+	promo(admin, "/promo kz Бірінші жол\nЕкінші жол")
+	if got := h.promoCaption(ctx, i18n.LangKz); got != "Бірінші жол\nЕкінші жол" {
+		t.Errorf("caption = %q, want the admin's with its line break", got)
+	}
+	if caption, _ := tg.lastPromo(admin); caption != "Бірінші жол\nЕкінші жол" {
+		t.Errorf("preview caption = %q, want the new caption", caption)
+	}
+
+	promo(admin, "/promo kz reset")
+	if got := h.promoCaption(ctx, i18n.LangKz); got != i18n.T(i18n.LangKz, i18n.StartPromo) {
+		t.Errorf("caption = %q after reset, want the default", got)
+	}
+}
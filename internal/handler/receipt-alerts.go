@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"go.uber.org/zap"
+)
+
+// receiptFailureHour is the counting window of receipt failures, e.g. "2025-01-31T14"
+func receiptFailureHour(now time.Time) string {
+	return now.UTC().Format("2006-01-02T15")
+}
+
+// recordReceiptFailure counts a receipt that failed validation and alerts admins once a
+// user, or all users together, fail more often in the hour than the configured
+// thresholds. Both kinds of alert have a cooldown, and when both trip at once admins get
+// a single message.
+func (h *Handler) recordReceiptFailure(ctx context.Context, userId int64, receiptPath string) {
+	if h.silent {
+		return
+	}
+
+	userFailures, globalFailures, err := h.redisRepo.CountReceiptFailure(ctx, userId, receiptFailureHour(time.Now()), receiptPath)
+	if err != nil {
+		h.logger.Warn("Failed to count receipt failure", zap.Error(err), zap.Int64("user_id", userId))
+		return
+	}
+
+	cooldown := time.Duration(h.cfg.ReceiptAlertCooldownMin) * time.Minute
+	userAlert := h.cfg.ReceiptAlertUserFailures > 0 && userFailures > h.cfg.ReceiptAlertUserFailures &&
+		h.startAlertCooldown(ctx, fmt.Sprintf("receipt_user:%d", userId), cooldown)
+	globalAlert := h.cfg.ReceiptAlertGlobalFailures > 0 && globalFailures > h.cfg.ReceiptAlertGlobalFailures &&
+		h.startAlertCooldown(ctx, "receipt_global", cooldown)
+	if !userAlert && !globalAlert {
+		return
+	}
+
+	paths, err := h.redisRepo.GetRecentReceiptFailures(ctx)
+	if err != nil {
+		h.logger.Warn("Failed to get recent receipt failures", zap.Error(err))
+	}
+
+	h.logger.Warn("Repeated receipt failures",
+		zap.Int64("user_id", userId),
+		zap.Int("user_failures", userFailures),
+		zap.Int("global_failures", globalFailures))
+
+	text := receiptFailureAlertText(userId, userFailures, globalFailures, userAlert, globalAlert, paths)
+	for _, adminID := range h.receiptAdmins() {
+		if adminID != 0 {
+			h.enqueueMessage(adminID, 0, &bot.SendMessageParams{
+				ChatID: adminID,
+				Text:   text,
+			})
+		}
+	}
+}
+
+// startAlertCooldown reports whether an alert may be sent now, starting its cooldown if
+// so. An alert isn't sent when the cooldown can't be checked, so a broken store can't
+// flood admins.
+func (h *Handler) startAlertCooldown(ctx context.Context, alert string, cooldown time.Duration) bool {
+	started, err := h.redisRepo.StartAlertCooldown(ctx, alert, cooldown)
+	if err != nil {
+		h.logger.Warn("Failed to start alert cooldown", zap.Error(err), zap.String("alert", alert))
+		return false
+	}
+	return started
+}
+
+// receiptFailureAlertText is the admin alert about repeated receipt failures
+func receiptFailureAlertText(userId int64, userFailures, globalFailures int, userAlert, globalAlert bool, paths []string) string {
+	lines := []string{"🚨 Чек тексеруінің қайталанған қателері", ""}
+	if userAlert {
+		lines = append(lines, fmt.Sprintf("👤 UserId %d: соңғы сағатта %d рет", userId, userFailures))
+	}
+	if globalAlert {
+		lines = append(lines, fmt.Sprintf("🌐 Барлық қолданушылар: соңғы сағатта %d рет", globalFailures))
+	}
+	if len(paths) > 0 {
+		lines = append(lines, "", "📄 Соңғы чектер:")
+		for _, path := range paths {
+			lines = append(lines, "  "+path)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"parfum/internal/service"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func TestReceiptFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      *models.Message
+		fileID   string
+		mimeType string
+		ok       bool
+	}{
+		{
+			name:     "pdf document",
+			msg:      &models.Message{Document: &models.Document{FileID: "pdf", MimeType: "application/pdf", FileName: "check.pdf"}},
+			fileID:   "pdf",
+			mimeType: service.ReceiptMimePDF,
+			ok:       true,
+		},
+		{
+			name:     "image document",
+			msg:      &models.Message{Document: &models.Document{FileID: "png", MimeType: "IMAGE/PNG", FileName: "check.png"}},
+			fileID:   "png",
+			mimeType: service.ReceiptMimePNG,
+			ok:       true,
+		},
+		{
+			name:     "document without a type",
+			msg:      &models.Message{Document: &models.Document{FileID: "jpg", MimeType: "application/octet-stream", FileName: "Check.JPG"}},
+			fileID:   "jpg",
+			mimeType: service.ReceiptMimeJPEG,
+			ok:       true,
+		},
+		{
+			name: "compressed photo",
+			msg: &models.Message{Photo: []models.PhotoSize{
+				{FileID: "small", Width: 90},
+				{FileID: "large", Width: 1280},
+			}},
+			fileID:   "large",
+			mimeType: service.ReceiptMimeJPEG,
+			ok:       true,
+		},
+		{
+			name: "text",
+			msg:  &models.Message{Text: "paid"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileID, mimeType, ok := receiptFile(tt.msg)
+			if fileID != tt.fileID || mimeType != tt.mimeType || ok != tt.ok {
+				t.Errorf("receiptFile = %q, %q, %v; want %q, %q, %v", fileID, mimeType, ok, tt.fileID, tt.mimeType, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAcceptsImageReceiptFromConfig(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.ReceiptFormats = []string{service.ReceiptMimePDF, service.ReceiptMimePNG}
+	h, _ := newTestHandlerWithConfig(t, cfg)
+
+	_, mimeType, _ := receiptFile(&models.Message{Document: &models.Document{FileID: "png", FileName: "check.png"}})
+	if !h.acceptsReceiptFormat(mimeType) {
+		t.Errorf("%s receipt not accepted with formats %q", mimeType, cfg.ReceiptFormats)
+	}
+	if !service.IsImageReceipt(mimeType) {
+		t.Errorf("%s receipt doesn't go through the image path", mimeType)
+	}
+	if h.acceptsReceiptFormat(service.ReceiptMimeJPEG) {
+		t.Error("jpeg receipts accepted without being configured")
+	}
+	if got := h.receiptFormatsLabel(); !strings.Contains(got, "PDF") || !strings.Contains(got, "PNG") {
+		t.Errorf("formats label = %q, want PDF and PNG", got)
+	}
+}
+
+func TestPaidHandlerRejectsUnknownFormat(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	const userID = 5001
+
+	h.PaidHandler(context.Background(), tg.bot, &models.Update{Message: &models.Message{
+		From:     &models.User{ID: userID},
+		Chat:     models.Chat{ID: userID},
+		Document: &models.Document{FileID: "doc", MimeType: "application/msword", FileName: "check.doc"},
+	}})
+
+	messages := tg.messages(userID)
+	if len(messages) != 1 || !strings.Contains(messages[0], "PDF") {
+		t.Errorf("messages = %q, want the accepted formats", messages)
+	}
+	if n := tg.count("getFile"); n != 0 {
+		t.Errorf("getFile called %d times for a rejected receipt", n)
+	}
+}
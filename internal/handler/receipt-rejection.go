@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"parfum/internal/service"
+
+	"github.com/go-telegram/bot"
+)
+
+// receiptRejectionReasons name the validation failures for admins
+var receiptRejectionReasons = map[string]string{
+	"unknown_volume": "белгісіз көлем",
+	"wrong_price":    "сумма сәйкес емес",
+	"wrong_bin":      "БСН сәйкес емес",
+}
+
+// receiptRejectionText tells admins why a user's receipt failed validation and what was
+// read from it. The user only gets the catalog message, without the values.
+func receiptRejectionText(userId int64, count int, err error) string {
+	lines := []string{
+		"❌ Чек тексеруден өтпеді",
+		"",
+		fmt.Sprintf("👤 UserId: %d", userId),
+		fmt.Sprintf("🧴 Косметика саны: %d", count),
+	}
+
+	var validationErr service.ValidationError
+	if !errors.As(err, &validationErr) {
+		lines = append(lines, fmt.Sprintf("⚠️ Себебі: %s", err))
+		return strings.Join(lines, "\n")
+	}
+
+	reason, ok := receiptRejectionReasons[validationErr.Type]
+	if !ok {
+		reason = validationErr.Message
+	}
+	lines = append(lines, fmt.Sprintf("⚠️ Себебі: %s (%s)", reason, validationErr.Field))
+
+	details := validationErr.Details
+	switch validationErr.Type {
+	case "wrong_price":
+		lines = append(lines,
+			fmt.Sprintf("💰 Чектегі сумма: %v ₸", details["actual"]),
+			fmt.Sprintf("🎯 Күтілген сумма: %v ₸", details["expected"]),
+			fmt.Sprintf("🏦 Чектегі БСН: %v", details["bin"]))
+	case "wrong_bin":
+		lines = append(lines,
+			fmt.Sprintf("🏦 Чектегі БСН: %v", details["actual"]),
+			fmt.Sprintf("🎯 Рұқсат етілген БСН: %v", details["expected"]),
+			fmt.Sprintf("💰 Чектегі сумма: %v ₸", details["amount"]))
+	case "unknown_volume":
+		lines = append(lines, fmt.Sprintf("🧾 Позициялар: %v", details["lines"]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// notifyReceiptRejected sends admins the details of a receipt that failed validation
+func (h *Handler) notifyReceiptRejected(userId int64, count int, err error) {
+	if h.silent {
+		return
+	}
+
+	text := receiptRejectionText(userId, count, err)
+	for _, adminID := range h.receiptAdmins() {
+		if adminID != 0 {
+			h.enqueueMessage(adminID, 0, &bot.SendMessageParams{
+				ChatID: adminID,
+				Text:   text,
+			})
+		}
+	}
+}
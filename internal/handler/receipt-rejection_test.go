@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func TestRejectedReceiptDetailsGoToAdminsOnly(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.AdminID, cfg.AdminID2 = 8201, 8202
+	cfg.Bin, cfg.Bin2, cfg.Bin3, cfg.Bin4, cfg.Bin5 = 111111111111, 222222222222, 0, 0, 0
+	h, _ := newTestHandlerWithConfig(t, cfg)
+	tg := newFakeTelegram(t)
+	h.SetBot(tg.bot)
+	const userID = 8211
+
+	tests := []struct {
+		name    string
+		receipt domain.PendingReceipt
+		want    []string // lines admins see
+	}{
+		{
+			name:    "wrong price",
+			receipt: domain.PendingReceipt{ActualPrice: 1999, Bin: 111111111111, Qr: "qr-1"},
+			want:    []string{"сумма сәйкес емес (amount)", "Чектегі сумма: 1999 ₸", "Күтілген сумма: 4998 ₸", "Чектегі БСН: 111111111111"},
+		},
+		{
+			name:    "wrong BIN",
+			receipt: domain.PendingReceipt{ActualPrice: 4998, Bin: 999999999999, Qr: "qr-2"},
+			want:    []string{"БСН сәйкес емес (bin)", "Чектегі БСН: 999999999999", "Рұқсат етілген БСН: [111111111111 222222222222]", "Чектегі сумма: 4998 ₸"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt := tt.receipt
+			state := &domain.UserState{State: StateCount, Count: 2, Amount: 4998}
+			h.finalizeReceipt(context.Background(), tg.bot, userID, userID, state, &receipt)
+
+			for _, adminID := range []int64{cfg.AdminID, cfg.AdminID2} {
+				text := tg.waitForMessage(t, adminID, tt.want[0])
+				for _, line := range tt.want[1:] {
+					if !strings.Contains(text, line) {
+						t.Errorf("admin %d message %q has no %q", adminID, text, line)
+					}
+				}
+			}
+		})
+	}
+
+	for _, text := range tg.messages(userID) {
+		if strings.Contains(text, "1999") || strings.Contains(text, "999999999999") {
+			t.Errorf("user message %q shows what was read from the receipt", text)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+)
+
+func saveSelection(h *Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.SavePerfumeSelection(rec, httptest.NewRequest("POST", "/api/perfumes/select", strings.NewReader(body)))
+	return rec
+}
+
+func TestSavePerfumeSelectionChecksPerfumesExist(t *testing.T) {
+	h, db := newTestHandler(t)
+	ctx := context.Background()
+	const userID = 7401
+
+	quantity := 3
+	seedOrder(t, db, domain.Order{IDUser: userID, Quantity: &quantity})
+	perfume := &repository.Product{NameParfume: "Baccarat Rouge", Sex: "Unisex", Price: 2499}
+	if err := repository.NewParfumeRepository(db).Create(ctx, perfume); err != nil {
+		t.Fatalf("create perfume: %v", err)
+	}
+
+	t.Run("unknown perfume", func(t *testing.T) {
+		rec := saveSelection(h, fmt.Sprintf(`{"telegram_id": %d, "selected_perfumes": [
+			{"id": %q, "quantity": 1},
+			{"id": "no-such-id", "quantity": 1},
+			{"name": "Bacarat Rouge", "quantity": 1}
+		]}`, userID, perfume.Id))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+		}
+
+		var resp struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+				Details struct {
+					Perfumes []struct {
+						ID   string `json:"id"`
+						Name string `json:"name"`
+					} `json:"perfumes"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		decodeJSON(t, rec, &resp)
+		if resp.Error.Code != "unknown_perfume" {
+			t.Errorf("code = %q, want unknown_perfume", resp.Error.Code)
+		}
+		unknown := resp.Error.Details.Perfumes
+		if len(unknown) != 2 || unknown[0].ID != "no-such-id" || unknown[1].Name != "Bacarat Rouge" {
+			t.Errorf("unknown perfumes = %+v, want the bad id and the misspelt name", unknown)
+		}
+		if !strings.Contains(resp.Error.Message, "no-such-id") || !strings.Contains(resp.Error.Message, "Bacarat Rouge") {
+			t.Errorf("message = %q, want both unknown perfumes", resp.Error.Message)
+		}
+
+		if cart, err := h.redisRepo.GetCart(ctx, userID); err != nil || len(cart) != 0 {
+			t.Errorf("cart = %+v, %v; want nothing saved", cart, err)
+		}
+	})
+
+	t.Run("valid selection", func(t *testing.T) {
+		rec := saveSelection(h, fmt.Sprintf(`{"telegram_id": %d, "selected_perfumes": [{"id": %q, "quantity": 2}]}`, userID, perfume.Id))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+		}
+
+		cart, err := h.redisRepo.GetCart(ctx, userID)
+		if err != nil {
+			t.Fatalf("get cart: %v", err)
+		}
+		want := domain.CartItem{ParfumeID: perfume.Id, Name: "Baccarat Rouge", Quantity: 2, Price: 2499}
+		if len(cart) != 1 || cart[0] != want {
+			t.Errorf("cart = %+v, want %+v", cart, want)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+)
+
+func TestShareContactStoresNormalizedPhone(t *testing.T) {
+	h, db := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	ctx := context.Background()
+	const userID = 7901
+
+	if err := h.clientRepo.SetLanguage(ctx, userID, i18n.LangKz); err != nil {
+		t.Fatalf("set language: %v", err)
+	}
+	order := seedOrder(t, db, domain.Order{IDUser: userID})
+	state := &domain.UserState{State: StateContact, Count: 1, IsPaid: true, OrderID: order.ID}
+	if err := h.redisRepo.SaveUserState(ctx, userID, state); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	share := func(phone string) {
+		h.ShareContactCallbackHandler(ctx, tg.bot, &models.Update{Message: &models.Message{
+			From:    &models.User{ID: userID},
+			Chat:    models.Chat{ID: userID},
+			Contact: &models.Contact{PhoneNumber: phone, UserID: userID},
+		}})
+	}
+
+	share("12345")
+	if !slices.Contains(tg.messages(userID), i18n.T(i18n.LangKz, i18n.ContactInvalid)) {
+		t.Errorf("messages = %q, want the number rejected", tg.messages(userID))
+	}
+	if state, err := h.redisRepo.GetUserState(ctx, userID); err != nil || state.Contact != "" {
+		t.Fatalf("state = %+v, %v; want no contact saved", state, err)
+	}
+
+	share("8 (701) 123 45 67")
+	got, err := h.orderRepo.GetByID(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("get order: %v", err)
+	}
+	if got.Contact != "+77011234567" {
+		t.Errorf("order contact = %q, want +77011234567", got.Contact)
+	}
+	if !slices.Contains(tg.messages(userID), i18n.T(i18n.LangKz, i18n.ContactReceived)) {
+		t.Errorf("messages = %q, want the contact confirmed", tg.messages(userID))
+	}
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SimulateOrderRequest configures a staging run of the whole order flow.
+// Every field is optional.
+type SimulateOrderRequest struct {
+	TelegramID int64  `json:"telegram_id"` // defaults to a synthetic negative ID
+	ParfumeID  string `json:"parfume_id"`  // defaults to the first active perfume in stock
+	Quantity   int    `json:"quantity"`    // defaults to 1
+}
+
+// SimulationStep is the outcome of one stage of a simulated order
+type SimulationStep struct {
+	Name     string                 `json:"name"`
+	Status   int                    `json:"status"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// handleSimulateOrder runs create order → select perfumes → complete address →
+// spin → complete prize against the real repositories for a synthetic user.
+// It's only served when the simulation is enabled in the config.
+func (h *Handler) handleSimulateOrder(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if !h.cfg.SimulationEnabled {
+		writeJSONError(w, http.StatusNotFound, "not_found", "Not found", nil)
+		return
+	}
+
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	var req SimulateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON", nil)
+		return
+	}
+
+	if req.TelegramID == 0 {
+		// Real Telegram users have positive IDs, so a negative one never collides
+		req.TelegramID = -time.Now().UnixMilli()
+	}
+	if req.Quantity <= 0 {
+		req.Quantity = 1
+	}
+
+	perfume, err := h.simulationPerfume(req.ParfumeID, req.Quantity)
+	if err != nil {
+		h.logger.Error("Error picking perfume for simulation", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+	if perfume == nil {
+		writeJSONError(w, http.StatusConflict, "no_perfume_available", "No active perfume with enough stock to simulate an order", nil)
+		return
+	}
+
+	// Nothing is sent to Telegram for the synthetic user or the admins
+	sim := *h
+	sim.bot = nil
+	sim.silent = true
+
+	telegramID := strconv.FormatInt(req.TelegramID, 10)
+	var steps []SimulationStep
+	fail := func(err error) {
+		h.logger.Error("Order simulation failed", zap.Int64("telegram_id", req.TelegramID), zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "simulation_failed", err.Error(), map[string]interface{}{
+			"telegram_id": req.TelegramID,
+			"steps":       steps,
+		})
+	}
+
+	// 1. The order the bot creates once the receipt is accepted and the contact is shared
+	quantity := req.Quantity
+	order := &domain.Order{
+		IDUser:   req.TelegramID,
+		UserName: "simulation",
+		Quantity: &quantity,
+		Contact:  "+70000000000",
+		DataPay:  time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := h.orderRepo.Create(r.Context(), order); err != nil {
+		fail(fmt.Errorf("create_order: %w", err))
+		return
+	}
+	steps = append(steps, SimulationStep{
+		Name:     "create_order",
+		Status:   http.StatusCreated,
+		Response: map[string]interface{}{"order_id": order.ID},
+	})
+
+	stages := []struct {
+		name    string
+		handler http.HandlerFunc
+		request func() (*http.Request, error)
+	}{
+		{"select_perfumes", sim.SavePerfumeSelection, func() (*http.Request, error) {
+			return simulationJSONRequest("/api/user/save-perfume-selection", map[string]interface{}{
+				"telegram_id": req.TelegramID,
+				"selected_perfumes": []map[string]interface{}{{
+					"id":       perfume.Id,
+					"name":     perfume.NameParfume,
+					"quantity": req.Quantity,
+				}},
+			})
+		}},
+		{"complete_address", sim.UpdateOrderWithClientInfo, func() (*http.Request, error) {
+			return simulationFormRequest("/api/order/complete", map[string]string{
+				"telegram_id": telegramID,
+				"fio":         "Simulation User",
+				"contact":     order.Contact,
+				"address":     "Simulation street 1",
+			})
+		}},
+		{"spin", sim.SpinWheel, func() (*http.Request, error) {
+			return simulationJSONRequest("/api/prize/spin", SpinWheelRequest{TelegramID: req.TelegramID})
+		}},
+		{"complete_prize", sim.CompletePrizeOrder, func() (*http.Request, error) {
+			// The spin step before this one issued the token
+			prizeToken, _ := steps[len(steps)-1].Response["prize_token"].(string)
+			return simulationFormRequest("/api/prize/complete", map[string]string{
+				"telegram_id": telegramID,
+				"order_id":    strconv.FormatInt(order.ID, 10),
+				"fio":         "Simulation User",
+				"contact":     order.Contact,
+				"address":     "Simulation street 1",
+				"prize_token": prizeToken,
+			})
+		}},
+	}
+
+	for _, stage := range stages {
+		stageReq, err := stage.request()
+		if err != nil {
+			fail(fmt.Errorf("%s: %w", stage.name, err))
+			return
+		}
+
+		step, err := runSimulationStep(stage.name, stage.handler, stageReq.WithContext(r.Context()))
+		steps = append(steps, step)
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+
+	record, err := h.orderRepo.GetByID(r.Context(), order.ID)
+	if err != nil {
+		fail(fmt.Errorf("load order: %w", err))
+		return
+	}
+
+	items, err := h.orderItemRepo.GetByOrder(r.Context(), order.ID)
+	if err != nil {
+		fail(fmt.Errorf("load order items: %w", err))
+		return
+	}
+
+	h.logger.Info("Order simulation completed",
+		zap.Int64("telegram_id", req.TelegramID),
+		zap.Int64("order_id", order.ID),
+		zap.String("prize", record.Gift))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"telegram_id": req.TelegramID,
+		"steps":       steps,
+		"order":       record,
+		"items":       items,
+	})
+}
+
+// simulationPerfume returns the requested perfume, or the first active one with
+// enough stock. It returns nil if there's nothing to order.
+func (h *Handler) simulationPerfume(id string, quantity int) (*repository.Product, error) {
+	perfumes, err := h.parfumeRepo.GetAll(h.ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, perfume := range perfumes {
+		if id != "" && perfume.Id != id {
+			continue
+		}
+		if perfume.IsActive && (perfume.Stock == nil || *perfume.Stock >= quantity) {
+			return &perfume, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// runSimulationStep serves req with handler and fails unless it reports success
+func runSimulationStep(name string, handler http.HandlerFunc, req *http.Request) (SimulationStep, error) {
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+
+	step := SimulationStep{Name: name, Status: recorder.Code}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &step.Response); err != nil {
+		return step, fmt.Errorf("%s: invalid response: %w", name, err)
+	}
+
+	if success, _ := step.Response["success"].(bool); recorder.Code != http.StatusOK || !success {
+		return step, fmt.Errorf("%s: returned status %d", name, recorder.Code)
+	}
+
+	return step, nil
+}
+
+func simulationJSONRequest(path string, body interface{}) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req := httptest.NewRequest("POST", path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func simulationFormRequest(path string, fields map[string]string) (*http.Request, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req, nil
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+)
+
+func simulateOrder(h *Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleSimulateOrder(rec, httptest.NewRequest("POST", "/api/test/simulate-order", strings.NewReader(body)))
+	return rec
+}
+
+func TestSimulateOrderRunsWholeFlow(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SimulationEnabled = true
+	h, db := newTestHandlerWithConfig(t, cfg)
+	ctx := context.Background()
+
+	perfume := &repository.Product{NameParfume: "Baccarat Rouge", Sex: "Unisex", Description: "Amber", Price: 2499}
+	if err := h.parfumeRepo.Create(ctx, perfume); err != nil {
+		t.Fatalf("create perfume: %v", err)
+	}
+
+	rec := simulateOrder(h, `{"telegram_id": -7001, "quantity": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Success    bool               `json:"success"`
+		TelegramID int64              `json:"telegram_id"`
+		Steps      []SimulationStep   `json:"steps"`
+		Order      domain.Order       `json:"order"`
+		Items      []domain.OrderItem `json:"items"`
+	}
+	decodeJSON(t, rec, &resp)
+	if !resp.Success || resp.TelegramID != -7001 {
+		t.Errorf("response = %+v, want a success for -7001", resp)
+	}
+
+	wantSteps := []string{"create_order", "select_perfumes", "complete_address", "spin", "complete_prize"}
+	if len(resp.Steps) != len(wantSteps) {
+		t.Fatalf("steps = %+v, want %v", resp.Steps, wantSteps)
+	}
+	for i, name := range wantSteps {
+		if resp.Steps[i].Name != name {
+			t.Errorf("step %d = %q, want %q", i, resp.Steps[i].Name, name)
+		}
+	}
+
+	// What the response reports is what the repositories hold
+	order, err := repository.NewOrderRepository(db).GetByID(ctx, resp.Order.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if order.IDUser != -7001 || order.Quantity == nil || *order.Quantity != 2 {
+		t.Errorf("order = %+v, want two kits for -7001", order)
+	}
+	if !strings.Contains(order.Parfumes, perfume.NameParfume) || order.Address != "Simulation street 1" || order.FIO != "Simulation User" {
+		t.Errorf("order = %+v, want the perfume and the address filled in", order)
+	}
+	if !isValidPrize(order.Gift) || order.PrizeClaimedAt == nil {
+		t.Errorf("order prize = %q claimed %v, want a claimed prize", order.Gift, order.PrizeClaimedAt)
+	}
+	if resp.Order.Gift != order.Gift {
+		t.Errorf("reported prize %q, stored %q", resp.Order.Gift, order.Gift)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].ParfumeID != perfume.Id || resp.Items[0].Quantity != 2 {
+		t.Errorf("items = %+v, want two of the perfume", resp.Items)
+	}
+
+	spins, err := repository.NewOrderRepository(db).GetSpins(ctx, domain.SpinFilter{})
+	if err != nil || len(spins) != 1 || spins[0].OrderID != order.ID {
+		t.Errorf("spins = %+v, %v; want the simulated spin", spins, err)
+	}
+}
+
+func TestSimulateOrderDisabled(t *testing.T) {
+	h, db := newTestHandler(t)
+
+	rec := simulateOrder(h, `{}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	var orders int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&orders); err != nil || orders != 0 {
+		t.Errorf("%d orders, %v; want none", orders, err)
+	}
+}
+
+func TestSimulateOrderWithoutPerfume(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SimulationEnabled = true
+	h, _ := newTestHandlerWithConfig(t, cfg)
+
+	rec := simulateOrder(h, "")
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "no_perfume_available") {
+		t.Errorf("status = %d, body %s; want 409 no_perfume_available", rec.Code, rec.Body.String())
+	}
+}
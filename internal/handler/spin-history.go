@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"parfum/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// Result limits of /api/admin/spins
+const (
+	defaultSpinHistoryLimit = 100
+	maxSpinHistoryLimit     = 1000
+)
+
+// recordSpin adds a spin that awarded a prize to the spins table; source says whether the
+// wheel or an admin awarded it. The prize is already on the order, so a spin that can't
+// be recorded is only logged.
+func (h *Handler) recordSpin(r *http.Request, order *domain.Order, orderSequence int, rulesVersion int64, drawnPrize, prize, source string) {
+	spin := &domain.Spin{
+		OrderID:       order.ID,
+		TelegramID:    order.IDUser,
+		OrderSequence: orderSequence,
+		Prize:         prize,
+		DrawnPrize:    drawnPrize,
+		Sector:        prizeSector(prize),
+		RulesVersion:  rulesVersion,
+		Source:        source,
+		RemoteAddr:    r.RemoteAddr,
+		UserAgent:     r.UserAgent(),
+	}
+	if err := h.orderRepo.RecordSpin(r.Context(), spin); err != nil {
+		h.logger.Error("Failed to record spin",
+			zap.Error(err),
+			zap.Int64("order_id", order.ID),
+			zap.String("prize", prize))
+	}
+}
+
+// spunOrders returns the orders of a user the spins table has a spin for. They can't be
+// spun again, also when their gift was cleared since. A spins table that can't be read
+// leaves only the gift to go by.
+func (h *Handler) spunOrders(ctx context.Context, telegramID int64) []int64 {
+	orderIDs, err := h.orderRepo.GetSpunOrderIDs(ctx, telegramID)
+	if err != nil {
+		h.logger.Warn("Failed to get spun orders", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		return []int64{}
+	}
+	return orderIDs
+}
+
+// List the prize wheel spins, to look into a dispute over a prize
+// GET /api/admin/spins?prize=diamond_ring&from=YYYY-MM-DD&to=YYYY-MM-DD&limit=100
+// totals counts the spins of every prize in the date range, whatever the prize filter and limit
+func (h *Handler) handleGetSpins(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	filter := domain.SpinFilter{
+		Prize: r.URL.Query().Get("prize"),
+		Limit: defaultSpinHistoryLimit,
+	}
+	if filter.Prize != "" && !slices.Contains(prizeWheelSectors, filter.Prize) {
+		writeJSONError(w, http.StatusBadRequest, "invalid_prize", "prize must be one of the wheel prizes", map[string]interface{}{
+			"prizes": prizeWheelSectors,
+		})
+		return
+	}
+
+	var err error
+	filter.From, filter.To, err = parseDateRange(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid_date_range", err.Error(), nil)
+		return
+	}
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > maxSpinHistoryLimit {
+			writeJSONError(w, http.StatusBadRequest, "invalid_limit", fmt.Sprintf("limit must be a number between 1 and %d", maxSpinHistoryLimit), nil)
+			return
+		}
+		filter.Limit = value
+	}
+
+	spins, err := h.orderRepo.GetSpins(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("Error getting spins", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	totalsFilter := domain.SpinFilter{From: filter.From, To: filter.To}
+	counts, err := h.orderRepo.CountSpinsByPrize(r.Context(), totalsFilter)
+	if err != nil {
+		h.logger.Error("Error counting spins", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	// Every wheel prize is in the totals, also when no spin awarded it in the period
+	totals := make(map[string]int, len(prizeWheelSectors))
+	total := 0
+	for _, prize := range prizeWheelSectors {
+		totals[prize] = 0
+	}
+	for prize, count := range counts {
+		totals[prize] = count
+		total += count
+	}
+
+	items := make([]map[string]interface{}, 0, len(spins))
+	for _, spin := range spins {
+		items = append(items, map[string]interface{}{
+			"id":             spin.ID,
+			"order_id":       spin.OrderID,
+			"telegram_id":    spin.TelegramID,
+			"order_sequence": spin.OrderSequence,
+			"prize":          spin.Prize,
+			"prize_name":     PrizeDisplayName(spin.Prize),
+			"drawn_prize":    spin.DrawnPrize,
+			"out_of_stock":   spin.DrawnPrize != spin.Prize,
+			"sector_index":   spin.Sector,
+			"rules_version":  spin.RulesVersion,
+			"source":         spin.Source,
+			"remote_addr":    spin.RemoteAddr,
+			"user_agent":     spin.UserAgent,
+			"created_at":     spin.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"from":    filter.From,
+		"to":      filter.To,
+		"prize":   filter.Prize,
+		"total":   total,
+		"totals":  totals,
+		"spins":   items,
+	})
+}
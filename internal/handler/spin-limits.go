@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// spinDay is the day a spin counts towards the daily cap
+func spinDay(now time.Time) string {
+	return now.Format("2006-01-02")
+}
+
+// nextSpinDay is when the daily cap starts over
+func nextSpinDay(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+}
+
+// spinAllowance returns how many of a user's eligible spins may still be spun today, and
+// when the next one may be spun if it can't be right now (nil if it can). A limit that
+// can't be read isn't applied, the same as SpinWheel does.
+func (h *Handler) spinAllowance(ctx context.Context, telegramID int64, eligible int) (int, *time.Time) {
+	now := time.Now()
+	spinsLeft := eligible
+	var nextSpinAt *time.Time
+
+	if h.cfg.DailySpinCap > 0 {
+		spins, err := h.redisRepo.GetDailySpins(ctx, telegramID, spinDay(now))
+		if err != nil {
+			h.logger.Warn("Failed to get daily spins", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		} else {
+			spinsLeft = min(spinsLeft, max(h.cfg.DailySpinCap-spins, 0))
+			if spinsLeft == 0 && eligible > 0 {
+				next := nextSpinDay(now)
+				return 0, &next
+			}
+		}
+	}
+
+	if spinsLeft > 0 && h.cfg.SpinCooldownSeconds > 0 {
+		left, err := h.redisRepo.GetSpinCooldown(ctx, telegramID)
+		if err != nil {
+			h.logger.Warn("Failed to get spin cooldown", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		} else if left > 0 {
+			next := now.Add(left)
+			nextSpinAt = &next
+		}
+	}
+
+	return spinsLeft, nextSpinAt
+}
+
+// writeSpinLimited answers a spin refused by the cooldown or the daily cap
+func writeSpinLimited(w http.ResponseWriter, code, message string, spinsLeft int, nextSpinAt time.Time) {
+	retryAfter := int(time.Until(nextSpinAt).Round(time.Second) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	writeJSONError(w, http.StatusTooManyRequests, code, message, map[string]interface{}{
+		"spins_left":   spinsLeft,
+		"next_spin_at": nextSpinAt,
+	})
+}
@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"parfum/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// While Redis is down it is pinged this often to switch back to it
+	stateStoreCheckInterval = 5 * time.Second
+	stateStorePingTimeout   = 2 * time.Second
+)
+
+// fallbackStateStore keeps bot state in Redis and in process memory while Redis is down.
+// A Redis error is only treated as an outage if a ping fails too. A value written to
+// memory during an outage is newer than anything Redis has, so it is read first until
+// the next successful write to Redis replaces it.
+type fallbackStateStore struct {
+	ctx     context.Context
+	primary StateStore
+	memory  StateStore
+	ping    func(ctx context.Context) error
+	logger  *zap.Logger
+	down    atomic.Bool
+}
+
+// newFallbackStateStore checks primary with ping and watches it until ctx is done
+func newFallbackStateStore(ctx context.Context, primary, memory StateStore, ping func(ctx context.Context) error, logger *zap.Logger) *fallbackStateStore {
+	s := &fallbackStateStore{
+		ctx:     ctx,
+		primary: primary,
+		memory:  memory,
+		ping:    ping,
+		logger:  logger,
+	}
+	if err := s.checkPrimary(); err != nil {
+		s.markDown(err)
+	}
+	go s.watch()
+	return s
+}
+
+func (s *fallbackStateStore) watch() {
+	ticker := time.NewTicker(stateStoreCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.down.Load() && s.checkPrimary() == nil {
+				s.down.Store(false)
+				s.logger.Info("Redis is available again, keeping bot state in Redis")
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *fallbackStateStore) checkPrimary() error {
+	ctx, cancel := context.WithTimeout(s.ctx, stateStorePingTimeout)
+	defer cancel()
+	return s.ping(ctx)
+}
+
+func (s *fallbackStateStore) markDown(err error) {
+	if s.down.CompareAndSwap(false, true) {
+		s.logger.Warn("Redis is unavailable, keeping bot state in memory", zap.Error(err))
+	}
+}
+
+// primaryDown is called after a Redis call failed: it reports whether Redis is down,
+// switching to memory if so, or the error was about that one call
+func (s *fallbackStateStore) primaryDown() bool {
+	if pingErr := s.checkPrimary(); pingErr != nil {
+		s.markDown(pingErr)
+		return true
+	}
+	return false
+}
+
+func (s *fallbackStateStore) GetUserState(ctx context.Context, userID int64) (*domain.UserState, error) {
+	if state, err := s.memory.GetUserState(ctx, userID); err != nil || state != nil {
+		return state, err
+	}
+	if s.down.Load() {
+		return nil, nil
+	}
+
+	state, err := s.primary.GetUserState(ctx, userID)
+	if err != nil && s.primaryDown() {
+		return nil, nil
+	}
+	return state, err
+}
+
+func (s *fallbackStateStore) SaveUserState(ctx context.Context, userID int64, state *domain.UserState) error {
+	if !s.down.Load() {
+		err := s.primary.SaveUserState(ctx, userID, state)
+		if err == nil {
+			return s.memory.DeleteUserState(ctx, userID)
+		}
+		if !s.primaryDown() {
+			return err
+		}
+	}
+	return s.memory.SaveUserState(ctx, userID, state)
+}
+
+func (s *fallbackStateStore) DeleteUserState(ctx context.Context, userID int64) error {
+	s.memory.DeleteUserState(ctx, userID)
+	if s.down.Load() {
+		return nil
+	}
+	if err := s.primary.DeleteUserState(ctx, userID); err != nil && !s.primaryDown() {
+		return err
+	}
+	return nil
+}
+
+func (s *fallbackStateStore) GetCart(ctx context.Context, userID int64) ([]domain.CartItem, error) {
+	if items, err := s.memory.GetCart(ctx, userID); err != nil || items != nil {
+		return items, err
+	}
+	if s.down.Load() {
+		return nil, nil
+	}
+
+	items, err := s.primary.GetCart(ctx, userID)
+	if err != nil && s.primaryDown() {
+		return nil, nil
+	}
+	return items, err
+}
+
+func (s *fallbackStateStore) SaveCart(ctx context.Context, userID int64, items []domain.CartItem, ttl time.Duration) error {
+	if !s.down.Load() {
+		err := s.primary.SaveCart(ctx, userID, items, ttl)
+		if err == nil {
+			return s.memory.ClearCart(ctx, userID)
+		}
+		if !s.primaryDown() {
+			return err
+		}
+	}
+	return s.memory.SaveCart(ctx, userID, items, ttl)
+}
+
+func (s *fallbackStateStore) ClearCart(ctx context.Context, userID int64) error {
+	s.memory.ClearCart(ctx, userID)
+	if s.down.Load() {
+		return nil
+	}
+	if err := s.primary.ClearCart(ctx, userID); err != nil && !s.primaryDown() {
+		return err
+	}
+	return nil
+}
+
+func (s *fallbackStateStore) GetPendingReceipt(ctx context.Context, userID int64) (*domain.PendingReceipt, error) {
+	if receipt, err := s.memory.GetPendingReceipt(ctx, userID); err != nil || receipt != nil {
+		return receipt, err
+	}
+	if s.down.Load() {
+		return nil, nil
+	}
+
+	receipt, err := s.primary.GetPendingReceipt(ctx, userID)
+	if err != nil && s.primaryDown() {
+		return nil, nil
+	}
+	return receipt, err
+}
+
+func (s *fallbackStateStore) SavePendingReceipt(ctx context.Context, userID int64, receipt *domain.PendingReceipt, ttl time.Duration) error {
+	if !s.down.Load() {
+		err := s.primary.SavePendingReceipt(ctx, userID, receipt, ttl)
+		if err == nil {
+			return s.memory.DeletePendingReceipt(ctx, userID)
+		}
+		if !s.primaryDown() {
+			return err
+		}
+	}
+	return s.memory.SavePendingReceipt(ctx, userID, receipt, ttl)
+}
+
+func (s *fallbackStateStore) DeletePendingReceipt(ctx context.Context, userID int64) error {
+	s.memory.DeletePendingReceipt(ctx, userID)
+	if s.down.Load() {
+		return nil
+	}
+	if err := s.primary.DeletePendingReceipt(ctx, userID); err != nil && !s.primaryDown() {
+		return err
+	}
+	return nil
+}
+
+func (s *fallbackStateStore) GetBroadcastState(ctx context.Context, adminID int64) (string, error) {
+	if broadcastType, err := s.memory.GetBroadcastState(ctx, adminID); err != nil || broadcastType != "" {
+		return broadcastType, err
+	}
+	if s.down.Load() {
+		return "", nil
+	}
+
+	broadcastType, err := s.primary.GetBroadcastState(ctx, adminID)
+	if err != nil && s.primaryDown() {
+		return "", nil
+	}
+	return broadcastType, err
+}
+
+func (s *fallbackStateStore) SaveBroadcastState(ctx context.Context, adminID int64, broadcastType string) error {
+	if !s.down.Load() {
+		err := s.primary.SaveBroadcastState(ctx, adminID, broadcastType)
+		if err == nil {
+			return s.memory.DeleteBroadcastState(ctx, adminID)
+		}
+		if !s.primaryDown() {
+			return err
+		}
+	}
+	return s.memory.SaveBroadcastState(ctx, adminID, broadcastType)
+}
+
+func (s *fallbackStateStore) DeleteBroadcastState(ctx context.Context, adminID int64) error {
+	s.memory.DeleteBroadcastState(ctx, adminID)
+	if s.down.Load() {
+		return nil
+	}
+	if err := s.primary.DeleteBroadcastState(ctx, adminID); err != nil && !s.primaryDown() {
+		return err
+	}
+	return nil
+}
+
+func (s *fallbackStateStore) MarkUpdateSeen(ctx context.Context, updateID int64) (bool, error) {
+	if !s.down.Load() {
+		first, err := s.primary.MarkUpdateSeen(ctx, updateID)
+		if err == nil || !s.primaryDown() {
+			return first, err
+		}
+	}
+	return s.memory.MarkUpdateSeen(ctx, updateID)
+}
+
+func (s *fallbackStateStore) StartSpinCooldown(ctx context.Context, userID int64, cooldown time.Duration) (time.Duration, error) {
+	if !s.down.Load() {
+		left, err := s.primary.StartSpinCooldown(ctx, userID, cooldown)
+		if err == nil || !s.primaryDown() {
+			return left, err
+		}
+	}
+	return s.memory.StartSpinCooldown(ctx, userID, cooldown)
+}
+
+func (s *fallbackStateStore) GetSpinCooldown(ctx context.Context, userID int64) (time.Duration, error) {
+	if !s.down.Load() {
+		left, err := s.primary.GetSpinCooldown(ctx, userID)
+		if err == nil || !s.primaryDown() {
+			return left, err
+		}
+	}
+	return s.memory.GetSpinCooldown(ctx, userID)
+}
+
+func (s *fallbackStateStore) GetDailySpins(ctx context.Context, userID int64, day string) (int, error) {
+	if !s.down.Load() {
+		spins, err := s.primary.GetDailySpins(ctx, userID, day)
+		if err == nil || !s.primaryDown() {
+			return spins, err
+		}
+	}
+	return s.memory.GetDailySpins(ctx, userID, day)
+}
+
+func (s *fallbackStateStore) IncrDailySpins(ctx context.Context, userID int64, day string) (int, error) {
+	if !s.down.Load() {
+		spins, err := s.primary.IncrDailySpins(ctx, userID, day)
+		if err == nil || !s.primaryDown() {
+			return spins, err
+		}
+	}
+	return s.memory.IncrDailySpins(ctx, userID, day)
+}
+
+func (s *fallbackStateStore) CountReceiptFailure(ctx context.Context, userID int64, hour, receiptPath string) (int, int, error) {
+	if !s.down.Load() {
+		user, global, err := s.primary.CountReceiptFailure(ctx, userID, hour, receiptPath)
+		if err == nil || !s.primaryDown() {
+			return user, global, err
+		}
+	}
+	return s.memory.CountReceiptFailure(ctx, userID, hour, receiptPath)
+}
+
+func (s *fallbackStateStore) GetRecentReceiptFailures(ctx context.Context) ([]string, error) {
+	if !s.down.Load() {
+		paths, err := s.primary.GetRecentReceiptFailures(ctx)
+		if err == nil || !s.primaryDown() {
+			return paths, err
+		}
+	}
+	return s.memory.GetRecentReceiptFailures(ctx)
+}
+
+func (s *fallbackStateStore) StartAlertCooldown(ctx context.Context, alert string, cooldown time.Duration) (bool, error) {
+	if !s.down.Load() {
+		started, err := s.primary.StartAlertCooldown(ctx, alert, cooldown)
+		if err == nil || !s.primaryDown() {
+			return started, err
+		}
+	}
+	return s.memory.StartAlertCooldown(ctx, alert, cooldown)
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+)
+
+// newTestStateStore puts a fallback store in front of an in-process Redis, which the
+// test stops and restarts to simulate an outage
+func newTestStateStore(t *testing.T) (*fallbackStateStore, *repository.RedisRepository, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	redisRepo := repository.NewRedisRepository(client)
+	store := newFallbackStateStore(ctx, redisRepo, repository.NewMemoryStateRepository(ctx), redisRepo.Ping, zap.NewNop())
+	return store, redisRepo, server
+}
+
+// userState reads a user's state from store, failing the test on an error
+func userState(t *testing.T, store StateStore, userID int64) *domain.UserState {
+	t.Helper()
+
+	state, err := store.GetUserState(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserState: %v", err)
+	}
+	return state
+}
+
+func TestStateSurvivesRedisOutage(t *testing.T) {
+	store, _, server := newTestStateStore(t)
+	ctx := context.Background()
+
+	if err := store.SaveUserState(ctx, 9001, &domain.UserState{State: "count", Count: 1}); err != nil {
+		t.Fatalf("SaveUserState: %v", err)
+	}
+	if store.down.Load() {
+		t.Fatal("store is down while Redis is up")
+	}
+
+	server.Close()
+
+	// The first failed write finds Redis down and keeps the state in memory
+	if err := store.SaveUserState(ctx, 9001, &domain.UserState{State: "paid", Count: 2, IsPaid: true}); err != nil {
+		t.Fatalf("SaveUserState during the outage: %v", err)
+	}
+	if !store.down.Load() {
+		t.Fatal("store didn't switch to memory")
+	}
+	if state := userState(t, store, 9001); state == nil || state.State != "paid" || state.Count != 2 {
+		t.Errorf("state during the outage = %+v, want the one saved during it", state)
+	}
+
+	cart := []domain.CartItem{{ParfumeID: "p1", Name: "Baccarat Rouge", Quantity: 2, Price: 2499}}
+	if err := store.SaveCart(ctx, 9001, cart, time.Hour); err != nil {
+		t.Fatalf("SaveCart during the outage: %v", err)
+	}
+	if got, err := store.GetCart(ctx, 9001); err != nil || len(got) != 1 || got[0] != cart[0] {
+		t.Errorf("cart during the outage = %+v, %v; want %+v", got, err, cart)
+	}
+
+	// A user Redis never saw reads as having no state rather than failing
+	if state := userState(t, store, 9002); state != nil {
+		t.Errorf("unknown user's state = %+v, want none", state)
+	}
+	if err := store.DeleteUserState(ctx, 9002); err != nil {
+		t.Errorf("DeleteUserState during the outage: %v", err)
+	}
+}
+
+func TestStateSavedDuringOutageWinsAfterRecovery(t *testing.T) {
+	store, redisRepo, server := newTestStateStore(t)
+	ctx := context.Background()
+
+	if err := store.SaveUserState(ctx, 9011, &domain.UserState{State: "count"}); err != nil {
+		t.Fatalf("SaveUserState: %v", err)
+	}
+	server.Close()
+	if err := store.SaveUserState(ctx, 9011, &domain.UserState{State: "paid"}); err != nil {
+		t.Fatalf("SaveUserState during the outage: %v", err)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("restart Redis: %v", err)
+	}
+	// What the watcher does on its next tick
+	if err := store.checkPrimary(); err != nil {
+		t.Fatalf("Redis still down after the restart: %v", err)
+	}
+	store.down.Store(false)
+
+	// Redis still has the stale state; the one from the outage is read first
+	if stale, err := redisRepo.GetUserState(ctx, 9011); err != nil || stale == nil || stale.State != "count" {
+		t.Fatalf("Redis state = %+v, %v; want the one from before the outage", stale, err)
+	}
+	if state := userState(t, store, 9011); state == nil || state.State != "paid" {
+		t.Errorf("state after recovery = %+v, want the one saved during the outage", state)
+	}
+
+	// The next write goes to Redis again and replaces the memory copy
+	if err := store.SaveUserState(ctx, 9011, &domain.UserState{State: "contact"}); err != nil {
+		t.Fatalf("SaveUserState after recovery: %v", err)
+	}
+	if saved, err := redisRepo.GetUserState(ctx, 9011); err != nil || saved == nil || saved.State != "contact" {
+		t.Errorf("Redis state = %+v, %v; want the write after recovery", saved, err)
+	}
+	if state := userState(t, store, 9011); state == nil || state.State != "contact" {
+		t.Errorf("state = %+v, want the write after recovery", state)
+	}
+}
+
+func TestStateStoreStartsInMemoryWhenRedisIsDown(t *testing.T) {
+	store, _, server := newTestStateStore(t)
+	server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	store = newFallbackStateStore(ctx, store.primary, repository.NewMemoryStateRepository(ctx), store.ping, zap.NewNop())
+	if !store.down.Load() {
+		t.Fatal("store started on Redis while it was down")
+	}
+
+	if err := store.SaveUserState(ctx, 9021, &domain.UserState{State: "count", Count: 3}); err != nil {
+		t.Fatalf("SaveUserState: %v", err)
+	}
+	if state := userState(t, store, 9021); state == nil || state.Count != 3 {
+		t.Errorf("state = %+v, want the saved one", state)
+	}
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/service"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// StatsCommand is the admin command that shows the headline business numbers
+const StatsCommand = "stats"
+
+// StatsCallbackPrefix starts the callback data of the range buttons under /stats, e.g. "stats_7d"
+const StatsCallbackPrefix = "stats_"
+
+// statsRange is a period the /stats message can show
+type statsRange struct {
+	key   string
+	label string
+	since func(now time.Time) time.Time // zero for all time
+}
+
+// statsRanges are the /stats buttons, in order; the first one is shown by the command
+var statsRanges = []statsRange{
+	{key: "all", label: "Барлық уақыт", since: func(time.Time) time.Time { return time.Time{} }},
+	{key: "today", label: "Бүгін", since: func(now time.Time) time.Time {
+		year, month, day := now.UTC().Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}},
+	{key: "7d", label: "7 күн", since: func(now time.Time) time.Time { return now.AddDate(0, 0, -7) }},
+	{key: "30d", label: "30 күн", since: func(now time.Time) time.Time { return now.AddDate(0, 0, -30) }},
+}
+
+// StatsHandler handles /stats and its range buttons from an admin. The command sends the
+// all-time numbers; a button redraws the same message for its range.
+func (h *Handler) StatsHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message != nil && update.Message.From != nil {
+		adminId := update.Message.From.ID
+		if !slices.Contains(h.receiptAdmins(), adminId) {
+			return
+		}
+
+		text, keyboard, err := h.statsMessage(ctx, statsRanges[0])
+		if err != nil {
+			h.logger.Error("Failed to get bot stats", zap.Error(err))
+			h.sendAdminText(ctx, b, adminId, "❌ Статистиканы алу мүмкін болмады, қайталап көріңіз.")
+			return
+		}
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      update.Message.Chat.ID,
+			Text:        text,
+			ReplyMarkup: keyboard,
+		})
+		if err != nil {
+			h.logger.Warn("Failed to send stats", zap.Error(err))
+		}
+		return
+	}
+
+	query := update.CallbackQuery
+	if query == nil || !strings.HasPrefix(query.Data, StatsCallbackPrefix) {
+		return
+	}
+	answer := func(text string) {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID, Text: text})
+	}
+	if !slices.Contains(h.receiptAdmins(), query.From.ID) {
+		answer("⛔️")
+		return
+	}
+
+	key := strings.TrimPrefix(query.Data, StatsCallbackPrefix)
+	i := slices.IndexFunc(statsRanges, func(r statsRange) bool { return r.key == key })
+	if i < 0 || query.Message.Message == nil {
+		answer("")
+		return
+	}
+
+	text, keyboard, err := h.statsMessage(ctx, statsRanges[i])
+	if err != nil {
+		h.logger.Error("Failed to get bot stats", zap.Error(err))
+		answer("Қате, қайталап көріңіз")
+		return
+	}
+	answer("")
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      query.Message.Message.Chat.ID,
+		MessageID:   query.Message.Message.ID,
+		Text:        text,
+		ReplyMarkup: keyboard,
+	})
+	// Pressing the range already shown changes nothing, which Telegram reports as an error
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		h.logger.Warn("Failed to edit stats message", zap.Error(err))
+	}
+}
+
+// statsMessage renders the /stats message for period, with the range buttons
+func (h *Handler) statsMessage(ctx context.Context, period statsRange) (string, *models.InlineKeyboardMarkup, error) {
+	now := time.Now()
+	stats, err := h.orderRepo.GetBotStats(ctx, period.since(now))
+	if err != nil {
+		return "", nil, err
+	}
+
+	usersLabel := "👥 Жаңа қолданушылар"
+	if stats.Since.IsZero() {
+		usersLabel = "👥 Қолданушылар"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📊 Статистика: %s\n\n", period.label)
+	fmt.Fprintf(&sb, "🧾 Тапсырыстар: %d\n", stats.Orders)
+	fmt.Fprintf(&sb, "⏳ Күтуде: %d · ✅ Расталған: %d\n", stats.PendingOrders, stats.CompletedOrders)
+	fmt.Fprintf(&sb, "🧴 Сатылған саны: %d\n", stats.Quantity)
+	fmt.Fprintf(&sb, "💰 Түсім: %s ₸\n", service.FormatPrice(int(stats.Revenue)))
+	fmt.Fprintf(&sb, "%s: %d\n", usersLabel, stats.NewUsers)
+	fmt.Fprintf(&sb, "📅 Бүгінгі тапсырыстар: %d\n\n", stats.TodayOrders)
+	sb.WriteString(statsPrizeLines(stats))
+	fmt.Fprintf(&sb, "\n\n🕐 %s", now.Format("2006-01-02 15:04:05"))
+
+	buttons := make([]models.InlineKeyboardButton, 0, len(statsRanges))
+	for _, r := range statsRanges {
+		label := r.label
+		if r.key == period.key {
+			label = "• " + label
+		}
+		buttons = append(buttons, models.InlineKeyboardButton{Text: label, CallbackData: StatsCallbackPrefix + r.key})
+	}
+
+	return sb.String(), &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{buttons}}, nil
+}
+
+// statsPrizeLines lists the prizes awarded, most awarded first
+func statsPrizeLines(stats *domain.BotStats) string {
+	if len(stats.PrizeCounts) == 0 {
+		return "🎁 Сыйлықтар: жоқ"
+	}
+
+	prizes := make([]string, 0, len(stats.PrizeCounts))
+	for prize := range stats.PrizeCounts {
+		prizes = append(prizes, prize)
+	}
+	sort.Slice(prizes, func(i, j int) bool {
+		if stats.PrizeCounts[prizes[i]] != stats.PrizeCounts[prizes[j]] {
+			return stats.PrizeCounts[prizes[i]] > stats.PrizeCounts[prizes[j]]
+		}
+		return prizes[i] < prizes[j]
+	})
+
+	lines := []string{"🎁 Сыйлықтар:"}
+	for _, prize := range prizes {
+		lines = append(lines, fmt.Sprintf("  %s — %d", PrizeDisplayName(prize), stats.PrizeCounts[prize]))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"go.uber.org/zap"
+)
+
+// Catalog keys of the messages sent to the buyer when an admin moves their order on.
+// The statuses the buyer reaches themselves (paid, perfume_selected, address_provided)
+// are already answered by the bot and the mini app, so they have no template.
+var orderStatusMessages = map[domain.OrderStatus]string{
+	domain.OrderStatusPacked:    i18n.OrderPackedNotice,
+	domain.OrderStatusShipped:   i18n.OrderShippedNotice,
+	domain.OrderStatusDelivered: i18n.OrderDeliveredNotice,
+	domain.OrderStatusCancelled: i18n.OrderCancelledNotice,
+}
+
+// orderStatusMessage builds the buyer's notification for the order's current status in
+// lang. It returns false if the status has no template.
+func orderStatusMessage(lang string, order *domain.Order) (string, bool) {
+	key, ok := orderStatusMessages[order.Status]
+	if !ok {
+		return "", false
+	}
+
+	lines := []string{i18n.T(lang, key), "", i18n.T(lang, i18n.OrderNumberLine, order.ID)}
+	if order.Parfumes != "" {
+		lines = append(lines, i18n.T(lang, i18n.OrderPerfumesLine, order.Parfumes))
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// notifyOrderStatus tells the buyer about a status change. Events that don't change
+// the status are skipped, so setting the same status twice sends nothing.
+func (h *Handler) notifyOrderStatus(order *domain.Order, event *domain.OrderEvent) {
+	if h.silent || event.FromStatus == event.ToStatus {
+		return
+	}
+
+	text, ok := orderStatusMessage(h.userLang(h.ctx, order.IDUser), order)
+	if !ok {
+		return
+	}
+
+	err := <-h.enqueueMessage(order.IDUser, order.ID, &bot.SendMessageParams{
+		ChatID: order.IDUser,
+		Text:   text,
+	})
+	if err != nil {
+		return
+	}
+
+	h.logger.Info("Order status notification sent",
+		zap.Int64("telegram_id", order.IDUser),
+		zap.Int64("order_id", order.ID),
+		zap.String("status", string(order.Status)))
+}
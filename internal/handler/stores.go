@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+)
+
+// The interfaces below list only the repository methods the handler calls,
+// so a Handler can run against in-memory stores instead of SQLite and Redis.
+
+// OrderStore is the order storage the handler uses, see repository.OrderRepository
+type OrderStore interface {
+	Create(ctx context.Context, order *domain.Order) error
+	Delete(ctx context.Context, id int64) error
+	GetByID(ctx context.Context, id int64) (*domain.Order, error)
+	GetByUserID(ctx context.Context, userID int64) ([]domain.Order, error)
+	SearchOrders(ctx context.Context, query, phone string, limit int) ([]domain.Order, error)
+	List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error)
+	QueryOrders(ctx context.Context, filter domain.OrderFilter) (*sql.Rows, error)
+	GetPendingPayment(ctx context.Context, telegramID int64) (*domain.Order, error)
+	GetUnpaidOrdersByUser(ctx context.Context, telegramID int64) ([]domain.Order, error)
+	GetAvailableQuantityForUser(ctx context.Context, telegramID int64) (int, error)
+	UpdateClientInfoWithCoordinates(ctx context.Context, orderID int64, fio, contact, address string, latitude, longitude *float64) error
+	UpdatePerfumeSelection(ctx context.Context, orderID int64, parfumes string) error
+	Checkout(ctx context.Context, telegramID int64, cart []domain.CartItem, info domain.CheckoutInfo) ([]domain.CheckoutAllocation, error)
+	UpdateStatus(ctx context.Context, orderID int64, status domain.OrderStatus, actor, note string) (*domain.OrderEvent, error)
+	MarkOrderAsCompleted(ctx context.Context, orderID int64) error
+	RecordEvent(ctx context.Context, orderID int64, eventType domain.OrderEventType, actor string, payload interface{}) error
+	GetEvents(ctx context.Context, orderID int64) ([]domain.OrderEvent, error)
+
+	// Prize wheel
+	GetOrderSequenceNumber(ctx context.Context, orderID int64) (int, error)
+	GetOrdersEligibleForPrize(ctx context.Context, telegramID int64) ([]domain.Order, error)
+	GetPrizeOrdersByUser(ctx context.Context, telegramID int64) ([]domain.Order, error)
+	GetCompletedPrizeOrders(ctx context.Context, from, to string) ([]domain.Order, error)
+	GetPrizeStatistics(ctx context.Context) (map[string]int, error)
+	AssignPrizes(ctx context.Context, assignments []domain.PrizeAssignment) ([]domain.AssignedPrize, error)
+	AwardPrize(ctx context.Context, orderID int64, prize, fallback string) (string, bool, error)
+	GetPrizeInventory(ctx context.Context) ([]domain.PrizeInventory, error)
+	TopUpPrizeInventory(ctx context.Context, prize string, amount int) (*domain.PrizeInventory, error)
+	RecordSpin(ctx context.Context, spin *domain.Spin) error
+	GetSpins(ctx context.Context, filter domain.SpinFilter) ([]domain.Spin, error)
+	CountSpinsByPrize(ctx context.Context, filter domain.SpinFilter) (map[string]int, error)
+	GetSpunOrderIDs(ctx context.Context, telegramID int64) ([]int64, error)
+	GetActivePrizeRules(ctx context.Context) (*domain.PrizeRules, error)
+	GetPrizeRules(ctx context.Context, version int64) (*domain.PrizeRules, error)
+	SavePrizeRules(ctx context.Context, rules *domain.PrizeRules) (*domain.PrizeRules, error)
+	GetPrizesToRemind(ctx context.Context, awardedBefore time.Time) ([]domain.Order, error)
+	GetPrizesToExpire(ctx context.Context, awardedBefore, remindedBefore time.Time) ([]domain.Order, error)
+	MarkPrizeReminded(ctx context.Context, orderID int64) error
+	ExpirePrize(ctx context.Context, orderID int64) (bool, error)
+	ClaimPrizeWithAddress(ctx context.Context, orderID int64, info domain.CheckoutInfo) (bool, error)
+	GetPrizeClaimStatistics(ctx context.Context) (map[string]domain.PrizeClaimCounts, error)
+
+	// Dashboard
+	GetOrderStats(ctx context.Context) (*domain.OrderStatsResponse, error)
+	GetPeriodStats(ctx context.Context, days int, granularity string) ([]domain.OrderPeriodStats, error)
+	GetConversionTimings(ctx context.Context) (*domain.ConversionTimings, error)
+	GetBotStats(ctx context.Context, since time.Time) (*domain.BotStats, error)
+}
+
+// OrderItemStore is the per-order perfume storage, see repository.OrderItemRepository
+type OrderItemStore interface {
+	AddItems(ctx context.Context, orderID int64, items []domain.OrderItem) error
+	GetByOrder(ctx context.Context, orderID int64) ([]domain.OrderItem, error)
+	SumQuantityByOrder(ctx context.Context, orderID int64) (int, error)
+	GetTopPerfumes(ctx context.Context, limit int) ([]domain.PerfumeSales, error)
+}
+
+// ClientStore is the client, receipt and notification storage, see repository.ClientRepository
+type ClientStore interface {
+	InsertJust(ctx context.Context, e domain.JustEntry) error
+	ExistsJust(ctx context.Context, userId int64) (bool, error)
+	GetUserName(ctx context.Context, userID int64) (string, error)
+	GetPreferredLanguage(ctx context.Context, telegramID int64) (string, error)
+	SetLanguage(ctx context.Context, userID int64, lang string) error
+	CountUsers(ctx context.Context) (int, error)
+
+	InsertClient(ctx context.Context, e domain.ClientEntry) error
+	IsClientUnique(ctx context.Context, userID int64) (bool, error)
+	GetByTelegramID(ctx context.Context, telegramID int64) (*domain.Client, error)
+	SaveOrUpdate(ctx context.Context, client *domain.Client) error
+	CountClients(ctx context.Context) (int, error)
+	SearchClients(ctx context.Context, filter domain.ClientFilter) ([]domain.ClientListItem, error)
+	CountSearchClients(ctx context.Context, filter domain.ClientFilter) (int, error)
+
+	InsertOrder(ctx context.Context, order domain.OrderEntry) (int64, error)
+	RecordPayment(ctx context.Context, p domain.PaymentEntry) (orderID int64, claimed bool, err error)
+	SetPaymentContact(ctx context.Context, userID, orderID int64, contact string) error
+	SavePendingPayment(ctx context.Context, p domain.PendingPayment) error
+	GetPendingPayment(ctx context.Context, userID int64) (*domain.PendingPayment, error)
+	DeletePendingPayment(ctx context.Context, userID int64) error
+	GetTotalSum(ctx context.Context) (int64, error)
+	GetLotoTickets(ctx context.Context, userID int64) ([]int, error)
+	GetLotoByUser(ctx context.Context, userID int64) ([]domain.LotoEntry, error)
+	GetAllLoto(ctx context.Context) ([]domain.LotoEntry, error)
+	DrawRandomLotoWinner(ctx context.Context) (domain.LotoEntry, error)
+	CountUndrawnLoto(ctx context.Context) (int, error)
+	CountLotoByUserAndReceipt(ctx context.Context, userID int64, qr string) (int, error)
+	GetLotoReceipt(ctx context.Context, userID int64, datePay string) (string, error)
+	TopUpLoto(ctx context.Context, userID int64, qr, datePay string, expected int, draw func(n int) []int) ([]int, error)
+
+	GetBroadcastAudience(ctx context.Context, audience string) ([]int64, error)
+	MarkBroadcastBlocked(ctx context.Context, userID int64, reason string) error
+
+	CreateManualPayment(ctx context.Context, p domain.ManualPayment) (int64, error)
+	GetManualPayment(ctx context.Context, id int64) (*domain.ManualPayment, error)
+	DecideManualPayment(ctx context.Context, id int64, status string, adminID int64) (bool, error)
+	ReopenManualPayment(ctx context.Context, id int64) error
+
+	LogFailedNotification(ctx context.Context, n domain.FailedNotification) (int64, error)
+	GetFailedNotification(ctx context.Context, id int64) (*domain.FailedNotification, error)
+	GetFailedNotifications(ctx context.Context, filter domain.FailedNotificationFilter) ([]domain.FailedNotification, error)
+	CountFailedNotifications(ctx context.Context, filter domain.FailedNotificationFilter) (int, error)
+	RecordNotificationAttempt(ctx context.Context, id int64, reason string) error
+	GetUnsentNotifications(ctx context.Context, reasons []string, limit int) ([]domain.FailedNotification, error)
+}
+
+// ParfumeStore is the perfume catalog storage, see repository.ParfumeRepository
+type ParfumeStore interface {
+	GetAll(ctx context.Context, includeArchived bool) ([]repository.Product, error)
+	GetByID(ctx context.Context, id string) (*repository.Product, error)
+	GetByIDs(ctx context.Context, ids []string) (map[string]repository.Product, error)
+	GetByNames(ctx context.Context, names []string) (map[string]repository.Product, error)
+	AdvancedSearch(ctx context.Context, name, sex string, minPrice, maxPrice int, includeArchived bool, sort string) ([]repository.Product, error)
+	GetLowStock(ctx context.Context, threshold int) ([]repository.Product, error)
+	Create(ctx context.Context, product *repository.Product) error
+	Update(ctx context.Context, product *repository.Product) error
+	UpdatePhotoPath(ctx context.Context, id, photoPath string) error
+	Import(ctx context.Context, items []repository.ProductImport, atomic bool) ([]repository.Product, []repository.ProductImportError, error)
+	Archive(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) error
+	Purge(ctx context.Context, id string) error
+	DecrementStock(ctx context.Context, quantities map[string]int) error
+	RestoreStock(ctx context.Context, quantities map[string]int) error
+}
+
+// PhotoStore is the perfume gallery storage, see repository.ParfumePhotoRepository
+type PhotoStore interface {
+	Add(ctx context.Context, parfumeID string, filenames []string, makePrimary bool) ([]repository.ParfumePhoto, error)
+	GetByID(ctx context.Context, id int64) (*repository.ParfumePhoto, error)
+	GetByParfume(ctx context.Context, parfumeID string) ([]repository.ParfumePhoto, error)
+	GetPrimary(ctx context.Context, parfumeID string) (*repository.ParfumePhoto, error)
+	GetMissingVariants(ctx context.Context) ([]repository.ParfumePhoto, error)
+	SetVariants(ctx context.Context, id int64, thumbFilename, mediumFilename string) error
+	Delete(ctx context.Context, id int64) error
+	DeleteByParfume(ctx context.Context, parfumeID string) ([]string, error)
+}
+
+// StateStore keeps bot conversation state, carts, pending receipts, admin broadcast
+// drafts, the IDs of recently handled updates, prize wheel spin limits and receipt
+// failure counters, see
+// repository.RedisRepository
+type StateStore interface {
+	GetUserState(ctx context.Context, userID int64) (*domain.UserState, error)
+	SaveUserState(ctx context.Context, userID int64, state *domain.UserState) error
+	DeleteUserState(ctx context.Context, userID int64) error
+
+	GetCart(ctx context.Context, userID int64) ([]domain.CartItem, error)
+	SaveCart(ctx context.Context, userID int64, items []domain.CartItem, ttl time.Duration) error
+	ClearCart(ctx context.Context, userID int64) error
+
+	GetPendingReceipt(ctx context.Context, userID int64) (*domain.PendingReceipt, error)
+	SavePendingReceipt(ctx context.Context, userID int64, receipt *domain.PendingReceipt, ttl time.Duration) error
+	DeletePendingReceipt(ctx context.Context, userID int64) error
+
+	GetBroadcastState(ctx context.Context, adminID int64) (string, error)
+	SaveBroadcastState(ctx context.Context, adminID int64, broadcastType string) error
+	DeleteBroadcastState(ctx context.Context, adminID int64) error
+
+	MarkUpdateSeen(ctx context.Context, updateID int64) (bool, error)
+
+	StartSpinCooldown(ctx context.Context, userID int64, cooldown time.Duration) (time.Duration, error)
+	GetSpinCooldown(ctx context.Context, userID int64) (time.Duration, error)
+	GetDailySpins(ctx context.Context, userID int64, day string) (int, error)
+	IncrDailySpins(ctx context.Context, userID int64, day string) (int, error)
+
+	CountReceiptFailure(ctx context.Context, userID int64, hour, receiptPath string) (int, int, error)
+	GetRecentReceiptFailures(ctx context.Context) ([]string, error)
+	StartAlertCooldown(ctx context.Context, alert string, cooldown time.Duration) (bool, error)
+}
+
+// IdempotencyStore records Idempotency-Key responses, see repository.IdempotencyRepository
+type IdempotencyStore interface {
+	Claim(ctx context.Context, key, endpoint string, telegramID int64) (*domain.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, key, endpoint string, telegramID int64, statusCode int, response []byte, resourceID int64) error
+	Release(ctx context.Context, key, endpoint string, telegramID int64) error
+}
+
+// SettingsStore keeps the settings admins change at runtime, see repository.SettingsRepository
+type SettingsStore interface {
+	GetSetting(ctx context.Context, key string) (string, bool, error)
+	SetSetting(ctx context.Context, key, value string) error
+	DeleteSetting(ctx context.Context, key string) error
+}
+
+// Stores are the storage backends a Handler runs against
+type Stores struct {
+	Orders      OrderStore
+	OrderItems  OrderItemStore
+	Clients     ClientStore
+	Parfumes    ParfumeStore
+	Photos      PhotoStore
+	State       StateStore
+	Idempotency IdempotencyStore
+	Settings    SettingsStore
+}
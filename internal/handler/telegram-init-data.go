@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// initDataHeader carries Telegram.WebApp.initData from the mini app pages
+const initDataHeader = "X-Telegram-Init-Data"
+
+// initDataMaxAge is how long after Telegram opened the mini app its initData is accepted
+const initDataMaxAge = 24 * time.Hour
+
+var (
+	errInitDataInvalid = errors.New("telegram init data is invalid")
+	errInitDataExpired = errors.New("telegram init data has expired")
+)
+
+// verifyInitData checks the signature Telegram put on a mini app's initData with the bot
+// token and returns the ID of the user it was issued to. See
+// https://core.telegram.org/bots/webapps#validating-data-received-via-the-mini-app
+func verifyInitData(initData, botToken string, now time.Time) (int64, error) {
+	if initData == "" || botToken == "" {
+		return 0, errInitDataInvalid
+	}
+
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return 0, errInitDataInvalid
+	}
+	hash, err := hex.DecodeString(values.Get("hash"))
+	if err != nil || len(hash) == 0 {
+		return 0, errInitDataInvalid
+	}
+
+	// The signed text is every other field as key=value, sorted by key, one per line
+	pairs := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			pairs = append(pairs, key+"="+values.Get(key))
+		}
+	}
+	sort.Strings(pairs)
+
+	if !hmac.Equal(hash, initDataMAC(botToken, strings.Join(pairs, "\n"))) {
+		return 0, errInitDataInvalid
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return 0, errInitDataInvalid
+	}
+	if now.After(time.Unix(authDate, 0).Add(initDataMaxAge)) {
+		return 0, errInitDataExpired
+	}
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(values.Get("user")), &user); err != nil || user.ID == 0 {
+		return 0, errInitDataInvalid
+	}
+	return user.ID, nil
+}
+
+// initDataMAC signs dataCheck with the key Telegram derives from the bot token
+func initDataMAC(botToken, dataCheck string) []byte {
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(dataCheck))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+)
+
+// signInitData builds the initData Telegram would give userID's mini app at authDate
+func signInitData(botToken string, userID int64, authDate time.Time) string {
+	values := url.Values{
+		"auth_date": {strconv.FormatInt(authDate.Unix(), 10)},
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"user":      {fmt.Sprintf(`{"id":%d,"first_name":"Aigerim","language_code":"kk"}`, userID)},
+	}
+	dataCheck := fmt.Sprintf("auth_date=%s\nquery_id=%s\nuser=%s", values.Get("auth_date"), values.Get("query_id"), values.Get("user"))
+	values.Set("hash", hex.EncodeToString(initDataMAC(botToken, dataCheck)))
+	return values.Encode()
+}
+
+func TestVerifyInitData(t *testing.T) {
+	const botToken = "123456:test-bot-token"
+	now := time.Now()
+	valid := signInitData(botToken, 7001, now.Add(-time.Hour))
+
+	tampered, _ := url.ParseQuery(valid)
+	tampered.Set("user", `{"id":7002,"first_name":"Aigerim","language_code":"kk"}`)
+
+	tests := []struct {
+		name     string
+		initData string
+		botToken string
+		wantID   int64
+		wantErr  error
+	}{
+		{"valid", valid, botToken, 7001, nil},
+		{"another user put in", tampered.Encode(), botToken, 0, errInitDataInvalid},
+		{"signed for another bot", valid, "654321:other-bot-token", 0, errInitDataInvalid},
+		{"too old", signInitData(botToken, 7001, now.Add(-25*time.Hour)), botToken, 0, errInitDataExpired},
+		{"no hash", "auth_date=1&user=%7B%22id%22%3A7001%7D", botToken, 0, errInitDataInvalid},
+		{"empty", "", botToken, 0, errInitDataInvalid},
+		{"no bot token", valid, "", 0, errInitDataInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := verifyInitData(tt.initData, tt.botToken, now)
+			if id != tt.wantID || err != tt.wantErr {
+				t.Errorf("verifyInitData = %d, %v; want %d, %v", id, err, tt.wantID, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetPrizeTokenRequiresInitData(t *testing.T) {
+	h, db := newTestHandler(t)
+	order := seedOrder(t, db, domain.Order{IDUser: 7001, Parfumes: "Baccarat Rouge: 1"})
+	setOrderPrize(t, db, order.ID, Prize30ML)
+	target := fmt.Sprintf("/api/prize/token?telegram_id=7001&order_id=%d", order.ID)
+
+	tests := []struct {
+		name       string
+		initData   string
+		wantStatus int
+	}{
+		{"without init data", "", http.StatusUnauthorized},
+		{"forged init data", signInitData("654321:other-bot-token", 7001, time.Now()), http.StatusUnauthorized},
+		{"another user's init data", signInitData(h.cfg.Token, 7002, time.Now()), http.StatusForbidden},
+		{"owner's init data", signInitData(h.cfg.Token, 7001, time.Now()), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", target, nil)
+			if tt.initData != "" {
+				r.Header.Set(initDataHeader, tt.initData)
+			}
+			rec := httptest.NewRecorder()
+			h.GetPrizeToken(rec, r)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				PrizeWon   string `json:"prize_won"`
+				PrizeToken string `json:"prize_token"`
+			}
+			decodeJSON(t, rec, &resp)
+			if resp.PrizeWon != Prize30ML {
+				t.Errorf("prize = %q, want %q", resp.PrizeWon, Prize30ML)
+			}
+			if err := h.verifyPrizeToken(resp.PrizeToken, order.ID, Prize30ML, time.Now()); err != nil {
+				t.Errorf("issued token doesn't verify: %v", err)
+			}
+		})
+	}
+}
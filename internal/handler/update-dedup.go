@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// SkipDuplicateUpdates is a bot middleware that handles each update once. After a restart
+// Telegram sends again the updates it hasn't seen confirmed, and a receipt or payment
+// button must not be processed twice. If the seen IDs can't be checked the update is
+// handled anyway.
+func (h *Handler) SkipDuplicateUpdates(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		first, err := h.redisRepo.MarkUpdateSeen(ctx, update.ID)
+		if err != nil {
+			h.logger.Warn("Failed to check update for duplicates", zap.Error(err), zap.Int64("update_id", update.ID))
+		} else if !first {
+			h.logger.Debug("Skipping duplicate update", zap.Int64("update_id", update.ID))
+			return
+		}
+
+		next(ctx, b, update)
+	}
+}
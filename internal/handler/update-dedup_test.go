@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func TestSameUpdateIsHandledOnce(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tg := newFakeTelegram(t)
+	ctx := context.Background()
+	handle := h.SkipDuplicateUpdates(h.DefaultHandler)
+
+	update := func(id int64) *models.Update {
+		return &models.Update{ID: id, Message: &models.Message{
+			From: &models.User{ID: 7951},
+			Chat: models.Chat{ID: 7951},
+			Text: "salem",
+		}}
+	}
+
+	// Redelivered after a restart: only the first one asks for the language
+	handle(ctx, tg.bot, update(100))
+	handle(ctx, tg.bot, update(100))
+	if n := len(tg.messages(7951)); n != 1 {
+		t.Fatalf("%d messages for the same update sent twice, want 1", n)
+	}
+
+	handle(ctx, tg.bot, update(101))
+	if n := len(tg.messages(7951)); n != 2 {
+		t.Errorf("%d messages after a new update, want 2", n)
+	}
+}
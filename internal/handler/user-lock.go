@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// SerializeUserUpdates is a bot middleware that handles one update at a time per user.
+// The bot runs every update in its own goroutine, so without it a double tap on a button
+// could interleave two changes of the same conversation state. Different users are still
+// handled in parallel. The lock is per process, so it doesn't span replicas.
+func (h *Handler) SerializeUserUpdates(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if userId := updateUserID(update); userId != 0 {
+			unlock := h.userLocks.Lock(userId)
+			defer unlock()
+		}
+
+		next(ctx, b, update)
+	}
+}
+
+// updateUserID returns who sent an update, 0 for updates without a user
+func updateUserID(update *models.Update) int64 {
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		return update.Message.From.ID
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.ID
+	case update.EditedMessage != nil && update.EditedMessage.From != nil:
+		return update.EditedMessage.From.ID
+	case update.PreCheckoutQuery != nil && update.PreCheckoutQuery.From != nil:
+		return update.PreCheckoutQuery.From.ID
+	}
+	return 0
+}
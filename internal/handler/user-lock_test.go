@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"parfum/internal/domain"
+)
+
+func callbackUpdate(userID int64) *models.Update {
+	return &models.Update{CallbackQuery: &models.CallbackQuery{From: models.User{ID: userID}, Data: "count_1"}}
+}
+
+func TestSerializeUserUpdatesUnderLoad(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+	const userID, taps = 7961, 50
+
+	// Each update reads the state, takes a moment and saves it back changed: interleaved
+	// updates would lose counts, and a Pay saved before its Count would end out of order
+	handle := h.SerializeUserUpdates(func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		state := h.getOrCreateUserState(ctx, update.CallbackQuery.From.ID)
+		state.Count++
+		state.State = StateCount
+		if err := h.redisRepo.SaveUserState(ctx, update.CallbackQuery.From.ID, state); err != nil {
+			t.Errorf("save state: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+
+		state.State = StatePay
+		if err := h.redisRepo.SaveUserState(ctx, update.CallbackQuery.From.ID, state); err != nil {
+			t.Errorf("save state: %v", err)
+		}
+	})
+
+	var wg sync.WaitGroup
+	for range taps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handle(ctx, nil, callbackUpdate(userID))
+		}()
+	}
+	wg.Wait()
+
+	state, err := h.redisRepo.GetUserState(ctx, userID)
+	if err != nil {
+		t.Fatalf("get state: %v", err)
+	}
+	if want := (domain.UserState{State: StatePay, Count: taps}); state == nil || state.State != want.State || state.Count != want.Count {
+		t.Errorf("state = %+v, want %s after %d counts", state, want.State, want.Count)
+	}
+	if n := h.userLocks.Len(); n != 0 {
+		t.Errorf("%d user locks left after the updates", n)
+	}
+}
+
+func TestSerializeUserUpdatesKeepsUsersParallel(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	// The first user's update waits inside the handler until the second user's has run
+	started := make(chan struct{})
+	secondDone := make(chan struct{})
+	handle := h.SerializeUserUpdates(func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if update.CallbackQuery.From.ID == 7971 {
+			close(started)
+			<-secondDone
+			return
+		}
+		close(secondDone)
+	})
+
+	go handle(ctx, nil, callbackUpdate(7971))
+	<-started
+	go handle(ctx, nil, callbackUpdate(7972))
+
+	select {
+	case <-secondDone:
+	case <-time.After(3 * time.Second):
+		t.Fatal("one user's update blocked another's")
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"parfum/internal/domain"
+	"parfum/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// Everything support needs about a user in one call: their client record, orders, loto
+// tickets and prizes
+// GET /api/user/profile?telegram_id=
+// A user who never filled in the address form has "client": null; one the bot has no
+// trace of is a 404.
+func (h *Handler) handleGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	h.setCORSHeaders(w)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed", nil)
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(r.URL.Query().Get("telegram_id"), 10, 64)
+	if err != nil || telegramID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid_telegram_id", "telegram_id must be a positive number", nil)
+		return
+	}
+
+	client, err := h.clientRepo.GetByTelegramID(r.Context(), telegramID)
+	if errors.Is(err, repository.ErrClientNotFound) {
+		client = nil
+	} else if err != nil {
+		h.logger.Error("Error getting client", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	orders, err := h.orderRepo.GetByUserID(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Error("Error getting user orders", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	tickets, err := h.clientRepo.GetLotoByUser(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Error("Error getting loto tickets", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	registered, err := h.clientRepo.ExistsJust(r.Context(), telegramID)
+	if err != nil {
+		h.logger.Error("Error checking user", zap.Error(err), zap.Int64("telegram_id", telegramID))
+		writeJSONError(w, http.StatusInternalServerError, "database_error", "Database error", nil)
+		return
+	}
+
+	if !registered && client == nil && len(orders) == 0 && len(tickets) == 0 {
+		writeJSONError(w, http.StatusNotFound, "user_not_found", "User not found", nil)
+		return
+	}
+
+	if orders == nil {
+		orders = []domain.Order{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"telegram_id":  telegramID,
+		"registered":   registered,
+		"language":     h.chosenLang(r.Context(), telegramID),
+		"client":       client,
+		"orders":       orders,
+		"loto_tickets": profileLotoTickets(tickets),
+		"prizes":       profilePrizes(orders),
+	})
+}
+
+// profileLotoTickets lists loto tickets without the NULL wrappers of domain.LotoEntry
+func profileLotoTickets(tickets []domain.LotoEntry) []map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(tickets))
+	for _, ticket := range tickets {
+		items = append(items, map[string]interface{}{
+			"loto_id":    ticket.LotoID,
+			"qr":         ticket.QR,
+			"receipt":    ticket.Receipt,
+			"date_pay":   ticket.DatePay,
+			"checks":     ticket.Checks,
+			"updated_at": ticket.UpdatedAt,
+		})
+	}
+	return items
+}
+
+// profilePrizes is the user's prize status: the prizes won on their orders and how many
+// orders can still spin the wheel
+func profilePrizes(orders []domain.Order) map[string]interface{} {
+	won := []map[string]interface{}{}
+	pendingSpins := 0
+	for _, order := range orders {
+		if order.Gift == "" || order.Gift == "null" {
+			if order.Parfumes != "" && order.Status != domain.OrderStatusCancelled && order.Status != domain.OrderStatusAwaitingPayment {
+				pendingSpins++
+			}
+			continue
+		}
+		won = append(won, map[string]interface{}{
+			"order_id":     order.ID,
+			"prize":        order.Gift,
+			"prize_name":   PrizeDisplayName(order.Gift),
+			"completed":    order.PrizeClaimedAt != nil,
+			"claim_status": order.PrizeClaim(),
+		})
+	}
+
+	return map[string]interface{}{
+		"won":           won,
+		"pending_spins": pendingSpins,
+	}
+}
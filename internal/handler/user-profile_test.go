@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"parfum/internal/domain"
+	"parfum/internal/service/i18n"
+)
+
+func getUserProfile(h *Handler, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.handleGetUserProfile(rec, adminRequest("GET", "/api/user/profile"+query, ""))
+	return rec
+}
+
+type userProfile struct {
+	Success     bool                     `json:"success"`
+	TelegramID  int64                    `json:"telegram_id"`
+	Registered  bool                     `json:"registered"`
+	Language    string                   `json:"language"`
+	Client      *domain.Client           `json:"client"`
+	Orders      []domain.Order           `json:"orders"`
+	LotoTickets []map[string]interface{} `json:"loto_tickets"`
+	Prizes      struct {
+		Won []struct {
+			OrderID   int64  `json:"order_id"`
+			Prize     string `json:"prize"`
+			Completed bool   `json:"completed"`
+		} `json:"won"`
+		PendingSpins int `json:"pending_spins"`
+	} `json:"prizes"`
+}
+
+func TestUserProfileAggregatesUserData(t *testing.T) {
+	h, db := newTestHandler(t)
+	ctx := context.Background()
+	const userID = 8301
+
+	if err := h.clientRepo.InsertJust(ctx, domain.JustEntry{UserId: userID, UserName: "Айгерим"}); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	if err := h.clientRepo.SetLanguage(ctx, userID, i18n.LangRu); err != nil {
+		t.Fatalf("set language: %v", err)
+	}
+	client := &domain.Client{TelegramID: userID, FIO: "Айгерим", Contact: "+77011234567", Address: "Алматы"}
+	if err := h.clientRepo.SaveOrUpdate(ctx, client); err != nil {
+		t.Fatalf("save client: %v", err)
+	}
+
+	// A paid order with two tickets, a won ring and an order still to spin
+	payment := func(userID int64, qr string, lotoIDs ...int) domain.PaymentEntry {
+		entry := domain.PaymentEntry{UserID: userID, UserName: "u", Quantity: len(lotoIDs), Amount: 2499 * len(lotoIDs), QR: qr, Receipt: "receipt.pdf", DatePay: "2026-03-01 10:00:00"}
+		for _, lotoID := range lotoIDs {
+			entry.Tickets = append(entry.Tickets, domain.LotoEntry{UserID: userID, LotoID: lotoID, QR: qr, Receipt: "receipt.pdf", DatePay: entry.DatePay})
+		}
+		return entry
+	}
+	if _, _, err := h.clientRepo.RecordPayment(ctx, payment(userID, "qr-profile", 11, 12)); err != nil {
+		t.Fatalf("record payment: %v", err)
+	}
+	ring := seedOrder(t, db, domain.Order{IDUser: userID, Parfumes: "Baccarat Rouge: 1"})
+	setOrderPrize(t, db, ring.ID, PrizeDiamond)
+	if _, err := db.Exec(`UPDATE orders SET prize_claimed_at = CURRENT_TIMESTAMP WHERE id = ?`, ring.ID); err != nil {
+		t.Fatalf("claim prize: %v", err)
+	}
+	seedOrder(t, db, domain.Order{IDUser: userID, Parfumes: "Lost Cherry: 1"})
+
+	// Someone else's ticket and order stay out of the profile
+	if _, _, err := h.clientRepo.RecordPayment(ctx, payment(8399, "qr-other", 13)); err != nil {
+		t.Fatalf("record other payment: %v", err)
+	}
+
+	rec := getUserProfile(h, fmt.Sprintf("?telegram_id=%d", userID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var profile userProfile
+	decodeJSON(t, rec, &profile)
+
+	if !profile.Success || profile.TelegramID != userID || !profile.Registered || profile.Language != i18n.LangRu {
+		t.Errorf("profile = %+v, want the registered ru user", profile)
+	}
+	if profile.Client == nil || profile.Client.FIO != "Айгерим" || profile.Client.Contact != "+77011234567" || profile.Client.Address != "Алматы" {
+		t.Errorf("client = %+v, want the saved one", profile.Client)
+	}
+	if len(profile.Orders) != 3 {
+		t.Errorf("orders = %d, want 3", len(profile.Orders))
+	}
+	for _, order := range profile.Orders {
+		if order.IDUser != userID {
+			t.Errorf("order %d belongs to %d", order.ID, order.IDUser)
+		}
+	}
+
+	if len(profile.LotoTickets) != 2 {
+		t.Fatalf("loto tickets = %+v, want 2", profile.LotoTickets)
+	}
+	for i, lotoID := range []float64{11, 12} {
+		if ticket := profile.LotoTickets[i]; ticket["loto_id"] != lotoID || ticket["qr"] != "qr-profile" || ticket["checks"] != false {
+			t.Errorf("ticket %d = %+v, want %v on qr-profile", i, ticket, lotoID)
+		}
+	}
+
+	won := profile.Prizes.Won
+	if len(won) != 1 || won[0].OrderID != ring.ID || won[0].Prize != PrizeDiamond || !won[0].Completed {
+		t.Errorf("prizes won = %+v, want the claimed ring", won)
+	}
+	if profile.Prizes.PendingSpins != 1 {
+		t.Errorf("pending spins = %d, want 1", profile.Prizes.PendingSpins)
+	}
+}
+
+func TestUserProfileWithoutClientRecord(t *testing.T) {
+	h, _ := newTestHandler(t)
+	const userID = 8302
+
+	if err := h.clientRepo.InsertJust(context.Background(), domain.JustEntry{UserId: userID, UserName: "new"}); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+
+	rec := getUserProfile(h, fmt.Sprintf("?telegram_id=%d", userID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var profile userProfile
+	decodeJSON(t, rec, &profile)
+	if profile.Client != nil || !profile.Registered {
+		t.Errorf("profile = %+v, want a registered user without a client", profile)
+	}
+	if profile.Orders == nil || len(profile.Orders) != 0 || profile.LotoTickets == nil || len(profile.LotoTickets) != 0 {
+		t.Errorf("orders = %v, tickets = %v; want empty lists", profile.Orders, profile.LotoTickets)
+	}
+	if profile.Prizes.Won == nil || profile.Prizes.PendingSpins != 0 {
+		t.Errorf("prizes = %+v, want none", profile.Prizes)
+	}
+}
+
+func TestUserProfileRejects(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, tc := range []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusBadRequest},
+		{"?telegram_id=abc", http.StatusBadRequest},
+		{"?telegram_id=-5", http.StatusBadRequest},
+		{"?telegram_id=8303", http.StatusNotFound},
+	} {
+		if rec := getUserProfile(h, tc.query); rec.Code != tc.status {
+			t.Errorf("%q: status = %d, want %d", tc.query, rec.Code, tc.status)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot"
+
+	"parfum/config"
+	"parfum/internal/service/i18n"
+)
+
+// postUpdate posts a Telegram update to the webhook path, with secret unless it is empty
+func postUpdate(t *testing.T, server *httptest.Server, path, secret, update string) {
+	t.Helper()
+
+	r, err := http.NewRequest("POST", server.URL+path, strings.NewReader(update))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		r.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	}
+	resp, err := server.Client().Do(r)
+	if err != nil {
+		t.Fatalf("post update: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestWebhookUpdateReachesStateMachine(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.BotMode = config.BotModeWebhook
+	cfg.Token = "123:test"
+	h, _ := newTestHandlerWithConfig(t, cfg)
+
+	tg := newFakeTelegram(t,
+		bot.WithMiddlewares(h.SkipDuplicateUpdates, h.SerializeUserUpdates),
+		bot.WithDefaultHandler(h.DefaultHandler),
+		bot.WithWebhookSecretToken(cfg.WebhookSecret()),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go tg.bot.StartWebhook(ctx)
+
+	server := httptest.NewServer(h.routes(tg.bot))
+	t.Cleanup(server.Close)
+
+	// Without the secret the update is dropped
+	postUpdate(t, server, cfg.WebhookPath(), "",
+		`{"update_id": 1, "message": {"message_id": 1, "date": 0, "chat": {"id": 7801, "type": "private"}, "from": {"id": 7801, "first_name": "A"}, "text": "salem"}}`)
+
+	postUpdate(t, server, cfg.WebhookPath(), cfg.WebhookSecret(),
+		`{"update_id": 2, "message": {"message_id": 1, "date": 0, "chat": {"id": 7802, "type": "private"}, "from": {"id": 7802, "first_name": "B", "username": "buyer"}, "text": "salem"}}`)
+
+	// A new user starts by choosing a language
+	tg.waitForMessage(t, 7802, i18n.T(i18n.LangKz, i18n.LanguagePrompt))
+	if exists, err := h.clientRepo.ExistsJust(ctx, 7802); err != nil || !exists {
+		t.Errorf("user registered = %v, %v; want true", exists, err)
+	}
+	if state, err := h.redisRepo.GetUserState(ctx, 7802); err != nil || state == nil || state.State != StateStart {
+		t.Errorf("state = %+v, %v; want %s", state, err, StateStart)
+	}
+
+	if exists, err := h.clientRepo.ExistsJust(ctx, 7801); err != nil || exists {
+		t.Errorf("user of the update without the secret registered = %v, %v; want false", exists, err)
+	}
+	if n := len(tg.messages(7801)); n != 0 {
+		t.Errorf("%d messages sent for the update without the secret", n)
+	}
+}
@@ -3,10 +3,18 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"parfum/internal/domain"
+	"strings"
 	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
+// ErrClientNotFound is returned by client lookups when no client matches
+var ErrClientNotFound = errors.New("client not found")
+
 type ClientRepository struct {
 	db *sql.DB
 }
@@ -16,10 +24,10 @@ func NewClientRepository(db *sql.DB) *ClientRepository {
 }
 
 // SaveOrUpdate creates or updates a client
-func (r *ClientRepository) SaveOrUpdate(client *domain.Client) error {
+func (r *ClientRepository) SaveOrUpdate(ctx context.Context, client *domain.Client) error {
 	// Check if client exists
-	existingClient, err := r.GetByTelegramID(client.TelegramID)
-	if err != nil && err != sql.ErrNoRows {
+	existingClient, err := r.GetByTelegramID(ctx, client.TelegramID)
+	if err != nil && !errors.Is(err, ErrClientNotFound) {
 		return err
 	}
 
@@ -30,7 +38,7 @@ func (r *ClientRepository) SaveOrUpdate(client *domain.Client) error {
 			SET fio = ?, contact = ?, address = ?, latitude = ?, longitude = ?, updated_at = CURRENT_TIMESTAMP 
 			WHERE telegram_id = ?
 		`
-		_, err = r.db.Exec(query, client.FIO, client.Contact, client.Address, client.Latitude, client.Longitude, client.TelegramID)
+		_, err = r.db.ExecContext(ctx, query, client.FIO, client.Contact, client.Address, client.Latitude, client.Longitude, client.TelegramID)
 		if err != nil {
 			return err
 		}
@@ -41,7 +49,7 @@ func (r *ClientRepository) SaveOrUpdate(client *domain.Client) error {
 			INSERT INTO clients (telegram_id, fio, contact, address, latitude, longitude, created_at, updated_at)
 			VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		`
-		result, err := r.db.Exec(query, client.TelegramID, client.FIO, client.Contact, client.Address, client.Latitude, client.Longitude)
+		result, err := r.db.ExecContext(ctx, query, client.TelegramID, client.FIO, client.Contact, client.Address, client.Latitude, client.Longitude)
 		if err != nil {
 			return err
 		}
@@ -56,15 +64,15 @@ func (r *ClientRepository) SaveOrUpdate(client *domain.Client) error {
 	return nil
 }
 
-// GetByTelegramID retrieves a client by telegram ID
-func (r *ClientRepository) GetByTelegramID(telegramID int64) (*domain.Client, error) {
+// GetByTelegramID retrieves a client by telegram ID; ErrClientNotFound if there is none
+func (r *ClientRepository) GetByTelegramID(ctx context.Context, telegramID int64) (*domain.Client, error) {
 	query := `
 		SELECT id, telegram_id, fio, contact, address, latitude, longitude, created_at, updated_at
 		FROM clients 
 		WHERE telegram_id = ?
 	`
 
-	row := r.db.QueryRow(query, telegramID)
+	row := r.db.QueryRowContext(ctx, query, telegramID)
 
 	var client domain.Client
 	var createdAt, updatedAt time.Time
@@ -81,6 +89,9 @@ func (r *ClientRepository) GetByTelegramID(telegramID int64) (*domain.Client, er
 		&updatedAt,
 	)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrClientNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -91,15 +102,15 @@ func (r *ClientRepository) GetByTelegramID(telegramID int64) (*domain.Client, er
 	return &client, nil
 }
 
-// GetByID retrieves a client by ID
-func (r *ClientRepository) GetByID(id int64) (*domain.Client, error) {
+// GetByID retrieves a client by ID; ErrClientNotFound if there is none
+func (r *ClientRepository) GetByID(ctx context.Context, id int64) (*domain.Client, error) {
 	query := `
 		SELECT id, telegram_id, fio, contact, address, latitude, longitude, created_at, updated_at
 		FROM clients 
 		WHERE id = ?
 	`
 
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 
 	var client domain.Client
 	var createdAt, updatedAt time.Time
@@ -116,6 +127,9 @@ func (r *ClientRepository) GetByID(id int64) (*domain.Client, error) {
 		&updatedAt,
 	)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrClientNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -127,14 +141,17 @@ func (r *ClientRepository) GetByID(id int64) (*domain.Client, error) {
 }
 
 // GetAll retrieves all clients
-func (r *ClientRepository) GetAll() ([]domain.Client, error) {
+func (r *ClientRepository) GetAll(ctx context.Context) ([]domain.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
 	query := `
 		SELECT id, telegram_id, fio, contact, address, latitude, longitude, created_at, updated_at
 		FROM clients 
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -171,10 +188,113 @@ func (r *ClientRepository) GetAll() ([]domain.Client, error) {
 	return clients, nil
 }
 
+// phoneDigitsSQL reduces the contact column of table to the digits of a phone number in
+// 7XXXXXXXXXX form (see service.PhoneDigits), selected as contact_digits
+func phoneDigitsSQL(table string) string {
+	return `
+	(SELECT c.*,
+		CASE
+			WHEN length(c.digits) = 10 AND c.digits LIKE '7%' THEN '7' || c.digits
+			WHEN c.digits LIKE '8%' THEN '7' || substr(c.digits, 2)
+			ELSE c.digits
+		END AS contact_digits
+	FROM (
+		SELECT *, REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(contact, '+', ''), ' ', ''), '-', ''), '(', ''), ')', '') AS digits
+		FROM ` + table + `
+	) c) c`
+}
+
+// clientListSource is the client table with its contact_digits
+var clientListSource = phoneDigitsSQL("client")
+
+// clientFilterClause builds the WHERE clause and its arguments for a ClientFilter
+func clientFilterClause(filter domain.ClientFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Query != "" {
+		pattern := "%" + filter.Query + "%"
+		match := "c.fio LIKE ? OR c.userName LIKE ? OR c.contact LIKE ?"
+		args = append(args, pattern, pattern, pattern)
+		if filter.Phone != "" {
+			match += " OR c.contact_digits LIKE ?"
+			args = append(args, "%"+filter.Phone+"%")
+		}
+		conditions = append(conditions, "("+match+")")
+	}
+
+	if filter.HasOrders != nil {
+		exists := "EXISTS (SELECT 1 FROM orders o WHERE o.id_user = c.id_user)"
+		if !*filter.HasOrders {
+			exists = "NOT " + exists
+		}
+		conditions = append(conditions, exists)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// SearchClients returns a page of paying clients, newest first, with their order counts
+func (r *ClientRepository) SearchClients(ctx context.Context, filter domain.ClientFilter) ([]domain.ClientListItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
+	where, args := clientFilterClause(filter)
+	query := `
+		SELECT c.id, c.id_user, c.userName, COALESCE(c.fio, ''), c.contact, COALESCE(c.address, ''),
+			c.dataPay, c.created_at,
+			(SELECT COUNT(*) FROM orders o WHERE o.id_user = c.id_user) AS order_count
+		FROM ` + clientListSource + where + `
+		ORDER BY c.created_at DESC, c.id DESC
+		LIMIT ? OFFSET ?
+	`
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []domain.ClientListItem{}
+	for rows.Next() {
+		var client domain.ClientListItem
+		err := rows.Scan(
+			&client.ID,
+			&client.TelegramID,
+			&client.UserName,
+			&client.FIO,
+			&client.Contact,
+			&client.Address,
+			&client.DatePay,
+			&client.CreatedAt,
+			&client.OrderCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+// CountSearchClients counts the clients matching filter, ignoring its Limit and Offset
+func (r *ClientRepository) CountSearchClients(ctx context.Context, filter domain.ClientFilter) (int, error) {
+	where, args := clientFilterClause(filter)
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+clientListSource+where, args...).Scan(&total)
+	return total, err
+}
+
 // Delete removes a client by ID
-func (r *ClientRepository) Delete(id int64) error {
+func (r *ClientRepository) Delete(ctx context.Context, id int64) error {
 	query := "DELETE FROM clients WHERE id = ?"
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
 
@@ -188,6 +308,39 @@ func (r *ClientRepository) ExistsJust(ctx context.Context, userId int64) (bool,
 	return cnt > 0, nil
 }
 
+// GetUserName возвращает userName из just; пустая строка, если пользователь не найден
+func (r *ClientRepository) GetUserName(ctx context.Context, userID int64) (string, error) {
+	const q = `SELECT userName FROM just WHERE id_user = ?;`
+	var userName string
+	err := r.db.QueryRowContext(ctx, q, userID).Scan(&userName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return userName, err
+}
+
+// GetPreferredLanguage возвращает язык, выбранный в боте (just), иначе язык клиента
+// мини-приложения (clients); пустая строка, если язык ещё не выбран
+func (r *ClientRepository) GetPreferredLanguage(ctx context.Context, telegramID int64) (string, error) {
+	const q = `
+		SELECT COALESCE(
+			(SELECT language FROM just WHERE id_user = ?),
+			(SELECT preferred_language FROM clients WHERE telegram_id = ?),
+			''
+		);
+	`
+	var lang string
+	err := r.db.QueryRowContext(ctx, q, telegramID, telegramID).Scan(&lang)
+	return lang, err
+}
+
+// SetLanguage сохраняет язык, выбранный пользователем в боте
+func (r *ClientRepository) SetLanguage(ctx context.Context, userID int64, lang string) error {
+	const q = `UPDATE just SET language = ?, updated_at = datetime('now') WHERE id_user = ?;`
+	_, err := r.db.ExecContext(ctx, q, lang, userID)
+	return err
+}
+
 // ExistsClient проверяет, есть ли запись в client по id_user
 func (r *ClientRepository) ExistsClient(ctx context.Context, userID int64) (bool, error) {
 	const q = `SELECT COUNT(1) FROM client WHERE id_user = ?;`
@@ -208,6 +361,175 @@ func (r *ClientRepository) ExistsLoto(ctx context.Context, userID int64) (bool,
 	return cnt > 0, nil
 }
 
+// GetLotoTickets возвращает номера лото-билетов пользователя в порядке выдачи
+func (r *ClientRepository) GetLotoTickets(ctx context.Context, userID int64) ([]int, error) {
+	const q = `SELECT id_loto FROM loto WHERE id_user = ? ORDER BY id_loto;`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tickets []int
+	for rows.Next() {
+		var ticket int
+		if err := rows.Scan(&ticket); err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets, rows.Err()
+}
+
+// lotoEntryColumns — колонки loto в порядке scanLotoEntries; NULL в текстовых
+// колонках, которые не sql.NullString, читается как пустая строка
+const lotoEntryColumns = `id_user, id_loto, COALESCE(qr, ''), who_paid, COALESCE(receipt, ''), fio, contact, address,
+	dataPay, COALESCE(updated_at, created_at, ''), COALESCE(checks, FALSE)`
+
+func scanLotoEntries(rows *sql.Rows) ([]domain.LotoEntry, error) {
+	defer rows.Close()
+
+	tickets := []domain.LotoEntry{}
+	for rows.Next() {
+		var ticket domain.LotoEntry
+		err := rows.Scan(&ticket.UserID, &ticket.LotoID, &ticket.QR, &ticket.WhoPaid, &ticket.Receipt,
+			&ticket.Fio, &ticket.Contact, &ticket.Address, &ticket.DatePay, &ticket.UpdatedAt, &ticket.Checks)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets, rows.Err()
+}
+
+// GetLotoByUser возвращает все лото-билеты пользователя с чеками, по которым они выданы,
+// в порядке выдачи
+func (r *ClientRepository) GetLotoByUser(ctx context.Context, userID int64) ([]domain.LotoEntry, error) {
+	q := `SELECT ` + lotoEntryColumns + ` FROM loto WHERE id_user = ? ORDER BY id;`
+	rows, err := r.db.QueryContext(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLotoEntries(rows)
+}
+
+// GetAllLoto возвращает все выданные лото-билеты по возрастанию номера — участники розыгрыша
+func (r *ClientRepository) GetAllLoto(ctx context.Context) ([]domain.LotoEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
+	q := `SELECT ` + lotoEntryColumns + ` FROM loto ORDER BY id_loto, id;`
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return scanLotoEntries(rows)
+}
+
+// ErrNoLotoTickets возвращается DrawRandomLotoWinner, когда все билеты уже разыграны
+var ErrNoLotoTickets = errors.New("no loto tickets left to draw")
+
+// DrawRandomLotoWinner выбирает случайный ещё не разыгранный лото-билет и отмечает его
+// разыгранным (checks), так что один билет не выигрывает дважды
+func (r *ClientRepository) DrawRandomLotoWinner(ctx context.Context) (domain.LotoEntry, error) {
+	q := `
+		UPDATE loto SET checks = TRUE, updated_at = datetime('now')
+		WHERE id = (SELECT id FROM loto WHERE COALESCE(checks, FALSE) = FALSE ORDER BY RANDOM() LIMIT 1)
+		RETURNING ` + lotoEntryColumns + `;`
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return domain.LotoEntry{}, fmt.Errorf("failed to draw loto ticket: %w", err)
+	}
+	tickets, err := scanLotoEntries(rows)
+	if err != nil {
+		return domain.LotoEntry{}, fmt.Errorf("failed to draw loto ticket: %w", err)
+	}
+	if len(tickets) == 0 {
+		return domain.LotoEntry{}, ErrNoLotoTickets
+	}
+	return tickets[0], nil
+}
+
+// CountUndrawnLoto возвращает, сколько лото-билетов ещё участвует в розыгрыше
+func (r *ClientRepository) CountUndrawnLoto(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM loto WHERE COALESCE(checks, FALSE) = FALSE;`).Scan(&count)
+	return count, err
+}
+
+// CountLotoByUserAndReceipt возвращает, сколько лото-билетов выдано пользователю за чек с этим QR
+func (r *ClientRepository) CountLotoByUserAndReceipt(ctx context.Context, userID int64, qr string) (int, error) {
+	const q = `SELECT COUNT(*) FROM loto WHERE id_user = ? AND qr = ?;`
+	var count int
+	err := r.db.QueryRowContext(ctx, q, userID, qr).Scan(&count)
+	return count, err
+}
+
+// GetLotoReceipt возвращает QR чека, за который пользователь получил билеты в оплату
+// datePay; пустая строка, если таких билетов нет. Нужен для заказов без payment_received
+func (r *ClientRepository) GetLotoReceipt(ctx context.Context, userID int64, datePay string) (string, error) {
+	const q = `
+		SELECT qr FROM loto
+		WHERE id_user = ? AND dataPay = ? AND qr IS NOT NULL AND qr != ''
+		ORDER BY id
+		LIMIT 1;
+	`
+	var qr string
+	err := r.db.QueryRowContext(ctx, q, userID, datePay).Scan(&qr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return qr, err
+}
+
+// TopUpLoto выдаёт пользователю недостающие билеты за чек qr, чтобы их стало expected, и
+// возвращает номера новых билетов. Номера берутся из draw; номер, который у пользователя
+// уже есть, пропускается и заменяется новым. Подсчёт и вставка идут в одной транзакции,
+// поэтому повторный вызов ничего не добавляет.
+func (r *ClientRepository) TopUpLoto(ctx context.Context, userID int64, qr, datePay string, expected int, draw func(n int) []int) ([]int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin loto transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var existing int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM loto WHERE id_user = ? AND qr = ?;`, userID, qr).Scan(&existing); err != nil {
+		return nil, fmt.Errorf("failed to count loto: %w", err)
+	}
+
+	// The new tickets point at the same receipt file as the payment
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO loto (id_user, id_loto, qr, receipt, dataPay, checks, updated_at)
+		VALUES (?, ?, ?, (SELECT file_path FROM receipts WHERE qr = ?), ?, FALSE, datetime('now'))
+		ON CONFLICT(id_user, id_loto) DO NOTHING;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare loto insert: %w", err)
+	}
+	defer stmt.Close()
+
+	var issued []int
+	for missing := expected - existing; missing > 0; missing = expected - existing - len(issued) {
+		for _, lotoID := range draw(missing) {
+			result, err := stmt.ExecContext(ctx, userID, lotoID, qr, qr, datePay)
+			if err != nil {
+				return nil, fmt.Errorf("failed to insert loto %d: %w", lotoID, err)
+			}
+			if inserted, err := result.RowsAffected(); err != nil {
+				return nil, err
+			} else if inserted == 1 {
+				issued = append(issued, lotoID)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit loto transaction: %w", err)
+	}
+	return issued, nil
+}
+
 // ExistsGeo проверяет, есть ли запись в geo по id_user
 func (r *ClientRepository) ExistsGeo(ctx context.Context, userID int64) (bool, error) {
 	const q = `SELECT COUNT(1) FROM geo WHERE id_user = ?;`
@@ -232,11 +554,13 @@ func (r *ClientRepository) IsClientPaid(ctx context.Context, userID int64) (bool
 	return checks, nil
 }
 
-// InsertJust вставляет запись в таблицу just с учетом новых полей (SQLite version)
+// InsertJust вставляет запись в таблицу just (SQLite version).
+// Повторный вызов для того же id_user ничего не меняет — дата регистрации сохраняется.
 func (r *ClientRepository) InsertJust(ctx context.Context, e domain.JustEntry) error {
 	const q = `
-		INSERT OR REPLACE INTO just (id_user, userName, dataRegistred, updated_at)
-		VALUES (?, ?, ?, datetime('now'));
+		INSERT INTO just (id_user, userName, dataRegistred, updated_at)
+		VALUES (?, ?, ?, datetime('now'))
+		ON CONFLICT(id_user) DO NOTHING;
 	`
 	_, err := r.db.ExecContext(ctx, q, e.UserId, e.UserName, e.DateRegistered)
 	return err
@@ -255,41 +579,454 @@ func (r *ClientRepository) InsertClient(ctx context.Context, e domain.ClientEntr
 	return err
 }
 
-func (r *ClientRepository) IsUniqueQr(ctx context.Context, qr string) (bool, error) {
-	const q = `SELECT COUNT(1) FROM loto WHERE qr = ?;`
-	var cnt int
-	if err := r.db.QueryRowContext(ctx, q, qr).Scan(&cnt); err != nil {
+// CountUsers returns the number of users who ever started the bot
+func (r *ClientRepository) CountUsers(ctx context.Context) (int, error) {
+	const q = `SELECT COUNT(*) FROM just;`
+	var count int
+	err := r.db.QueryRowContext(ctx, q).Scan(&count)
+	return count, err
+}
+
+// CountClients returns the number of users who shared their contact after paying
+func (r *ClientRepository) CountClients(ctx context.Context) (int, error) {
+	const q = `SELECT COUNT(*) FROM client;`
+	var count int
+	err := r.db.QueryRowContext(ctx, q).Scan(&count)
+	return count, err
+}
+
+// GetTotalSum returns the total paid sum tracked by RecordPayment
+func (r *ClientRepository) GetTotalSum(ctx context.Context) (int64, error) {
+	const q = `SELECT COALESCE(SUM(sum), 0) FROM money;`
+	var sum int64
+	err := r.db.QueryRowContext(ctx, q).Scan(&sum)
+	return sum, err
+}
+
+// LogFailedNotification сохраняет недоставленное пользователю сообщение и возвращает его id
+func (r *ClientRepository) LogFailedNotification(ctx context.Context, n domain.FailedNotification) (int64, error) {
+	const q = `
+		INSERT INTO failed_notifications (telegram_id, order_id, message, parse_mode, reply_markup, reason)
+		VALUES (?, ?, ?, ?, ?, ?);
+	`
+	res, err := r.db.ExecContext(ctx, q, n.TelegramID, n.OrderID, n.Message, n.ParseMode, n.ReplyMarkup, n.Reason)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+const failedNotificationColumns = `id, telegram_id, order_id, message, parse_mode, reply_markup, reason, attempts,
+	created_at, last_attempt_at, delivered_at`
+
+func scanFailedNotification(scanner interface{ Scan(...interface{}) error }) (domain.FailedNotification, error) {
+	var n domain.FailedNotification
+	var orderID sql.NullInt64
+	var deliveredAt sql.NullTime
+	err := scanner.Scan(&n.ID, &n.TelegramID, &orderID, &n.Message, &n.ParseMode, &n.ReplyMarkup, &n.Reason,
+		&n.Attempts, &n.CreatedAt, &n.LastAttemptAt, &deliveredAt)
+	if err != nil {
+		return n, err
+	}
+	if orderID.Valid {
+		n.OrderID = &orderID.Int64
+	}
+	if deliveredAt.Valid {
+		n.DeliveredAt = &deliveredAt.Time
+	}
+	return n, nil
+}
+
+func failedNotificationFilterClause(filter domain.FailedNotificationFilter) string {
+	switch {
+	case filter.Delivered == nil:
+		return ""
+	case *filter.Delivered:
+		return " WHERE delivered_at IS NOT NULL"
+	default:
+		return " WHERE delivered_at IS NULL"
+	}
+}
+
+// GetFailedNotifications returns a page of failed notifications, newest first
+func (r *ClientRepository) GetFailedNotifications(ctx context.Context, filter domain.FailedNotificationFilter) ([]domain.FailedNotification, error) {
+	query := `SELECT ` + failedNotificationColumns + ` FROM failed_notifications` +
+		failedNotificationFilterClause(filter) + ` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []domain.FailedNotification{}
+	for rows.Next() {
+		n, err := scanFailedNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// CountFailedNotifications counts the failed notifications matching filter, ignoring its Limit and Offset
+func (r *ClientRepository) CountFailedNotifications(ctx context.Context, filter domain.FailedNotificationFilter) (int, error) {
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM failed_notifications`+failedNotificationFilterClause(filter)).Scan(&total)
+	return total, err
+}
+
+// GetFailedNotification returns one failed notification; sql.ErrNoRows if there is none
+func (r *ClientRepository) GetFailedNotification(ctx context.Context, id int64) (*domain.FailedNotification, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+failedNotificationColumns+` FROM failed_notifications WHERE id = ?`, id)
+	n, err := scanFailedNotification(row)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// GetUnsentNotifications returns the undelivered notifications whose last attempt ended
+// with one of reasons, oldest first
+func (r *ClientRepository) GetUnsentNotifications(ctx context.Context, reasons []string, limit int) ([]domain.FailedNotification, error) {
+	if len(reasons) == 0 {
+		return []domain.FailedNotification{}, nil
+	}
+
+	args := make([]interface{}, 0, len(reasons)+1)
+	for _, reason := range reasons {
+		args = append(args, reason)
+	}
+	args = append(args, limit)
+	query := `SELECT ` + failedNotificationColumns + ` FROM failed_notifications
+		WHERE delivered_at IS NULL AND reason IN (?` + strings.Repeat(", ?", len(reasons)-1) + `)
+		ORDER BY created_at, id LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []domain.FailedNotification{}
+	for rows.Next() {
+		n, err := scanFailedNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// RecordNotificationAttempt counts a resend of a failed notification; an empty reason
+// means it was delivered
+func (r *ClientRepository) RecordNotificationAttempt(ctx context.Context, id int64, reason string) error {
+	const q = `
+		UPDATE failed_notifications
+		SET attempts = attempts + 1,
+			last_attempt_at = CURRENT_TIMESTAMP,
+			reason = CASE WHEN ? = '' THEN reason ELSE ? END,
+			delivered_at = CASE WHEN ? = '' THEN CURRENT_TIMESTAMP ELSE NULL END
+		WHERE id = ?;
+	`
+	_, err := r.db.ExecContext(ctx, q, reason, reason, reason, id)
+	return err
+}
+
+const manualPaymentColumns = `id, id_user, quantity, amount, file_path, file_name, reason, status, decided_by,
+	created_at, decided_at`
+
+// CreateManualPayment сохраняет чек для ручной проверки админом и возвращает его id
+func (r *ClientRepository) CreateManualPayment(ctx context.Context, p domain.ManualPayment) (int64, error) {
+	const q = `
+		INSERT INTO manual_payments (id_user, quantity, amount, file_path, file_name, reason)
+		VALUES (?, ?, ?, ?, ?, ?);
+	`
+	res, err := r.db.ExecContext(ctx, q, p.UserID, p.Quantity, p.Amount, p.FilePath, p.FileName, p.Reason)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetManualPayment returns a manual payment by id, or sql.ErrNoRows
+func (r *ClientRepository) GetManualPayment(ctx context.Context, id int64) (*domain.ManualPayment, error) {
+	var p domain.ManualPayment
+	var decidedBy sql.NullInt64
+	var decidedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT `+manualPaymentColumns+` FROM manual_payments WHERE id = ?`, id).Scan(
+		&p.ID, &p.UserID, &p.Quantity, &p.Amount, &p.FilePath, &p.FileName, &p.Reason, &p.Status,
+		&decidedBy, &p.CreatedAt, &decidedAt)
+	if err != nil {
+		return nil, err
+	}
+	if decidedBy.Valid {
+		p.DecidedBy = &decidedBy.Int64
+	}
+	if decidedAt.Valid {
+		p.DecidedAt = &decidedAt.Time
+	}
+	return &p, nil
+}
+
+// DecideManualPayment approves or rejects a pending manual payment. It returns false if
+// the payment was already decided, e.g. by another admin at the same time.
+func (r *ClientRepository) DecideManualPayment(ctx context.Context, id int64, status string, adminID int64) (bool, error) {
+	const q = `
+		UPDATE manual_payments
+		SET status = ?, decided_by = ?, decided_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'pending';
+	`
+	res, err := r.db.ExecContext(ctx, q, status, adminID, id)
+	if err != nil {
 		return false, err
 	}
-	return cnt > 0, nil
+	updated, err := res.RowsAffected()
+	return updated == 1, err
 }
 
-// IncreaseTotalSum increases the total sum by the specified amount
-func (r *ClientRepository) IncreaseTotalSum(ctx context.Context, amount int) error {
-	const q = `UPDATE money SET sum = sum + ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1;`
-	_, err := r.db.ExecContext(ctx, q, amount)
+// ReopenManualPayment returns a decided manual payment to pending, e.g. when recording
+// an approved payment failed
+func (r *ClientRepository) ReopenManualPayment(ctx context.Context, id int64) error {
+	const q = `
+		UPDATE manual_payments
+		SET status = 'pending', decided_by = NULL, decided_at = NULL
+		WHERE id = ?;
+	`
+	_, err := r.db.ExecContext(ctx, q, id)
 	return err
 }
 
-// InsertLoto inserts loto entry with updated domain model
-func (r *ClientRepository) InsertLoto(ctx context.Context, e domain.LotoEntry) error {
+// SavePendingPayment remembers the count and sum quoted to a user, replacing an earlier quote
+func (r *ClientRepository) SavePendingPayment(ctx context.Context, p domain.PendingPayment) error {
 	const q = `
-		INSERT OR REPLACE INTO loto (id_user, id_loto, qr, who_paid, receipt, fio, contact, address, dataPay, checks, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'));
+		INSERT INTO pending_payments (id_user, quantity, amount, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id_user) DO UPDATE SET
+			quantity = excluded.quantity,
+			amount = excluded.amount,
+			updated_at = excluded.updated_at;
 	`
-	_, err := r.db.ExecContext(ctx, q,
-		e.UserID, e.LotoID, e.QR, e.WhoPaid,
-		e.Receipt, e.Fio, e.Contact, e.Address, e.DatePay, e.Checks,
-	)
+	_, err := r.db.ExecContext(ctx, q, p.UserID, p.Quantity, p.Amount)
+	return err
+}
+
+// GetPendingPayment returns the last quote given to a user, or sql.ErrNoRows
+func (r *ClientRepository) GetPendingPayment(ctx context.Context, userID int64) (*domain.PendingPayment, error) {
+	var p domain.PendingPayment
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id_user, quantity, amount, updated_at FROM pending_payments WHERE id_user = ?
+	`, userID).Scan(&p.UserID, &p.Quantity, &p.Amount, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeletePendingPayment forgets the quote given to a user, e.g. when they start over
+func (r *ClientRepository) DeletePendingPayment(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM pending_payments WHERE id_user = ?;`, userID)
 	return err
 }
 
-func (r *ClientRepository) InsertOrder(ctx context.Context, order domain.OrderEntry) error {
+// broadcastAudienceQueries выбирают id_user каждой аудитории рассылки
+var broadcastAudienceQueries = map[string]string{
+	domain.BroadcastAudienceAll:     `SELECT id_user FROM just`,
+	domain.BroadcastAudienceClients: `SELECT id_user FROM client WHERE checks = 1`,
+	domain.BroadcastAudienceWinners: `SELECT DISTINCT id_user FROM orders WHERE gift IS NOT NULL AND gift != '' AND gift != 'null'`,
+}
+
+// GetBroadcastAudience возвращает получателей рассылки, кроме заблокировавших бота
+func (r *ClientRepository) GetBroadcastAudience(ctx context.Context, audience string) ([]int64, error) {
+	query, ok := broadcastAudienceQueries[audience]
+	if !ok {
+		return nil, fmt.Errorf("unknown broadcast audience %q", audience)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT a.id_user FROM (`+query+`) a
+		WHERE a.id_user NOT IN (SELECT id_user FROM broadcast_blocked)
+		ORDER BY a.id_user;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// MarkBroadcastBlocked записывает пользователя, которому рассылка не доходит (бот заблокирован)
+func (r *ClientRepository) MarkBroadcastBlocked(ctx context.Context, userID int64, reason string) error {
+	const q = `
+		INSERT INTO broadcast_blocked (id_user, reason) VALUES (?, ?)
+		ON CONFLICT(id_user) DO UPDATE SET reason = excluded.reason, created_at = CURRENT_TIMESTAMP;`
+	_, err := r.db.ExecContext(ctx, q, userID, reason)
+	return err
+}
+
+// paymentAttempts is how many times RecordPayment tries a payment while the database is busy
+const paymentAttempts = 3
+
+// RecordPayment writes everything a validated receipt pays for in one transaction: the
+// receipt claim, the loto tickets, the money total, the client and the order. claimed is
+// false, and nothing is written, when the receipt's QR was already paid out. A busy
+// database is retried; any other error rolls everything back, so a crash or failure
+// never leaves a paid receipt without its order.
+func (r *ClientRepository) RecordPayment(ctx context.Context, p domain.PaymentEntry) (orderID int64, claimed bool, err error) {
+	for attempt := 1; ; attempt++ {
+		orderID, claimed, err = r.recordPayment(ctx, p)
+		if err == nil || attempt >= paymentAttempts || !isDatabaseBusy(err) {
+			return orderID, claimed, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, false, ctx.Err()
+		case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+		}
+	}
+}
+
+func (r *ClientRepository) recordPayment(ctx context.Context, p domain.PaymentEntry) (int64, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin payment transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Claiming the QR is what credits the receipt, so the same receipt sent twice at
+	// once is only paid out by the transaction that inserts it
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO receipts (qr, id_user, amount, file_path, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(qr) DO NOTHING;
+	`, p.QR, p.UserID, p.Amount, p.Receipt)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to claim receipt: %w", err)
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted != 1 {
+		return 0, false, err
+	}
+
+	// A ticket number the user already has fails the payment instead of replacing the old ticket
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO loto (id_user, id_loto, qr, who_paid, receipt, fio, contact, address, dataPay, checks, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'));
+	`)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to prepare loto insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range p.Tickets {
+		if _, err := stmt.ExecContext(ctx,
+			e.UserID, e.LotoID, e.QR, e.WhoPaid,
+			e.Receipt, e.Fio, e.Contact, e.Address, e.DatePay, e.Checks,
+		); err != nil {
+			return 0, false, fmt.Errorf("failed to insert loto %d: %w", e.LotoID, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO money (id, sum, updated_at) VALUES (1, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET sum = sum + excluded.sum, updated_at = CURRENT_TIMESTAMP;
+	`, p.Amount); err != nil {
+		return 0, false, fmt.Errorf("failed to increase total sum: %w", err)
+	}
+
+	// A returning client keeps the contact and address they gave before
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO client (id_user, userName, contact, dataPay, checks, updated_at)
+		VALUES (?, ?, '', ?, FALSE, datetime('now'))
+		ON CONFLICT(id_user) DO UPDATE SET
+			userName = excluded.userName,
+			dataPay = excluded.dataPay,
+			updated_at = excluded.updated_at;
+	`, p.UserID, p.UserName, p.DatePay); err != nil {
+		return 0, false, fmt.Errorf("failed to save client: %w", err)
+	}
+
+	// The contact is asked for after the payment; SetPaymentContact fills it in
+	result, err = tx.ExecContext(ctx, `
+		INSERT INTO orders (id_user, userName, quantity, contact, dataPay, checks)
+		VALUES (?, ?, ?, '', ?, FALSE);
+	`, p.UserID, p.UserName, nullableQuantity(p.Quantity), p.DatePay)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to insert order: %w", err)
+	}
+	orderID, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read order id: %w", err)
+	}
+
+	// The quote is paid, the next receipt needs a new one
+	if _, err := tx.ExecContext(ctx, `DELETE FROM pending_payments WHERE id_user = ?;`, p.UserID); err != nil {
+		return 0, false, fmt.Errorf("failed to clear pending payment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("failed to commit payment transaction: %w", err)
+	}
+	return orderID, true, nil
+}
+
+// SetPaymentContact stores the phone a user shared after paying on their order and client
+// row. It returns sql.ErrNoRows if the user has no such order.
+func (r *ClientRepository) SetPaymentContact(ctx context.Context, userID, orderID int64, contact string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin contact transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE orders SET contact = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND id_user = ?;
+	`, contact, orderID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update order contact: %w", err)
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE client SET contact = ?, updated_at = datetime('now')
+		WHERE id_user = ?;
+	`, contact, userID); err != nil {
+		return fmt.Errorf("failed to update client contact: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// isDatabaseBusy reports whether err is SQLite refusing a write because of another writer
+func isDatabaseBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// InsertOrder сохраняет заказ из бота и возвращает его id
+func (r *ClientRepository) InsertOrder(ctx context.Context, order domain.OrderEntry) (int64, error) {
 	const q = `
 		INSERT INTO orders (id_user, userName, quantity, fio, contact, address, dateRegister, dataPay, checks)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
 	`
-	_, err := r.db.ExecContext(ctx, q,
+	res, err := r.db.ExecContext(ctx, q,
 		order.UserID,
 		order.UserName,
 		order.Quantity,
@@ -300,7 +1037,15 @@ func (r *ClientRepository) InsertOrder(ctx context.Context, order domain.OrderEn
 		order.DatePay,
 		order.Checks,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// nullableQuantity stores a quantity that isn't known, e.g. after the bot lost the count, as NULL
+func nullableQuantity(quantity int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(quantity), Valid: quantity > 0}
 }
 
 // IsClientUnique возвращает true, если в client нет записи с данным id_user
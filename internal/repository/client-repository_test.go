@@ -0,0 +1,453 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+)
+
+func TestClientRepositoryInsertJustKeepsRegistration(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewClientRepository(db)
+	ctx := context.Background()
+	const userID = 300
+
+	exists, err := repo.ExistsJust(ctx, userID)
+	if err != nil || exists {
+		t.Fatalf("ExistsJust before /start = %v, %v; want false", exists, err)
+	}
+
+	first := domain.JustEntry{UserId: userID, UserName: "aigerim", DateRegistered: "2025-01-01 10:00:00"}
+	if err := repo.InsertJust(ctx, first); err != nil {
+		t.Fatalf("InsertJust: %v", err)
+	}
+	// Backdate the row so a rewrite of created_at would show
+	if _, err := db.Exec(`UPDATE just SET created_at = '2025-01-01 10:00:00' WHERE id_user = ?`, userID); err != nil {
+		t.Fatalf("backdate: %v", err)
+	}
+
+	// The user sends /start again on another day
+	second := domain.JustEntry{UserId: userID, UserName: "aigerim_new", DateRegistered: "2025-02-15 18:30:00"}
+	if err := repo.InsertJust(ctx, second); err != nil {
+		t.Fatalf("InsertJust again: %v", err)
+	}
+
+	var rows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM just WHERE id_user = ?`, userID).Scan(&rows); err != nil {
+		t.Fatalf("count just: %v", err)
+	}
+	if rows != 1 {
+		t.Fatalf("got %d rows for the user, want 1", rows)
+	}
+
+	var registered string
+	var createdAt time.Time
+	if err := db.QueryRow(`SELECT dataRegistred, created_at FROM just WHERE id_user = ?`, userID).Scan(&registered, &createdAt); err != nil {
+		t.Fatalf("read just: %v", err)
+	}
+	if registered != first.DateRegistered {
+		t.Errorf("dataRegistred = %q, want the first registration %q", registered, first.DateRegistered)
+	}
+	if want := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC); !createdAt.Equal(want) {
+		t.Errorf("created_at = %v, want it unchanged at %v", createdAt, want)
+	}
+
+	exists, err = repo.ExistsJust(ctx, userID)
+	if err != nil || !exists {
+		t.Errorf("ExistsJust after /start = %v, %v; want true", exists, err)
+	}
+}
+
+func TestClientRepositoryGetByTelegramID(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewClientRepository(db)
+	ctx := context.Background()
+
+	client, err := repo.GetByTelegramID(ctx, 7101)
+	if client != nil || !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("GetByTelegramID for a missing client = %+v, %v; want nil, ErrClientNotFound", client, err)
+	}
+
+	saved := &domain.Client{TelegramID: 7101, FIO: "Айгерим", Contact: "+77011234567", Address: "Алматы"}
+	if err := repo.SaveOrUpdate(ctx, saved); err != nil {
+		t.Fatalf("SaveOrUpdate: %v", err)
+	}
+	saved.Address = "Астана"
+	if err := repo.SaveOrUpdate(ctx, saved); err != nil {
+		t.Fatalf("SaveOrUpdate existing: %v", err)
+	}
+
+	client, err = repo.GetByTelegramID(ctx, 7101)
+	if err != nil {
+		t.Fatalf("GetByTelegramID: %v", err)
+	}
+	if client.ID != saved.ID || client.FIO != "Айгерим" || client.Address != "Астана" {
+		t.Errorf("client = %+v, want the updated client %d", client, saved.ID)
+	}
+}
+
+func TestClientRepositoryGetByTelegramIDDatabaseError(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewClientRepository(db)
+
+	if _, err := db.Exec(`DROP TABLE clients`); err != nil {
+		t.Fatalf("drop clients: %v", err)
+	}
+
+	client, err := repo.GetByTelegramID(context.Background(), 7102)
+	if client != nil || err == nil || errors.Is(err, ErrClientNotFound) {
+		t.Errorf("GetByTelegramID = %+v, %v; want a database error, not ErrClientNotFound", client, err)
+	}
+	if err := repo.SaveOrUpdate(context.Background(), &domain.Client{TelegramID: 7102}); err == nil {
+		t.Error("SaveOrUpdate ignored the database error")
+	}
+}
+
+func TestRecordPaymentAccumulatesTotalSum(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewClientRepository(db)
+	ctx := context.Background()
+
+	total, err := repo.GetTotalSum(ctx)
+	if err != nil || total != 0 {
+		t.Fatalf("GetTotalSum of a fresh database = %d, %v; want 0", total, err)
+	}
+
+	pay := func(qr string, amount int, wantClaimed bool) {
+		t.Helper()
+		_, claimed, err := repo.RecordPayment(ctx, domain.PaymentEntry{
+			UserID: 310, UserName: "aigerim", Quantity: 1, Amount: amount, QR: qr, DatePay: "2026-03-01 10:00:00",
+		})
+		if err != nil || claimed != wantClaimed {
+			t.Fatalf("RecordPayment %s = %v, %v; want claimed %v", qr, claimed, err, wantClaimed)
+		}
+	}
+
+	pay("qr-1", 2499, true)
+	pay("qr-2", 4998, true)
+	pay("qr-1", 2499, false) // the same receipt again adds nothing
+
+	if total, err := repo.GetTotalSum(ctx); err != nil || total != 7497 {
+		t.Errorf("GetTotalSum = %d, %v; want 7497", total, err)
+	}
+
+	// A database that lost the seeded row gets it back on the next payment
+	if _, err := db.Exec(`DELETE FROM money`); err != nil {
+		t.Fatalf("delete money: %v", err)
+	}
+	pay("qr-3", 999, true)
+	if total, err := repo.GetTotalSum(ctx); err != nil || total != 999 {
+		t.Errorf("GetTotalSum after the row was lost = %d, %v; want 999", total, err)
+	}
+}
+
+func TestRecordPaymentCreditsSameQROnce(t *testing.T) {
+	db := newTestDB(t)
+	db.SetMaxOpenConns(4) // let the two payments really overlap
+	repo := NewClientRepository(db)
+	ctx := context.Background()
+
+	payment := func(userID int64) domain.PaymentEntry {
+		tickets := make([]domain.LotoEntry, 3)
+		for i := range tickets {
+			tickets[i] = domain.LotoEntry{UserID: userID, LotoID: int(userID)*10 + i, QR: "qr-race", Receipt: "receipt.pdf", DatePay: "2026-03-01 10:00:00"}
+		}
+		return domain.PaymentEntry{UserID: userID, UserName: "u", Quantity: 1, Amount: 2499, QR: "qr-race", Receipt: "receipt.pdf", DatePay: "2026-03-01 10:00:00", Tickets: tickets}
+	}
+
+	var wg sync.WaitGroup
+	claims := make([]bool, 2)
+	errs := make([]error, 2)
+	for i := range claims {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, claims[i], errs[i] = repo.RecordPayment(ctx, payment(int64(320+i)))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("RecordPayment %d: %v", i, err)
+		}
+	}
+	if claims[0] == claims[1] {
+		t.Fatalf("claims = %v, want exactly one payment credited", claims)
+	}
+
+	var receipts, tickets, orders int
+	for query, n := range map[string]*int{
+		`SELECT COUNT(*) FROM receipts WHERE qr = 'qr-race'`: &receipts,
+		`SELECT COUNT(*) FROM loto WHERE qr = 'qr-race'`:     &tickets,
+		`SELECT COUNT(*) FROM orders`:                        &orders,
+	} {
+		if err := db.QueryRow(query).Scan(n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	if receipts != 1 || tickets != 3 || orders != 1 {
+		t.Errorf("receipts = %d, tickets = %d, orders = %d; want one payment's worth", receipts, tickets, orders)
+	}
+	if total, err := repo.GetTotalSum(ctx); err != nil || total != 2499 {
+		t.Errorf("GetTotalSum = %d, %v; want 2499", total, err)
+	}
+}
+
+func TestRecordPaymentLeavesNothingWhenAStepFails(t *testing.T) {
+	ctx := context.Background()
+	payment := domain.PaymentEntry{
+		UserID: 330, UserName: "dana", Quantity: 2, Amount: 4998, QR: "qr-crash", Receipt: "receipt.pdf", DatePay: "2026-03-01 10:00:00",
+		Tickets: []domain.LotoEntry{
+			{UserID: 330, LotoID: 3301, QR: "qr-crash", Receipt: "receipt.pdf", DatePay: "2026-03-01 10:00:00"},
+			{UserID: 330, LotoID: 3302, QR: "qr-crash", Receipt: "receipt.pdf", DatePay: "2026-03-01 10:00:00"},
+		},
+	}
+
+	// Each trigger makes one step of the payment fail, as if the process died there
+	steps := map[string]string{
+		"tickets":         `CREATE TRIGGER crash BEFORE INSERT ON loto BEGIN SELECT RAISE(ABORT, 'crash'); END`,
+		"money total":     `CREATE TRIGGER crash BEFORE UPDATE ON money BEGIN SELECT RAISE(ABORT, 'crash'); END`,
+		"client":          `CREATE TRIGGER crash BEFORE INSERT ON client BEGIN SELECT RAISE(ABORT, 'crash'); END`,
+		"order":           `CREATE TRIGGER crash BEFORE INSERT ON orders BEGIN SELECT RAISE(ABORT, 'crash'); END`,
+		"pending payment": `CREATE TRIGGER crash BEFORE DELETE ON pending_payments BEGIN SELECT RAISE(ABORT, 'crash'); END`,
+	}
+	for step, trigger := range steps {
+		t.Run(step, func(t *testing.T) {
+			db := newTestDB(t)
+			repo := NewClientRepository(db)
+			if err := repo.SavePendingPayment(ctx, domain.PendingPayment{UserID: 330, Quantity: 2, Amount: 4998}); err != nil {
+				t.Fatalf("save pending payment: %v", err)
+			}
+			if _, err := db.Exec(`INSERT INTO money (id, sum) VALUES (1, 0) ON CONFLICT(id) DO NOTHING`); err != nil {
+				t.Fatalf("seed money: %v", err)
+			}
+			if _, err := db.Exec(trigger); err != nil {
+				t.Fatalf("create trigger: %v", err)
+			}
+
+			if _, claimed, err := repo.RecordPayment(ctx, payment); err == nil || claimed {
+				t.Fatalf("RecordPayment = %v, %v; want the failure", claimed, err)
+			}
+			for query, want := range map[string]int{
+				`SELECT COUNT(*) FROM receipts`:           0,
+				`SELECT COUNT(*) FROM loto`:               0,
+				`SELECT COUNT(*) FROM client`:             0,
+				`SELECT COUNT(*) FROM orders`:             0,
+				`SELECT COUNT(*) FROM pending_payments`:   1,
+				`SELECT COALESCE(SUM(sum), 0) FROM money`: 0,
+			} {
+				var got int
+				if err := db.QueryRow(query).Scan(&got); err != nil {
+					t.Fatalf("%s: %v", query, err)
+				}
+				if got != want {
+					t.Errorf("%s = %d, want %d", query, got, want)
+				}
+			}
+
+			// The receipt wasn't used up, so sending it again pays it out
+			if _, err := db.Exec(`DROP TRIGGER crash`); err != nil {
+				t.Fatalf("drop trigger: %v", err)
+			}
+			orderID, claimed, err := repo.RecordPayment(ctx, payment)
+			if err != nil || !claimed || orderID == 0 {
+				t.Fatalf("RecordPayment after the failure = %d, %v, %v; want the payment recorded", orderID, claimed, err)
+			}
+			if total, err := repo.GetTotalSum(ctx); err != nil || total != 4998 {
+				t.Errorf("GetTotalSum = %d, %v; want 4998", total, err)
+			}
+		})
+	}
+}
+
+func TestTopUpLotoIssuesOnlyMissingTickets(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewClientRepository(db)
+	ctx := context.Background()
+
+	for _, ticket := range []struct {
+		user   int64
+		lotoID int
+		qr     string
+	}{
+		{510, 100001, "qr-1"},
+		{510, 100002, "qr-1"},
+		{510, 100003, "qr-other"}, // another payment of the same user
+		{511, 100004, "qr-1"},     // another user
+	} {
+		if _, err := db.Exec(`INSERT INTO loto (id_user, id_loto, qr, receipt, dataPay) VALUES (?, ?, ?, '', '')`, ticket.user, ticket.lotoID, ticket.qr); err != nil {
+			t.Fatalf("insert loto: %v", err)
+		}
+	}
+
+	if count, err := repo.CountLotoByUserAndReceipt(ctx, 510, "qr-1"); err != nil || count != 2 {
+		t.Fatalf("CountLotoByUserAndReceipt = %d, %v; want 2", count, err)
+	}
+
+	// The first draw repeats numbers the user already has, which are drawn again
+	draws := [][]int{{100001, 200001, 100003}, {200002, 200003}}
+	draw := func(n int) []int {
+		next := draws[0]
+		draws = draws[1:]
+		return next[:min(n, len(next))]
+	}
+	issued, err := repo.TopUpLoto(ctx, 510, "qr-1", "2026-03-01 10:00:00", 5, draw)
+	if err != nil {
+		t.Fatalf("TopUpLoto: %v", err)
+	}
+	if !slices.Equal(issued, []int{200001, 200002, 200003}) {
+		t.Errorf("issued = %v, want the three new numbers", issued)
+	}
+	if count, err := repo.CountLotoByUserAndReceipt(ctx, 510, "qr-1"); err != nil || count != 5 {
+		t.Errorf("CountLotoByUserAndReceipt after the top-up = %d, %v; want 5", count, err)
+	}
+
+	if issued, err := repo.TopUpLoto(ctx, 510, "qr-1", "2026-03-01 10:00:00", 5, draw); err != nil || len(issued) != 0 {
+		t.Errorf("second TopUpLoto = %v, %v; want nothing issued", issued, err)
+	}
+}
+
+func TestLotoTicketsReadBack(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewClientRepository(db)
+	ctx := context.Background()
+
+	// A ticket with every column set, one from before qr, receipt and the delivery details
+	// were stored, and another user's ticket
+	_, err := db.Exec(`
+		INSERT INTO loto (id_user, id_loto, qr, who_paid, receipt, fio, contact, address, dataPay, checks, updated_at)
+		VALUES (500, 42, 'qr-1', 'Айгерим', 'files/receipt.pdf', 'Айгерим Н.', '+77011234567', 'Алматы', '2026-03-01 10:00:00', TRUE, '2026-03-02 09:00:00');
+		INSERT INTO loto (id_user, id_loto, qr, who_paid, receipt, fio, contact, address, dataPay, checks, created_at, updated_at)
+		VALUES (500, 7, NULL, NULL, NULL, NULL, NULL, NULL, '2026-02-01 10:00:00', NULL, '2026-02-01 10:00:05', NULL);
+		INSERT INTO loto (id_user, id_loto, qr, dataPay) VALUES (501, 13, 'qr-2', '2026-03-05 12:00:00');
+	`)
+	if err != nil {
+		t.Fatalf("insert tickets: %v", err)
+	}
+
+	full := domain.LotoEntry{
+		UserID:    500,
+		LotoID:    42,
+		QR:        "qr-1",
+		WhoPaid:   sql.NullString{String: "Айгерим", Valid: true},
+		Receipt:   "files/receipt.pdf",
+		Fio:       sql.NullString{String: "Айгерим Н.", Valid: true},
+		Contact:   sql.NullString{String: "+77011234567", Valid: true},
+		Address:   sql.NullString{String: "Алматы", Valid: true},
+		DatePay:   "2026-03-01 10:00:00",
+		UpdatedAt: "2026-03-02 09:00:00",
+		Checks:    true,
+	}
+	bare := domain.LotoEntry{UserID: 500, LotoID: 7, DatePay: "2026-02-01 10:00:00", UpdatedAt: "2026-02-01 10:00:05"}
+
+	tickets, err := repo.GetLotoByUser(ctx, 500)
+	if err != nil {
+		t.Fatalf("get user tickets: %v", err)
+	}
+	if want := []domain.LotoEntry{full, bare}; !reflect.DeepEqual(tickets, want) {
+		t.Errorf("user tickets = %+v\nwant %+v", tickets, want)
+	}
+
+	if tickets, err := repo.GetLotoByUser(ctx, 502); err != nil || tickets == nil || len(tickets) != 0 {
+		t.Errorf("tickets of a user without any = %+v, %v; want an empty list", tickets, err)
+	}
+
+	all, err := repo.GetAllLoto(ctx)
+	if err != nil {
+		t.Fatalf("get all tickets: %v", err)
+	}
+	var numbers []int
+	for _, ticket := range all {
+		numbers = append(numbers, ticket.LotoID)
+	}
+	if !slices.Equal(numbers, []int{7, 13, 42}) {
+		t.Errorf("all tickets = %v, want every ticket by number", numbers)
+	}
+}
+
+// insertLotoTickets issues the numbers to their own users, each drawn when checked says so
+func insertLotoTickets(t *testing.T, db *sql.DB, checked map[int]bool, numbers ...int) {
+	t.Helper()
+
+	for _, number := range numbers {
+		_, err := db.Exec(`INSERT INTO loto (id_user, id_loto, qr, dataPay, checks) VALUES (?, ?, ?, '2026-03-01 10:00:00', ?)`,
+			600+number, number, fmt.Sprintf("qr-%d", number), checked[number])
+		if err != nil {
+			t.Fatalf("insert ticket %d: %v", number, err)
+		}
+	}
+}
+
+func TestDrawRandomLotoWinnerSkipsDrawnTickets(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewClientRepository(db)
+	ctx := context.Background()
+
+	insertLotoTickets(t, db, map[int]bool{2: true, 4: true}, 1, 2, 3, 4, 5)
+
+	var drawn []int
+	for range 3 {
+		winner, err := repo.DrawRandomLotoWinner(ctx)
+		if err != nil {
+			t.Fatalf("draw: %v", err)
+		}
+		if !winner.Checks || winner.UserID != int64(600+winner.LotoID) || winner.QR != fmt.Sprintf("qr-%d", winner.LotoID) {
+			t.Errorf("winner = %+v, want the drawn ticket as stored", winner)
+		}
+		drawn = append(drawn, winner.LotoID)
+	}
+	slices.Sort(drawn)
+	if !slices.Equal(drawn, []int{1, 3, 5}) {
+		t.Errorf("drawn = %v, want each undrawn ticket once", drawn)
+	}
+
+	if winner, err := repo.DrawRandomLotoWinner(ctx); !errors.Is(err, ErrNoLotoTickets) {
+		t.Errorf("draw with every ticket drawn = %+v, %v; want ErrNoLotoTickets", winner, err)
+	}
+	if count, err := repo.CountUndrawnLoto(ctx); err != nil || count != 0 {
+		t.Errorf("undrawn tickets = %d, %v; want 0", count, err)
+	}
+}
+
+func TestDrawRandomLotoWinnerIsUniform(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewClientRepository(db)
+	ctx := context.Background()
+
+	numbers := []int{1, 2, 3, 4}
+	insertLotoTickets(t, db, map[int]bool{5: true}, append(numbers, 5)...)
+
+	const draws = 2000
+	wins := make(map[int]int)
+	for range draws {
+		winner, err := repo.DrawRandomLotoWinner(ctx)
+		if err != nil {
+			t.Fatalf("draw: %v", err)
+		}
+		wins[winner.LotoID]++
+		if _, err := db.Exec(`UPDATE loto SET checks = FALSE WHERE id_loto = ?`, winner.LotoID); err != nil {
+			t.Fatalf("put ticket back: %v", err)
+		}
+	}
+
+	// 500 wins each are expected with a standard deviation of about 19; 100 either way
+	// would be a five sigma fluke
+	want := draws / len(numbers)
+	for _, number := range numbers {
+		if got := wins[number]; got < want-100 || got > want+100 {
+			t.Errorf("ticket %d won %d of %d draws, want about %d", number, got, draws, want)
+		}
+	}
+	if wins[5] != 0 {
+		t.Errorf("drawn ticket won %d times, want never", wins[5])
+	}
+}
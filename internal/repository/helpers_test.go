@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"parfum/traits/database"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestDB opens a fresh database file with the full schema and every migration applied
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	settings := database.SQLiteSettings{JournalMode: "WAL", BusyTimeout: 5 * time.Second, MaxOpenConns: 1}
+	db, err := sql.Open("sqlite3", database.DSN(filepath.Join(t.TempDir(), "test.db"), settings))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.Configure(db, settings); err != nil {
+		t.Fatalf("configure database: %v", err)
+	}
+	if err := database.CreateTables(db); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	if err := database.MigrateDatabase(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"parfum/internal/domain"
+	"time"
+)
+
+// IdempotencyKeyTTL is how long a stored response is replayed for its key
+const IdempotencyKeyTTL = 24 * time.Hour
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Claim reserves (key, endpoint, telegramID) for the caller and returns true. If the
+// key is already taken it returns the existing record instead: in progress while the
+// first request runs, with its response once that request is done. Records older than
+// IdempotencyKeyTTL no longer count.
+func (r *IdempotencyRepository) Claim(ctx context.Context, key, endpoint string, telegramID int64) (*domain.IdempotencyRecord, bool, error) {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE idempotency_key = ? AND endpoint = ? AND telegram_id = ?
+		  AND created_at < datetime('now', ?)
+	`, key, endpoint, telegramID, ttlModifier(IdempotencyKeyTTL))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to expire idempotency key: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO idempotency_keys (idempotency_key, endpoint, telegram_id, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, key, endpoint, telegramID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if claimed == 1 {
+		return nil, true, nil
+	}
+
+	record := domain.IdempotencyRecord{Key: key, Endpoint: endpoint, TelegramID: telegramID}
+	var response string
+	var resourceID sql.NullInt64
+	err = r.db.QueryRowContext(ctx, `
+		SELECT status_code, response, resource_id, created_at
+		FROM idempotency_keys
+		WHERE idempotency_key = ? AND endpoint = ? AND telegram_id = ?
+	`, key, endpoint, telegramID).Scan(&record.StatusCode, &response, &resourceID, &record.CreatedAt)
+	if err == sql.ErrNoRows {
+		// Released between the two statements; the caller may claim again
+		return &domain.IdempotencyRecord{Key: key, Endpoint: endpoint, TelegramID: telegramID}, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	record.Response = []byte(response)
+	record.ResourceID = resourceID.Int64
+	return &record, false, nil
+}
+
+// Complete stores the response of a claimed key so repeats replay it
+func (r *IdempotencyRepository) Complete(ctx context.Context, key, endpoint string, telegramID int64, statusCode int, response []byte, resourceID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = ?, response = ?, resource_id = ?
+		WHERE idempotency_key = ? AND endpoint = ? AND telegram_id = ?
+	`, statusCode, string(response), sql.NullInt64{Int64: resourceID, Valid: resourceID != 0}, key, endpoint, telegramID)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release frees a claimed key whose request failed, so it can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, key, endpoint string, telegramID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE idempotency_key = ? AND endpoint = ? AND telegram_id = ?
+	`, key, endpoint, telegramID)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes keys older than IdempotencyKeyTTL and returns how many were removed
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < datetime('now', ?)`, ttlModifier(IdempotencyKeyTTL))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ttlModifier turns a TTL into a SQLite datetime modifier, e.g. "-86400 seconds"
+func ttlModifier(ttl time.Duration) string {
+	return fmt.Sprintf("-%d seconds", int64(ttl.Seconds()))
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIdempotencyRepositoryClaimCompleteRelease(t *testing.T) {
+	repo := NewIdempotencyRepository(newTestDB(t))
+	ctx := context.Background()
+
+	if _, claimed, err := repo.Claim(ctx, "key", "order_place", 7201); err != nil || !claimed {
+		t.Fatalf("first Claim = %v, %v; want claimed", claimed, err)
+	}
+	record, claimed, err := repo.Claim(ctx, "key", "order_place", 7201)
+	if err != nil || claimed || !record.InProgress() {
+		t.Fatalf("Claim while running = %+v, %v, %v; want in progress", record, claimed, err)
+	}
+
+	// The same key is separate per endpoint and per user
+	for _, other := range []struct {
+		endpoint string
+		user     int64
+	}{{"prize_complete", 7201}, {"order_place", 7202}} {
+		if _, claimed, err := repo.Claim(ctx, "key", other.endpoint, other.user); err != nil || !claimed {
+			t.Errorf("Claim for %s/%d = %v, %v; want claimed", other.endpoint, other.user, claimed, err)
+		}
+	}
+
+	if err := repo.Complete(ctx, "key", "order_place", 7201, 200, []byte(`{"order_id":5}`), 5); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	record, claimed, err = repo.Claim(ctx, "key", "order_place", 7201)
+	if err != nil || claimed || record.InProgress() || record.StatusCode != 200 || string(record.Response) != `{"order_id":5}` || record.ResourceID != 5 {
+		t.Fatalf("Claim after Complete = %+v, %v, %v; want the stored response", record, claimed, err)
+	}
+
+	if err := repo.Release(ctx, "key", "prize_complete", 7201); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, claimed, err := repo.Claim(ctx, "key", "prize_complete", 7201); err != nil || !claimed {
+		t.Errorf("Claim after Release = %v, %v; want claimed again", claimed, err)
+	}
+}
+
+func TestIdempotencyRepositoryDeleteExpired(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewIdempotencyRepository(db)
+	ctx := context.Background()
+
+	for _, key := range []string{"old", "new"} {
+		if _, claimed, err := repo.Claim(ctx, key, "order_place", 7203); err != nil || !claimed {
+			t.Fatalf("Claim %s = %v, %v", key, claimed, err)
+		}
+	}
+	if _, err := db.Exec(`UPDATE idempotency_keys SET created_at = datetime('now', '-25 hours') WHERE idempotency_key = 'old'`); err != nil {
+		t.Fatalf("age key: %v", err)
+	}
+
+	deleted, err := repo.DeleteExpired(ctx)
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpired = %d, %v; want 1", deleted, err)
+	}
+	if _, claimed, err := repo.Claim(ctx, "new", "order_place", 7203); err != nil || claimed {
+		t.Errorf("Claim of a live key = %v, %v; want it still taken", claimed, err)
+	}
+}
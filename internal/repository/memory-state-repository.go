@@ -0,0 +1,208 @@
+// internal/repository/memory-state-repository.go
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"parfum/internal/domain"
+	"parfum/traits/cache"
+)
+
+// memoryStateTTL is how long a user state is kept, the same as its Redis key
+const memoryStateTTL = 24 * time.Hour
+
+// memoryBroadcastStateTTL is how long a broadcast draft is kept, the same as its Redis key
+const memoryBroadcastStateTTL = time.Hour
+
+// MemoryStateRepository keeps user states, carts, pending receipts, broadcast drafts,
+// handled update IDs, spin limits and receipt failure counters in process memory with the same expiry as their Redis keys. It stands in for Redis while
+// Redis is down; everything in it is lost on restart.
+type MemoryStateRepository struct {
+	states     *cache.TTLCache[int64, domain.UserState]
+	carts      *cache.TTLCache[int64, []domain.CartItem]
+	receipts   *cache.TTLCache[int64, domain.PendingReceipt]
+	broadcasts *cache.TTLCache[int64, string]
+	updates    *cache.TTLCache[int64, struct{}]
+	cooldowns  *cache.TTLCache[int64, time.Time] // when each running spin cooldown ends
+
+	// spinsMu makes reading and bumping a daily spin counter one step
+	spinsMu sync.Mutex
+	spins   *cache.TTLCache[string, int]
+
+	// failuresMu makes bumping the receipt failure counters and the recent paths one step
+	failuresMu     sync.Mutex
+	failures       *cache.TTLCache[string, int]
+	recentFailures []string
+	alerts         *cache.TTLCache[string, struct{}]
+}
+
+// NewMemoryStateRepository creates the store; expired entries are swept until ctx is done
+func NewMemoryStateRepository(ctx context.Context) *MemoryStateRepository {
+	return &MemoryStateRepository{
+		states:     cache.NewTTLCache[int64, domain.UserState](ctx, time.Minute),
+		carts:      cache.NewTTLCache[int64, []domain.CartItem](ctx, time.Minute),
+		receipts:   cache.NewTTLCache[int64, domain.PendingReceipt](ctx, time.Minute),
+		broadcasts: cache.NewTTLCache[int64, string](ctx, time.Minute),
+		updates:    cache.NewTTLCache[int64, struct{}](ctx, time.Minute),
+		cooldowns:  cache.NewTTLCache[int64, time.Time](ctx, time.Minute),
+		spins:      cache.NewTTLCache[string, int](ctx, time.Minute),
+		failures:   cache.NewTTLCache[string, int](ctx, time.Minute),
+		alerts:     cache.NewTTLCache[string, struct{}](ctx, time.Minute),
+	}
+}
+
+// Values are copied in and out, so callers can't change a stored state without saving
+// it, just like with Redis
+
+func (r *MemoryStateRepository) SaveUserState(ctx context.Context, userID int64, state *domain.UserState) error {
+	r.states.Set(userID, copyUserState(*state), memoryStateTTL)
+	return nil
+}
+
+func (r *MemoryStateRepository) GetUserState(ctx context.Context, userID int64) (*domain.UserState, error) {
+	state, ok := r.states.Get(userID)
+	if !ok {
+		return nil, nil
+	}
+	copied := copyUserState(state)
+	return &copied, nil
+}
+
+func (r *MemoryStateRepository) DeleteUserState(ctx context.Context, userID int64) error {
+	r.states.Delete(userID)
+	return nil
+}
+
+func (r *MemoryStateRepository) SaveCart(ctx context.Context, userID int64, items []domain.CartItem, ttl time.Duration) error {
+	r.carts.Set(userID, slices.Clone(items), ttl)
+	return nil
+}
+
+func (r *MemoryStateRepository) GetCart(ctx context.Context, userID int64) ([]domain.CartItem, error) {
+	items, ok := r.carts.Get(userID)
+	if !ok {
+		return nil, nil
+	}
+	return slices.Clone(items), nil
+}
+
+func (r *MemoryStateRepository) ClearCart(ctx context.Context, userID int64) error {
+	r.carts.Delete(userID)
+	return nil
+}
+
+func (r *MemoryStateRepository) SavePendingReceipt(ctx context.Context, userID int64, receipt *domain.PendingReceipt, ttl time.Duration) error {
+	r.receipts.Set(userID, *receipt, ttl)
+	return nil
+}
+
+func (r *MemoryStateRepository) GetPendingReceipt(ctx context.Context, userID int64) (*domain.PendingReceipt, error) {
+	receipt, ok := r.receipts.Get(userID)
+	if !ok {
+		return nil, nil
+	}
+	return &receipt, nil
+}
+
+func (r *MemoryStateRepository) DeletePendingReceipt(ctx context.Context, userID int64) error {
+	r.receipts.Delete(userID)
+	return nil
+}
+
+func (r *MemoryStateRepository) SaveBroadcastState(ctx context.Context, adminID int64, broadcastType string) error {
+	r.broadcasts.Set(adminID, broadcastType, memoryBroadcastStateTTL)
+	return nil
+}
+
+func (r *MemoryStateRepository) GetBroadcastState(ctx context.Context, adminID int64) (string, error) {
+	broadcastType, _ := r.broadcasts.Get(adminID)
+	return broadcastType, nil
+}
+
+func (r *MemoryStateRepository) DeleteBroadcastState(ctx context.Context, adminID int64) error {
+	r.broadcasts.Delete(adminID)
+	return nil
+}
+
+func (r *MemoryStateRepository) MarkUpdateSeen(ctx context.Context, updateID int64) (bool, error) {
+	return r.updates.SetIfAbsent(updateID, struct{}{}, processedUpdateTTL), nil
+}
+
+func (r *MemoryStateRepository) StartSpinCooldown(ctx context.Context, userID int64, cooldown time.Duration) (time.Duration, error) {
+	if r.cooldowns.SetIfAbsent(userID, time.Now().Add(cooldown), cooldown) {
+		return 0, nil
+	}
+	return r.GetSpinCooldown(ctx, userID)
+}
+
+func (r *MemoryStateRepository) GetSpinCooldown(ctx context.Context, userID int64) (time.Duration, error) {
+	ends, ok := r.cooldowns.Get(userID)
+	if !ok {
+		return 0, nil
+	}
+	return max(time.Until(ends), 0), nil
+}
+
+func (r *MemoryStateRepository) GetDailySpins(ctx context.Context, userID int64, day string) (int, error) {
+	spins, _ := r.spins.Get(dailySpinsKey(userID, day))
+	return spins, nil
+}
+
+func (r *MemoryStateRepository) IncrDailySpins(ctx context.Context, userID int64, day string) (int, error) {
+	key := dailySpinsKey(userID, day)
+
+	r.spinsMu.Lock()
+	defer r.spinsMu.Unlock()
+	spins, _ := r.spins.Get(key)
+	spins++
+	r.spins.Set(key, spins, dailySpinsTTL)
+	return spins, nil
+}
+
+func (r *MemoryStateRepository) CountReceiptFailure(ctx context.Context, userID int64, hour, receiptPath string) (int, int, error) {
+	userKey := fmt.Sprintf("user:%d:%s", userID, hour)
+	globalKey := "global:" + hour
+
+	r.failuresMu.Lock()
+	defer r.failuresMu.Unlock()
+	user, _ := r.failures.Get(userKey)
+	user++
+	r.failures.Set(userKey, user, receiptFailuresTTL)
+	global, _ := r.failures.Get(globalKey)
+	global++
+	r.failures.Set(globalKey, global, receiptFailuresTTL)
+
+	if receiptPath != "" {
+		r.recentFailures = append([]string{receiptPath}, r.recentFailures...)
+		if len(r.recentFailures) > recentReceiptFailures {
+			r.recentFailures = r.recentFailures[:recentReceiptFailures]
+		}
+	}
+	return user, global, nil
+}
+
+func (r *MemoryStateRepository) GetRecentReceiptFailures(ctx context.Context) ([]string, error) {
+	r.failuresMu.Lock()
+	defer r.failuresMu.Unlock()
+	return slices.Clone(r.recentFailures), nil
+}
+
+func (r *MemoryStateRepository) StartAlertCooldown(ctx context.Context, alert string, cooldown time.Duration) (bool, error) {
+	return r.alerts.SetIfAbsent(alert, struct{}{}, cooldown), nil
+}
+
+func dailySpinsKey(userID int64, day string) string {
+	return fmt.Sprintf("%d:%s", userID, day)
+}
+
+func copyUserState(state domain.UserState) domain.UserState {
+	if state.Receipt != nil {
+		receipt := *state.Receipt
+		state.Receipt = &receipt
+	}
+	return state
+}
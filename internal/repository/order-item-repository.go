@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"parfum/internal/domain"
+)
+
+type OrderItemRepository struct {
+	db *sql.DB
+}
+
+func NewOrderItemRepository(db *sql.DB) *OrderItemRepository {
+	return &OrderItemRepository{db: db}
+}
+
+// Create inserts a single order item
+func (r *OrderItemRepository) Create(ctx context.Context, item *domain.OrderItem) error {
+	query := `
+		INSERT INTO order_items (order_id, parfume_id, name, quantity, price, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, item.OrderID, nullableString(item.ParfumeID), item.Name, item.Quantity, item.Price)
+	if err != nil {
+		return fmt.Errorf("failed to create order item: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get order item id: %w", err)
+	}
+
+	item.ID = id
+	return nil
+}
+
+// GetByID retrieves an order item by ID
+func (r *OrderItemRepository) GetByID(ctx context.Context, id int64) (*domain.OrderItem, error) {
+	query := `
+		SELECT id, order_id, parfume_id, name, quantity, price, created_at, updated_at
+		FROM order_items
+		WHERE id = ?
+	`
+
+	item, err := scanOrderItem(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetByOrder retrieves all items of an order
+func (r *OrderItemRepository) GetByOrder(ctx context.Context, orderID int64) ([]domain.OrderItem, error) {
+	return queryOrderItems(ctx, r.db, orderID)
+}
+
+// queryer runs read queries on a *sql.DB or within a *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryOrderItems reads the items of an order, oldest first
+func queryOrderItems(ctx context.Context, q queryer, orderID int64) ([]domain.OrderItem, error) {
+	query := `
+		SELECT id, order_id, parfume_id, name, quantity, price, created_at, updated_at
+		FROM order_items
+		WHERE order_id = ?
+		ORDER BY id ASC
+	`
+
+	rows, err := q.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []domain.OrderItem
+	for rows.Next() {
+		item, err := scanOrderItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return items, nil
+}
+
+// Update updates quantity and snapshots of an order item
+func (r *OrderItemRepository) Update(ctx context.Context, item *domain.OrderItem) error {
+	query := `
+		UPDATE order_items
+		SET parfume_id = ?, name = ?, quantity = ?, price = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	result, err := r.db.ExecContext(ctx, query, nullableString(item.ParfumeID), item.Name, item.Quantity, item.Price, item.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update order item: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("no order item found with id %d", item.ID)
+	}
+
+	return nil
+}
+
+// Delete removes an order item by ID
+func (r *OrderItemRepository) Delete(ctx context.Context, id int64) error {
+	query := "DELETE FROM order_items WHERE id = ?"
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// DeleteByOrder removes all items of an order
+func (r *OrderItemRepository) DeleteByOrder(ctx context.Context, orderID int64) error {
+	query := "DELETE FROM order_items WHERE order_id = ?"
+	_, err := r.db.ExecContext(ctx, query, orderID)
+	return err
+}
+
+// AddItems appends items to an order in one transaction
+func (r *OrderItemRepository) AddItems(ctx context.Context, orderID int64, items []domain.OrderItem) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertOrderItems(ctx, tx, orderID, items); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order items: %w", err)
+	}
+	return nil
+}
+
+// insertOrderItems appends items to an order within tx
+func insertOrderItems(ctx context.Context, tx *sql.Tx, orderID int64, items []domain.OrderItem) error {
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO order_items (order_id, parfume_id, name, quantity, price, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare order item insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, item := range items {
+		if _, err := stmt.ExecContext(ctx, orderID, nullableString(item.ParfumeID), item.Name, item.Quantity, item.Price); err != nil {
+			return fmt.Errorf("failed to insert order item %q: %w", item.Name, err)
+		}
+	}
+	return nil
+}
+
+// SumQuantityByOrder returns the total selected quantity of an order
+func (r *OrderItemRepository) SumQuantityByOrder(ctx context.Context, orderID int64) (int, error) {
+	var total int
+	query := "SELECT COALESCE(SUM(quantity), 0) FROM order_items WHERE order_id = ?"
+	err := r.db.QueryRowContext(ctx, query, orderID).Scan(&total)
+	return total, err
+}
+
+// GetTopPerfumes returns the perfumes with the largest selected quantity across all orders.
+// Items without a perfume ID (legacy selections) are grouped by name.
+func (r *OrderItemRepository) GetTopPerfumes(ctx context.Context, limit int) ([]domain.PerfumeSales, error) {
+	query := `
+		SELECT COALESCE(parfume_id, ''), MAX(name), SUM(quantity) AS total
+		FROM order_items
+		GROUP BY COALESCE(parfume_id, name)
+		ORDER BY total DESC, MAX(name)
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top perfumes: %w", err)
+	}
+	defer rows.Close()
+
+	var top []domain.PerfumeSales
+	for rows.Next() {
+		var sales domain.PerfumeSales
+		if err := rows.Scan(&sales.ParfumeID, &sales.Name, &sales.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan top perfume: %w", err)
+		}
+		top = append(top, sales)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return top, nil
+}
+
+// AddOrderItems is AddItems under the name the order_items API was specified with
+func (r *OrderItemRepository) AddOrderItems(ctx context.Context, orderID int64, items []domain.OrderItem) error {
+	return r.AddItems(ctx, orderID, items)
+}
+
+// GetOrderItems is GetByOrder under the name the order_items API was specified with
+func (r *OrderItemRepository) GetOrderItems(ctx context.Context, orderID int64) ([]domain.OrderItem, error) {
+	return r.GetByOrder(ctx, orderID)
+}
+
+// SumOrderItemsQuantity is SumQuantityByOrder under the name the order_items API was
+// specified with
+func (r *OrderItemRepository) SumOrderItemsQuantity(ctx context.Context, orderID int64) (int, error) {
+	return r.SumQuantityByOrder(ctx, orderID)
+}
+
+// scanOrderItem reads one order_items row selected with the standard column list
+func scanOrderItem(row interface{ Scan(...interface{}) error }) (domain.OrderItem, error) {
+	var item domain.OrderItem
+	var parfumeID sql.NullString
+
+	err := row.Scan(
+		&item.ID,
+		&item.OrderID,
+		&parfumeID,
+		&item.Name,
+		&item.Quantity,
+		&item.Price,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+	if err != nil {
+		return item, err
+	}
+
+	if parfumeID.Valid {
+		item.ParfumeID = parfumeID.String
+	}
+	return item, nil
+}
+
+// orderItemStock sums item quantities per perfume ID, skipping items without a perfume
+func orderItemStock(items []domain.OrderItem) map[string]int {
+	quantities := make(map[string]int)
+	for _, item := range items {
+		if item.ParfumeID != "" {
+			quantities[item.ParfumeID] += item.Quantity
+		}
+	}
+	return quantities
+}
+
+// nullableString stores empty strings as NULL
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
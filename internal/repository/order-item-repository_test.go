@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"parfum/internal/domain"
+)
+
+func TestOrderItemRepositoryAddAndRead(t *testing.T) {
+	db := newTestDB(t)
+	orders := NewOrderRepository(db)
+	items := NewOrderItemRepository(db)
+	ctx := context.Background()
+
+	perfume := createTestPerfume(t, NewParfumeRepository(db), "Baccarat Rouge", 2499)
+	order := &domain.Order{IDUser: 8001, UserName: "buyer", Contact: "+77010000000", DataPay: "2026-03-01 10:00:00"}
+	if err := orders.Create(ctx, order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	other := &domain.Order{IDUser: 8002, UserName: "other", Contact: "+77010000001", DataPay: "2026-03-01 10:00:00"}
+	if err := orders.Create(ctx, other); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	err := items.AddItems(ctx, order.ID, []domain.OrderItem{
+		{ParfumeID: perfume.Id, Name: perfume.NameParfume, Quantity: 2, Price: perfume.Price},
+		{Name: "Legacy Scent", Quantity: 3},
+	})
+	if err != nil {
+		t.Fatalf("AddItems: %v", err)
+	}
+	if err := items.AddItems(ctx, other.ID, []domain.OrderItem{{Name: "Other Scent", Quantity: 5}}); err != nil {
+		t.Fatalf("AddItems: %v", err)
+	}
+
+	got, err := items.GetByOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("GetByOrder: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("items = %+v, want 2", got)
+	}
+	if got[0].OrderID != order.ID || got[0].ParfumeID != perfume.Id || got[0].Name != "Baccarat Rouge" || got[0].Quantity != 2 || got[0].Price != 2499 {
+		t.Errorf("first item = %+v, want two Baccarat Rouge", got[0])
+	}
+	if got[1].ParfumeID != "" || got[1].Name != "Legacy Scent" || got[1].Quantity != 3 {
+		t.Errorf("second item = %+v, want three of the legacy scent without an ID", got[1])
+	}
+
+	var parfumeID *string
+	if err := db.QueryRow(`SELECT parfume_id FROM order_items WHERE id = ?`, got[1].ID).Scan(&parfumeID); err != nil || parfumeID != nil {
+		t.Errorf("stored parfume_id = %v, %v; want NULL", parfumeID, err)
+	}
+
+	byID, err := items.GetByID(ctx, got[0].ID)
+	if err != nil || byID.Name != got[0].Name {
+		t.Errorf("GetByID = %+v, %v; want %+v", byID, err, got[0])
+	}
+}
+
+func TestOrderItemRepositorySumQuantity(t *testing.T) {
+	db := newTestDB(t)
+	orders := NewOrderRepository(db)
+	items := NewOrderItemRepository(db)
+	ctx := context.Background()
+
+	order := &domain.Order{IDUser: 8011, UserName: "buyer", Contact: "+77010000000", DataPay: "2026-03-01 10:00:00"}
+	if err := orders.Create(ctx, order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	total, err := items.SumQuantityByOrder(ctx, order.ID)
+	if err != nil || total != 0 {
+		t.Errorf("sum without items = %d, %v; want 0", total, err)
+	}
+
+	err = items.AddItems(ctx, order.ID, []domain.OrderItem{
+		{Name: "Baccarat Rouge", Quantity: 2},
+		{Name: "Lost Cherry", Quantity: 1},
+		{Name: "Oud Wood", Quantity: 4},
+	})
+	if err != nil {
+		t.Fatalf("AddItems: %v", err)
+	}
+	if total, err := items.SumQuantityByOrder(ctx, order.ID); err != nil || total != 7 {
+		t.Errorf("sum = %d, %v; want 7", total, err)
+	}
+
+	// A zero quantity violates the table's check and leaves the batch out
+	if err := items.AddItems(ctx, order.ID, []domain.OrderItem{{Name: "Extra", Quantity: 1}, {Name: "Nothing", Quantity: 0}}); err == nil {
+		t.Error("AddItems accepted a zero quantity")
+	}
+	if total, err := items.SumQuantityByOrder(ctx, order.ID); err != nil || total != 7 {
+		t.Errorf("sum after a failed batch = %d, %v; want 7", total, err)
+	}
+
+	if err := items.DeleteByOrder(ctx, order.ID); err != nil {
+		t.Fatalf("DeleteByOrder: %v", err)
+	}
+	if total, err := items.SumQuantityByOrder(ctx, order.ID); err != nil || total != 0 {
+		t.Errorf("sum after delete = %d, %v; want 0", total, err)
+	}
+}
+
+func TestOrderItemRepositorySpecifiedNames(t *testing.T) {
+	db := newTestDB(t)
+	orders := NewOrderRepository(db)
+	items := NewOrderItemRepository(db)
+	ctx := context.Background()
+
+	order := &domain.Order{IDUser: 8021, UserName: "buyer", Contact: "+77010000000", DataPay: "2026-03-01 10:00:00"}
+	if err := orders.Create(ctx, order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	err := items.AddOrderItems(ctx, order.ID, []domain.OrderItem{
+		{Name: "Baccarat Rouge", Quantity: 2},
+		{Name: "Lost Cherry", Quantity: 1},
+	})
+	if err != nil {
+		t.Fatalf("AddOrderItems: %v", err)
+	}
+
+	got, err := items.GetOrderItems(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("GetOrderItems: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "Baccarat Rouge" || got[1].Name != "Lost Cherry" {
+		t.Errorf("items = %+v, want Baccarat Rouge then Lost Cherry", got)
+	}
+	if total, err := items.SumOrderItemsQuantity(ctx, order.ID); err != nil || total != 3 {
+		t.Errorf("SumOrderItemsQuantity = %d, %v; want 3", total, err)
+	}
+}
@@ -1,10 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"parfum/internal/domain"
-	"time"
+	"slices"
+	"strings"
 	"fmt"
+	"time"
 )
 
 type OrderRepository struct {
@@ -15,9 +20,116 @@ func NewOrderRepository(db *sql.DB) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
+// listQueryTimeout bounds queries that scan whole tables, such as GetAll and the stats,
+// so a slow one gives up instead of holding a connection after its caller moved on
+const listQueryTimeout = 30 * time.Second
+
+// orderColumns is the column list every order query selects, in the order scanOrder reads it
+const orderColumns = `id, id_user, userName, quantity, parfumes, gift, fio, contact, address, latitude, longitude, dateRegister, dataPay, checks, status, payment_link, total_amount, created_at, updated_at, prize_awarded_at, prize_reminded_at, prize_expired_at, prize_claimed_at`
+
+// scannable is implemented by both *sql.Row and *sql.Rows
+type scannable interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanOrder reads one row selected with orderColumns, mapping NULLs to zero values
+func scanOrder(row scannable) (domain.Order, error) {
+	var order domain.Order
+	var quantity sql.NullInt64
+	var parfumes, gift, fio, address, dateRegister sql.NullString
+	var latitude, longitude sql.NullFloat64
+	var prizeAwardedAt, prizeRemindedAt, prizeExpiredAt, prizeClaimedAt sql.NullTime
+
+	err := row.Scan(
+		&order.ID,
+		&order.IDUser,
+		&order.UserName,
+		&quantity,
+		&parfumes,
+		&gift,
+		&fio,
+		&order.Contact,
+		&address,
+		&latitude,
+		&longitude,
+		&dateRegister,
+		&order.DataPay,
+		&order.Checks,
+		&order.Status,
+		&order.PaymentLink,
+		&order.TotalAmount,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+		&prizeAwardedAt,
+		&prizeRemindedAt,
+		&prizeExpiredAt,
+		&prizeClaimedAt,
+	)
+	if err != nil {
+		return order, err
+	}
+
+	if quantity.Valid {
+		qty := int(quantity.Int64)
+		order.Quantity = &qty
+	}
+	order.Parfumes = parfumes.String
+	order.Gift = gift.String
+	order.FIO = fio.String
+	order.Address = address.String
+	if latitude.Valid && longitude.Valid {
+		order.Latitude = &latitude.Float64
+		order.Longitude = &longitude.Float64
+	}
+	order.DateRegister = dateRegister.String
+	if prizeAwardedAt.Valid {
+		order.PrizeAwardedAt = &prizeAwardedAt.Time
+	}
+	if prizeRemindedAt.Valid {
+		order.PrizeRemindedAt = &prizeRemindedAt.Time
+	}
+	if prizeExpiredAt.Valid {
+		order.PrizeExpiredAt = &prizeExpiredAt.Time
+	}
+	if prizeClaimedAt.Valid {
+		order.PrizeClaimedAt = &prizeClaimedAt.Time
+	}
+
+	return order, nil
+}
+
+// scanOrderRow reads a single order; sql.ErrNoRows is returned unwrapped
+func scanOrderRow(row *sql.Row) (*domain.Order, error) {
+	order, err := scanOrder(row)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// scanOrders reads every row of an order query and closes it
+func scanOrders(rows *sql.Rows) ([]domain.Order, error) {
+	defer rows.Close()
+
+	var orders []domain.Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return orders, nil
+}
+
 
 // GetOrderSequenceNumber gets the sequence number of an order for prize determination
-func (r *OrderRepository) GetOrderSequenceNumber(orderID int64) (int, error) {
+func (r *OrderRepository) GetOrderSequenceNumber(ctx context.Context, orderID int64) (int, error) {
 	query := `
 		SELECT COUNT(*) + 1 
 		FROM orders 
@@ -25,7 +137,7 @@ func (r *OrderRepository) GetOrderSequenceNumber(orderID int64) (int, error) {
 	`
 	
 	var sequence int
-	err := r.db.QueryRow(query, orderID).Scan(&sequence)
+	err := r.db.QueryRowContext(ctx, query, orderID).Scan(&sequence)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get order sequence: %w", err)
 	}
@@ -34,14 +146,15 @@ func (r *OrderRepository) GetOrderSequenceNumber(orderID int64) (int, error) {
 }
 
 // UpdateOrderPrize updates an order with the won prize
-func (r *OrderRepository) UpdateOrderPrize(orderID int64, prize string) error {
+func (r *OrderRepository) UpdateOrderPrize(ctx context.Context, orderID int64, prize string) error {
 	query := `
 		UPDATE orders 
-		SET gift = ?, updated_at = CURRENT_TIMESTAMP 
+		SET gift = ?, prize_awarded_at = CURRENT_TIMESTAMP, prize_reminded_at = NULL, prize_expired_at = NULL,
+			updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ?
 	`
 	
-	result, err := r.db.Exec(query, prize, orderID)
+	result, err := r.db.ExecContext(ctx, query, prize, orderID)
 	if err != nil {
 		return fmt.Errorf("failed to update order prize: %w", err)
 	}
@@ -58,71 +171,318 @@ func (r *OrderRepository) UpdateOrderPrize(orderID int64, prize string) error {
 	return nil
 }
 
-// MarkOrderAsCompleted marks an order as completed (checks = true)
-func (r *OrderRepository) MarkOrderAsCompleted(orderID int64) error {
-	query := `
-		UPDATE orders 
-		SET checks = true, updated_at = CURRENT_TIMESTAMP 
-		WHERE id = ?
-	`
-	
-	result, err := r.db.Exec(query, orderID)
+// AssignPrizes sets prizes on several orders in one transaction. As in AwardPrize, a
+// limited prize comes out of its stock, and an assignment gets its Fallback once the
+// stock is used up. A limited prize an order had before goes back into stock. It returns
+// what each order got and had before, in the order of assignments.
+func (r *OrderRepository) AssignPrizes(ctx context.Context, assignments []domain.PrizeAssignment) ([]domain.AssignedPrize, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to mark order as completed: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	
-	rowsAffected, err := result.RowsAffected()
+	defer tx.Rollback()
+
+	assigned := make([]domain.AssignedPrize, 0, len(assignments))
+	for _, assignment := range assignments {
+		var gift sql.NullString
+		err := tx.QueryRowContext(ctx, `SELECT gift FROM orders WHERE id = ?`, assignment.OrderID).Scan(&gift)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no order found with id %d", assignment.OrderID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get order prize: %w", err)
+		}
+
+		previous := gift.String
+		if previous == "null" {
+			previous = ""
+		}
+		if previous != "" {
+			if err := returnPrizeStock(ctx, tx, previous); err != nil {
+				return nil, err
+			}
+		}
+
+		prize, err := takePrizeStock(ctx, tx, assignment.Prize, assignment.Fallback)
+		if err != nil {
+			return nil, err
+		}
+
+		// An assigned prize has its own time to be claimed
+		_, err = tx.ExecContext(ctx, `
+			UPDATE orders 
+			SET gift = ?, prize_awarded_at = CURRENT_TIMESTAMP, prize_reminded_at = NULL, prize_expired_at = NULL,
+				updated_at = CURRENT_TIMESTAMP 
+			WHERE id = ?
+		`, prize, assignment.OrderID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update order prize: %w", err)
+		}
+
+		assigned = append(assigned, domain.AssignedPrize{
+			OrderID:       assignment.OrderID,
+			Prize:         prize,
+			PreviousPrize: previous,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit prize assignments: %w", err)
+	}
+
+	return assigned, nil
+}
+
+// MarkOrderAsCompleted marks an order as completed: it moves on to address_provided,
+// which sets checks = true
+func (r *OrderRepository) MarkOrderAsCompleted(ctx context.Context, orderID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to get affected rows: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	
-	if rowsAffected == 0 {
+	defer tx.Rollback()
+
+	err = advanceOrderStatus(ctx, tx, orderID, domain.OrderStatusAddressProvided)
+	if errors.Is(err, sql.ErrNoRows) {
 		return fmt.Errorf("no order found with id %d", orderID)
 	}
-	
+	if err != nil {
+		return fmt.Errorf("failed to mark order as completed: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// StatusTransitionError is returned when the lifecycle doesn't allow a status change
+type StatusTransitionError struct {
+	From domain.OrderStatus
+	To   domain.OrderStatus
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("order status can't change from %s to %s", e.From, e.To)
+}
+
+// InsufficientQuantityError is returned by Checkout when the user's paid orders have room
+// for fewer sets than the cart holds
+type InsufficientQuantityError struct {
+	Available int
+	Requested int
+}
+
+func (e *InsufficientQuantityError) Error() string {
+	return fmt.Sprintf("insufficient order quantity: available %d, requested %d", e.Available, e.Requested)
+}
+
+// UpdateStatus moves an order to status if the lifecycle allows it, keeping checks in
+// sync and recording the change in order_events. Cancelling puts the order's items back
+// into stock. A missing order gives sql.ErrNoRows, a disallowed change a *StatusTransitionError.
+func (r *OrderRepository) UpdateStatus(ctx context.Context, orderID int64, status domain.OrderStatus, actor, note string) (*domain.OrderEvent, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current domain.OrderStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = ?`, orderID).Scan(&current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order status: %w", err)
+	}
+
+	if !current.CanTransitionTo(status) {
+		return nil, &StatusTransitionError{From: current, To: status}
+	}
+
+	event, err := setOrderStatus(ctx, tx, orderID, current, status, actor, note)
+	if err != nil {
+		return nil, err
+	}
+
+	// A cancelled order gives its perfumes back; cancelled is final, so this happens once
+	if status == domain.OrderStatusCancelled {
+		items, err := queryOrderItems(ctx, tx, orderID)
+		if err != nil {
+			return nil, err
+		}
+		if err := restoreStock(ctx, tx, orderItemStock(items)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit status change: %w", err)
+	}
+
+	return event, nil
+}
+
+// RecordEvent appends an event to the order's timeline; payload is stored as JSON
+func (r *OrderRepository) RecordEvent(ctx context.Context, orderID int64, eventType domain.OrderEventType, actor string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event payload: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO order_events (order_id, event_type, from_status, to_status, payload, actor)
+		VALUES (?, ?, '', '', ?, ?)
+	`, orderID, eventType, string(data), actor)
+	if err != nil {
+		return fmt.Errorf("failed to record order event: %w", err)
+	}
+
 	return nil
 }
 
+// GetEvents returns the timeline of an order, oldest first
+func (r *OrderRepository) GetEvents(ctx context.Context, orderID int64) ([]domain.OrderEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, order_id, event_type, from_status, to_status, payload, actor, note, created_at
+		FROM order_events
+		WHERE order_id = ?
+		ORDER BY created_at, id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.OrderEvent
+	for rows.Next() {
+		var event domain.OrderEvent
+		var payload string
+		err := rows.Scan(&event.ID, &event.OrderID, &event.EventType, &event.FromStatus, &event.ToStatus,
+			&payload, &event.Actor, &event.Note, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return events, nil
+}
+
+// setOrderStatus writes status and the matching checks value and appends the event
+func setOrderStatus(ctx context.Context, tx *sql.Tx, orderID int64, from, to domain.OrderStatus, actor, note string) (*domain.OrderEvent, error) {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET status = ?, checks = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, to, to.Checks(), orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	event := &domain.OrderEvent{
+		OrderID:    orderID,
+		EventType:  domain.OrderEventStatusChanged,
+		FromStatus: from,
+		ToStatus:   to,
+		Payload:    json.RawMessage("{}"),
+		Actor:      actor,
+		Note:       note,
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO order_events (order_id, event_type, from_status, to_status, payload, actor, note)
+		VALUES (?, ?, ?, ?, '{}', ?, ?)
+		RETURNING id, created_at
+	`, orderID, event.EventType, from, to, actor, note).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record order event: %w", err)
+	}
+
+	return event, nil
+}
+
+// advanceOrderStatus moves an order forward to status as the customer flow reaches it.
+// Orders already at or past status, and cancelled orders, are left as they are.
+func advanceOrderStatus(ctx context.Context, tx *sql.Tx, orderID int64, status domain.OrderStatus) error {
+	var current domain.OrderStatus
+	err := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = ?`, orderID).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	if current == domain.OrderStatusCancelled || slices.Index(domain.OrderStatuses, current) >= slices.Index(domain.OrderStatuses, status) {
+		return nil
+	}
+
+	_, err = setOrderStatus(ctx, tx, orderID, current, status, domain.OrderEventActorUser, "")
+	return err
+}
+
 // GetOrdersWithPrizes gets all orders that have prizes assigned
-func (r *OrderRepository) GetOrdersWithPrizes() ([]domain.Order, error) {
+func (r *OrderRepository) GetOrdersWithPrizes(ctx context.Context) ([]domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, gift, fio, contact, 
-		       address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE gift IS NOT NULL AND gift != '' AND gift != 'null'
 		ORDER BY created_at DESC
 	`
 	
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query orders with prizes: %w", err)
 	}
-	defer rows.Close()
-	
-	var orders []domain.Order
-	for rows.Next() {
-		var order domain.Order
-		err := rows.Scan(
-			&order.ID, &order.ID_user, &order.UserName, &order.Quantity, 
-			&order.Parfumes, &order.Gift, &order.FIO, &order.Contact,
-			&order.Address, &order.DateRegister, &order.DatePay, 
-			&order.Checks, &order.CreatedAt, &order.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-		orders = append(orders, order)
+
+	return scanOrders(rows)
+}
+
+// GetPrizeOrdersByUser gets a user's orders that have a prize, newest first
+func (r *OrderRepository) GetPrizeOrdersByUser(ctx context.Context, telegramID int64) ([]domain.Order, error) {
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders
+		WHERE id_user = ? AND gift IS NOT NULL AND gift != '' AND gift != 'null'
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prize orders: %w", err)
 	}
-	
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("row iteration error: %w", err)
+
+	return scanOrders(rows)
+}
+
+// GetCompletedPrizeOrders returns the prize orders whose winner has sent their delivery
+// details, in the order they came in. from and to are optional YYYY-MM-DD dates, both
+// inclusive, of the prize claim.
+func (r *OrderRepository) GetCompletedPrizeOrders(ctx context.Context, from, to string) ([]domain.Order, error) {
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders
+		WHERE gift IS NOT NULL AND gift != '' AND gift != 'null' AND prize_claimed_at IS NOT NULL`
+	var args []interface{}
+	if from != "" {
+		query += " AND DATE(prize_claimed_at) >= ?"
+		args = append(args, from)
 	}
-	
-	return orders, nil
+	if to != "" {
+		query += " AND DATE(prize_claimed_at) <= ?"
+		args = append(args, to)
+	}
+	query += " ORDER BY prize_claimed_at ASC, id ASC"
+
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query completed prize orders: %w", err)
+	}
+
+	return scanOrders(rows)
 }
 
 // GetPrizeStatistics gets statistics about prize distribution
-func (r *OrderRepository) GetPrizeStatistics() (map[string]int, error) {
+func (r *OrderRepository) GetPrizeStatistics(ctx context.Context) (map[string]int, error) {
 	query := `
 		SELECT 
 			gift,
@@ -133,7 +493,7 @@ func (r *OrderRepository) GetPrizeStatistics() (map[string]int, error) {
 		ORDER BY count DESC
 	`
 	
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query prize statistics: %w", err)
 	}
@@ -158,54 +518,39 @@ func (r *OrderRepository) GetPrizeStatistics() (map[string]int, error) {
 }
 
 // GetOrdersEligibleForPrize gets orders that are eligible for prize wheel
-func (r *OrderRepository) GetOrdersEligibleForPrize(telegramID int64) ([]domain.Order, error) {
+func (r *OrderRepository) GetOrdersEligibleForPrize(ctx context.Context, telegramID int64) ([]domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, gift, fio, contact, 
-		       address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE id_user = ? 
 		  AND parfumes IS NOT NULL 
 		  AND parfumes != ''
 		  AND (gift IS NULL OR gift = '' OR gift = 'null')
+		  AND status NOT IN ('cancelled', 'awaiting_payment')
 		ORDER BY created_at ASC
 	`
 	
-	rows, err := r.db.Query(query, telegramID)
+	rows, err := r.db.QueryContext(ctx, query, telegramID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query eligible orders: %w", err)
 	}
-	defer rows.Close()
-	
-	var orders []domain.Order
-	for rows.Next() {
-		var order domain.Order
-		err := rows.Scan(
-			&order.ID, &order.ID_user, &order.UserName, &order.Quantity, 
-			&order.Parfumes, &order.Gift, &order.FIO, &order.Contact,
-			&order.Address, &order.DateRegister, &order.DatePay, 
-			&order.Checks, &order.CreatedAt, &order.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-		orders = append(orders, order)
-	}
-	
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("row iteration error: %w", err)
-	}
-	
-	return orders, nil
+
+	return scanOrders(rows)
 }
 
 // Create creates a new order
-func (r *OrderRepository) Create(order *domain.Order) error {
+func (r *OrderRepository) Create(ctx context.Context, order *domain.Order) error {
 	query := `
-		INSERT INTO orders (id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO orders (id_user, userName, quantity, parfumes, fio, contact, address, latitude, longitude, dateRegister, dataPay, checks, status, payment_link, total_amount, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`
 
-	result, err := r.db.Exec(query,
+	// Orders are created once the receipt is accepted
+	if order.Status == "" {
+		order.Status = domain.OrderStatusPaid
+	}
+
+	result, err := r.db.ExecContext(ctx, query,
 		order.IDUser,
 		order.UserName,
 		order.Quantity,
@@ -213,9 +558,14 @@ func (r *OrderRepository) Create(order *domain.Order) error {
 		order.FIO,
 		order.Contact,
 		order.Address,
+		order.Latitude,
+		order.Longitude,
 		order.DateRegister,
 		order.DataPay,
-		order.Checks)
+		order.Checks,
+		order.Status,
+		order.PaymentLink,
+		order.TotalAmount)
 
 	if err != nil {
 		return err
@@ -231,214 +581,196 @@ func (r *OrderRepository) Create(order *domain.Order) error {
 }
 
 // GetByID retrieves an order by ID
-func (r *OrderRepository) GetByID(id int64) (*domain.Order, error) {
+func (r *OrderRepository) GetByID(ctx context.Context, id int64) (*domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE id = ?
 	`
 
-	row := r.db.QueryRow(query, id)
-
-	var order domain.Order
-	var createdAt, updatedAt time.Time
-	var parfumes, fio, address, dateRegister sql.NullString
+	return scanOrderRow(r.db.QueryRowContext(ctx, query, id))
+}
 
-	err := row.Scan(
-		&order.ID,
-		&order.IDUser,
-		&order.UserName,
-		&order.Quantity,
-		&parfumes,
-		&fio,
-		&order.Contact,
-		&address,
-		&dateRegister,
-		&order.DataPay,
-		&order.Checks,
-		&createdAt,
-		&updatedAt,
-	)
+// GetByUserID retrieves orders by user ID
+func (r *OrderRepository) GetByUserID(ctx context.Context, userID int64) ([]domain.Order, error) {
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders 
+		WHERE id_user = ?
+		ORDER BY created_at DESC
+	`
 
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Handle nullable fields
-	if parfumes.Valid {
-		order.Parfumes = parfumes.String
-	}
-	if fio.Valid {
-		order.FIO = fio.String
-	}
-	if address.Valid {
-		order.Address = address.String
-	}
-	if dateRegister.Valid {
-		order.DateRegister = dateRegister.String
-	}
-
-	order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-	order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
-
-	return &order, nil
+	return scanOrders(rows)
 }
 
-// GetByUserID retrieves orders by user ID
-func (r *OrderRepository) GetByUserID(userID int64) ([]domain.Order, error) {
+// GetAll retrieves all orders
+func (r *OrderRepository) GetAll(ctx context.Context) ([]domain.Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
-		WHERE id_user = ?
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var orders []domain.Order
+	return scanOrders(rows)
+}
 
-	for rows.Next() {
-		var order domain.Order
-		var createdAt, updatedAt time.Time
-		var parfumes, fio, address, dateRegister sql.NullString
-
-		err := rows.Scan(
-			&order.ID,
-			&order.IDUser,
-			&order.UserName,
-			&order.Quantity,
-			&parfumes,
-			&fio,
-			&order.Contact,
-			&address,
-			&dateRegister,
-			&order.DataPay,
-			&order.Checks,
-			&createdAt,
-			&updatedAt,
-		)
+// orderFilterClause builds the WHERE clause and arguments for an OrderFilter
+func orderFilterClause(filter domain.OrderFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
 
-		if err != nil {
-			return nil, err
-		}
-
-		// Handle nullable fields
-		if parfumes.Valid {
-			order.Parfumes = parfumes.String
-		}
-		if fio.Valid {
-			order.FIO = fio.String
-		}
-		if address.Valid {
-			order.Address = address.String
-		}
-		if dateRegister.Valid {
-			order.DateRegister = dateRegister.String
-		}
-
-		order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-		order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
-
-		orders = append(orders, order)
+	if filter.Checks != nil {
+		conditions = append(conditions, "checks = ?")
+		args = append(args, *filter.Checks)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "DATE(created_at) >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "DATE(created_at) <= ?")
+		args = append(args, filter.To)
 	}
 
-	return orders, nil
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
 }
 
-// GetAll retrieves all orders
-func (r *OrderRepository) GetAll() ([]domain.Order, error) {
+// QueryOrders returns an open cursor over the orders matching filter, newest first.
+// Read it with ScanOrder; the caller must close it.
+func (r *OrderRepository) QueryOrders(ctx context.Context, filter domain.OrderFilter) (*sql.Rows, error) {
+	where, args := orderFilterClause(filter)
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
-		FROM orders 
+		SELECT ` + orderColumns + `
+		FROM orders
+		` + where + `
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to query orders: %w", err)
 	}
-	defer rows.Close()
+	return rows, nil
+}
 
-	var orders []domain.Order
+// ScanOrder reads the current row of a QueryOrders cursor
+func ScanOrder(rows *sql.Rows) (domain.Order, error) {
+	order, err := scanOrder(rows)
+	if err != nil {
+		return order, fmt.Errorf("failed to scan order: %w", err)
+	}
+	return order, nil
+}
 
-	for rows.Next() {
-		var order domain.Order
-		var createdAt, updatedAt time.Time
-		var parfumes, fio, address, dateRegister sql.NullString
-
-		err := rows.Scan(
-			&order.ID,
-			&order.IDUser,
-			&order.UserName,
-			&order.Quantity,
-			&parfumes,
-			&fio,
-			&order.Contact,
-			&address,
-			&dateRegister,
-			&order.DataPay,
-			&order.Checks,
-			&createdAt,
-			&updatedAt,
-		)
+// ForEachOrder streams the orders matching filter to fn one row at a time,
+// newest first, so large exports never hold the whole table in memory
+func (r *OrderRepository) ForEachOrder(ctx context.Context, filter domain.OrderFilter, fn func(domain.Order) error) error {
+	rows, err := r.QueryOrders(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
+	for rows.Next() {
+		order, err := ScanOrder(rows)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		// Handle nullable fields
-		if parfumes.Valid {
-			order.Parfumes = parfumes.String
-		}
-		if fio.Valid {
-			order.FIO = fio.String
-		}
-		if address.Valid {
-			order.Address = address.String
-		}
-		if dateRegister.Valid {
-			order.DateRegister = dateRegister.String
+		if err := fn(order); err != nil {
+			return err
 		}
+	}
 
-		order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-		order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
+	return rows.Err()
+}
 
+// List returns the orders matching filter, newest first
+func (r *OrderRepository) List(ctx context.Context, filter domain.OrderFilter) ([]domain.Order, error) {
+	var orders []domain.Order
+	err := r.ForEachOrder(ctx, filter, func(order domain.Order) error {
 		orders = append(orders, order)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-
 	return orders, nil
 }
 
+// SearchOrders returns up to limit orders, newest first, whose fio, userName or contact
+// contains query, or whose contact has the digits of phone (see service.PhoneDigits)
+// in any format. An empty phone matches by text only.
+func (r *OrderRepository) SearchOrders(ctx context.Context, query, phone string, limit int) ([]domain.Order, error) {
+	pattern := "%" + query + "%"
+	match := "c.fio LIKE ? OR c.userName LIKE ? OR c.contact LIKE ?"
+	args := []interface{}{pattern, pattern, pattern}
+	if phone != "" {
+		match += " OR c.contact_digits LIKE ?"
+		args = append(args, "%"+phone+"%")
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM `+phoneDigitsSQL("orders")+`
+		WHERE `+match+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search orders: %w", err)
+	}
+
+	return scanOrders(rows)
+}
+
 // UpdateChecks updates order check status
-func (r *OrderRepository) UpdateChecks(id int64, checks bool) error {
+func (r *OrderRepository) UpdateChecks(ctx context.Context, id int64, checks bool) error {
 	query := `
 		UPDATE orders 
 		SET checks = ?, updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query, checks, id)
+	_, err := r.db.ExecContext(ctx, query, checks, id)
 	return err
 }
 
 // UpdatePaymentDate updates the payment date
-func (r *OrderRepository) UpdatePaymentDate(id int64, dataPay string) error {
+func (r *OrderRepository) UpdatePaymentDate(ctx context.Context, id int64, dataPay string) error {
 	query := `
 		UPDATE orders 
 		SET dataPay = ?, updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query, dataPay, id)
+	_, err := r.db.ExecContext(ctx, query, dataPay, id)
 	return err
 }
 
 // Update updates an order
-func (r *OrderRepository) Update(order *domain.Order) error {
+func (r *OrderRepository) Update(ctx context.Context, order *domain.Order) error {
 	query := `
 		UPDATE orders 
 		SET id_user = ?, userName = ?, quantity = ?, parfumes = ?, fio = ?, 
@@ -447,7 +779,7 @@ func (r *OrderRepository) Update(order *domain.Order) error {
 		WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query,
+	_, err := r.db.ExecContext(ctx, query,
 		order.IDUser,
 		order.UserName,
 		order.Quantity,
@@ -464,375 +796,208 @@ func (r *OrderRepository) Update(order *domain.Order) error {
 }
 
 // Delete removes an order by ID
-func (r *OrderRepository) Delete(id int64) error {
+func (r *OrderRepository) Delete(ctx context.Context, id int64) error {
 	query := "DELETE FROM orders WHERE id = ?"
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
 
 // GetOrdersByChecksStatus retrieves orders by check status
-func (r *OrderRepository) GetOrdersByChecksStatus(checks bool) ([]domain.Order, error) {
+func (r *OrderRepository) GetOrdersByChecksStatus(ctx context.Context, checks bool) ([]domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE checks = ?
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, checks)
+	rows, err := r.db.QueryContext(ctx, query, checks)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var orders []domain.Order
-
-	for rows.Next() {
-		var order domain.Order
-		var createdAt, updatedAt time.Time
-		var parfumes, fio, address, dateRegister sql.NullString
-
-		err := rows.Scan(
-			&order.ID,
-			&order.IDUser,
-			&order.UserName,
-			&order.Quantity,
-			&parfumes,
-			&fio,
-			&order.Contact,
-			&address,
-			&dateRegister,
-			&order.DataPay,
-			&order.Checks,
-			&createdAt,
-			&updatedAt,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		// Handle nullable fields
-		if parfumes.Valid {
-			order.Parfumes = parfumes.String
-		}
-		if fio.Valid {
-			order.FIO = fio.String
-		}
-		if address.Valid {
-			order.Address = address.String
-		}
-		if dateRegister.Valid {
-			order.DateRegister = dateRegister.String
-		}
-
-		order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-		order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
-
-		orders = append(orders, order)
-	}
 
-	return orders, nil
+	return scanOrders(rows)
 }
 
 // GetOrdersByUserName retrieves orders by username
-func (r *OrderRepository) GetOrdersByUserName(userName string) ([]domain.Order, error) {
+func (r *OrderRepository) GetOrdersByUserName(ctx context.Context, userName string) ([]domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE userName LIKE ?
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, "%"+userName+"%")
+	rows, err := r.db.QueryContext(ctx, query, "%"+userName+"%")
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var orders []domain.Order
-
-	for rows.Next() {
-		var order domain.Order
-		var createdAt, updatedAt time.Time
-		var parfumes, fio, address, dateRegister sql.NullString
-
-		err := rows.Scan(
-			&order.ID,
-			&order.IDUser,
-			&order.UserName,
-			&order.Quantity,
-			&parfumes,
-			&fio,
-			&order.Contact,
-			&address,
-			&dateRegister,
-			&order.DataPay,
-			&order.Checks,
-			&createdAt,
-			&updatedAt,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		// Handle nullable fields
-		if parfumes.Valid {
-			order.Parfumes = parfumes.String
-		}
-		if fio.Valid {
-			order.FIO = fio.String
-		}
-		if address.Valid {
-			order.Address = address.String
-		}
-		if dateRegister.Valid {
-			order.DateRegister = dateRegister.String
-		}
-
-		order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-		order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
-
-		orders = append(orders, order)
-	}
 
-	return orders, nil
+	return scanOrders(rows)
 }
 
-// GetOrderStats returns order statistics
-func (r *OrderRepository) GetOrderStats() (map[string]interface{}, error) {
-	stats := make(map[string]interface{})
+// GetOrderStats returns order counts and total quantity in a single pass over orders
+func (r *OrderRepository) GetOrderStats(ctx context.Context) (*domain.OrderStatsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
 
-	// Total orders
-	var totalOrders int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&totalOrders)
-	if err != nil {
-		return nil, err
-	}
-	stats["total_orders"] = totalOrders
+	query := `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN checks = 0 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN checks = 1 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(quantity), 0),
+			COALESCE(SUM(CASE WHEN DATE(created_at) = DATE('now') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN created_at >= datetime('now', '-7 days') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN created_at >= datetime('now', 'start of month') THEN 1 ELSE 0 END), 0)
+		FROM orders
+	`
 
-	// Pending orders (unchecked)
-	var pendingOrders int
-	err = r.db.QueryRow("SELECT COUNT(*) FROM orders WHERE checks = 0").Scan(&pendingOrders)
+	var stats domain.OrderStatsResponse
+	err := r.db.QueryRowContext(ctx, query).Scan(
+		&stats.TotalOrders,
+		&stats.PendingOrders,
+		&stats.CompletedOrders,
+		&stats.TotalQuantity,
+		&stats.TodayOrders,
+		&stats.WeekOrders,
+		&stats.MonthOrders,
+	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to query order stats: %w", err)
 	}
-	stats["pending_orders"] = pendingOrders
 
-	// Completed orders (checked)
-	var completedOrders int
-	err = r.db.QueryRow("SELECT COUNT(*) FROM orders WHERE checks = 1").Scan(&completedOrders)
-	if err != nil {
-		return nil, err
-	}
-	stats["completed_orders"] = completedOrders
+	return &stats, nil
+}
 
-	// Total quantity
-	var totalQuantity sql.NullInt64
-	err = r.db.QueryRow("SELECT SUM(quantity) FROM orders").Scan(&totalQuantity)
-	if err != nil {
-		return nil, err
-	}
-	if totalQuantity.Valid {
-		stats["total_quantity"] = totalQuantity.Int64
-	} else {
-		stats["total_quantity"] = 0
-	}
+// GetBotStats returns the numbers of the /stats bot command for everything created since
+// since, or for all time if since is zero, in a single query. The all-time revenue is the
+// money total; a period's revenue is the sum of the receipts paid in it.
+func (r *OrderRepository) GetBotStats(ctx context.Context, since time.Time) (*domain.BotStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
 
-	// Today's orders
-	var todayOrders int
-	err = r.db.QueryRow("SELECT COUNT(*) FROM orders WHERE DATE(created_at) = DATE('now')").Scan(&todayOrders)
-	if err != nil {
-		return nil, err
-	}
-	stats["today_orders"] = todayOrders
+	query := `
+		WITH period AS (SELECT checks, quantity, gift FROM orders WHERE created_at >= ?1)
+		SELECT
+			(SELECT COUNT(*) FROM period),
+			(SELECT COUNT(*) FROM period WHERE checks = 0),
+			(SELECT COUNT(*) FROM period WHERE checks = 1),
+			(SELECT COALESCE(SUM(quantity), 0) FROM period),
+			CASE WHEN ?2
+				THEN (SELECT COALESCE(SUM(sum), 0) FROM money)
+				ELSE (SELECT COALESCE(SUM(amount), 0) FROM receipts WHERE created_at >= ?1)
+			END,
+			(SELECT COUNT(*) FROM just WHERE created_at >= ?1),
+			(SELECT COUNT(*) FROM orders WHERE DATE(created_at) = DATE('now')),
+			(SELECT COALESCE(json_group_object(gift, count), '{}') FROM (
+				SELECT gift, COUNT(*) AS count
+				FROM period
+				WHERE gift IS NOT NULL AND gift != '' AND gift != 'null'
+				GROUP BY gift
+			))
+	`
 
-	// This week's orders
-	var weekOrders int
-	err = r.db.QueryRow("SELECT COUNT(*) FROM orders WHERE created_at >= datetime('now', '-7 days')").Scan(&weekOrders)
+	stats := domain.BotStats{Since: since}
+	var prizes string
+	err := r.db.QueryRowContext(ctx, query, since.UTC().Format("2006-01-02 15:04:05"), since.IsZero()).Scan(
+		&stats.Orders,
+		&stats.PendingOrders,
+		&stats.CompletedOrders,
+		&stats.Quantity,
+		&stats.Revenue,
+		&stats.NewUsers,
+		&stats.TodayOrders,
+		&prizes,
+	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to query bot stats: %w", err)
 	}
-	stats["week_orders"] = weekOrders
-
-	// This month's orders
-	var monthOrders int
-	err = r.db.QueryRow("SELECT COUNT(*) FROM orders WHERE created_at >= datetime('now', 'start of month')").Scan(&monthOrders)
-	if err != nil {
-		return nil, err
+	if err := json.Unmarshal([]byte(prizes), &stats.PrizeCounts); err != nil {
+		return nil, fmt.Errorf("failed to decode prize counts: %w", err)
 	}
-	stats["month_orders"] = monthOrders
 
-	return stats, nil
+	return &stats, nil
 }
 
 // GetOrdersByDateRange retrieves orders within a date range
-func (r *OrderRepository) GetOrdersByDateRange(startDate, endDate string) ([]domain.Order, error) {
+func (r *OrderRepository) GetOrdersByDateRange(ctx context.Context, startDate, endDate string) ([]domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE DATE(created_at) BETWEEN ? AND ?
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, startDate, endDate)
+	rows, err := r.db.QueryContext(ctx, query, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var orders []domain.Order
-
-	for rows.Next() {
-		var order domain.Order
-		var createdAt, updatedAt time.Time
-		var parfumes, fio, address, dateRegister sql.NullString
-
-		err := rows.Scan(
-			&order.ID,
-			&order.IDUser,
-			&order.UserName,
-			&order.Quantity,
-			&parfumes,
-			&fio,
-			&order.Contact,
-			&address,
-			&dateRegister,
-			&order.DataPay,
-			&order.Checks,
-			&createdAt,
-			&updatedAt,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		// Handle nullable fields
-		if parfumes.Valid {
-			order.Parfumes = parfumes.String
-		}
-		if fio.Valid {
-			order.FIO = fio.String
-		}
-		if address.Valid {
-			order.Address = address.String
-		}
-		if dateRegister.Valid {
-			order.DateRegister = dateRegister.String
-		}
-
-		order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-		order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
-
-		orders = append(orders, order)
-	}
 
-	return orders, nil
+	return scanOrders(rows)
 }
 
 // CountOrdersByUser returns the count of orders for a specific user
-func (r *OrderRepository) CountOrdersByUser(userID int64) (int, error) {
+func (r *OrderRepository) CountOrdersByUser(ctx context.Context, userID int64) (int, error) {
 	var count int
 	query := "SELECT COUNT(*) FROM orders WHERE id_user = ?"
-	err := r.db.QueryRow(query, userID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&count)
 	return count, err
 }
 
 // Add these methods to your OrderRepository
 
-// GetUnpaidOrdersByUser gets all unpaid orders for a user
-func (r *OrderRepository) GetUnpaidOrdersByUser(telegramID int64) ([]domain.Order, error) {
+// GetUnpaidOrdersByUser gets all unpaid orders for a user. Orders whose quantity isn't
+// known are included, with a nil Quantity.
+func (r *OrderRepository) GetUnpaidOrdersByUser(ctx context.Context, telegramID int64) ([]domain.Order, error) {
+	return queryUnpaidOrders(ctx, r.db, telegramID)
+}
+
+// queryUnpaidOrders reads a user's paid orders that aren't completed yet, newest first
+func queryUnpaidOrders(ctx context.Context, q queryer, telegramID int64) ([]domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
-		WHERE id_user = ? AND checks = 0 AND quantity > 0
+		WHERE id_user = ? AND checks = 0 AND (quantity IS NULL OR quantity > 0) AND status NOT IN ('cancelled', 'awaiting_payment')
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, telegramID)
+	rows, err := q.QueryContext(ctx, query, telegramID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var orders []domain.Order
-
-	for rows.Next() {
-		var order domain.Order
-		var createdAt, updatedAt time.Time
-		var quantity sql.NullInt64
-		var parfumes, fio, address, dateRegister sql.NullString
-
-		err := rows.Scan(
-			&order.ID,
-			&order.IDUser,
-			&order.UserName,
-			&quantity,
-			&parfumes,
-			&fio,
-			&order.Contact,
-			&address,
-			&dateRegister,
-			&order.DataPay,
-			&order.Checks,
-			&createdAt,
-			&updatedAt,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		// Handle nullable fields
-		if quantity.Valid {
-			qty := int(quantity.Int64)
-			order.Quantity = &qty
-		}
-		if parfumes.Valid {
-			order.Parfumes = parfumes.String
-		}
-		if fio.Valid {
-			order.FIO = fio.String
-		}
-		if address.Valid {
-			order.Address = address.String
-		}
-		if dateRegister.Valid {
-			order.DateRegister = dateRegister.String
-		}
 
-		order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-		order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
+	return scanOrders(rows)
+}
 
-		orders = append(orders, order)
-	}
+// GetPendingPayment returns the user's newest order still awaiting payment; sql.ErrNoRows if there is none
+func (r *OrderRepository) GetPendingPayment(ctx context.Context, telegramID int64) (*domain.Order, error) {
+	query := `
+		SELECT ` + orderColumns + `
+		FROM orders 
+		WHERE id_user = ? AND status = 'awaiting_payment'
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
 
-	return orders, nil
+	return scanOrderRow(r.db.QueryRowContext(ctx, query, telegramID))
 }
 
 // GetAvailableQuantityForUser calculates available perfume quantity for user
-func (r *OrderRepository) GetAvailableQuantityForUser(telegramID int64) (int, error) {
+func (r *OrderRepository) GetAvailableQuantityForUser(ctx context.Context, telegramID int64) (int, error) {
 	query := `
 		SELECT 
 			COALESCE(SUM(
-				CASE 
-					WHEN quantity IS NULL THEN 0
-					ELSE quantity - (
-						CASE 
-							WHEN parfumes IS NULL OR parfumes = '' THEN 0
-							ELSE (LENGTH(parfumes) - LENGTH(REPLACE(parfumes, ':', '')))/1
-						END
-					)
-				END
+				COALESCE(o.quantity, 0) - COALESCE((
+					SELECT SUM(oi.quantity) FROM order_items oi WHERE oi.order_id = o.id
+				), 0)
 			), 0) as available
-		FROM orders 
-		WHERE id_user = ? AND checks = 0 AND quantity > 0
+		FROM orders o
+		WHERE o.id_user = ? AND o.checks = 0 AND o.quantity > 0 AND o.status NOT IN ('cancelled', 'awaiting_payment')
 	`
 
 	var available int
-	err := r.db.QueryRow(query, telegramID).Scan(&available)
+	err := r.db.QueryRowContext(ctx, query, telegramID).Scan(&available)
 	if err != nil {
 		return 0, err
 	}
@@ -840,165 +1005,77 @@ func (r *OrderRepository) GetAvailableQuantityForUser(telegramID int64) (int, er
 	return available, nil
 }
 
-// UpdatePerfumeSelection updates the parfumes field for an order
-func (r *OrderRepository) UpdatePerfumeSelection(orderID int64, parfumes string) error {
+// UpdatePerfumeSelection updates the parfumes field for an order and moves it on to perfume_selected
+func (r *OrderRepository) UpdatePerfumeSelection(ctx context.Context, orderID int64, parfumes string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE orders 
 		SET parfumes = ?, updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query, parfumes, orderID)
-	return err
+	if _, err := tx.ExecContext(ctx, query, parfumes, orderID); err != nil {
+		return err
+	}
+
+	if err := advanceOrderStatus(ctx, tx, orderID, domain.OrderStatusPerfumeSelected); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // GetOrderWithPerfumeSelection gets an order that has perfume selection but no client info yet
-func (r *OrderRepository) GetOrderWithPerfumeSelection(telegramID int64) (*domain.Order, error) {
+func (r *OrderRepository) GetOrderWithPerfumeSelection(ctx context.Context, telegramID int64) (*domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE id_user = ? AND checks = 0 AND parfumes IS NOT NULL AND parfumes != ''
 		ORDER BY updated_at DESC
 		LIMIT 1
 	`
 
-	row := r.db.QueryRow(query, telegramID)
-
-	var order domain.Order
-	var createdAt, updatedAt time.Time
-	var quantity sql.NullInt64
-	var parfumes, fio, address, dateRegister sql.NullString
-
-	err := row.Scan(
-		&order.ID,
-		&order.IDUser,
-		&order.UserName,
-		&quantity,
-		&parfumes,
-		&fio,
-		&order.Contact,
-		&address,
-		&dateRegister,
-		&order.DataPay,
-		&order.Checks,
-		&createdAt,
-		&updatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// Handle nullable fields
-	if quantity.Valid {
-		qty := int(quantity.Int64)
-		order.Quantity = &qty
-	}
-	if parfumes.Valid {
-		order.Parfumes = parfumes.String
-	}
-	if fio.Valid {
-		order.FIO = fio.String
-	}
-	if address.Valid {
-		order.Address = address.String
-	}
-	if dateRegister.Valid {
-		order.DateRegister = dateRegister.String
-	}
-
-	order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-	order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
-
-	return &order, nil
+	return scanOrderRow(r.db.QueryRowContext(ctx, query, telegramID))
 }
 
 // UpdateClientInfo updates order with client information
-func (r *OrderRepository) UpdateClientInfo(orderID int64, fio, contact, address string) error {
+func (r *OrderRepository) UpdateClientInfo(ctx context.Context, orderID int64, fio, contact, address string) error {
 	query := `
 		UPDATE orders 
 		SET fio = ?, contact = ?, address = ?, updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query, fio, contact, address, orderID)
+	_, err := r.db.ExecContext(ctx, query, fio, contact, address, orderID)
 	return err
 }
 
 // GetOrdersByUserWithSelection gets orders with perfume selections for a user
-func (r *OrderRepository) GetOrdersByUserWithSelection(telegramID int64) ([]domain.Order, error) {
+func (r *OrderRepository) GetOrdersByUserWithSelection(ctx context.Context, telegramID int64) ([]domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE id_user = ? AND checks = 0 AND parfumes IS NOT NULL AND parfumes != ''
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, telegramID)
+	rows, err := r.db.QueryContext(ctx, query, telegramID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var orders []domain.Order
-
-	for rows.Next() {
-		var order domain.Order
-		var createdAt, updatedAt time.Time
-		var quantity sql.NullInt64
-		var parfumes, fio, address, dateRegister sql.NullString
-
-		err := rows.Scan(
-			&order.ID,
-			&order.IDUser,
-			&order.UserName,
-			&quantity,
-			&parfumes,
-			&fio,
-			&order.Contact,
-			&address,
-			&dateRegister,
-			&order.DataPay,
-			&order.Checks,
-			&createdAt,
-			&updatedAt,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		// Handle nullable fields
-		if quantity.Valid {
-			qty := int(quantity.Int64)
-			order.Quantity = &qty
-		}
-		if parfumes.Valid {
-			order.Parfumes = parfumes.String
-		}
-		if fio.Valid {
-			order.FIO = fio.String
-		}
-		if address.Valid {
-			order.Address = address.String
-		}
-		if dateRegister.Valid {
-			order.DateRegister = dateRegister.String
-		}
-
-		order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-		order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
-
-		orders = append(orders, order)
-	}
 
-	return orders, nil
+	return scanOrders(rows)
 }
 
 // GetUncompletedOrdersWithPerfumes gets orders that have perfume selection but incomplete client info
-func (r *OrderRepository) GetUncompletedOrdersWithPerfumes() ([]domain.Order, error) {
+func (r *OrderRepository) GetUncompletedOrdersWithPerfumes(ctx context.Context) ([]domain.Order, error) {
 	query := `
-		SELECT id, id_user, userName, quantity, parfumes, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at
+		SELECT ` + orderColumns + `
 		FROM orders 
 		WHERE checks = 0 
 		AND parfumes IS NOT NULL 
@@ -1007,96 +1084,196 @@ func (r *OrderRepository) GetUncompletedOrdersWithPerfumes() ([]domain.Order, er
 		ORDER BY updated_at DESC
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var orders []domain.Order
+	return scanOrders(rows)
+}
 
-	for rows.Next() {
-		var order domain.Order
-		var createdAt, updatedAt time.Time
-		var quantity sql.NullInt64
-		var parfumes, fio, address, dateRegister sql.NullString
-
-		err := rows.Scan(
-			&order.ID,
-			&order.IDUser,
-			&order.UserName,
-			&quantity,
-			&parfumes,
-			&fio,
-			&order.Contact,
-			&address,
-			&dateRegister,
-			&order.DataPay,
-			&order.Checks,
-			&createdAt,
-			&updatedAt,
-		)
+// Granularities accepted by GetPeriodStats
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
 
-		if err != nil {
-			return nil, err
-		}
+// periodStartExpressions maps each granularity to the SQL that turns a day into
+// the first day of its period (weeks start on Monday)
+var periodStartExpressions = map[string]string{
+	GranularityDay:   "d.day",
+	GranularityWeek:  "DATE(d.day, '-6 days', 'weekday 1')",
+	GranularityMonth: "DATE(d.day, 'start of month')",
+}
 
-		// Handle nullable fields
-		if quantity.Valid {
-			qty := int(quantity.Int64)
-			order.Quantity = &qty
-		}
-		if parfumes.Valid {
-			order.Parfumes = parfumes.String
-		}
-		if fio.Valid {
-			order.FIO = fio.String
-		}
-		if address.Valid {
-			order.Address = address.String
-		}
-		if dateRegister.Valid {
-			order.DateRegister = dateRegister.String
-		}
+// dailyStatsFallback computes the same rows as daily_stats_view for databases without it
+const dailyStatsFallback = `(
+	SELECT
+		DATE(created_at) as order_date,
+		COUNT(*) as total_orders,
+		SUM(quantity) as total_quantity,
+		COUNT(CASE WHEN checks = 1 THEN 1 END) as checked_orders,
+		COUNT(CASE WHEN checks = 0 THEN 1 END) as unchecked_orders
+	FROM orders
+	GROUP BY DATE(created_at)
+)`
+
+// IsValidGranularity reports whether granularity is accepted by GetPeriodStats
+func IsValidGranularity(granularity string) bool {
+	_, ok := periodStartExpressions[granularity]
+	return ok
+}
 
-		order.CreatedAt = createdAt.Format("2006-01-02 15:04:05")
-		order.UpdatedAt = updatedAt.Format("2006-01-02 15:04:05")
+// GetPeriodStats returns order stats for the last days days (today included), oldest
+// first, grouped by granularity. Periods without orders are returned with zeros.
+// Revenue is left for the caller, which knows the price.
+func (r *OrderRepository) GetPeriodStats(ctx context.Context, days int, granularity string) ([]domain.OrderPeriodStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
 
-		orders = append(orders, order)
+	periodStart, ok := periodStartExpressions[granularity]
+	if !ok {
+		return nil, fmt.Errorf("unknown granularity %q", granularity)
 	}
 
-	return orders, nil
-}
+	source := "daily_stats_view"
+	var views int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'view' AND name = 'daily_stats_view'`).Scan(&views)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up daily_stats_view: %w", err)
+	}
+	if views == 0 {
+		source = dailyStatsFallback
+	}
 
-// GetPendingOrdersCount returns count of pending orders
-func (r *OrderRepository) GetPendingOrdersCount() (int, error) {
-	var count int
-	query := "SELECT COUNT(*) FROM orders WHERE checks = 0"
-	err := r.db.QueryRow(query).Scan(&count)
-	return count, err
-}
+	// Every day of the range joins its stats row, so days without orders still show up
+	query := `
+		WITH RECURSIVE days(day) AS (
+			SELECT DATE('now', ?)
+			UNION ALL
+			SELECT DATE(day, '+1 day') FROM days WHERE day < DATE('now')
+		)
+		SELECT
+			` + periodStart + ` AS period,
+			COALESCE(SUM(s.total_orders), 0),
+			COALESCE(SUM(s.total_quantity), 0),
+			COALESCE(SUM(s.checked_orders), 0),
+			COALESCE(SUM(s.unchecked_orders), 0)
+		FROM days d
+		LEFT JOIN ` + source + ` s ON s.order_date = d.day
+		GROUP BY period
+		ORDER BY period
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, fmt.Sprintf("-%d days", days-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query period stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []domain.OrderPeriodStats
+	for rows.Next() {
+		var period domain.OrderPeriodStats
+		err := rows.Scan(&period.Date, &period.TotalOrders, &period.TotalQuantity, &period.CheckedOrders, &period.UncheckedOrders)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan period stats: %w", err)
+		}
+		stats = append(stats, period)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetConversionTimings measures how long users take from registering (just) to their
+// first order, and from paying an order to completing its delivery address. Address
+// completion is taken from updated_at of completed orders, as that is when
+// UpdateClientInfoWithCoordinates marks them checked.
+func (r *OrderRepository) GetConversionTimings(ctx context.Context) (*domain.ConversionTimings, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
+	registrationToPurchase, err := r.queryDurations(ctx, `
+		SELECT strftime('%s', MIN(o.created_at)) - strftime('%s', j.created_at)
+		FROM just j
+		JOIN orders o ON o.id_user = j.id_user
+		GROUP BY j.id_user
+		HAVING MIN(o.created_at) >= j.created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registration timings: %w", err)
+	}
+
+	paymentToAddress, err := r.queryDurations(ctx, `
+		SELECT strftime('%s', updated_at) - strftime('%s', created_at)
+		FROM orders
+		WHERE checks = 1 AND address IS NOT NULL AND address != ''
+		AND updated_at >= created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get address timings: %w", err)
+	}
+
+	return &domain.ConversionTimings{
+		RegistrationToPurchase: domain.NewDurationStats(registrationToPurchase),
+		PaymentToAddress:       domain.NewDurationStats(paymentToAddress),
+	}, nil
+}
+
+// queryDurations reads a single column of durations in seconds
+func (r *OrderRepository) queryDurations(ctx context.Context, query string) ([]float64, error) {
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seconds []float64
+	for rows.Next() {
+		var value sql.NullFloat64
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		if value.Valid {
+			seconds = append(seconds, value.Float64)
+		}
+	}
+	return seconds, rows.Err()
+}
+
+// GetPendingOrdersCount returns count of pending orders
+func (r *OrderRepository) GetPendingOrdersCount(ctx context.Context) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM orders WHERE checks = 0"
+	err := r.db.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
 
 // GetCompletedOrdersCount returns count of completed orders
-func (r *OrderRepository) GetCompletedOrdersCount() (int, error) {
+func (r *OrderRepository) GetCompletedOrdersCount(ctx context.Context) (int, error) {
 	var count int
 	query := "SELECT COUNT(*) FROM orders WHERE checks = 1"
-	err := r.db.QueryRow(query).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query).Scan(&count)
 	return count, err
 }
 
 // GetOrdersWithPerfumeSelectionCount returns count of orders that have perfume selections
-func (r *OrderRepository) GetOrdersWithPerfumeSelectionCount() (int, error) {
+func (r *OrderRepository) GetOrdersWithPerfumeSelectionCount(ctx context.Context) (int, error) {
 	var count int
 	query := "SELECT COUNT(*) FROM orders WHERE parfumes IS NOT NULL AND parfumes != ''"
-	err := r.db.QueryRow(query).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query).Scan(&count)
 	return count, err
 }
 
 // GetTotalQuantityOrdered returns total quantity of all orders
-func (r *OrderRepository) GetTotalQuantityOrdered() (int, error) {
+func (r *OrderRepository) GetTotalQuantityOrdered(ctx context.Context) (int, error) {
 	var total sql.NullInt64
 	query := "SELECT SUM(quantity) FROM orders WHERE quantity IS NOT NULL"
-	err := r.db.QueryRow(query).Scan(&total)
+	err := r.db.QueryRowContext(ctx, query).Scan(&total)
 	if err != nil {
 		return 0, err
 	}
@@ -1107,26 +1284,679 @@ func (r *OrderRepository) GetTotalQuantityOrdered() (int, error) {
 	return 0, nil
 }
 
-// UpdateClientInfoWithCoordinates updates order with client info and optional coordinates
-func (r *OrderRepository) UpdateClientInfoWithCoordinates(orderID int64, fio, contact, address string) error {
+// UpdateClientInfoWithCoordinates updates order with client info and the delivery
+// coordinates (nil when the map pin wasn't set) and moves it on to address_provided,
+// which sets checks = true
+func (r *OrderRepository) UpdateClientInfoWithCoordinates(ctx context.Context, orderID int64, fio, contact, address string, latitude, longitude *float64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE orders 
-		SET fio = ?, contact = ?, address = ?, checks = true,  updated_at = CURRENT_TIMESTAMP
+		SET fio = ?, contact = ?, address = ?, latitude = ?, longitude = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query, fio, contact, address, orderID)
-	return err
+	if _, err := tx.ExecContext(ctx, query, fio, contact, address, latitude, longitude, orderID); err != nil {
+		return err
+	}
+
+	if err := advanceOrderStatus(ctx, tx, orderID, domain.OrderStatusAddressProvided); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Add coordinates to existing order
-func (r *OrderRepository) UpdateOrderCoordinates(orderID int64, latitude, longitude float64) error {
+func (r *OrderRepository) UpdateOrderCoordinates(ctx context.Context, orderID int64, latitude, longitude float64) error {
 	query := `
 		UPDATE orders 
 		SET latitude = ?, longitude = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
 
-	_, err := r.db.Exec(query, latitude, longitude, orderID)
+	_, err := r.db.ExecContext(ctx, query, latitude, longitude, orderID)
 	return err
 }
+
+// AwardPrize sets the prize an order won on the wheel. A prize with a prize_inventory row
+// is taken from its stock in the same transaction; once the stock is used up the order
+// gets fallback instead. Only an order without a prize gets one, so of two spins racing
+// for the same order one loses: it gets the prize the winner set and false. It returns
+// the prize the order has and whether this call awarded it.
+func (r *OrderRepository) AwardPrize(ctx context.Context, orderID int64, prize, fallback string) (string, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the write lock, so the loser of a race waits and then finds the
+	// prize set
+	result, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET gift = ?, prize_awarded_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND (gift IS NULL OR gift = '' OR gift = 'null')
+	`, prize, orderID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to update order prize: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if updated == 0 {
+		var gift sql.NullString
+		err := tx.QueryRowContext(ctx, `SELECT gift FROM orders WHERE id = ?`, orderID).Scan(&gift)
+		if err == sql.ErrNoRows {
+			return "", false, fmt.Errorf("no order found with id %d", orderID)
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to get order prize: %w", err)
+		}
+		return gift.String, false, nil
+	}
+
+	awarded, err := takePrizeStock(ctx, tx, prize, fallback)
+	if err != nil {
+		return "", false, err
+	}
+	if awarded != prize {
+		_, err := tx.ExecContext(ctx, `UPDATE orders SET gift = ? WHERE id = ?`, awarded, orderID)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to update order prize: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", false, fmt.Errorf("failed to commit prize: %w", err)
+	}
+
+	return awarded, true, nil
+}
+
+// takePrizeStock takes one prize out of its prize_inventory stock. A prize without a
+// prize_inventory row isn't limited. Once the stock is used up fallback is given instead;
+// it returns the prize the order gets.
+func takePrizeStock(ctx context.Context, tx *sql.Tx, prize, fallback string) (string, error) {
+	result, err := tx.ExecContext(ctx, `
+		UPDATE prize_inventory
+		SET awarded = awarded + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE prize = ? AND awarded < total
+	`, prize)
+	if err != nil {
+		return "", fmt.Errorf("failed to take prize from stock: %w", err)
+	}
+	taken, err := result.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("failed to take prize from stock: %w", err)
+	}
+	if taken == 1 {
+		return prize, nil
+	}
+
+	var limited bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM prize_inventory WHERE prize = ?)`, prize).Scan(&limited)
+	if err != nil {
+		return "", fmt.Errorf("failed to get prize inventory: %w", err)
+	}
+	if limited {
+		return fallback, nil
+	}
+	return prize, nil
+}
+
+// returnPrizeStock puts a limited prize an order no longer has back into its stock
+func returnPrizeStock(ctx context.Context, tx *sql.Tx, prize string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE prize_inventory
+		SET awarded = awarded - 1, updated_at = CURRENT_TIMESTAMP
+		WHERE prize = ? AND awarded > 0
+	`, prize)
+	if err != nil {
+		return fmt.Errorf("failed to return prize to stock: %w", err)
+	}
+	return nil
+}
+
+// GetPrizeInventory lists the stock of every limited prize
+func (r *OrderRepository) GetPrizeInventory(ctx context.Context) ([]domain.PrizeInventory, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT prize, total, awarded, updated_at
+		FROM prize_inventory
+		ORDER BY prize
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prize inventory: %w", err)
+	}
+	defer rows.Close()
+
+	inventory := []domain.PrizeInventory{}
+	for rows.Next() {
+		var item domain.PrizeInventory
+		if err := rows.Scan(&item.Prize, &item.Total, &item.Awarded, &item.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan prize inventory: %w", err)
+		}
+		inventory = append(inventory, item)
+	}
+
+	return inventory, rows.Err()
+}
+
+// TopUpPrizeInventory adds amount to the stock of prize, which makes the prize limited if
+// it wasn't, and returns the new stock
+func (r *OrderRepository) TopUpPrizeInventory(ctx context.Context, prize string, amount int) (*domain.PrizeInventory, error) {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO prize_inventory (prize, total, awarded, updated_at)
+		VALUES (?, ?, 0, CURRENT_TIMESTAMP)
+		ON CONFLICT(prize) DO UPDATE SET total = total + excluded.total, updated_at = CURRENT_TIMESTAMP
+	`, prize, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to top up prize inventory: %w", err)
+	}
+
+	var item domain.PrizeInventory
+	err = r.db.QueryRowContext(ctx, `
+		SELECT prize, total, awarded, updated_at
+		FROM prize_inventory
+		WHERE prize = ?
+	`, prize).Scan(&item.Prize, &item.Total, &item.Awarded, &item.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prize inventory: %w", err)
+	}
+
+	return &item, nil
+}
+
+// RecordSpin adds a spin to the spins table. An order has one row there, so the award of
+// a prize an admin assigns over an earlier one replaces it.
+func (r *OrderRepository) RecordSpin(ctx context.Context, spin *domain.Spin) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO spins (order_id, telegram_id, order_sequence, prize, drawn_prize, sector, rules_version, source, remote_addr, user_agent)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(order_id) DO UPDATE SET
+			prize = excluded.prize, drawn_prize = excluded.drawn_prize, sector = excluded.sector,
+			rules_version = excluded.rules_version, source = excluded.source,
+			remote_addr = excluded.remote_addr, user_agent = excluded.user_agent, created_at = CURRENT_TIMESTAMP
+	`, spin.OrderID, spin.TelegramID, spin.OrderSequence, spin.Prize, spin.DrawnPrize, spin.Sector, spin.RulesVersion,
+		spin.Source, spin.RemoteAddr, spin.UserAgent)
+	if err != nil {
+		return fmt.Errorf("failed to record spin: %w", err)
+	}
+	return nil
+}
+
+// spinFilterWhere is the WHERE clause of filter for the spins table
+func spinFilterWhere(filter domain.SpinFilter) (string, []interface{}) {
+	where := " WHERE 1 = 1"
+	var args []interface{}
+	if filter.Prize != "" {
+		where += " AND prize = ?"
+		args = append(args, filter.Prize)
+	}
+	if filter.From != "" {
+		where += " AND DATE(created_at) >= ?"
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		where += " AND DATE(created_at) <= ?"
+		args = append(args, filter.To)
+	}
+	return where, args
+}
+
+// GetSpins lists the spins that match filter, newest first
+func (r *OrderRepository) GetSpins(ctx context.Context, filter domain.SpinFilter) ([]domain.Spin, error) {
+	where, args := spinFilterWhere(filter)
+	query := `
+		SELECT id, order_id, telegram_id, order_sequence, prize, drawn_prize, sector, rules_version, source, remote_addr, user_agent, created_at
+		FROM spins` + where + `
+		ORDER BY created_at DESC, id DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spins: %w", err)
+	}
+	defer rows.Close()
+
+	spins := []domain.Spin{}
+	for rows.Next() {
+		var spin domain.Spin
+		err := rows.Scan(&spin.ID, &spin.OrderID, &spin.TelegramID, &spin.OrderSequence, &spin.Prize,
+			&spin.DrawnPrize, &spin.Sector, &spin.RulesVersion, &spin.Source, &spin.RemoteAddr, &spin.UserAgent, &spin.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan spin: %w", err)
+		}
+		spins = append(spins, spin)
+	}
+
+	return spins, rows.Err()
+}
+
+// CountSpinsByPrize counts the spins that match filter by the prize they awarded; the
+// limit of filter doesn't apply
+func (r *OrderRepository) CountSpinsByPrize(ctx context.Context, filter domain.SpinFilter) (map[string]int, error) {
+	where, args := spinFilterWhere(filter)
+	rows, err := r.db.QueryContext(ctx, `SELECT prize, COUNT(*) FROM spins`+where+` GROUP BY prize`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count spins: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int)
+	for rows.Next() {
+		var prize string
+		var count int
+		if err := rows.Scan(&prize, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan spin count: %w", err)
+		}
+		totals[prize] = count
+	}
+
+	return totals, rows.Err()
+}
+
+// GetSpunOrderIDs returns the orders of a user that the spins table has a spin for
+func (r *OrderRepository) GetSpunOrderIDs(ctx context.Context, telegramID int64) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT order_id FROM spins WHERE telegram_id = ? ORDER BY order_id`, telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spun orders: %w", err)
+	}
+	defer rows.Close()
+
+	orderIDs := []int64{}
+	for rows.Next() {
+		var orderID int64
+		if err := rows.Scan(&orderID); err != nil {
+			return nil, fmt.Errorf("failed to scan spun order: %w", err)
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+
+	return orderIDs, rows.Err()
+}
+
+// ErrNoPrizeRules is returned when no prize rules have been saved yet
+var ErrNoPrizeRules = errors.New("no prize rules saved")
+
+// GetActivePrizeRules returns the newest prize rules, ErrNoPrizeRules if none were saved
+func (r *OrderRepository) GetActivePrizeRules(ctx context.Context) (*domain.PrizeRules, error) {
+	return r.queryPrizeRules(ctx, `
+		SELECT version, money_interval, diamonds_per_1000, ml30_interval, diamond_positions, created_at
+		FROM prize_rules
+		ORDER BY version DESC
+		LIMIT 1
+	`)
+}
+
+// GetPrizeRules returns a version of the prize rules, ErrNoPrizeRules if there is no such version
+func (r *OrderRepository) GetPrizeRules(ctx context.Context, version int64) (*domain.PrizeRules, error) {
+	return r.queryPrizeRules(ctx, `
+		SELECT version, money_interval, diamonds_per_1000, ml30_interval, diamond_positions, created_at
+		FROM prize_rules
+		WHERE version = ?
+	`, version)
+}
+
+func (r *OrderRepository) queryPrizeRules(ctx context.Context, query string, args ...interface{}) (*domain.PrizeRules, error) {
+	var rules domain.PrizeRules
+	var positions string
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&rules.Version, &rules.MoneyInterval,
+		&rules.DiamondsPer1000, &rules.ML30Interval, &positions, &rules.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoPrizeRules
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prize rules: %w", err)
+	}
+	if err := json.Unmarshal([]byte(positions), &rules.DiamondPositions); err != nil {
+		return nil, fmt.Errorf("failed to decode diamond positions of prize rules %d: %w", rules.Version, err)
+	}
+
+	return &rules, nil
+}
+
+// SavePrizeRules stores rules as a new version, which makes them the active rules, and
+// returns them with their version
+func (r *OrderRepository) SavePrizeRules(ctx context.Context, rules *domain.PrizeRules) (*domain.PrizeRules, error) {
+	positions, err := json.Marshal(rules.DiamondPositions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode diamond positions: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO prize_rules (money_interval, diamonds_per_1000, ml30_interval, diamond_positions)
+		VALUES (?, ?, ?, ?)
+	`, rules.MoneyInterval, rules.DiamondsPer1000, rules.ML30Interval, string(positions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save prize rules: %w", err)
+	}
+	version, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prize rules version: %w", err)
+	}
+
+	return r.GetPrizeRules(ctx, version)
+}
+
+// unclaimedPrizeQuery selects the prize orders that wait for a delivery address and
+// haven't expired
+const unclaimedPrizeQuery = `
+	SELECT ` + orderColumns + `
+	FROM orders
+	WHERE gift IS NOT NULL AND gift != '' AND gift != 'null'
+	  AND prize_claimed_at IS NULL AND prize_expired_at IS NULL AND prize_awarded_at IS NOT NULL`
+
+// GetPrizesToRemind returns the unclaimed prizes awarded before awardedBefore whose winner
+// wasn't reminded yet, oldest first
+func (r *OrderRepository) GetPrizesToRemind(ctx context.Context, awardedBefore time.Time) ([]domain.Order, error) {
+	query := unclaimedPrizeQuery + `
+		AND prize_awarded_at <= ? AND prize_reminded_at IS NULL
+		ORDER BY prize_awarded_at ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, awardedBefore.UTC().Format(time.DateTime))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prizes to remind: %w", err)
+	}
+
+	return scanOrders(rows)
+}
+
+// GetPrizesToExpire returns the unclaimed prizes awarded before awardedBefore, oldest
+// first. Unless remindedBefore is zero only prizes whose winner was reminded before then
+// are returned, so nobody loses a prize without a reminder.
+func (r *OrderRepository) GetPrizesToExpire(ctx context.Context, awardedBefore, remindedBefore time.Time) ([]domain.Order, error) {
+	query := unclaimedPrizeQuery + `
+		AND prize_awarded_at <= ?`
+	args := []interface{}{awardedBefore.UTC().Format(time.DateTime)}
+	if !remindedBefore.IsZero() {
+		query += " AND prize_reminded_at <= ?"
+		args = append(args, remindedBefore.UTC().Format(time.DateTime))
+	}
+	query += " ORDER BY prize_awarded_at ASC, id ASC"
+
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prizes to expire: %w", err)
+	}
+
+	return scanOrders(rows)
+}
+
+// MarkPrizeReminded records that the winner of an order was reminded to claim the prize
+func (r *OrderRepository) MarkPrizeReminded(ctx context.Context, orderID int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE orders SET prize_reminded_at = CURRENT_TIMESTAMP WHERE id = ?`, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to mark prize reminded: %w", err)
+	}
+	return nil
+}
+
+// ExpirePrize marks the prize of an order expired. The gift stays on the order. It
+// returns false when the prize was claimed or expired in the meantime.
+func (r *OrderRepository) ExpirePrize(ctx context.Context, orderID int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE orders
+		SET prize_expired_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND prize_claimed_at IS NULL AND prize_expired_at IS NULL
+		  AND gift IS NOT NULL AND gift != '' AND gift != 'null'
+	`, orderID)
+	if err != nil {
+		return false, fmt.Errorf("failed to expire prize: %w", err)
+	}
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return expired == 1, nil
+}
+
+// ClaimPrize records that the winner of an order sent the delivery address for its prize.
+// A repeated claim keeps the first time. It returns false when the order has no prize to
+// claim: none was awarded, it was claimed already or it expired in the meantime.
+func (r *OrderRepository) ClaimPrize(ctx context.Context, orderID int64) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	claimed, err := claimPrize(ctx, tx, orderID)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit prize claim: %w", err)
+	}
+	return claimed, nil
+}
+
+// ClaimPrizeWithAddress claims the prize of an order and saves the delivery address the
+// winner sent for it, in one transaction. The address is only written while the prize can
+// be claimed: a resent form updates it and keeps the first claim time, but an order whose
+// prize expired or that has none is left untouched and gives false.
+func (r *OrderRepository) ClaimPrizeWithAddress(ctx context.Context, orderID int64, info domain.CheckoutInfo) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	claimed, err := claimPrize(ctx, tx, orderID)
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		var claimedBefore bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT prize_claimed_at IS NOT NULL AND prize_expired_at IS NULL FROM orders WHERE id = ?
+		`, orderID).Scan(&claimedBefore)
+		if err == sql.ErrNoRows || (err == nil && !claimedBefore) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to get prize claim: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE orders
+		SET fio = ?, contact = ?, address = ?, latitude = ?, longitude = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, info.FIO, info.Contact, info.Address, info.Latitude, info.Longitude, orderID)
+	if err != nil {
+		return false, fmt.Errorf("failed to save prize address: %w", err)
+	}
+
+	if err := advanceOrderStatus(ctx, tx, orderID, domain.OrderStatusAddressProvided); err != nil {
+		return false, fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit prize claim: %w", err)
+	}
+	return true, nil
+}
+
+// claimPrize sets the claim time of an order's prize within tx, see ClaimPrize
+func claimPrize(ctx context.Context, tx *sql.Tx, orderID int64) (bool, error) {
+	result, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET prize_claimed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND prize_claimed_at IS NULL AND prize_expired_at IS NULL
+		  AND gift IS NOT NULL AND gift != '' AND gift != 'null'
+	`, orderID)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim prize: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return claimed == 1, nil
+}
+
+// GetPrizeClaimStatistics counts the prizes of every kind by whether they were claimed,
+// are pending or expired
+func (r *OrderRepository) GetPrizeClaimStatistics(ctx context.Context) (map[string]domain.PrizeClaimCounts, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT gift,
+			SUM(CASE WHEN prize_claimed_at IS NOT NULL THEN 1 ELSE 0 END),
+			SUM(CASE WHEN prize_claimed_at IS NULL AND prize_expired_at IS NULL THEN 1 ELSE 0 END),
+			SUM(CASE WHEN prize_claimed_at IS NULL AND prize_expired_at IS NOT NULL THEN 1 ELSE 0 END)
+		FROM orders
+		WHERE gift IS NOT NULL AND gift != '' AND gift != 'null'
+		GROUP BY gift
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prize claim statistics: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[string]domain.PrizeClaimCounts)
+	for rows.Next() {
+		var gift string
+		var counts domain.PrizeClaimCounts
+		if err := rows.Scan(&gift, &counts.Claimed, &counts.Pending, &counts.Expired); err != nil {
+			return nil, fmt.Errorf("failed to scan prize claim statistics: %w", err)
+		}
+		stats[gift] = counts
+	}
+
+	return stats, rows.Err()
+}
+
+// Checkout completes a user's paid orders with the cart and the address form in one
+// transaction. The cart is spread over the orders with free quantity, oldest first; the
+// tracked stock of its perfumes is taken, and every order it lands in gets its items, its
+// perfume selection and the client info. Nothing is written when the orders can't hold
+// the cart (*InsufficientQuantityError) or a perfume runs short (*InsufficientStockError).
+func (r *OrderRepository) Checkout(ctx context.Context, telegramID int64, cart []domain.CartItem, info domain.CheckoutInfo) ([]domain.CheckoutAllocation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin checkout: %w", err)
+	}
+	defer tx.Rollback()
+
+	orders, err := queryUnpaidOrders(ctx, tx, telegramID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unpaid orders: %w", err)
+	}
+
+	allocations, unallocated, err := allocateCart(ctx, tx, orders, cart)
+	if err != nil {
+		return nil, err
+	}
+	if unallocated > 0 || len(allocations) == 0 {
+		requested := domain.CartQuantity(cart)
+		return nil, &InsufficientQuantityError{Available: requested - unallocated, Requested: requested}
+	}
+
+	var items []domain.OrderItem
+	for _, allocation := range allocations {
+		items = append(items, allocation.Items...)
+	}
+	if err := decrementStock(ctx, tx, orderItemStock(items)); err != nil {
+		return nil, err
+	}
+
+	for _, allocation := range allocations {
+		orderID := allocation.Order.ID
+		if err := insertOrderItems(ctx, tx, orderID, allocation.Items); err != nil {
+			return nil, err
+		}
+
+		// Keep the legacy string in sync (format: "name: quantity, name: quantity")
+		selected, err := queryOrderItems(ctx, tx, orderID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE orders
+			SET parfumes = ?, fio = ?, contact = ?, address = ?, latitude = ?, longitude = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, domain.FormatOrderItems(selected), info.FIO, info.Contact, info.Address, info.Latitude, info.Longitude, orderID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update order %d: %w", orderID, err)
+		}
+
+		if err := advanceOrderStatus(ctx, tx, orderID, domain.OrderStatusPerfumeSelected); err != nil {
+			return nil, fmt.Errorf("failed to update order %d status: %w", orderID, err)
+		}
+		if err := advanceOrderStatus(ctx, tx, orderID, domain.OrderStatusAddressProvided); err != nil {
+			return nil, fmt.Errorf("failed to update order %d status: %w", orderID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit checkout: %w", err)
+	}
+
+	return allocations, nil
+}
+
+// allocateCart spreads cart items over orders with free quantity, oldest order first.
+// It also returns the quantity that didn't fit into any order.
+func allocateCart(ctx context.Context, tx *sql.Tx, orders []domain.Order, cart []domain.CartItem) ([]domain.CheckoutAllocation, int, error) {
+	pending := make([]domain.CartItem, len(cart))
+	copy(pending, cart)
+
+	var allocations []domain.CheckoutAllocation
+	next := 0
+
+	// Orders come newest first
+	for i := len(orders) - 1; i >= 0 && next < len(pending); i-- {
+		order := orders[i]
+		if order.Quantity == nil {
+			continue
+		}
+
+		var used int
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(quantity), 0) FROM order_items WHERE order_id = ?`, order.ID).Scan(&used)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to sum order items: %w", err)
+		}
+
+		free := *order.Quantity - used
+		if free <= 0 {
+			continue
+		}
+
+		allocation := domain.CheckoutAllocation{Order: order}
+		for free > 0 && next < len(pending) {
+			take := min(free, pending[next].Quantity)
+			allocation.Items = append(allocation.Items, pending[next].ToOrderItem(order.ID, take))
+			pending[next].Quantity -= take
+			free -= take
+			if pending[next].Quantity == 0 {
+				next++
+			}
+		}
+		allocations = append(allocations, allocation)
+	}
+
+	unallocated := 0
+	for ; next < len(pending); next++ {
+		unallocated += pending[next].Quantity
+	}
+
+	return allocations, unallocated, nil
+}
@@ -0,0 +1,674 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+)
+
+// insertTimingOrder adds an order created and last updated at the given times
+func insertTimingOrder(t *testing.T, db *sql.DB, userID int64, checks bool, address, createdAt, updatedAt string) {
+	t.Helper()
+
+	_, err := db.Exec(`
+		INSERT INTO orders (id_user, userName, quantity, parfumes, gift, fio, contact, address, dateRegister, dataPay, checks, created_at, updated_at)
+		VALUES (?, '', 1, '', '', '', '', ?, '', '', ?, ?, ?)
+	`, userID, address, checks, createdAt, updatedAt)
+	if err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+}
+
+func TestGetConversionTimings(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, user := range []struct {
+		id           int64
+		registeredAt string
+	}{
+		{101, "2026-03-01 10:00:00"},
+		{102, "2026-03-01 10:00:00"},
+		{103, "2026-03-01 10:00:00"},
+		{104, "2026-03-01 10:00:00"}, // never buys
+	} {
+		_, err := db.Exec(`INSERT INTO just (id_user, userName, dataRegistred, created_at) VALUES (?, 'user', '', ?)`,
+			user.id, user.registeredAt)
+		if err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+	}
+
+	// First purchases after 1h, 3h and 2h; only user 101's first order counts
+	insertTimingOrder(t, db, 101, true, "Алматы", "2026-03-01 11:00:00", "2026-03-01 11:10:00")
+	insertTimingOrder(t, db, 101, false, "", "2026-03-02 10:00:00", "2026-03-02 10:00:00")
+	insertTimingOrder(t, db, 102, true, "Астана", "2026-03-01 13:00:00", "2026-03-01 13:30:00")
+	insertTimingOrder(t, db, 103, true, "", "2026-03-01 12:00:00", "2026-03-01 12:05:00")
+
+	timings, err := NewOrderRepository(db).GetConversionTimings(context.Background())
+	if err != nil {
+		t.Fatalf("GetConversionTimings: %v", err)
+	}
+
+	wantPurchase := domain.DurationStats{Count: 3, AverageSeconds: 7200, MedianSeconds: 7200, MinSeconds: 3600, MaxSeconds: 10800}
+	if timings.RegistrationToPurchase != wantPurchase {
+		t.Errorf("registration to purchase = %+v, want %+v", timings.RegistrationToPurchase, wantPurchase)
+	}
+
+	// Paid orders with an address: 10 and 30 minutes
+	wantAddress := domain.DurationStats{Count: 2, AverageSeconds: 1200, MedianSeconds: 1200, MinSeconds: 600, MaxSeconds: 1800}
+	if timings.PaymentToAddress != wantAddress {
+		t.Errorf("payment to address = %+v, want %+v", timings.PaymentToAddress, wantAddress)
+	}
+}
+
+func TestGetConversionTimingsWithoutData(t *testing.T) {
+	db := newTestDB(t)
+
+	timings, err := NewOrderRepository(db).GetConversionTimings(context.Background())
+	if err != nil {
+		t.Fatalf("GetConversionTimings: %v", err)
+	}
+	if *timings != (domain.ConversionTimings{}) {
+		t.Errorf("timings = %+v, want zeros", timings)
+	}
+}
+
+func TestOrderWithNullOptionalsReadsTheSame(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewOrderRepository(db)
+	ctx := context.Background()
+
+	// Every nullable column left NULL
+	result, err := db.Exec(`
+		INSERT INTO orders (id_user, userName, contact, dataPay, created_at, updated_at)
+		VALUES (6001, 'nulls', '+77010000000', '2026-03-05 09:00:00', '2026-03-05 09:00:00', '2026-03-05 09:00:00')
+	`)
+	if err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+	id, _ := result.LastInsertId()
+
+	want := domain.Order{
+		ID:        id,
+		IDUser:    6001,
+		UserName:  "nulls",
+		Contact:   "+77010000000",
+		DataPay:   "2026-03-05 09:00:00",
+		Status:    domain.OrderStatusPaid,
+		CreatedAt: time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC),
+	}
+
+	byID, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !reflect.DeepEqual(*byID, want) {
+		t.Errorf("GetByID = %+v, want %+v", *byID, want)
+	}
+
+	lists := map[string]func() ([]domain.Order, error){
+		"GetByUserID":             func() ([]domain.Order, error) { return repo.GetByUserID(ctx, 6001) },
+		"GetAll":                  func() ([]domain.Order, error) { return repo.GetAll(ctx) },
+		"GetOrdersByChecksStatus": func() ([]domain.Order, error) { return repo.GetOrdersByChecksStatus(ctx, false) },
+		"GetOrdersByUserName":     func() ([]domain.Order, error) { return repo.GetOrdersByUserName(ctx, "null") },
+		"GetOrdersByDateRange":    func() ([]domain.Order, error) { return repo.GetOrdersByDateRange(ctx, "2026-03-01", "2026-03-31") },
+		"GetUnpaidOrdersByUser":   func() ([]domain.Order, error) { return repo.GetUnpaidOrdersByUser(ctx, 6001) },
+		"List":                    func() ([]domain.Order, error) { return repo.List(ctx, domain.OrderFilter{}) },
+	}
+	for name, list := range lists {
+		orders, err := list()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(orders) != 1 || !reflect.DeepEqual(orders[0], want) {
+			t.Errorf("%s = %+v, want [%+v]", name, orders, want)
+		}
+	}
+}
+
+func TestOrderCoordinatesRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewOrderRepository(db)
+	ctx := context.Background()
+
+	order := &domain.Order{IDUser: 6101, UserName: "geo", Contact: "+77010000000", DataPay: "2026-03-05 09:00:00"}
+	if err := repo.Create(ctx, order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	latitude, longitude := 43.238949, 76.889709
+	if err := repo.UpdateClientInfoWithCoordinates(ctx, order.ID, "Айгерим", "+77011234567", "Алматы, Абая 1", &latitude, &longitude); err != nil {
+		t.Fatalf("UpdateClientInfoWithCoordinates: %v", err)
+	}
+
+	saved, err := repo.GetByID(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if saved.Latitude == nil || saved.Longitude == nil || *saved.Latitude != latitude || *saved.Longitude != longitude {
+		t.Errorf("coordinates = %v, %v; want %v, %v", saved.Latitude, saved.Longitude, latitude, longitude)
+	}
+	if saved.Address != "Алматы, Абая 1" || saved.Status != domain.OrderStatusAddressProvided {
+		t.Errorf("order = %+v, want the address saved", saved)
+	}
+
+	listed, err := repo.GetByUserID(ctx, 6101)
+	if err != nil || len(listed) != 1 || listed[0].Latitude == nil || *listed[0].Latitude != latitude {
+		t.Errorf("GetByUserID = %+v, %v; want the coordinates", listed, err)
+	}
+
+	if err := repo.UpdateOrderCoordinates(ctx, order.ID, 51.128207, 71.430420); err != nil {
+		t.Fatalf("UpdateOrderCoordinates: %v", err)
+	}
+	moved, err := repo.GetByID(ctx, order.ID)
+	if err != nil || *moved.Latitude != 51.128207 || *moved.Longitude != 71.430420 {
+		t.Errorf("moved coordinates = %v, %v, %v", moved.Latitude, moved.Longitude, err)
+	}
+
+	// An address without a point on the map clears the coordinates
+	if err := repo.UpdateClientInfoWithCoordinates(ctx, order.ID, "Айгерим", "+77011234567", "Астана", nil, nil); err != nil {
+		t.Fatalf("UpdateClientInfoWithCoordinates: %v", err)
+	}
+	cleared, err := repo.GetByID(ctx, order.ID)
+	if err != nil || cleared.Latitude != nil || cleared.Longitude != nil {
+		t.Errorf("coordinates = %v, %v, %v; want none", cleared.Latitude, cleared.Longitude, err)
+	}
+}
+
+func TestGetPrizeOrdersByUser(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewOrderRepository(db)
+	ctx := context.Background()
+
+	insert := func(userID int64, gift interface{}, createdAt string) int64 {
+		t.Helper()
+		result, err := db.Exec(`
+			INSERT INTO orders (id_user, userName, contact, dataPay, gift, created_at)
+			VALUES (?, 'u', '+77010000000', '', ?, ?)
+		`, userID, gift, createdAt)
+		if err != nil {
+			t.Fatalf("insert order: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return id
+	}
+
+	older := insert(6201, "parfum_10ml", "2026-03-01 10:00:00")
+	insert(6201, nil, "2026-03-02 10:00:00")
+	insert(6201, "", "2026-03-03 10:00:00")
+	insert(6201, "null", "2026-03-04 10:00:00")
+	newer := insert(6201, "diamond_ring", "2026-03-05 10:00:00")
+	insert(6202, "money", "2026-03-06 10:00:00")
+
+	orders, err := repo.GetPrizeOrdersByUser(ctx, 6201)
+	if err != nil {
+		t.Fatalf("GetPrizeOrdersByUser: %v", err)
+	}
+	if len(orders) != 2 || orders[0].ID != newer || orders[1].ID != older {
+		t.Fatalf("orders = %+v, want the two prizes newest first", orders)
+	}
+	if orders[0].Gift != "diamond_ring" || orders[1].Gift != "parfum_10ml" {
+		t.Errorf("gifts = %q, %q", orders[0].Gift, orders[1].Gift)
+	}
+
+	none, err := repo.GetPrizeOrdersByUser(ctx, 6203)
+	if err != nil || len(none) != 0 {
+		t.Errorf("prizes of a user without orders = %+v, %v; want none", none, err)
+	}
+}
+
+func TestRepositoriesReturnContextErrors(t *testing.T) {
+	db := newTestDB(t)
+	orders := NewOrderRepository(db)
+	perfumes := NewParfumeRepository(db)
+	clients := NewClientRepository(db)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	calls := map[string]func(ctx context.Context) error{
+		"OrderRepository.GetAll": func(ctx context.Context) error {
+			_, err := orders.GetAll(ctx)
+			return err
+		},
+		"OrderRepository.GetByID": func(ctx context.Context) error {
+			_, err := orders.GetByID(ctx, 1)
+			return err
+		},
+		"OrderRepository.List": func(ctx context.Context) error {
+			_, err := orders.List(ctx, domain.OrderFilter{})
+			return err
+		},
+		"OrderRepository.Create": func(ctx context.Context) error {
+			return orders.Create(ctx, &domain.Order{IDUser: 1, Contact: "+7"})
+		},
+		"ParfumeRepository.GetAll": func(ctx context.Context) error {
+			_, err := perfumes.GetAll(ctx, false)
+			return err
+		},
+		"ParfumeRepository.SearchByName": func(ctx context.Context) error {
+			_, err := perfumes.SearchByName(ctx, "Lumen")
+			return err
+		},
+		"ClientRepository.GetTotalSum": func(ctx context.Context) error {
+			_, err := clients.GetTotalSum(ctx)
+			return err
+		},
+	}
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{"cancelled", cancelled, context.Canceled},
+		{"past deadline", expired, context.DeadlineExceeded},
+	} {
+		for name, call := range calls {
+			start := time.Now()
+			err := call(tt.ctx)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s with a %s context = %v, want %v", name, tt.name, err, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("%s with a %s context took %v", name, tt.name, elapsed)
+			}
+		}
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM orders`).Scan(&count); err != nil || count != 0 {
+		t.Errorf("orders = %d, %v; want nothing created", count, err)
+	}
+}
+
+func TestOrderWithUnknownQuantityStaysUnpaid(t *testing.T) {
+	db := newTestDB(t)
+	clients := NewClientRepository(db)
+	orders := NewOrderRepository(db)
+	ctx := context.Background()
+	const userID = 420
+
+	// A payment and a contact-share order made after the bot lost the count, and one
+	// with a known count
+	paid, _, err := clients.RecordPayment(ctx, domain.PaymentEntry{
+		UserID: userID, UserName: "aigerim", Quantity: 0, Amount: 2499, QR: "qr-1", DatePay: "2026-03-01 10:00:00",
+	})
+	if err != nil {
+		t.Fatalf("RecordPayment: %v", err)
+	}
+	shared, err := clients.InsertOrder(ctx, domain.OrderEntry{UserID: userID, UserName: "aigerim", DatePay: "2026-03-01 11:00:00"})
+	if err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	counted, err := clients.InsertOrder(ctx, domain.OrderEntry{
+		UserID: userID, UserName: "aigerim", Quantity: sql.NullInt64{Int64: 2, Valid: true}, DatePay: "2026-03-01 12:00:00",
+	})
+	if err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+
+	unpaid, err := orders.GetUnpaidOrdersByUser(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUnpaidOrdersByUser: %v", err)
+	}
+	quantities := make(map[int64]*int)
+	for _, order := range unpaid {
+		quantities[order.ID] = order.Quantity
+	}
+	if len(unpaid) != 3 {
+		t.Fatalf("unpaid orders = %d, want all 3", len(unpaid))
+	}
+	if quantities[paid] != nil || quantities[shared] != nil {
+		t.Errorf("quantities of the orders without a count = %v, %v; want nil", quantities[paid], quantities[shared])
+	}
+	if q := quantities[counted]; q == nil || *q != 2 {
+		t.Errorf("quantity of the counted order = %v, want 2", q)
+	}
+
+	// Only the known count can be spent on perfumes
+	if available, err := orders.GetAvailableQuantityForUser(ctx, userID); err != nil || available != 2 {
+		t.Errorf("GetAvailableQuantityForUser = %d, %v; want 2", available, err)
+	}
+}
+
+func TestAwardPrizeRaceHasOneWinner(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewOrderRepository(db)
+	ctx := context.Background()
+
+	result, err := db.Exec(`INSERT INTO orders (id_user, userName, contact, dataPay, parfumes) VALUES (8101, 'u', '+77010000000', '', 'Baccarat Rouge: 1')`)
+	if err != nil {
+		t.Fatalf("insert order: %v", err)
+	}
+	orderID, _ := result.LastInsertId()
+
+	const spins = 8
+	type award struct {
+		prize   string
+		awarded bool
+		err     error
+	}
+	awards := make([]award, spins)
+	var wg sync.WaitGroup
+	for i := range awards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prize, awarded, err := repo.AwardPrize(ctx, orderID, "diamond_ring", "prize_10ml")
+			awards[i] = award{prize, awarded, err}
+		}()
+	}
+	wg.Wait()
+
+	winners := 0
+	for i, a := range awards {
+		if a.err != nil || a.prize != "diamond_ring" {
+			t.Errorf("award %d = %+v, want the ring", i, a)
+		}
+		if a.awarded {
+			winners++
+		}
+	}
+	if winners != 1 {
+		t.Errorf("awarded %d times, want once", winners)
+	}
+
+	var awarded int
+	if err := db.QueryRow(`SELECT awarded FROM prize_inventory WHERE prize = 'diamond_ring'`).Scan(&awarded); err != nil {
+		t.Fatalf("get ring stock: %v", err)
+	}
+	if awarded != 1 {
+		t.Errorf("rings taken from stock = %d, want 1", awarded)
+	}
+}
+
+func TestCheckoutSpreadsCartOverOrders(t *testing.T) {
+	db := newTestDB(t)
+	clients := NewClientRepository(db)
+	orders := NewOrderRepository(db)
+	parfumes := NewParfumeRepository(db)
+	ctx := context.Background()
+	const userID = 430
+
+	stock := 5
+	tracked := Product{NameParfume: "Lost Cherry", Sex: "Unisex", Price: 2999, Stock: &stock}
+	if err := parfumes.Create(ctx, &tracked); err != nil {
+		t.Fatalf("create perfume: %v", err)
+	}
+	untracked := createTestPerfume(t, parfumes, "Baccarat Rouge", 2499)
+
+	var orderIDs []int64
+	for i, datePay := range []string{"2026-03-01 10:00:00", "2026-03-01 11:00:00"} {
+		id, err := clients.InsertOrder(ctx, domain.OrderEntry{
+			UserID: userID, UserName: "aigerim", Quantity: sql.NullInt64{Int64: int64(i + 1), Valid: true}, DatePay: datePay,
+		})
+		if err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE orders SET created_at = ? WHERE id = ?`, datePay, id); err != nil {
+			t.Fatalf("set created_at: %v", err)
+		}
+		orderIDs = append(orderIDs, id)
+	}
+
+	cart := []domain.CartItem{
+		{ParfumeID: tracked.Id, Name: tracked.NameParfume, Quantity: 2},
+		{ParfumeID: untracked.Id, Name: untracked.NameParfume, Quantity: 1},
+	}
+	info := domain.CheckoutInfo{FIO: "Айгерим", Contact: "+77011234567", Address: "Алматы, Абая 1"}
+	allocations, err := orders.Checkout(ctx, userID, cart, info)
+	if err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	if len(allocations) != 2 || allocations[0].Order.ID != orderIDs[0] || allocations[1].Order.ID != orderIDs[1] {
+		t.Fatalf("allocations = %+v, want the older order first", allocations)
+	}
+
+	// The older order holds one set, the newer one the other two
+	wantParfumes := []string{"Lost Cherry: 1", "Lost Cherry: 1, Baccarat Rouge: 1"}
+	for i, id := range orderIDs {
+		order, err := orders.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if order.Parfumes != wantParfumes[i] || order.Address != info.Address || order.Status != domain.OrderStatusAddressProvided {
+			t.Errorf("order %d = %q, %q, %s; want %q at the address", id, order.Parfumes, order.Address, order.Status, wantParfumes[i])
+		}
+	}
+
+	product, err := parfumes.GetByID(ctx, tracked.Id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if product.Stock == nil || *product.Stock != 3 {
+		t.Errorf("stock = %v, want 3", product.Stock)
+	}
+}
+
+func TestCheckoutLeavesNothingWhenStockRunsShort(t *testing.T) {
+	db := newTestDB(t)
+	clients := NewClientRepository(db)
+	orders := NewOrderRepository(db)
+	parfumes := NewParfumeRepository(db)
+	ctx := context.Background()
+	const userID = 431
+
+	plenty, short := 5, 1
+	first := Product{NameParfume: "Lost Cherry", Sex: "Unisex", Price: 2999, Stock: &plenty}
+	second := Product{NameParfume: "Oud Wood", Sex: "Unisex", Price: 1999, Stock: &short}
+	for _, product := range []*Product{&first, &second} {
+		if err := parfumes.Create(ctx, product); err != nil {
+			t.Fatalf("create perfume: %v", err)
+		}
+	}
+
+	orderID, err := clients.InsertOrder(ctx, domain.OrderEntry{
+		UserID: userID, UserName: "aigerim", Quantity: sql.NullInt64{Int64: 3, Valid: true}, DatePay: "2026-03-01 10:00:00",
+	})
+	if err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	before, err := orders.GetByID(ctx, orderID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+
+	cart := []domain.CartItem{
+		{ParfumeID: first.Id, Name: first.NameParfume, Quantity: 1},
+		{ParfumeID: second.Id, Name: second.NameParfume, Quantity: 2},
+	}
+	_, err = orders.Checkout(ctx, userID, cart, domain.CheckoutInfo{FIO: "Айгерим", Contact: "+77011234567", Address: "Алматы"})
+	var stockErr *InsufficientStockError
+	if !errors.As(err, &stockErr) || stockErr.ParfumeID != second.Id {
+		t.Fatalf("Checkout error = %v, want insufficient stock of %s", err, second.Id)
+	}
+
+	// Neither the stock taken for the first perfume nor any order data survives
+	for _, want := range []struct {
+		id    string
+		stock int
+	}{{first.Id, 5}, {second.Id, 1}} {
+		product, err := parfumes.GetByID(ctx, want.id)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if product.Stock == nil || *product.Stock != want.stock {
+			t.Errorf("stock of %s = %v, want %d", want.id, product.Stock, want.stock)
+		}
+	}
+
+	var items int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM order_items WHERE order_id = ?`, orderID).Scan(&items); err != nil {
+		t.Fatalf("count items: %v", err)
+	}
+	if items != 0 {
+		t.Errorf("order items = %d, want none", items)
+	}
+	after, err := orders.GetByID(ctx, orderID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if after.Parfumes != before.Parfumes || after.Address != before.Address || after.Status != before.Status {
+		t.Errorf("order changed to %q, %q, %s", after.Parfumes, after.Address, after.Status)
+	}
+
+	// An order without room for the cart refuses it before touching the stock
+	cart = []domain.CartItem{{ParfumeID: first.Id, Name: first.NameParfume, Quantity: 4}}
+	_, err = orders.Checkout(ctx, userID, cart, domain.CheckoutInfo{FIO: "Айгерим", Contact: "+77011234567", Address: "Алматы"})
+	var quantityErr *InsufficientQuantityError
+	if !errors.As(err, &quantityErr) || quantityErr.Available != 3 || quantityErr.Requested != 4 {
+		t.Errorf("Checkout error = %v, want 3 of 4 available", err)
+	}
+}
+
+func TestCancelOrderRestoresStock(t *testing.T) {
+	db := newTestDB(t)
+	clients := NewClientRepository(db)
+	orders := NewOrderRepository(db)
+	parfumes := NewParfumeRepository(db)
+	ctx := context.Background()
+	const userID = 432
+
+	stock := 5
+	tracked := Product{NameParfume: "Lost Cherry", Sex: "Unisex", Price: 2999, Stock: &stock}
+	if err := parfumes.Create(ctx, &tracked); err != nil {
+		t.Fatalf("create perfume: %v", err)
+	}
+	untracked := createTestPerfume(t, parfumes, "Baccarat Rouge", 2499)
+
+	orderID, err := clients.InsertOrder(ctx, domain.OrderEntry{
+		UserID: userID, UserName: "aigerim", Quantity: sql.NullInt64{Int64: 3, Valid: true}, DatePay: "2026-03-01 10:00:00",
+	})
+	if err != nil {
+		t.Fatalf("InsertOrder: %v", err)
+	}
+	cart := []domain.CartItem{
+		{ParfumeID: tracked.Id, Name: tracked.NameParfume, Quantity: 2},
+		{ParfumeID: untracked.Id, Name: untracked.NameParfume, Quantity: 1},
+	}
+	if _, err := orders.Checkout(ctx, userID, cart, domain.CheckoutInfo{FIO: "Айгерим", Contact: "+77011234567", Address: "Алматы"}); err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+
+	stockOf := func() int {
+		t.Helper()
+		product, err := parfumes.GetByID(ctx, tracked.Id)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if product.Stock == nil {
+			t.Fatalf("stock of %s is no longer tracked", tracked.Id)
+		}
+		return *product.Stock
+	}
+
+	// Moving forward keeps the stock taken
+	if _, err := orders.UpdateStatus(ctx, orderID, domain.OrderStatusPacked, domain.OrderEventActorAdmin, ""); err != nil {
+		t.Fatalf("UpdateStatus packed: %v", err)
+	}
+	if got := stockOf(); got != 3 {
+		t.Errorf("stock after packing = %d, want 3", got)
+	}
+
+	if _, err := orders.UpdateStatus(ctx, orderID, domain.OrderStatusCancelled, domain.OrderEventActorAdmin, "client refused"); err != nil {
+		t.Fatalf("UpdateStatus cancelled: %v", err)
+	}
+	if got := stockOf(); got != 5 {
+		t.Errorf("stock after cancelling = %d, want 5", got)
+	}
+	if product, err := parfumes.GetByID(ctx, untracked.Id); err != nil || product.Stock != nil {
+		t.Errorf("untracked perfume = %+v, %v; want its stock left untracked", product, err)
+	}
+
+	// A second cancel is refused and gives nothing back again
+	var transitionErr *StatusTransitionError
+	if _, err := orders.UpdateStatus(ctx, orderID, domain.OrderStatusCancelled, domain.OrderEventActorAdmin, ""); !errors.As(err, &transitionErr) {
+		t.Errorf("second cancel error = %v, want a status transition error", err)
+	}
+	if got := stockOf(); got != 5 {
+		t.Errorf("stock after the second cancel = %d, want 5", got)
+	}
+}
+
+func TestClaimPrizeWithAddress(t *testing.T) {
+	db := newTestDB(t)
+	clients := NewClientRepository(db)
+	orders := NewOrderRepository(db)
+	ctx := context.Background()
+
+	newOrder := func(userID int64, gift string) int64 {
+		t.Helper()
+		id, err := clients.InsertOrder(ctx, domain.OrderEntry{
+			UserID: userID, UserName: "aigerim", Quantity: sql.NullInt64{Int64: 1, Valid: true}, DatePay: "2026-03-01 10:00:00",
+		})
+		if err != nil {
+			t.Fatalf("InsertOrder: %v", err)
+		}
+		if _, err := db.Exec(`UPDATE orders SET gift = ?, prize_awarded_at = CURRENT_TIMESTAMP WHERE id = ?`, gift, id); err != nil {
+			t.Fatalf("set prize: %v", err)
+		}
+		return id
+	}
+	first := domain.CheckoutInfo{FIO: "Айгерим", Contact: "+77011234567", Address: "Алматы, Абая 1"}
+
+	won := newOrder(440, "diamond_ring")
+	if claimed, err := orders.ClaimPrizeWithAddress(ctx, won, first); err != nil || !claimed {
+		t.Fatalf("ClaimPrizeWithAddress = %v, %v; want claimed", claimed, err)
+	}
+	order, err := orders.GetByID(ctx, won)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if order.Address != first.Address || order.PrizeClaimedAt == nil || order.Status != domain.OrderStatusAddressProvided {
+		t.Fatalf("order = %q, claimed %v, %s; want the address with the claim", order.Address, order.PrizeClaimedAt, order.Status)
+	}
+
+	// A resent form an hour later fixes the address and keeps the first claim
+	if _, err := db.Exec(`UPDATE orders SET prize_claimed_at = datetime('now', '-1 hour') WHERE id = ?`, won); err != nil {
+		t.Fatalf("age claim: %v", err)
+	}
+	order, err = orders.GetByID(ctx, won)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	claimedAt := *order.PrizeClaimedAt
+	resent := first
+	resent.Address = "Алматы, Абая 2"
+	if claimed, err := orders.ClaimPrizeWithAddress(ctx, won, resent); err != nil || !claimed {
+		t.Fatalf("resent ClaimPrizeWithAddress = %v, %v; want claimed", claimed, err)
+	}
+	order, _ = orders.GetByID(ctx, won)
+	if order.Address != resent.Address || order.PrizeClaimedAt == nil || !order.PrizeClaimedAt.Equal(claimedAt) {
+		t.Errorf("resent order = %q, claimed %v; want %q claimed at %v", order.Address, order.PrizeClaimedAt, resent.Address, claimedAt)
+	}
+
+	// An expired prize or an order without one keeps no address
+	expired := newOrder(441, "30ml")
+	if ok, err := orders.ExpirePrize(ctx, expired); err != nil || !ok {
+		t.Fatalf("ExpirePrize = %v, %v", ok, err)
+	}
+	none := newOrder(442, "")
+	for _, id := range []int64{expired, none} {
+		if claimed, err := orders.ClaimPrizeWithAddress(ctx, id, first); err != nil || claimed {
+			t.Errorf("ClaimPrizeWithAddress(%d) = %v, %v; want not claimed", id, claimed, err)
+		}
+		order, err := orders.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if order.Address != "" || order.PrizeClaimedAt != nil || order.Status != domain.OrderStatusPaid {
+			t.Errorf("order %d = %q, claimed %v, %s; want it untouched", id, order.Address, order.PrizeClaimedAt, order.Status)
+		}
+	}
+}
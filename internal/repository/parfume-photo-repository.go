@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ParfumePhoto is one image in a perfume's gallery
+type ParfumePhoto struct {
+	ID        int64     `json:"id" db:"id"`
+	ParfumeID string    `json:"parfume_id" db:"parfume_id"`
+	Filename  string    `json:"filename" db:"filename"`
+	URL       string    `json:"url"`
+	SortOrder int       `json:"sort_order" db:"sort_order"`
+	IsPrimary bool      `json:"is_primary" db:"is_primary"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Resized variants; the URLs fall back to the original until they exist
+	ThumbFilename  string `json:"thumb_filename,omitempty" db:"thumb_filename"`
+	MediumFilename string `json:"medium_filename,omitempty" db:"medium_filename"`
+	ThumbURL       string `json:"thumb_url"`
+	MediumURL      string `json:"medium_url"`
+}
+
+// Files lists the original and any generated variants stored on disk
+func (p ParfumePhoto) Files() []string {
+	files := []string{p.Filename}
+	if p.ThumbFilename != "" {
+		files = append(files, p.ThumbFilename)
+	}
+	if p.MediumFilename != "" {
+		files = append(files, p.MediumFilename)
+	}
+	return files
+}
+
+// setURLs fills the public URLs derived from the stored filename
+func (p *ParfumePhoto) setURLs() {
+	p.URL = "/photo/" + p.Filename
+	p.ThumbURL = p.URL + "?size=thumb"
+	p.MediumURL = p.URL + "?size=medium"
+}
+
+type ParfumePhotoRepository struct {
+	db *sql.DB
+}
+
+func NewParfumePhotoRepository(db *sql.DB) *ParfumePhotoRepository {
+	return &ParfumePhotoRepository{
+		db: db,
+	}
+}
+
+// scanParfumePhoto reads one parfume_photos row selected with the standard column list
+func scanParfumePhoto(row productScanner) (ParfumePhoto, error) {
+	var photo ParfumePhoto
+	var thumb, medium sql.NullString
+	err := row.Scan(
+		&photo.ID,
+		&photo.ParfumeID,
+		&photo.Filename,
+		&photo.SortOrder,
+		&photo.IsPrimary,
+		&photo.CreatedAt,
+		&thumb,
+		&medium,
+	)
+	if err != nil {
+		return photo, err
+	}
+
+	photo.ThumbFilename = thumb.String
+	photo.MediumFilename = medium.String
+	photo.setURLs()
+	return photo, nil
+}
+
+// Add appends photos to a perfume's gallery. With makePrimary the first new photo
+// becomes the primary one; otherwise it only does when the gallery has no primary yet.
+func (r *ParfumePhotoRepository) Add(ctx context.Context, parfumeID string, filenames []string, makePrimary bool) ([]ParfumePhoto, error) {
+	if len(filenames) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting photo transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var nextOrder, primaryCount int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(sort_order) + 1, 0), COALESCE(SUM(is_primary), 0)
+		FROM parfume_photos
+		WHERE parfume_id = ?
+	`, parfumeID).Scan(&nextOrder, &primaryCount)
+	if err != nil {
+		return nil, fmt.Errorf("error reading perfume gallery: %w", err)
+	}
+
+	if makePrimary && primaryCount > 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE parfume_photos SET is_primary = 0 WHERE parfume_id = ?`, parfumeID); err != nil {
+			return nil, fmt.Errorf("error clearing primary photo: %w", err)
+		}
+		primaryCount = 0
+	}
+
+	photos := make([]ParfumePhoto, 0, len(filenames))
+	for i, filename := range filenames {
+		photo := ParfumePhoto{
+			ParfumeID: parfumeID,
+			Filename:  filename,
+			SortOrder: nextOrder + i,
+			IsPrimary: i == 0 && primaryCount == 0,
+			CreatedAt: time.Now(),
+		}
+		photo.setURLs()
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO parfume_photos (parfume_id, filename, sort_order, is_primary, created_at)
+			VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, photo.ParfumeID, photo.Filename, photo.SortOrder, photo.IsPrimary)
+		if err != nil {
+			return nil, fmt.Errorf("error adding perfume photo: %w", err)
+		}
+
+		photo.ID, err = result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("error getting photo id: %w", err)
+		}
+		photos = append(photos, photo)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing photo transaction: %w", err)
+	}
+	return photos, nil
+}
+
+// GetByParfume returns a perfume's gallery in display order
+func (r *ParfumePhotoRepository) GetByParfume(ctx context.Context, parfumeID string) ([]ParfumePhoto, error) {
+	query := `
+		SELECT id, parfume_id, filename, sort_order, is_primary, created_at, thumb_filename, medium_filename
+		FROM parfume_photos
+		WHERE parfume_id = ?
+		ORDER BY is_primary DESC, sort_order ASC, id ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, parfumeID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying perfume photos: %w", err)
+	}
+	defer rows.Close()
+
+	var photos []ParfumePhoto
+	for rows.Next() {
+		photo, err := scanParfumePhoto(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning perfume photo: %w", err)
+		}
+		photos = append(photos, photo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating perfume photo rows: %w", err)
+	}
+
+	return photos, nil
+}
+
+// GetByID returns a single gallery photo
+func (r *ParfumePhotoRepository) GetByID(ctx context.Context, id int64) (*ParfumePhoto, error) {
+	query := `
+		SELECT id, parfume_id, filename, sort_order, is_primary, created_at, thumb_filename, medium_filename
+		FROM parfume_photos
+		WHERE id = ?
+	`
+
+	photo, err := scanParfumePhoto(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("photo not found")
+		}
+		return nil, fmt.Errorf("error getting perfume photo: %w", err)
+	}
+
+	return &photo, nil
+}
+
+// GetPrimary returns the primary photo of a perfume, or nil if the gallery is empty
+func (r *ParfumePhotoRepository) GetPrimary(ctx context.Context, parfumeID string) (*ParfumePhoto, error) {
+	query := `
+		SELECT id, parfume_id, filename, sort_order, is_primary, created_at, thumb_filename, medium_filename
+		FROM parfume_photos
+		WHERE parfume_id = ? AND is_primary = 1
+		LIMIT 1
+	`
+
+	photo, err := scanParfumePhoto(r.db.QueryRowContext(ctx, query, parfumeID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting primary photo: %w", err)
+	}
+
+	return &photo, nil
+}
+
+// Delete removes a gallery photo; if it was primary, the next photo takes its place
+func (r *ParfumePhotoRepository) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting photo transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var parfumeID string
+	var isPrimary bool
+	err = tx.QueryRowContext(ctx, `SELECT parfume_id, is_primary FROM parfume_photos WHERE id = ?`, id).Scan(&parfumeID, &isPrimary)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("photo not found")
+		}
+		return fmt.Errorf("error getting perfume photo: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM parfume_photos WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("error deleting perfume photo: %w", err)
+	}
+
+	if isPrimary {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE parfume_photos SET is_primary = 1
+			WHERE id = (
+				SELECT id FROM parfume_photos
+				WHERE parfume_id = ?
+				ORDER BY sort_order ASC, id ASC
+				LIMIT 1
+			)
+		`, parfumeID)
+		if err != nil {
+			return fmt.Errorf("error promoting primary photo: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing photo transaction: %w", err)
+	}
+	return nil
+}
+
+// GetMissingVariants returns every gallery photo that has no resized variants yet
+func (r *ParfumePhotoRepository) GetMissingVariants(ctx context.Context) ([]ParfumePhoto, error) {
+	query := `
+		SELECT id, parfume_id, filename, sort_order, is_primary, created_at, thumb_filename, medium_filename
+		FROM parfume_photos
+		WHERE thumb_filename IS NULL OR medium_filename IS NULL
+		ORDER BY id ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying perfume photos: %w", err)
+	}
+	defer rows.Close()
+
+	var photos []ParfumePhoto
+	for rows.Next() {
+		photo, err := scanParfumePhoto(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning perfume photo: %w", err)
+		}
+		photos = append(photos, photo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating perfume photo rows: %w", err)
+	}
+
+	return photos, nil
+}
+
+// SetVariants stores the file names of a photo's resized variants
+func (r *ParfumePhotoRepository) SetVariants(ctx context.Context, id int64, thumbFilename, mediumFilename string) error {
+	query := `
+		UPDATE parfume_photos
+		SET thumb_filename = ?, medium_filename = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, nullableString(thumbFilename), nullableString(mediumFilename), id)
+	if err != nil {
+		return fmt.Errorf("error updating photo variants: %w", err)
+	}
+	return nil
+}
+
+// DeleteByParfume removes a perfume's whole gallery and returns the files it held,
+// variants included
+func (r *ParfumePhotoRepository) DeleteByParfume(ctx context.Context, parfumeID string) ([]string, error) {
+	photos, err := r.GetByParfume(ctx, parfumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM parfume_photos WHERE parfume_id = ?`, parfumeID); err != nil {
+		return nil, fmt.Errorf("error deleting perfume photos: %w", err)
+	}
+
+	var filenames []string
+	for _, photo := range photos {
+		filenames = append(filenames, photo.Files()...)
+	}
+	return filenames, nil
+}
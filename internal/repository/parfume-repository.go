@@ -1,22 +1,88 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Product struct {
-	Id          string    `json:"Id" db:"id"`
-	NameParfume string    `json:"NameParfume" db:"name_parfume"`
-	Sex         string    `json:"Sex" db:"sex"`
-	Description string    `json:"Description" db:"description"`
-	Price       int       `json:"Price" db:"price"`
-	PhotoPath   string    `json:"PhotoPath" db:"photo_path"`
-	CreatedAt   time.Time `json:"CreatedAt" db:"created_at"`
-	UpdatedAt   time.Time `json:"UpdatedAt" db:"updated_at"`
+	Id          string     `json:"Id" db:"id"`
+	NameParfume string     `json:"NameParfume" db:"name_parfume"`
+	Sex         string     `json:"Sex" db:"sex"`
+	Description string     `json:"Description" db:"description"`
+	Price       int        `json:"Price" db:"price"`
+	PhotoPath   string     `json:"PhotoPath" db:"photo_path"`
+	ThumbPath   string     `json:"thumb_path,omitempty" db:"thumb_path"` // small JPEG of the primary photo, served from /photo/
+	CreatedAt   time.Time  `json:"CreatedAt" db:"created_at"`
+	UpdatedAt   time.Time  `json:"UpdatedAt" db:"updated_at"`
+	DeletedAt   *time.Time `json:"DeletedAt,omitempty" db:"deleted_at"` // set while the perfume is archived
+	IsActive    bool       `json:"IsActive"`                            // not archived, i.e. DeletedAt is nil
+	Stock       *int       `json:"stock" db:"stock"` // nil — stock isn't tracked
+	InStock     bool       `json:"in_stock"`
+}
+
+// InsufficientStockError is returned when a perfume has less stock than requested
+type InsufficientStockError struct {
+	ParfumeID string
+	Available int
+	Requested int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for perfume %s: available %d, requested %d", e.ParfumeID, e.Available, e.Requested)
+}
+
+// productColumns is the standard column list read by scanProduct; thumb_path is the
+// small variant of the primary gallery photo, if one has been generated
+const productColumns = `id, name_parfume, sex, description, price, photo_path, created_at, updated_at, deleted_at, stock,
+		(SELECT thumb_filename FROM parfume_photos WHERE parfume_photos.parfume_id = parfume.id AND is_primary = 1 LIMIT 1) AS thumb_path`
+
+// productScanner is satisfied by both *sql.Row and *sql.Rows
+type productScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProduct reads one perfume row selected with the standard column list
+func scanProduct(row productScanner) (Product, error) {
+	var product Product
+	var photoPath, thumbPath sql.NullString
+	var deletedAt sql.NullTime
+	var stock sql.NullInt64
+	err := row.Scan(
+		&product.Id,
+		&product.NameParfume,
+		&product.Sex,
+		&product.Description,
+		&product.Price,
+		&photoPath,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+		&deletedAt,
+		&stock,
+		&thumbPath,
+	)
+	if err != nil {
+		return product, err
+	}
+
+	product.PhotoPath = photoPath.String
+	product.ThumbPath = thumbPath.String
+	if deletedAt.Valid {
+		product.DeletedAt = &deletedAt.Time
+	}
+	product.IsActive = product.DeletedAt == nil
+	if stock.Valid {
+		value := int(stock.Int64)
+		product.Stock = &value
+	}
+	product.InStock = product.Stock == nil || *product.Stock > 0
+	return product, nil
 }
 
 type ParfumeRepository struct {
@@ -30,30 +96,112 @@ func NewParfumeRepository(db *sql.DB) *ParfumeRepository {
 }
 
 // Create a new perfume
-func (r *ParfumeRepository) Create(product *Product) error {
+func (r *ParfumeRepository) Create(ctx context.Context, product *Product) error {
 	product.Id = uuid.New().String()
 
 	query := `
-		INSERT INTO parfume (id, name_parfume, sex, description, price, photo_path, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO parfume (id, name_parfume, sex, description, price, photo_path, stock, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`
 
-	_, err := r.db.Exec(query, product.Id, product.NameParfume, product.Sex, product.Description, product.Price, product.PhotoPath)
+	_, err := r.db.ExecContext(ctx, query, product.Id, product.NameParfume, product.Sex, product.Description, product.Price, product.PhotoPath, product.Stock)
 	if err != nil {
 		return fmt.Errorf("error creating perfume: %w", err)
 	}
 	return nil
 }
 
-// Get all perfumes
-func (r *ParfumeRepository) GetAll() ([]Product, error) {
+// ProductImport is one validated row of a catalog CSV import
+type ProductImport struct {
+	Row     int
+	Product Product
+}
+
+// ProductImportError explains why an import row was not inserted
+type ProductImportError struct {
+	Row     int    `json:"row"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"error"`
+}
+
+// Import inserts the rows in a single transaction. A row whose name matches an active
+// perfume (or an earlier row of the same batch) is rejected; with atomic, any rejection
+// rolls the whole batch back and nothing is created.
+func (r *ParfumeRepository) Import(ctx context.Context, items []ProductImport, atomic bool) ([]Product, []ProductImportError, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error starting import transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existsStmt, err := tx.PrepareContext(ctx, `
+		SELECT COUNT(*) FROM parfume
+		WHERE LOWER(TRIM(name_parfume)) = LOWER(TRIM(?)) AND deleted_at IS NULL
+	`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error preparing import: %w", err)
+	}
+	defer existsStmt.Close()
+
+	insertStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO parfume (id, name_parfume, sex, description, price, photo_path, stock, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error preparing import: %w", err)
+	}
+	defer insertStmt.Close()
+
+	var created []Product
+	var rejected []ProductImportError
+	for _, item := range items {
+		product := item.Product
+
+		var count int
+		if err := existsStmt.QueryRowContext(ctx, product.NameParfume).Scan(&count); err != nil {
+			return nil, nil, fmt.Errorf("error checking perfume name: %w", err)
+		}
+		if count > 0 {
+			rejected = append(rejected, ProductImportError{Row: item.Row, Name: product.NameParfume, Message: "duplicate name"})
+			continue
+		}
+
+		product.Id = uuid.New().String()
+		_, err := insertStmt.ExecContext(ctx, product.Id, product.NameParfume, product.Sex, product.Description, product.Price, product.PhotoPath, product.Stock)
+		if err != nil {
+			// SQLite only undoes the failed statement, so the batch can go on
+			rejected = append(rejected, ProductImportError{Row: item.Row, Name: product.NameParfume, Message: err.Error()})
+			continue
+		}
+
+		product.IsActive = true
+		product.InStock = product.Stock == nil || *product.Stock > 0
+		created = append(created, product)
+	}
+
+	if atomic && len(rejected) > 0 {
+		return nil, rejected, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("error committing import: %w", err)
+	}
+	return created, rejected, nil
+}
+
+// Get all perfumes; archived ones only when includeArchived is set
+func (r *ParfumeRepository) GetAll(ctx context.Context, includeArchived bool) ([]Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, listQueryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, name_parfume, sex, description, price, photo_path, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM parfume
+		WHERE deleted_at IS NULL OR ?
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query, includeArchived)
 	if err != nil {
 		return nil, fmt.Errorf("error querying perfumes: %w", err)
 	}
@@ -61,17 +209,7 @@ func (r *ParfumeRepository) GetAll() ([]Product, error) {
 
 	var products []Product
 	for rows.Next() {
-		var product Product
-		err := rows.Scan(
-			&product.Id,
-			&product.NameParfume,
-			&product.Sex,
-			&product.Description,
-			&product.Price,
-			&product.PhotoPath,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-		)
+		product, err := scanProduct(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning perfume: %w", err)
 		}
@@ -86,24 +224,14 @@ func (r *ParfumeRepository) GetAll() ([]Product, error) {
 }
 
 // Get perfume by ID
-func (r *ParfumeRepository) GetByID(id string) (*Product, error) {
+func (r *ParfumeRepository) GetByID(ctx context.Context, id string) (*Product, error) {
 	query := `
-		SELECT id, name_parfume, sex, description, price, photo_path, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM parfume
 		WHERE id = ?
 	`
 
-	var product Product
-	err := r.db.QueryRow(query, id).Scan(
-		&product.Id,
-		&product.NameParfume,
-		&product.Sex,
-		&product.Description,
-		&product.Price,
-		&product.PhotoPath,
-		&product.CreatedAt,
-		&product.UpdatedAt,
-	)
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, id))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -115,15 +243,88 @@ func (r *ParfumeRepository) GetByID(id string) (*Product, error) {
 	return &product, nil
 }
 
+// Get perfumes by a list of IDs, keyed by ID
+func (r *ParfumeRepository) GetByIDs(ctx context.Context, ids []string) (map[string]Product, error) {
+	products, err := r.getByColumnIn(ctx, "id", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Product, len(products))
+	for _, product := range products {
+		result[product.Id] = product
+	}
+	return result, nil
+}
+
+// Get perfumes by a list of names, keyed by name
+func (r *ParfumeRepository) GetByNames(ctx context.Context, names []string) (map[string]Product, error) {
+	products, err := r.getByColumnIn(ctx, "name_parfume", names)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Product, len(products))
+	for _, product := range products {
+		// An active perfume wins over an archived one with the same name
+		if existing, ok := result[product.NameParfume]; ok && existing.IsActive {
+			continue
+		}
+		result[product.NameParfume] = product
+	}
+	return result, nil
+}
+
+// getByColumnIn loads perfumes whose column matches any of the values in one IN (...) query
+func (r *ParfumeRepository) getByColumnIn(ctx context.Context, column string, values []string) ([]Product, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		placeholders[i] = "?"
+		args[i] = value
+	}
+
+	query := fmt.Sprintf(`
+		SELECT `+productColumns+`
+		FROM parfume
+		WHERE %s IN (%s)
+	`, column, strings.Join(placeholders, ", "))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying perfumes by %s: %w", column, err)
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning perfume: %w", err)
+		}
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating perfume rows: %w", err)
+	}
+
+	return products, nil
+}
+
 // Update perfume
-func (r *ParfumeRepository) Update(product *Product) error {
+func (r *ParfumeRepository) Update(ctx context.Context, product *Product) error {
 	query := `
 		UPDATE parfume
-		SET name_parfume = ?, sex = ?, description = ?, price = ?, photo_path = ?, updated_at = CURRENT_TIMESTAMP
+		SET name_parfume = ?, sex = ?, description = ?, price = ?, photo_path = ?, stock = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
 
-	result, err := r.db.Exec(query, product.NameParfume, product.Sex, product.Description, product.Price, product.PhotoPath, product.Id)
+	result, err := r.db.ExecContext(ctx, query, product.NameParfume, product.Sex, product.Description, product.Price, product.PhotoPath, product.Stock, product.Id)
 	if err != nil {
 		return fmt.Errorf("error updating perfume: %w", err)
 	}
@@ -140,11 +341,76 @@ func (r *ParfumeRepository) Update(product *Product) error {
 	return nil
 }
 
-// Delete perfume
-func (r *ParfumeRepository) Delete(id string) error {
+// UpdatePhotoPath points the perfume's legacy photo_path at its primary image
+func (r *ParfumeRepository) UpdatePhotoPath(ctx context.Context, id, photoPath string) error {
+	query := `
+		UPDATE parfume
+		SET photo_path = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	_, err := r.db.ExecContext(ctx, query, photoPath, id)
+	if err != nil {
+		return fmt.Errorf("error updating perfume photo: %w", err)
+	}
+	return nil
+}
+
+// Archive hides a perfume from the catalog; historical orders still resolve it
+func (r *ParfumeRepository) Archive(ctx context.Context, id string) error {
+	query := `
+		UPDATE parfume
+		SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("error archiving perfume: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("perfume not found")
+	}
+
+	return nil
+}
+
+// Restore brings an archived perfume back into the catalog
+func (r *ParfumeRepository) Restore(ctx context.Context, id string) error {
+	query := `
+		UPDATE parfume
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND deleted_at IS NOT NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("error restoring perfume: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("perfume not found")
+	}
+
+	return nil
+}
+
+// Purge permanently removes a perfume row
+func (r *ParfumeRepository) Purge(ctx context.Context, id string) error {
 	query := `DELETE FROM parfume WHERE id = ?`
 
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("error deleting perfume: %w", err)
 	}
@@ -161,16 +427,135 @@ func (r *ParfumeRepository) Delete(id string) error {
 	return nil
 }
 
+// DecrementStock subtracts the given quantities (keyed by perfume ID) in one transaction.
+// Untracked perfumes are skipped; if any tracked perfume runs short nothing is changed.
+func (r *ParfumeRepository) DecrementStock(ctx context.Context, quantities map[string]int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting stock transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := decrementStock(ctx, tx, quantities); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing stock transaction: %w", err)
+	}
+	return nil
+}
+
+// RestoreStock adds the given quantities (keyed by perfume ID) back to tracked perfumes
+func (r *ParfumeRepository) RestoreStock(ctx context.Context, quantities map[string]int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting stock transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := restoreStock(ctx, tx, quantities); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing stock transaction: %w", err)
+	}
+	return nil
+}
+
+// decrementStock takes quantities out of tracked stock within tx. A perfume is only
+// updated while its stock covers the quantity, so stock never goes negative; the first
+// one that runs short gives an *InsufficientStockError.
+func decrementStock(ctx context.Context, tx *sql.Tx, quantities map[string]int) error {
+	for id, quantity := range quantities {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE parfume
+			SET stock = stock - ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND stock IS NOT NULL AND stock >= ?
+		`, quantity, id, quantity)
+		if err != nil {
+			return fmt.Errorf("error decrementing stock: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected > 0 {
+			continue
+		}
+
+		var stock sql.NullInt64
+		err = tx.QueryRowContext(ctx, `SELECT stock FROM parfume WHERE id = ?`, id).Scan(&stock)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !stock.Valid) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error checking stock: %w", err)
+		}
+
+		return &InsufficientStockError{ParfumeID: id, Available: int(stock.Int64), Requested: quantity}
+	}
+	return nil
+}
+
+// restoreStock puts quantities back into tracked stock within tx
+func restoreStock(ctx context.Context, tx *sql.Tx, quantities map[string]int) error {
+	for id, quantity := range quantities {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE parfume
+			SET stock = stock + ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND stock IS NOT NULL
+		`, quantity, id)
+		if err != nil {
+			return fmt.Errorf("error restoring stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get active perfumes whose tracked stock is below the threshold
+func (r *ParfumeRepository) GetLowStock(ctx context.Context, threshold int) ([]Product, error) {
+	query := `
+		SELECT ` + productColumns + `
+		FROM parfume
+		WHERE deleted_at IS NULL AND stock IS NOT NULL AND stock < ?
+		ORDER BY stock ASC, name_parfume ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("error querying low stock perfumes: %w", err)
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning perfume: %w", err)
+		}
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating perfume rows: %w", err)
+	}
+
+	return products, nil
+}
+
 // Get perfumes by sex
-func (r *ParfumeRepository) GetBySex(sex string) ([]Product, error) {
+func (r *ParfumeRepository) GetBySex(ctx context.Context, sex string) ([]Product, error) {
 	query := `
-		SELECT id, name_parfume, sex, description, price, photo_path, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM parfume
-		WHERE sex = ?
+		WHERE sex = ? AND deleted_at IS NULL
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query, sex)
+	rows, err := r.db.QueryContext(ctx, query, sex)
 	if err != nil {
 		return nil, fmt.Errorf("error querying perfumes by sex: %w", err)
 	}
@@ -178,17 +563,7 @@ func (r *ParfumeRepository) GetBySex(sex string) ([]Product, error) {
 
 	var products []Product
 	for rows.Next() {
-		var product Product
-		err := rows.Scan(
-			&product.Id,
-			&product.NameParfume,
-			&product.Sex,
-			&product.Description,
-			&product.Price,
-			&product.PhotoPath,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-		)
+		product, err := scanProduct(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning perfume: %w", err)
 		}
@@ -199,16 +574,16 @@ func (r *ParfumeRepository) GetBySex(sex string) ([]Product, error) {
 }
 
 // Search perfumes by name or description
-func (r *ParfumeRepository) SearchByName(name string) ([]Product, error) {
+func (r *ParfumeRepository) SearchByName(ctx context.Context, name string) ([]Product, error) {
 	query := `
-		SELECT id, name_parfume, sex, description, price, photo_path, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM parfume
-		WHERE name_parfume LIKE ? OR description LIKE ?
+		WHERE (name_parfume LIKE ? OR description LIKE ?) AND deleted_at IS NULL
 		ORDER BY created_at DESC
 	`
 
 	searchTerm := "%" + name + "%"
-	rows, err := r.db.Query(query, searchTerm, searchTerm)
+	rows, err := r.db.QueryContext(ctx, query, searchTerm, searchTerm)
 	if err != nil {
 		return nil, fmt.Errorf("error searching perfumes: %w", err)
 	}
@@ -216,17 +591,7 @@ func (r *ParfumeRepository) SearchByName(name string) ([]Product, error) {
 
 	var products []Product
 	for rows.Next() {
-		var product Product
-		err := rows.Scan(
-			&product.Id,
-			&product.NameParfume,
-			&product.Sex,
-			&product.Description,
-			&product.Price,
-			&product.PhotoPath,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-		)
+		product, err := scanProduct(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning perfume: %w", err)
 		}
@@ -236,15 +601,50 @@ func (r *ParfumeRepository) SearchByName(name string) ([]Product, error) {
 	return products, nil
 }
 
-// Advanced search with multiple criteria
-func (r *ParfumeRepository) AdvancedSearch(name, sex string, minPrice, maxPrice int) ([]Product, error) {
+// Sort orders accepted by AdvancedSearch
+const (
+	SortNewest    = "newest"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortNameAsc   = "name_asc"
+)
+
+// perfumeSortClauses is the allowlist of ORDER BY clauses; ties keep the newest first
+var perfumeSortClauses = map[string]string{
+	SortNewest:    "created_at DESC",
+	SortPriceAsc:  "price ASC, created_at DESC",
+	SortPriceDesc: "price DESC, created_at DESC",
+	SortNameAsc:   "name_parfume COLLATE NOCASE ASC, created_at DESC",
+}
+
+// IsValidPerfumeSort reports whether sort is one of the AdvancedSearch sort orders
+func IsValidPerfumeSort(sort string) bool {
+	_, ok := perfumeSortClauses[sort]
+	return ok
+}
+
+// Advanced search with multiple criteria; archived perfumes only when includeArchived is set.
+// An empty sort means SortNewest.
+func (r *ParfumeRepository) AdvancedSearch(ctx context.Context, name, sex string, minPrice, maxPrice int, includeArchived bool, sort string) ([]Product, error) {
+	if sort == "" {
+		sort = SortNewest
+	}
+	orderBy, ok := perfumeSortClauses[sort]
+	if !ok {
+		return nil, fmt.Errorf("error in advanced search: unknown sort %q", sort)
+	}
+
 	query := `
-		SELECT id, name_parfume, sex, description, price, photo_path, created_at, updated_at
+		SELECT ` + productColumns + `
 		FROM parfume
-		WHERE 1=1
+		WHERE 1 = 1
 	`
 	var args []interface{}
 
+	if !includeArchived {
+		query += " AND deleted_at IS NULL"
+	}
+
 	if name != "" {
 		query += " AND name_parfume LIKE ?"
 		args = append(args, "%"+name+"%")
@@ -265,9 +665,9 @@ func (r *ParfumeRepository) AdvancedSearch(name, sex string, minPrice, maxPrice
 		args = append(args, maxPrice)
 	}
 
-	query += " ORDER BY created_at DESC"
+	query += " ORDER BY " + orderBy
 
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error in advanced search: %w", err)
 	}
@@ -275,17 +675,7 @@ func (r *ParfumeRepository) AdvancedSearch(name, sex string, minPrice, maxPrice
 
 	var products []Product
 	for rows.Next() {
-		var product Product
-		err := rows.Scan(
-			&product.Id,
-			&product.NameParfume,
-			&product.Sex,
-			&product.Description,
-			&product.Price,
-			&product.PhotoPath,
-			&product.CreatedAt,
-			&product.UpdatedAt,
-		)
+		product, err := scanProduct(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning perfume: %w", err)
 		}
@@ -0,0 +1,431 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"testing"
+
+	"parfum/traits/database"
+)
+
+func createTestPerfume(t *testing.T, repo *ParfumeRepository, name string, price int) Product {
+	t.Helper()
+
+	product := Product{NameParfume: name, Sex: "Unisex", Description: name + " description", Price: price}
+	if err := repo.Create(context.Background(), &product); err != nil {
+		t.Fatalf("create perfume %q: %v", name, err)
+	}
+	return product
+}
+
+func TestParfumeRepositoryGetByIDs(t *testing.T) {
+	repo := NewParfumeRepository(newTestDB(t))
+	ctx := context.Background()
+
+	first := createTestPerfume(t, repo, "Baccarat Rouge", 2499)
+	second := createTestPerfume(t, repo, "Tobacco Vanille", 2999)
+	createTestPerfume(t, repo, "Lost Cherry", 3499)
+
+	products, err := repo.GetByIDs(ctx, []string{first.Id, second.Id, "missing-id"})
+	if err != nil {
+		t.Fatalf("GetByIDs: %v", err)
+	}
+
+	if len(products) != 2 {
+		t.Fatalf("got %d perfumes, want 2: %v", len(products), products)
+	}
+	for _, want := range []Product{first, second} {
+		got, ok := products[want.Id]
+		if !ok {
+			t.Errorf("perfume %s missing from the result", want.Id)
+			continue
+		}
+		if got.NameParfume != want.NameParfume || got.Price != want.Price {
+			t.Errorf("products[%s] = %q at %d, want %q at %d", want.Id, got.NameParfume, got.Price, want.NameParfume, want.Price)
+		}
+	}
+}
+
+func TestParfumeRepositoryGetByIDsEmpty(t *testing.T) {
+	repo := NewParfumeRepository(newTestDB(t))
+	createTestPerfume(t, repo, "Baccarat Rouge", 2499)
+
+	for name, ids := range map[string][]string{"nil": nil, "empty": {}} {
+		products, err := repo.GetByIDs(context.Background(), ids)
+		if err != nil {
+			t.Fatalf("%s: GetByIDs: %v", name, err)
+		}
+		if products == nil || len(products) != 0 {
+			t.Errorf("%s: got %v, want an empty map", name, products)
+		}
+	}
+}
+
+func TestParfumeRepositoryGetByNames(t *testing.T) {
+	repo := NewParfumeRepository(newTestDB(t))
+	ctx := context.Background()
+
+	for _, name := range []string{"Baccarat Rouge", "Tom Ford's Oud", `Hermès "Terre"`, "Oud'); DROP TABLE parfume; --"} {
+		createTestPerfume(t, repo, name, 2499)
+	}
+
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"nil", nil, nil},
+		{"empty", []string{}, nil},
+		{"plain", []string{"Baccarat Rouge"}, []string{"Baccarat Rouge"}},
+		{"quotes", []string{"Tom Ford's Oud", `Hermès "Terre"`}, []string{`Hermès "Terre"`, "Tom Ford's Oud"}},
+		{"injection stays a name", []string{"Oud'); DROP TABLE parfume; --"}, []string{"Oud'); DROP TABLE parfume; --"}},
+		{"unknown names", []string{"Lost Cherry", "baccarat rouge", ""}, nil},
+		{"known and unknown", []string{"Lost Cherry", "Baccarat Rouge", "Baccarat Rouge"}, []string{"Baccarat Rouge"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products, err := repo.GetByNames(ctx, tt.names)
+			if err != nil {
+				t.Fatalf("GetByNames: %v", err)
+			}
+			if products == nil {
+				t.Fatal("got a nil map, want an empty one")
+			}
+
+			var got []string
+			for name, product := range products {
+				if product.NameParfume != name {
+					t.Errorf("products[%q] is %q", name, product.NameParfume)
+				}
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if all, err := repo.GetAll(ctx, true); err != nil || len(all) != 4 {
+		t.Errorf("GetAll = %d perfumes, %v; want the table intact", len(all), err)
+	}
+}
+
+// productNames returns the names of products in order
+func productNames(products []Product) []string {
+	names := make([]string, 0, len(products))
+	for _, product := range products {
+		names = append(names, product.NameParfume)
+	}
+	return names
+}
+
+func containsName(products []Product, name string) bool {
+	for _, product := range products {
+		if product.NameParfume == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParfumeRepositoryArchiveKeepsRow(t *testing.T) {
+	repo := NewParfumeRepository(newTestDB(t))
+	ctx := context.Background()
+	product := createTestPerfume(t, repo, "Baccarat Rouge", 2499)
+
+	if err := repo.Archive(ctx, product.Id); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	// Orders still resolve an archived perfume
+	archived, err := repo.GetByID(ctx, product.Id)
+	if err != nil {
+		t.Fatalf("GetByID after archiving: %v", err)
+	}
+	if archived.IsActive || archived.DeletedAt == nil {
+		t.Errorf("archived perfume IsActive = %v, DeletedAt = %v; want inactive with DeletedAt", archived.IsActive, archived.DeletedAt)
+	}
+
+	if err := repo.Archive(ctx, product.Id); err == nil {
+		t.Error("archiving twice succeeded, want an error")
+	}
+
+	if err := repo.Restore(ctx, product.Id); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	restored, err := repo.GetByID(ctx, product.Id)
+	if err != nil {
+		t.Fatalf("GetByID after restoring: %v", err)
+	}
+	if !restored.IsActive || restored.DeletedAt != nil {
+		t.Errorf("restored perfume IsActive = %v, DeletedAt = %v; want active", restored.IsActive, restored.DeletedAt)
+	}
+}
+
+func TestParfumeRepositoryPurgeRemovesRow(t *testing.T) {
+	repo := NewParfumeRepository(newTestDB(t))
+	ctx := context.Background()
+	product := createTestPerfume(t, repo, "Baccarat Rouge", 2499)
+
+	if err := repo.Purge(ctx, product.Id); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, product.Id); err == nil {
+		t.Error("GetByID found a purged perfume")
+	}
+	if err := repo.Purge(ctx, product.Id); err == nil {
+		t.Error("purging twice succeeded, want an error")
+	}
+
+	all, err := repo.GetAll(ctx, true)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(all) != 0 {
+		t.Errorf("GetAll with archived = %v, want nothing", productNames(all))
+	}
+}
+
+func TestParfumeRepositoryListingsExcludeArchived(t *testing.T) {
+	repo := NewParfumeRepository(newTestDB(t))
+	ctx := context.Background()
+	createTestPerfume(t, repo, "Rouge Active", 2499)
+	archived := createTestPerfume(t, repo, "Rouge Archived", 2499)
+	if err := repo.Archive(ctx, archived.Id); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	listings := map[string]func() ([]Product, error){
+		"GetAll":       func() ([]Product, error) { return repo.GetAll(ctx, false) },
+		"GetBySex":     func() ([]Product, error) { return repo.GetBySex(ctx, "Unisex") },
+		"SearchByName": func() ([]Product, error) { return repo.SearchByName(ctx, "Rouge") },
+		"AdvancedSearch": func() ([]Product, error) {
+			return repo.AdvancedSearch(ctx, "Rouge", "", 0, 0, false, "")
+		},
+	}
+	for name, list := range listings {
+		products, err := list()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !containsName(products, "Rouge Active") || containsName(products, "Rouge Archived") {
+			t.Errorf("%s = %v, want only the active perfume", name, productNames(products))
+		}
+	}
+
+	// Admins can still list archived perfumes
+	all, err := repo.GetAll(ctx, true)
+	if err != nil {
+		t.Fatalf("GetAll with archived: %v", err)
+	}
+	if !containsName(all, "Rouge Archived") {
+		t.Errorf("GetAll with archived = %v, want the archived perfume too", productNames(all))
+	}
+	found, err := repo.AdvancedSearch(ctx, "Rouge", "", 0, 0, true, "")
+	if err != nil {
+		t.Fatalf("AdvancedSearch with archived: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("AdvancedSearch with archived = %v, want both perfumes", productNames(found))
+	}
+}
+
+// newMemoryDB opens an empty in-memory database; one connection, since every
+// connection to ":memory:" gets a database of its own
+func newMemoryDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestParfumeRepositoryReadsSeedData(t *testing.T) {
+	db := newMemoryDB(t)
+	if err := database.CreateTables(db); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	if err := database.SeedData(db); err != nil {
+		t.Fatalf("SeedData: %v", err)
+	}
+
+	products, err := NewParfumeRepository(db).GetAll(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+
+	names := productNames(products)
+	sort.Strings(names)
+	want := []string{"Lumen Crystal", "Lumen Noir", "Lumen Platinum", "Lumen Rose Gold", "Lumen Silver"}
+	if len(names) != len(want) {
+		t.Fatalf("GetAll = %v, want the five seeded perfumes", names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("GetAll = %v, want %v", names, want)
+		}
+	}
+}
+
+func TestMigrationMovesLegacyParfumesTable(t *testing.T) {
+	db := newMemoryDB(t)
+	if err := database.CreateTables(db); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+
+	// The catalog of an install from before the table was renamed
+	if _, err := db.Exec(`
+		CREATE TABLE parfumes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name_parfume TEXT NOT NULL,
+			sex TEXT NOT NULL,
+			description TEXT NOT NULL,
+			price REAL NOT NULL,
+			photo_path TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO parfumes (name_parfume, sex, description, price) VALUES ('Legacy Oud', 'Male', 'From the old table', 19990);
+	`); err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+
+	if err := database.MigrateDatabase(db); err != nil {
+		t.Fatalf("MigrateDatabase: %v", err)
+	}
+
+	products, err := NewParfumeRepository(db).GetAll(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(products) != 1 || products[0].NameParfume != "Legacy Oud" || products[0].Price != 19990 {
+		t.Fatalf("GetAll = %+v, want the legacy perfume", products)
+	}
+}
+
+func TestParfumeRepositoryAdvancedSearchSorts(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewParfumeRepository(db)
+	ctx := context.Background()
+
+	for _, seed := range []struct {
+		name      string
+		price     int
+		createdAt string
+	}{
+		{"tobacco Vanille", 2999, "2026-03-01 10:00:00"},
+		{"Baccarat Rouge", 2499, "2026-03-02 10:00:00"},
+		{"Lost Cherry", 3499, "2026-03-03 10:00:00"},
+		{"Oud Wood", 2499, "2026-03-04 10:00:00"},
+	} {
+		product := createTestPerfume(t, repo, seed.name, seed.price)
+		if _, err := db.Exec(`UPDATE parfume SET created_at = ? WHERE id = ?`, seed.createdAt, product.Id); err != nil {
+			t.Fatalf("set created_at: %v", err)
+		}
+	}
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"", []string{"Oud Wood", "Lost Cherry", "Baccarat Rouge", "tobacco Vanille"}},
+		{SortNewest, []string{"Oud Wood", "Lost Cherry", "Baccarat Rouge", "tobacco Vanille"}},
+		{SortPriceAsc, []string{"Oud Wood", "Baccarat Rouge", "tobacco Vanille", "Lost Cherry"}},
+		{SortPriceDesc, []string{"Lost Cherry", "tobacco Vanille", "Oud Wood", "Baccarat Rouge"}},
+		{SortNameAsc, []string{"Baccarat Rouge", "Lost Cherry", "Oud Wood", "tobacco Vanille"}},
+	}
+	for _, tt := range tests {
+		products, err := repo.AdvancedSearch(ctx, "", "", 0, 0, false, tt.sort)
+		if err != nil {
+			t.Fatalf("AdvancedSearch(%q): %v", tt.sort, err)
+		}
+		if got := productNames(products); !slices.Equal(got, tt.want) {
+			t.Errorf("AdvancedSearch(%q) = %q, want %q", tt.sort, got, tt.want)
+		}
+	}
+
+	// Filters apply before the sort
+	products, err := repo.AdvancedSearch(ctx, "", "", 2500, 0, false, SortPriceAsc)
+	if err != nil {
+		t.Fatalf("AdvancedSearch: %v", err)
+	}
+	if got := productNames(products); !slices.Equal(got, []string{"tobacco Vanille", "Lost Cherry"}) {
+		t.Errorf("filtered search = %q, want the two dearer perfumes", got)
+	}
+
+	if _, err := repo.AdvancedSearch(ctx, "", "", 0, 0, false, "price; DROP TABLE parfume"); err == nil {
+		t.Error("AdvancedSearch accepted a sort outside the allowlist")
+	}
+}
+
+func TestParfumeRepositorySharesTableWithSeedData(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewParfumeRepository(db)
+	ctx := context.Background()
+
+	if err := database.SeedData(db); err != nil {
+		t.Fatalf("SeedData: %v", err)
+	}
+	seeded, err := repo.GetByID(ctx, "lumen-001")
+	if err != nil || seeded.NameParfume != "Lumen Noir" {
+		t.Fatalf("GetByID(lumen-001) = %+v, %v; want the seeded perfume", seeded, err)
+	}
+
+	// Admin edits land on the seeded rows
+	seeded.Price = 27000
+	if err := repo.Update(ctx, seeded); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	created := createTestPerfume(t, repo, "Lumen Amber", 21000)
+
+	// Seeding again leaves a catalog that has rows alone
+	if err := database.SeedData(db); err != nil {
+		t.Fatalf("SeedData again: %v", err)
+	}
+	products, err := repo.GetAll(ctx, false)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if len(products) != 6 {
+		t.Fatalf("GetAll = %v, want the five seeded perfumes and the new one", productNames(products))
+	}
+	for _, product := range products {
+		if product.Id == "lumen-001" && product.Price != 27000 {
+			t.Errorf("seeded perfume price = %d, want the admin's 27000", product.Price)
+		}
+	}
+	if !slices.Contains(productNames(products), created.NameParfume) {
+		t.Errorf("GetAll = %v, want %q", productNames(products), created.NameParfume)
+	}
+
+	var legacy int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'parfumes'`).Scan(&legacy); err != nil || legacy != 0 {
+		t.Errorf("parfumes table count = %d, %v; want the plural table gone", legacy, err)
+	}
+
+	rows, err := db.Query(`SELECT name FROM pragma_index_list('parfume')`)
+	if err != nil {
+		t.Fatalf("list indexes: %v", err)
+	}
+	defer rows.Close()
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("scan index: %v", err)
+		}
+		indexes = append(indexes, name)
+	}
+	for _, index := range []string{"idx_parfume_sex", "idx_parfume_price", "idx_parfume_name"} {
+		if !slices.Contains(indexes, index) {
+			t.Errorf("indexes = %v, want %s", indexes, index)
+		}
+	}
+}
@@ -156,12 +156,298 @@ func (r *RedisRepository) DeleteBroadcastState(ctx context.Context, adminID int6
 	return nil
 }
 
+// processedUpdateTTL is how long a handled update ID is remembered. Telegram redelivers
+// updates for much less than that after a restart.
+const processedUpdateTTL = time.Hour
+
+// MarkUpdateSeen records a Telegram update ID and reports whether it is the first time
+// the update is seen
+func (r *RedisRepository) MarkUpdateSeen(ctx context.Context, updateID int64) (bool, error) {
+	key := fmt.Sprintf("update_seen:%d", updateID)
+
+	first, err := r.client.SetNX(ctx, key, 1, processedUpdateTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark update as seen in redis: %w", err)
+	}
+
+	return first, nil
+}
+
+// dailySpinsTTL keeps a day's spin counter a little past the end of the day
+const dailySpinsTTL = 48 * time.Hour
+
+// StartSpinCooldown starts the user's spin cooldown unless one is running. It returns how
+// much of a running cooldown is left, or 0 if it started a new one.
+func (r *RedisRepository) StartSpinCooldown(ctx context.Context, userID int64, cooldown time.Duration) (time.Duration, error) {
+	key := fmt.Sprintf("spin_cooldown:%d", userID)
+
+	started, err := r.client.SetNX(ctx, key, 1, cooldown).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start spin cooldown in redis: %w", err)
+	}
+	if started {
+		return 0, nil
+	}
+
+	return r.GetSpinCooldown(ctx, userID)
+}
+
+// GetSpinCooldown returns how much of the user's spin cooldown is left, 0 if none is running
+func (r *RedisRepository) GetSpinCooldown(ctx context.Context, userID int64) (time.Duration, error) {
+	key := fmt.Sprintf("spin_cooldown:%d", userID)
+
+	left, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get spin cooldown from redis: %w", err)
+	}
+	// -2 means no key, -1 no expiry
+	if left < 0 {
+		return 0, nil
+	}
+
+	return left, nil
+}
+
+// GetDailySpins returns how many times the user spun the wheel on day (YYYY-MM-DD)
+func (r *RedisRepository) GetDailySpins(ctx context.Context, userID int64, day string) (int, error) {
+	key := fmt.Sprintf("daily_spins:%d:%s", userID, day)
+
+	spins, err := r.client.Get(ctx, key).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get daily spins from redis: %w", err)
+	}
+
+	return spins, nil
+}
+
+// IncrDailySpins counts a spin of the user on day (YYYY-MM-DD) and returns the day's total
+func (r *RedisRepository) IncrDailySpins(ctx context.Context, userID int64, day string) (int, error) {
+	key := fmt.Sprintf("daily_spins:%d:%s", userID, day)
+
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, dailySpinsTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count daily spin in redis: %w", err)
+	}
+
+	return int(incr.Val()), nil
+}
+
+// receiptFailuresTTL keeps an hour's receipt failure counters a little past the hour
+const receiptFailuresTTL = 2 * time.Hour
+
+// recentReceiptFailures is how many receipt paths of recent failures are kept
+const recentReceiptFailures = 5
+
+// CountReceiptFailure counts a receipt of the user that failed validation in hour
+// (YYYY-MM-DDTHH) and remembers its path. It returns the user's and everyone's failures
+// in the hour.
+func (r *RedisRepository) CountReceiptFailure(ctx context.Context, userID int64, hour, receiptPath string) (int, int, error) {
+	userKey := fmt.Sprintf("receipt_failures:user:%d:%s", userID, hour)
+	globalKey := fmt.Sprintf("receipt_failures:global:%s", hour)
+
+	pipe := r.client.TxPipeline()
+	userIncr := pipe.Incr(ctx, userKey)
+	pipe.Expire(ctx, userKey, receiptFailuresTTL)
+	globalIncr := pipe.Incr(ctx, globalKey)
+	pipe.Expire(ctx, globalKey, receiptFailuresTTL)
+	if receiptPath != "" {
+		pipe.LPush(ctx, "receipt_failures:recent", receiptPath)
+		pipe.LTrim(ctx, "receipt_failures:recent", 0, recentReceiptFailures-1)
+		pipe.Expire(ctx, "receipt_failures:recent", receiptFailuresTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to count receipt failure in redis: %w", err)
+	}
+
+	return int(userIncr.Val()), int(globalIncr.Val()), nil
+}
+
+// GetRecentReceiptFailures returns the receipt paths of the latest failures, newest first
+func (r *RedisRepository) GetRecentReceiptFailures(ctx context.Context) ([]string, error) {
+	paths, err := r.client.LRange(ctx, "receipt_failures:recent", 0, recentReceiptFailures-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent receipt failures from redis: %w", err)
+	}
+
+	return paths, nil
+}
+
+// StartAlertCooldown starts the cooldown of an admin alert unless one is running, and
+// reports whether it started one, i.e. whether the alert may be sent
+func (r *RedisRepository) StartAlertCooldown(ctx context.Context, alert string, cooldown time.Duration) (bool, error) {
+	key := fmt.Sprintf("alert_cooldown:%s", alert)
+
+	started, err := r.client.SetNX(ctx, key, 1, cooldown).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to start alert cooldown in redis: %w", err)
+	}
+
+	return started, nil
+}
+
+// Pending receipt methods
+func (r *RedisRepository) SavePendingReceipt(ctx context.Context, userID int64, receipt *domain.PendingReceipt, ttl time.Duration) error {
+	key := fmt.Sprintf("pending_receipt:%d", userID)
+
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending receipt: %w", err)
+	}
+
+	err = r.client.Set(ctx, key, data, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to save pending receipt to redis: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RedisRepository) GetPendingReceipt(ctx context.Context, userID int64) (*domain.PendingReceipt, error) {
+	key := fmt.Sprintf("pending_receipt:%d", userID)
+
+	data, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil // Key doesn't exist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending receipt from redis: %w", err)
+	}
+
+	var receipt domain.PendingReceipt
+	err = json.Unmarshal([]byte(data), &receipt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending receipt: %w", err)
+	}
+
+	return &receipt, nil
+}
+
+func (r *RedisRepository) DeletePendingReceipt(ctx context.Context, userID int64) error {
+	key := fmt.Sprintf("pending_receipt:%d", userID)
+
+	err := r.client.Del(ctx, key).Err()
+	if err != nil {
+		return fmt.Errorf("failed to delete pending receipt from redis: %w", err)
+	}
+
+	return nil
+}
+
+// Cart methods
+func (r *RedisRepository) SaveCart(ctx context.Context, userID int64, items []domain.CartItem, ttl time.Duration) error {
+	key := fmt.Sprintf("cart:%d", userID)
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cart: %w", err)
+	}
+
+	err = r.client.Set(ctx, key, data, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to save cart to redis: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RedisRepository) GetCart(ctx context.Context, userID int64) ([]domain.CartItem, error) {
+	key := fmt.Sprintf("cart:%d", userID)
+
+	data, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil // Key doesn't exist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cart from redis: %w", err)
+	}
+
+	var items []domain.CartItem
+	err = json.Unmarshal([]byte(data), &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cart: %w", err)
+	}
+
+	return items, nil
+}
+
+func (r *RedisRepository) ClearCart(ctx context.Context, userID int64) error {
+	key := fmt.Sprintf("cart:%d", userID)
+
+	err := r.client.Del(ctx, key).Err()
+	if err != nil {
+		return fmt.Errorf("failed to clear cart in redis: %w", err)
+	}
+
+	return nil
+}
+
+// Idempotency key methods. A key holds "" while its request is being processed and
+// the stored response once it's done. Place order and prize completion keep their keys
+// in the database (IdempotencyRepository), which survives a Redis flush; these remain
+// for callers that only need a short-lived key.
+//
+// GetOrSetIdempotent claims key for the caller (true), or returns the stored response
+// of the request that claimed it first (nil while that request is still running).
+func (r *RedisRepository) GetOrSetIdempotent(ctx context.Context, key string, ttl time.Duration) ([]byte, bool, error) {
+	redisKey := fmt.Sprintf("idempotency:%s", key)
+
+	claimed, err := r.client.SetNX(ctx, redisKey, "", ttl).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim idempotency key in redis: %w", err)
+	}
+	if claimed {
+		return nil, true, nil
+	}
+
+	data, err := r.client.Get(ctx, redisKey).Result()
+	if err == redis.Nil {
+		// Released between the two calls; the caller may retry
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get idempotent result from redis: %w", err)
+	}
+	if data == "" {
+		return nil, false, nil // Still in progress
+	}
+
+	return []byte(data), false, nil
+}
+
+func (r *RedisRepository) SaveIdempotentResult(ctx context.Context, key string, result []byte, ttl time.Duration) error {
+	redisKey := fmt.Sprintf("idempotency:%s", key)
+
+	err := r.client.Set(ctx, redisKey, result, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to save idempotent result to redis: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RedisRepository) DeleteIdempotent(ctx context.Context, key string) error {
+	redisKey := fmt.Sprintf("idempotency:%s", key)
+
+	err := r.client.Del(ctx, redisKey).Err()
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency key from redis: %w", err)
+	}
+
+	return nil
+}
+
 // Helper method to clear all states for a user (useful for cleanup)
 func (r *RedisRepository) ClearAllUserStates(ctx context.Context, userID int64) error {
 	keys := []string{
 		fmt.Sprintf("user_state:%d", userID),
 		fmt.Sprintf("admin_state:%d", userID),
 		fmt.Sprintf("broadcast_state:%d", userID),
+		fmt.Sprintf("pending_receipt:%d", userID),
 	}
 
 	err := r.client.Del(ctx, keys...).Err()
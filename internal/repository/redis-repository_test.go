@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"parfum/internal/domain"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis starts an in-process Redis and returns a repository connected to it
+func newTestRedis(t *testing.T) (*RedisRepository, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisRepository(client), server
+}
+
+func TestRedisRepositoryPendingReceipt(t *testing.T) {
+	repo, server := newTestRedis(t)
+	ctx := context.Background()
+	const userID = 42
+
+	receipt, err := repo.GetPendingReceipt(ctx, userID)
+	if err != nil || receipt != nil {
+		t.Fatalf("GetPendingReceipt before saving = %v, %v; want nil, nil", receipt, err)
+	}
+
+	saved := &domain.PendingReceipt{
+		FilePath:    "./payment/42_20250101_120000.pdf",
+		FileName:    "42_20250101_120000.pdf",
+		ActualPrice: 4998,
+		Qr:          "qr-code",
+		Bin:         951125301078,
+	}
+	if err := repo.SavePendingReceipt(ctx, userID, saved, 30*time.Minute); err != nil {
+		t.Fatalf("SavePendingReceipt: %v", err)
+	}
+
+	receipt, err = repo.GetPendingReceipt(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetPendingReceipt: %v", err)
+	}
+	if receipt == nil || *receipt != *saved {
+		t.Fatalf("GetPendingReceipt = %+v, want %+v", receipt, saved)
+	}
+
+	// The receipt is only kept for the TTL
+	server.FastForward(31 * time.Minute)
+	receipt, err = repo.GetPendingReceipt(ctx, userID)
+	if err != nil || receipt != nil {
+		t.Fatalf("GetPendingReceipt after the TTL = %+v, %v; want nil, nil", receipt, err)
+	}
+}
+
+func TestRedisRepositoryDeletePendingReceipt(t *testing.T) {
+	repo, _ := newTestRedis(t)
+	ctx := context.Background()
+
+	if err := repo.SavePendingReceipt(ctx, 7, &domain.PendingReceipt{ActualPrice: 2499, Qr: "qr"}, time.Hour); err != nil {
+		t.Fatalf("SavePendingReceipt: %v", err)
+	}
+	if err := repo.DeletePendingReceipt(ctx, 7); err != nil {
+		t.Fatalf("DeletePendingReceipt: %v", err)
+	}
+
+	receipt, err := repo.GetPendingReceipt(ctx, 7)
+	if err != nil || receipt != nil {
+		t.Fatalf("GetPendingReceipt after deleting = %+v, %v; want nil, nil", receipt, err)
+	}
+}
+
+func TestRedisRepositoryCartRoundTrip(t *testing.T) {
+	repo, _ := newTestRedis(t)
+	ctx := context.Background()
+	const userID = 100
+
+	items, err := repo.GetCart(ctx, userID)
+	if err != nil || items != nil {
+		t.Fatalf("GetCart of an empty cart = %v, %v; want nil, nil", items, err)
+	}
+
+	saved := []domain.CartItem{
+		{ParfumeID: "p1", Name: "Baccarat Rouge", Quantity: 2, Price: 2499},
+		{ParfumeID: "p2", Name: "Lost Cherry", Quantity: 1, Price: 2499},
+	}
+	if err := repo.SaveCart(ctx, userID, saved, time.Hour); err != nil {
+		t.Fatalf("SaveCart: %v", err)
+	}
+	items, err = repo.GetCart(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetCart: %v", err)
+	}
+	if !reflect.DeepEqual(items, saved) {
+		t.Fatalf("GetCart = %+v, want %+v", items, saved)
+	}
+
+	// Saving again replaces the cart
+	replaced := saved[:1]
+	if err := repo.SaveCart(ctx, userID, replaced, time.Hour); err != nil {
+		t.Fatalf("SaveCart again: %v", err)
+	}
+	items, err = repo.GetCart(ctx, userID)
+	if err != nil || !reflect.DeepEqual(items, replaced) {
+		t.Fatalf("GetCart after replacing = %+v, %v; want %+v", items, err, replaced)
+	}
+
+	// Another user's cart is separate
+	if other, err := repo.GetCart(ctx, userID+1); err != nil || other != nil {
+		t.Errorf("GetCart of another user = %v, %v; want nil, nil", other, err)
+	}
+
+	if err := repo.ClearCart(ctx, userID); err != nil {
+		t.Fatalf("ClearCart: %v", err)
+	}
+	items, err = repo.GetCart(ctx, userID)
+	if err != nil || items != nil {
+		t.Fatalf("GetCart after clearing = %v, %v; want nil, nil", items, err)
+	}
+}
+
+func TestRedisRepositoryCartExpires(t *testing.T) {
+	repo, server := newTestRedis(t)
+	ctx := context.Background()
+
+	cart := []domain.CartItem{{ParfumeID: "p1", Name: "Baccarat Rouge", Quantity: 1, Price: 2499}}
+	if err := repo.SaveCart(ctx, 5, cart, 72*time.Hour); err != nil {
+		t.Fatalf("SaveCart: %v", err)
+	}
+
+	server.FastForward(71 * time.Hour)
+	if items, err := repo.GetCart(ctx, 5); err != nil || len(items) != 1 {
+		t.Fatalf("GetCart before the TTL = %v, %v; want the cart", items, err)
+	}
+
+	server.FastForward(2 * time.Hour)
+	if items, err := repo.GetCart(ctx, 5); err != nil || items != nil {
+		t.Fatalf("GetCart after the TTL = %v, %v; want nil, nil", items, err)
+	}
+}
+
+func TestRedisRepositoryMarkUpdateSeenExpires(t *testing.T) {
+	repo, server := newTestRedis(t)
+	ctx := context.Background()
+
+	for i, want := range []bool{true, false} {
+		if first, err := repo.MarkUpdateSeen(ctx, 42); err != nil || first != want {
+			t.Fatalf("MarkUpdateSeen #%d = %v, %v; want %v", i+1, first, err, want)
+		}
+	}
+
+	server.FastForward(processedUpdateTTL + time.Second)
+	if first, err := repo.MarkUpdateSeen(ctx, 42); err != nil || !first {
+		t.Errorf("MarkUpdateSeen after the TTL = %v, %v; want a first sighting", first, err)
+	}
+}
+
+func TestRedisRepositoryIdempotencyKey(t *testing.T) {
+	repo, server := newTestRedis(t)
+	ctx := context.Background()
+	const key = "7001:checkout-1"
+
+	previous, claimed, err := repo.GetOrSetIdempotent(ctx, key, time.Hour)
+	if err != nil || !claimed || previous != nil {
+		t.Fatalf("first GetOrSetIdempotent = %q, %v, %v; want the key claimed", previous, claimed, err)
+	}
+
+	// A repeat while the first request runs gets nothing to replay
+	previous, claimed, err = repo.GetOrSetIdempotent(ctx, key, time.Hour)
+	if err != nil || claimed || previous != nil {
+		t.Fatalf("GetOrSetIdempotent in progress = %q, %v, %v; want not claimed, no result", previous, claimed, err)
+	}
+
+	response := []byte(`{"order_id":12}`)
+	if err := repo.SaveIdempotentResult(ctx, key, response, time.Hour); err != nil {
+		t.Fatalf("SaveIdempotentResult: %v", err)
+	}
+	previous, claimed, err = repo.GetOrSetIdempotent(ctx, key, time.Hour)
+	if err != nil || claimed || string(previous) != string(response) {
+		t.Fatalf("GetOrSetIdempotent after saving = %q, %v, %v; want the saved response", previous, claimed, err)
+	}
+
+	// A released key can be claimed again
+	if err := repo.DeleteIdempotent(ctx, key); err != nil {
+		t.Fatalf("DeleteIdempotent: %v", err)
+	}
+	if _, claimed, err := repo.GetOrSetIdempotent(ctx, key, time.Hour); err != nil || !claimed {
+		t.Fatalf("GetOrSetIdempotent after releasing = %v, %v; want the key claimed", claimed, err)
+	}
+
+	// And so can an expired one
+	server.FastForward(2 * time.Hour)
+	if _, claimed, err := repo.GetOrSetIdempotent(ctx, key, time.Hour); err != nil || !claimed {
+		t.Errorf("GetOrSetIdempotent after the TTL = %v, %v; want the key claimed", claimed, err)
+	}
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSettingsRepositoryGetSetDelete(t *testing.T) {
+	repo := NewSettingsRepository(newTestDB(t))
+	ctx := context.Background()
+	const key = "promo_caption_kz"
+
+	if value, ok, err := repo.GetSetting(ctx, key); err != nil || ok || value != "" {
+		t.Fatalf("unset setting = %q, %v, %v; want nothing", value, ok, err)
+	}
+
+	for _, value := range []string{"Сәлем!", "Жаңа\nмәтін"} {
+		if err := repo.SetSetting(ctx, key, value); err != nil {
+			t.Fatalf("set %q: %v", value, err)
+		}
+		if got, ok, err := repo.GetSetting(ctx, key); err != nil || !ok || got != value {
+			t.Errorf("setting = %q, %v, %v; want %q", got, ok, err, value)
+		}
+	}
+
+	if err := repo.DeleteSetting(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if value, ok, err := repo.GetSetting(ctx, key); err != nil || ok {
+		t.Errorf("deleted setting = %q, %v, %v; want nothing", value, ok, err)
+	}
+	if err := repo.DeleteSetting(ctx, key); err != nil {
+		t.Errorf("deleting an unset setting: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+// Package i18n holds the bot's user-facing messages in every supported language.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Supported languages; the codes match clients.preferred_language and just.language
+const (
+	LangKz = "kz"
+	LangRu = "ru"
+
+	DefaultLang = LangKz
+)
+
+// Langs lists the supported languages in the order they are offered to users
+var Langs = []string{LangKz, LangRu}
+
+// Message keys of the language choice
+const (
+	LanguagePrompt      = "language_prompt"
+	LanguageButton      = "language_button"
+	LanguageChosen      = "language_chosen"
+	LanguageCommandDesc = "language_command_desc"
+)
+
+// Message keys of the purchase flow
+const (
+	StartPromo            = "start_promo"
+	BuyButton             = "buy_button"
+	ChooseCount           = "choose_count"
+	CountInvalid          = "count_invalid"
+	PayButton             = "pay_button"
+	PayInstructions       = "pay_instructions"
+	ReceiptWrongFormat    = "receipt_wrong_format"
+	ReceiptUnreadable     = "receipt_unreadable"
+	ReceiptAlreadyUsed    = "receipt_already_used"
+	ReceiptAmountMismatch = "receipt_amount_mismatch"
+	ReceiptWrongBin       = "receipt_wrong_bin"
+	ReceiptWrongPrice     = "receipt_wrong_price"
+	ReceiptInvalid        = "receipt_invalid"
+	ReceiptRetry          = "receipt_retry"
+	ReceiptManualReview   = "receipt_manual_review"
+	ReceiptRejected       = "receipt_rejected"
+	ReceiptAccepted       = "receipt_accepted"
+	ShareContactButton    = "share_contact_button"
+	ShareContactPrompt    = "share_contact_prompt"
+	ContactInvalid        = "contact_invalid"
+	ContactReceived       = "contact_received"
+	AddressButton         = "address_button"
+	SendReceiptHint       = "send_receipt_hint"
+	CancelButton          = "cancel_button"
+	CancelDone            = "cancel_done"
+)
+
+// Message keys of order confirmations and status notifications
+const (
+	OrderPlaced          = "order_placed"
+	OrderSummaryTitle    = "order_summary_title"
+	OrderSummaryItem     = "order_summary_item"
+	OrderSummaryTotal    = "order_summary_total"
+	OrderPayButton       = "order_pay_button"
+	SupportButton        = "support_button"
+	OrderPackedNotice    = "order_packed_notice"
+	OrderShippedNotice   = "order_shipped_notice"
+	OrderDeliveredNotice = "order_delivered_notice"
+	OrderCancelledNotice = "order_cancelled_notice"
+	OrderNumberLine      = "order_number_line"
+	OrderPerfumesLine    = "order_perfumes_line"
+)
+
+// Message keys of /myorders
+const (
+	MyOrdersTitle        = "my_orders_title"
+	MyOrdersEmpty        = "my_orders_empty"
+	MyOrdersItem         = "my_orders_item"
+	MyOrdersNoPerfumes   = "my_orders_no_perfumes"
+	MyOrdersGift         = "my_orders_gift"
+	MyOrdersTickets      = "my_orders_tickets"
+	MyOrdersNoTickets    = "my_orders_no_tickets"
+	MyOrdersSelectButton = "my_orders_select_button"
+	MyOrdersCommandDesc  = "my_orders_command_desc"
+	StartCommandDesc     = "start_command_desc"
+	CancelCommandDesc    = "cancel_command_desc"
+	HelpCommandDesc      = "help_command_desc"
+	HelpTitle            = "help_title"
+
+	OrderStatusAwaitingPayment = "order_status_awaiting_payment"
+	OrderStatusPaid            = "order_status_paid"
+	OrderStatusPerfumeSelected = "order_status_perfume_selected"
+	OrderStatusAddressProvided = "order_status_address_provided"
+	OrderStatusPacked          = "order_status_packed"
+	OrderStatusShipped         = "order_status_shipped"
+	OrderStatusDelivered       = "order_status_delivered"
+	OrderStatusCancelled       = "order_status_cancelled"
+)
+
+// Message keys of the prize wheel
+const (
+	PrizeWon = "prize_won"
+
+	Prize10ML    = "prize_parfum_10ml"
+	Prize30ML    = "prize_parfum_30ml"
+	PrizeDiamond = "prize_diamond_ring"
+	PrizeMoney   = "prize_money"
+
+	PrizeClaimReminder = "prize_claim_reminder"
+	PrizeClaimDaysLeft = "prize_claim_days_left"
+	PrizeClaimButton   = "prize_claim_button"
+	PrizeClaimExpired  = "prize_claim_expired"
+)
+
+// Message keys of the loto raffle
+const (
+	LotoWinner = "loto_winner"
+)
+
+// logger reports missing messages; SetLogger replaces it at startup
+var logger = zap.NewNop()
+
+// SetLogger sets the logger missing messages are reported to. Call it once before
+// the bot starts handling updates.
+func SetLogger(l *zap.Logger) {
+	logger = l
+}
+
+var catalogs = map[string]map[string]string{
+	LangKz: kz,
+	LangRu: ru,
+}
+
+// All returns the message for key in every language that has it, e.g. to match a
+// button's text whatever language it was shown in
+func All(key string) []string {
+	var texts []string
+	for _, lang := range Langs {
+		if text, ok := catalogs[lang][key]; ok {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+// T returns the message for key in lang, formatted with args. Unknown languages and
+// keys missing from lang fall back to Kazakh; a key missing everywhere is returned as is.
+// Missing keys are logged, since they mean a catalog is out of date.
+func T(lang, key string, args ...interface{}) string {
+	lang = Normalize(lang)
+	text, ok := catalogs[lang][key]
+	if !ok && lang != DefaultLang {
+		logger.Warn("Message missing, falling back to the default language",
+			zap.String("key", key),
+			zap.String("lang", lang),
+			zap.String("fallback", DefaultLang))
+		text, ok = catalogs[DefaultLang][key]
+	}
+	if !ok {
+		logger.Warn("Message missing in every language", zap.String("key", key))
+		return key
+	}
+
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Normalize maps a stored or Telegram language code to a supported language,
+// e.g. "ru-RU" to "ru". Anything unsupported becomes DefaultLang.
+func Normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if base, _, found := strings.Cut(lang, "-"); found {
+		lang = base
+	}
+
+	switch lang {
+	case LangRu:
+		return LangRu
+	case LangKz, "kk":
+		return LangKz
+	}
+	return DefaultLang
+}
@@ -0,0 +1,73 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestTTranslates(t *testing.T) {
+	tests := []struct {
+		lang string
+		key  string
+		args []interface{}
+		want string
+	}{
+		{LangKz, BuyButton, nil, "🛍 Сатып алу"},
+		{LangRu, BuyButton, nil, "🛍 Купить"},
+		{LangKz, OrderSummaryItem, []interface{}{"Baccarat Rouge", 2, "4 998"}, "• Baccarat Rouge\n  Саны: 2 дана\n  Бағасы: 4 998₸\n\n"},
+		{LangRu, OrderSummaryItem, []interface{}{"Baccarat Rouge", 2, "4 998"}, "• Baccarat Rouge\n  Количество: 2 шт.\n  Цена: 4 998₸\n\n"},
+		{"ru-RU", LanguageChosen, nil, "✅ Выбран русский язык"},
+		{"kk", LanguageChosen, nil, "✅ Қазақ тілі таңдалды"},
+		{"", LanguageChosen, nil, "✅ Қазақ тілі таңдалды"},
+		{"en", BuyButton, nil, "🛍 Сатып алу"},
+	}
+
+	for _, tt := range tests {
+		if got := T(tt.lang, tt.key, tt.args...); got != tt.want {
+			t.Errorf("T(%q, %q) = %q, want %q", tt.lang, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestTFallsBackToKazakh(t *testing.T) {
+	const key = "test_only_in_kz"
+	kz[key] = "тек қазақша %d"
+	t.Cleanup(func() { delete(kz, key) })
+
+	if got := T(LangRu, key, 5); got != "тек қазақша 5" {
+		t.Errorf("T(ru, missing key) = %q, want the Kazakh text", got)
+	}
+	if got := T(LangRu, "no_such_key"); got != "no_such_key" {
+		t.Errorf("T(ru, unknown key) = %q, want the key", got)
+	}
+}
+
+func TestCatalogsMatch(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+
+	for key, kzText := range kz {
+		ruText, ok := ru[key]
+		if !ok {
+			t.Errorf("%q is missing from the Russian catalog", key)
+			continue
+		}
+		if kzVerbs, ruVerbs := verbs.FindAllString(kzText, -1), verbs.FindAllString(ruText, -1); !slices.Equal(kzVerbs, ruVerbs) {
+			t.Errorf("%q: verbs %v in kz, %v in ru", key, kzVerbs, ruVerbs)
+		}
+	}
+	for key := range ru {
+		if _, ok := kz[key]; !ok {
+			t.Errorf("%q is missing from the Kazakh catalog", key)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	if got, want := All(BuyButton), []string{"🛍 Сатып алу", "🛍 Купить"}; !slices.Equal(got, want) {
+		t.Errorf("All(BuyButton) = %q, want %q", got, want)
+	}
+	if got := All("no_such_key"); got != nil {
+		t.Errorf("All(unknown key) = %q, want none", got)
+	}
+}
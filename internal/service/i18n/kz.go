@@ -0,0 +1,127 @@
+package i18n
+
+var kz = map[string]string{
+	LanguagePrompt:      "🌐 Тілді таңдаңыз",
+	LanguageButton:      "🇰🇿 Қазақша",
+	LanguageChosen:      "✅ Қазақ тілі таңдалды",
+	LanguageCommandDesc: "Тілді ауыстыру",
+
+	StartPromo:         "24990тгге 30мл парфюм сатып алып, 10мл, 30мллік парфюм , 89990тглік бриллант жүзік және 100 000 теңге ақшалай сыйлықтың біріне ие болыңыз.",
+	BuyButton:          "🛍 Сатып алу",
+	ChooseCount:        "🧪 Парфюм санын таңдаңыз немесе санмен жазып жіберіңіз",
+	CountInvalid:       "⚠️ Парфюм санын 1-ден %d-ге дейінгі санмен жазыңыз, мысалы: 50",
+	PayButton:          "💳 Төлем жасау",
+	PayInstructions:    "✅ Тамаша! Енді төмендегі сілтемеге өтіп %d теңге төлем жасап, төлемді растайтын чекті PDF форматында немесе скриншот ретінде ботқа кері жіберіңіз.",
+	ReceiptWrongFormat: "❌ Қате! Тек қана %s форматындағы файлдарды қабылдаймыз.",
+	ReceiptUnreadable:  "❌ Дұрыс емес форматтағы чек! 📄 Қайталап көріңіз.",
+	ReceiptAlreadyUsed: "⚠️ Бұл чек бұрын төленіп қойылған! 💳 ✅",
+	ReceiptAmountMismatch: "⚠️ Дұрыс емес сумма! 💰\n\n" +
+		"🔄 Көрсетілген сумаға сәйкес төлеңіз!\n" +
+		"📦 Немесе жиынтық суммасына сәйкес жиынтық санын түймелер таңдаңыз.\n\n" +
+		"Сіздң жиынтық саны: %d",
+	ReceiptWrongBin: "❌ Қате банк картасы! 💳\n\n" +
+		"🏦 Тек біздің серіктес банк картасымен төлем жасауға болады.\n" +
+		"📋 Дұрыс банк картасын пайдаланып қайталап көріңіз!",
+	ReceiptWrongPrice: "❌ Дұрыс емес сумма! 💰\n\n" +
+		"🔍 Төлем сомасы сәйкес келмейді.\n" +
+		"📄 Чекті қайталап тексеріп көріңіз!",
+	ReceiptInvalid: "❌ Дұрыс емес PDF файл! 📄\n\n" +
+		"🔄 Қайталап көріңіз немесе жаңа чек жүктеңіз.",
+	ReceiptRetry: "⚠️ Чекті өңдеу кезінде қате шықты! 😔\n\n" +
+		"🔄 Чекті қайта жіберіңіз.",
+	ReceiptManualReview: "📩 Чегіңіз қабылданды! 🔎\n\n" +
+		"👤 Оны әкімші тексереді, нәтижесі осы чатқа келеді. ⏳",
+	ReceiptRejected: "❌ Чегіңізді әкімші қабылдамады.\n\n" +
+		"📄 Төлемнің дұрыс чегін қайта жіберіңіз немесе себебін білу үшін бізге жазыңыз.",
+	ReceiptAccepted: "✅ Чек PDF сәтті қабылданды! 🎉\n\n" +
+		"📞 Сізбен кері байланысқа шығу үшін төмендегі\n" +
+		"📲 Контактіні бөлісу түймесін 👇 міндетті басыңыз.\n\n",
+	ShareContactButton: "📲 Контактіні бөлісу",
+	ShareContactPrompt: "Cізбен кері байланысқа шығу үшін контактіні 📲 бөлісу түймесін басыңыз.",
+	ContactInvalid:     "Телефон нөмірі танылмады. Қазақстандық нөміріңізді 📲 бөлісу түймесі арқылы жіберіңіз.",
+	ContactReceived: "✅ Контактіңіз сәтті алынды! 😊\n" +
+		"Парфюм жинақты қай мекен-жайға жеткізу керек екенін көрсетіңіз. 🚚\n" +
+		"⤵️ Мекен-жайыңызды енгізу үшін батырманы басыңыз👇",
+	AddressButton: "📍 Мекен-жайды енгізу",
+	SendReceiptHint: "📄 Төлем чегін PDF немесе скриншот ретінде жіберіңіз.\n\n" +
+		"↩️ Басынан бастау үшін төмендегі түймені басыңыз немесе /cancel жазыңыз.",
+	CancelButton: "↩️ Бастапқыға оралу",
+	CancelDone:   "↩️ Барлығы бастапқы қалпына келтірілді. Төленген тапсырыстарыңыз сақталады.",
+
+	OrderPlaced: "✅ Тапсырыс сәтті рәсімделді!\n\n" +
+		"📦 Тапсырыс №: %d\n" +
+		"👤 Клиент: %s\n" +
+		"📱 Телефон: %s\n" +
+		"📍 Мекенжай: %s\n\n" +
+		"🌸 Таңдалған парфюмдер:\n" +
+		"_%s_\n\n" +
+		"🚚 Жеткізу туралы ақпарат:\n" +
+		"Біздің менеджер сізбен 48 сағат ішінде байланысады.\n\n" +
+		"Рахмет! 💝",
+	OrderSummaryTitle: "🌟 *Lumen Парфюмерия* - Тапсырыс растауы\n\n" +
+		"📦 *Тапсырыс №:* `%d`\n\n" +
+		"🛒 *Сіздің тапсырысыңыз:*\n",
+	OrderSummaryItem: "• %s\n" +
+		"  Саны: %d дана\n" +
+		"  Бағасы: %s₸\n\n",
+	OrderSummaryTotal: "━━━━━━━━━━━━━━━━━━\n" +
+		"💰 *Жалпы сома: %s₸*\n\n" +
+		"Төлеу үшін төмендегі түймені басыңыз 👇",
+	OrderPayButton:       "💳 Төлеу жасау",
+	SupportButton:        "📞 Қолдау қызметі",
+	OrderPackedNotice:    "📦 Тапсырысыңыз жиналды және жөнелтуге дайын.",
+	OrderShippedNotice:   "🚚 Тапсырысыңыз жолға шықты! Жақында сізге жетеді.",
+	OrderDeliveredNotice: "✅ Тапсырысыңыз жеткізілді. Рахмет! 💝",
+	OrderCancelledNotice: "❌ Тапсырысыңыз жойылды. Сұрақтарыңыз болса, бізге жазыңыз.",
+	OrderNumberLine:      "🆔 Тапсырыс №: %d",
+	OrderPerfumesLine:    "🌸 Таңдалған парфюмдер: %s",
+
+	MyOrdersTitle:        "📦 Сіздің тапсырыстарыңыз (%d/%d бет):",
+	MyOrdersEmpty:        "📭 Сізде әлі тапсырыс жоқ. Сатып алу үшін /start басыңыз.",
+	MyOrdersItem:         "🆔 Тапсырыс №%d · %d дана\n🧴 %s\n🚚 Күйі: %s",
+	MyOrdersNoPerfumes:   "парфюм әлі таңдалмаған",
+	MyOrdersGift:         "🎁 Сыйлық: %s",
+	MyOrdersTickets:      "🎟 Лото-билеттеріңіз (%d): %s",
+	MyOrdersNoTickets:    "🎟 Лото-билеттеріңіз әлі жоқ.",
+	MyOrdersSelectButton: "🧴 №%d тапсырысқа парфюм таңдау",
+	MyOrdersCommandDesc:  "Менің тапсырыстарым мен билеттерім",
+	StartCommandDesc:     "Басынан бастау",
+	CancelCommandDesc:    "Бастапқыға оралу",
+	HelpCommandDesc:      "Командалар тізімі",
+	HelpTitle:            "ℹ️ Бот командалары:",
+
+	OrderStatusAwaitingPayment: "⏳ Төлем күтілуде",
+	OrderStatusPaid:            "💳 Төленді",
+	OrderStatusPerfumeSelected: "🧴 Парфюм таңдалды",
+	OrderStatusAddressProvided: "📍 Мекенжай алынды",
+	OrderStatusPacked:          "📦 Жинақталды",
+	OrderStatusShipped:         "🚚 Жолда",
+	OrderStatusDelivered:       "✅ Жеткізілді",
+	OrderStatusCancelled:       "❌ Тоқтатылды",
+
+	PrizeWon: "🎉 Құттықтаймыз! Сіз сыйлық ұттыңыз! 🎉\n\n" +
+		"🏆 Сіздің сыйлығыңыз: %s\n\n" +
+		"📦 Тапсырыс мәліметтері:\n" +
+		"🆔 Тапсырыс №: %d\n" +
+		"👤 Тапсырыс беруші: %s\n" +
+		"📱 Телефон: %s\n" +
+		"📍 Мекенжай: %s\n\n" +
+		"🌸 Таңдалған парфюмдер:\n%s\n\n" +
+		"🚚 Жеткізу туралы ақпарат:\n" +
+		"Біздің менеджер сізбен 24 сағат ішінде байланысады.\n" +
+		"Сыйлығыңыз парфюммен бірге жеткізіледі.\n\n" +
+		"Рахмет! 💝",
+	Prize10ML:    "🧪 10мл парфюм",
+	Prize30ML:    "🧪 30мл парфюм",
+	PrizeDiamond: "💍 Бриллиант сақина",
+	PrizeMoney:   "💰 100,000 теңге",
+
+	PrizeClaimReminder: "🎁 Сіз №%[2]d тапсырыс бойынша %[1]s ұтып алдыңыз, бірақ оны алу үшін әлі мекенжай қалдырмадыңыз.",
+	PrizeClaimDaysLeft: "⏳ Сыйлықты алуға %d күн қалды, одан кейін ол күшін жояды.",
+	PrizeClaimButton:   "🎁 Сыйлықты алу",
+	PrizeClaimExpired: "⌛️ №%[2]d тапсырыс бойынша ұтқан %[1]s сыйлығыңыздың мерзімі өтті: оны алу үшін мекенжай уақытында қалдырылмады.\n\n" +
+		"Сұрақтарыңыз болса, бізге жазыңыз.",
+
+	LotoWinner: "🎉 Құттықтаймыз! Сіздің №%d лото билетіңіз ұтыста жеңді! 🎉\n\n" +
+		"Жүлдені тапсыру үшін біздің менеджер сізбен жақын арада байланысады.",
+}
@@ -0,0 +1,127 @@
+package i18n
+
+var ru = map[string]string{
+	LanguagePrompt:      "🌐 Выберите язык",
+	LanguageButton:      "🇷🇺 Русский",
+	LanguageChosen:      "✅ Выбран русский язык",
+	LanguageCommandDesc: "Сменить язык",
+
+	StartPromo:         "Купите парфюм 30мл за 24990тг и получите один из подарков: парфюм 10мл или 30мл, бриллиантовое кольцо за 89990тг или 100 000 тенге деньгами.",
+	BuyButton:          "🛍 Купить",
+	ChooseCount:        "🧪 Выберите количество парфюмов или отправьте его числом",
+	CountInvalid:       "⚠️ Отправьте количество парфюмов числом от 1 до %d, например: 50",
+	PayButton:          "💳 Оплатить",
+	PayInstructions:    "✅ Отлично! Перейдите по ссылке ниже, оплатите %d тенге и отправьте боту чек об оплате в формате PDF или скриншотом.",
+	ReceiptWrongFormat: "❌ Ошибка! Мы принимаем только файлы в формате %s.",
+	ReceiptUnreadable:  "❌ Чек в неверном формате! 📄 Попробуйте ещё раз.",
+	ReceiptAlreadyUsed: "⚠️ Этот чек уже был оплачен! 💳 ✅",
+	ReceiptAmountMismatch: "⚠️ Неверная сумма! 💰\n\n" +
+		"🔄 Оплатите указанную сумму!\n" +
+		"📦 Или выберите кнопками количество наборов, соответствующее сумме.\n\n" +
+		"Количество наборов по вашей сумме: %d",
+	ReceiptWrongBin: "❌ Неверная банковская карта! 💳\n\n" +
+		"🏦 Оплатить можно только картой нашего банка-партнёра.\n" +
+		"📋 Попробуйте ещё раз с правильной картой!",
+	ReceiptWrongPrice: "❌ Неверная сумма! 💰\n\n" +
+		"🔍 Сумма оплаты не совпадает.\n" +
+		"📄 Проверьте чек и попробуйте ещё раз!",
+	ReceiptInvalid: "❌ Неверный PDF файл! 📄\n\n" +
+		"🔄 Попробуйте ещё раз или загрузите новый чек.",
+	ReceiptRetry: "⚠️ Не удалось обработать чек! 😔\n\n" +
+		"🔄 Отправьте чек ещё раз.",
+	ReceiptManualReview: "📩 Ваш чек получен! 🔎\n\n" +
+		"👤 Его проверит администратор, результат придёт в этот чат. ⏳",
+	ReceiptRejected: "❌ Администратор отклонил ваш чек.\n\n" +
+		"📄 Отправьте правильный чек об оплате ещё раз или напишите нам, чтобы узнать причину.",
+	ReceiptAccepted: "✅ Чек PDF успешно принят! 🎉\n\n" +
+		"📞 Чтобы мы могли с вами связаться, обязательно нажмите\n" +
+		"📲 кнопку «Поделиться контактом» ниже 👇\n\n",
+	ShareContactButton: "📲 Поделиться контактом",
+	ShareContactPrompt: "Чтобы мы могли с вами связаться, нажмите кнопку 📲 «Поделиться контактом».",
+	ContactInvalid:     "Не удалось распознать номер телефона. Отправьте ваш казахстанский номер кнопкой 📲 «Поделиться контактом».",
+	ContactReceived: "✅ Контакт успешно получен! 😊\n" +
+		"Укажите, по какому адресу доставить парфюмерный набор. 🚚\n" +
+		"⤵️ Нажмите кнопку, чтобы ввести адрес👇",
+	AddressButton: "📍 Ввести адрес",
+	SendReceiptHint: "📄 Отправьте чек об оплате в формате PDF или скриншотом.\n\n" +
+		"↩️ Чтобы начать заново, нажмите кнопку ниже или напишите /cancel.",
+	CancelButton: "↩️ Вернуться в начало",
+	CancelDone:   "↩️ Всё сброшено, можно начать заново. Оплаченные заказы сохранены.",
+
+	OrderPlaced: "✅ Заказ успешно оформлен!\n\n" +
+		"📦 Заказ №: %d\n" +
+		"👤 Клиент: %s\n" +
+		"📱 Телефон: %s\n" +
+		"📍 Адрес: %s\n\n" +
+		"🌸 Выбранные парфюмы:\n" +
+		"_%s_\n\n" +
+		"🚚 Информация о доставке:\n" +
+		"Наш менеджер свяжется с вами в течение 48 часов.\n\n" +
+		"Спасибо! 💝",
+	OrderSummaryTitle: "🌟 *Lumen Парфюмерия* - Подтверждение заказа\n\n" +
+		"📦 *Заказ №:* `%d`\n\n" +
+		"🛒 *Ваш заказ:*\n",
+	OrderSummaryItem: "• %s\n" +
+		"  Количество: %d шт.\n" +
+		"  Цена: %s₸\n\n",
+	OrderSummaryTotal: "━━━━━━━━━━━━━━━━━━\n" +
+		"💰 *Итого: %s₸*\n\n" +
+		"Чтобы оплатить, нажмите кнопку ниже 👇",
+	OrderPayButton:       "💳 Оплатить",
+	SupportButton:        "📞 Служба поддержки",
+	OrderPackedNotice:    "📦 Ваш заказ собран и готов к отправке.",
+	OrderShippedNotice:   "🚚 Ваш заказ в пути! Скоро он будет у вас.",
+	OrderDeliveredNotice: "✅ Ваш заказ доставлен. Спасибо! 💝",
+	OrderCancelledNotice: "❌ Ваш заказ отменён. Если у вас есть вопросы, напишите нам.",
+	OrderNumberLine:      "🆔 Заказ №: %d",
+	OrderPerfumesLine:    "🌸 Выбранные парфюмы: %s",
+
+	MyOrdersTitle:        "📦 Ваши заказы (стр. %d/%d):",
+	MyOrdersEmpty:        "📭 У вас пока нет заказов. Чтобы купить, нажмите /start.",
+	MyOrdersItem:         "🆔 Заказ №%d · %d шт.\n🧴 %s\n🚚 Статус: %s",
+	MyOrdersNoPerfumes:   "парфюмы ещё не выбраны",
+	MyOrdersGift:         "🎁 Подарок: %s",
+	MyOrdersTickets:      "🎟 Ваши лото-билеты (%d): %s",
+	MyOrdersNoTickets:    "🎟 Лото-билетов пока нет.",
+	MyOrdersSelectButton: "🧴 Выбрать парфюмы к заказу №%d",
+	MyOrdersCommandDesc:  "Мои заказы и билеты",
+	StartCommandDesc:     "Начать сначала",
+	CancelCommandDesc:    "Вернуться в начало",
+	HelpCommandDesc:      "Список команд",
+	HelpTitle:            "ℹ️ Команды бота:",
+
+	OrderStatusAwaitingPayment: "⏳ Ожидает оплаты",
+	OrderStatusPaid:            "💳 Оплачен",
+	OrderStatusPerfumeSelected: "🧴 Парфюмы выбраны",
+	OrderStatusAddressProvided: "📍 Адрес получен",
+	OrderStatusPacked:          "📦 Собран",
+	OrderStatusShipped:         "🚚 В пути",
+	OrderStatusDelivered:       "✅ Доставлен",
+	OrderStatusCancelled:       "❌ Отменён",
+
+	PrizeWon: "🎉 Поздравляем! Вы выиграли подарок! 🎉\n\n" +
+		"🏆 Ваш подарок: %s\n\n" +
+		"📦 Детали заказа:\n" +
+		"🆔 Заказ №: %d\n" +
+		"👤 Получатель: %s\n" +
+		"📱 Телефон: %s\n" +
+		"📍 Адрес: %s\n\n" +
+		"🌸 Выбранные парфюмы:\n%s\n\n" +
+		"🚚 Информация о доставке:\n" +
+		"Наш менеджер свяжется с вами в течение 24 часов.\n" +
+		"Подарок будет доставлен вместе с парфюмом.\n\n" +
+		"Спасибо! 💝",
+	Prize10ML:    "🧪 Парфюм 10мл",
+	Prize30ML:    "🧪 Парфюм 30мл",
+	PrizeDiamond: "💍 Бриллиантовое кольцо",
+	PrizeMoney:   "💰 100,000 тенге",
+
+	PrizeClaimReminder: "🎁 Вы выиграли %[1]s по заказу №%[2]d, но ещё не оставили адрес, чтобы его получить.",
+	PrizeClaimDaysLeft: "⏳ Осталось дней, чтобы забрать подарок: %d, после этого он сгорит.",
+	PrizeClaimButton:   "🎁 Получить подарок",
+	PrizeClaimExpired: "⌛️ Срок вашего подарка %[1]s по заказу №%[2]d истёк: адрес для его получения не был оставлен вовремя.\n\n" +
+		"Если у вас есть вопросы, напишите нам.",
+
+	LotoWinner: "🎉 Поздравляем! Ваш лото-билет №%d выиграл в розыгрыше! 🎉\n\n" +
+		"Наш менеджер скоро свяжется с вами, чтобы передать приз.",
+}
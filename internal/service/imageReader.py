@@ -0,0 +1,43 @@
+import sys
+import json
+
+from PIL import Image
+import pytesseract
+
+from pdfReader import determine_language, filter_receipt_lines
+
+
+class ImageReceiptReader:
+    def __init__(self, file_path):
+        self.file_path = file_path
+
+    def extract_text(self):
+        """Распознаёт текст на фото/скриншоте чека (русский и казахский)."""
+        with Image.open(self.file_path) as image:
+            return pytesseract.image_to_string(image.convert('L'), lang='rus+kaz')
+
+    def extract_detailed_info(self):
+        """Возвращает строки чека в том же формате, что и PDFReaders."""
+        text = self.extract_text()
+        return filter_receipt_lines([text], determine_language(text))
+
+
+def main():
+    if len(sys.argv) != 2:
+        print(json.dumps(["Error: No file path provided"]), flush=True)
+        sys.exit(1)
+
+    file_path = sys.argv[1]
+
+    try:
+        reader = ImageReceiptReader(file_path)
+        print(json.dumps(reader.extract_detailed_info(), ensure_ascii=False), flush=True)
+    except FileNotFoundError:
+        print(json.dumps([f"Error: File not found: {file_path}"]), flush=True)
+        sys.exit(1)
+    except Exception as e:
+        print(json.dumps([f"Error: {str(e)}"]), flush=True)
+        sys.exit(1)
+
+if __name__ == "__main__":
+    main()
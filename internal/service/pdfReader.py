@@ -2,6 +2,52 @@ import PyPDF2
 import sys
 import json
 
+# Ключевые слова строк чека, которые нужны для проверки оплаты
+RECEIPT_KEYWORDS = {
+    'kazakh': [
+        "Фискалдық түбіртек", "ИП", "Төлем сәтті өтті", "₸", "Сату", "Фото и видео",
+        "Түбіртек №", "QR", "Күні мен уақыты", "Төленді", "Мекенжай", 
+        "Сатушының ЖСН/БСН", "Сатып алушының аты-жөні", "МТН", "МЗН", "ФБ", "ФДО"
+    ],
+    'russian': [
+        "Фискальный чек", "ИП", "Платеж успешно совершен", "₸", "Продажа", "Фото и видео",
+        "№ чека", "QR", "Дата и время", "Оплачено", "Адрес", 
+        "ИИН/БИН продавца", "ФИО покупателя", "РНМ", "ЗНМ", "ФП", "ОФД"
+    ],
+}
+
+
+def determine_language(first_page_text):
+    """Определяет язык чека по ключевым словам на первой странице."""
+    if "Счет на оплату" in first_page_text or "Фискальный чек" in first_page_text:
+        return 'russian'
+    elif "Төлем шоты" in first_page_text or "Фискалдық түбіртек" in first_page_text:
+        return 'kazakh'
+    elif "Сатып алғаным" in first_page_text:
+        return 'kazakh'
+    elif "Покупки" in first_page_text:
+        return 'russian'
+    return 'unknown'
+
+
+def filter_receipt_lines(texts, language):
+    """Извлекает каждую строку с ключевым словом как отдельный элемент массива."""
+    specific_keywords = RECEIPT_KEYWORDS.get(language)
+    if specific_keywords is None:
+        return ["Language not recognized."]
+
+    # Извлечение строк, содержащих ключевые слова
+    result_lines = []
+    for text in texts:
+        if text:  # Проверяем, что текст не пустой
+            for line in text.split('\n'):
+                clean_line = line.strip()
+                if clean_line and any(keyword in clean_line for keyword in specific_keywords):
+                    result_lines.append(clean_line)
+
+    return result_lines
+
+
 class PDFReaders:
     def __init__(self, file_path):
         self.file_path = file_path
@@ -32,50 +78,14 @@ class PDFReaders:
     def determine_language(self):
         """Определяет язык PDF по ключевым словам на первой странице."""
         if self.reader:
-            first_page_text = self.extract_text_from_page(0)
-            if "Счет на оплату" in first_page_text or "Фискальный чек" in first_page_text:
-                return 'russian'
-            elif "Төлем шоты" in first_page_text or "Фискалдық түбіртек" in first_page_text:
-                return 'kazakh'
-            elif "Сатып алғаным" in first_page_text:
-                return 'kazakh'
-            elif "Покупки" in first_page_text:
-                return 'russian'
+            return determine_language(self.extract_text_from_page(0))
         return 'unknown'
     
     def extract_detailed_info(self):
         """Извлекает каждую строку как отдельный элемент массива, учитывая ключевые слова на казахском и русском языках."""
         language = self.determine_language()
-        
-        # Определение ключевых слов в зависимости от языка
-        if language == 'kazakh':
-            specific_keywords = [
-                "Фискалдық түбіртек", "ИП", "Төлем сәтті өтті", "₸", "Сату", "Фото и видео",
-                "Түбіртек №", "QR", "Күні мен уақыты", "Төленді", "Мекенжай", 
-                "Сатушының ЖСН/БСН", "Сатып алушының аты-жөні", "МТН", "МЗН", "ФБ", "ФДО"
-            ]
-        elif language == 'russian':
-            specific_keywords = [
-                "Фискальный чек", "ИП", "Платеж успешно совершен", "₸", "Продажа", "Фото и видео",
-                "№ чека", "QR", "Дата и время", "Оплачено", "Адрес", 
-                "ИИН/БИН продавца", "ФИО покупателя", "РНМ", "ЗНМ", "ФП", "ОФД"
-            ]
-        else:
-            return ["Language not recognized."]
-
-        # Извлечение строк, содержащих ключевые слова
-        result_lines = []
-        number_of_pages = self.get_number_of_pages()
-        for page_num in range(number_of_pages):
-            text = self.extract_text_from_page(page_num)
-            if text:  # Проверяем, что текст не пустой
-                lines = text.split('\n')
-                for line in lines:
-                    clean_line = line.strip()
-                    if clean_line and any(keyword in clean_line for keyword in specific_keywords):
-                        result_lines.append(clean_line)
-                        
-        return result_lines
+        texts = [self.extract_text_from_page(page_num) for page_num in range(self.get_number_of_pages())]
+        return filter_receipt_lines(texts, language)
 
 def main():
     # Check if file path is provided as command line argument
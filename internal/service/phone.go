@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPhone is returned by NormalizePhone for input that isn't a phone number
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// PhoneDigits reduces a Kazakhstan phone number to its digits with the 7 country
+// code, so "+7 701 123-45-67", "8 (701) 1234567" and "7011234567" all become
+// "77011234567". Partial numbers keep their digits; a leading 8 still becomes 7.
+func PhoneDigits(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+
+	normalized := digits.String()
+	switch {
+	case len(normalized) == 10 && strings.HasPrefix(normalized, "7"):
+		// Local number without the country code
+		return "7" + normalized
+	case strings.HasPrefix(normalized, "8"):
+		return "7" + normalized[1:]
+	}
+	return normalized
+}
+
+// NormalizePhone turns a Kazakhstan phone number in any of the usual formats into
+// "+7XXXXXXXXXX", the form contacts are stored in. Anything but digits, spaces, dashes,
+// brackets and a leading + is rejected, as is a number that isn't 10 digits after the
+// country code.
+func NormalizePhone(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9', r == ' ', r == '-', r == '(', r == ')':
+		case r == '+' && i == 0:
+		default:
+			return "", fmt.Errorf("%w: unexpected %q in %q", ErrInvalidPhone, r, raw)
+		}
+	}
+
+	digits := PhoneDigits(raw)
+	if len(digits) != 11 || !strings.HasPrefix(digits, "7") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPhone, raw)
+	}
+	return "+" + digits, nil
+}
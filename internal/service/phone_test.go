@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPhoneDigits(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{"+77011234567", "77011234567"},
+		{"+7 701 123-45-67", "77011234567"},
+		{"8 (701) 123 45 67", "77011234567"},
+		{"87011234567", "77011234567"},
+		{"7011234567", "77011234567"},
+		{"701-123", "701123"},
+		{"8701", "7701"},
+		{"Айгерим", ""},
+	}
+
+	for _, tt := range tests {
+		if got := PhoneDigits(tt.phone); got != tt.want {
+			t.Errorf("PhoneDigits(%q) = %q, want %q", tt.phone, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	valid := []string{
+		"+77011234567",
+		"77011234567",
+		"87011234567",
+		"7011234567",
+		"+7 701 123-45-67",
+		"8 (701) 123 45 67",
+		"  +7 (701) 1234567 ",
+	}
+	for _, phone := range valid {
+		if got, err := NormalizePhone(phone); err != nil || got != "+77011234567" {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want +77011234567", phone, got, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"12345",
+		"+770112345",    // a digit short
+		"+770112345678", // a digit too many
+		"+17011234567",  // another country
+		"7 701 123 45 67 ext 2",
+		"7701+1234567",
+		"Айгерим",
+	}
+	for _, phone := range invalid {
+		if got, err := NormalizePhone(phone); !errors.Is(err, ErrInvalidPhone) {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want ErrInvalidPhone", phone, got, err)
+		}
+	}
+}
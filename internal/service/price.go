@@ -0,0 +1,33 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FormatPrice formats an amount with space thousand separators, e.g. -1234567 -> "-1 234 567"
+func FormatPrice(price int) string {
+	priceStr := strconv.Itoa(price)
+
+	// Keep the sign out of the digit grouping
+	sign := ""
+	if strings.HasPrefix(priceStr, "-") {
+		sign = "-"
+		priceStr = priceStr[1:]
+	}
+
+	if len(priceStr) <= 3 {
+		return sign + priceStr
+	}
+
+	var result strings.Builder
+	result.WriteString(sign)
+	for i, digit := range priceStr {
+		if i > 0 && (len(priceStr)-i)%3 == 0 {
+			result.WriteString(" ")
+		}
+		result.WriteRune(digit)
+	}
+
+	return result.String()
+}
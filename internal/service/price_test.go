@@ -0,0 +1,29 @@
+package service
+
+import "testing"
+
+func TestFormatPrice(t *testing.T) {
+	tests := []struct {
+		price int
+		want  string
+	}{
+		{0, "0"},
+		{7, "7"},
+		{999, "999"},
+		{2499, "2 499"},
+		{100000, "100 000"},
+		{999999, "999 999"},
+		{1234567, "1 234 567"},
+		{-5, "-5"},
+		{-999, "-999"},
+		{-2499, "-2 499"},
+		{-123456, "-123 456"},
+		{-1234567, "-1 234 567"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatPrice(tt.price); got != tt.want {
+			t.Errorf("FormatPrice(%d) = %q, want %q", tt.price, got, tt.want)
+		}
+	}
+}
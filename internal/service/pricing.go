@@ -0,0 +1,22 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"parfum/internal/domain"
+)
+
+var ErrUnknownVolume = errors.New("no price for volume")
+
+// OrderPrice sums the lines of an order using the per-volume price list
+func OrderPrice(prices map[string]int, lines []domain.PriceLine) (int, error) {
+	total := 0
+	for _, line := range lines {
+		price, ok := prices[line.Volume]
+		if !ok {
+			return 0, fmt.Errorf("%w %q", ErrUnknownVolume, line.Volume)
+		}
+		total += price * line.Quantity
+	}
+	return total, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"parfum/config"
+	"parfum/internal/domain"
+)
+
+func TestOrderPrice(t *testing.T) {
+	prices := map[string]int{"10ml": 999, "30ml": 2499}
+
+	tests := []struct {
+		name  string
+		lines []domain.PriceLine
+		want  int
+	}{
+		{"empty", nil, 0},
+		{"one volume", []domain.PriceLine{{Volume: "30ml", Quantity: 3}}, 7497},
+		{"mixed", []domain.PriceLine{{Volume: "10ml", Quantity: 2}, {Volume: "30ml", Quantity: 1}}, 4497},
+	}
+	for _, tt := range tests {
+		got, err := OrderPrice(prices, tt.lines)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: OrderPrice = %d, %v; want %d", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := OrderPrice(prices, []domain.PriceLine{{Volume: "50ml", Quantity: 1}}); !errors.Is(err, ErrUnknownVolume) {
+		t.Errorf("OrderPrice of an unpriced volume = %v, want ErrUnknownVolume", err)
+	}
+}
+
+func TestValidatorPricesMixedOrder(t *testing.T) {
+	cfg := &config.Config{
+		Bin:           951125301078,
+		Prices:        map[string]int{"10ml": 999, "30ml": 2499},
+		DefaultVolume: "30ml",
+	}
+	lines := []domain.PriceLine{{Volume: "10ml", Quantity: 2}, {Volume: "30ml", Quantity: 1}}
+
+	tests := []struct {
+		name   string
+		actual int
+		want   error
+	}{
+		{"summed price", 2*999 + 2499, nil},
+		{"flat price of three kits", 3 * 2499, ErrWrongPrice},
+		{"only the 30ml", 2499, ErrWrongPrice},
+	}
+	for _, tt := range tests {
+		pdf := domain.PdfResult{Total: 3, Lines: lines, ActualPrice: tt.actual, Bin: cfg.Bin}
+		if err := Validator(cfg, pdf); err != tt.want {
+			t.Errorf("%s: Validator = %v, want %v", tt.name, err, tt.want)
+		}
+		if err := ValidatorWithDetails(cfg, pdf); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("%s: ValidatorWithDetails = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	var validationErr ValidationError
+	err := ValidatorWithDetails(cfg, domain.PdfResult{Lines: []domain.PriceLine{{Volume: "50ml", Quantity: 1}}, ActualPrice: 5000, Bin: cfg.Bin})
+	if !errors.As(err, &validationErr) || validationErr.Type != "unknown_volume" || validationErr.Field != "lines" {
+		t.Errorf("ValidatorWithDetails of an unpriced volume = %v, want an unknown_volume error", err)
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Receipt MIME types the bot knows how to read
+const (
+	ReceiptMimePDF  = "application/pdf"
+	ReceiptMimeJPEG = "image/jpeg"
+	ReceiptMimePNG  = "image/png"
+	ReceiptMimeWebP = "image/webp"
+)
+
+// receiptExtensions is the file extension a saved receipt gets for each MIME type
+var receiptExtensions = map[string]string{
+	ReceiptMimePDF:  ".pdf",
+	ReceiptMimeJPEG: ".jpg",
+	ReceiptMimePNG:  ".png",
+	ReceiptMimeWebP: ".webp",
+}
+
+// ReceiptExtension returns the extension to save a receipt with, or "" if the
+// MIME type can't be read at all
+func ReceiptExtension(mimeType string) string {
+	return receiptExtensions[mimeType]
+}
+
+// IsImageReceipt reports whether the receipt goes through the image (OCR) path
+func IsImageReceipt(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/")
+}
+
+// ReadReceipt extracts the receipt lines from a saved PDF or image receipt.
+// Both paths return lines in the same layout, so the caller parses them the same way.
+func ReadReceipt(filePath, mimeType string) ([]string, error) {
+	if IsImageReceipt(mimeType) {
+		return ReadReceiptImage(filePath)
+	}
+	return ReadPDF(filePath)
+}
+
+// ReadReceiptImage recognises the text of a photo/screenshot receipt with the
+// imageReader.py OCR script
+func ReadReceiptImage(filePath string) ([]string, error) {
+	absFilePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	if _, err := os.Stat(absFilePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("image file does not exist: %s", absFilePath)
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	scriptDir := filepath.Join(workDir, "internal", "service")
+	pythonScriptPath := filepath.Join(scriptDir, "imageReader.py")
+	if _, err := os.Stat(pythonScriptPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("Python script not found: %s", pythonScriptPath)
+	}
+
+	// Run from the script directory so it can import the shared parsing from pdfReader.py
+	cmd := exec.Command("python3", pythonScriptPath, absFilePath)
+	cmd.Dir = scriptDir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute Python script: %w\nOutput: %s", err, string(output))
+	}
+
+	outputStr := strings.TrimSpace(string(output))
+	if outputStr == "" {
+		return []string{}, nil
+	}
+
+	lines, err := parsePythonListOutput(outputStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Python output: %w", err)
+	}
+
+	return lines, nil
+}
+
+// receiptSuccessHeader opens the receipts where the amount comes right after the header
+const receiptSuccessHeader = "Платеж успешно совершен"
+
+// Receipt fields ReceiptParseError can report
+const (
+	ReceiptFieldLines  = "lines"
+	ReceiptFieldAmount = "amount"
+	ReceiptFieldBin    = "bin"
+)
+
+// ReceiptFields are the values the bot checks on a read receipt
+type ReceiptFields struct {
+	Price int
+	QR    string
+	Bin   int
+}
+
+// ReceiptParseError says which field of the receipt lines couldn't be read; such
+// receipts go to an admin instead
+type ReceiptParseError struct {
+	Field string // one of the ReceiptField constants
+	Value string // the text that failed to parse, empty when lines are missing
+	Lines int    // how many lines the receipt had
+}
+
+func (e *ReceiptParseError) Error() string {
+	if e.Field == ReceiptFieldLines {
+		return fmt.Sprintf("receipt has too few lines: %d", e.Lines)
+	}
+	return fmt.Sprintf("receipt %s can't be read: %q", e.Field, e.Value)
+}
+
+// ParseReceiptLines picks the amount, QR and BIN out of the lines ReadReceipt returns.
+// Receipts opening with the success header carry the amount in line 1, the others in
+// line 2; the QR and BIN follow it. Any other input gives a *ReceiptParseError.
+func ParseReceiptLines(lines []string) (ReceiptFields, error) {
+	first := 2
+	if len(lines) > 0 && lines[0] == receiptSuccessHeader {
+		first = 1
+	}
+	if len(lines) < first+3 {
+		return ReceiptFields{}, &ReceiptParseError{Field: ReceiptFieldLines, Lines: len(lines)}
+	}
+
+	price, err := ParsePrice(lines[first])
+	if err != nil {
+		return ReceiptFields{}, &ReceiptParseError{Field: ReceiptFieldAmount, Value: lines[first], Lines: len(lines)}
+	}
+	bin, err := ParsePrice(lines[first+2])
+	if err != nil {
+		return ReceiptFields{}, &ReceiptParseError{Field: ReceiptFieldBin, Value: lines[first+2], Lines: len(lines)}
+	}
+
+	return ReceiptFields{Price: price, QR: lines[first+1], Bin: bin}, nil
+}
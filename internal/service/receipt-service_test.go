@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReceiptExtension(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		want     string
+		image    bool
+	}{
+		{ReceiptMimePDF, ".pdf", false},
+		{ReceiptMimeJPEG, ".jpg", true},
+		{ReceiptMimePNG, ".png", true},
+		{ReceiptMimeWebP, ".webp", true},
+		{"image/gif", "", true},
+		{"application/msword", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := ReceiptExtension(tt.mimeType); got != tt.want {
+			t.Errorf("ReceiptExtension(%q) = %q, want %q", tt.mimeType, got, tt.want)
+		}
+		if got := IsImageReceipt(tt.mimeType); got != tt.image {
+			t.Errorf("IsImageReceipt(%q) = %v, want %v", tt.mimeType, got, tt.image)
+		}
+	}
+}
+
+func TestParseReceiptLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		want      ReceiptFields
+		wantField string // the field of the ReceiptParseError, "" for none
+	}{
+		{
+			name:  "success header",
+			lines: []string{"Платеж успешно совершен", "4 998 ₸", "QR123", "951125301078"},
+			want:  ReceiptFields{Price: 4998, QR: "QR123", Bin: 951125301078},
+		},
+		{
+			name:  "without the header",
+			lines: []string{"Kaspi.kz", "Перевод", "2 499 ₸", "QR456", "951125301078"},
+			want:  ReceiptFields{Price: 2499, QR: "QR456", Bin: 951125301078},
+		},
+		{
+			name:      "four lines without the header",
+			lines:     []string{"Kaspi.kz", "Перевод", "2 499 ₸", "QR456"},
+			wantField: ReceiptFieldLines,
+		},
+		{
+			name:      "header only",
+			lines:     []string{"Платеж успешно совершен", "4 998 ₸", "QR123"},
+			wantField: ReceiptFieldLines,
+		},
+		{
+			name:      "nothing read",
+			wantField: ReceiptFieldLines,
+		},
+		{
+			name:      "amount without digits",
+			lines:     []string{"Платеж успешно совершен", "сумма", "QR123", "951125301078"},
+			wantField: ReceiptFieldAmount,
+		},
+		{
+			name:      "BIN without digits",
+			lines:     []string{"Kaspi.kz", "Перевод", "2 499 ₸", "QR456", "БИН не указан"},
+			wantField: ReceiptFieldBin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReceiptLines(tt.lines)
+			if tt.wantField == "" {
+				if err != nil || got != tt.want {
+					t.Errorf("ParseReceiptLines = %+v, %v; want %+v", got, err, tt.want)
+				}
+				return
+			}
+
+			var parseErr *ReceiptParseError
+			if !errors.As(err, &parseErr) || parseErr.Field != tt.wantField {
+				t.Errorf("ParseReceiptLines error = %v, want a %s parse error", err, tt.wantField)
+			}
+		})
+	}
+}
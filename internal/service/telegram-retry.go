@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+// Backoff between Telegram send attempts: 1s, 2s, 4s, ... up to sendMaxDelay.
+// A 429 response waits for its retry_after instead.
+var (
+	sendBaseDelay = time.Second
+	sendMaxDelay  = 30 * time.Second
+)
+
+// SendWithRetry calls send up to attempts times, backing off exponentially between
+// transient failures (429, 5xx, network errors). It returns the last error, or nil
+// once a call succeeds. It gives up early if ctx is done.
+func SendWithRetry(ctx context.Context, attempts int, send func(ctx context.Context) error) error {
+	delay := sendBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := send(ctx)
+		if err == nil || attempt >= attempts || !IsRetryableSendError(err) {
+			return err
+		}
+
+		wait := delay
+		var tooMany *bot.TooManyRequestsError
+		if errors.As(err, &tooMany) && tooMany.RetryAfter > 0 {
+			wait = time.Duration(tooMany.RetryAfter) * time.Second
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay = min(delay*2, sendMaxDelay)
+	}
+}
+
+// IsRetryableSendError reports whether a Telegram send may succeed if repeated.
+// Client errors (blocked bot, bad chat ID, bad token, ...) never will.
+func IsRetryableSendError(err error) bool {
+	var migrate *bot.MigrateError
+	switch {
+	case errors.Is(err, bot.ErrorForbidden),
+		errors.Is(err, bot.ErrorBadRequest),
+		errors.Is(err, bot.ErrorUnauthorized),
+		errors.Is(err, bot.ErrorNotFound),
+		errors.Is(err, bot.ErrorConflict),
+		errors.As(err, &migrate),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+// fastBackoff shortens the retry delays for the duration of a test
+func fastBackoff(t *testing.T) {
+	t.Helper()
+
+	base, maxDelay := sendBaseDelay, sendMaxDelay
+	sendBaseDelay, sendMaxDelay = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { sendBaseDelay, sendMaxDelay = base, maxDelay })
+}
+
+// stubSender fails with errs in turn, then succeeds
+type stubSender struct {
+	errs  []error
+	calls int
+}
+
+func (s *stubSender) send(ctx context.Context) error {
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return s.errs[s.calls-1]
+	}
+	return nil
+}
+
+func TestSendWithRetrySucceedsAfterTwoFailures(t *testing.T) {
+	fastBackoff(t)
+	sender := &stubSender{errs: []error{
+		errors.New("error response from telegram: 502 Bad Gateway"),
+		errors.New("connection reset by peer"),
+	}}
+
+	if err := SendWithRetry(context.Background(), 3, sender.send); err != nil {
+		t.Fatalf("SendWithRetry = %v, want nil", err)
+	}
+	if sender.calls != 3 {
+		t.Errorf("sent %d times, want 3", sender.calls)
+	}
+}
+
+func TestSendWithRetryGivesUpAfterAttempts(t *testing.T) {
+	fastBackoff(t)
+	last := errors.New("503 Service Unavailable")
+	sender := &stubSender{errs: []error{errors.New("500"), errors.New("502"), last, nil}}
+
+	if err := SendWithRetry(context.Background(), 3, sender.send); err != last {
+		t.Errorf("SendWithRetry = %v, want the last error %v", err, last)
+	}
+	if sender.calls != 3 {
+		t.Errorf("sent %d times, want 3", sender.calls)
+	}
+}
+
+func TestSendWithRetryStopsOnClientErrors(t *testing.T) {
+	fastBackoff(t)
+
+	for _, err := range []error{
+		fmt.Errorf("%w, Forbidden: bot was blocked by the user", bot.ErrorForbidden),
+		fmt.Errorf("%w, Bad Request: chat not found", bot.ErrorBadRequest),
+		bot.ErrorUnauthorized,
+		&bot.MigrateError{Message: "migrated", MigrateToChatID: 1},
+		context.Canceled,
+	} {
+		sender := &stubSender{errs: []error{err}}
+		if got := SendWithRetry(context.Background(), 3, sender.send); got != err {
+			t.Errorf("SendWithRetry = %v, want %v", got, err)
+		}
+		if sender.calls != 1 {
+			t.Errorf("%v: sent %d times, want once", err, sender.calls)
+		}
+	}
+}
+
+func TestSendWithRetryHonorsRetryAfter(t *testing.T) {
+	fastBackoff(t)
+	sender := &stubSender{errs: []error{&bot.TooManyRequestsError{Message: "Too Many Requests", RetryAfter: 1}}}
+
+	start := time.Now()
+	if err := SendWithRetry(context.Background(), 2, sender.send); err != nil {
+		t.Fatalf("SendWithRetry = %v, want nil", err)
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %v, want the 1s retry_after", waited)
+	}
+	if sender.calls != 2 {
+		t.Errorf("sent %d times, want 2", sender.calls)
+	}
+}
+
+func TestSendWithRetryStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	failure := &bot.TooManyRequestsError{Message: "Too Many Requests", RetryAfter: 60}
+	sender := &stubSender{errs: []error{failure}}
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if err := SendWithRetry(ctx, 3, sender.send); err != failure {
+		t.Errorf("SendWithRetry = %v, want %v", err, failure)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("returned after %v, want as soon as the context is done", waited)
+	}
+	if sender.calls != 1 {
+		t.Errorf("sent %d times, want once", sender.calls)
+	}
+}
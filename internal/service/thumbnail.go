@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Photo variant names accepted by the /photo/ handler's ?size= parameter
+const (
+	ThumbnailSizeThumb  = "thumb"
+	ThumbnailSizeMedium = "medium"
+)
+
+// thumbnailWidths is the target width in pixels of each variant
+var thumbnailWidths = map[string]int{
+	ThumbnailSizeThumb:  200,
+	ThumbnailSizeMedium: 600,
+}
+
+// IsThumbnailSize reports whether size names a known photo variant
+func IsThumbnailSize(size string) bool {
+	_, ok := thumbnailWidths[size]
+	return ok
+}
+
+// ThumbnailFilename returns the file name of a photo's resized variant,
+// e.g. "abc.png" -> "abc_thumb.jpg"
+func ThumbnailFilename(filename, size string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + "_" + size + ".jpg"
+}
+
+// GenerateThumbnails writes the JPEG variants of dir/filename next to the original
+// and returns their file names by size. Images narrower than a variant are not
+// upscaled, only re-encoded.
+func GenerateThumbnails(dir, filename string) (map[string]string, error) {
+	file, err := os.Open(filepath.Join(dir, filename))
+	if err != nil {
+		return nil, fmt.Errorf("error opening photo: %w", err)
+	}
+	defer file.Close()
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding photo: %w", err)
+	}
+
+	// Flatten onto white once so transparent PNGs don't turn black in JPEG
+	bounds := src.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+
+	variants := make(map[string]string, len(thumbnailWidths))
+	for size, width := range thumbnailWidths {
+		variant := ThumbnailFilename(filename, size)
+		if err := writeJPEG(filepath.Join(dir, variant), resizeToWidth(flat, width)); err != nil {
+			return nil, err
+		}
+		variants[size] = variant
+	}
+
+	return variants, nil
+}
+
+// resizeToWidth scales src down to width keeping the aspect ratio
+func resizeToWidth(src *image.RGBA, width int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if width >= sw || sw == 0 || sh == 0 {
+		return src
+	}
+
+	height := max(sh*width/sw, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+func writeJPEG(path string, img image.Image) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating thumbnail: %w", err)
+	}
+
+	if err := jpeg.Encode(out, img, &jpeg.Options{Quality: 82}); err != nil {
+		out.Close()
+		os.Remove(path)
+		return fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+	return out.Close()
+}
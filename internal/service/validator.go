@@ -7,6 +7,7 @@ import (
 	"parfum/config"
 	"parfum/internal/domain"
 	"regexp"
+	"slices"
 	"strconv"
 )
 
@@ -26,13 +27,25 @@ func ParsePrice(raw string) (int, error) {
 	return strconv.Atoi(digits)
 }
 
+// expectedPrice is the sum the receipt must show: the quoted one if there is one, so a
+// price change between the quote and the payment doesn't fail it
+func expectedPrice(cfg *config.Config, pdfData domain.PdfResult) (int, error) {
+	if pdfData.Expected > 0 {
+		return pdfData.Expected, nil
+	}
+	return OrderPrice(cfg.Prices, pdfData.Lines)
+}
+
 func Validator(cfg *config.Config, pdfData domain.PdfResult) error {
-	mustPrice := pdfData.Total * cfg.Cost
+	mustPrice, err := expectedPrice(cfg, pdfData)
+	if err != nil {
+		return err
+	}
 	if pdfData.ActualPrice != mustPrice {
 		return ErrWrongPrice
 	}
 
-	if pdfData.Bin != cfg.Bin {
+	if !slices.Contains(cfg.Bins(), pdfData.Bin) {
 		return ErrWrongBin
 	}
 
@@ -42,6 +55,7 @@ func Validator(cfg *config.Config, pdfData domain.PdfResult) error {
 // Alternative approach with detailed error infodf -h
 type ValidationError struct {
 	Type    string
+	Field   string // the PdfResult field that failed: lines, amount or bin
 	Message string
 	Details map[string]interface{}
 }
@@ -50,26 +64,53 @@ func (e ValidationError) Error() string {
 	return e.Message
 }
 
+// Unwrap lets errors.Is match a ValidationError with ErrWrongPrice or ErrWrongBin
+func (e ValidationError) Unwrap() error {
+	switch e.Type {
+	case "wrong_price":
+		return ErrWrongPrice
+	case "wrong_bin":
+		return ErrWrongBin
+	}
+	return nil
+}
+
+// ValidatorWithDetails checks the same as Validator, and says which value was read and
+// what was expected instead
 func ValidatorWithDetails(cfg *config.Config, pdfData domain.PdfResult) error {
-	mustPrice := pdfData.Total * cfg.Cost
+	mustPrice, err := expectedPrice(cfg, pdfData)
+	if err != nil {
+		return ValidationError{
+			Type:    "unknown_volume",
+			Field:   "lines",
+			Message: err.Error(),
+			Details: map[string]interface{}{
+				"lines": pdfData.Lines,
+			},
+		}
+	}
 	if pdfData.ActualPrice != mustPrice {
 		return ValidationError{
 			Type:    "wrong_price",
+			Field:   "amount",
 			Message: "price is not correct",
 			Details: map[string]interface{}{
 				"expected": mustPrice,
 				"actual":   pdfData.ActualPrice,
+				"bin":      pdfData.Bin,
 			},
 		}
 	}
 
-	if pdfData.Bin != cfg.Bin {
+	if bins := cfg.Bins(); !slices.Contains(bins, pdfData.Bin) {
 		return ValidationError{
 			Type:    "wrong_bin",
+			Field:   "bin",
 			Message: "wrong bin number",
 			Details: map[string]interface{}{
-				"expected": cfg.Bin,
+				"expected": bins,
 				"actual":   pdfData.Bin,
+				"amount":   pdfData.ActualPrice,
 			},
 		}
 	}
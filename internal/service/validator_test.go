@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"parfum/config"
+	"parfum/internal/domain"
+)
+
+func TestValidatorWithDetails(t *testing.T) {
+	cfg := &config.Config{Bin: 111111111111, Bin3: 333333333333, Prices: map[string]int{"30ml": 2499}}
+	lines := []domain.PriceLine{{Volume: "30ml", Quantity: 2}}
+
+	tests := []struct {
+		name    string
+		pdf     domain.PdfResult
+		wantErr error // what errors.Is matches, nil for none
+		want    *ValidationError
+	}{
+		{
+			name: "valid with the first BIN",
+			pdf:  domain.PdfResult{Lines: lines, ActualPrice: 4998, Bin: 111111111111},
+		},
+		{
+			name: "valid with another BIN",
+			pdf:  domain.PdfResult{Lines: lines, ActualPrice: 4998, Bin: 333333333333},
+		},
+		{
+			name: "quoted sum wins over the price list",
+			pdf:  domain.PdfResult{Lines: lines, ActualPrice: 4500, Expected: 4500, Bin: 111111111111},
+		},
+		{
+			name:    "wrong price",
+			pdf:     domain.PdfResult{Lines: lines, ActualPrice: 2499, Bin: 222222222222},
+			wantErr: ErrWrongPrice,
+			want: &ValidationError{Type: "wrong_price", Field: "amount", Details: map[string]interface{}{
+				"expected": 4998, "actual": 2499, "bin": 222222222222,
+			}},
+		},
+		{
+			name:    "wrong BIN",
+			pdf:     domain.PdfResult{Lines: lines, ActualPrice: 4998, Bin: 222222222222},
+			wantErr: ErrWrongBin,
+			want: &ValidationError{Type: "wrong_bin", Field: "bin", Details: map[string]interface{}{
+				"expected": []int{111111111111, 333333333333}, "actual": 222222222222, "amount": 4998,
+			}},
+		},
+		{
+			name:    "unknown volume",
+			pdf:     domain.PdfResult{Lines: []domain.PriceLine{{Volume: "50ml", Quantity: 1}}, ActualPrice: 4998, Bin: 111111111111},
+			wantErr: ErrUnknownVolume,
+			want: &ValidationError{Type: "unknown_volume", Field: "lines", Details: map[string]interface{}{
+				"lines": []domain.PriceLine{{Volume: "50ml", Quantity: 1}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatorWithDetails(cfg, tt.pdf)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidatorWithDetails = %v, want nil", err)
+				}
+				return
+			}
+
+			var got ValidationError
+			if !errors.As(err, &got) {
+				t.Fatalf("ValidatorWithDetails = %v, want a ValidationError", err)
+			}
+			if got.Type != tt.want.Type || got.Field != tt.want.Field {
+				t.Errorf("type, field = %s, %s; want %s, %s", got.Type, got.Field, tt.want.Type, tt.want.Field)
+			}
+			if !reflect.DeepEqual(got.Details, tt.want.Details) {
+				t.Errorf("details = %v, want %v", got.Details, tt.want.Details)
+			}
+			if tt.wantErr != ErrUnknownVolume && !errors.Is(err, tt.wantErr) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.wantErr)
+			}
+			if err := Validator(cfg, tt.pdf); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validator = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimiter allows at most limit events per key in each fixed window.
+// It is safe for concurrent use; idle keys are evicted in the background
+// until the context passed to NewRateLimiter is cancelled.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+}
+
+// NewRateLimiter creates a limiter and starts its eviction loop
+func NewRateLimiter(ctx context.Context, limit int, window time.Duration) *RateLimiter {
+	l := &RateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+	go runEvery(ctx, window, l.evictIdle)
+	return l
+}
+
+// Allow records an event for key and reports whether it is within the limit
+func (l *RateLimiter) Allow(key string) bool {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		l.windows[key] = &rateWindow{start: now, count: 1}
+		return true
+	}
+
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// RetryAfter returns how long key has to wait before its window resets
+func (l *RateLimiter) RetryAfter(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[key]
+	if !ok {
+		return 0
+	}
+	return max(l.window-time.Since(w.start), 0)
+}
+
+func (l *RateLimiter) evictIdle(now time.Time) {
+	l.mu.Lock()
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, key)
+		}
+	}
+	l.mu.Unlock()
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterConcurrentLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewRateLimiter(ctx, 10, time.Minute)
+
+	allowed := make([]atomic.Int32, 4)
+	var wg sync.WaitGroup
+	for g := 0; g < 40; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				key := i % len(allowed)
+				if l.Allow(fmt.Sprintf("ip-%d", key)) {
+					allowed[key].Add(1)
+				}
+				l.RetryAfter(fmt.Sprintf("ip-%d", key))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for key := range allowed {
+		if n := allowed[key].Load(); n != 10 {
+			t.Errorf("ip-%d allowed %d times, want 10", key, n)
+		}
+	}
+}
+
+func TestRateLimiterWindowResets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := NewRateLimiter(ctx, 2, 30*time.Millisecond)
+
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatal("events within the limit refused")
+	}
+	if l.Allow("a") {
+		t.Error("third event in the window allowed")
+	}
+	if !l.Allow("b") {
+		t.Error("another key limited")
+	}
+	if wait := l.RetryAfter("a"); wait <= 0 || wait > 30*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want within the window", wait)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if !l.Allow("a") {
+		t.Error("event refused after the window")
+	}
+	if wait := l.RetryAfter("unknown"); wait != 0 {
+		t.Errorf("RetryAfter for an unknown key = %v, want 0", wait)
+	}
+}
+
+func TestRateLimiterEvictsIdleKeys(t *testing.T) {
+	l := NewRateLimiter(context.Background(), 1, time.Hour)
+	l.Allow("idle")
+
+	l.evictIdle(time.Now().Add(2 * time.Hour))
+
+	l.mu.Lock()
+	n := len(l.windows)
+	l.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d windows kept, want the idle key evicted", n)
+	}
+	if !l.Allow("idle") {
+		t.Error("evicted key still limited")
+	}
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTLCache is a mutex-guarded map whose entries expire after a per-entry TTL.
+// Expired entries are never returned and are swept by a background goroutine
+// that stops when the context passed to NewTTLCache is cancelled.
+type TTLCache[K comparable, V any] struct {
+	mu      sync.RWMutex
+	entries map[K]ttlEntry[V]
+
+	// loading serializes GetOrLoad per key so concurrent misses load once
+	loadMu  sync.Mutex
+	loading map[K]*sync.Mutex
+}
+
+// NewTTLCache creates a cache and starts its eviction loop
+func NewTTLCache[K comparable, V any](ctx context.Context, cleanupInterval time.Duration) *TTLCache[K, V] {
+	c := &TTLCache[K, V]{
+		entries: make(map[K]ttlEntry[V]),
+		loading: make(map[K]*sync.Mutex),
+	}
+	go runEvery(ctx, cleanupInterval, c.evictExpired)
+	return c
+}
+
+// Get returns the value stored under key if it hasn't expired
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// Set stores value under key for ttl
+func (c *TTLCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	c.entries[key] = ttlEntry[V]{value: value, expiresAt: time.Now().Add(ttl)}
+	c.mu.Unlock()
+}
+
+// SetIfAbsent stores value under key for ttl unless an unexpired entry is already there,
+// and reports whether it did
+func (c *TTLCache[K, V]) SetIfAbsent(key K, value V, ttl time.Duration) bool {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[key]; ok && !now.After(entry.expiresAt) {
+		return false
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expiresAt: now.Add(ttl)}
+	return true
+}
+
+// Delete removes key from the cache
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// Clear drops every entry, e.g. after the underlying data changed
+func (c *TTLCache[K, V]) Clear() {
+	c.mu.Lock()
+	c.entries = make(map[K]ttlEntry[V])
+	c.mu.Unlock()
+}
+
+// Len returns the number of stored entries, including expired ones not yet swept
+func (c *TTLCache[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// GetOrLoad returns the cached value for key or calls load once to fill it.
+// Concurrent callers missing the same key wait for that single load; a load
+// error is returned to the caller and nothing is cached.
+func (c *TTLCache[K, V]) GetOrLoad(key K, ttl time.Duration, load func() (V, error)) (V, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	c.loadMu.Lock()
+	keyMu, ok := c.loading[key]
+	if !ok {
+		keyMu = &sync.Mutex{}
+		c.loading[key] = keyMu
+	}
+	c.loadMu.Unlock()
+
+	keyMu.Lock()
+	defer keyMu.Unlock()
+
+	// Another caller may have loaded it while we waited
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	c.Set(key, value, ttl)
+	return value, nil
+}
+
+func (c *TTLCache[K, V]) evictExpired(now time.Time) {
+	c.mu.Lock()
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+
+	// Drop per-key load locks nobody holds any more; a caller racing with this
+	// sweep can at worst trigger one extra load, never a data race
+	c.loadMu.Lock()
+	for key, keyMu := range c.loading {
+		if keyMu.TryLock() {
+			delete(c.loading, key)
+			keyMu.Unlock()
+		}
+	}
+	c.loadMu.Unlock()
+}
+
+// runEvery calls fn on every tick until ctx is done
+func runEvery(ctx context.Context, interval time.Duration, fn func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			fn(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTTLCacheConcurrentAccess(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewTTLCache[string, int](ctx, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("key-%d", i%20)
+				switch i % 5 {
+				case 0:
+					c.Set(key, g, time.Duration(i%3)*time.Millisecond)
+				case 1:
+					c.Get(key)
+				case 2:
+					c.SetIfAbsent(key, g, time.Millisecond)
+				case 3:
+					c.Delete(key)
+				default:
+					c.Len()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	c.Clear()
+
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len after Clear = %d, want 0", n)
+	}
+}
+
+func TestTTLCacheGetOrLoadLoadsOnce(t *testing.T) {
+	c := NewTTLCache[string, string](context.Background(), time.Minute)
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.GetOrLoad("catalog", time.Minute, func() (string, error) {
+				loads.Add(1)
+				<-release
+				return "loaded", nil
+			})
+			if err != nil || value != "loaded" {
+				t.Errorf("GetOrLoad = %q, %v", value, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want once", n)
+	}
+}
+
+func TestTTLCacheGetOrLoadDoesNotCacheErrors(t *testing.T) {
+	c := NewTTLCache[string, int](context.Background(), time.Minute)
+	failure := errors.New("database is down")
+
+	if _, err := c.GetOrLoad("k", time.Minute, func() (int, error) { return 0, failure }); !errors.Is(err, failure) {
+		t.Fatalf("GetOrLoad error = %v, want %v", err, failure)
+	}
+	value, err := c.GetOrLoad("k", time.Minute, func() (int, error) { return 7, nil })
+	if err != nil || value != 7 {
+		t.Errorf("GetOrLoad after a failure = %d, %v; want 7", value, err)
+	}
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewTTLCache[string, int](ctx, 5*time.Millisecond)
+
+	c.Set("short", 1, 10*time.Millisecond)
+	c.Set("long", 2, time.Minute)
+	if c.SetIfAbsent("long", 3, time.Minute) {
+		t.Error("SetIfAbsent replaced a live entry")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Error("expired entry returned")
+	}
+	if value, ok := c.Get("long"); !ok || value != 2 {
+		t.Errorf("Get(long) = %d, %v; want 2", value, ok)
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len = %d, want the expired entry swept", n)
+	}
+	if !c.SetIfAbsent("short", 4, time.Minute) {
+		t.Error("SetIfAbsent refused an expired key")
+	}
+}
+
+func TestTTLCacheStopsSweepingOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewTTLCache[string, int](ctx, 5*time.Millisecond)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+
+	c.Set("k", 1, time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len = %d, want the expired entry left after cancel", n)
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Error("expired entry returned")
+	}
+}
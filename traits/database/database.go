@@ -4,6 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // CreateTables creates all required tables for the Lumen application
@@ -13,10 +16,25 @@ func CreateTables(db *sql.DB) error {
 		fn   func(*sql.DB) error
 	}{
 		{"just", createJustTable},
-		{"parfumes", createParfumesTable},
+		{"parfume", createParfumeTable},
+		{"parfume_photos", createParfumePhotosTable},
 		{"client", createClientTable},
+		{"clients", createClientsTable},
 		{"loto", createLotoTable},
+		{"receipts", createReceiptsTable},
+		{"money", createMoneyTable},
 		{"orders", CreateOrderTable}, // Updated to use new schema
+		{"order_items", createOrderItemsTable},
+		{"order_events", createOrderEventsTable},
+		{"idempotency_keys", createIdempotencyKeysTable},
+		{"failed_notifications", createFailedNotificationsTable},
+		{"manual_payments", createManualPaymentsTable},
+		{"pending_payments", createPendingPaymentsTable},
+		{"broadcast_blocked", createBroadcastBlockedTable},
+		{"settings", createSettingsTable},
+		{"prize_inventory", createPrizeInventoryTable},
+		{"spins", createSpinsTable},
+		{"prize_rules", createPrizeRulesTable},
 	}
 
 	for _, table := range tables {
@@ -38,6 +56,7 @@ func createJustTable(db *sql.DB) error {
 		id_user BIGINT NOT NULL UNIQUE,
 		userName VARCHAR(255) NOT NULL,
 		dataRegistred VARCHAR(50) NOT NULL,
+		language VARCHAR(5) NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -46,23 +65,46 @@ func createJustTable(db *sql.DB) error {
 	return err
 }
 
-// createParfumesTable creates the parfumes table
-func createParfumesTable(db *sql.DB) error {
+// createParfumeTable creates the parfume table (the one ParfumeRepository reads)
+func createParfumeTable(db *sql.DB) error {
 	const stmt = `
-	CREATE TABLE IF NOT EXISTS parfumes (
+	CREATE TABLE IF NOT EXISTS parfume (
 		id TEXT PRIMARY KEY,
 		name_parfume VARCHAR(255) NOT NULL,
-		sex VARCHAR(10) NOT NULL CHECK(sex IN ('Male', 'Female', 'Unisex')),
+		sex VARCHAR(20) NOT NULL CHECK(sex IN ('Male', 'Female', 'Unisex')),
 		description TEXT NOT NULL,
-		price INTEGER NOT NULL,
+		price INTEGER NOT NULL CHECK(price >= 0),
 		photo_path VARCHAR(500),
+		stock INTEGER NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deleted_at DATETIME NULL
 	);
 	
-	CREATE INDEX IF NOT EXISTS idx_parfumes_sex ON parfumes(sex);
-	CREATE INDEX IF NOT EXISTS idx_parfumes_price ON parfumes(price);
-	CREATE INDEX IF NOT EXISTS idx_parfumes_name ON parfumes(name_parfume);
+	CREATE INDEX IF NOT EXISTS idx_parfume_sex ON parfume(sex);
+	CREATE INDEX IF NOT EXISTS idx_parfume_price ON parfume(price);
+	CREATE INDEX IF NOT EXISTS idx_parfume_name ON parfume(name_parfume);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createParfumePhotosTable creates the parfume_photos table (gallery images per perfume)
+func createParfumePhotosTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS parfume_photos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		parfume_id TEXT NOT NULL,
+		filename VARCHAR(500) NOT NULL,
+		sort_order INT NOT NULL DEFAULT 0,
+		is_primary BOOLEAN NOT NULL DEFAULT 0,
+		thumb_filename VARCHAR(500),
+		medium_filename VARCHAR(500),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (parfume_id) REFERENCES parfume(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_parfume_photos_parfume_id ON parfume_photos(parfume_id);
 	`
 	_, err := db.Exec(stmt)
 	return err
@@ -88,6 +130,208 @@ func createClientTable(db *sql.DB) error {
 	return err
 }
 
+// createClientsTable creates the clients table: the delivery details a user last entered in the mini app
+func createClientsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS clients (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		telegram_id BIGINT NOT NULL UNIQUE,
+		fio TEXT NOT NULL,
+		contact VARCHAR(50) NOT NULL,
+		address TEXT NOT NULL,
+		latitude VARCHAR(50) NULL,
+		longitude VARCHAR(50) NULL,
+		preferred_language VARCHAR(5) DEFAULT 'kz',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createReceiptsTable creates the receipts table: one row per credited receipt, so a
+// receipt QR can only ever be paid out once
+func createReceiptsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS receipts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		qr TEXT NOT NULL UNIQUE,
+		id_user BIGINT NOT NULL,
+		amount INTEGER NOT NULL DEFAULT 0,
+		file_path TEXT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createFailedNotificationsTable creates the failed_notifications table: messages to
+// users that Telegram didn't deliver, kept so admins can review and resend them
+func createFailedNotificationsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS failed_notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		telegram_id BIGINT NOT NULL,
+		order_id INTEGER NULL,
+		message TEXT NOT NULL,
+		parse_mode TEXT NOT NULL DEFAULT '',
+		reply_markup TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		attempts INT NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_attempt_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delivered_at DATETIME NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_failed_notifications_delivered_at ON failed_notifications(delivered_at);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createManualPaymentsTable creates the manual_payments table: receipts the bot couldn't
+// read, waiting for an admin to approve or reject them
+func createManualPaymentsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS manual_payments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		id_user BIGINT NOT NULL,
+		quantity INT NOT NULL,
+		amount INTEGER NOT NULL,
+		file_path TEXT NOT NULL,
+		file_name TEXT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+		decided_by BIGINT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		decided_at DATETIME NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_manual_payments_status ON manual_payments(status);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createPendingPaymentsTable creates the pending_payments table: the count and sum the bot
+// quoted each user who hasn't paid yet, one row per user
+func createPendingPaymentsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS pending_payments (
+		id_user BIGINT PRIMARY KEY,
+		quantity INT NOT NULL,
+		amount INTEGER NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createBroadcastBlockedTable creates the broadcast_blocked table: users a broadcast couldn't
+// reach because they blocked the bot, left out of later broadcasts
+func createBroadcastBlockedTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS broadcast_blocked (
+		id_user BIGINT PRIMARY KEY,
+		reason TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createSettingsTable creates the settings table: values admins change at runtime, such
+// as the start promo, by key
+func createSettingsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createPrizeInventoryTable creates the prize_inventory table: how many of each limited
+// prize the wheel may award, and how many it has
+func createPrizeInventoryTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS prize_inventory (
+		prize TEXT PRIMARY KEY,
+		total INTEGER NOT NULL,
+		awarded INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createSpinsTable creates the spins table: every prize wheel spin that awarded a prize,
+// with the request it came from
+func createSpinsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS spins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id INTEGER NOT NULL UNIQUE,
+		telegram_id BIGINT NOT NULL,
+		order_sequence INTEGER NOT NULL,
+		prize TEXT NOT NULL,
+		drawn_prize TEXT NOT NULL,
+		sector INTEGER NOT NULL,
+		rules_version INTEGER NOT NULL DEFAULT 0,
+		source TEXT NOT NULL DEFAULT 'wheel',
+		remote_addr TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_spins_telegram_id ON spins(telegram_id);
+	CREATE INDEX IF NOT EXISTS idx_spins_created_at ON spins(created_at);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createPrizeRulesTable creates the prize_rules table: every version of the rules the
+// wheel draws prizes by, the newest being the active one
+func createPrizeRulesTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS prize_rules (
+		version INTEGER PRIMARY KEY AUTOINCREMENT,
+		money_interval INTEGER NOT NULL,
+		diamonds_per_1000 INTEGER NOT NULL,
+		ml30_interval INTEGER NOT NULL,
+		diamond_positions TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createMoneyTable creates the money table: one row (id = 1) holding the total paid sum
+func createMoneyTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS money (
+		id INTEGER PRIMARY KEY,
+		sum INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	INSERT OR IGNORE INTO money (id, sum) VALUES (1, 0);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
 // CreateOrderTable creates the orders table with the new schema
 func CreateOrderTable(db *sql.DB) error {
 	const stmt = `
@@ -100,10 +344,19 @@ func CreateOrderTable(db *sql.DB) error {
 		fio TEXT NULL,
 		contact VARCHAR(50) NOT NULL,
 		address TEXT NULL,
+		latitude REAL NULL,
+		longitude REAL NULL,
 		gift TEXT NULL,
 		dateRegister VARCHAR(50) NULL,
 		dataPay VARCHAR(50) NOT NULL,
 		checks BOOLEAN DEFAULT FALSE,
+		status TEXT NOT NULL DEFAULT 'paid',
+		payment_link TEXT NOT NULL DEFAULT '',
+		total_amount INTEGER NOT NULL DEFAULT 0,
+		prize_awarded_at DATETIME NULL,
+		prize_reminded_at DATETIME NULL,
+		prize_expired_at DATETIME NULL,
+		prize_claimed_at DATETIME NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -116,6 +369,70 @@ func CreateOrderTable(db *sql.DB) error {
 	return err
 }
 
+// createOrderEventsTable creates the order_events table (timeline of an order)
+func createOrderEventsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS order_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id INTEGER NOT NULL,
+		event_type TEXT NOT NULL DEFAULT 'status_changed',
+		from_status TEXT NOT NULL DEFAULT '',
+		to_status TEXT NOT NULL DEFAULT '',
+		payload TEXT NOT NULL DEFAULT '{}',
+		actor TEXT NOT NULL DEFAULT 'system',
+		note TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_order_events_order_id ON order_events(order_id);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createIdempotencyKeysTable creates the idempotency_keys table: the stored response of
+// every POST sent with an Idempotency-Key, replayed to retries of the same request
+func createIdempotencyKeysTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		idempotency_key TEXT NOT NULL,
+		endpoint TEXT NOT NULL,
+		telegram_id BIGINT NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		response TEXT NOT NULL DEFAULT '',
+		resource_id INTEGER NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (idempotency_key, endpoint, telegram_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
+// createOrderItemsTable creates the order_items table (one row per perfume in an order)
+func createOrderItemsTable(db *sql.DB) error {
+	const stmt = `
+	CREATE TABLE IF NOT EXISTS order_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id INTEGER NOT NULL,
+		parfume_id TEXT NULL,
+		name VARCHAR(255) NOT NULL,
+		quantity INT NOT NULL CHECK(quantity > 0),
+		price INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
+	`
+	_, err := db.Exec(stmt)
+	return err
+}
+
 // CreateViews creates useful views for reporting
 func CreateViews(db *sql.DB) error {
 	views := []struct {
@@ -171,7 +488,7 @@ func CreateViews(db *sql.DB) error {
 func SeedData(db *sql.DB) error {
 	// Check if data already exists
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM parfumes").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM parfume").Scan(&count)
 	if err != nil {
 		return err
 	}
@@ -229,7 +546,7 @@ func SeedData(db *sql.DB) error {
 
 	for _, perfume := range samplePerfumes {
 		_, err := db.Exec(`
-			INSERT INTO parfumes (id, name_parfume, sex, description, price)
+			INSERT INTO parfume (id, name_parfume, sex, description, price)
 			VALUES (?, ?, ?, ?, ?)
 		`, perfume.id, perfume.name, perfume.sex, perfume.description, perfume.price)
 
@@ -266,60 +583,510 @@ func createLotoTable(db *sql.DB) error {
 	return err
 }
 
-// MigrateDatabase performs any necessary migrations
+// migration is one versioned schema change, applied once and recorded in schema_migrations.
+// Databases from before schema_migrations may already have it: CreateTables builds the
+// current schema and older versions ran every migration on each start. appliedIf is a
+// query returning non-zero in that case, and the migration is then recorded without running.
+type migration struct {
+	version   string
+	sql       string
+	run       func(tx *sql.Tx) error // used instead of sql when the migration needs Go code
+	appliedIf string
+}
+
+// migrations are applied in this order; add new ones at the end
+var migrations = []migration{
+	{
+		version:   "v1.1.0",
+		sql:       "ALTER TABLE orders ADD COLUMN delivery_notes TEXT DEFAULT '';",
+		appliedIf: columnsExist("orders", "delivery_notes"),
+	},
+	{
+		version:   "v1.2.0",
+		sql:       "ALTER TABLE clients ADD COLUMN preferred_language VARCHAR(5) DEFAULT 'kz';",
+		appliedIf: columnsExist("clients", "preferred_language"),
+	},
+	{
+		version:   "v1.3.0",
+		sql:       "ALTER TABLE parfume ADD COLUMN deleted_at DATETIME NULL;",
+		appliedIf: columnsExist("parfume", "deleted_at"),
+	},
+	{
+		// NULL stock means the perfume isn't stock-tracked
+		version:   "v1.4.0",
+		sql:       "ALTER TABLE parfume ADD COLUMN stock INTEGER NULL;",
+		appliedIf: columnsExist("parfume", "stock"),
+	},
+	{
+		// Older installs created the catalog as "parfumes"; move its rows into
+		// "parfume" and keep a copy of the old table. RENAME isn't used because
+		// SQLite re-validates every view on rename.
+		version: "v1.5.0",
+		sql: `INSERT OR IGNORE INTO parfume (id, name_parfume, sex, description, price, photo_path, created_at, updated_at)
+		SELECT CAST(id AS TEXT), name_parfume, sex, description, CAST(price AS INTEGER), photo_path, created_at, updated_at
+		FROM parfumes;
+		CREATE TABLE parfumes_legacy AS SELECT * FROM parfumes;
+		DROP TABLE parfumes;`,
+		appliedIf: tableMissing("parfumes"),
+	},
+	{
+		// deleted_at now only records when a perfume was archived
+		version: "v1.6.0",
+		sql: `ALTER TABLE parfume ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT 1;
+		UPDATE parfume SET is_active = 0 WHERE deleted_at IS NOT NULL;`,
+		appliedIf: columnsExist("parfume", "is_active"),
+	},
+	{
+		// Existing single photos become the primary gallery image
+		version: "v1.7.0",
+		sql: `INSERT INTO parfume_photos (parfume_id, filename, sort_order, is_primary)
+		SELECT id, photo_path, 0, 1 FROM parfume
+		WHERE photo_path IS NOT NULL AND photo_path != ''
+		AND id NOT IN (SELECT parfume_id FROM parfume_photos);`,
+	},
+	{
+		// Resized variants generated on upload
+		version: "v1.8.0",
+		sql: `ALTER TABLE parfume_photos ADD COLUMN thumb_filename VARCHAR(500);
+		ALTER TABLE parfume_photos ADD COLUMN medium_filename VARCHAR(500);`,
+		appliedIf: columnsExist("parfume_photos", "thumb_filename", "medium_filename"),
+	},
+	{
+		// Delivery coordinates picked on the address form
+		version: "v1.9.0",
+		sql: `ALTER TABLE orders ADD COLUMN latitude REAL;
+		ALTER TABLE orders ADD COLUMN longitude REAL;`,
+		appliedIf: columnsExist("orders", "latitude", "longitude"),
+	},
+	{
+		// Order lifecycle status, derived from what the order already has
+		version: "v1.10.0",
+		sql: `ALTER TABLE orders ADD COLUMN status TEXT NOT NULL DEFAULT 'paid';
+		UPDATE orders SET status = CASE
+			WHEN checks = 1 THEN 'address_provided'
+			WHEN parfumes IS NOT NULL AND parfumes != '' THEN 'perfume_selected'
+			ELSE 'paid'
+		END;
+		CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);`,
+		appliedIf: columnsExist("orders", "status"),
+	},
+	{
+		// order_events holds every kind of order event, not only status changes
+		version: "v1.11.0",
+		sql: `ALTER TABLE order_events ADD COLUMN event_type TEXT NOT NULL DEFAULT 'status_changed';
+		ALTER TABLE order_events ADD COLUMN payload TEXT NOT NULL DEFAULT '{}';
+		ALTER TABLE order_events ADD COLUMN actor TEXT NOT NULL DEFAULT 'system';`,
+		appliedIf: columnsExist("order_events", "event_type", "payload", "actor"),
+	},
+	{
+		// Orders placed from the mini app keep their payment link and amount
+		version: "v1.12.0",
+		sql: `ALTER TABLE orders ADD COLUMN payment_link TEXT NOT NULL DEFAULT '';
+		ALTER TABLE orders ADD COLUMN total_amount INTEGER NOT NULL DEFAULT 0;`,
+		appliedIf: columnsExist("orders", "payment_link", "total_amount"),
+	},
+	{
+		// Receipts credited before the receipts table existed are only known by their loto tickets
+		version: "v1.13.0",
+		sql: `INSERT OR IGNORE INTO receipts (qr, id_user, amount, file_path, created_at)
+		SELECT qr, MIN(id_user), 0, MIN(receipt), MIN(created_at)
+		FROM loto
+		WHERE qr IS NOT NULL AND qr != ''
+		GROUP BY qr;`,
+	},
+	{
+		// Prize orders name the prize they were won for
+		version:   "v1.14.0",
+		sql:       "ALTER TABLE orders ADD COLUMN gift TEXT NULL;",
+		appliedIf: columnsExist("orders", "gift"),
+	},
+	{
+		// Finalized orders from before order_items get items parsed from orders.parfumes
+		version: "v1.15.0",
+		run:     migrateOrderItems,
+	},
+	{
+		// Created here rather than in CreateOrderTable: an orders table from before
+		// v1.10.0 has no status column until the migrations have run
+		version: "v1.16.0",
+		sql:     "CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);",
+	},
+	{
+		// Orders created after the bot lost the count stored 0; NULL now marks the
+		// quantity as unknown
+		version: "v1.17.0",
+		sql:     "UPDATE orders SET quantity = NULL WHERE quantity = 0;",
+	},
+	{
+		// The language bot users pick on /start; NULL until they do
+		version:   "v1.18.0",
+		sql:       "ALTER TABLE just ADD COLUMN language VARCHAR(5) NULL;",
+		appliedIf: columnsExist("just", "language"),
+	},
+	{
+		// The budgeted 10 rings and 5 cash prizes, less those already won
+		version: "v1.19.0",
+		sql: `INSERT OR IGNORE INTO prize_inventory (prize, total, awarded)
+		SELECT 'diamond_ring', 10, COUNT(*) FROM orders WHERE gift = 'diamond_ring';
+		INSERT OR IGNORE INTO prize_inventory (prize, total, awarded)
+		SELECT 'money', 5, COUNT(*) FROM orders WHERE gift = 'money';`,
+	},
+	{
+		// Spins name the prize rules they were drawn by; 0 is the built-in rules
+		version:   "v1.20.0",
+		sql:       "ALTER TABLE spins ADD COLUMN rules_version INTEGER NOT NULL DEFAULT 0;",
+		appliedIf: columnsExist("spins", "rules_version"),
+	},
+	{
+		// Unclaimed prizes get a reminder and then expire. Prizes from before this count
+		// from their spin, or from the order's last update when the spin wasn't recorded.
+		version: "v1.21.0",
+		sql: `ALTER TABLE orders ADD COLUMN prize_awarded_at DATETIME NULL;
+		ALTER TABLE orders ADD COLUMN prize_reminded_at DATETIME NULL;
+		ALTER TABLE orders ADD COLUMN prize_expired_at DATETIME NULL;
+		UPDATE orders
+		SET prize_awarded_at = COALESCE((SELECT created_at FROM spins WHERE spins.order_id = orders.id), updated_at)
+		WHERE gift IS NOT NULL AND gift != '' AND gift != 'null';`,
+		appliedIf: columnsExist("orders", "prize_awarded_at", "prize_reminded_at", "prize_expired_at"),
+	},
+	{
+		// is_active duplicated deleted_at: a perfume is archived while deleted_at is set
+		version: "v1.22.0",
+		sql: `UPDATE parfume SET deleted_at = COALESCE(deleted_at, updated_at, CURRENT_TIMESTAMP) WHERE is_active = 0;
+		UPDATE parfume SET deleted_at = NULL WHERE is_active = 1;
+		ALTER TABLE parfume DROP COLUMN is_active;`,
+		appliedIf: columnMissing("parfume", "is_active"),
+	},
+	{
+		// Prizes assigned by admins are recorded in spins too
+		version:   "v1.23.0",
+		sql:       "ALTER TABLE spins ADD COLUMN source TEXT NOT NULL DEFAULT 'wheel';",
+		appliedIf: columnsExist("spins", "source"),
+	},
+	{
+		// checks is set as soon as the purchase form is filled in, before the spin, so a
+		// prize is claimed only once its delivery address came in on /api/prize/complete.
+		// Orders from before the events timeline keep counting as claimed by checks.
+		version: "v1.24.0",
+		sql: `ALTER TABLE orders ADD COLUMN prize_claimed_at DATETIME NULL;
+		UPDATE orders
+		SET prize_claimed_at = (
+			SELECT MIN(created_at) FROM order_events
+			WHERE order_events.order_id = orders.id AND order_events.event_type = 'prize_completed'
+		)
+		WHERE gift IS NOT NULL AND gift != '' AND gift != 'null';
+		UPDATE orders
+		SET prize_claimed_at = updated_at
+		WHERE gift IS NOT NULL AND gift != '' AND gift != 'null' AND checks = 1 AND prize_expired_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM order_events WHERE order_events.order_id = orders.id);`,
+		appliedIf: columnsExist("orders", "prize_claimed_at"),
+	},
+}
+
+// MigrateDatabase applies every migration not yet recorded in schema_migrations, in order,
+// each in its own transaction. It stops at the first migration that fails.
 func MigrateDatabase(db *sql.DB) error {
 	log.Println("Running database migrations...")
 
-	// Add any future migrations here
-	migrations := []struct {
-		version string
-		sql     string
-	}{
-		{
-			"v1.1.0",
-			"ALTER TABLE orders ADD COLUMN delivery_notes TEXT DEFAULT '';",
-		},
-		{
-			"v1.2.0",
-			"ALTER TABLE clients ADD COLUMN preferred_language VARCHAR(5) DEFAULT 'kz';",
-		},
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+	`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
 	}
 
-	for _, migration := range migrations {
-		// Simple migration tracking - just try to run and ignore if column exists
-		_, err := db.Exec(migration.sql)
-		if err != nil {
-			// Log but don't fail - likely means migration already ran
-			log.Printf("Migration %s: %v (likely already applied)", migration.version, err)
-		} else {
-			log.Printf("Applied migration %s successfully", migration.version)
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %s: %w", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the latest migration recorded in schema_migrations,
+// or "" if none is
+func SchemaVersion(db *sql.DB) (string, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if applied[migrations[i].version] {
+			return migrations[i].version, nil
+		}
+	}
+	return "", nil
+}
+
+func appliedMigrations(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	alreadyApplied := false
+	if m.appliedIf != "" {
+		var count int
+		if err := tx.QueryRow(m.appliedIf).Scan(&count); err != nil {
+			return fmt.Errorf("check schema: %w", err)
+		}
+		alreadyApplied = count > 0
+	}
+
+	switch {
+	case alreadyApplied:
+	case m.run != nil:
+		err = m.run(tx)
+	default:
+		_, err = tx.Exec(m.sql)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, m.version); err != nil {
+		return fmt.Errorf("record: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	if alreadyApplied {
+		log.Printf("Migration %s was already in the schema, recorded it", m.version)
+	} else {
+		log.Printf("Applied migration %s successfully", m.version)
+	}
+	return nil
+}
+
+// columnsExist returns an appliedIf query for a migration that adds columns to table
+func columnsExist(table string, columns ...string) string {
+	return fmt.Sprintf(
+		"SELECT COUNT(*) = %d FROM pragma_table_info('%s') WHERE name IN ('%s')",
+		len(columns), table, strings.Join(columns, "', '"),
+	)
+}
+
+// columnMissing returns an appliedIf query for a migration that drops column
+func columnMissing(table, column string) string {
+	return fmt.Sprintf("SELECT COUNT(*) = 0 FROM pragma_table_info('%s') WHERE name = '%s'", table, column)
+}
+
+// tableMissing returns an appliedIf query for a migration that replaces table
+func tableMissing(table string) string {
+	return fmt.Sprintf("SELECT COUNT(*) = 0 FROM sqlite_master WHERE type = 'table' AND name = '%s'", table)
+}
+
+// migrateOrderItems parses legacy orders.parfumes strings ("name: qty, name: qty")
+// into order_items rows for finalized orders that don't have any items yet.
+// Unfinalized selections are left alone: they now live in the user's cart.
+func migrateOrderItems(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT o.id, o.parfumes
+		FROM orders o
+		WHERE o.parfumes IS NOT NULL AND o.parfumes != ''
+		AND o.address IS NOT NULL AND o.address != ''
+		AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = o.id)
+	`)
+	if err != nil {
+		return fmt.Errorf("query legacy selections: %w", err)
+	}
+
+	legacy := make(map[int64]string)
+	for rows.Next() {
+		var orderID int64
+		var parfumes string
+		if err := rows.Scan(&orderID, &parfumes); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan legacy selection: %w", err)
+		}
+		legacy[orderID] = parfumes
+	}
+	rows.Close()
+
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO order_items (order_id, parfume_id, name, quantity, price)
+		VALUES (
+			?,
+			(SELECT id FROM parfume WHERE TRIM(name_parfume) = ? LIMIT 1),
+			?,
+			?,
+			COALESCE((SELECT price FROM parfume WHERE TRIM(name_parfume) = ? LIMIT 1), 0)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare backfill insert: %w", err)
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for orderID, parfumes := range legacy {
+		for _, part := range strings.Split(parfumes, ",") {
+			part = strings.TrimSpace(part)
+			sep := strings.LastIndex(part, ":")
+			if sep <= 0 {
+				continue
+			}
+
+			name := strings.TrimSpace(part[:sep])
+			quantity, err := strconv.Atoi(strings.TrimSpace(part[sep+1:]))
+			if err != nil || quantity <= 0 {
+				log.Printf("Skipping unparsable selection %q in order %d", part, orderID)
+				continue
+			}
+
+			if _, err := stmt.Exec(orderID, name, name, quantity, name); err != nil {
+				return fmt.Errorf("insert item for order %d: %w", orderID, err)
+			}
+			inserted++
 		}
 	}
 
+	log.Printf("Backfilled %d order items from %d legacy selections", inserted, len(legacy))
 	return nil
 }
 
-// CleanupOldData removes old data (optional cleanup task)
-func CleanupOldData(db *sql.DB, daysOld int) error {
+// staleOrdersWhere matches unchecked orders created more than ? days ago that were never
+// paid: still awaiting payment, with no paid status in their history. Everything from
+// paid on is a sale and stays, and so does an order with a prize assigned: the prize
+// still has to be delivered.
+const staleOrdersWhere = `
+	WHERE checks = 0
+	AND status = 'awaiting_payment'
+	AND NOT EXISTS (SELECT 1 FROM order_events e WHERE e.order_id = orders.id AND e.to_status = 'paid')
+	AND (gift IS NULL OR gift = '')
+	AND created_at < datetime('now', '-' || ? || ' days')`
+
+// CleanupOldData deletes unpaid orders older than daysOld days and returns how many it
+// deleted. With dryRun it only counts and logs them, deleting nothing.
+func CleanupOldData(db *sql.DB, daysOld int, dryRun bool) (int64, error) {
 	if daysOld <= 0 {
-		return fmt.Errorf("daysOld must be positive")
+		return 0, fmt.Errorf("daysOld must be positive")
 	}
 
-	log.Printf("Cleaning up data older than %d days...", daysOld)
+	if dryRun {
+		var count int64
+		if err := db.QueryRow(`SELECT COUNT(*) FROM orders`+staleOrdersWhere, daysOld).Scan(&count); err != nil {
+			return 0, fmt.Errorf("count old orders: %w", err)
+		}
+		log.Printf("Dry run: would clean up %d unpaid orders older than %d days", count, daysOld)
+		return count, nil
+	}
 
-	// Clean up old unchecked orders (older than specified days)
-	result, err := db.Exec(`
-		DELETE FROM orders 
-		WHERE checks = 0 
-		AND created_at < datetime('now', '-' || ? || ' days')
-	`, daysOld)
+	log.Printf("Cleaning up data older than %d days...", daysOld)
 
+	result, err := db.Exec(`DELETE FROM orders`+staleOrdersWhere, daysOld)
 	if err != nil {
-		return fmt.Errorf("cleanup old orders: %w", err)
+		return 0, fmt.Errorf("cleanup old orders: %w", err)
 	}
 
 	affected, _ := result.RowsAffected()
-	log.Printf("Cleaned up %d old unchecked orders", affected)
+	log.Printf("Cleaned up %d old unpaid orders", affected)
 
-	return nil
+	return affected, nil
+}
+
+// ExpectedTables lists every table the repositories query, with the repository that needs it
+var ExpectedTables = map[string]string{
+	"just":                 "ClientRepository",
+	"client":               "ClientRepository",
+	"clients":              "ClientRepository",
+	"loto":                 "ClientRepository",
+	"receipts":             "ClientRepository",
+	"geo":                  "ClientRepository",
+	"money":                "ClientRepository",
+	"parfume":              "ParfumeRepository",
+	"parfume_photos":       "ParfumePhotoRepository",
+	"orders":               "OrderRepository",
+	"order_items":          "OrderItemRepository",
+	"order_events":         "OrderRepository",
+	"idempotency_keys":     "IdempotencyRepository",
+	"failed_notifications": "ClientRepository",
+	"manual_payments":      "ClientRepository",
+	"pending_payments":     "ClientRepository",
+	"broadcast_blocked":    "ClientRepository",
+	"settings":             "SettingsRepository",
+	"prize_inventory":      "OrderRepository",
+	"spins":                "OrderRepository",
+	"prize_rules":          "OrderRepository",
+}
+
+// MissingTablesError lists expected tables that don't exist in the database
+type MissingTablesError struct {
+	Tables []string
+}
+
+func (e *MissingTablesError) Error() string {
+	return fmt.Sprintf("missing tables: %s", strings.Join(e.Tables, ", "))
+}
+
+// VerifyTables checks that every table in ExpectedTables exists.
+// Missing tables are reported together as a *MissingTablesError.
+func VerifyTables(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return fmt.Errorf("list tables: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("scan table name: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("list tables: %w", err)
+	}
+
+	var missing []string
+	for table := range ExpectedTables {
+		if !existing[table] {
+			missing = append(missing, table)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return &MissingTablesError{Tables: missing}
 }
@@ -0,0 +1,373 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestDB opens an empty database file that lives until the test ends
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	settings := SQLiteSettings{JournalMode: "WAL", BusyTimeout: 5 * time.Second, MaxOpenConns: 1}
+	db, err := sql.Open("sqlite3", DSN(filepath.Join(t.TempDir(), "test.db"), settings))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := Configure(db, settings); err != nil {
+		t.Fatalf("configure database: %v", err)
+	}
+	return db
+}
+
+// newTestDB opens a database with the full schema and every migration applied
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := openTestDB(t)
+	if err := CreateTables(db); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	if err := MigrateDatabase(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// missingTables is what VerifyTables reports as missing, nil for nothing
+func missingTables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	err := VerifyTables(db)
+	if err == nil {
+		return nil
+	}
+	var missingErr *MissingTablesError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("VerifyTables = %v, want a *MissingTablesError", err)
+	}
+	return missingErr.Tables
+}
+
+func TestVerifyTablesReportsMissingTables(t *testing.T) {
+	db := newTestDB(t)
+	before := missingTables(t, db)
+
+	dropped := []string{"parfume", "money"}
+	for _, table := range dropped {
+		if slices.Contains(before, table) {
+			t.Fatalf("%s is missing from the full schema", table)
+		}
+		if _, err := db.Exec("DROP TABLE " + table); err != nil {
+			t.Fatalf("drop %s: %v", table, err)
+		}
+	}
+
+	want := append(slices.Clone(before), dropped...)
+	slices.Sort(want)
+	after := missingTables(t, db)
+	if !slices.Equal(after, want) {
+		t.Errorf("missing tables = %q, want %q", after, want)
+	}
+
+	err := VerifyTables(db)
+	if got, want := err.Error(), "missing tables: "+strings.Join(want, ", "); got != want {
+		t.Errorf("error = %q, want %q", got, want)
+	}
+}
+
+func TestVerifyTablesReportsEveryTableOfEmptyDatabase(t *testing.T) {
+	db := openTestDB(t)
+
+	if missing := missingTables(t, db); len(missing) != len(ExpectedTables) {
+		t.Errorf("missing %d tables, want all %d", len(missing), len(ExpectedTables))
+	}
+}
+
+func TestMigrateOrderItemsParsesLegacySelections(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := db.Exec(`INSERT INTO parfume (id, name_parfume, sex, description, price) VALUES ('p1', 'Baccarat Rouge', 'Unisex', 'Amber', 2499)`); err != nil {
+		t.Fatalf("insert perfume: %v", err)
+	}
+	for _, order := range []struct {
+		parfumes, address string
+	}{
+		{"Baccarat Rouge: 2, Lost Cherry: 1, broken, Oud Wood: x", "Алматы"},
+		{"Baccarat Rouge: 5", ""}, // not finalized: stays in the cart
+	} {
+		_, err := db.Exec(`INSERT INTO orders (id_user, userName, contact, dataPay, parfumes, address) VALUES (1, 'u', '+7', '', ?, ?)`,
+			order.parfumes, order.address)
+		if err != nil {
+			t.Fatalf("insert order: %v", err)
+		}
+	}
+
+	for run := 0; run < 2; run++ {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		if err := migrateOrderItems(tx); err != nil {
+			t.Fatalf("migrateOrderItems: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+	}
+
+	rows, err := db.Query(`SELECT order_id, COALESCE(parfume_id, ''), name, quantity, price FROM order_items ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query items: %v", err)
+	}
+	defer rows.Close()
+
+	type item struct {
+		orderID         int64
+		parfumeID, name string
+		quantity, price int
+	}
+	var got []item
+	for rows.Next() {
+		var i item
+		if err := rows.Scan(&i.orderID, &i.parfumeID, &i.name, &i.quantity, &i.price); err != nil {
+			t.Fatalf("scan item: %v", err)
+		}
+		got = append(got, i)
+	}
+
+	want := []item{
+		{1, "p1", "Baccarat Rouge", 2, 2499},
+		{1, "", "Lost Cherry", 1, 0},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("items = %+v, want %+v", got, want)
+	}
+}
+
+// schemaDump is every table and index definition of db
+func schemaDump(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT type || ' ' || name || ': ' || COALESCE(sql, '') FROM sqlite_master ORDER BY type, name`)
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	defer rows.Close()
+
+	var schema []string
+	for rows.Next() {
+		var entry string
+		if err := rows.Scan(&entry); err != nil {
+			t.Fatalf("scan schema: %v", err)
+		}
+		schema = append(schema, entry)
+	}
+	return schema
+}
+
+func TestMigrateDatabaseTwiceIsIdempotent(t *testing.T) {
+	db := newTestDB(t)
+
+	var recorded int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil {
+		t.Fatalf("count migrations: %v", err)
+	}
+	if recorded != len(migrations) {
+		t.Fatalf("recorded %d migrations, want all %d", recorded, len(migrations))
+	}
+	version, err := SchemaVersion(db)
+	if err != nil || version != migrations[len(migrations)-1].version {
+		t.Fatalf("SchemaVersion = %q, %v; want the latest", version, err)
+	}
+
+	before := schemaDump(t, db)
+	if err := MigrateDatabase(db); err != nil {
+		t.Fatalf("MigrateDatabase again: %v", err)
+	}
+	if after := schemaDump(t, db); !slices.Equal(before, after) {
+		t.Errorf("schema changed on the second run:\n%q\nwant\n%q", after, before)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil || recorded != len(migrations) {
+		t.Errorf("recorded %d migrations after the second run, %v; want %d", recorded, err, len(migrations))
+	}
+}
+
+func TestMigrateDatabaseRecordsSchemaOfOlderInstall(t *testing.T) {
+	// An install from before schema_migrations: the current tables, nothing recorded
+	db := openTestDB(t)
+	if err := CreateTables(db); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	if err := MigrateDatabase(db); err != nil {
+		t.Fatalf("MigrateDatabase: %v", err)
+	}
+	if version, err := SchemaVersion(db); err != nil || version != migrations[len(migrations)-1].version {
+		t.Errorf("SchemaVersion = %q, %v; want the latest", version, err)
+	}
+
+	// Columns CreateTables already has aren't added a second time
+	for _, entry := range schemaDump(t, db) {
+		if strings.HasPrefix(entry, "table orders:") && strings.Count(entry, "delivery_notes") != 1 {
+			t.Errorf("orders = %s, want delivery_notes once", entry)
+		}
+	}
+	var recorded int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil || recorded != len(migrations) {
+		t.Errorf("recorded %d migrations, %v; want all %d", recorded, err, len(migrations))
+	}
+}
+
+func TestMigrateDatabaseStopsAtBrokenMigration(t *testing.T) {
+	db := newTestDB(t)
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(slices.Clip(saved),
+		migration{version: "v9.0.0", sql: "CREATE TABLE good_before (id INTEGER);"},
+		migration{version: "v9.1.0", sql: "CREATE TABLE half_done (id INTEGER); ALTER TABLE no_such_table ADD COLUMN x TEXT;"},
+		migration{version: "v9.2.0", sql: "CREATE TABLE never_reached (id INTEGER);"},
+	)
+
+	err := MigrateDatabase(db)
+	if err == nil || !strings.Contains(err.Error(), "v9.1.0") {
+		t.Fatalf("MigrateDatabase = %v, want the broken migration's error", err)
+	}
+
+	if version, err := SchemaVersion(db); err != nil || version != "v9.0.0" {
+		t.Errorf("SchemaVersion = %q, %v; want the last one that worked", version, err)
+	}
+	for table, want := range map[string]bool{"good_before": true, "half_done": false, "never_reached": false} {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count); err != nil {
+			t.Fatalf("look up %s: %v", table, err)
+		}
+		if got := count == 1; got != want {
+			t.Errorf("table %s exists = %v, want %v", table, got, want)
+		}
+	}
+
+	// Still broken on the next start, not skipped as applied
+	if err := MigrateDatabase(db); err == nil {
+		t.Error("second MigrateDatabase succeeded past the broken migration")
+	}
+}
+
+func TestMigrateDatabaseClearsZeroQuantities(t *testing.T) {
+	db := newTestDB(t)
+
+	// Orders saved before v1.17.0 stored a lost count as 0
+	if _, err := db.Exec(`INSERT INTO orders (id_user, userName, quantity, contact, dataPay) VALUES (1, 'a', 0, '', ''), (2, 'b', 3, '', '')`); err != nil {
+		t.Fatalf("insert orders: %v", err)
+	}
+	if _, err := db.Exec(`DELETE FROM schema_migrations WHERE version = 'v1.17.0'`); err != nil {
+		t.Fatalf("forget migration: %v", err)
+	}
+	if err := MigrateDatabase(db); err != nil {
+		t.Fatalf("MigrateDatabase: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT quantity FROM orders ORDER BY id_user`)
+	if err != nil {
+		t.Fatalf("query orders: %v", err)
+	}
+	defer rows.Close()
+	var quantities []sql.NullInt64
+	for rows.Next() {
+		var quantity sql.NullInt64
+		if err := rows.Scan(&quantity); err != nil {
+			t.Fatalf("scan quantity: %v", err)
+		}
+		quantities = append(quantities, quantity)
+	}
+	want := []sql.NullInt64{{}, {Int64: 3, Valid: true}}
+	if !slices.Equal(quantities, want) {
+		t.Errorf("quantities = %v, want %v", quantities, want)
+	}
+}
+
+func TestCleanupOldData(t *testing.T) {
+	db := newTestDB(t)
+
+	// Only the two old orders that never got paid and have no prize are stale
+	for _, order := range []struct {
+		user    int
+		status  string
+		checks  bool
+		gift    interface{}
+		created string
+		wasPaid bool // a paid status change in the order's history
+	}{
+		{1, "awaiting_payment", false, nil, "-40 days", false},
+		{2, "awaiting_payment", false, "", "-40 days", false},
+		{3, "awaiting_payment", false, "diamond_ring", "-40 days", false},
+		{4, "awaiting_payment", true, nil, "-40 days", false},
+		{5, "awaiting_payment", false, nil, "-5 days", false},
+		{6, "paid", false, nil, "-40 days", false},
+		{7, "perfume_selected", false, nil, "-40 days", false},
+		{8, "cancelled", false, nil, "-40 days", true},
+		{9, "awaiting_payment", false, nil, "-40 days", true},
+	} {
+		result, err := db.Exec(`
+			INSERT INTO orders (id_user, userName, contact, dataPay, status, checks, gift, created_at)
+			VALUES (?, 'user', '', '', ?, ?, ?, datetime('now', ?))
+		`, order.user, order.status, order.checks, order.gift, order.created)
+		if err != nil {
+			t.Fatalf("insert order: %v", err)
+		}
+		if order.wasPaid {
+			id, _ := result.LastInsertId()
+			if _, err := db.Exec(`
+				INSERT INTO order_events (order_id, event_type, from_status, to_status, payload, actor)
+				VALUES (?, 'status_changed', 'awaiting_payment', 'paid', '{}', 'system')
+			`, id); err != nil {
+				t.Fatalf("insert event: %v", err)
+			}
+		}
+	}
+	remaining := func() []int {
+		t.Helper()
+		rows, err := db.Query(`SELECT id_user FROM orders ORDER BY id_user`)
+		if err != nil {
+			t.Fatalf("query orders: %v", err)
+		}
+		defer rows.Close()
+		var users []int
+		for rows.Next() {
+			var user int
+			if err := rows.Scan(&user); err != nil {
+				t.Fatalf("scan order: %v", err)
+			}
+			users = append(users, user)
+		}
+		return users
+	}
+
+	if n, err := CleanupOldData(db, 30, true); err != nil || n != 2 {
+		t.Errorf("dry run = %d, %v; want 2", n, err)
+	}
+	if users := remaining(); len(users) != 9 {
+		t.Errorf("orders after the dry run = %v, want all 9", users)
+	}
+
+	if n, err := CleanupOldData(db, 30, false); err != nil || n != 2 {
+		t.Errorf("cleanup = %d, %v; want 2", n, err)
+	}
+	if users := remaining(); !slices.Equal(users, []int{3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("orders after cleanup = %v, want the prize, checked, recent and paid ones", users)
+	}
+
+	if _, err := CleanupOldData(db, 0, true); err == nil {
+		t.Error("cleanup of 0 days succeeded")
+	}
+}
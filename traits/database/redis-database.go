@@ -11,33 +11,46 @@ import (
 
 // Existing CreateTables function remains the same...
 
+// RedisSettings locate the Redis server the application keeps its state in
+type RedisSettings struct {
+	Addr     string // host:port of the server
+	Password string // empty when the server has no AUTH
+	DB       int    // logical database number
+}
+
 // ConnectRedis creates a new Redis client connection
-func ConnectRedis(ctx context.Context, logger *zap.Logger) (*redis.Client, error) {
-	// Redis connection options matching your docker-compose
-	rdb := redis.NewClient(&redis.Options{
-		Addr:         "localhost:6379", // Redis server address
-		Password:     "",               // No password set
-		DB:           0,                // Use default DB
-		DialTimeout:  5 * time.Second,  // Connection timeout
-		ReadTimeout:  3 * time.Second,  // Read timeout
-		WriteTimeout: 3 * time.Second,  // Write timeout
-		PoolSize:     10,               // Connection pool size
-		MinIdleConns: 2,                // Minimum idle connections
-	})
+func ConnectRedis(ctx context.Context, settings RedisSettings, logger *zap.Logger) (*redis.Client, error) {
+	rdb := NewRedisClient(settings)
 
 	// Test the connection
 	_, err := rdb.Ping(ctx).Result()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		rdb.Close()
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", settings.Addr, err)
 	}
 
 	logger.Info("Successfully connected to Redis",
-		zap.String("addr", "localhost:6379"),
-		zap.Int("db", 0))
+		zap.String("addr", settings.Addr),
+		zap.Int("db", settings.DB))
 
 	return rdb, nil
 }
 
+// NewRedisClient creates a Redis client without checking the server is reachable;
+// it connects on first use
+func NewRedisClient(settings RedisSettings) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:         settings.Addr,
+		Password:     settings.Password,
+		DB:           settings.DB,
+		DialTimeout:  5 * time.Second, // Connection timeout
+		ReadTimeout:  3 * time.Second, // Read timeout
+		WriteTimeout: 3 * time.Second, // Write timeout
+		PoolSize:     10,              // Connection pool size
+		MinIdleConns: 2,               // Minimum idle connections
+	})
+}
+
 // CloseRedis gracefully closes Redis connection
 func CloseRedis(rdb *redis.Client, logger *zap.Logger) {
 	if err := rdb.Close(); err != nil {
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.uber.org/zap"
+)
+
+func TestConnectRedisUsesSettings(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("s3cret")
+
+	rdb, err := ConnectRedis(context.Background(), RedisSettings{Addr: server.Addr(), Password: "s3cret", DB: 2}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("ConnectRedis: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+
+	if err := rdb.Set(context.Background(), "greeting", "salem", 0).Err(); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	server.Select(2)
+	if got, err := server.Get("greeting"); err != nil || got != "salem" {
+		t.Errorf("database 2 greeting = %q, %v; want the key written there", got, err)
+	}
+}
+
+func TestConnectRedisFails(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("s3cret")
+
+	// A port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	for name, settings := range map[string]RedisSettings{
+		"wrong password":      {Addr: server.Addr(), Password: "guess"},
+		"unreachable address": {Addr: closedAddr},
+	} {
+		rdb, err := ConnectRedis(context.Background(), settings, zap.NewNop())
+		if err == nil {
+			rdb.Close()
+			t.Errorf("%s: ConnectRedis succeeded", name)
+			continue
+		}
+		if !strings.Contains(err.Error(), settings.Addr) {
+			t.Errorf("%s: err = %v, want the address in it", name, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SQLiteSettings are the connection settings the application runs SQLite with
+type SQLiteSettings struct {
+	JournalMode  string        // e.g. "WAL", so readers don't block the writer
+	BusyTimeout  time.Duration // how long a statement waits for a lock before "database is locked"
+	MaxOpenConns int           // size of the connection pool
+}
+
+// DSN returns the data source name for the database file at path with settings applied.
+// busy_timeout and foreign_keys are per-connection, so they have to be in the DSN to
+// reach every connection the pool opens, not only the one Configure runs on.
+// Transactions take the write lock when they begin: a read transaction that later
+// writes would otherwise fail at once instead of waiting for busy_timeout.
+func DSN(path string, settings SQLiteSettings) string {
+	params := url.Values{}
+	params.Set("_journal_mode", settings.JournalMode)
+	params.Set("_busy_timeout", fmt.Sprint(settings.BusyTimeout.Milliseconds()))
+	params.Set("_foreign_keys", "on")
+	params.Set("_txlock", "immediate")
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return "file:" + path + separator + params.Encode()
+}
+
+// Configure sizes the connection pool, applies settings to db and checks they took effect.
+// The journal mode is stored in the database file; an in-memory database stays in
+// "memory" mode, which is reported as an error.
+func Configure(db *sql.DB, settings SQLiteSettings) error {
+	db.SetMaxOpenConns(settings.MaxOpenConns)
+	db.SetMaxIdleConns(settings.MaxOpenConns)
+
+	var journalMode string
+	if err := db.QueryRow(fmt.Sprintf("PRAGMA journal_mode=%s", settings.JournalMode)).Scan(&journalMode); err != nil {
+		return fmt.Errorf("failed to set journal mode: %w", err)
+	}
+	if !strings.EqualFold(journalMode, settings.JournalMode) {
+		return fmt.Errorf("journal mode is %q, wanted %q", journalMode, settings.JournalMode)
+	}
+
+	pragmas := []string{
+		fmt.Sprintf("PRAGMA busy_timeout=%d", settings.BusyTimeout.Milliseconds()),
+		"PRAGMA foreign_keys=ON",
+	}
+	for _, pragma := range pragmas {
+		if _, err := db.Exec(pragma); err != nil {
+			return fmt.Errorf("failed to run %s: %w", pragma, err)
+		}
+	}
+
+	var foreignKeys int
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	if foreignKeys != 1 {
+		return fmt.Errorf("foreign keys are not enforced")
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// appSettings are the defaults the application runs with
+var appSettings = SQLiteSettings{JournalMode: "WAL", BusyTimeout: 5 * time.Second, MaxOpenConns: 4}
+
+func TestConfigureAppliesSettings(t *testing.T) {
+	db, err := sql.Open("sqlite3", DSN(filepath.Join(t.TempDir(), "test.db"), appSettings))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Configure(db, appSettings); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	if got := db.Stats().MaxOpenConnections; got != appSettings.MaxOpenConns {
+		t.Errorf("max open connections = %d, want %d", got, appSettings.MaxOpenConns)
+	}
+
+	// Hold every connection of the pool at once, so each one is checked
+	ctx := context.Background()
+	for i := 0; i < appSettings.MaxOpenConns; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+		defer conn.Close()
+
+		var journalMode string
+		var busyTimeout, foreignKeys int
+		if err := conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			t.Fatalf("journal_mode: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+			t.Fatalf("busy_timeout: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+			t.Fatalf("foreign_keys: %v", err)
+		}
+		if !strings.EqualFold(journalMode, "wal") || busyTimeout != 5000 || foreignKeys != 1 {
+			t.Errorf("connection %d: journal_mode = %s, busy_timeout = %d, foreign_keys = %d", i, journalMode, busyTimeout, foreignKeys)
+		}
+	}
+}
+
+func TestConfigureRejectsInMemoryWAL(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := Configure(db, SQLiteSettings{JournalMode: "WAL", BusyTimeout: time.Second, MaxOpenConns: 1}); err == nil {
+		t.Error("Configure of an in-memory database succeeded, want the journal mode reported")
+	}
+}
+
+func TestConcurrentOrderWritesAndReads(t *testing.T) {
+	db, err := sql.Open("sqlite3", DSN(filepath.Join(t.TempDir(), "test.db"), appSettings))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Configure(db, appSettings); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := CreateTables(db); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	if err := MigrateDatabase(db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	const writers, readers, ordersEach = 8, 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*ordersEach+readers*ordersEach)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(user int) {
+			defer wg.Done()
+			for i := 0; i < ordersEach; i++ {
+				// Each order is written with its item in one transaction
+				tx, err := db.Begin()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				result, err := tx.Exec(`INSERT INTO orders (id_user, userName, contact, dataPay) VALUES (?, 'u', '+7', '')`, user)
+				if err == nil {
+					id, _ := result.LastInsertId()
+					_, err = tx.Exec(`INSERT INTO order_items (order_id, name, quantity, price) VALUES (?, 'Baccarat Rouge', 1, 2499)`, id)
+				}
+				if err != nil {
+					tx.Rollback()
+					errs <- err
+					continue
+				}
+				if err := tx.Commit(); err != nil {
+					errs <- err
+				}
+			}
+		}(w + 1)
+	}
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < ordersEach; i++ {
+				var orders, items int
+				err := db.QueryRow(`
+					SELECT COUNT(DISTINCT o.id), COUNT(i.id)
+					FROM orders o LEFT JOIN order_items i ON i.order_id = o.id
+				`).Scan(&orders, &items)
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent access: %v", err)
+	}
+
+	var orders, items int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM orders), (SELECT COUNT(*) FROM order_items)`).Scan(&orders, &items); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if orders != writers*ordersEach || items != writers*ordersEach {
+		t.Errorf("orders = %d, items = %d; want %d of each", orders, items, writers*ordersEach)
+	}
+}
@@ -0,0 +1,56 @@
+package keylock
+
+import "sync"
+
+type lockEntry struct {
+	mu   sync.Mutex
+	refs int // holders and waiters; the entry is dropped when it reaches 0
+}
+
+// Locker is a set of mutexes, one per key, so work on the same key runs one at a time
+// while different keys run in parallel. A key's mutex exists only while it is held or
+// waited for, so the set stays as small as the number of busy keys.
+type Locker[K comparable] struct {
+	mu    sync.Mutex
+	locks map[K]*lockEntry
+}
+
+// New creates a Locker with no keys held
+func New[K comparable]() *Locker[K] {
+	return &Locker[K]{locks: make(map[K]*lockEntry)}
+}
+
+// Lock blocks until key is free and returns the function that frees it again
+func (l *Locker[K]) Lock(key K) (unlock func()) {
+	l.mu.Lock()
+	entry, ok := l.locks[key]
+	if !ok {
+		entry = &lockEntry{}
+		l.locks[key] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			entry.mu.Unlock()
+
+			l.mu.Lock()
+			entry.refs--
+			if entry.refs == 0 {
+				delete(l.locks, key)
+			}
+			l.mu.Unlock()
+		})
+	}
+}
+
+// Len returns how many keys are held or waited for
+func (l *Locker[K]) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.locks)
+}
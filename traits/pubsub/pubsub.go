@@ -0,0 +1,57 @@
+package pubsub
+
+import "sync"
+
+// Broker fans published values out to every current subscriber within the process.
+// Publishing never blocks: a subscriber whose buffer is full misses the value.
+type Broker[T any] struct {
+	mu          sync.Mutex
+	subscribers map[chan T]struct{}
+}
+
+// NewBroker creates a broker without subscribers
+func NewBroker[T any]() *Broker[T] {
+	return &Broker[T]{subscribers: make(map[chan T]struct{})}
+}
+
+// Subscribe returns a channel receiving every value published from now on, buffering up
+// to buffer of them, and a function that unsubscribes and closes the channel
+func (b *Broker[T]) Subscribe(buffer int) (<-chan T, func()) {
+	ch := make(chan T, buffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends v to every subscriber and returns how many received it
+func (b *Broker[T]) Publish(v T) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delivered := 0
+	for ch := range b.subscribers {
+		select {
+		case ch <- v:
+			delivered++
+		default:
+		}
+	}
+	return delivered
+}
+
+// Subscribers returns the number of current subscribers
+func (b *Broker[T]) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}